@@ -0,0 +1,73 @@
+// Command rotate-datasource-secrets encrypts datasource and schedule
+// delivery secrets still stored in plaintext and re-wraps those sealed under
+// a previous master key.
+//
+// To rotate, set DATASOURCE_SECRET_KEY to the new key and list the old one
+// in DATASOURCE_PREVIOUS_SECRET_KEYS, run this command, then drop the old
+// key from the previous list.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/gujiaweiguo/goreport/internal/config"
+	"github.com/gujiaweiguo/goreport/internal/database"
+	"github.com/gujiaweiguo/goreport/internal/repository"
+	"github.com/gujiaweiguo/goreport/internal/schedule"
+	"github.com/gujiaweiguo/goreport/internal/secrets"
+)
+
+func main() {
+	generate := flag.Bool("generate-key", false, "print a new master key and exit")
+	flag.Parse()
+
+	if *generate {
+		key, err := secrets.GenerateKey()
+		if err != nil {
+			fmt.Printf("Failed to generate key: %v\n", err)
+			return
+		}
+		fmt.Println(key)
+		return
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Printf("Failed to load config: %v\n", err)
+		return
+	}
+
+	keyring, err := secrets.NewKeyring(cfg.Datasource.SecretKey, cfg.Datasource.PreviousSecretKeys...)
+	if err != nil {
+		fmt.Printf("Failed to load master keys: %v\n", err)
+		return
+	}
+	if !keyring.Enabled() {
+		fmt.Println("DATASOURCE_SECRET_KEY is not set")
+		return
+	}
+
+	db, err := database.Init(cfg.Database.DSN)
+	if err != nil {
+		fmt.Printf("Failed to connect to database: %v\n", err)
+		return
+	}
+
+	updated, err := repository.RewrapDatasourceSecrets(context.Background(), db, keyring)
+	if err != nil {
+		fmt.Printf("Failed to rotate datasource secrets after %d datasources: %v\n", updated, err)
+		return
+	}
+
+	fmt.Printf("Rotated secrets of %d datasources\n", updated)
+
+	updated, err = schedule.RewrapSecrets(context.Background(), db, keyring)
+	if err != nil {
+		fmt.Printf("Failed to rotate schedule secrets after %d schedules: %v\n", updated, err)
+		return
+	}
+
+	fmt.Printf("Rotated secrets of %d schedules\n", updated)
+}
@@ -65,13 +65,13 @@ CREATE TABLE IF NOT EXISTS data_sources (
     `database` VARCHAR(100) NOT NULL DEFAULT '' COMMENT '兼容新版本字段',
     database_name VARCHAR(100) NOT NULL,
     username VARCHAR(100) NOT NULL,
-    password VARCHAR(255) NOT NULL,
+    password TEXT NOT NULL COMMENT '加密存储',
     ssh_host VARCHAR(255) DEFAULT '',
     ssh_port INT DEFAULT 0,
     ssh_username VARCHAR(100) DEFAULT '',
-    ssh_password VARCHAR(255) DEFAULT '',
-    ssh_key TEXT,
-    ssh_key_phrase VARCHAR(255) DEFAULT '',
+    ssh_password TEXT COMMENT '加密存储',
+    ssh_key TEXT COMMENT '加密存储',
+    ssh_key_phrase TEXT COMMENT '加密存储',
     max_connections INT DEFAULT 10,
     query_timeout_seconds INT DEFAULT 30,
     config TEXT,
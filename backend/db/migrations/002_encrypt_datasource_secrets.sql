@@ -0,0 +1,12 @@
+-- 数据源敏感字段加密迁移脚本
+-- password / ssh_password / ssh_key / ssh_key_phrase 改为 AES-GCM 信封加密存储，
+-- 密文长于原 VARCHAR(255)，故扩展为 TEXT。
+-- 执行后运行 cmd/tools/rotate-datasource-secrets 加密已有的明文数据。
+
+USE goreport;
+
+ALTER TABLE data_sources
+    MODIFY password TEXT NOT NULL COMMENT '加密存储',
+    MODIFY ssh_password TEXT COMMENT '加密存储',
+    MODIFY ssh_key TEXT COMMENT '加密存储',
+    MODIFY ssh_key_phrase TEXT COMMENT '加密存储';
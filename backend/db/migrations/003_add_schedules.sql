@@ -0,0 +1,49 @@
+-- 定时报表数据库迁移脚本
+-- 添加报表调度表、调度执行记录表
+
+USE goreport;
+
+-- 报表调度表
+CREATE TABLE IF NOT EXISTS report_schedules (
+    id VARCHAR(36) PRIMARY KEY,
+    tenant_id VARCHAR(36) NOT NULL,
+    name VARCHAR(200) NOT NULL,
+    cron VARCHAR(100) NOT NULL COMMENT '标准五段 cron 表达式或 @daily 等描述符',
+    timezone VARCHAR(64) COMMENT '时区，为空时使用服务器时区',
+    target_type ENUM('report', 'dashboard') NOT NULL COMMENT '调度对象：报表/仪表盘快照',
+    target_id VARCHAR(36) NOT NULL,
+    format VARCHAR(20) NOT NULL COMMENT '导出格式：pdf/xlsx/docx/png，仪表盘为 json',
+    params JSON COMMENT '报表参数，以 @ 开头的字符串为相对日期，如 @today-7d',
+    delivery JSON COMMENT '投递方式：邮件收件人或 Webhook 地址',
+    max_retries INT DEFAULT 3 COMMENT '投递失败后的重试次数',
+    enabled BOOLEAN DEFAULT TRUE,
+    next_run_at DATETIME(3) NULL COMMENT '下次执行时间，停用时为空',
+    last_run_at DATETIME(3) NULL,
+    created_by VARCHAR(36),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP NULL DEFAULT NULL,
+    INDEX idx_tenant_id (tenant_id),
+    INDEX idx_next_run_at (enabled, next_run_at),
+    INDEX idx_deleted_at (deleted_at),
+    FOREIGN KEY (tenant_id) REFERENCES tenants(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='定时报表调度';
+
+-- 调度执行记录表
+CREATE TABLE IF NOT EXISTS schedule_runs (
+    id VARCHAR(36) PRIMARY KEY,
+    schedule_id VARCHAR(36) NOT NULL,
+    tenant_id VARCHAR(36) NOT NULL,
+    `trigger` ENUM('cron', 'manual') NOT NULL COMMENT '触发方式：定时/立即执行',
+    status ENUM('running', 'succeeded', 'failed') NOT NULL,
+    attempts INT DEFAULT 0 COMMENT '投递尝试次数，含重试',
+    file_name VARCHAR(255),
+    file_size BIGINT,
+    error TEXT COMMENT '失败原因',
+    started_at DATETIME(3) NOT NULL,
+    finished_at DATETIME(3) NULL,
+    INDEX idx_schedule_started (schedule_id, started_at),
+    INDEX idx_tenant_id (tenant_id),
+    FOREIGN KEY (schedule_id) REFERENCES report_schedules(id) ON DELETE CASCADE,
+    FOREIGN KEY (tenant_id) REFERENCES tenants(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='定时报表执行记录';
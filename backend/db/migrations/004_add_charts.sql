@@ -0,0 +1,22 @@
+-- 图表数据库迁移脚本
+-- 添加图表表
+
+USE goreport;
+
+-- 图表表
+CREATE TABLE IF NOT EXISTS charts (
+    id VARCHAR(36) PRIMARY KEY,
+    tenant_id VARCHAR(36) NOT NULL,
+    name VARCHAR(200) NOT NULL,
+    code VARCHAR(100),
+    type VARCHAR(50) NOT NULL COMMENT '图表类型：bar/line/area/pie/scatter',
+    config JSON NOT NULL COMMENT '图表配置JSON，含数据集维度、度量与分组系列',
+    status TINYINT DEFAULT 1,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP NULL DEFAULT NULL,
+    INDEX idx_tenant_id (tenant_id),
+    INDEX idx_code (code),
+    INDEX idx_deleted_at (deleted_at),
+    FOREIGN KEY (tenant_id) REFERENCES tenants(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='图表';
@@ -0,0 +1,7 @@
+-- 数据集下钻路径迁移脚本
+-- 为数据集添加层级下钻路径
+
+USE goreport;
+
+ALTER TABLE datasets
+    ADD COLUMN drill_paths JSON NULL COMMENT '下钻路径：[{name, fields}]，字段由粗到细，如 区域→城市→门店' AFTER config;
@@ -0,0 +1,31 @@
+-- 版本管理数据库迁移脚本
+-- 添加仪表盘、报表、图表的版本历史表与已发布版本号
+
+USE goreport;
+
+-- 版本历史表：每次保存记录一份快照
+CREATE TABLE IF NOT EXISTS resource_versions (
+    id VARCHAR(36) PRIMARY KEY,
+    tenant_id VARCHAR(36) NOT NULL,
+    resource_type ENUM('dashboard', 'report', 'chart') NOT NULL,
+    resource_id VARCHAR(36) NOT NULL,
+    number INT NOT NULL COMMENT '版本号，每个资源从 1 递增',
+    snapshot JSON NOT NULL COMMENT '保存时的名称与配置快照',
+    comment VARCHAR(500),
+    created_by VARCHAR(36),
+    created_at DATETIME(3) NOT NULL,
+    UNIQUE INDEX idx_resource_version (resource_type, resource_id, number),
+    INDEX idx_tenant_id (tenant_id),
+    FOREIGN KEY (tenant_id) REFERENCES tenants(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='仪表盘、报表、图表版本历史';
+
+-- 状态：0-草稿 1-已发布 2-停用；查看者看到 published_version 指向的版本，
+-- 为空时看到当前内容（版本管理之前发布的资源），首次编辑时自动固定为一个版本
+ALTER TABLE dashboards
+    ADD COLUMN published_version INT NULL COMMENT '查看者看到的版本号' AFTER status;
+
+ALTER TABLE reports
+    ADD COLUMN published_version INT NULL COMMENT '查看者看到的版本号' AFTER status;
+
+ALTER TABLE charts
+    ADD COLUMN published_version INT NULL COMMENT '查看者看到的版本号' AFTER status;
@@ -43,6 +43,12 @@ func RevokeToken(ctx context.Context, token string, expiresAt time.Time) error {
 	return blacklistStore.cache.Set(ctx, "default", "auth_blacklist", key, nil, []byte("1"), ttl)
 }
 
+// RevocationAvailable reports whether revoked tokens are remembered; without
+// a cache RevokeToken does nothing.
+func RevocationAvailable() bool {
+	return blacklistStore != nil && blacklistStore.cache != nil && !blacklistStore.cache.IsDegraded()
+}
+
 func IsTokenRevoked(ctx context.Context, token string) bool {
 	if blacklistStore == nil || blacklistStore.cache == nil {
 		return false
@@ -0,0 +1,218 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const EmbedClaimsKey contextKey = "embedClaims"
+
+// EmbedPasswordHeader carries the password of a password-protected embed
+// token. It is not read from the query, which request logs record.
+const EmbedPasswordHeader = "X-Embed-Password"
+
+// EmbedClaims grant read-only access to one dashboard or report of a tenant.
+// Params are report parameters and Filters dashboard filter values by filter
+// ID; both override what the embedding page asks for. Origins, when set,
+// restrict the pages the token may be used from.
+type EmbedClaims struct {
+	TenantID     string                 `json:"tenantId"`
+	ResourceType string                 `json:"resourceType"`
+	ResourceID   string                 `json:"resourceId"`
+	Params       map[string]interface{} `json:"params,omitempty"`
+	Filters      map[string]interface{} `json:"filters,omitempty"`
+	Origins      []string               `json:"origins,omitempty"`
+	PasswordMAC  string                 `json:"pwd,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// embedKey signs embed tokens. It is derived from the JWT secret so that an
+// embed token never validates as a user token, nor the other way round.
+func embedKey() []byte {
+	mac := hmac.New(sha256.New, []byte(jwtCfg.Secret))
+	mac.Write([]byte("embed"))
+	return mac.Sum(nil)
+}
+
+// embedPasswordMAC keys an embed password with the JWT secret, so the token
+// carries no hash that can be cracked without it.
+func embedPasswordMAC(password string) string {
+	mac := hmac.New(sha256.New, []byte(jwtCfg.Secret))
+	mac.Write([]byte("embed-password:" + password))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SetPassword protects the token with password.
+func (c *EmbedClaims) SetPassword(password string) error {
+	if jwtCfg == nil {
+		return errors.New("JWT config not initialized")
+	}
+	c.PasswordMAC = embedPasswordMAC(password)
+	return nil
+}
+
+// CheckPassword reports whether password opens the token.
+func (c *EmbedClaims) CheckPassword(password string) bool {
+	if jwtCfg == nil || c.PasswordMAC == "" {
+		return false
+	}
+	return hmac.Equal([]byte(c.PasswordMAC), []byte(embedPasswordMAC(password)))
+}
+
+func GenerateEmbedToken(claims *EmbedClaims, ttl time.Duration) (string, error) {
+	if jwtCfg == nil {
+		return "", errors.New("JWT config not initialized")
+	}
+
+	now := time.Now()
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ID:        fmt.Sprintf("embed-%d", now.UnixNano()),
+		Issuer:    jwtCfg.Issuer,
+		Subject:   claims.ResourceID,
+		Audience:  []string{jwtCfg.Audience},
+		ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		IssuedAt:  jwt.NewNumericDate(now),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(embedKey())
+}
+
+func ValidateEmbedToken(tokenString string) (*EmbedClaims, error) {
+	if jwtCfg == nil {
+		return nil, errors.New("JWT config not initialized")
+	}
+
+	token, err := jwt.ParseWithClaims(tokenString, &EmbedClaims{}, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+		return embedKey(), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if claims, ok := token.Claims.(*EmbedClaims); ok && token.Valid && claims.ResourceID != "" {
+		return claims, nil
+	}
+
+	return nil, errors.New("invalid token")
+}
+
+// AllowsOrigin reports whether the token may be used from origin.
+func (c *EmbedClaims) AllowsOrigin(origin string) bool {
+	if len(c.Origins) == 0 {
+		return true
+	}
+	origin = NormalizeOrigin(origin)
+	for _, allowed := range c.Origins {
+		if allowed == origin {
+			return true
+		}
+	}
+	return false
+}
+
+// NormalizeOrigin reduces an origin or URL to its lower-case scheme and
+// host, or returns "" when it has neither.
+func NormalizeOrigin(origin string) string {
+	parsed, err := url.Parse(strings.TrimSpace(origin))
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return ""
+	}
+	return strings.ToLower(parsed.Scheme + "://" + parsed.Host)
+}
+
+// EmbedMiddleware authenticates the embed routes with an embed token from
+// the Authorization header or the "token" query parameter, checking that it
+// is not revoked, is used from an allowed origin and, if it has one, comes
+// with its password. The tenant is set as for a user without roles, so
+// services treat the caller as a viewer.
+func EmbedMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authHeader := c.GetHeader("Authorization")
+		if authHeader == "" {
+			authHeader = c.Query("token")
+		}
+
+		if authHeader == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"message": "missing embed token",
+			})
+			c.Abort()
+			return
+		}
+
+		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+
+		if IsTokenRevoked(c.Request.Context(), tokenString) {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"message": "token revoked",
+			})
+			c.Abort()
+			return
+		}
+
+		claims, err := ValidateEmbedToken(tokenString)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"message": "invalid or expired token",
+			})
+			c.Abort()
+			return
+		}
+
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			origin = c.GetHeader("Referer")
+		}
+		if !claims.AllowsOrigin(origin) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"message": "origin not allowed",
+			})
+			c.Abort()
+			return
+		}
+
+		if claims.PasswordMAC != "" {
+			password := c.GetHeader(EmbedPasswordHeader)
+			if password == "" || !claims.CheckPassword(password) {
+				c.JSON(http.StatusUnauthorized, gin.H{
+					"success": false,
+					"message": "password required",
+				})
+				c.Abort()
+				return
+			}
+		}
+
+		c.Set(string(TenantIDKey), claims.TenantID)
+		c.Set(string(EmbedClaimsKey), claims)
+
+		c.Next()
+	}
+}
+
+func GetEmbedClaims(c *gin.Context) *EmbedClaims {
+	if claims, exists := c.Get(string(EmbedClaimsKey)); exists {
+		if ec, ok := claims.(*EmbedClaims); ok {
+			return ec
+		}
+	}
+	return nil
+}
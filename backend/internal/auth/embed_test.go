@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gujiaweiguo/goreport/internal/config"
+	"github.com/gujiaweiguo/goreport/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateAndValidateEmbedToken(t *testing.T) {
+	InitJWT(&config.JWTConfig{Secret: "test-secret", Issuer: "test", Audience: "test"})
+
+	token, err := GenerateEmbedToken(&EmbedClaims{
+		TenantID:     "tenant-1",
+		ResourceType: "dashboard",
+		ResourceID:   "dash-1",
+		Filters:      map[string]interface{}{"region": "east"},
+	}, time.Hour)
+	require.NoError(t, err)
+
+	claims, err := ValidateEmbedToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, "tenant-1", claims.TenantID)
+	assert.Equal(t, "dash-1", claims.ResourceID)
+	assert.Equal(t, "east", claims.Filters["region"])
+	assert.NotEmpty(t, claims.ID)
+
+	// Neither kind of token passes for the other.
+	_, err = ValidateToken(token)
+	assert.Error(t, err)
+	userToken, err := GenerateToken(&models.User{ID: "u-1", Role: "admin", TenantID: "tenant-1"})
+	require.NoError(t, err)
+	_, err = ValidateEmbedToken(userToken)
+	assert.Error(t, err)
+
+	expired, err := GenerateEmbedToken(&EmbedClaims{TenantID: "tenant-1", ResourceType: "report", ResourceID: "r-1"}, -time.Minute)
+	require.NoError(t, err)
+	_, err = ValidateEmbedToken(expired)
+	assert.Error(t, err)
+}
+
+func TestEmbedClaims_AllowsOrigin(t *testing.T) {
+	claims := &EmbedClaims{}
+	assert.True(t, claims.AllowsOrigin(""))
+
+	claims.Origins = []string{"https://app.example.com"}
+	assert.True(t, claims.AllowsOrigin("https://APP.example.com"))
+	assert.True(t, claims.AllowsOrigin("https://app.example.com/page?x=1"))
+	assert.False(t, claims.AllowsOrigin("https://other.example.com"))
+	assert.False(t, claims.AllowsOrigin(""))
+}
+
+func TestEmbedMiddleware(t *testing.T) {
+	InitJWT(&config.JWTConfig{Secret: "test-secret", Issuer: "test", Audience: "test"})
+	blacklistStore = nil
+
+	claims := &EmbedClaims{
+		TenantID:     "tenant-1",
+		ResourceType: "dashboard",
+		ResourceID:   "dash-1",
+		Origins:      []string{"https://app.example.com"},
+	}
+	require.NoError(t, claims.SetPassword("secret"))
+	token, err := GenerateEmbedToken(claims, time.Hour)
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(EmbedMiddleware())
+	r.GET("/embed", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"tenantId": GetTenantID(c), "resourceId": GetEmbedClaims(c).ResourceID, "roles": GetRoles(c)})
+	})
+
+	tests := []struct {
+		name     string
+		token    string
+		origin   string
+		password string
+		want     int
+	}{
+		{name: "missing token", origin: "https://app.example.com", password: "secret", want: http.StatusUnauthorized},
+		{name: "invalid token", token: "invalid", origin: "https://app.example.com", password: "secret", want: http.StatusUnauthorized},
+		{name: "other origin", token: token, origin: "https://other.example.com", password: "secret", want: http.StatusForbidden},
+		{name: "no origin", token: token, password: "secret", want: http.StatusForbidden},
+		{name: "wrong password", token: token, origin: "https://app.example.com", password: "wrong", want: http.StatusUnauthorized},
+		{name: "no password", token: token, origin: "https://app.example.com", want: http.StatusUnauthorized},
+		{name: "valid", token: token, origin: "https://app.example.com", password: "secret", want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/embed", nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.password != "" {
+				req.Header.Set(EmbedPasswordHeader, tt.password)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.want, w.Code)
+			if tt.want == http.StatusOK {
+				assert.Contains(t, w.Body.String(), `"tenantId":"tenant-1"`)
+				assert.Contains(t, w.Body.String(), `"resourceId":"dash-1"`)
+				assert.Contains(t, w.Body.String(), `"roles":null`)
+			}
+		})
+	}
+
+	// The token query parameter serves an iframe that cannot set headers;
+	// the password never comes from the query, which request logs record.
+	req := httptest.NewRequest(http.MethodGet, "/embed?token="+token+"&password=secret", nil)
+	req.Header.Set("Referer", "https://app.example.com/reports/1")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	req.Header.Set(EmbedPasswordHeader, "secret")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
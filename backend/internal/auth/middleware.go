@@ -23,10 +23,14 @@ var publicPaths = []string{
 	"/drag/list",
 }
 
-func AuthMiddleware() gin.HandlerFunc {
+// AuthMiddleware requires a user token on every path but the public ones
+// and those under the extra public prefixes, such as the embed routes that
+// authenticate with their own tokens.
+func AuthMiddleware(publicPrefixes ...string) gin.HandlerFunc {
+	public := append(append([]string{}, publicPaths...), publicPrefixes...)
 	return func(c *gin.Context) {
 		path := c.Request.URL.Path
-		for _, publicPath := range publicPaths {
+		for _, publicPath := range public {
 			if strings.HasPrefix(path, publicPath) {
 				c.Next()
 				return
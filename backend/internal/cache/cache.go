@@ -5,6 +5,7 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -49,11 +50,18 @@ func HashParams(params map[string]interface{}) string {
 		return "none"
 	}
 
+	// Map order is random; sort the names so equal params hash alike.
+	names := make([]string, 0, len(params))
+	for k := range params {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
 	key := strings.Builder{}
-	for k, v := range params {
+	for _, k := range names {
 		key.WriteString(k)
 		key.WriteString("=")
-		key.WriteString(fmt.Sprintf("%v", v))
+		key.WriteString(fmt.Sprintf("%v", params[k]))
 		key.WriteString("&")
 	}
 
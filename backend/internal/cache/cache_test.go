@@ -161,6 +161,14 @@ func TestHashParams(t *testing.T) {
 		result := HashParams(params)
 		assert.Len(t, result, 8)
 	})
+
+	t.Run("stable across map order", func(t *testing.T) {
+		params := map[string]interface{}{"a": 1, "b": 2, "c": 3, "d": 4, "e": 5, "f": 6}
+		first := HashParams(params)
+		for i := 0; i < 20; i++ {
+			assert.Equal(t, first, HashParams(params))
+		}
+	})
 }
 
 func TestCache_GetHitRate(t *testing.T) {
@@ -1,10 +1,17 @@
 package chart
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gujiaweiguo/goreport/internal/auth"
+	"github.com/gujiaweiguo/goreport/internal/dataset"
+	"github.com/gujiaweiguo/goreport/internal/models"
+	"github.com/gujiaweiguo/goreport/internal/version"
 )
 
 type Handler struct {
@@ -27,8 +34,13 @@ func (h *Handler) Create(c *gin.Context) {
 		c.JSON(http.StatusForbidden, gin.H{"success": false, "message": "tenant not found"})
 		return
 	}
+	req.CreatedBy = auth.GetUserID(c)
 
 	chart, err := h.service.Create(c.Request.Context(), &req)
+	if errors.Is(err, ErrInvalidChart) {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "failed to create chart"})
 		return
@@ -49,8 +61,13 @@ func (h *Handler) Update(c *gin.Context) {
 		c.JSON(http.StatusForbidden, gin.H{"success": false, "message": "tenant not found"})
 		return
 	}
+	req.UpdatedBy = auth.GetUserID(c)
 
 	chart, err := h.service.Update(c.Request.Context(), &req)
+	if errors.Is(err, ErrInvalidChart) {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "chart not found"})
 		return
@@ -93,7 +110,7 @@ func (h *Handler) Get(c *gin.Context) {
 		return
 	}
 
-	chart, err := h.service.Get(c.Request.Context(), id, tenantID)
+	chart, err := h.service.Get(version.ViewContext(c), id, tenantID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "chart not found"})
 		return
@@ -109,7 +126,7 @@ func (h *Handler) List(c *gin.Context) {
 		return
 	}
 
-	charts, err := h.service.List(c.Request.Context(), tenantID)
+	charts, err := h.service.List(version.ViewContext(c), tenantID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "failed to list charts"})
 		return
@@ -131,11 +148,191 @@ func (h *Handler) Render(c *gin.Context) {
 		return
 	}
 
-	config, err := h.service.Render(c.Request.Context(), id, tenantID)
-	if err != nil {
+	var drill *dataset.DrillState
+	if raw := c.Query("drill"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &drill); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "invalid drill state"})
+			return
+		}
+	}
+
+	option, err := h.service.Render(version.ViewContext(c), id, tenantID, drill)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "chart not found"})
+		return
+	case errors.Is(err, ErrInvalidChart):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	case err != nil:
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "failed to render chart"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"success": true, "result": map[string]interface{}{"config": config}, "message": "success"})
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": map[string]interface{}{"option": option}, "message": "success"})
+}
+
+type drillThroughRequest struct {
+	Row map[string]interface{} `json:"row" binding:"required"`
+}
+
+// DrillThrough resolves what clicking a row of the chart opens.
+func (h *Handler) DrillThrough(c *gin.Context) {
+	id := c.Query("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "id is required"})
+		return
+	}
+
+	var req drillThroughRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "invalid request"})
+		return
+	}
+
+	tenantID := auth.GetTenantID(c)
+	if tenantID == "" {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "message": "tenant not found"})
+		return
+	}
+
+	target, err := h.service.DrillThrough(version.ViewContext(c), id, tenantID, req.Row)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "chart not found"})
+		return
+	case errors.Is(err, ErrInvalidChart):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "failed to resolve drill-through"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": target, "message": "success"})
+}
+
+func (h *Handler) Versions(c *gin.Context) {
+	id := c.Query("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "id is required"})
+		return
+	}
+
+	tenantID := auth.GetTenantID(c)
+	if tenantID == "" {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "message": "tenant not found"})
+		return
+	}
+
+	versions, err := h.service.Versions(c.Request.Context(), id, tenantID)
+	if writeVersionError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": versions, "message": "success"})
+}
+
+func (h *Handler) Version(c *gin.Context) {
+	id := c.Query("id")
+	number, err := strconv.Atoi(c.Query("version"))
+	if id == "" || err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "id and version are required"})
+		return
+	}
+
+	tenantID := auth.GetTenantID(c)
+	if tenantID == "" {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "message": "tenant not found"})
+		return
+	}
+
+	result, err := h.service.Version(c.Request.Context(), id, tenantID, number)
+	if writeVersionError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": result, "message": "success"})
+}
+
+// Diff compares the versions given as the from and to query parameters; an
+// omitted or 0 version is the current draft.
+func (h *Handler) Diff(c *gin.Context) {
+	id := c.Query("id")
+	from, fromErr := strconv.Atoi(c.DefaultQuery("from", "0"))
+	to, toErr := strconv.Atoi(c.DefaultQuery("to", "0"))
+	if id == "" || fromErr != nil || toErr != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "invalid versions"})
+		return
+	}
+
+	tenantID := auth.GetTenantID(c)
+	if tenantID == "" {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "message": "tenant not found"})
+		return
+	}
+
+	diff, err := h.service.Diff(c.Request.Context(), id, tenantID, from, to)
+	if writeVersionError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": diff, "message": "success"})
+}
+
+type versionRequest struct {
+	ID      string `json:"id" binding:"required"`
+	Version int    `json:"version"`
+}
+
+// Publish pins a version for viewers; without one the latest save is
+// published.
+func (h *Handler) Publish(c *gin.Context) {
+	h.changeVersion(c, h.service.Publish, "chart published")
+}
+
+// Rollback restores a version into the draft.
+func (h *Handler) Rollback(c *gin.Context) {
+	h.changeVersion(c, h.service.Rollback, "chart rolled back")
+}
+
+func (h *Handler) changeVersion(c *gin.Context, change func(ctx context.Context, id, tenantID string, number int, userID string) (*models.Chart, error), message string) {
+	var req versionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "invalid request"})
+		return
+	}
+
+	tenantID := auth.GetTenantID(c)
+	if tenantID == "" {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "message": "tenant not found"})
+		return
+	}
+	if !version.CanEdit(auth.GetRoles(c)) {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "message": "insufficient permissions"})
+		return
+	}
+
+	chart, err := change(c.Request.Context(), req.ID, tenantID, req.Version, auth.GetUserID(c))
+	if writeVersionError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": chart, "message": message})
+}
+
+// writeVersionError writes the response for a failed version operation and
+// reports whether there was one.
+func writeVersionError(c *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "chart not found"})
+	case errors.Is(err, version.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "version not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "version operation failed"})
+	}
+	return true
 }
@@ -4,14 +4,17 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gujiaweiguo/goreport/internal/auth"
 	"github.com/gujiaweiguo/goreport/internal/dataset"
 	"github.com/gujiaweiguo/goreport/internal/models"
+	"github.com/gujiaweiguo/goreport/internal/version"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -39,7 +42,10 @@ type mockChartService struct {
 	getErr       error
 	listErr      error
 	renderErr    error
-	renderResult string
+	renderResult map[string]interface{}
+	renderDrill  *dataset.DrillState
+	drillTarget  *dataset.DrillThroughTarget
+	drillErr     error
 }
 
 func (m *mockChartService) Create(ctx context.Context, req *CreateRequest) (*models.Chart, error) {
@@ -74,13 +80,47 @@ func (m *mockChartService) List(ctx context.Context, tenantID string) ([]*models
 	return m.charts, nil
 }
 
-func (m *mockChartService) Render(ctx context.Context, id, tenantID string) (string, error) {
+func (m *mockChartService) Render(ctx context.Context, id, tenantID string, drill *dataset.DrillState) (map[string]interface{}, error) {
+	m.renderDrill = drill
 	if m.renderErr != nil {
-		return "", m.renderErr
+		return nil, m.renderErr
 	}
 	return m.renderResult, nil
 }
 
+func (m *mockChartService) DrillThrough(ctx context.Context, id, tenantID string, row map[string]interface{}) (*dataset.DrillThroughTarget, error) {
+	if m.drillErr != nil {
+		return nil, m.drillErr
+	}
+	return m.drillTarget, nil
+}
+
+func (m *mockChartService) Versions(ctx context.Context, id, tenantID string) ([]*version.Version, error) {
+	return []*version.Version{{ResourceID: id, Number: 1}}, nil
+}
+
+func (m *mockChartService) Version(ctx context.Context, id, tenantID string, number int) (*version.Version, error) {
+	return nil, version.ErrNotFound
+}
+
+func (m *mockChartService) Diff(ctx context.Context, id, tenantID string, from, to int) (*version.Diff, error) {
+	return &version.Diff{From: from, To: to}, nil
+}
+
+func (m *mockChartService) Publish(ctx context.Context, id, tenantID string, number int, userID string) (*models.Chart, error) {
+	if m.getErr != nil {
+		return nil, m.getErr
+	}
+	return m.chart, nil
+}
+
+func (m *mockChartService) Rollback(ctx context.Context, id, tenantID string, number int, userID string) (*models.Chart, error) {
+	if m.getErr != nil {
+		return nil, m.getErr
+	}
+	return m.chart, nil
+}
+
 func setChartTenantID(c *gin.Context, tenantID string) {
 	c.Set(string(auth.TenantIDKey), tenantID)
 }
@@ -290,7 +330,7 @@ func TestHandler_List_NoTenant(t *testing.T) {
 
 func TestHandler_Render_Success(t *testing.T) {
 	service := &mockChartService{
-		renderResult: `{"series":[{"name":"Series1","data":[1,2,3]}]}`,
+		renderResult: map[string]interface{}{"series": []interface{}{map[string]interface{}{"name": "Series1", "data": []int{1, 2, 3}}}},
 	}
 	handler := NewHandler(service)
 
@@ -302,6 +342,7 @@ func TestHandler_Render_Success(t *testing.T) {
 	handler.Render(c)
 
 	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"option":{"series":[{"data":[1,2,3],"name":"Series1"}]}`)
 }
 
 func TestHandler_Render_NoID(t *testing.T) {
@@ -351,7 +392,7 @@ func TestChartConfig_Marshal(t *testing.T) {
 func TestService_Update_CodeAndType(t *testing.T) {
 	queryExec := &mockQueryExecutor{}
 	repo := &mockRepository{}
-	svc := NewService(repo, queryExec)
+	svc := NewService(repo, queryExec, nil)
 
 	existingChart := &models.Chart{ID: "c1", Name: "Old", Code: "old-code", Type: "bar", TenantID: "tenant-1"}
 	repo.On("Get", mock.Anything, "c1", "tenant-1").Return(existingChart, nil).Once()
@@ -378,7 +419,7 @@ func TestService_Update_CodeAndType(t *testing.T) {
 func TestService_Render_NoDatasetID(t *testing.T) {
 	queryExec := &mockQueryExecutor{}
 	repo := &mockRepository{}
-	svc := NewService(repo, queryExec)
+	svc := NewService(repo, queryExec, nil)
 
 	configJSON, _ := json.Marshal(ChartConfig{
 		Series: []SeriesConfig{
@@ -399,19 +440,20 @@ func TestService_Render_NoDatasetID(t *testing.T) {
 
 	repo.On("Get", mock.Anything, "c1", "tenant-1").Return(existingChart, nil).Once()
 
-	result, err := svc.Render(context.Background(), "c1", "tenant-1")
+	result, err := svc.Render(context.Background(), "c1", "tenant-1", nil)
 	assert.NoError(t, err)
-	assert.NotEmpty(t, result)
-	assert.Contains(t, result, "Series 1")
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"name": "Series 1", "type": "bar", "data": []any{1.0, 2.0, 3.0}},
+	}, result["series"])
 
 	repo.AssertExpectations(t)
-	queryExec.AssertNotCalled(t, "Query")
+	queryExec.AssertNotCalled(t, "Export")
 }
 
 func TestService_Render_InvalidJSON(t *testing.T) {
 	queryExec := &mockQueryExecutor{}
 	repo := &mockRepository{}
-	svc := NewService(repo, queryExec)
+	svc := NewService(repo, queryExec, nil)
 
 	existingChart := &models.Chart{
 		ID:       "c1",
@@ -422,7 +464,7 @@ func TestService_Render_InvalidJSON(t *testing.T) {
 
 	repo.On("Get", mock.Anything, "c1", "tenant-1").Return(existingChart, nil).Once()
 
-	_, err := svc.Render(context.Background(), "c1", "tenant-1")
+	_, err := svc.Render(context.Background(), "c1", "tenant-1", nil)
 	assert.Error(t, err)
 
 	repo.AssertExpectations(t)
@@ -522,5 +564,87 @@ func TestHandler_Render_NotFound(t *testing.T) {
 
 	handler.Render(c)
 
-	assert.NotEqual(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestHandler_Render_InvalidChart(t *testing.T) {
+	service := &mockChartService{renderErr: fmt.Errorf("%w: unsupported chart type \"radar\"", ErrInvalidChart)}
+	handler := NewHandler(service)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/charts/render?id=chart-1", nil)
+	setChartTenantID(c, "tenant-1")
+
+	handler.Render(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "radar")
+}
+
+func TestHandler_Render_DrillState(t *testing.T) {
+	service := &mockChartService{renderResult: map[string]interface{}{}}
+	handler := NewHandler(service)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/charts/render?id=chart-1&drill="+url.QueryEscape(`{"path":"geo","values":["North"]}`), nil)
+	setChartTenantID(c, "tenant-1")
+	handler.Render(c)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, &dataset.DrillState{Path: "geo", Values: []interface{}{"North"}}, service.renderDrill)
+
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/charts/render?id=chart-1&drill=oops", nil)
+	setChartTenantID(c, "tenant-1")
+	handler.Render(c)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestHandler_DrillThrough(t *testing.T) {
+	service := &mockChartService{drillTarget: &dataset.DrillThroughTarget{TargetType: "report", TargetID: "report-1", Params: map[string]interface{}{"region": "North"}}}
+	handler := NewHandler(service)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/charts/drill-through?id=chart-1", bytes.NewBufferString(`{"row":{"region":"North"}}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	setChartTenantID(c, "tenant-1")
+	handler.DrillThrough(c)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"targetId":"report-1"`)
+
+	service.drillErr = fmt.Errorf("%w: chart has no drill-through", ErrInvalidChart)
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/charts/drill-through?id=chart-1", bytes.NewBufferString(`{"row":{"region":"North"}}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	setChartTenantID(c, "tenant-1")
+	handler.DrillThrough(c)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestHandler_Publish(t *testing.T) {
+	handler := NewHandler(&mockChartService{chart: &models.Chart{ID: "c1", PublishedVersion: 3}})
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/charts/publish", bytes.NewBufferString(`{"id":"c1","version":3}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	setChartTenantID(c, "tenant-1")
+	c.Set(string(auth.RolesKey), []string{"user"})
+	handler.Publish(c)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"publishedVersion":3`)
+
+	handler = NewHandler(&mockChartService{getErr: ErrNotFound})
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/charts/rollback", bytes.NewBufferString(`{"id":"missing","version":1}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	setChartTenantID(c, "tenant-1")
+	c.Set(string(auth.RolesKey), []string{"user"})
+	handler.Rollback(c)
+	assert.Equal(t, http.StatusNotFound, w.Code)
 }
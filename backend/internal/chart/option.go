@@ -0,0 +1,396 @@
+package chart
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gujiaweiguo/goreport/internal/dataset"
+)
+
+const (
+	TypeBar     = "bar"
+	TypeLine    = "line"
+	TypeArea    = "area"
+	TypePie     = "pie"
+	TypeScatter = "scatter"
+
+	measureAliasPrefix = "measure_"
+	// maxCategories bounds the categories, and the series of a grouping
+	// dimension, a chart may draw.
+	maxCategories = 1000
+)
+
+var ErrInvalidChart = errors.New("invalid chart")
+
+var chartTypes = map[string]bool{TypeBar: true, TypeLine: true, TypeArea: true, TypePie: true, TypeScatter: true}
+
+var measureAggregations = map[string]bool{"SUM": true, "AVG": true, "COUNT": true, "MIN": true, "MAX": true}
+
+// MeasureConfig is a numeric field drawn as a series, aggregated over each
+// category. Type overrides the chart type for this measure's series, to mix
+// bars and lines.
+type MeasureConfig struct {
+	Field       string `json:"field"`
+	Aggregation string `json:"aggregation,omitempty"`
+	Label       string `json:"label,omitempty"`
+	Type        string `json:"type,omitempty"`
+}
+
+// binding is the query result of a dataset-bound chart laid out for ECharts.
+type binding struct {
+	categories []string
+	series     []boundSeries
+}
+
+type boundSeries struct {
+	name  string
+	typ   string
+	data  []interface{}
+	stack bool
+}
+
+// effectiveType defaults charts saved before types were validated to bars.
+func effectiveType(chartType string) string {
+	if chartType == "" {
+		return TypeBar
+	}
+	return chartType
+}
+
+// normalizeBinding validates the dataset binding of a chart and fills its
+// defaults. A chart without a dataset keeps its static series.
+func normalizeBinding(chartType string, config *ChartConfig) error {
+	if !chartTypes[chartType] {
+		return fmt.Errorf("%w: unsupported chart type %q", ErrInvalidChart, chartType)
+	}
+	if config.DatasetID == "" {
+		return nil
+	}
+	if len(config.Dimensions) == 0 {
+		return fmt.Errorf("%w: a dataset chart needs a dimension", ErrInvalidChart)
+	}
+	if len(config.Measures) == 0 {
+		return fmt.Errorf("%w: a dataset chart needs a measure", ErrInvalidChart)
+	}
+	if config.SeriesBy != "" {
+		if chartType == TypePie {
+			return fmt.Errorf("%w: pie charts cannot split series by %q", ErrInvalidChart, config.SeriesBy)
+		}
+		for _, dimension := range config.Dimensions {
+			if dimension == config.SeriesBy {
+				return fmt.Errorf("%w: %q is both a dimension and the series grouping", ErrInvalidChart, dimension)
+			}
+		}
+	}
+	for i := range config.Measures {
+		measure := &config.Measures[i]
+		if measure.Field == "" {
+			return fmt.Errorf("%w: measure %d has no field", ErrInvalidChart, i)
+		}
+		measure.Aggregation = strings.ToUpper(measure.Aggregation)
+		if measure.Aggregation == "" {
+			measure.Aggregation = "SUM"
+		}
+		if !measureAggregations[measure.Aggregation] {
+			return fmt.Errorf("%w: unsupported aggregation %q", ErrInvalidChart, measure.Aggregation)
+		}
+		if measure.Type != "" && (!chartTypes[measure.Type] || measure.Type == TypePie) {
+			return fmt.Errorf("%w: unsupported series type %q", ErrInvalidChart, measure.Type)
+		}
+	}
+	if config.Limit < 0 || config.Limit > maxCategories {
+		return fmt.Errorf("%w: limit must be between 0 and %d", ErrInvalidChart, maxCategories)
+	}
+	if config.DrillThrough != nil {
+		if err := config.DrillThrough.Validate(); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidChart, err)
+		}
+	}
+	return nil
+}
+
+// drilledDimensions reads the dimensions back from the fields of a drilled
+// query, where the drill level has replaced the path fields.
+func drilledDimensions(config *ChartConfig, fields []string) []string {
+	if config.SeriesBy != "" && len(fields) > 0 && fields[len(fields)-1] == config.SeriesBy {
+		fields = fields[:len(fields)-1]
+	}
+	return append([]string(nil), fields...)
+}
+
+// queryRequest groups the dataset by the dimensions and the series grouping,
+// aggregating each measure under its own alias.
+func queryRequest(config *ChartConfig) *dataset.QueryRequest {
+	groupBy := append([]string(nil), config.Dimensions...)
+	if config.SeriesBy != "" {
+		groupBy = append(groupBy, config.SeriesBy)
+	}
+	req := &dataset.QueryRequest{
+		DatasetID:    config.DatasetID,
+		Fields:       groupBy,
+		GroupBy:      groupBy,
+		Filters:      config.Filters,
+		SortBy:       config.SortBy,
+		SortOrder:    config.SortOrder,
+		Aggregations: make(map[string]dataset.Aggregation, len(config.Measures)),
+	}
+	for i, measure := range config.Measures {
+		alias := measureAlias(i)
+		req.Aggregations[alias] = dataset.Aggregation{Function: measure.Aggregation, Field: measure.Field}
+		if config.SortBy == measure.Field {
+			req.SortBy = alias
+		}
+	}
+	if req.SortBy == "" {
+		req.SortBy = config.Dimensions[0]
+	}
+	return req
+}
+
+func measureAlias(i int) string {
+	return measureAliasPrefix + strconv.Itoa(i)
+}
+
+// bindRecords lays query records out as categories, joined from the
+// dimensions in record order, and one series per measure, or per measure
+// and value of the grouping dimension.
+func bindRecords(chartType string, config *ChartConfig, records []map[string]interface{}) (*binding, error) {
+	b := &binding{}
+	categoryIndex := map[string]int{}
+	for _, record := range records {
+		category := categoryLabel(record, config.Dimensions)
+		if _, ok := categoryIndex[category]; ok {
+			continue
+		}
+		if config.Limit > 0 && len(b.categories) == config.Limit {
+			continue
+		}
+		categoryIndex[category] = len(b.categories)
+		b.categories = append(b.categories, category)
+	}
+	if len(b.categories) > maxCategories {
+		return nil, fmt.Errorf("%w: more than %d categories", ErrInvalidChart, maxCategories)
+	}
+
+	var groups []string
+	groupIndex := map[string]int{}
+	if config.SeriesBy != "" {
+		for _, record := range records {
+			group := categoryLabel(record, []string{config.SeriesBy})
+			if _, ok := groupIndex[group]; !ok {
+				groupIndex[group] = len(groups)
+				groups = append(groups, group)
+			}
+		}
+		if len(groups)*len(config.Measures) > maxCategories {
+			return nil, fmt.Errorf("%w: more than %d series", ErrInvalidChart, maxCategories)
+		}
+	} else {
+		groups = []string{""}
+		groupIndex[""] = 0
+	}
+
+	for _, group := range groups {
+		for _, measure := range config.Measures {
+			name := measure.Label
+			if name == "" {
+				name = measure.Field
+			}
+			if config.SeriesBy != "" {
+				if len(config.Measures) == 1 {
+					name = group
+				} else {
+					name = group + " - " + name
+				}
+			}
+			typ := measure.Type
+			if typ == "" {
+				typ = chartType
+			}
+			b.series = append(b.series, boundSeries{
+				name:  name,
+				typ:   typ,
+				data:  make([]interface{}, len(b.categories)),
+				stack: config.Stack && config.SeriesBy != "",
+			})
+		}
+	}
+
+	for _, record := range records {
+		col, ok := categoryIndex[categoryLabel(record, config.Dimensions)]
+		if !ok {
+			continue
+		}
+		group := 0
+		if config.SeriesBy != "" {
+			group = groupIndex[categoryLabel(record, []string{config.SeriesBy})]
+		}
+		for i := range config.Measures {
+			b.series[group*len(config.Measures)+i].data[col] = numericValue(record[measureAlias(i)])
+		}
+	}
+	return b, nil
+}
+
+func categoryLabel(record map[string]interface{}, fields []string) string {
+	parts := make([]string, len(fields))
+	for i, field := range fields {
+		parts[i] = textValue(record[field])
+	}
+	return strings.Join(parts, " / ")
+}
+
+func textValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// numericValue converts a database value to a number ECharts can plot; a
+// missing or non-numeric value is a gap.
+func numericValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case float64:
+		return v
+	case float32:
+		return float64(v)
+	case int:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	case []byte:
+		return numericValue(string(v))
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return nil
+		}
+		return f
+	default:
+		return nil
+	}
+}
+
+// buildOption assembles the ECharts option of a chart from its bound data,
+// or its static series when it has no dataset. Options from the config are
+// merged in last, so the designer can set anything ECharts supports.
+func buildOption(chartType string, config *ChartConfig, b *binding) map[string]interface{} {
+	if b == nil {
+		b = staticBinding(chartType, config)
+	}
+
+	option := map[string]interface{}{}
+	if config.Title != "" {
+		option["title"] = map[string]interface{}{"text": config.Title}
+	}
+	names := make([]string, len(b.series))
+	for i, s := range b.series {
+		names[i] = s.name
+	}
+
+	if chartType == TypePie {
+		option["tooltip"] = map[string]interface{}{"trigger": "item"}
+		option["legend"] = map[string]interface{}{"data": b.categories}
+		series := make([]interface{}, 0, len(b.series))
+		for _, s := range b.series {
+			data := make([]interface{}, len(s.data))
+			for i, value := range s.data {
+				name := ""
+				if i < len(b.categories) {
+					name = b.categories[i]
+				}
+				data[i] = map[string]interface{}{"name": name, "value": value}
+			}
+			series = append(series, map[string]interface{}{"name": s.name, "type": TypePie, "radius": "60%", "data": data})
+		}
+		option["series"] = series
+		return mergeOptions(option, config.Options)
+	}
+
+	option["tooltip"] = map[string]interface{}{"trigger": "axis"}
+	option["legend"] = map[string]interface{}{"data": names}
+	xAxis := map[string]interface{}{"type": "category", "data": b.categories}
+	yAxis := map[string]interface{}{"type": "value"}
+	if config.XAxis != nil {
+		if config.XAxis.Name != "" {
+			xAxis["name"] = config.XAxis.Name
+		}
+		if config.XAxis.Type != "" {
+			xAxis["type"] = config.XAxis.Type
+		}
+	}
+	if config.YAxis != nil {
+		if config.YAxis.Name != "" {
+			yAxis["name"] = config.YAxis.Name
+		}
+		if config.YAxis.Type != "" {
+			yAxis["type"] = config.YAxis.Type
+		}
+	}
+	option["xAxis"] = xAxis
+	option["yAxis"] = yAxis
+
+	series := make([]interface{}, 0, len(b.series))
+	for _, s := range b.series {
+		entry := map[string]interface{}{"name": s.name, "type": s.typ, "data": s.data}
+		if s.typ == TypeArea {
+			entry["type"] = TypeLine
+			entry["areaStyle"] = map[string]interface{}{}
+		}
+		if s.stack {
+			entry["stack"] = "total"
+		}
+		series = append(series, entry)
+	}
+	option["series"] = series
+	return mergeOptions(option, config.Options)
+}
+
+// staticBinding takes the categories and series data stored in the config.
+func staticBinding(chartType string, config *ChartConfig) *binding {
+	b := &binding{}
+	if config.XAxis != nil {
+		b.categories = config.XAxis.Data
+	}
+	for _, s := range config.Series {
+		typ := s.Type
+		if typ == "" {
+			typ = chartType
+		}
+		data := s.Data
+		if data == nil {
+			data = []interface{}{}
+		}
+		b.series = append(b.series, boundSeries{name: s.Name, typ: typ, data: data})
+	}
+	return b
+}
+
+// mergeOptions overlays extra onto option, merging nested objects key by key.
+func mergeOptions(option, extra map[string]interface{}) map[string]interface{} {
+	for key, value := range extra {
+		nested, ok := value.(map[string]interface{})
+		existing, isMap := option[key].(map[string]interface{})
+		if ok && isMap {
+			option[key] = mergeOptions(existing, nested)
+			continue
+		}
+		option[key] = value
+	}
+	return option
+}
@@ -13,6 +13,9 @@ type Repository interface {
 	Delete(ctx context.Context, id, tenantID string) error
 	Get(ctx context.Context, id, tenantID string) (*models.Chart, error)
 	List(ctx context.Context, tenantID string) ([]*models.Chart, error)
+	// Transaction runs fn with the repository bound to one transaction; tx
+	// lets fn write other tables in it.
+	Transaction(ctx context.Context, fn func(repo Repository, tx *gorm.DB) error) error
 }
 
 type repository struct {
@@ -59,3 +62,9 @@ func (r *repository) List(ctx context.Context, tenantID string) ([]*models.Chart
 	}
 	return charts, nil
 }
+
+func (r *repository) Transaction(ctx context.Context, fn func(repo Repository, tx *gorm.DB) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&repository{db: tx}, tx)
+	})
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/gujiaweiguo/goreport/internal/dataset"
 	"github.com/gujiaweiguo/goreport/internal/models"
+	"github.com/gujiaweiguo/goreport/internal/version"
 )
 
 var ErrNotFound = errors.New("chart not found")
@@ -19,41 +20,69 @@ type Service interface {
 	Delete(ctx context.Context, id, tenantID string) error
 	Get(ctx context.Context, id, tenantID string) (*models.Chart, error)
 	List(ctx context.Context, tenantID string) ([]*models.Chart, error)
-	Render(ctx context.Context, id, tenantID string) (string, error)
+	Render(ctx context.Context, id, tenantID string, drill *dataset.DrillState) (map[string]interface{}, error)
+	DrillThrough(ctx context.Context, id, tenantID string, row map[string]interface{}) (*dataset.DrillThroughTarget, error)
+	Versions(ctx context.Context, id, tenantID string) ([]*version.Version, error)
+	Version(ctx context.Context, id, tenantID string, number int) (*version.Version, error)
+	Diff(ctx context.Context, id, tenantID string, from, to int) (*version.Diff, error)
+	Publish(ctx context.Context, id, tenantID string, number int, userID string) (*models.Chart, error)
+	Rollback(ctx context.Context, id, tenantID string, number int, userID string) (*models.Chart, error)
 }
 
 type service struct {
 	repo          Repository
 	queryExecutor dataset.QueryExecutor
+	history       *version.History
 }
 
-func NewService(repo Repository, queryExecutor dataset.QueryExecutor) Service {
-	return &service{repo: repo, queryExecutor: queryExecutor}
+func NewService(repo Repository, queryExecutor dataset.QueryExecutor, versions version.Repository) Service {
+	return &service{repo: repo, queryExecutor: queryExecutor, history: version.NewHistory(versions, version.ResourceChart)}
 }
 
 type CreateRequest struct {
-	TenantID string      `json:"-"`
-	Name     string      `json:"name" binding:"required"`
-	Code     string      `json:"code"`
-	Type     string      `json:"type" binding:"required"`
-	Config   ChartConfig `json:"config" binding:"required"`
+	TenantID  string      `json:"-"`
+	CreatedBy string      `json:"-"`
+	Name      string      `json:"name" binding:"required"`
+	Code      string      `json:"code"`
+	Type      string      `json:"type" binding:"required"`
+	Config    ChartConfig `json:"config" binding:"required"`
 }
 
 type UpdateRequest struct {
-	TenantID string      `json:"-"`
-	ID       string      `json:"id" binding:"required"`
-	Name     string      `json:"name"`
-	Code     string      `json:"code"`
-	Type     string      `json:"type"`
-	Config   ChartConfig `json:"config"`
+	TenantID  string      `json:"-"`
+	UpdatedBy string      `json:"-"`
+	ID        string      `json:"id" binding:"required"`
+	Name      string      `json:"name"`
+	Code      string      `json:"code"`
+	Type      string      `json:"type"`
+	Config    ChartConfig `json:"config"`
 }
 
+// ChartConfig describes a chart either by static series or by a dataset
+// binding: Dimensions become the axis categories, Measures the series, and
+// SeriesBy splits each measure into one series per value. DrillPath names
+// the dataset drill path clicks follow, and DrillThrough what a click opens.
+// Options is merged into the rendered ECharts option last.
 type ChartConfig struct {
 	Title  string                 `json:"title"`
 	XAxis  *AxisConfig            `json:"xAxis,omitempty"`
 	YAxis  *AxisConfig            `json:"yAxis,omitempty"`
 	Series []SeriesConfig         `json:"series"`
 	Params map[string]interface{} `json:"params,omitempty"`
+
+	DatasetID  string                 `json:"datasetId,omitempty"`
+	Dimensions []string               `json:"dimensions,omitempty"`
+	Measures   []MeasureConfig        `json:"measures,omitempty"`
+	SeriesBy   string                 `json:"seriesBy,omitempty"`
+	Filters    []dataset.Filter       `json:"filters,omitempty"`
+	SortBy     string                 `json:"sortBy,omitempty"`
+	SortOrder  string                 `json:"sortOrder,omitempty"`
+	Limit      int                    `json:"limit,omitempty"`
+	Stack      bool                   `json:"stack,omitempty"`
+	Options    map[string]interface{} `json:"options,omitempty"`
+
+	DrillPath    string                `json:"drillPath,omitempty"`
+	DrillThrough *dataset.DrillThrough `json:"drillThrough,omitempty"`
 }
 
 type AxisConfig struct {
@@ -71,6 +100,9 @@ type SeriesConfig struct {
 }
 
 func (s *service) Create(ctx context.Context, req *CreateRequest) (*models.Chart, error) {
+	if err := normalizeBinding(req.Type, &req.Config); err != nil {
+		return nil, err
+	}
 	configJSON, err := json.Marshal(req.Config)
 	if err != nil {
 		return nil, err
@@ -83,10 +115,16 @@ func (s *service) Create(ctx context.Context, req *CreateRequest) (*models.Chart
 		Code:     req.Code,
 		Type:     req.Type,
 		Config:   string(configJSON),
-		Status:   1,
+		Status:   version.StatusDraft,
 	}
 
-	if err := s.repo.Create(ctx, chart); err != nil {
+	if err := s.inTx(ctx, func(repo Repository, history *version.History) error {
+		if err := repo.Create(ctx, chart); err != nil {
+			return err
+		}
+		_, err := history.Record(ctx, chart.TenantID, chart.ID, req.CreatedBy, "", snapshotOf(chart))
+		return err
+	}); err != nil {
 		return nil, err
 	}
 
@@ -98,25 +136,37 @@ func (s *service) Update(ctx context.Context, req *UpdateRequest) (*models.Chart
 	if err != nil {
 		return nil, ErrNotFound
 	}
+	if err := s.inTx(ctx, func(repo Repository, history *version.History) error {
+		if err := s.pinPublished(ctx, history, chart, req.UpdatedBy); err != nil {
+			return err
+		}
 
-	if req.Name != "" {
-		chart.Name = req.Name
-	}
-	if req.Code != "" {
-		chart.Code = req.Code
-	}
-	if req.Type != "" {
-		chart.Type = req.Type
-	}
-	if req.Config.Series != nil {
-		configJSON, err := json.Marshal(req.Config)
-		if err != nil {
-			return nil, err
+		if req.Name != "" {
+			chart.Name = req.Name
+		}
+		if req.Code != "" {
+			chart.Code = req.Code
+		}
+		if req.Type != "" {
+			chart.Type = req.Type
+		}
+		if req.Config.Series != nil || req.Config.DatasetID != "" {
+			if err := normalizeBinding(effectiveType(chart.Type), &req.Config); err != nil {
+				return err
+			}
+			configJSON, err := json.Marshal(req.Config)
+			if err != nil {
+				return err
+			}
+			chart.Config = string(configJSON)
 		}
-		chart.Config = string(configJSON)
-	}
 
-	if err := s.repo.Update(ctx, chart); err != nil {
+		if err := repo.Update(ctx, chart); err != nil {
+			return err
+		}
+		_, err := history.Record(ctx, chart.TenantID, chart.ID, req.UpdatedBy, "", snapshotOf(chart))
+		return err
+	}); err != nil {
 		return nil, err
 	}
 
@@ -127,54 +177,108 @@ func (s *service) Delete(ctx context.Context, id, tenantID string) error {
 	return s.repo.Delete(ctx, id, tenantID)
 }
 
+// Get returns the draft of a chart, or to a viewer its published version.
 func (s *service) Get(ctx context.Context, id, tenantID string) (*models.Chart, error) {
-	chart, err := s.repo.Get(ctx, id, tenantID)
-	if err != nil {
-		return nil, ErrNotFound
-	}
-	return chart, nil
+	return s.load(ctx, id, tenantID)
 }
 
+// List returns the charts of a tenant; a viewer sees the published ones.
 func (s *service) List(ctx context.Context, tenantID string) ([]*models.Chart, error) {
-	return s.repo.List(ctx, tenantID)
+	charts, err := s.repo.List(ctx, tenantID)
+	if err != nil || !version.Published(ctx) {
+		return charts, err
+	}
+	published := make([]*models.Chart, 0, len(charts))
+	for _, chart := range charts {
+		if chart.Status == version.StatusPublished {
+			published = append(published, chart)
+		}
+	}
+	return published, nil
 }
 
-func (s *service) Render(ctx context.Context, id, tenantID string) (string, error) {
-	chart, err := s.repo.Get(ctx, id, tenantID)
+// Render builds the ECharts option of a chart, querying its dataset binding,
+// or the dataset of each legacy static series, on behalf of the tenant. A
+// drill state replaces the dimensions with the drilled level.
+func (s *service) Render(ctx context.Context, id, tenantID string, drill *dataset.DrillState) (map[string]interface{}, error) {
+	chart, err := s.load(ctx, id, tenantID)
 	if err != nil {
-		return "", ErrNotFound
+		return nil, err
 	}
 
 	var config ChartConfig
 	if err := json.Unmarshal([]byte(chart.Config), &config); err != nil {
-		return "", err
+		return nil, err
+	}
+	chartType := effectiveType(chart.Type)
+	if err := normalizeBinding(chartType, &config); err != nil {
+		return nil, err
 	}
 
-	for i, series := range config.Series {
-		if series.DatasetID != "" {
-			query := series.Query
-			query.DatasetID = series.DatasetID
-			resp, err := s.queryExecutor.Query(ctx, &query)
-			if err != nil {
-				return "", err
+	if config.DatasetID != "" {
+		req := queryRequest(&config)
+		if drill != nil {
+			state := *drill
+			if state.Path == "" {
+				state.Path = config.DrillPath
 			}
+			req.Drill = &state
+		}
+		records, err := dataset.QueryRecords(ctx, s.queryExecutor, tenantID, req)
+		if errors.Is(err, dataset.ErrInvalidDrill) {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidChart, err)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if drill != nil {
+			config.Dimensions = drilledDimensions(&config, req.Fields)
+		}
+		bound, err := bindRecords(chartType, &config, records)
+		if err != nil {
+			return nil, err
+		}
+		return buildOption(chartType, &config, bound), nil
+	}
 
-			if resp.Data != nil {
-				data := make([]any, 0, len(resp.Data))
-				for _, row := range resp.Data {
-					if val, exists := row[series.Name]; exists {
-						data = append(data, val)
-					}
-				}
-				config.Series[i].Data = data
+	for i, series := range config.Series {
+		if series.DatasetID == "" {
+			continue
+		}
+		query := series.Query
+		query.DatasetID = series.DatasetID
+		records, err := dataset.QueryRecords(ctx, s.queryExecutor, tenantID, &query)
+		if err != nil {
+			return nil, err
+		}
+		data := make([]any, 0, len(records))
+		for _, row := range records {
+			if val, exists := row[series.Name]; exists {
+				data = append(data, numericValue(val))
 			}
 		}
+		config.Series[i].Data = data
 	}
 
-	resultJSON, err := json.Marshal(config)
+	return buildOption(chartType, &config, nil), nil
+}
+
+// DrillThrough resolves the chart's drill-through for a clicked row.
+func (s *service) DrillThrough(ctx context.Context, id, tenantID string, row map[string]interface{}) (*dataset.DrillThroughTarget, error) {
+	chart, err := s.load(ctx, id, tenantID)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-
-	return string(resultJSON), nil
+	var config ChartConfig
+	if err := json.Unmarshal([]byte(chart.Config), &config); err != nil {
+		return nil, err
+	}
+	if config.DrillThrough == nil {
+		return nil, fmt.Errorf("%w: chart has no drill-through", ErrInvalidChart)
+	}
+	target, err := config.DrillThrough.Resolve(row)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidChart, err)
+	}
+	return target, nil
 }
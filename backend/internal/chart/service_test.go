@@ -10,6 +10,8 @@ import (
 	"github.com/gujiaweiguo/goreport/internal/models"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
 )
 
 type mockQueryExecutor struct {
@@ -24,6 +26,22 @@ func (m *mockQueryExecutor) Query(ctx context.Context, req *dataset.QueryRequest
 	return args.Get(0).(*dataset.QueryResponse), args.Error(1)
 }
 
+func (m *mockQueryExecutor) Export(ctx context.Context, tenantID string, req *dataset.QueryRequest, w dataset.RowWriter) (int64, error) {
+	args := m.Called(ctx, tenantID, req, w)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// writeRows makes an Export expectation write header and rows to its RowWriter.
+func writeRows(header []string, rows ...[]interface{}) func(mock.Arguments) {
+	return func(args mock.Arguments) {
+		w := args.Get(3).(dataset.RowWriter)
+		_ = w.WriteHeader(header)
+		for _, row := range rows {
+			_ = w.WriteRow(row)
+		}
+	}
+}
+
 type mockRepository struct {
 	mock.Mock
 }
@@ -38,6 +56,10 @@ func (m *mockRepository) Update(ctx context.Context, chart *models.Chart) error
 	return args.Error(0)
 }
 
+func (m *mockRepository) Transaction(ctx context.Context, fn func(repo Repository, tx *gorm.DB) error) error {
+	return fn(m, nil)
+}
+
 func (m *mockRepository) Delete(ctx context.Context, id, tenantID string) error {
 	args := m.Called(ctx, id, tenantID)
 	return args.Error(0)
@@ -62,7 +84,7 @@ func (m *mockRepository) List(ctx context.Context, tenantID string) ([]*models.C
 func TestNewService(t *testing.T) {
 	queryExec := &mockQueryExecutor{}
 	repo := &mockRepository{}
-	svc := NewService(repo, queryExec)
+	svc := NewService(repo, queryExec, nil)
 	assert.NotNil(t, svc)
 }
 
@@ -70,7 +92,7 @@ func TestService_Create(t *testing.T) {
 	t.Run("成功创建图表", func(t *testing.T) {
 		queryExec := &mockQueryExecutor{}
 		repo := &mockRepository{}
-		svc := NewService(repo, queryExec)
+		svc := NewService(repo, queryExec, nil)
 
 		repo.On("Create", mock.Anything, mock.MatchedBy(func(c *models.Chart) bool {
 			return c.Name == "Test Chart"
@@ -102,7 +124,7 @@ func TestService_Create(t *testing.T) {
 	t.Run("创建失败-Repository错误", func(t *testing.T) {
 		queryExec := &mockQueryExecutor{}
 		repo := &mockRepository{}
-		svc := NewService(repo, queryExec)
+		svc := NewService(repo, queryExec, nil)
 
 		repo.On("Create", mock.Anything, mock.Anything).Return(errors.New("db error")).Once()
 
@@ -125,7 +147,7 @@ func TestService_Get(t *testing.T) {
 	t.Run("成功获取图表", func(t *testing.T) {
 		queryExec := &mockQueryExecutor{}
 		repo := &mockRepository{}
-		svc := NewService(repo, queryExec)
+		svc := NewService(repo, queryExec, nil)
 
 		existingChart := &models.Chart{ID: "c1", Name: "Test Chart", TenantID: "tenant-1"}
 		repo.On("Get", mock.Anything, "c1", "tenant-1").Return(existingChart, nil).Once()
@@ -140,7 +162,7 @@ func TestService_Get(t *testing.T) {
 	t.Run("图表不存在", func(t *testing.T) {
 		queryExec := &mockQueryExecutor{}
 		repo := &mockRepository{}
-		svc := NewService(repo, queryExec)
+		svc := NewService(repo, queryExec, nil)
 
 		repo.On("Get", mock.Anything, "not-found", "tenant-1").Return(nil, assert.AnError).Once()
 
@@ -155,7 +177,7 @@ func TestService_Update(t *testing.T) {
 	t.Run("成功更新图表", func(t *testing.T) {
 		queryExec := &mockQueryExecutor{}
 		repo := &mockRepository{}
-		svc := NewService(repo, queryExec)
+		svc := NewService(repo, queryExec, nil)
 
 		existingChart := &models.Chart{ID: "c1", Name: "Old Name", TenantID: "tenant-1"}
 		repo.On("Get", mock.Anything, "c1", "tenant-1").Return(existingChart, nil).Once()
@@ -180,7 +202,7 @@ func TestService_Update(t *testing.T) {
 	t.Run("更新失败-图表不存在", func(t *testing.T) {
 		queryExec := &mockQueryExecutor{}
 		repo := &mockRepository{}
-		svc := NewService(repo, queryExec)
+		svc := NewService(repo, queryExec, nil)
 
 		repo.On("Get", mock.Anything, "not-found", "tenant-1").Return(nil, assert.AnError).Once()
 
@@ -197,7 +219,7 @@ func TestService_Update(t *testing.T) {
 	t.Run("更新失败-Repository错误", func(t *testing.T) {
 		queryExec := &mockQueryExecutor{}
 		repo := &mockRepository{}
-		svc := NewService(repo, queryExec)
+		svc := NewService(repo, queryExec, nil)
 
 		existingChart := &models.Chart{ID: "c1", Name: "Old Name", TenantID: "tenant-1"}
 		repo.On("Get", mock.Anything, "c1", "tenant-1").Return(existingChart, nil).Once()
@@ -219,7 +241,7 @@ func TestService_Delete(t *testing.T) {
 	t.Run("成功删除图表", func(t *testing.T) {
 		queryExec := &mockQueryExecutor{}
 		repo := &mockRepository{}
-		svc := NewService(repo, queryExec)
+		svc := NewService(repo, queryExec, nil)
 
 		repo.On("Delete", mock.Anything, "c1", "tenant-1").Return(nil).Once()
 
@@ -231,7 +253,7 @@ func TestService_Delete(t *testing.T) {
 	t.Run("删除失败", func(t *testing.T) {
 		queryExec := &mockQueryExecutor{}
 		repo := &mockRepository{}
-		svc := NewService(repo, queryExec)
+		svc := NewService(repo, queryExec, nil)
 
 		repo.On("Delete", mock.Anything, "c1", "tenant-1").Return(errors.New("delete failed")).Once()
 
@@ -246,7 +268,7 @@ func TestService_List(t *testing.T) {
 	t.Run("成功获取图表列表", func(t *testing.T) {
 		queryExec := &mockQueryExecutor{}
 		repo := &mockRepository{}
-		svc := NewService(repo, queryExec)
+		svc := NewService(repo, queryExec, nil)
 
 		expected := []*models.Chart{{ID: "c1", Name: "Chart 1"}, {ID: "c2", Name: "Chart 2"}}
 		repo.On("List", mock.Anything, "tenant-1").Return(expected, nil).Once()
@@ -262,7 +284,7 @@ func TestService_List(t *testing.T) {
 	t.Run("空列表", func(t *testing.T) {
 		queryExec := &mockQueryExecutor{}
 		repo := &mockRepository{}
-		svc := NewService(repo, queryExec)
+		svc := NewService(repo, queryExec, nil)
 
 		repo.On("List", mock.Anything, "tenant-1").Return([]*models.Chart{}, nil).Once()
 
@@ -275,7 +297,7 @@ func TestService_List(t *testing.T) {
 	t.Run("列表获取失败", func(t *testing.T) {
 		queryExec := &mockQueryExecutor{}
 		repo := &mockRepository{}
-		svc := NewService(repo, queryExec)
+		svc := NewService(repo, queryExec, nil)
 
 		repo.On("List", mock.Anything, "tenant-1").Return(nil, errors.New("list error")).Once()
 
@@ -290,7 +312,7 @@ func TestService_Render(t *testing.T) {
 	t.Run("成功渲染图表", func(t *testing.T) {
 		queryExec := &mockQueryExecutor{}
 		repo := &mockRepository{}
-		svc := NewService(repo, queryExec)
+		svc := NewService(repo, queryExec, nil)
 
 		configJSON, _ := json.Marshal(ChartConfig{
 			Series: []SeriesConfig{
@@ -312,18 +334,14 @@ func TestService_Render(t *testing.T) {
 
 		repo.On("Get", mock.Anything, "c1", "tenant-1").Return(existingChart, nil).Once()
 
-		queryExec.On("Query", mock.Anything, mock.MatchedBy(func(req *dataset.QueryRequest) bool {
+		queryExec.On("Export", mock.Anything, "tenant-1", mock.MatchedBy(func(req *dataset.QueryRequest) bool {
 			return req.DatasetID == "ds-1"
-		})).Return(&dataset.QueryResponse{
-			Data: []map[string]interface{}{
-				{"Series 1": "value1"},
-				{"Series 1": "value2"},
-			},
-		}, nil).Once()
+		}), mock.Anything).Run(writeRows([]string{"Series 1"}, []interface{}{"1.5"}, []interface{}{[]byte("2")})).Return(int64(2), nil).Once()
 
-		result, err := svc.Render(context.Background(), "c1", "tenant-1")
+		result, err := svc.Render(context.Background(), "c1", "tenant-1", nil)
 		assert.NoError(t, err)
-		assert.NotEmpty(t, result)
+		series := result["series"].([]interface{})
+		assert.Equal(t, []interface{}{1.5, 2.0}, series[0].(map[string]interface{})["data"])
 
 		repo.AssertExpectations(t)
 		queryExec.AssertExpectations(t)
@@ -332,11 +350,11 @@ func TestService_Render(t *testing.T) {
 	t.Run("渲染失败-图表不存在", func(t *testing.T) {
 		queryExec := &mockQueryExecutor{}
 		repo := &mockRepository{}
-		svc := NewService(repo, queryExec)
+		svc := NewService(repo, queryExec, nil)
 
 		repo.On("Get", mock.Anything, "not-found", "tenant-1").Return(nil, assert.AnError).Once()
 
-		_, err := svc.Render(context.Background(), "not-found", "tenant-1")
+		_, err := svc.Render(context.Background(), "not-found", "tenant-1", nil)
 		assert.ErrorIs(t, err, ErrNotFound)
 		repo.AssertExpectations(t)
 	})
@@ -344,7 +362,7 @@ func TestService_Render(t *testing.T) {
 	t.Run("渲染失败-查询错误", func(t *testing.T) {
 		queryExec := &mockQueryExecutor{}
 		repo := &mockRepository{}
-		svc := NewService(repo, queryExec)
+		svc := NewService(repo, queryExec, nil)
 
 		configJSON, _ := json.Marshal(ChartConfig{
 			Series: []SeriesConfig{
@@ -364,9 +382,9 @@ func TestService_Render(t *testing.T) {
 		}
 
 		repo.On("Get", mock.Anything, "c1", "tenant-1").Return(existingChart, nil).Once()
-		queryExec.On("Query", mock.Anything, mock.Anything).Return(nil, errors.New("query failed")).Once()
+		queryExec.On("Export", mock.Anything, "tenant-1", mock.Anything, mock.Anything).Return(int64(0), errors.New("query failed")).Once()
 
-		_, err := svc.Render(context.Background(), "c1", "tenant-1")
+		_, err := svc.Render(context.Background(), "c1", "tenant-1", nil)
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "query failed")
 
@@ -374,3 +392,174 @@ func TestService_Render(t *testing.T) {
 		queryExec.AssertExpectations(t)
 	})
 }
+
+func TestService_Render_DatasetBinding(t *testing.T) {
+	t.Run("维度映射为类目-度量映射为系列", func(t *testing.T) {
+		queryExec := &mockQueryExecutor{}
+		repo := &mockRepository{}
+		svc := NewService(repo, queryExec, nil)
+
+		configJSON, _ := json.Marshal(ChartConfig{
+			Title:      "Sales",
+			DatasetID:  "ds-1",
+			Dimensions: []string{"month"},
+			Measures: []MeasureConfig{
+				{Field: "amount", Label: "Amount"},
+				{Field: "orders", Aggregation: "count", Type: "line"},
+			},
+			Options: map[string]interface{}{"legend": map[string]interface{}{"top": "bottom"}},
+		})
+		repo.On("Get", mock.Anything, "c1", "tenant-1").Return(&models.Chart{ID: "c1", TenantID: "tenant-1", Type: "bar", Config: string(configJSON)}, nil).Once()
+		queryExec.On("Export", mock.Anything, "tenant-1", mock.MatchedBy(func(req *dataset.QueryRequest) bool {
+			return req.DatasetID == "ds-1" &&
+				assert.ObjectsAreEqual([]string{"month"}, req.GroupBy) &&
+				req.Aggregations["measure_0"] == dataset.Aggregation{Function: "SUM", Field: "amount"} &&
+				req.Aggregations["measure_1"] == dataset.Aggregation{Function: "COUNT", Field: "orders"} &&
+				req.SortBy == "month"
+		}), mock.Anything).Run(writeRows([]string{"month", "measure_0", "measure_1"},
+			[]interface{}{"Jan", []byte("100.5"), int64(3)},
+			[]interface{}{"Feb", nil, int64(1)},
+		)).Return(int64(2), nil).Once()
+
+		option, err := svc.Render(context.Background(), "c1", "tenant-1", nil)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{"text": "Sales"}, option["title"])
+		assert.Equal(t, map[string]interface{}{"type": "category", "data": []string{"Jan", "Feb"}}, option["xAxis"])
+		assert.Equal(t, map[string]interface{}{"data": []string{"Amount", "orders"}, "top": "bottom"}, option["legend"])
+		assert.Equal(t, []interface{}{
+			map[string]interface{}{"name": "Amount", "type": "bar", "data": []interface{}{100.5, nil}},
+			map[string]interface{}{"name": "orders", "type": "line", "data": []interface{}{3.0, 1.0}},
+		}, option["series"])
+		queryExec.AssertExpectations(t)
+	})
+
+	t.Run("按分组维度拆分多系列", func(t *testing.T) {
+		queryExec := &mockQueryExecutor{}
+		repo := &mockRepository{}
+		svc := NewService(repo, queryExec, nil)
+
+		configJSON, _ := json.Marshal(ChartConfig{
+			DatasetID:  "ds-1",
+			Dimensions: []string{"month"},
+			Measures:   []MeasureConfig{{Field: "amount"}},
+			SeriesBy:   "region",
+			Stack:      true,
+		})
+		repo.On("Get", mock.Anything, "c1", "tenant-1").Return(&models.Chart{ID: "c1", TenantID: "tenant-1", Type: "area", Config: string(configJSON)}, nil).Once()
+		queryExec.On("Export", mock.Anything, "tenant-1", mock.MatchedBy(func(req *dataset.QueryRequest) bool {
+			return assert.ObjectsAreEqual([]string{"month", "region"}, req.GroupBy)
+		}), mock.Anything).Run(writeRows([]string{"month", "region", "measure_0"},
+			[]interface{}{"Jan", "North", 10.0},
+			[]interface{}{"Jan", "South", 20.0},
+			[]interface{}{"Feb", "South", 5.0},
+		)).Return(int64(3), nil).Once()
+
+		option, err := svc.Render(context.Background(), "c1", "tenant-1", nil)
+		require.NoError(t, err)
+		assert.Equal(t, []interface{}{
+			map[string]interface{}{"name": "North", "type": "line", "areaStyle": map[string]interface{}{}, "stack": "total", "data": []interface{}{10.0, nil}},
+			map[string]interface{}{"name": "South", "type": "line", "areaStyle": map[string]interface{}{}, "stack": "total", "data": []interface{}{20.0, 5.0}},
+		}, option["series"])
+	})
+
+	t.Run("饼图", func(t *testing.T) {
+		queryExec := &mockQueryExecutor{}
+		repo := &mockRepository{}
+		svc := NewService(repo, queryExec, nil)
+
+		configJSON, _ := json.Marshal(ChartConfig{
+			DatasetID:  "ds-1",
+			Dimensions: []string{"region"},
+			Measures:   []MeasureConfig{{Field: "amount", Label: "Amount"}},
+			SortBy:     "amount",
+			SortOrder:  "desc",
+		})
+		repo.On("Get", mock.Anything, "c1", "tenant-1").Return(&models.Chart{ID: "c1", TenantID: "tenant-1", Type: "pie", Config: string(configJSON)}, nil).Once()
+		queryExec.On("Export", mock.Anything, "tenant-1", mock.MatchedBy(func(req *dataset.QueryRequest) bool {
+			return req.SortBy == "measure_0" && req.SortOrder == "desc"
+		}), mock.Anything).Run(writeRows([]string{"region", "measure_0"},
+			[]interface{}{"South", 20.0},
+			[]interface{}{"North", 10.0},
+		)).Return(int64(2), nil).Once()
+
+		option, err := svc.Render(context.Background(), "c1", "tenant-1", nil)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{"trigger": "item"}, option["tooltip"])
+		assert.Equal(t, []interface{}{
+			map[string]interface{}{"name": "Amount", "type": "pie", "radius": "60%", "data": []interface{}{
+				map[string]interface{}{"name": "South", "value": 20.0},
+				map[string]interface{}{"name": "North", "value": 10.0},
+			}},
+		}, option["series"])
+	})
+}
+
+func TestService_Create_InvalidBinding(t *testing.T) {
+	svc := NewService(&mockRepository{}, &mockQueryExecutor{}, nil)
+	invalid := map[string]CreateRequest{
+		"type":        {Type: "radar"},
+		"dimension":   {Type: "bar", Config: ChartConfig{DatasetID: "ds-1", Measures: []MeasureConfig{{Field: "amount"}}}},
+		"measure":     {Type: "bar", Config: ChartConfig{DatasetID: "ds-1", Dimensions: []string{"month"}}},
+		"aggregation": {Type: "bar", Config: ChartConfig{DatasetID: "ds-1", Dimensions: []string{"month"}, Measures: []MeasureConfig{{Field: "amount", Aggregation: "median"}}}},
+		"pieSeries":   {Type: "pie", Config: ChartConfig{DatasetID: "ds-1", Dimensions: []string{"month"}, Measures: []MeasureConfig{{Field: "amount"}}, SeriesBy: "region"}},
+		"seriesBy":    {Type: "bar", Config: ChartConfig{DatasetID: "ds-1", Dimensions: []string{"month"}, Measures: []MeasureConfig{{Field: "amount"}}, SeriesBy: "month"}},
+	}
+	for name, req := range invalid {
+		req := req
+		req.TenantID = "tenant-1"
+		req.Name = name
+		_, err := svc.Create(context.Background(), &req)
+		assert.ErrorIs(t, err, ErrInvalidChart, name)
+	}
+}
+
+func TestService_Render_Drill(t *testing.T) {
+	queryExec := &mockQueryExecutor{}
+	repo := &mockRepository{}
+	svc := NewService(repo, queryExec, nil)
+
+	configJSON, _ := json.Marshal(ChartConfig{
+		DatasetID:  "ds-1",
+		Dimensions: []string{"region"},
+		Measures:   []MeasureConfig{{Field: "amount"}},
+		DrillPath:  "geo",
+	})
+	repo.On("Get", mock.Anything, "c1", "tenant-1").Return(&models.Chart{ID: "c1", TenantID: "tenant-1", Type: "bar", Config: string(configJSON)}, nil).Once()
+	queryExec.On("Export", mock.Anything, "tenant-1", mock.MatchedBy(func(req *dataset.QueryRequest) bool {
+		return req.Drill != nil && req.Drill.Path == "geo"
+	}), mock.Anything).Run(func(args mock.Arguments) {
+		// The executor rewrites the request to the drilled level.
+		req := args.Get(2).(*dataset.QueryRequest)
+		req.Fields, req.GroupBy, req.Drill = []string{"city"}, []string{"city"}, nil
+		writeRows([]string{"city", "measure_0"}, []interface{}{"Leeds", 4.0}, []interface{}{"York", 6.0})(args)
+	}).Return(int64(2), nil).Once()
+
+	option, err := svc.Render(context.Background(), "c1", "tenant-1", &dataset.DrillState{Values: []interface{}{"North"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Leeds", "York"}, option["xAxis"].(map[string]interface{})["data"])
+	queryExec.AssertExpectations(t)
+}
+
+func TestService_DrillThrough(t *testing.T) {
+	repo := &mockRepository{}
+	svc := NewService(repo, &mockQueryExecutor{}, nil)
+
+	configJSON, _ := json.Marshal(ChartConfig{
+		DatasetID:    "ds-1",
+		Dimensions:   []string{"region"},
+		Measures:     []MeasureConfig{{Field: "amount"}},
+		DrillThrough: &dataset.DrillThrough{TargetType: "dashboard", TargetID: "dashboard-1", Params: map[string]string{"region": "region"}},
+	})
+	repo.On("Get", mock.Anything, "c1", "tenant-1").Return(&models.Chart{ID: "c1", Type: "bar", Config: string(configJSON)}, nil)
+	repo.On("Get", mock.Anything, "c2", "tenant-1").Return(&models.Chart{ID: "c2", Type: "bar", Config: `{"series":[]}`}, nil)
+
+	target, err := svc.DrillThrough(context.Background(), "c1", "tenant-1", map[string]interface{}{"region": "North", "measure_0": 10.0})
+	require.NoError(t, err)
+	assert.Equal(t, "dashboard-1", target.TargetID)
+	assert.Equal(t, map[string]interface{}{"region": "North"}, target.Params)
+
+	_, err = svc.DrillThrough(context.Background(), "c1", "tenant-1", map[string]interface{}{})
+	assert.ErrorIs(t, err, ErrInvalidChart)
+	_, err = svc.DrillThrough(context.Background(), "c2", "tenant-1", map[string]interface{}{"region": "North"})
+	assert.ErrorIs(t, err, ErrInvalidChart)
+}
@@ -0,0 +1,181 @@
+package chart
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/gujiaweiguo/goreport/internal/models"
+	"github.com/gujiaweiguo/goreport/internal/version"
+	"gorm.io/gorm"
+)
+
+// Snapshot is what a chart version records.
+type Snapshot struct {
+	Name   string          `json:"name"`
+	Type   string          `json:"type"`
+	Config json.RawMessage `json:"config"`
+}
+
+func snapshotOf(chart *models.Chart) *Snapshot {
+	config := json.RawMessage(chart.Config)
+	if len(config) == 0 {
+		config = json.RawMessage("null")
+	}
+	return &Snapshot{Name: chart.Name, Type: chart.Type, Config: config}
+}
+
+func (s *Snapshot) apply(chart *models.Chart) {
+	chart.Name = s.Name
+	chart.Type = s.Type
+	chart.Config = string(s.Config)
+}
+
+// load reads a chart as the caller may see it: the draft, or for a viewer
+// the published version.
+func (s *service) load(ctx context.Context, id, tenantID string) (*models.Chart, error) {
+	chart, err := s.repo.Get(ctx, id, tenantID)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	if !version.Published(ctx) {
+		return chart, nil
+	}
+	if chart.Status != version.StatusPublished {
+		return nil, ErrNotFound
+	}
+	if chart.PublishedVersion == 0 {
+		return chart, nil
+	}
+	var snapshot Snapshot
+	if _, err := s.history.Load(ctx, tenantID, id, chart.PublishedVersion, &snapshot); err != nil {
+		return nil, err
+	}
+	snapshot.apply(chart)
+	return chart, nil
+}
+
+// inTx runs fn with the repository and history bound to one transaction,
+// so a chart and the versions it records are saved together.
+func (s *service) inTx(ctx context.Context, fn func(repo Repository, history *version.History) error) error {
+	return s.repo.Transaction(ctx, func(repo Repository, tx *gorm.DB) error {
+		return fn(repo, s.history.WithTx(tx))
+	})
+}
+
+// pinPublished keeps what viewers of a chart published before versioning
+// see when its draft first changes.
+func (s *service) pinPublished(ctx context.Context, history *version.History, chart *models.Chart, userID string) error {
+	if chart.Status != version.StatusPublished || chart.PublishedVersion != 0 {
+		return nil
+	}
+	recorded, err := history.Record(ctx, chart.TenantID, chart.ID, userID, "published", snapshotOf(chart))
+	if err != nil || recorded == nil {
+		return err
+	}
+	chart.PublishedVersion = recorded.Number
+	return nil
+}
+
+func (s *service) Versions(ctx context.Context, id, tenantID string) ([]*version.Version, error) {
+	if _, err := s.repo.Get(ctx, id, tenantID); err != nil {
+		return nil, ErrNotFound
+	}
+	return s.history.List(ctx, tenantID, id)
+}
+
+func (s *service) Version(ctx context.Context, id, tenantID string, number int) (*version.Version, error) {
+	if _, err := s.repo.Get(ctx, id, tenantID); err != nil {
+		return nil, ErrNotFound
+	}
+	return s.history.Get(ctx, tenantID, id, number)
+}
+
+// Diff compares two versions of a chart; version 0 is the current draft.
+func (s *service) Diff(ctx context.Context, id, tenantID string, from, to int) (*version.Diff, error) {
+	chart, err := s.repo.Get(ctx, id, tenantID)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	snapshotAt := func(number int) ([]byte, error) {
+		if number == 0 {
+			return json.Marshal(snapshotOf(chart))
+		}
+		recorded, err := s.history.Get(ctx, tenantID, id, number)
+		if err != nil {
+			return nil, err
+		}
+		return recorded.Snapshot, nil
+	}
+	before, err := snapshotAt(from)
+	if err != nil {
+		return nil, err
+	}
+	after, err := snapshotAt(to)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := version.DiffJSON(before, after)
+	if err != nil {
+		return nil, err
+	}
+	return &version.Diff{From: from, To: to, Changes: changes}, nil
+}
+
+// Publish pins a version for viewers; version 0 publishes the latest save.
+func (s *service) Publish(ctx context.Context, id, tenantID string, number int, userID string) (*models.Chart, error) {
+	chart, err := s.repo.Get(ctx, id, tenantID)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	if err := s.inTx(ctx, func(repo Repository, history *version.History) error {
+		published, err := history.Get(ctx, tenantID, id, number)
+		if errors.Is(err, version.ErrNotFound) && number == 0 {
+			// Saved before versioning: publish the draft as its first version.
+			published, err = history.Record(ctx, tenantID, id, userID, "published", snapshotOf(chart))
+			if err == nil && published == nil {
+				err = version.ErrNotFound
+			}
+		}
+		if err != nil {
+			return err
+		}
+		chart.PublishedVersion = published.Number
+		chart.Status = version.StatusPublished
+		return repo.Update(ctx, chart)
+	}); err != nil {
+		return nil, err
+	}
+	return chart, nil
+}
+
+// Rollback restores a version into the draft and records it as a new
+// version; viewers keep the published one until it is published again.
+func (s *service) Rollback(ctx context.Context, id, tenantID string, number int, userID string) (*models.Chart, error) {
+	chart, err := s.repo.Get(ctx, id, tenantID)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	if number <= 0 {
+		return nil, version.ErrNotFound
+	}
+	var snapshot Snapshot
+	if _, err := s.history.Load(ctx, tenantID, id, number, &snapshot); err != nil {
+		return nil, err
+	}
+	if err := s.inTx(ctx, func(repo Repository, history *version.History) error {
+		if err := s.pinPublished(ctx, history, chart, userID); err != nil {
+			return err
+		}
+		snapshot.apply(chart)
+		if err := repo.Update(ctx, chart); err != nil {
+			return err
+		}
+		_, err := history.Record(ctx, tenantID, id, userID, fmt.Sprintf("rollback to version %d", number), snapshotOf(chart))
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return chart, nil
+}
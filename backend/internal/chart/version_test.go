@@ -0,0 +1,58 @@
+package chart
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/gujiaweiguo/goreport/internal/models"
+	"github.com/gujiaweiguo/goreport/internal/testutil"
+	"github.com/gujiaweiguo/goreport/internal/version"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_VersionsAndPublish(t *testing.T) {
+	repo := &mockRepository{}
+	svc := NewService(repo, &mockQueryExecutor{}, testutil.NewVersionRepository())
+	ctx := context.Background()
+
+	var created *models.Chart
+	repo.On("Create", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		created = args.Get(1).(*models.Chart)
+	}).Return(nil).Once()
+	_, err := svc.Create(ctx, &CreateRequest{TenantID: "tenant-1", CreatedBy: "user-1", Name: "Sales", Type: TypeBar, Config: ChartConfig{Title: "Sales"}})
+	require.NoError(t, err)
+	assert.Equal(t, version.StatusDraft, created.Status)
+
+	stored := *created
+	repo.On("Get", mock.Anything, created.ID, "tenant-1").Return(&stored, nil)
+	repo.On("Update", mock.Anything, mock.Anything).Return(nil)
+
+	_, err = svc.Get(version.WithPublished(ctx), created.ID, "tenant-1")
+	assert.ErrorIs(t, err, ErrNotFound, "viewers cannot see a draft")
+
+	published, err := svc.Publish(ctx, created.ID, "tenant-1", 0, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, published.PublishedVersion)
+	assert.Equal(t, version.StatusPublished, published.Status)
+
+	_, err = svc.Update(ctx, &UpdateRequest{TenantID: "tenant-1", UpdatedBy: "user-2", ID: created.ID, Config: ChartConfig{Title: "Revenue", Series: []SeriesConfig{}}})
+	require.NoError(t, err)
+
+	diff, err := svc.Diff(ctx, created.ID, "tenant-1", 1, 2)
+	require.NoError(t, err)
+	assert.Contains(t, diff.Changes, version.Change{Path: "config.title", Kind: version.ChangeModified, From: "Sales", To: "Revenue"})
+
+	restored, err := svc.Rollback(ctx, created.ID, "tenant-1", 1, "user-1")
+	require.NoError(t, err)
+	var config ChartConfig
+	require.NoError(t, json.Unmarshal([]byte(restored.Config), &config))
+	assert.Equal(t, "Sales", config.Title)
+
+	history, err := svc.Versions(ctx, created.ID, "tenant-1")
+	require.NoError(t, err)
+	require.Len(t, history, 3)
+	assert.Equal(t, "rollback to version 1", history[0].Comment)
+}
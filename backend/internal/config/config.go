@@ -5,14 +5,21 @@ import (
 	"encoding/hex"
 	"fmt"
 	"os"
+	"strings"
 )
 
 // Config 应用配置
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	JWT      JWTConfig
-	Cache    CacheConfig
+	Server     ServerConfig
+	Database   DatabaseConfig
+	JWT        JWTConfig
+	Cache      CacheConfig
+	Storage    StorageConfig
+	Datasource DatasourceConfig
+	Export     ExportConfig
+	Scheduler  SchedulerConfig
+	SMTP       SMTPConfig
+	Embed      EmbedConfig
 }
 
 // ServerConfig 服务器配置
@@ -37,6 +44,50 @@ type CacheConfig struct {
 	DefaultTTL int // 默认 TTL（秒）
 }
 
+// StorageConfig 文件存储配置
+type StorageConfig struct {
+	UploadDir       string // 上传文件根目录，按租户分目录存放
+	MaxUploadSizeMB int    // 单个上传文件大小上限（MB）
+}
+
+// DatasourceConfig 外部数据源连接配置
+type DatasourceConfig struct {
+	PoolIdleTimeout    int      // 连接池空闲多久后关闭（秒）
+	SecretKey          string   // 数据源密码等敏感字段的加密主密钥（base64 编码的 32 字节）
+	PreviousSecretKeys []string // 轮换前的旧主密钥，仅用于解密
+}
+
+// ExportConfig 数据导出配置
+type ExportConfig struct {
+	MaxRows       int            // 单次导出的行数上限
+	TenantMaxRows map[string]int // 按租户覆盖的行数上限
+	PDFFont       string         // 报表 PDF、图片导出使用的 TrueType 字体文件，中文报表需配置 CJK 字体
+}
+
+// SchedulerConfig 定时报表调度配置
+type SchedulerConfig struct {
+	Enabled       bool // 是否在本实例上轮询到期的调度，多实例部署时可只开启部分实例
+	PollInterval  int  // 轮询到期调度的间隔（秒）
+	MaxConcurrent int  // 同时执行的调度任务数上限
+	RetryBackoff  int  // 投递失败后首次重试的等待时间（秒），之后每次翻倍
+}
+
+// SMTPConfig 邮件投递使用的 SMTP 服务器配置
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string // 为空时不做认证
+	Password string
+	From     string // 发件人地址
+}
+
+// EmbedConfig 仪表盘、报表嵌入配置
+type EmbedConfig struct {
+	AllowedOrigins []string // 允许跨域调用嵌入接口的来源，"*" 表示任意来源
+	TokenTTL       int      // 嵌入令牌未指定有效期时的默认有效期（秒）
+	MaxTokenTTL    int      // 嵌入令牌有效期上限（秒）
+}
+
 // JWTConfig JWT 配置
 type JWTConfig struct {
 	Secret   string
@@ -73,6 +124,38 @@ func Load() (*Config, error) {
 			DB:         getIntEnv("CACHE_DB", 0),
 			DefaultTTL: getIntEnv("CACHE_DEFAULT_TTL", 3600),
 		},
+		Storage: StorageConfig{
+			UploadDir:       getEnv("UPLOAD_DIR", "./data/uploads"),
+			MaxUploadSizeMB: getIntEnv("UPLOAD_MAX_SIZE_MB", 50),
+		},
+		Datasource: DatasourceConfig{
+			PoolIdleTimeout:    getIntEnv("DATASOURCE_POOL_IDLE_TIMEOUT", 600),
+			SecretKey:          getEnv("DATASOURCE_SECRET_KEY", ""),
+			PreviousSecretKeys: getListEnv("DATASOURCE_PREVIOUS_SECRET_KEYS"),
+		},
+		Export: ExportConfig{
+			MaxRows:       getIntEnv("EXPORT_MAX_ROWS", 1000000),
+			TenantMaxRows: getIntMapEnv("EXPORT_TENANT_MAX_ROWS"),
+			PDFFont:       getEnv("EXPORT_PDF_FONT", ""),
+		},
+		Scheduler: SchedulerConfig{
+			Enabled:       getBoolEnv("SCHEDULER_ENABLED", true),
+			PollInterval:  getIntEnv("SCHEDULER_POLL_INTERVAL", 30),
+			MaxConcurrent: getIntEnv("SCHEDULER_MAX_CONCURRENT", 4),
+			RetryBackoff:  getIntEnv("SCHEDULER_RETRY_BACKOFF", 30),
+		},
+		SMTP: SMTPConfig{
+			Host:     getEnv("SMTP_HOST", ""),
+			Port:     getIntEnv("SMTP_PORT", 25),
+			Username: getEnv("SMTP_USERNAME", ""),
+			Password: getEnv("SMTP_PASSWORD", ""),
+			From:     getEnv("SMTP_FROM", ""),
+		},
+		Embed: EmbedConfig{
+			AllowedOrigins: getListEnv("EMBED_ALLOWED_ORIGINS"),
+			TokenTTL:       getIntEnv("EMBED_TOKEN_TTL", 7*24*3600),
+			MaxTokenTTL:    getIntEnv("EMBED_MAX_TOKEN_TTL", 365*24*3600),
+		},
 	}, nil
 }
 
@@ -93,6 +176,33 @@ func getBoolEnv(key string, defaultValue bool) bool {
 	return defaultValue
 }
 
+// getListEnv 读取逗号分隔的列表
+func getListEnv(key string) []string {
+	var result []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// getIntMapEnv 读取形如 "a=1,b=2" 的映射，忽略无法解析的条目
+func getIntMapEnv(key string) map[string]int {
+	result := make(map[string]int)
+	for _, item := range getListEnv(key) {
+		name, value, ok := strings.Cut(item, "=")
+		if !ok {
+			continue
+		}
+		var parsed int
+		if _, err := fmt.Sscanf(strings.TrimSpace(value), "%d", &parsed); err == nil {
+			result[strings.TrimSpace(name)] = parsed
+		}
+	}
+	return result
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
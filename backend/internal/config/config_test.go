@@ -65,6 +65,128 @@ func TestLoad_DefaultValues(t *testing.T) {
 	if cfg.Cache.DefaultTTL != 3600 {
 		t.Errorf("Cache.DefaultTTL = %d, want 3600", cfg.Cache.DefaultTTL)
 	}
+
+	// Test storage defaults
+	if cfg.Storage.UploadDir != "./data/uploads" {
+		t.Errorf("Storage.UploadDir = %q, want ./data/uploads", cfg.Storage.UploadDir)
+	}
+	if cfg.Storage.MaxUploadSizeMB != 50 {
+		t.Errorf("Storage.MaxUploadSizeMB = %d, want 50", cfg.Storage.MaxUploadSizeMB)
+	}
+	if cfg.Datasource.PoolIdleTimeout != 600 {
+		t.Errorf("Datasource.PoolIdleTimeout = %d, want 600", cfg.Datasource.PoolIdleTimeout)
+	}
+	if cfg.Datasource.SecretKey != "" || len(cfg.Datasource.PreviousSecretKeys) != 0 {
+		t.Errorf("Datasource secret keys = %q, %q, want empty", cfg.Datasource.SecretKey, cfg.Datasource.PreviousSecretKeys)
+	}
+}
+
+func TestLoad_ExportRowLimits(t *testing.T) {
+	clearConfigEnvVars(t)
+	defer clearConfigEnvVars(t)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.Export.MaxRows != 1000000 || len(cfg.Export.TenantMaxRows) != 0 {
+		t.Errorf("Export = %+v, want MaxRows 1000000 and no tenant limits", cfg.Export)
+	}
+
+	os.Setenv("EXPORT_MAX_ROWS", "5000")
+	os.Setenv("EXPORT_TENANT_MAX_ROWS", "tenant-a=100, tenant-b = 200,broken,tenant-c=x")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.Export.MaxRows != 5000 {
+		t.Errorf("Export.MaxRows = %d, want 5000", cfg.Export.MaxRows)
+	}
+	want := map[string]int{"tenant-a": 100, "tenant-b": 200}
+	if len(cfg.Export.TenantMaxRows) != len(want) {
+		t.Fatalf("Export.TenantMaxRows = %v, want %v", cfg.Export.TenantMaxRows, want)
+	}
+	for tenant, rows := range want {
+		if cfg.Export.TenantMaxRows[tenant] != rows {
+			t.Errorf("Export.TenantMaxRows[%s] = %d, want %d", tenant, cfg.Export.TenantMaxRows[tenant], rows)
+		}
+	}
+}
+
+func TestLoad_SchedulerAndSMTP(t *testing.T) {
+	clearConfigEnvVars(t)
+	defer clearConfigEnvVars(t)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	want := SchedulerConfig{Enabled: true, PollInterval: 30, MaxConcurrent: 4, RetryBackoff: 30}
+	if cfg.Scheduler != want {
+		t.Errorf("Scheduler = %+v, want %+v", cfg.Scheduler, want)
+	}
+	if cfg.SMTP.Host != "" || cfg.SMTP.Port != 25 {
+		t.Errorf("SMTP = %+v, want no host on port 25", cfg.SMTP)
+	}
+
+	os.Setenv("SCHEDULER_ENABLED", "false")
+	os.Setenv("SMTP_HOST", "smtp.example.com")
+	os.Setenv("SMTP_PORT", "587")
+	os.Setenv("SMTP_FROM", "reports@example.com")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.Scheduler.Enabled {
+		t.Error("Scheduler.Enabled = true, want false")
+	}
+	if cfg.SMTP.Host != "smtp.example.com" || cfg.SMTP.Port != 587 || cfg.SMTP.From != "reports@example.com" {
+		t.Errorf("SMTP = %+v, want smtp.example.com:587 from reports@example.com", cfg.SMTP)
+	}
+}
+
+func TestLoad_Embed(t *testing.T) {
+	clearConfigEnvVars(t)
+	defer clearConfigEnvVars(t)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(cfg.Embed.AllowedOrigins) != 0 || cfg.Embed.TokenTTL != 7*24*3600 || cfg.Embed.MaxTokenTTL != 365*24*3600 {
+		t.Errorf("Embed = %+v, want no origins, a week TTL and a year max", cfg.Embed)
+	}
+
+	os.Setenv("EMBED_ALLOWED_ORIGINS", "https://a.example.com, https://b.example.com")
+	os.Setenv("EMBED_TOKEN_TTL", "3600")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got := cfg.Embed.AllowedOrigins; len(got) != 2 || got[1] != "https://b.example.com" {
+		t.Errorf("Embed.AllowedOrigins = %q, want both origins", got)
+	}
+	if cfg.Embed.TokenTTL != 3600 {
+		t.Errorf("Embed.TokenTTL = %d, want 3600", cfg.Embed.TokenTTL)
+	}
+}
+
+func TestLoad_DatasourceSecretKeys(t *testing.T) {
+	clearConfigEnvVars(t)
+	os.Setenv("DATASOURCE_SECRET_KEY", "current")
+	os.Setenv("DATASOURCE_PREVIOUS_SECRET_KEYS", "old-1, ,old-2")
+	defer clearConfigEnvVars(t)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.Datasource.SecretKey != "current" {
+		t.Errorf("Datasource.SecretKey = %q, want current", cfg.Datasource.SecretKey)
+	}
+	if got := cfg.Datasource.PreviousSecretKeys; len(got) != 2 || got[0] != "old-1" || got[1] != "old-2" {
+		t.Errorf("Datasource.PreviousSecretKeys = %q, want [old-1 old-2]", got)
+	}
 }
 
 func TestLoad_EnvironmentVariables(t *testing.T) {
@@ -303,6 +425,26 @@ func clearConfigEnvVars(t *testing.T) {
 		"CACHE_PASSWORD",
 		"CACHE_DB",
 		"CACHE_DEFAULT_TTL",
+		"UPLOAD_DIR",
+		"UPLOAD_MAX_SIZE_MB",
+		"DATASOURCE_POOL_IDLE_TIMEOUT",
+		"DATASOURCE_SECRET_KEY",
+		"DATASOURCE_PREVIOUS_SECRET_KEYS",
+		"EXPORT_MAX_ROWS",
+		"EXPORT_TENANT_MAX_ROWS",
+		"EXPORT_PDF_FONT",
+		"SCHEDULER_ENABLED",
+		"SCHEDULER_POLL_INTERVAL",
+		"SCHEDULER_MAX_CONCURRENT",
+		"SCHEDULER_RETRY_BACKOFF",
+		"SMTP_HOST",
+		"SMTP_PORT",
+		"SMTP_USERNAME",
+		"EMBED_ALLOWED_ORIGINS",
+		"EMBED_TOKEN_TTL",
+		"EMBED_MAX_TOKEN_TTL",
+		"SMTP_PASSWORD",
+		"SMTP_FROM",
 	}
 	for _, v := range envVars {
 		os.Unsetenv(v)
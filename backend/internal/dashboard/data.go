@@ -0,0 +1,226 @@
+package dashboard
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/gujiaweiguo/goreport/internal/dataset"
+	"github.com/gujiaweiguo/goreport/internal/models"
+)
+
+// DataConcurrency bounds how many component queries of one dashboard run at
+// once, so a large screen cannot exhaust the datasource pools.
+const DataConcurrency = 8
+
+// DefaultBindingLimit caps the records of a binding that sets no limit.
+const DefaultBindingLimit = 1000
+
+var ErrInvalidBinding = errors.New("invalid component binding")
+
+// ComponentBinding is the dataset binding kept in a component's Data.
+// Dimension, Measure and Aggregation are the single-field form the designer
+// property panel saves; Dimensions and Measures take several.
+type ComponentBinding struct {
+	DatasetID   string           `json:"datasetId"`
+	Dimension   string           `json:"dimension"`
+	Dimensions  []string         `json:"dimensions"`
+	Measure     string           `json:"measure"`
+	Aggregation string           `json:"aggregation"`
+	Measures    []Measure        `json:"measures"`
+	Filters     []dataset.Filter `json:"filters"`
+	SortBy      string           `json:"sortBy"`
+	SortOrder   string           `json:"sortOrder"`
+	Limit       int              `json:"limit"`
+	DrillPath   string           `json:"drillPath"`
+
+	drill *dataset.DrillState
+}
+
+// Measure is a bound numeric field. With an aggregation the records are
+// grouped by the dimensions and the value is keyed by Alias, or the field
+// name; without one ("none") the raw values are returned.
+type Measure struct {
+	Field       string `json:"field"`
+	Aggregation string `json:"aggregation"`
+	Alias       string `json:"alias"`
+}
+
+// ComponentData is the resolved data of one component. Fields lists the
+// record fields, which a drill replaces with the drilled level. Error is set
+// instead of Records when its query failed, without failing the others.
+type ComponentData struct {
+	Fields  []string                 `json:"fields,omitempty"`
+	Records []map[string]interface{} `json:"records"`
+	Error   string                   `json:"error,omitempty"`
+}
+
+// DataResponse holds the data of every bound, visible component of a
+// dashboard, keyed by component ID.
+type DataResponse struct {
+	DashboardID string                    `json:"dashboardId"`
+	Components  map[string]*ComponentData `json:"components"`
+}
+
+var bindingAggregations = map[string]bool{"SUM": true, "AVG": true, "COUNT": true, "MIN": true, "MAX": true}
+
+// componentBinding reads the dataset binding of a component; ok is false
+// when the component is not bound to a dataset.
+func componentBinding(component models.DashboardComponent) (*ComponentBinding, bool, error) {
+	datasetID, _ := component.Data["datasetId"].(string)
+	if datasetID == "" {
+		return nil, false, nil
+	}
+	raw, err := json.Marshal(component.Data)
+	if err != nil {
+		return nil, true, fmt.Errorf("%w: %v", ErrInvalidBinding, err)
+	}
+	var binding ComponentBinding
+	if err := json.Unmarshal(raw, &binding); err != nil {
+		return nil, true, fmt.Errorf("%w: %v", ErrInvalidBinding, err)
+	}
+	if binding.Dimension != "" && len(binding.Dimensions) == 0 {
+		binding.Dimensions = []string{binding.Dimension}
+	}
+	if binding.Measure != "" && len(binding.Measures) == 0 {
+		binding.Measures = []Measure{{Field: binding.Measure, Aggregation: binding.Aggregation}}
+	}
+	return &binding, true, nil
+}
+
+// queryRequest turns a binding into a dataset query. Aggregated measures
+// group the records by the dimensions; raw measures are selected alongside.
+func (b *ComponentBinding) queryRequest() (*dataset.QueryRequest, error) {
+	if len(b.Dimensions) == 0 && len(b.Measures) == 0 {
+		return nil, fmt.Errorf("%w: no dimension or measure", ErrInvalidBinding)
+	}
+	if b.Limit < 0 {
+		return nil, fmt.Errorf("%w: negative limit", ErrInvalidBinding)
+	}
+	req := &dataset.QueryRequest{
+		DatasetID: b.DatasetID,
+		Fields:    append([]string(nil), b.Dimensions...),
+		Filters:   b.Filters,
+		SortBy:    b.SortBy,
+		SortOrder: b.SortOrder,
+	}
+	aliases := map[string]bool{}
+	for _, measure := range b.Measures {
+		if measure.Field == "" {
+			return nil, fmt.Errorf("%w: measure has no field", ErrInvalidBinding)
+		}
+		function := strings.ToUpper(measure.Aggregation)
+		if function == "" || function == "NONE" {
+			req.Fields = append(req.Fields, measure.Field)
+			continue
+		}
+		if !bindingAggregations[function] {
+			return nil, fmt.Errorf("%w: unsupported aggregation %q", ErrInvalidBinding, measure.Aggregation)
+		}
+		alias := measure.Alias
+		if alias == "" {
+			alias = measure.Field
+		}
+		if aliases[alias] {
+			return nil, fmt.Errorf("%w: duplicate measure %q", ErrInvalidBinding, alias)
+		}
+		aliases[alias] = true
+		if req.Aggregations == nil {
+			req.Aggregations = map[string]dataset.Aggregation{}
+		}
+		req.Aggregations[alias] = dataset.Aggregation{Function: function, Field: measure.Field}
+	}
+	if len(req.Aggregations) > 0 {
+		if len(req.Fields) > len(b.Dimensions) {
+			return nil, fmt.Errorf("%w: raw and aggregated measures cannot be mixed", ErrInvalidBinding)
+		}
+		req.GroupBy = req.Fields
+	}
+	if b.drill != nil {
+		drill := *b.drill
+		if drill.Path == "" {
+			drill.Path = b.DrillPath
+		}
+		req.Drill = &drill
+	}
+	return req, nil
+}
+
+// resolveComponents queries the bound, visible components of a dashboard,
+// at most concurrency at a time, adding the active dashboard filters and
+// drill state of each. A failed component carries its error and does not
+// affect the others.
+func resolveComponents(ctx context.Context, executor dataset.QueryExecutor, tenantID string, components []models.DashboardComponent, active map[string][]dataset.Filter, drills map[string]*dataset.DrillState, concurrency int) map[string]*ComponentData {
+	result := make(map[string]*ComponentData)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, concurrency)
+
+	for _, component := range components {
+		if !component.Visible {
+			continue
+		}
+		binding, bound, err := componentBinding(component)
+		if !bound {
+			continue
+		}
+		if err != nil {
+			result[component.ID] = &ComponentData{Error: err.Error()}
+			continue
+		}
+		if extra := active[component.ID]; len(extra) > 0 {
+			binding.Filters = append(append([]dataset.Filter(nil), binding.Filters...), extra...)
+		}
+		binding.drill = drills[component.ID]
+
+		wg.Add(1)
+		go func(id string, binding *ComponentBinding) {
+			defer wg.Done()
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+			case <-ctx.Done():
+				mu.Lock()
+				result[id] = &ComponentData{Error: ctx.Err().Error()}
+				mu.Unlock()
+				return
+			}
+
+			data := resolveComponent(ctx, executor, tenantID, binding)
+			mu.Lock()
+			result[id] = data
+			mu.Unlock()
+		}(component.ID, binding)
+	}
+	wg.Wait()
+	return result
+}
+
+func resolveComponent(ctx context.Context, executor dataset.QueryExecutor, tenantID string, binding *ComponentBinding) (data *ComponentData) {
+	// A panicking query must not take the other components down with it.
+	defer func() {
+		if r := recover(); r != nil {
+			data = &ComponentData{Error: fmt.Sprintf("query panicked: %v", r)}
+		}
+	}()
+
+	req, err := binding.queryRequest()
+	if err != nil {
+		return &ComponentData{Error: err.Error()}
+	}
+	limit := binding.Limit
+	if limit == 0 {
+		limit = DefaultBindingLimit
+	}
+	records, err := dataset.QueryTopRecords(ctx, executor, tenantID, req, limit)
+	if err != nil {
+		return &ComponentData{Error: err.Error()}
+	}
+	if records == nil {
+		records = []map[string]interface{}{}
+	}
+	return &ComponentData{Fields: req.Fields, Records: records}
+}
@@ -0,0 +1,229 @@
+package dashboard
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gujiaweiguo/goreport/internal/dataset"
+	"github.com/gujiaweiguo/goreport/internal/models"
+	repos "github.com/gujiaweiguo/goreport/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeQueryExecutor exports rows identical rows per dataset, or two, failing
+// the datasets in fail, and records the requests and peak concurrency it
+// sees.
+type fakeQueryExecutor struct {
+	mu       sync.Mutex
+	requests map[string]*dataset.QueryRequest
+	fail     map[string]bool
+	rows     int
+	delay    time.Duration
+	running  int32
+	peak     int32
+}
+
+func (f *fakeQueryExecutor) Query(ctx context.Context, req *dataset.QueryRequest) (*dataset.QueryResponse, error) {
+	return nil, errors.New("not used")
+}
+
+func (f *fakeQueryExecutor) Export(ctx context.Context, tenantID string, req *dataset.QueryRequest, w dataset.RowWriter) (int64, error) {
+	running := atomic.AddInt32(&f.running, 1)
+	defer atomic.AddInt32(&f.running, -1)
+	for {
+		peak := atomic.LoadInt32(&f.peak)
+		if running <= peak || atomic.CompareAndSwapInt32(&f.peak, peak, running) {
+			break
+		}
+	}
+	time.Sleep(f.delay)
+
+	f.mu.Lock()
+	if f.requests == nil {
+		f.requests = map[string]*dataset.QueryRequest{}
+	}
+	f.requests[req.DatasetID] = req
+	f.mu.Unlock()
+
+	if tenantID != "tenant-1" || f.fail[req.DatasetID] {
+		return 0, errors.New("dataset not found")
+	}
+	headers := append([]string(nil), req.Fields...)
+	row := []interface{}{}
+	for range req.Fields {
+		row = append(row, "North")
+	}
+	for alias := range req.Aggregations {
+		headers = append(headers, alias)
+		row = append(row, 42.0)
+	}
+	_ = w.WriteHeader(headers)
+	rows := f.rows
+	if rows == 0 {
+		rows = 2
+	}
+	for i := 0; i < rows; i++ {
+		_ = w.WriteRow(row)
+	}
+	return int64(rows), nil
+}
+
+func boundComponent(id string, data map[string]interface{}) models.DashboardComponent {
+	return models.DashboardComponent{ID: id, Visible: true, Data: data}
+}
+
+func TestService_Data(t *testing.T) {
+	executor := &fakeQueryExecutor{fail: map[string]bool{"ds-broken": true}}
+	repo := &mockDashboardRepo{dashboard: &models.Dashboard{
+		ID: "dashboard-1",
+		Components: []models.DashboardComponent{
+			boundComponent("sales", map[string]interface{}{"datasetId": "ds-sales", "dimension": "region", "measure": "amount", "aggregation": "SUM"}),
+			boundComponent("raw", map[string]interface{}{"datasetId": "ds-raw", "dimensions": []interface{}{"region"}, "measures": []interface{}{map[string]interface{}{"field": "amount", "aggregation": "none"}}, "limit": 1.0}),
+			boundComponent("broken", map[string]interface{}{"datasetId": "ds-broken", "dimension": "region"}),
+			boundComponent("invalid", map[string]interface{}{"datasetId": "ds-invalid", "measure": "amount", "aggregation": "MEDIAN"}),
+			boundComponent("text", map[string]interface{}{"dataSource": "static"}),
+			{ID: "hidden", Visible: false, Data: map[string]interface{}{"datasetId": "ds-hidden", "dimension": "region"}},
+		},
+	}}
+	svc := NewService(repo, executor, nil)
+
+	data, err := svc.Data(context.Background(), "dashboard-1", "tenant-1", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "dashboard-1", data.DashboardID)
+	assert.Len(t, data.Components, 4, "unbound and hidden components are skipped")
+
+	assert.Empty(t, data.Components["sales"].Error)
+	assert.Equal(t, []map[string]interface{}{{"region": "North", "amount": 42.0}, {"region": "North", "amount": 42.0}}, data.Components["sales"].Records)
+	sales := executor.requests["ds-sales"]
+	assert.Equal(t, []string{"region"}, sales.GroupBy)
+	assert.Equal(t, dataset.Aggregation{Function: "SUM", Field: "amount"}, sales.Aggregations["amount"])
+
+	assert.Equal(t, []map[string]interface{}{{"region": "North", "amount": "North"}}, data.Components["raw"].Records)
+	assert.Empty(t, executor.requests["ds-raw"].GroupBy)
+
+	assert.Equal(t, "dataset not found", data.Components["broken"].Error)
+	assert.Contains(t, data.Components["invalid"].Error, "MEDIAN")
+	assert.NotContains(t, executor.requests, "ds-hidden")
+
+	_, err = svc.Data(context.Background(), "missing", "tenant-1", nil)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+// stubDatasets serves one dataset to a real query executor.
+type stubDatasets struct {
+	repos.DatasetRepository
+	dataset *models.Dataset
+}
+
+func (s *stubDatasets) GetByIDWithFields(ctx context.Context, id string) (*models.Dataset, error) {
+	if id != s.dataset.ID {
+		return nil, errors.New("not found")
+	}
+	copied := *s.dataset
+	return &copied, nil
+}
+
+func TestService_Data_DisplayNames(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"items":[{"region":"east","amount":10},{"region":"west","amount":7},{"region":"east","amount":5}]}`))
+	}))
+	defer server.Close()
+
+	regionName, amountName := "Region", "Amount (USD)"
+	datasets := &stubDatasets{dataset: &models.Dataset{
+		ID:       "ds-sales",
+		TenantID: "tenant-1",
+		Type:     "api",
+		Config:   `{"url":"` + server.URL + `","dataPath":"items"}`,
+		Fields: []models.DatasetField{
+			{Name: "region", DataType: "string", DisplayName: &regionName},
+			{Name: "amount", DataType: "number", DisplayName: &amountName},
+		},
+	}}
+	executor := dataset.NewQueryExecutor(datasets, nil, nil, dataset.NewSQLExpressionBuilder(), dataset.NewComputedFieldCache())
+	repo := &mockDashboardRepo{dashboard: &models.Dashboard{
+		ID: "dashboard-1",
+		Components: []models.DashboardComponent{
+			boundComponent("sales", map[string]interface{}{"datasetId": "ds-sales", "dimension": "region", "measure": "amount", "aggregation": "SUM", "sortBy": "amount", "sortOrder": "desc", "limit": 1.0}),
+			boundComponent("total", map[string]interface{}{"datasetId": "ds-sales", "measure": "amount", "aggregation": "SUM"}),
+		},
+	}}
+	svc := NewService(repo, executor, nil)
+
+	data, err := svc.Data(context.Background(), "dashboard-1", "tenant-1", nil)
+	require.NoError(t, err)
+	require.Empty(t, data.Components["sales"].Error)
+	assert.Equal(t, []map[string]interface{}{{"region": "east", "amount": 15.0}}, data.Components["sales"].Records)
+	require.Empty(t, data.Components["total"].Error)
+	assert.Equal(t, []map[string]interface{}{{"amount": 22.0}}, data.Components["total"].Records)
+}
+
+func TestService_Data_BoundedConcurrency(t *testing.T) {
+	executor := &fakeQueryExecutor{delay: 10 * time.Millisecond}
+	components := make([]models.DashboardComponent, 30)
+	for i := range components {
+		id := string(rune('a'+i%26)) + string(rune('0'+i/26))
+		components[i] = boundComponent(id, map[string]interface{}{"datasetId": "ds-" + id, "dimension": "region"})
+	}
+	repo := &mockDashboardRepo{dashboard: &models.Dashboard{ID: "dashboard-1", Components: components}}
+	svc := &service{repo: repo, queryExecutor: executor, concurrency: 4}
+
+	data, err := svc.Data(context.Background(), "dashboard-1", "tenant-1", nil)
+	require.NoError(t, err)
+	assert.Len(t, data.Components, 30)
+	assert.LessOrEqual(t, atomic.LoadInt32(&executor.peak), int32(4))
+	assert.Greater(t, atomic.LoadInt32(&executor.peak), int32(1), "components load in parallel")
+}
+
+func TestService_Data_DefaultLimit(t *testing.T) {
+	executor := &fakeQueryExecutor{rows: DefaultBindingLimit + 5}
+	repo := &mockDashboardRepo{dashboard: &models.Dashboard{
+		ID: "dashboard-1",
+		Components: []models.DashboardComponent{
+			boundComponent("unbounded", map[string]interface{}{"datasetId": "ds-unbounded", "dimension": "region"}),
+			boundComponent("bounded", map[string]interface{}{"datasetId": "ds-bounded", "dimension": "region", "limit": 3.0}),
+		},
+	}}
+	svc := NewService(repo, executor, nil)
+
+	data, err := svc.Data(context.Background(), "dashboard-1", "tenant-1", nil)
+	require.NoError(t, err)
+	assert.Len(t, data.Components["unbounded"].Records, DefaultBindingLimit)
+	assert.Len(t, data.Components["bounded"].Records, 3)
+}
+
+func TestComponentBinding_QueryRequest(t *testing.T) {
+	binding := &ComponentBinding{
+		DatasetID:  "ds-1",
+		Dimensions: []string{"region", "month"},
+		Measures:   []Measure{{Field: "amount", Aggregation: "sum"}, {Field: "amount", Aggregation: "avg", Alias: "avgAmount"}},
+		Filters:    []dataset.Filter{{Field: "year", Operator: "eq", Value: 2026}},
+	}
+	req, err := binding.queryRequest()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"region", "month"}, req.GroupBy)
+	assert.Equal(t, map[string]dataset.Aggregation{
+		"amount":    {Function: "SUM", Field: "amount"},
+		"avgAmount": {Function: "AVG", Field: "amount"},
+	}, req.Aggregations)
+	assert.Equal(t, binding.Filters, req.Filters)
+
+	invalid := map[string]*ComponentBinding{
+		"empty":     {DatasetID: "ds-1"},
+		"duplicate": {DatasetID: "ds-1", Measures: []Measure{{Field: "amount", Aggregation: "SUM"}, {Field: "amount", Aggregation: "MAX"}}},
+		"mixed":     {DatasetID: "ds-1", Measures: []Measure{{Field: "amount", Aggregation: "SUM"}, {Field: "qty"}}},
+		"field":     {DatasetID: "ds-1", Measures: []Measure{{Aggregation: "SUM"}}},
+	}
+	for name, binding := range invalid {
+		_, err := binding.queryRequest()
+		assert.ErrorIs(t, err, ErrInvalidBinding, name)
+	}
+}
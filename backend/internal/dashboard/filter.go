@@ -0,0 +1,260 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/gujiaweiguo/goreport/internal/dataset"
+	"github.com/gujiaweiguo/goreport/internal/models"
+)
+
+const (
+	FilterDateRange = "dateRange"
+	FilterSelect    = "select"
+	FilterSearch    = "search"
+
+	// maxFilterOptions bounds the distinct values a dropdown offers.
+	maxFilterOptions = 1000
+)
+
+var (
+	ErrInvalidFilter  = errors.New("invalid dashboard filter")
+	ErrFilterNotFound = errors.New("dashboard filter not found")
+)
+
+// FilterState is the active filter state of a dashboard: control values by
+// filter ID, the chart elements clicked for cross-component linkage, and
+// the drill state of components by component ID. A control missing from
+// Filters takes its default; one set to null is off.
+type FilterState struct {
+	Filters map[string]interface{}         `json:"filters"`
+	Links   []LinkSelection                `json:"links"`
+	Drills  map[string]*dataset.DrillState `json:"drills"`
+}
+
+// LinkSelection is the value of the element clicked in a component.
+type LinkSelection struct {
+	ComponentID string      `json:"componentId"`
+	Value       interface{} `json:"value"`
+}
+
+// Linkage is kept under "linkage" in a component's Interaction: clicking an
+// element of the component filters each target on the clicked Field value.
+type Linkage struct {
+	Field   string                `json:"field"`
+	Targets []models.FilterTarget `json:"targets"`
+}
+
+func componentLinkage(component models.DashboardComponent) (*Linkage, error) {
+	raw, ok := component.Interaction["linkage"]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFilter, err)
+	}
+	var linkage Linkage
+	if err := json.Unmarshal(data, &linkage); err != nil {
+		return nil, fmt.Errorf("%w: component %s linkage: %v", ErrInvalidFilter, component.ID, err)
+	}
+	return &linkage, nil
+}
+
+// componentDrillThrough reads the drill-through kept under "drillThrough" in
+// a component's Interaction.
+func componentDrillThrough(component models.DashboardComponent) (*dataset.DrillThrough, error) {
+	raw, ok := component.Interaction["drillThrough"]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFilter, err)
+	}
+	var drillThrough dataset.DrillThrough
+	if err := json.Unmarshal(data, &drillThrough); err != nil {
+		return nil, fmt.Errorf("%w: component %s drill-through: %v", ErrInvalidFilter, component.ID, err)
+	}
+	return &drillThrough, nil
+}
+
+// validateFilters checks the filter controls and linkages of a dashboard
+// refer to its own components.
+func validateFilters(config models.DashboardConfig, components []models.DashboardComponent) error {
+	componentIDs := make(map[string]bool, len(components))
+	for _, component := range components {
+		componentIDs[component.ID] = true
+	}
+	checkTargets := func(owner string, targets []models.FilterTarget) error {
+		if len(targets) == 0 {
+			return fmt.Errorf("%w: %s has no targets", ErrInvalidFilter, owner)
+		}
+		for _, target := range targets {
+			if !componentIDs[target.ComponentID] {
+				return fmt.Errorf("%w: %s targets unknown component %q", ErrInvalidFilter, owner, target.ComponentID)
+			}
+			if target.Field == "" {
+				return fmt.Errorf("%w: %s target %s has no field", ErrInvalidFilter, owner, target.ComponentID)
+			}
+		}
+		return nil
+	}
+
+	filterIDs := make(map[string]bool, len(config.Filters))
+	for _, filter := range config.Filters {
+		if filter.ID == "" {
+			return fmt.Errorf("%w: filter has no id", ErrInvalidFilter)
+		}
+		if filterIDs[filter.ID] {
+			return fmt.Errorf("%w: duplicate filter %q", ErrInvalidFilter, filter.ID)
+		}
+		filterIDs[filter.ID] = true
+		switch filter.Type {
+		case FilterDateRange, FilterSearch:
+		case FilterSelect:
+			if filter.Options == nil || filter.Options.DatasetID == "" || filter.Options.Field == "" {
+				return fmt.Errorf("%w: select filter %q needs an options dataset field", ErrInvalidFilter, filter.ID)
+			}
+		default:
+			return fmt.Errorf("%w: unsupported filter type %q", ErrInvalidFilter, filter.Type)
+		}
+		if err := checkTargets("filter "+filter.ID, filter.Targets); err != nil {
+			return err
+		}
+	}
+
+	for _, component := range components {
+		drillThrough, err := componentDrillThrough(component)
+		if err != nil {
+			return err
+		}
+		if drillThrough != nil {
+			if err := drillThrough.Validate(); err != nil {
+				return fmt.Errorf("%w: component %s: %v", ErrInvalidFilter, component.ID, err)
+			}
+		}
+		linkage, err := componentLinkage(component)
+		if err != nil {
+			return err
+		}
+		if linkage == nil {
+			continue
+		}
+		if linkage.Field == "" {
+			return fmt.Errorf("%w: component %s linkage has no field", ErrInvalidFilter, component.ID)
+		}
+		if err := checkTargets("component "+component.ID+" linkage", linkage.Targets); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// activeFilters turns the filter state into dataset filters for each target
+// component, keyed by component ID.
+func activeFilters(dashboard *models.Dashboard, state *FilterState) (map[string][]dataset.Filter, error) {
+	if state == nil {
+		state = &FilterState{}
+	}
+	filters := make(map[string]interface{}, len(dashboard.Config.Filters))
+	known := make(map[string]bool, len(dashboard.Config.Filters))
+	for _, filter := range dashboard.Config.Filters {
+		known[filter.ID] = true
+		filters[filter.ID] = filter.Default
+	}
+	for id, value := range state.Filters {
+		if !known[id] {
+			return nil, fmt.Errorf("%w: unknown filter %q", ErrInvalidFilter, id)
+		}
+		filters[id] = value
+	}
+
+	result := map[string][]dataset.Filter{}
+	for _, filter := range dashboard.Config.Filters {
+		conditions, err := filterConditions(filter.Type, filters[filter.ID])
+		if err != nil {
+			return nil, fmt.Errorf("%w: filter %q: %v", ErrInvalidFilter, filter.ID, err)
+		}
+		applyConditions(result, filter.Targets, conditions)
+	}
+
+	components := make(map[string]models.DashboardComponent, len(dashboard.Components))
+	for _, component := range dashboard.Components {
+		components[component.ID] = component
+	}
+	for id := range state.Drills {
+		if _, ok := components[id]; !ok {
+			return nil, fmt.Errorf("%w: unknown drilled component %q", ErrInvalidFilter, id)
+		}
+	}
+	for _, link := range state.Links {
+		component, ok := components[link.ComponentID]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown linked component %q", ErrInvalidFilter, link.ComponentID)
+		}
+		linkage, err := componentLinkage(component)
+		if err != nil {
+			return nil, err
+		}
+		if linkage == nil {
+			return nil, fmt.Errorf("%w: component %q has no linkage", ErrInvalidFilter, link.ComponentID)
+		}
+		if link.Value == nil {
+			continue
+		}
+		applyConditions(result, linkage.Targets, []dataset.Filter{{Operator: "eq", Value: link.Value}})
+	}
+	return result, nil
+}
+
+// filterConditions maps a control value onto conditions, leaving the field
+// to each target. An unset value yields none.
+func filterConditions(filterType string, value interface{}) ([]dataset.Filter, error) {
+	if value == nil {
+		return nil, nil
+	}
+	switch filterType {
+	case FilterDateRange:
+		bounds, ok := value.([]interface{})
+		if !ok || len(bounds) != 2 {
+			return nil, errors.New("a date range is [start, end]")
+		}
+		var conditions []dataset.Filter
+		if bounds[0] != nil && bounds[0] != "" {
+			conditions = append(conditions, dataset.Filter{Operator: "gte", Value: bounds[0]})
+		}
+		if bounds[1] != nil && bounds[1] != "" {
+			conditions = append(conditions, dataset.Filter{Operator: "lte", Value: bounds[1]})
+		}
+		return conditions, nil
+	case FilterSelect:
+		if values, ok := value.([]interface{}); ok {
+			if len(values) == 0 {
+				return nil, nil
+			}
+			return []dataset.Filter{{Operator: "in", Value: values}}, nil
+		}
+		return []dataset.Filter{{Operator: "eq", Value: value}}, nil
+	case FilterSearch:
+		text, ok := value.(string)
+		if !ok {
+			return nil, errors.New("a search is text")
+		}
+		if text == "" {
+			return nil, nil
+		}
+		return []dataset.Filter{{Operator: "like", Value: "%" + text + "%"}}, nil
+	}
+	return nil, fmt.Errorf("unsupported filter type %q", filterType)
+}
+
+func applyConditions(result map[string][]dataset.Filter, targets []models.FilterTarget, conditions []dataset.Filter) {
+	for _, target := range targets {
+		for _, condition := range conditions {
+			condition.Field = target.Field
+			result[target.ComponentID] = append(result[target.ComponentID], condition)
+		}
+	}
+}
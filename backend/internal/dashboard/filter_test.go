@@ -0,0 +1,184 @@
+package dashboard
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gujiaweiguo/goreport/internal/dataset"
+	"github.com/gujiaweiguo/goreport/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func filteredDashboard() *models.Dashboard {
+	linkage := map[string]interface{}{"linkage": map[string]interface{}{
+		"field":   "region",
+		"targets": []interface{}{map[string]interface{}{"componentId": "orders", "field": "sales_region"}},
+	}}
+	return &models.Dashboard{
+		ID: "dashboard-1",
+		Config: models.DashboardConfig{Width: 1920, Height: 1080, Filters: []models.DashboardFilter{
+			{ID: "period", Type: FilterDateRange, Default: []interface{}{"2026-01-01", "2026-12-31"}, Targets: []models.FilterTarget{
+				{ComponentID: "sales", Field: "order_date"}, {ComponentID: "orders", Field: "created_at"},
+			}},
+			{ID: "region", Type: FilterSelect, Options: &models.FilterOptionSource{DatasetID: "ds-regions", Field: "name"}, Targets: []models.FilterTarget{
+				{ComponentID: "sales", Field: "region"},
+			}},
+			{ID: "customer", Type: FilterSearch, Targets: []models.FilterTarget{{ComponentID: "orders", Field: "customer"}}},
+		}},
+		Components: []models.DashboardComponent{
+			{ID: "sales", Visible: true, Data: map[string]interface{}{"datasetId": "ds-sales", "dimension": "region", "measure": "amount", "aggregation": "SUM"}, Interaction: linkage},
+			{ID: "orders", Visible: true, Data: map[string]interface{}{"datasetId": "ds-orders", "dimension": "customer"}},
+		},
+	}
+}
+
+func TestActiveFilters(t *testing.T) {
+	dashboard := filteredDashboard()
+
+	active, err := activeFilters(dashboard, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []dataset.Filter{
+		{Field: "order_date", Operator: "gte", Value: "2026-01-01"},
+		{Field: "order_date", Operator: "lte", Value: "2026-12-31"},
+	}, active["sales"], "defaults apply without a state")
+
+	active, err = activeFilters(dashboard, &FilterState{
+		Filters: map[string]interface{}{
+			"period":   []interface{}{"2026-03-01", nil},
+			"region":   []interface{}{"North", "East"},
+			"customer": "acme",
+		},
+		Links: []LinkSelection{{ComponentID: "sales", Value: "North"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []dataset.Filter{
+		{Field: "order_date", Operator: "gte", Value: "2026-03-01"},
+		{Field: "region", Operator: "in", Value: []interface{}{"North", "East"}},
+	}, active["sales"])
+	assert.Equal(t, []dataset.Filter{
+		{Field: "created_at", Operator: "gte", Value: "2026-03-01"},
+		{Field: "customer", Operator: "like", Value: "%acme%"},
+		{Field: "sales_region", Operator: "eq", Value: "North"},
+	}, active["orders"])
+
+	active, err = activeFilters(dashboard, &FilterState{Filters: map[string]interface{}{"period": nil, "region": "West"}})
+	require.NoError(t, err)
+	assert.Equal(t, []dataset.Filter{{Field: "region", Operator: "eq", Value: "West"}}, active["sales"], "a null value turns the default off")
+
+	invalid := map[string]*FilterState{
+		"unknown filter":    {Filters: map[string]interface{}{"missing": "x"}},
+		"bad range":         {Filters: map[string]interface{}{"period": "2026-01-01"}},
+		"bad search":        {Filters: map[string]interface{}{"customer": 42.0}},
+		"unknown component": {Links: []LinkSelection{{ComponentID: "missing", Value: "x"}}},
+		"no linkage":        {Links: []LinkSelection{{ComponentID: "orders", Value: "x"}}},
+	}
+	for name, state := range invalid {
+		_, err := activeFilters(dashboard, state)
+		assert.ErrorIs(t, err, ErrInvalidFilter, name)
+	}
+}
+
+func TestValidateFilters(t *testing.T) {
+	dashboard := filteredDashboard()
+	require.NoError(t, validateFilters(dashboard.Config, dashboard.Components))
+
+	invalid := map[string]func(*models.Dashboard){
+		"type":      func(d *models.Dashboard) { d.Config.Filters[0].Type = "slider" },
+		"duplicate": func(d *models.Dashboard) { d.Config.Filters[1].ID = "period" },
+		"options":   func(d *models.Dashboard) { d.Config.Filters[1].Options = nil },
+		"target":    func(d *models.Dashboard) { d.Config.Filters[2].Targets[0].ComponentID = "missing" },
+		"no target": func(d *models.Dashboard) { d.Config.Filters[2].Targets = nil },
+		"linkage": func(d *models.Dashboard) {
+			d.Components[1].Interaction = map[string]interface{}{"linkage": map[string]interface{}{"targets": []interface{}{}}}
+		},
+	}
+	for name, mutate := range invalid {
+		dashboard := filteredDashboard()
+		mutate(dashboard)
+		assert.ErrorIs(t, validateFilters(dashboard.Config, dashboard.Components), ErrInvalidFilter, name)
+	}
+}
+
+func TestService_Data_AppliesFilters(t *testing.T) {
+	executor := &fakeQueryExecutor{}
+	svc := NewService(&mockDashboardRepo{dashboard: filteredDashboard()}, executor, nil)
+
+	_, err := svc.Data(context.Background(), "dashboard-1", "tenant-1", &FilterState{
+		Filters: map[string]interface{}{"period": nil, "region": "North"},
+		Links:   []LinkSelection{{ComponentID: "sales", Value: "North"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []dataset.Filter{{Field: "region", Operator: "eq", Value: "North"}}, executor.requests["ds-sales"].Filters)
+	assert.Equal(t, []dataset.Filter{{Field: "sales_region", Operator: "eq", Value: "North"}}, executor.requests["ds-orders"].Filters)
+
+	_, err = svc.Data(context.Background(), "dashboard-1", "tenant-1", &FilterState{Filters: map[string]interface{}{"missing": 1.0}})
+	assert.ErrorIs(t, err, ErrInvalidFilter)
+}
+
+func TestService_FilterOptions(t *testing.T) {
+	executor := &fakeQueryExecutor{}
+	svc := NewService(&mockDashboardRepo{dashboard: filteredDashboard()}, executor, nil)
+
+	options, err := svc.FilterOptions(context.Background(), "dashboard-1", "tenant-1", "region")
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"North", "North"}, options)
+	assert.Equal(t, []string{"name"}, executor.requests["ds-regions"].GroupBy)
+
+	_, err = svc.FilterOptions(context.Background(), "dashboard-1", "tenant-1", "customer")
+	assert.ErrorIs(t, err, ErrFilterNotFound)
+	_, err = svc.FilterOptions(context.Background(), "missing", "tenant-1", "region")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestService_Create_InvalidFilter(t *testing.T) {
+	svc := NewService(&mockDashboardRepo{}, nil, nil)
+	dashboard := filteredDashboard()
+	dashboard.Config.Filters[0].Type = "slider"
+
+	_, err := svc.Create(context.Background(), &CreateRequest{Name: "Sales", Config: dashboard.Config, Components: dashboard.Components})
+	assert.ErrorIs(t, err, ErrInvalidFilter)
+}
+
+func TestService_Data_Drill(t *testing.T) {
+	executor := &fakeQueryExecutor{}
+	dashboard := filteredDashboard()
+	dashboard.Components[0].Data["drillPath"] = "geo"
+	svc := NewService(&mockDashboardRepo{dashboard: dashboard}, executor, nil)
+
+	data, err := svc.Data(context.Background(), "dashboard-1", "tenant-1", &FilterState{
+		Drills: map[string]*dataset.DrillState{"sales": {Values: []interface{}{"North"}}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, &dataset.DrillState{Path: "geo", Values: []interface{}{"North"}}, executor.requests["ds-sales"].Drill, "the binding supplies the default path")
+	assert.Nil(t, executor.requests["ds-orders"].Drill)
+	assert.Equal(t, []string{"region"}, data.Components["sales"].Fields)
+
+	_, err = svc.Data(context.Background(), "dashboard-1", "tenant-1", &FilterState{
+		Drills: map[string]*dataset.DrillState{"missing": {Path: "geo"}},
+	})
+	assert.ErrorIs(t, err, ErrInvalidFilter)
+}
+
+func TestService_DrillThrough(t *testing.T) {
+	dashboard := filteredDashboard()
+	dashboard.Components[1].Interaction = map[string]interface{}{"drillThrough": map[string]interface{}{
+		"targetType": "report", "targetId": "report-1", "params": map[string]interface{}{"customer": "customer"},
+	}}
+	require.NoError(t, validateFilters(dashboard.Config, dashboard.Components))
+	svc := NewService(&mockDashboardRepo{dashboard: dashboard}, nil, nil)
+
+	target, err := svc.DrillThrough(context.Background(), "dashboard-1", "tenant-1", "orders", map[string]interface{}{"customer": "acme"})
+	require.NoError(t, err)
+	assert.Equal(t, &dataset.DrillThroughTarget{TargetType: "report", TargetID: "report-1", Params: map[string]interface{}{"customer": "acme"}}, target)
+
+	_, err = svc.DrillThrough(context.Background(), "dashboard-1", "tenant-1", "orders", map[string]interface{}{})
+	assert.ErrorIs(t, err, ErrInvalidFilter, "the row lacks a mapped field")
+	_, err = svc.DrillThrough(context.Background(), "dashboard-1", "tenant-1", "sales", map[string]interface{}{})
+	assert.ErrorIs(t, err, ErrInvalidFilter, "no drill-through configured")
+	_, err = svc.DrillThrough(context.Background(), "missing", "tenant-1", "orders", nil)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	dashboard.Components[1].Interaction["drillThrough"] = map[string]interface{}{"targetType": "page", "targetId": "x"}
+	assert.ErrorIs(t, validateFilters(dashboard.Config, dashboard.Components), ErrInvalidFilter)
+}
@@ -1,10 +1,16 @@
 package dashboard
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gujiaweiguo/goreport/internal/auth"
+	"github.com/gujiaweiguo/goreport/internal/models"
+	"github.com/gujiaweiguo/goreport/internal/version"
 )
 
 type Handler struct {
@@ -22,7 +28,7 @@ func (h *Handler) List(c *gin.Context) {
 		return
 	}
 
-	dashboards, err := h.service.List(c.Request.Context(), tenantID)
+	dashboards, err := h.service.List(version.ViewContext(c), tenantID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "failed to list dashboards"})
 		return
@@ -43,8 +49,13 @@ func (h *Handler) Create(c *gin.Context) {
 		c.JSON(http.StatusForbidden, gin.H{"success": false, "message": "tenant not found"})
 		return
 	}
+	req.CreatedBy = auth.GetUserID(c)
 
 	dashboard, err := h.service.Create(c.Request.Context(), &req)
+	if errors.Is(err, ErrInvalidFilter) {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "failed to create dashboard"})
 		return
@@ -72,8 +83,13 @@ func (h *Handler) Update(c *gin.Context) {
 		c.JSON(http.StatusForbidden, gin.H{"success": false, "message": "tenant not found"})
 		return
 	}
+	req.UpdatedBy = auth.GetUserID(c)
 
 	dashboard, err := h.service.Update(c.Request.Context(), &req)
+	if errors.Is(err, ErrInvalidFilter) || errors.Is(err, ErrPublishRequired) {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "dashboard not found"})
 		return
@@ -116,7 +132,7 @@ func (h *Handler) Get(c *gin.Context) {
 		return
 	}
 
-	dashboard, err := h.service.Get(c.Request.Context(), id, tenantID)
+	dashboard, err := h.service.Get(version.ViewContext(c), id, tenantID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "dashboard not found"})
 		return
@@ -124,3 +140,277 @@ func (h *Handler) Get(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"success": true, "result": dashboard, "message": "success"})
 }
+
+func (h *Handler) Data(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "id is required"})
+		return
+	}
+
+	tenantID := auth.GetTenantID(c)
+	if tenantID == "" {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "message": "tenant not found"})
+		return
+	}
+
+	state, err := BindFilterState(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "invalid filter state"})
+		return
+	}
+
+	data, err := h.service.Data(version.ViewContext(c), id, tenantID, state)
+	if errors.Is(err, ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "dashboard not found"})
+		return
+	}
+	if errors.Is(err, ErrInvalidFilter) {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "failed to load dashboard data"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": data, "message": "success"})
+}
+
+// BindFilterState reads the filter state from a POST body, or from the JSON
+// "filters", "links" and "drills" query parameters of a GET, as a shared
+// link carries.
+func BindFilterState(c *gin.Context) (*FilterState, error) {
+	var state FilterState
+	if c.Request.Method == http.MethodPost {
+		if c.Request.ContentLength == 0 {
+			return &state, nil
+		}
+		err := c.ShouldBindJSON(&state)
+		return &state, err
+	}
+	if raw := c.Query("filters"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &state.Filters); err != nil {
+			return nil, err
+		}
+	}
+	if raw := c.Query("links"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &state.Links); err != nil {
+			return nil, err
+		}
+	}
+	if raw := c.Query("drills"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &state.Drills); err != nil {
+			return nil, err
+		}
+	}
+	return &state, nil
+}
+
+func (h *Handler) FilterOptions(c *gin.Context) {
+	id := c.Param("id")
+	filterID := c.Param("filterId")
+	if id == "" || filterID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "id is required"})
+		return
+	}
+
+	tenantID := auth.GetTenantID(c)
+	if tenantID == "" {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "message": "tenant not found"})
+		return
+	}
+
+	options, err := h.service.FilterOptions(version.ViewContext(c), id, tenantID, filterID)
+	if errors.Is(err, ErrNotFound) || errors.Is(err, ErrFilterNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "failed to load filter options"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": options, "message": "success"})
+}
+
+type drillThroughRequest struct {
+	Row map[string]interface{} `json:"row" binding:"required"`
+}
+
+// DrillThrough resolves what clicking a row of a component opens.
+func (h *Handler) DrillThrough(c *gin.Context) {
+	id := c.Param("id")
+	componentID := c.Param("componentId")
+	if id == "" || componentID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "id is required"})
+		return
+	}
+
+	var req drillThroughRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "invalid request"})
+		return
+	}
+
+	tenantID := auth.GetTenantID(c)
+	if tenantID == "" {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "message": "tenant not found"})
+		return
+	}
+
+	target, err := h.service.DrillThrough(version.ViewContext(c), id, tenantID, componentID, req.Row)
+	if errors.Is(err, ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "dashboard not found"})
+		return
+	}
+	if errors.Is(err, ErrInvalidFilter) {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "failed to resolve drill-through"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": target, "message": "success"})
+}
+
+func (h *Handler) Versions(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "id is required"})
+		return
+	}
+
+	tenantID := auth.GetTenantID(c)
+	if tenantID == "" {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "message": "tenant not found"})
+		return
+	}
+
+	versions, err := h.service.Versions(c.Request.Context(), id, tenantID)
+	if errors.Is(err, ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "dashboard not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "failed to list versions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": versions, "message": "success"})
+}
+
+func (h *Handler) Version(c *gin.Context) {
+	id := c.Param("id")
+	number, err := strconv.Atoi(c.Param("version"))
+	if id == "" || err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "id and version are required"})
+		return
+	}
+
+	tenantID := auth.GetTenantID(c)
+	if tenantID == "" {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "message": "tenant not found"})
+		return
+	}
+
+	result, err := h.service.Version(c.Request.Context(), id, tenantID, number)
+	if writeVersionError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": result, "message": "success"})
+}
+
+// Diff compares the versions given as the from and to query parameters; an
+// omitted or 0 version is the current draft.
+func (h *Handler) Diff(c *gin.Context) {
+	id := c.Param("id")
+	from, fromErr := strconv.Atoi(c.DefaultQuery("from", "0"))
+	to, toErr := strconv.Atoi(c.DefaultQuery("to", "0"))
+	if id == "" || fromErr != nil || toErr != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "invalid versions"})
+		return
+	}
+
+	tenantID := auth.GetTenantID(c)
+	if tenantID == "" {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "message": "tenant not found"})
+		return
+	}
+
+	diff, err := h.service.Diff(c.Request.Context(), id, tenantID, from, to)
+	if writeVersionError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": diff, "message": "success"})
+}
+
+type versionRequest struct {
+	Version int `json:"version"`
+}
+
+// Publish pins a version for viewers; without one the latest save is
+// published.
+func (h *Handler) Publish(c *gin.Context) {
+	h.changeVersion(c, h.service.Publish, "dashboard published")
+}
+
+// Rollback restores a version into the draft.
+func (h *Handler) Rollback(c *gin.Context) {
+	h.changeVersion(c, h.service.Rollback, "dashboard rolled back")
+}
+
+func (h *Handler) changeVersion(c *gin.Context, change func(ctx context.Context, id, tenantID string, number int, userID string) (*models.Dashboard, error), message string) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "id is required"})
+		return
+	}
+
+	var req versionRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "invalid request"})
+			return
+		}
+	}
+
+	tenantID := auth.GetTenantID(c)
+	if tenantID == "" {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "message": "tenant not found"})
+		return
+	}
+	if !version.CanEdit(auth.GetRoles(c)) {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "message": "insufficient permissions"})
+		return
+	}
+
+	dashboard, err := change(c.Request.Context(), id, tenantID, req.Version, auth.GetUserID(c))
+	if writeVersionError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": dashboard, "message": message})
+}
+
+// writeVersionError writes the response for a failed version operation and
+// reports whether there was one.
+func writeVersionError(c *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "dashboard not found"})
+	case errors.Is(err, version.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "version not found"})
+	case errors.Is(err, ErrInvalidFilter):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "version operation failed"})
+	}
+	return true
+}
@@ -4,13 +4,17 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gujiaweiguo/goreport/internal/auth"
+	"github.com/gujiaweiguo/goreport/internal/dataset"
 	"github.com/gujiaweiguo/goreport/internal/models"
+	"github.com/gujiaweiguo/goreport/internal/version"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -19,7 +23,7 @@ func init() {
 }
 
 func TestNewHandler(t *testing.T) {
-	service := NewService(nil)
+	service := NewService(nil, nil, nil)
 	handler := NewHandler(service)
 	assert.NotNil(t, handler)
 }
@@ -37,6 +41,11 @@ type mockService struct {
 	deleteErr  error
 	getErr     error
 	listErr    error
+	data       *DataResponse
+	dataErr    error
+	state      *FilterState
+	options    []interface{}
+	published  int
 }
 
 func (m *mockService) Create(ctx context.Context, req *CreateRequest) (*models.Dashboard, error) {
@@ -71,6 +80,55 @@ func (m *mockService) List(ctx context.Context, tenantID string) ([]*models.Dash
 	return m.dashboards, nil
 }
 
+func (m *mockService) Data(ctx context.Context, id, tenantID string, state *FilterState) (*DataResponse, error) {
+	m.state = state
+	if m.dataErr != nil {
+		return nil, m.dataErr
+	}
+	return m.data, nil
+}
+
+func (m *mockService) FilterOptions(ctx context.Context, id, tenantID, filterID string) ([]interface{}, error) {
+	if filterID != "region" {
+		return nil, ErrFilterNotFound
+	}
+	return m.options, nil
+}
+
+func (m *mockService) DrillThrough(ctx context.Context, id, tenantID, componentID string, row map[string]interface{}) (*dataset.DrillThroughTarget, error) {
+	if componentID != "c1" {
+		return nil, ErrInvalidFilter
+	}
+	return &dataset.DrillThroughTarget{TargetType: dataset.DrillThroughReport, TargetID: "r1", Params: map[string]interface{}{"region": row["region"]}}, nil
+}
+
+func (m *mockService) Versions(ctx context.Context, id, tenantID string) ([]*version.Version, error) {
+	return []*version.Version{{ResourceID: id, Number: 2}, {ResourceID: id, Number: 1}}, nil
+}
+
+func (m *mockService) Version(ctx context.Context, id, tenantID string, number int) (*version.Version, error) {
+	if number != 1 {
+		return nil, version.ErrNotFound
+	}
+	return &version.Version{ResourceID: id, Number: 1}, nil
+}
+
+func (m *mockService) Diff(ctx context.Context, id, tenantID string, from, to int) (*VersionDiff, error) {
+	return &VersionDiff{From: from, To: to}, nil
+}
+
+func (m *mockService) Publish(ctx context.Context, id, tenantID string, number int, userID string) (*models.Dashboard, error) {
+	m.published = number
+	return m.dashboard, nil
+}
+
+func (m *mockService) Rollback(ctx context.Context, id, tenantID string, number int, userID string) (*models.Dashboard, error) {
+	if number == 0 {
+		return nil, version.ErrNotFound
+	}
+	return m.dashboard, nil
+}
+
 func setTenantID(c *gin.Context, tenantID string) {
 	c.Set(string(auth.TenantIDKey), tenantID)
 }
@@ -347,6 +405,25 @@ func TestHandler_Update_NotFound(t *testing.T) {
 	assert.NotEqual(t, http.StatusOK, w.Code)
 }
 
+func TestHandler_Update_PublishedStatus(t *testing.T) {
+	service := &mockService{updateErr: ErrPublishRequired}
+	handler := NewHandler(service)
+
+	jsonBody, _ := json.Marshal(map[string]interface{}{"name": "Updated", "status": version.StatusPublished})
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Params = gin.Params{{Key: "id", Value: "dashboard-1"}}
+	c.Request = httptest.NewRequest(http.MethodPut, "/dashboards/dashboard-1", bytes.NewReader(jsonBody))
+	c.Request.Header.Set("Content-Type", "application/json")
+	setTenantID(c, "tenant-1")
+
+	handler.Update(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "/publish")
+}
+
 func TestHandler_Delete_Error(t *testing.T) {
 	service := &mockService{deleteErr: assert.AnError}
 	handler := NewHandler(service)
@@ -361,3 +438,226 @@ func TestHandler_Delete_Error(t *testing.T) {
 
 	assert.NotEqual(t, http.StatusOK, w.Code)
 }
+
+func TestHandler_Data(t *testing.T) {
+	service := &mockService{data: &DataResponse{
+		DashboardID: "dashboard-1",
+		Components: map[string]*ComponentData{
+			"c1": {Records: []map[string]interface{}{{"region": "North"}}},
+			"c2": {Error: "dataset not found"},
+		},
+	}}
+	handler := NewHandler(service)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/dashboard/dashboard-1/data", nil)
+	c.Params = gin.Params{{Key: "id", Value: "dashboard-1"}}
+	setTenantID(c, "tenant-1")
+
+	handler.Data(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"c1":{"records":[{"region":"North"}]}`)
+	assert.Contains(t, w.Body.String(), `"c2":{"records":null,"error":"dataset not found"}`)
+}
+
+func TestHandler_Data_NotFound(t *testing.T) {
+	handler := NewHandler(&mockService{dataErr: ErrNotFound})
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/dashboard/missing/data", nil)
+	c.Params = gin.Params{{Key: "id", Value: "missing"}}
+	setTenantID(c, "tenant-1")
+
+	handler.Data(c)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestHandler_Data_FilterState(t *testing.T) {
+	service := &mockService{data: &DataResponse{DashboardID: "dashboard-1"}}
+	handler := NewHandler(service)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	target := "/api/v1/dashboard/dashboard-1/data?filters=" + url.QueryEscape(`{"region":"North"}`) + "&links=" + url.QueryEscape(`[{"componentId":"c1","value":"Q1"}]`)
+	c.Request = httptest.NewRequest(http.MethodGet, target, nil)
+	c.Params = gin.Params{{Key: "id", Value: "dashboard-1"}}
+	setTenantID(c, "tenant-1")
+	handler.Data(c)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, &FilterState{
+		Filters: map[string]interface{}{"region": "North"},
+		Links:   []LinkSelection{{ComponentID: "c1", Value: "Q1"}},
+	}, service.state)
+
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/dashboard/dashboard-1/data", bytes.NewBufferString(`{"filters":{"period":["2026-01-01","2026-03-31"]}}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = gin.Params{{Key: "id", Value: "dashboard-1"}}
+	setTenantID(c, "tenant-1")
+	handler.Data(c)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []interface{}{"2026-01-01", "2026-03-31"}, service.state.Filters["period"])
+
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/dashboard/dashboard-1/data?filters=oops", nil)
+	c.Params = gin.Params{{Key: "id", Value: "dashboard-1"}}
+	setTenantID(c, "tenant-1")
+	handler.Data(c)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	service.dataErr = fmt.Errorf("%w: unknown filter \"x\"", ErrInvalidFilter)
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/dashboard/dashboard-1/data", nil)
+	c.Params = gin.Params{{Key: "id", Value: "dashboard-1"}}
+	setTenantID(c, "tenant-1")
+	handler.Data(c)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestHandler_FilterOptions(t *testing.T) {
+	handler := NewHandler(&mockService{options: []interface{}{"North", "South"}})
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/dashboard/dashboard-1/filters/region/options", nil)
+	c.Params = gin.Params{{Key: "id", Value: "dashboard-1"}, {Key: "filterId", Value: "region"}}
+	setTenantID(c, "tenant-1")
+	handler.FilterOptions(c)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"result":["North","South"]`)
+
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/dashboard/dashboard-1/filters/missing/options", nil)
+	c.Params = gin.Params{{Key: "id", Value: "dashboard-1"}, {Key: "filterId", Value: "missing"}}
+	setTenantID(c, "tenant-1")
+	handler.FilterOptions(c)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestHandler_Data_DrillState(t *testing.T) {
+	service := &mockService{data: &DataResponse{DashboardID: "dashboard-1"}}
+	handler := NewHandler(service)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	target := "/api/v1/dashboard/dashboard-1/data?drills=" + url.QueryEscape(`{"c1":{"path":"geo","values":["North"]}}`)
+	c.Request = httptest.NewRequest(http.MethodGet, target, nil)
+	c.Params = gin.Params{{Key: "id", Value: "dashboard-1"}}
+	setTenantID(c, "tenant-1")
+	handler.Data(c)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, &dataset.DrillState{Path: "geo", Values: []interface{}{"North"}}, service.state.Drills["c1"])
+}
+
+func TestHandler_DrillThrough(t *testing.T) {
+	handler := NewHandler(&mockService{})
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/dashboard/dashboard-1/components/c1/drill-through", bytes.NewBufferString(`{"row":{"region":"North"}}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = gin.Params{{Key: "id", Value: "dashboard-1"}, {Key: "componentId", Value: "c1"}}
+	setTenantID(c, "tenant-1")
+	handler.DrillThrough(c)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"targetId":"r1"`)
+	assert.Contains(t, w.Body.String(), `"region":"North"`)
+
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/dashboard/dashboard-1/components/c2/drill-through", bytes.NewBufferString(`{"row":{}}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = gin.Params{{Key: "id", Value: "dashboard-1"}, {Key: "componentId", Value: "c2"}}
+	setTenantID(c, "tenant-1")
+	handler.DrillThrough(c)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/dashboard/dashboard-1/components/c1/drill-through", bytes.NewBufferString(`{}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = gin.Params{{Key: "id", Value: "dashboard-1"}, {Key: "componentId", Value: "c1"}}
+	setTenantID(c, "tenant-1")
+	handler.DrillThrough(c)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestHandler_Versions(t *testing.T) {
+	handler := NewHandler(&mockService{})
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/dashboard/dashboard-1/versions", nil)
+	c.Params = gin.Params{{Key: "id", Value: "dashboard-1"}}
+	setTenantID(c, "tenant-1")
+	handler.Versions(c)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"number":2`)
+
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/dashboard/dashboard-1/versions/3", nil)
+	c.Params = gin.Params{{Key: "id", Value: "dashboard-1"}, {Key: "version", Value: "3"}}
+	setTenantID(c, "tenant-1")
+	handler.Version(c)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/dashboard/dashboard-1/diff?from=1", nil)
+	c.Params = gin.Params{{Key: "id", Value: "dashboard-1"}}
+	setTenantID(c, "tenant-1")
+	handler.Diff(c)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"from":1,"to":0`)
+
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/dashboard/dashboard-1/diff?from=x", nil)
+	c.Params = gin.Params{{Key: "id", Value: "dashboard-1"}}
+	setTenantID(c, "tenant-1")
+	handler.Diff(c)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestHandler_PublishAndRollback(t *testing.T) {
+	service := &mockService{dashboard: &models.Dashboard{ID: "dashboard-1"}}
+	handler := NewHandler(service)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/dashboard/dashboard-1/publish", bytes.NewBufferString(`{"version":2}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = gin.Params{{Key: "id", Value: "dashboard-1"}}
+	setTenantID(c, "tenant-1")
+	handler.Publish(c)
+	assert.Equal(t, http.StatusForbidden, w.Code, "viewers cannot publish")
+
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/dashboard/dashboard-1/publish", bytes.NewBufferString(`{"version":2}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = gin.Params{{Key: "id", Value: "dashboard-1"}}
+	setTenantID(c, "tenant-1")
+	c.Set(string(auth.RolesKey), []string{"admin"})
+	handler.Publish(c)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 2, service.published)
+
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/dashboard/dashboard-1/rollback", nil)
+	c.Params = gin.Params{{Key: "id", Value: "dashboard-1"}}
+	setTenantID(c, "tenant-1")
+	c.Set(string(auth.RolesKey), []string{"user"})
+	handler.Rollback(c)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
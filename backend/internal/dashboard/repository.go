@@ -13,6 +13,9 @@ type Repository interface {
 	Delete(id, tenantID string) error
 	Get(id, tenantID string) (*models.Dashboard, error)
 	List(tenantID string) ([]*models.Dashboard, error)
+	// Transaction runs fn with the repository bound to one transaction; tx
+	// lets fn write other tables in it.
+	Transaction(fn func(repo Repository, tx *gorm.DB) error) error
 }
 
 type repository struct {
@@ -62,3 +65,9 @@ func (r *repository) List(tenantID string) ([]*models.Dashboard, error) {
 	}
 	return dashboards, nil
 }
+
+func (r *repository) Transaction(fn func(repo Repository, tx *gorm.DB) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&repository{db: tx}, tx)
+	})
+}
@@ -6,7 +6,16 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/gujiaweiguo/goreport/internal/dataset"
 	"github.com/gujiaweiguo/goreport/internal/models"
+	"github.com/gujiaweiguo/goreport/internal/version"
+)
+
+var (
+	ErrNotFound = errors.New("dashboard not found")
+	// ErrPublishRequired rejects a save that sets the published status,
+	// which only Publish may do since it records the version viewers see.
+	ErrPublishRequired = errors.New("dashboards are published through POST /dashboards/:id/publish")
 )
 
 type Service interface {
@@ -15,14 +24,30 @@ type Service interface {
 	Delete(ctx context.Context, id, tenantID string) error
 	Get(ctx context.Context, id, tenantID string) (*models.Dashboard, error)
 	List(ctx context.Context, tenantID string) ([]*models.Dashboard, error)
+	Data(ctx context.Context, id, tenantID string, state *FilterState) (*DataResponse, error)
+	FilterOptions(ctx context.Context, id, tenantID, filterID string) ([]interface{}, error)
+	DrillThrough(ctx context.Context, id, tenantID, componentID string, row map[string]interface{}) (*dataset.DrillThroughTarget, error)
+	Versions(ctx context.Context, id, tenantID string) ([]*version.Version, error)
+	Version(ctx context.Context, id, tenantID string, number int) (*version.Version, error)
+	Diff(ctx context.Context, id, tenantID string, from, to int) (*VersionDiff, error)
+	Publish(ctx context.Context, id, tenantID string, number int, userID string) (*models.Dashboard, error)
+	Rollback(ctx context.Context, id, tenantID string, number int, userID string) (*models.Dashboard, error)
 }
 
 type service struct {
-	repo Repository
+	repo          Repository
+	queryExecutor dataset.QueryExecutor
+	history       *version.History
+	concurrency   int
 }
 
-func NewService(repo Repository) Service {
-	return &service{repo: repo}
+func NewService(repo Repository, queryExecutor dataset.QueryExecutor, versions version.Repository) Service {
+	return &service{
+		repo:          repo,
+		queryExecutor: queryExecutor,
+		history:       version.NewHistory(versions, version.ResourceDashboard),
+		concurrency:   DataConcurrency,
+	}
 }
 
 type CreateRequest struct {
@@ -44,6 +69,7 @@ type UpdateRequest struct {
 	Components  []models.DashboardComponent `json:"components"`
 	Status      int                         `json:"status"`
 	TenantID    string                      `json:"-"`
+	UpdatedBy   string                      `json:"-"`
 }
 
 func (s *service) Create(ctx context.Context, req *CreateRequest) (*models.Dashboard, error) {
@@ -62,6 +88,10 @@ func (s *service) Create(ctx context.Context, req *CreateRequest) (*models.Dashb
 		config.BackgroundColor = "#0a0e27"
 	}
 
+	if err := validateFilters(config, req.Components); err != nil {
+		return nil, err
+	}
+
 	dashboard := &models.Dashboard{
 		ID:         fmt.Sprintf("dashboard-%d", time.Now().UnixNano()),
 		TenantID:   req.TenantID,
@@ -69,13 +99,19 @@ func (s *service) Create(ctx context.Context, req *CreateRequest) (*models.Dashb
 		Code:       req.Code,
 		Config:     config,
 		Components: req.Components,
-		Status:     1,
+		Status:     version.StatusDraft,
 		CreatedBy:  req.CreatedBy,
 		CreatedAt:  time.Now(),
 		UpdatedAt:  time.Now(),
 	}
 
-	if err := s.repo.Create(dashboard); err != nil {
+	if err := s.inTx(func(repo Repository, history *version.History) error {
+		if err := repo.Create(dashboard); err != nil {
+			return err
+		}
+		_, err := history.Record(ctx, dashboard.TenantID, dashboard.ID, req.CreatedBy, "", snapshotOf(dashboard))
+		return err
+	}); err != nil {
 		return nil, err
 	}
 
@@ -87,29 +123,44 @@ func (s *service) Update(ctx context.Context, req *UpdateRequest) (*models.Dashb
 		return nil, errors.New("id is required")
 	}
 
+	if req.Status == version.StatusPublished {
+		return nil, ErrPublishRequired
+	}
 	dashboard, err := s.repo.Get(req.ID, req.TenantID)
 	if err != nil {
 		return nil, err
 	}
+	if err := s.inTx(func(repo Repository, history *version.History) error {
+		if err := s.pinPublished(ctx, history, dashboard, req.UpdatedBy); err != nil {
+			return err
+		}
 
-	if req.Name != "" {
-		dashboard.Name = req.Name
-	}
-	if req.Code != "" {
-		dashboard.Code = req.Code
-	}
-	if req.Config.Width != 0 || req.Config.Height != 0 {
-		dashboard.Config = req.Config
-	}
-	if len(req.Components) > 0 {
-		dashboard.Components = req.Components
-	}
-	if req.Status != 0 {
-		dashboard.Status = req.Status
-	}
-	dashboard.UpdatedAt = time.Now()
+		if req.Name != "" {
+			dashboard.Name = req.Name
+		}
+		if req.Code != "" {
+			dashboard.Code = req.Code
+		}
+		if req.Config.Width != 0 || req.Config.Height != 0 {
+			dashboard.Config = req.Config
+		}
+		if len(req.Components) > 0 {
+			dashboard.Components = req.Components
+		}
+		if req.Status != 0 {
+			dashboard.Status = req.Status
+		}
+		if err := validateFilters(dashboard.Config, dashboard.Components); err != nil {
+			return err
+		}
+		dashboard.UpdatedAt = time.Now()
 
-	if err := s.repo.Update(dashboard); err != nil {
+		if err := repo.Update(dashboard); err != nil {
+			return err
+		}
+		_, err := history.Record(ctx, dashboard.TenantID, dashboard.ID, req.UpdatedBy, "", snapshotOf(dashboard))
+		return err
+	}); err != nil {
 		return nil, err
 	}
 
@@ -120,10 +171,115 @@ func (s *service) Delete(ctx context.Context, id, tenantID string) error {
 	return s.repo.Delete(id, tenantID)
 }
 
+// Get returns the draft of a dashboard, or to a viewer its published
+// version.
 func (s *service) Get(ctx context.Context, id, tenantID string) (*models.Dashboard, error) {
-	return s.repo.Get(id, tenantID)
+	return s.load(ctx, id, tenantID)
 }
 
+// List returns the dashboards of a tenant; a viewer sees the published ones.
 func (s *service) List(ctx context.Context, tenantID string) ([]*models.Dashboard, error) {
-	return s.repo.List(tenantID)
+	dashboards, err := s.repo.List(tenantID)
+	if err != nil || !version.Published(ctx) {
+		return dashboards, err
+	}
+	published := make([]*models.Dashboard, 0, len(dashboards))
+	for _, dashboard := range dashboards {
+		if dashboard.Status == version.StatusPublished {
+			published = append(published, dashboard)
+		}
+	}
+	return published, nil
+}
+
+// Data resolves the dataset binding of every visible component in one pass,
+// so the dashboard loads in a single round trip. The filter state is applied
+// server-side, so the designer, shared links and embeds filter alike.
+func (s *service) Data(ctx context.Context, id, tenantID string, state *FilterState) (*DataResponse, error) {
+	dashboard, err := s.load(ctx, id, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	active, err := activeFilters(dashboard, state)
+	if err != nil {
+		return nil, err
+	}
+	var drills map[string]*dataset.DrillState
+	if state != nil {
+		drills = state.Drills
+	}
+	return &DataResponse{
+		DashboardID: dashboard.ID,
+		Components:  resolveComponents(ctx, s.queryExecutor, tenantID, dashboard.Components, active, drills, s.concurrency),
+	}, nil
+}
+
+// FilterOptions lists the distinct values of the dataset field a dropdown
+// filter draws its options from.
+func (s *service) FilterOptions(ctx context.Context, id, tenantID, filterID string) ([]interface{}, error) {
+	dashboard, err := s.load(ctx, id, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	var source *models.FilterOptionSource
+	for _, filter := range dashboard.Config.Filters {
+		if filter.ID == filterID {
+			source = filter.Options
+			break
+		}
+	}
+	if source == nil {
+		return nil, ErrFilterNotFound
+	}
+
+	records, err := dataset.QueryRecords(ctx, s.queryExecutor, tenantID, &dataset.QueryRequest{
+		DatasetID: source.DatasetID,
+		Fields:    []string{source.Field},
+		GroupBy:   []string{source.Field},
+		SortBy:    source.Field,
+		SortOrder: "asc",
+	})
+	if err != nil {
+		return nil, err
+	}
+	options := make([]interface{}, 0, len(records))
+	for _, record := range records {
+		if len(options) == maxFilterOptions {
+			break
+		}
+		value := record[source.Field]
+		if raw, ok := value.([]byte); ok {
+			value = string(raw)
+		}
+		if value != nil {
+			options = append(options, value)
+		}
+	}
+	return options, nil
+}
+
+// DrillThrough resolves the drill-through of a component for a clicked row.
+func (s *service) DrillThrough(ctx context.Context, id, tenantID, componentID string, row map[string]interface{}) (*dataset.DrillThroughTarget, error) {
+	dashboard, err := s.load(ctx, id, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	for _, component := range dashboard.Components {
+		if component.ID != componentID {
+			continue
+		}
+		drillThrough, err := componentDrillThrough(component)
+		if err != nil {
+			return nil, err
+		}
+		if drillThrough == nil {
+			return nil, fmt.Errorf("%w: component %q has no drill-through", ErrInvalidFilter, componentID)
+		}
+		target, err := drillThrough.Resolve(row)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidFilter, err)
+		}
+		return target, nil
+	}
+	return nil, fmt.Errorf("%w: unknown component %q", ErrInvalidFilter, componentID)
 }
@@ -6,7 +6,9 @@ import (
 	"testing"
 
 	"github.com/gujiaweiguo/goreport/internal/models"
+	"github.com/gujiaweiguo/goreport/internal/version"
 	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
 )
 
 type mockDashboardRepo struct {
@@ -17,9 +19,15 @@ type mockDashboardRepo struct {
 	deleteErr  error
 	getErr     error
 	listErr    error
+	inTx       bool
+	// untracked counts writes made outside a transaction.
+	untracked int
 }
 
 func (m *mockDashboardRepo) Create(dashboard *models.Dashboard) error {
+	if !m.inTx {
+		m.untracked++
+	}
 	if m.createErr != nil {
 		return m.createErr
 	}
@@ -28,6 +36,9 @@ func (m *mockDashboardRepo) Create(dashboard *models.Dashboard) error {
 }
 
 func (m *mockDashboardRepo) Update(dashboard *models.Dashboard) error {
+	if !m.inTx {
+		m.untracked++
+	}
 	if m.updateErr != nil {
 		return m.updateErr
 	}
@@ -48,7 +59,9 @@ func (m *mockDashboardRepo) Get(id, tenantID string) (*models.Dashboard, error)
 		return nil, m.getErr
 	}
 	if m.dashboard != nil && m.dashboard.ID == id {
-		return m.dashboard, nil
+		// Hand out a copy, as the database does.
+		dashboard := *m.dashboard
+		return &dashboard, nil
 	}
 	return nil, errors.New("dashboard not found")
 }
@@ -60,14 +73,25 @@ func (m *mockDashboardRepo) List(tenantID string) ([]*models.Dashboard, error) {
 	return m.dashboards, nil
 }
 
+func (m *mockDashboardRepo) Transaction(fn func(repo Repository, tx *gorm.DB) error) error {
+	saved := m.dashboard
+	m.inTx = true
+	err := fn(m, nil)
+	m.inTx = false
+	if err != nil {
+		m.dashboard = saved
+	}
+	return err
+}
+
 func TestNewService(t *testing.T) {
-	service := NewService(nil)
+	service := NewService(nil, nil, nil)
 	assert.NotNil(t, service)
 }
 
 func TestService_Create(t *testing.T) {
 	repo := &mockDashboardRepo{}
-	service := NewService(repo)
+	service := NewService(repo, nil, nil)
 
 	t.Run("成功创建仪表盘", func(t *testing.T) {
 		req := &CreateRequest{
@@ -137,7 +161,7 @@ func TestService_Update(t *testing.T) {
 	repo := &mockDashboardRepo{
 		dashboard: existingDashboard,
 	}
-	service := NewService(repo)
+	service := NewService(repo, nil, nil)
 
 	t.Run("成功更新仪表盘", func(t *testing.T) {
 		newName := "Updated Name"
@@ -145,7 +169,7 @@ func TestService_Update(t *testing.T) {
 			ID:       "dashboard-1",
 			Name:     newName,
 			TenantID: "tenant-1",
-			Status:   1,
+			Status:   version.StatusDisabled,
 		}
 
 		dashboard, err := service.Update(context.Background(), req)
@@ -153,7 +177,7 @@ func TestService_Update(t *testing.T) {
 		assert.NoError(t, err)
 		assert.NotNil(t, dashboard)
 		assert.Equal(t, newName, dashboard.Name)
-		assert.Equal(t, 1, dashboard.Status)
+		assert.Equal(t, version.StatusDisabled, dashboard.Status)
 	})
 
 	t.Run("更新配置", func(t *testing.T) {
@@ -228,7 +252,7 @@ func TestService_Delete(t *testing.T) {
 	repo := &mockDashboardRepo{
 		dashboard: existingDashboard,
 	}
-	service := NewService(repo)
+	service := NewService(repo, nil, nil)
 
 	t.Run("成功删除仪表盘", func(t *testing.T) {
 		err := service.Delete(context.Background(), "dashboard-1", "tenant-1")
@@ -256,7 +280,7 @@ func TestService_Get(t *testing.T) {
 	repo := &mockDashboardRepo{
 		dashboard: existingDashboard,
 	}
-	service := NewService(repo)
+	service := NewService(repo, nil, nil)
 
 	t.Run("成功获取仪表盘", func(t *testing.T) {
 		dashboard, err := service.Get(context.Background(), "dashboard-1", "tenant-1")
@@ -294,7 +318,7 @@ func TestService_List(t *testing.T) {
 	repo := &mockDashboardRepo{
 		dashboards: dashboards,
 	}
-	service := NewService(repo)
+	service := NewService(repo, nil, nil)
 
 	t.Run("成功获取仪表盘列表", func(t *testing.T) {
 		list, err := service.List(context.Background(), "tenant-1")
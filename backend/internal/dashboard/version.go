@@ -0,0 +1,298 @@
+package dashboard
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/gujiaweiguo/goreport/internal/models"
+	"github.com/gujiaweiguo/goreport/internal/version"
+	"gorm.io/gorm"
+)
+
+const (
+	ComponentAdded              = "added"
+	ComponentRemoved            = "removed"
+	ComponentMoved              = "moved"
+	ComponentResized            = "resized"
+	ComponentStyleChanged       = "style"
+	ComponentDataChanged        = "data"
+	ComponentInteractionChanged = "interaction"
+	ComponentPropertiesChanged  = "properties"
+)
+
+// Snapshot is what a dashboard version records.
+type Snapshot struct {
+	Name       string                      `json:"name"`
+	Config     models.DashboardConfig      `json:"config"`
+	Components []models.DashboardComponent `json:"components"`
+}
+
+func snapshotOf(dashboard *models.Dashboard) *Snapshot {
+	return &Snapshot{Name: dashboard.Name, Config: dashboard.Config, Components: dashboard.Components}
+}
+
+func (s *Snapshot) apply(dashboard *models.Dashboard) {
+	dashboard.Name = s.Name
+	dashboard.Config = s.Config
+	dashboard.Components = s.Components
+}
+
+// ComponentChange is one way a component differs between two versions.
+// Changes details a style, data or interaction change key by key.
+type ComponentChange struct {
+	ComponentID string           `json:"componentId"`
+	Title       string           `json:"title"`
+	Kind        string           `json:"kind"`
+	Changes     []version.Change `json:"changes,omitempty"`
+}
+
+// VersionDiff compares two dashboard versions: Changes covers the name and
+// canvas config, Components the components added, removed, moved, resized
+// or restyled. Version 0 is the current draft.
+type VersionDiff struct {
+	From       int               `json:"from"`
+	To         int               `json:"to"`
+	Changes    []version.Change  `json:"changes"`
+	Components []ComponentChange `json:"components"`
+}
+
+func diffSnapshots(from, to *Snapshot) *VersionDiff {
+	diff := &VersionDiff{Changes: []version.Change{}, Components: []ComponentChange{}}
+	if from.Name != to.Name {
+		diff.Changes = append(diff.Changes, version.Change{Path: "name", Kind: version.ChangeModified, From: from.Name, To: to.Name})
+	}
+	diff.Changes = append(diff.Changes, version.DiffValues("config", decoded(from.Config), decoded(to.Config))...)
+
+	before := make(map[string]models.DashboardComponent, len(from.Components))
+	for _, component := range from.Components {
+		before[component.ID] = component
+	}
+	after := make(map[string]bool, len(to.Components))
+	for _, component := range to.Components {
+		after[component.ID] = true
+		old, ok := before[component.ID]
+		if !ok {
+			diff.Components = append(diff.Components, ComponentChange{ComponentID: component.ID, Title: component.Title, Kind: ComponentAdded})
+			continue
+		}
+		diff.Components = append(diff.Components, componentChanges(old, component)...)
+	}
+	for _, component := range from.Components {
+		if !after[component.ID] {
+			diff.Components = append(diff.Components, ComponentChange{ComponentID: component.ID, Title: component.Title, Kind: ComponentRemoved})
+		}
+	}
+	return diff
+}
+
+func componentChanges(from, to models.DashboardComponent) []ComponentChange {
+	var changes []ComponentChange
+	add := func(kind string, details []version.Change) {
+		changes = append(changes, ComponentChange{ComponentID: to.ID, Title: to.Title, Kind: kind, Changes: details})
+	}
+	if from.X != to.X || from.Y != to.Y {
+		add(ComponentMoved, []version.Change{
+			{Path: "x", Kind: version.ChangeModified, From: from.X, To: to.X},
+			{Path: "y", Kind: version.ChangeModified, From: from.Y, To: to.Y},
+		})
+	}
+	if from.Width != to.Width || from.Height != to.Height {
+		add(ComponentResized, []version.Change{
+			{Path: "width", Kind: version.ChangeModified, From: from.Width, To: to.Width},
+			{Path: "height", Kind: version.ChangeModified, From: from.Height, To: to.Height},
+		})
+	}
+	for _, part := range []struct {
+		kind     string
+		from, to map[string]interface{}
+	}{
+		{ComponentStyleChanged, from.Style, to.Style},
+		{ComponentDataChanged, from.Data, to.Data},
+		{ComponentInteractionChanged, from.Interaction, to.Interaction},
+	} {
+		if details := version.DiffValues("", decoded(part.from), decoded(part.to)); len(details) > 0 {
+			add(part.kind, details)
+		}
+	}
+	if from.Title != to.Title || from.Type != to.Type || from.Visible != to.Visible || from.Locked != to.Locked {
+		add(ComponentPropertiesChanged, version.DiffValues("", decoded(properties(from)), decoded(properties(to))))
+	}
+	return changes
+}
+
+func properties(component models.DashboardComponent) map[string]interface{} {
+	return map[string]interface{}{"title": component.Title, "type": component.Type, "visible": component.Visible, "locked": component.Locked}
+}
+
+// decoded round-trips value through JSON so typed and decoded values
+// compare alike.
+func decoded(value interface{}) interface{} {
+	if v := reflect.ValueOf(value); value == nil || (v.Kind() == reflect.Map && v.IsNil()) {
+		return map[string]interface{}{}
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil
+	}
+	var result interface{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil
+	}
+	return result
+}
+
+// load reads a dashboard as the caller may see it: the draft, or for a
+// viewer the published version.
+func (s *service) load(ctx context.Context, id, tenantID string) (*models.Dashboard, error) {
+	dashboard, err := s.repo.Get(id, tenantID)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	if !version.Published(ctx) {
+		return dashboard, nil
+	}
+	if dashboard.Status != version.StatusPublished {
+		return nil, ErrNotFound
+	}
+	if dashboard.PublishedVersion == 0 {
+		return dashboard, nil
+	}
+	var snapshot Snapshot
+	if _, err := s.history.Load(ctx, tenantID, id, dashboard.PublishedVersion, &snapshot); err != nil {
+		return nil, err
+	}
+	snapshot.apply(dashboard)
+	return dashboard, nil
+}
+
+// inTx runs fn with the repository and history bound to one transaction,
+// so a dashboard and the versions it records are saved together.
+func (s *service) inTx(fn func(repo Repository, history *version.History) error) error {
+	return s.repo.Transaction(func(repo Repository, tx *gorm.DB) error {
+		return fn(repo, s.history.WithTx(tx))
+	})
+}
+
+// pinPublished keeps what viewers of a dashboard published before
+// versioning see when its draft first changes.
+func (s *service) pinPublished(ctx context.Context, history *version.History, dashboard *models.Dashboard, userID string) error {
+	if dashboard.Status != version.StatusPublished || dashboard.PublishedVersion != 0 {
+		return nil
+	}
+	recorded, err := history.Record(ctx, dashboard.TenantID, dashboard.ID, userID, "published", snapshotOf(dashboard))
+	if err != nil || recorded == nil {
+		return err
+	}
+	dashboard.PublishedVersion = recorded.Number
+	return nil
+}
+
+func (s *service) Versions(ctx context.Context, id, tenantID string) ([]*version.Version, error) {
+	if _, err := s.repo.Get(id, tenantID); err != nil {
+		return nil, ErrNotFound
+	}
+	return s.history.List(ctx, tenantID, id)
+}
+
+func (s *service) Version(ctx context.Context, id, tenantID string, number int) (*version.Version, error) {
+	if _, err := s.repo.Get(id, tenantID); err != nil {
+		return nil, ErrNotFound
+	}
+	return s.history.Get(ctx, tenantID, id, number)
+}
+
+// Diff compares two versions of a dashboard; version 0 is the current
+// draft.
+func (s *service) Diff(ctx context.Context, id, tenantID string, from, to int) (*VersionDiff, error) {
+	dashboard, err := s.repo.Get(id, tenantID)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	snapshotAt := func(number int) (*Snapshot, error) {
+		if number == 0 {
+			return snapshotOf(dashboard), nil
+		}
+		var snapshot Snapshot
+		if _, err := s.history.Load(ctx, tenantID, id, number, &snapshot); err != nil {
+			return nil, err
+		}
+		return &snapshot, nil
+	}
+	before, err := snapshotAt(from)
+	if err != nil {
+		return nil, err
+	}
+	after, err := snapshotAt(to)
+	if err != nil {
+		return nil, err
+	}
+	diff := diffSnapshots(before, after)
+	diff.From, diff.To = from, to
+	return diff, nil
+}
+
+// Publish pins a version for viewers; version 0 publishes the latest save.
+func (s *service) Publish(ctx context.Context, id, tenantID string, number int, userID string) (*models.Dashboard, error) {
+	dashboard, err := s.repo.Get(id, tenantID)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	if err := s.inTx(func(repo Repository, history *version.History) error {
+		published, err := history.Get(ctx, tenantID, id, number)
+		if errors.Is(err, version.ErrNotFound) && number == 0 {
+			// Saved before versioning: publish the draft as its first version.
+			published, err = history.Record(ctx, tenantID, id, userID, "published", snapshotOf(dashboard))
+			if err == nil && published == nil {
+				err = version.ErrNotFound
+			}
+		}
+		if err != nil {
+			return err
+		}
+		dashboard.PublishedVersion = published.Number
+		dashboard.Status = version.StatusPublished
+		dashboard.UpdatedAt = time.Now()
+		return repo.Update(dashboard)
+	}); err != nil {
+		return nil, err
+	}
+	return dashboard, nil
+}
+
+// Rollback restores a version into the draft and records it as a new
+// version; viewers keep the published one until it is published again.
+func (s *service) Rollback(ctx context.Context, id, tenantID string, number int, userID string) (*models.Dashboard, error) {
+	dashboard, err := s.repo.Get(id, tenantID)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	if number <= 0 {
+		return nil, version.ErrNotFound
+	}
+	var snapshot Snapshot
+	if _, err := s.history.Load(ctx, tenantID, id, number, &snapshot); err != nil {
+		return nil, err
+	}
+	if err := s.inTx(func(repo Repository, history *version.History) error {
+		if err := s.pinPublished(ctx, history, dashboard, userID); err != nil {
+			return err
+		}
+		snapshot.apply(dashboard)
+		if err := validateFilters(dashboard.Config, dashboard.Components); err != nil {
+			return err
+		}
+		dashboard.UpdatedAt = time.Now()
+		if err := repo.Update(dashboard); err != nil {
+			return err
+		}
+		_, err := history.Record(ctx, tenantID, id, userID, fmt.Sprintf("rollback to version %d", number), snapshotOf(dashboard))
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return dashboard, nil
+}
@@ -0,0 +1,188 @@
+package dashboard
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/gujiaweiguo/goreport/internal/models"
+	"github.com/gujiaweiguo/goreport/internal/testutil"
+	"github.com/gujiaweiguo/goreport/internal/version"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func versionedComponents() []models.DashboardComponent {
+	return []models.DashboardComponent{
+		{ID: "c1", Title: "Sales", Type: "bar", X: 0, Y: 0, Width: 400, Height: 300, Visible: true, Style: map[string]interface{}{"color": "#fff"}},
+		{ID: "c2", Title: "Orders", Type: "table", X: 400, Y: 0, Width: 400, Height: 300, Visible: true},
+	}
+}
+
+func TestService_VersionsAndPublish(t *testing.T) {
+	repo := &mockDashboardRepo{}
+	versions := testutil.NewVersionRepository()
+	svc := NewService(repo, nil, versions)
+	ctx := context.Background()
+	viewer := version.WithPublished(ctx)
+
+	created, err := svc.Create(ctx, &CreateRequest{Name: "Sales", TenantID: "tenant-1", CreatedBy: "user-1", Components: versionedComponents()})
+	require.NoError(t, err)
+	assert.Equal(t, version.StatusDraft, created.Status)
+	_, err = svc.Get(viewer, created.ID, "tenant-1")
+	assert.ErrorIs(t, err, ErrNotFound, "viewers cannot see a draft")
+
+	published, err := svc.Publish(ctx, created.ID, "tenant-1", 0, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, version.StatusPublished, published.Status)
+	assert.Equal(t, 1, published.PublishedVersion)
+
+	components := versionedComponents()
+	components[0].X = 100
+	_, err = svc.Update(ctx, &UpdateRequest{ID: created.ID, Name: "Sales v2", TenantID: "tenant-1", UpdatedBy: "user-2", Components: components})
+	require.NoError(t, err)
+
+	history, err := svc.Versions(ctx, created.ID, "tenant-1")
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, 2, history[0].Number)
+	assert.Equal(t, "user-2", history[0].CreatedBy)
+	assert.Nil(t, history[0].Snapshot, "the list leaves snapshots out")
+
+	seen, err := svc.Get(viewer, created.ID, "tenant-1")
+	require.NoError(t, err)
+	assert.Equal(t, "Sales", seen.Name, "viewers keep the published version")
+	assert.Equal(t, 0, seen.Components[0].X)
+
+	draft, err := svc.Get(ctx, created.ID, "tenant-1")
+	require.NoError(t, err)
+	assert.Equal(t, "Sales v2", draft.Name)
+
+	_, err = svc.Publish(ctx, created.ID, "tenant-1", 9, "user-1")
+	assert.ErrorIs(t, err, version.ErrNotFound)
+}
+
+func TestService_Update_RejectsPublishedStatus(t *testing.T) {
+	repo := &mockDashboardRepo{}
+	svc := NewService(repo, nil, testutil.NewVersionRepository())
+	ctx := context.Background()
+
+	created, err := svc.Create(ctx, &CreateRequest{Name: "Sales", TenantID: "tenant-1", Components: versionedComponents()})
+	require.NoError(t, err)
+
+	_, err = svc.Update(ctx, &UpdateRequest{ID: created.ID, Name: "Sales v2", TenantID: "tenant-1", Status: version.StatusPublished})
+	assert.ErrorIs(t, err, ErrPublishRequired)
+
+	draft, err := svc.Get(ctx, created.ID, "tenant-1")
+	require.NoError(t, err)
+	assert.Equal(t, "Sales", draft.Name, "the rejected save changes nothing")
+	assert.Equal(t, version.StatusDraft, draft.Status)
+	_, err = svc.Get(version.WithPublished(ctx), created.ID, "tenant-1")
+	assert.ErrorIs(t, err, ErrNotFound, "viewers still cannot see the draft")
+}
+
+func TestService_Update_PinsLegacyPublished(t *testing.T) {
+	repo := &mockDashboardRepo{dashboard: &models.Dashboard{
+		ID: "dashboard-1", TenantID: "tenant-1", Name: "Legacy", Status: version.StatusPublished,
+		Config: models.DashboardConfig{Width: 1920, Height: 1080}, Components: versionedComponents(),
+	}}
+	versions := testutil.NewVersionRepository()
+	svc := NewService(repo, nil, versions)
+
+	seen, err := svc.Get(version.WithPublished(context.Background()), "dashboard-1", "tenant-1")
+	require.NoError(t, err)
+	assert.Equal(t, "Legacy", seen.Name, "published before versioning")
+
+	updated, err := svc.Update(context.Background(), &UpdateRequest{ID: "dashboard-1", Name: "Renamed", TenantID: "tenant-1"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, updated.PublishedVersion, "the pre-edit state is pinned for viewers")
+	assert.Len(t, versions.Versions, 2)
+
+	var snapshot Snapshot
+	_, err = version.NewHistory(versions, version.ResourceDashboard).Load(context.Background(), "tenant-1", "dashboard-1", 1, &snapshot)
+	require.NoError(t, err)
+	assert.Equal(t, "Legacy", snapshot.Name)
+}
+
+func TestService_DiffAndRollback(t *testing.T) {
+	repo := &mockDashboardRepo{}
+	svc := NewService(repo, nil, testutil.NewVersionRepository())
+	ctx := context.Background()
+
+	created, err := svc.Create(ctx, &CreateRequest{Name: "Sales", TenantID: "tenant-1", Components: versionedComponents()})
+	require.NoError(t, err)
+
+	components := versionedComponents()
+	components[0].X, components[0].Y = 50, 60
+	components[0].Style = map[string]interface{}{"color": "#000", "border": "1px"}
+	components = append(components[:1], models.DashboardComponent{ID: "c3", Title: "Map", Type: "map", Visible: true})
+	_, err = svc.Update(ctx, &UpdateRequest{ID: created.ID, TenantID: "tenant-1", Config: models.DashboardConfig{Width: 1280, Height: 720}, Components: components})
+	require.NoError(t, err)
+
+	diff, err := svc.Diff(ctx, created.ID, "tenant-1", 1, 2)
+	require.NoError(t, err)
+	assert.Equal(t, 1, diff.From)
+	assert.Contains(t, diff.Changes, version.Change{Path: "config.width", Kind: version.ChangeModified, From: 1920.0, To: 1280.0})
+	kinds := map[string]string{}
+	for _, change := range diff.Components {
+		kinds[change.ComponentID+":"+change.Kind] = change.Title
+	}
+	assert.Equal(t, map[string]string{
+		"c1:" + ComponentMoved:        "Sales",
+		"c1:" + ComponentStyleChanged: "Sales",
+		"c3:" + ComponentAdded:        "Map",
+		"c2:" + ComponentRemoved:      "Orders",
+	}, kinds)
+	for _, change := range diff.Components {
+		if change.Kind == ComponentStyleChanged {
+			assert.Equal(t, []version.Change{
+				{Path: "border", Kind: version.ChangeAdded, To: "1px"},
+				{Path: "color", Kind: version.ChangeModified, From: "#fff", To: "#000"},
+			}, change.Changes)
+		}
+	}
+
+	draftDiff, err := svc.Diff(ctx, created.ID, "tenant-1", 2, 0)
+	require.NoError(t, err)
+	assert.Empty(t, draftDiff.Changes)
+	assert.Empty(t, draftDiff.Components, "version 2 is the current draft")
+
+	restored, err := svc.Rollback(ctx, created.ID, "tenant-1", 1, "user-1")
+	require.NoError(t, err)
+	assert.Len(t, restored.Components, 2)
+	assert.Equal(t, 1920, restored.Config.Width)
+	history, err := svc.Versions(ctx, created.ID, "tenant-1")
+	require.NoError(t, err)
+	require.Len(t, history, 3)
+	assert.Equal(t, "rollback to version 1", history[0].Comment)
+
+	_, err = svc.Rollback(ctx, created.ID, "tenant-1", 7, "user-1")
+	assert.ErrorIs(t, err, version.ErrNotFound)
+	_, err = svc.Diff(ctx, "missing", "tenant-1", 1, 2)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestService_VersionsSavedWithDashboard(t *testing.T) {
+	repo := &mockDashboardRepo{}
+	versions := testutil.NewVersionRepository()
+	svc := NewService(repo, nil, versions)
+	ctx := context.Background()
+
+	created, err := svc.Create(ctx, &CreateRequest{Name: "Sales", TenantID: "tenant-1", Components: versionedComponents()})
+	require.NoError(t, err)
+	_, err = svc.Publish(ctx, created.ID, "tenant-1", 0, "user-1")
+	require.NoError(t, err)
+	_, err = svc.Update(ctx, &UpdateRequest{ID: created.ID, Name: "Sales v2", TenantID: "tenant-1"})
+	require.NoError(t, err)
+	_, err = svc.Rollback(ctx, created.ID, "tenant-1", 1, "user-1")
+	require.NoError(t, err)
+	assert.Zero(t, repo.untracked, "every write shares a transaction with its version")
+
+	// A failed version insert rolls the dashboard back with it.
+	versions.CreateErr = errors.New("duplicate version")
+	_, err = svc.Update(ctx, &UpdateRequest{ID: created.ID, Name: "Sales v3", TenantID: "tenant-1"})
+	assert.Error(t, err)
+	current, err := svc.Get(ctx, created.ID, "tenant-1")
+	require.NoError(t, err)
+	assert.Equal(t, "Sales", current.Name)
+}
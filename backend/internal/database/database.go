@@ -3,6 +3,7 @@ package database
 import (
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/gujiaweiguo/goreport/internal/config"
@@ -67,9 +68,9 @@ func ensureDatasourceSchemaCompatibility(db *gorm.DB) error {
 		{name: "ssh_host", ddl: "ALTER TABLE data_sources ADD COLUMN ssh_host VARCHAR(255) DEFAULT ''"},
 		{name: "ssh_port", ddl: "ALTER TABLE data_sources ADD COLUMN ssh_port INT DEFAULT 0"},
 		{name: "ssh_username", ddl: "ALTER TABLE data_sources ADD COLUMN ssh_username VARCHAR(100) DEFAULT ''"},
-		{name: "ssh_password", ddl: "ALTER TABLE data_sources ADD COLUMN ssh_password VARCHAR(255) DEFAULT ''"},
+		{name: "ssh_password", ddl: "ALTER TABLE data_sources ADD COLUMN ssh_password TEXT"},
 		{name: "ssh_key", ddl: "ALTER TABLE data_sources ADD COLUMN ssh_key TEXT"},
-		{name: "ssh_key_phrase", ddl: "ALTER TABLE data_sources ADD COLUMN ssh_key_phrase VARCHAR(255) DEFAULT ''"},
+		{name: "ssh_key_phrase", ddl: "ALTER TABLE data_sources ADD COLUMN ssh_key_phrase TEXT"},
 		{name: "max_connections", ddl: "ALTER TABLE data_sources ADD COLUMN max_connections INT DEFAULT 10"},
 		{name: "query_timeout_seconds", ddl: "ALTER TABLE data_sources ADD COLUMN query_timeout_seconds INT DEFAULT 30"},
 		{name: "config", ddl: "ALTER TABLE data_sources ADD COLUMN config TEXT"},
@@ -89,6 +90,30 @@ func ensureDatasourceSchemaCompatibility(db *gorm.DB) error {
 		}
 	}
 
+	// Encrypted secrets outgrow VARCHAR(255), so older string columns are widened.
+	secretColumns := []struct {
+		name string
+		ddl  string
+	}{
+		{name: "password", ddl: "ALTER TABLE data_sources MODIFY password TEXT NOT NULL"},
+		{name: "ssh_password", ddl: "ALTER TABLE data_sources MODIFY ssh_password TEXT"},
+		{name: "ssh_key", ddl: "ALTER TABLE data_sources MODIFY ssh_key TEXT"},
+		{name: "ssh_key_phrase", ddl: "ALTER TABLE data_sources MODIFY ssh_key_phrase TEXT"},
+	}
+
+	for _, col := range secretColumns {
+		var dataType string
+		err := db.Raw("SELECT DATA_TYPE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'data_sources' AND COLUMN_NAME = ?", col.name).Scan(&dataType).Error
+		if err != nil {
+			return fmt.Errorf("failed to check datasource column %s: %w", col.name, err)
+		}
+		if strings.EqualFold(dataType, "varchar") || strings.EqualFold(dataType, "char") {
+			if err := db.Exec(col.ddl).Error; err != nil {
+				return fmt.Errorf("failed to widen datasource column %s: %w", col.name, err)
+			}
+		}
+	}
+
 	updates := []string{
 		"UPDATE data_sources SET id = CONCAT('ds-', REPLACE(UUID(), '-', '')) WHERE id IS NULL OR id = ''",
 		"UPDATE data_sources SET `database` = database_name WHERE (`database` IS NULL OR `database` = '') AND database_name IS NOT NULL",
@@ -1,9 +1,7 @@
 package dataset
 
 import (
-	"fmt"
-	"regexp"
-	"strings"
+	"sync"
 )
 
 type APIExpressionBuilder interface {
@@ -13,148 +11,12 @@ type APIExpressionBuilder interface {
 }
 
 type apiExpressionBuilder struct {
-	functionMap map[string]func(...interface{}) interface{}
+	// parsed caches expression trees, since Evaluate runs once per row.
+	parsed sync.Map
 }
 
 func NewAPIExpressionBuilder() APIExpressionBuilder {
-	return &apiExpressionBuilder{
-		functionMap: map[string]func(...interface{}) interface{}{
-			"CONCAT": func(args ...interface{}) interface{} {
-				var result strings.Builder
-				for _, arg := range args {
-					result.WriteString(fmt.Sprintf("%v", arg))
-				}
-				return result.String()
-			},
-			"SUBSTRING": func(args ...interface{}) interface{} {
-				if len(args) < 2 {
-					return nil
-				}
-				str := fmt.Sprintf("%v", args[0])
-				start := 0
-				if len(args) >= 2 {
-					if s, ok := args[1].(int); ok {
-						start = s
-					}
-				}
-				if start < 0 || start >= len(str) {
-					return ""
-				}
-				if len(args) >= 3 {
-					length := 0
-					if l, ok := args[2].(int); ok {
-						length = l
-					}
-					end := start + length
-					if end > len(str) {
-						end = len(str)
-					}
-					return str[start:end]
-				}
-				return str[start:]
-			},
-			"LENGTH": func(args ...interface{}) interface{} {
-				if len(args) == 0 {
-					return 0
-				}
-				return len(fmt.Sprintf("%v", args[0]))
-			},
-			"UPPER": func(args ...interface{}) interface{} {
-				if len(args) == 0 {
-					return ""
-				}
-				return strings.ToUpper(fmt.Sprintf("%v", args[0]))
-			},
-			"LOWER": func(args ...interface{}) interface{} {
-				if len(args) == 0 {
-					return ""
-				}
-				return strings.ToLower(fmt.Sprintf("%v", args[0]))
-			},
-			"TRIM": func(args ...interface{}) interface{} {
-				if len(args) == 0 {
-					return ""
-				}
-				return strings.TrimSpace(fmt.Sprintf("%v", args[0]))
-			},
-			"ROUND": func(args ...interface{}) interface{} {
-				if len(args) == 0 {
-					return 0
-				}
-				return args[0]
-			},
-			"CEIL": func(args ...interface{}) interface{} {
-				if len(args) == 0 {
-					return 0
-				}
-				if val, ok := args[0].(int); ok {
-					if val >= 0 {
-						return val
-					}
-					return val - 1
-				}
-				return args[0]
-			},
-			"FLOOR": func(args ...interface{}) interface{} {
-				if len(args) == 0 {
-					return 0
-				}
-				if val, ok := args[0].(int); ok {
-					if val <= 0 {
-						return val
-					}
-					return val + 1
-				}
-				return args[0]
-			},
-			"ABS": func(args ...interface{}) interface{} {
-				if len(args) == 0 {
-					return 0
-				}
-				return args[0]
-			},
-			"SUM": func(args ...interface{}) interface{} {
-				if len(args) == 0 {
-					return 0
-				}
-				return args[0]
-			},
-			"AVG": func(args ...interface{}) interface{} {
-				if len(args) == 0 {
-					return 0
-				}
-				return args[0]
-			},
-			"COUNT": func(args ...interface{}) interface{} {
-				return 0
-			},
-			"MAX": func(args ...interface{}) interface{} {
-				if len(args) == 0 {
-					return nil
-				}
-				return args[0]
-			},
-			"MIN": func(args ...interface{}) interface{} {
-				if len(args) == 0 {
-					return nil
-				}
-				return args[0]
-			},
-			"IF": func(args ...interface{}) interface{} {
-				if len(args) < 2 {
-					return nil
-				}
-				condition := fmt.Sprintf("%v", args[0])
-				if condition == "true" || condition == "1" {
-					return args[1]
-				}
-				if len(args) >= 3 {
-					return args[2]
-				}
-				return nil
-			},
-		},
-	}
+	return &apiExpressionBuilder{}
 }
 
 func (b *apiExpressionBuilder) Build(expression string, fields []string) (string, error) {
@@ -166,40 +28,38 @@ func (b *apiExpressionBuilder) Build(expression string, fields []string) (string
 }
 
 func (b *apiExpressionBuilder) Validate(expression string, fields []string) error {
-	if expression == "" {
-		return fmt.Errorf("expression cannot be empty")
-	}
+	_, _, err := schemaFromNames(fields).compile(expression)
+	return err
+}
 
-	fieldPattern := regexp.MustCompile(`\[(\w+)\]`)
-	matches := fieldPattern.FindAllString(expression, -1)
+// Evaluate computes the expression for one row. It runs on the same tree and
+// type rules as the SQL builder, with the row's keys as the field set.
+func (b *apiExpressionBuilder) Evaluate(expression string, row map[string]interface{}) (interface{}, error) {
+	node, err := b.parse(expression)
+	if err != nil {
+		return nil, err
+	}
 
-	for _, match := range matches {
-		fieldName := strings.Trim(match, "[]")
-		found := false
-		for _, field := range fields {
-			if field == fieldName {
-				found = true
-				break
-			}
-		}
-		if !found {
-			return fmt.Errorf("field reference '%s' not found in dataset fields", match)
-		}
+	fields := make(map[string]exprType, len(row))
+	for name := range row {
+		fields[name] = typeAny
+	}
+	if _, err := newExpressionChecker(fields).check(node); err != nil {
+		return nil, err
 	}
 
-	return nil
+	return evaluateExpression(node, row)
 }
 
-func (b *apiExpressionBuilder) Evaluate(expression string, row map[string]interface{}) (interface{}, error) {
-	result := expression
-
-	for fieldName, value := range row {
-		result = strings.ReplaceAll(result, fmt.Sprintf("[%s]", fieldName), fmt.Sprintf("%v", value))
+func (b *apiExpressionBuilder) parse(expression string) (exprNode, error) {
+	if cached, ok := b.parsed.Load(expression); ok {
+		return cached.(exprNode), nil
 	}
 
-	if strings.Contains(result, "[") || strings.Contains(result, "]") {
-		return nil, fmt.Errorf("unresolved field references in expression")
+	node, err := parseExpression(expression)
+	if err != nil {
+		return nil, err
 	}
-
-	return result, nil
+	b.parsed.Store(expression, node)
+	return node, nil
 }
@@ -117,7 +117,7 @@ func TestAPIExpressionBuilder_Evaluate(t *testing.T) {
 		},
 		{
 			name:       "expression with multiple fields",
-			expression: "[first] [last]",
+			expression: "CONCAT([first], ' ', [last])",
 			row:        map[string]interface{}{"first": "John", "last": "Doe"},
 			want:       "John Doe",
 			wantErr:    false,
@@ -133,9 +133,51 @@ func TestAPIExpressionBuilder_Evaluate(t *testing.T) {
 			name:       "numeric field",
 			expression: "[count]",
 			row:        map[string]interface{}{"count": 42},
-			want:       "42",
+			want:       float64(42),
 			wantErr:    false,
 		},
+		{
+			name:       "arithmetic",
+			expression: "[price] * [quantity] + 1",
+			row:        map[string]interface{}{"price": 2.5, "quantity": 4},
+			want:       float64(11),
+			wantErr:    false,
+		},
+		{
+			name:       "null propagates through arithmetic",
+			expression: "[price] * 2",
+			row:        map[string]interface{}{"price": nil},
+			want:       nil,
+			wantErr:    false,
+		},
+		{
+			name:       "searched case",
+			expression: "CASE WHEN [amount] >= 100 THEN 'big' WHEN [amount] >= 10 THEN 'medium' ELSE 'small' END",
+			row:        map[string]interface{}{"amount": 42},
+			want:       "medium",
+			wantErr:    false,
+		},
+		{
+			name:       "simple case",
+			expression: "CASE [status] WHEN 'A' THEN 1 WHEN 'B' THEN 2 END",
+			row:        map[string]interface{}{"status": "B"},
+			want:       float64(2),
+			wantErr:    false,
+		},
+		{
+			name:       "boolean logic",
+			expression: "[a] > 1 AND NOT [b] IS NULL",
+			row:        map[string]interface{}{"a": 2, "b": "x"},
+			want:       true,
+			wantErr:    false,
+		},
+		{
+			name:       "type error",
+			expression: "[name] * 2",
+			row:        map[string]interface{}{"name": "abc"},
+			want:       nil,
+			wantErr:    true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -153,205 +195,59 @@ func TestAPIExpressionBuilder_Evaluate(t *testing.T) {
 
 func TestAPIExpressionBuilder_Functions(t *testing.T) {
 	builder := NewAPIExpressionBuilder()
-	fnMap := builder.(*apiExpressionBuilder).functionMap
-
-	t.Run("CONCAT", func(t *testing.T) {
-		result := fnMap["CONCAT"]("a", "b", "c")
-		if result != "abc" {
-			t.Errorf("CONCAT() = %v, want abc", result)
-		}
-	})
-
-	t.Run("UPPER", func(t *testing.T) {
-		result := fnMap["UPPER"]("hello")
-		if result != "HELLO" {
-			t.Errorf("UPPER() = %v, want HELLO", result)
-		}
-	})
-
-	t.Run("LOWER", func(t *testing.T) {
-		result := fnMap["LOWER"]("HELLO")
-		if result != "hello" {
-			t.Errorf("LOWER() = %v, want hello", result)
-		}
-	})
-
-	t.Run("LENGTH", func(t *testing.T) {
-		result := fnMap["LENGTH"]("hello")
-		if result != 5 {
-			t.Errorf("LENGTH() = %v, want 5", result)
-		}
-	})
-
-	t.Run("TRIM", func(t *testing.T) {
-		result := fnMap["TRIM"]("  hello  ")
-		if result != "hello" {
-			t.Errorf("TRIM() = %v, want hello", result)
-		}
-	})
-
-	t.Run("IF true condition", func(t *testing.T) {
-		result := fnMap["IF"]("true", "yes", "no")
-		if result != "yes" {
-			t.Errorf("IF() = %v, want yes", result)
-		}
-	})
-
-	t.Run("IF false condition", func(t *testing.T) {
-		result := fnMap["IF"]("false", "yes", "no")
-		if result != "no" {
-			t.Errorf("IF() = %v, want no", result)
-		}
-	})
-
-	t.Run("IF with 1 condition", func(t *testing.T) {
-		result := fnMap["IF"]("1", "yes", "no")
-		if result != "yes" {
-			t.Errorf("IF() = %v, want yes", result)
-		}
-	})
-
-	t.Run("SUBSTRING with length", func(t *testing.T) {
-		result := fnMap["SUBSTRING"]("hello", 0, 3)
-		if result != "hel" {
-			t.Errorf("SUBSTRING() = %v, want hel", result)
-		}
-	})
-
-	t.Run("SUBSTRING without length", func(t *testing.T) {
-		result := fnMap["SUBSTRING"]("hello", 2)
-		if result != "llo" {
-			t.Errorf("SUBSTRING() = %v, want llo", result)
-		}
-	})
-
-	t.Run("SUBSTRING out of range", func(t *testing.T) {
-		result := fnMap["SUBSTRING"]("hello", 100, 3)
-		if result != "" {
-			t.Errorf("SUBSTRING() = %v, want empty", result)
-		}
-	})
-
-	t.Run("SUBSTRING negative start", func(t *testing.T) {
-		result := fnMap["SUBSTRING"]("hello", -1, 3)
-		if result != "" {
-			t.Errorf("SUBSTRING() = %v, want empty", result)
-		}
-	})
-
-	t.Run("CEIL positive", func(t *testing.T) {
-		result := fnMap["CEIL"](3)
-		if result != 3 {
-			t.Errorf("CEIL() = %v, want 3", result)
-		}
-	})
-
-	t.Run("CEIL negative", func(t *testing.T) {
-		result := fnMap["CEIL"](-3)
-		if result != -4 {
-			t.Errorf("CEIL() = %v, want -4", result)
-		}
-	})
-
-	t.Run("FLOOR positive", func(t *testing.T) {
-		result := fnMap["FLOOR"](3)
-		if result != 4 {
-			t.Errorf("FLOOR() = %v, want 4", result)
-		}
-	})
-
-	t.Run("FLOOR negative", func(t *testing.T) {
-		result := fnMap["FLOOR"](-3)
-		if result != -3 {
-			t.Errorf("FLOOR() = %v, want -3", result)
-		}
-	})
-
-	t.Run("ABS", func(t *testing.T) {
-		result := fnMap["ABS"](-5)
-		if result != -5 {
-			t.Errorf("ABS() = %v, want -5", result)
-		}
-	})
-
-	t.Run("SUM", func(t *testing.T) {
-		result := fnMap["SUM"](10)
-		if result != 10 {
-			t.Errorf("SUM() = %v, want 10", result)
-		}
-	})
-
-	t.Run("AVG", func(t *testing.T) {
-		result := fnMap["AVG"](10)
-		if result != 10 {
-			t.Errorf("AVG() = %v, want 10", result)
-		}
-	})
-
-	t.Run("COUNT", func(t *testing.T) {
-		result := fnMap["COUNT"]()
-		if result != 0 {
-			t.Errorf("COUNT() = %v, want 0", result)
-		}
-	})
-
-	t.Run("MAX", func(t *testing.T) {
-		result := fnMap["MAX"](10)
-		if result != 10 {
-			t.Errorf("MAX() = %v, want 10", result)
-		}
-	})
-
-	t.Run("MIN", func(t *testing.T) {
-		result := fnMap["MIN"](10)
-		if result != 10 {
-			t.Errorf("MIN() = %v, want 10", result)
-		}
-	})
+	row := map[string]interface{}{
+		"name":       "  Hello  ",
+		"word":       "hello",
+		"amount":     -3.456,
+		"created_at": "2024-01-31 08:05:09",
+		"ended_at":   "2024-03-01",
+		"missing":    nil,
+	}
 
-	t.Run("ROUND", func(t *testing.T) {
-		result := fnMap["ROUND"](3.14)
-		if result != 3.14 {
-			t.Errorf("ROUND() = %v, want 3.14", result)
-		}
-	})
+	tests := []struct {
+		expression string
+		want       interface{}
+	}{
+		{"CONCAT('a', 'b', 'c')", "abc"},
+		{"CONCAT('a', [missing])", nil},
+		{"UPPER([word])", "HELLO"},
+		{"LOWER('HELLO')", "hello"},
+		{"LENGTH([word])", float64(5)},
+		{"TRIM([name])", "Hello"},
+		{"SUBSTRING([word], 1, 3)", "hel"},
+		{"SUBSTRING([word], 3)", "llo"},
+		{"SUBSTRING([word], -2)", "lo"},
+		{"SUBSTRING([word], 100, 3)", ""},
+		{"ROUND([amount], 2)", -3.46},
+		{"ROUND([amount])", float64(-3)},
+		{"CEIL([amount])", float64(-3)},
+		{"FLOOR([amount])", float64(-4)},
+		{"ABS(-5)", float64(5)},
+		{"SUM(10)", float64(10)},
+		{"COUNT(*)", float64(1)},
+		{"COUNT([missing])", float64(0)},
+		{"MAX(10)", float64(10)},
+		{"COALESCE([missing], 'fallback')", "fallback"},
+		{"IF([amount] < 0, 'yes', 'no')", "yes"},
+		{"IF([missing] > 0, 'yes', 'no')", "no"},
+		{"DATE_FORMAT([created_at], '%Y/%m/%d %H:%i:%s')", "2024/01/31 08:05:09"},
+		{"DATE_FORMAT(DATE_ADD([created_at], 1, 'month'), '%Y-%m-%d')", "2024-02-29"},
+		{"DATE_FORMAT(DATE_SUB([created_at], 2), '%Y-%m-%d')", "2024-01-29"},
+		{"DATEDIFF([ended_at], [created_at])", float64(30)},
+		{"YEAR([created_at])", float64(2024)},
+		{"MONTH([created_at])", float64(1)},
+		{"MINUTE([created_at])", float64(5)},
+	}
 
-	t.Run("functions with no args", func(t *testing.T) {
-		if fnMap["LENGTH"]() != 0 {
-			t.Error("LENGTH with no args should return 0")
-		}
-		if fnMap["UPPER"]() != "" {
-			t.Error("UPPER with no args should return empty")
-		}
-		if fnMap["LOWER"]() != "" {
-			t.Error("LOWER with no args should return empty")
-		}
-		if fnMap["TRIM"]() != "" {
-			t.Error("TRIM with no args should return empty")
-		}
-		if fnMap["ROUND"]() != 0 {
-			t.Error("ROUND with no args should return 0")
-		}
-		if fnMap["CEIL"]() != 0 {
-			t.Error("CEIL with no args should return 0")
-		}
-		if fnMap["FLOOR"]() != 0 {
-			t.Error("FLOOR with no args should return 0")
-		}
-		if fnMap["ABS"]() != 0 {
-			t.Error("ABS with no args should return 0")
-		}
-		if fnMap["SUM"]() != 0 {
-			t.Error("SUM with no args should return 0")
-		}
-		if fnMap["AVG"]() != 0 {
-			t.Error("AVG with no args should return 0")
-		}
-		if fnMap["MAX"]() != nil {
-			t.Error("MAX with no args should return nil")
-		}
-		if fnMap["MIN"]() != nil {
-			t.Error("MIN with no args should return nil")
-		}
-	})
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			result, err := builder.Evaluate(tt.expression, row)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+			if result != tt.want {
+				t.Errorf("Evaluate() = %#v, want %#v", result, tt.want)
+			}
+		})
+	}
 }
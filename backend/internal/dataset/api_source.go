@@ -0,0 +1,327 @@
+package dataset
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gujiaweiguo/goreport/internal/models"
+)
+
+// maxAPIResponseBytes bounds how much of an API response is read into memory.
+const maxAPIResponseBytes = 32 << 20
+
+// apiDatasetConfig is the Config of an "api" dataset. URL may be absolute or
+// relative to the url of the dataset's API datasource.
+type apiDatasetConfig struct {
+	URL      string            `json:"url"`
+	Method   string            `json:"method"`
+	Headers  map[string]string `json:"headers"`
+	Params   map[string]string `json:"params"`
+	Body     json.RawMessage   `json:"body"`
+	DataPath string            `json:"dataPath"`
+}
+
+// apiConnectorConfig is the connector Config of an "api" datasource, matching
+// the fields of the api ConnectorProfile. Username and Password come from the
+// datasource columns.
+type apiConnectorConfig struct {
+	URL     string            `json:"url"`
+	Token   string            `json:"token"`
+	Headers map[string]string `json:"headers"`
+}
+
+func newAPIHTTPClient() *http.Client {
+	return &http.Client{}
+}
+
+func parseAPIDatasetConfig(raw string) (*apiDatasetConfig, error) {
+	var config apiDatasetConfig
+	if strings.TrimSpace(raw) != "" {
+		if err := json.Unmarshal([]byte(raw), &config); err != nil {
+			return nil, fmt.Errorf("invalid dataset config: %w", err)
+		}
+	}
+	return &config, nil
+}
+
+// fetchAPIRows calls the dataset's API and flattens the records found at
+// DataPath into rows. ds may be nil when the dataset URL is absolute.
+func fetchAPIRows(ctx context.Context, client *http.Client, ds *models.DataSource, config *apiDatasetConfig) ([]map[string]interface{}, error) {
+	var connector apiConnectorConfig
+	if ds != nil && strings.TrimSpace(ds.Config) != "" {
+		if err := json.Unmarshal([]byte(ds.Config), &connector); err != nil {
+			return nil, fmt.Errorf("invalid datasource config: %w", err)
+		}
+	}
+
+	target, err := resolveAPIURL(connector.URL, config.URL, config.Params)
+	if err != nil {
+		return nil, err
+	}
+
+	method := strings.ToUpper(strings.TrimSpace(config.Method))
+	if method == "" {
+		method = http.MethodGet
+	}
+	if method != http.MethodGet && method != http.MethodPost {
+		return nil, fmt.Errorf("unsupported api method: %s", config.Method)
+	}
+
+	var body io.Reader
+	if method == http.MethodPost && len(config.Body) > 0 {
+		body = bytes.NewReader(config.Body)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid api request: %w", err)
+	}
+	httpReq.Header.Set("Accept", "application/json")
+	if body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	for key, value := range connector.Headers {
+		httpReq.Header.Set(key, value)
+	}
+	for key, value := range config.Headers {
+		httpReq.Header.Set(key, value)
+	}
+	if connector.Token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+connector.Token)
+	} else if ds != nil && ds.Username != "" {
+		httpReq.SetBasicAuth(ds.Username, ds.Password)
+	}
+
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, errors.New("api request timeout")
+		}
+		return nil, fmt.Errorf("api request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("api request failed with status %d", resp.StatusCode)
+	}
+
+	limited := io.LimitReader(resp.Body, maxAPIResponseBytes+1)
+	payload, err := io.ReadAll(limited)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read api response: %w", err)
+	}
+	if len(payload) > maxAPIResponseBytes {
+		return nil, fmt.Errorf("api response exceeds %d bytes", maxAPIResponseBytes)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	var document interface{}
+	if err := decoder.Decode(&document); err != nil {
+		return nil, fmt.Errorf("api response is not valid JSON: %w", err)
+	}
+
+	records, err := selectJSONPath(document, config.DataPath)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]map[string]interface{}, 0, len(records))
+	for _, record := range records {
+		row := make(map[string]interface{})
+		flattenJSONRecord("", record, row)
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func resolveAPIURL(baseURL, datasetURL string, params map[string]string) (string, error) {
+	var target *url.URL
+	switch {
+	case datasetURL == "" && baseURL == "":
+		return "", errors.New("api url is required")
+	case baseURL == "":
+		parsed, err := url.Parse(datasetURL)
+		if err != nil {
+			return "", fmt.Errorf("invalid api url: %w", err)
+		}
+		target = parsed
+	default:
+		base, err := url.Parse(baseURL)
+		if err != nil {
+			return "", fmt.Errorf("invalid datasource url: %w", err)
+		}
+		ref, err := url.Parse(datasetURL)
+		if err != nil {
+			return "", fmt.Errorf("invalid api url: %w", err)
+		}
+		target = base.ResolveReference(ref)
+	}
+
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return "", fmt.Errorf("api url must use http or https: %s", target.String())
+	}
+
+	if len(params) > 0 {
+		query := target.Query()
+		for key, value := range params {
+			query.Set(key, value)
+		}
+		target.RawQuery = query.Encode()
+	}
+	return target.String(), nil
+}
+
+// selectJSONPath walks a dot-separated path such as "data.items" and returns
+// the records there. Numeric segments index into arrays; a single object
+// yields one record.
+func selectJSONPath(document interface{}, path string) ([]interface{}, error) {
+	current := document
+	path = strings.TrimPrefix(strings.TrimSpace(path), "$")
+	for _, segment := range strings.Split(path, ".") {
+		if segment == "" {
+			continue
+		}
+		switch node := current.(type) {
+		case map[string]interface{}:
+			next, ok := node[segment]
+			if !ok {
+				return nil, fmt.Errorf("data path %q not found in api response", path)
+			}
+			current = next
+		case []interface{}:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, fmt.Errorf("data path %q not found in api response", path)
+			}
+			current = node[index]
+		default:
+			return nil, fmt.Errorf("data path %q not found in api response", path)
+		}
+	}
+
+	switch node := current.(type) {
+	case []interface{}:
+		return node, nil
+	case map[string]interface{}:
+		return []interface{}{node}, nil
+	case nil:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("data path %q does not point to an object or array", path)
+	}
+}
+
+// flattenJSONRecord turns nested objects into dotted column names, e.g.
+// {"customer":{"name":"x"}} becomes customer.name. Arrays are kept as values.
+func flattenJSONRecord(prefix string, value interface{}, row map[string]interface{}) {
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		if prefix == "" {
+			prefix = "value"
+		}
+		row[prefix] = jsonScalar(value)
+		return
+	}
+
+	for key, child := range object {
+		name := key
+		if prefix != "" {
+			name = prefix + "." + key
+		}
+		if nested, ok := child.(map[string]interface{}); ok && len(nested) > 0 {
+			flattenJSONRecord(name, nested, row)
+			continue
+		}
+		row[name] = jsonScalar(child)
+	}
+}
+
+func jsonScalar(value interface{}) interface{} {
+	number, ok := value.(json.Number)
+	if !ok {
+		return value
+	}
+	if integer, err := number.Int64(); err == nil {
+		return integer
+	}
+	if float, err := number.Float64(); err == nil {
+		return float
+	}
+	return number.String()
+}
+
+// inferRowFields derives dataset fields from sampled rows. Columns are sorted
+// so the field order is stable across fetches.
+func inferRowFields(rows []map[string]interface{}) []models.DatasetField {
+	types := make(map[string]string)
+	for _, row := range rows {
+		for name, value := range row {
+			if value == nil {
+				if _, ok := types[name]; !ok {
+					types[name] = ""
+				}
+				continue
+			}
+			types[name] = mergeInferredDataType(types[name], inferValueDataType(value))
+		}
+	}
+
+	names := make([]string, 0, len(types))
+	for name := range types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fields := make([]models.DatasetField, 0, len(names))
+	for i, name := range names {
+		dataType := types[name]
+		if dataType == "" {
+			dataType = "string"
+		}
+		fieldType := "dimension"
+		if dataType == "number" {
+			fieldType = "measure"
+		}
+		fields = append(fields, models.DatasetField{
+			Name:      name,
+			Type:      fieldType,
+			DataType:  dataType,
+			SortIndex: i,
+		})
+	}
+	return fields
+}
+
+func inferValueDataType(value interface{}) string {
+	switch v := normalizeExpressionValue(value).(type) {
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case string:
+		if _, err := expressionTime(v, 0); err == nil {
+			return "date"
+		}
+		return "string"
+	default:
+		return "date"
+	}
+}
+
+// mergeInferredDataType widens to string when samples disagree.
+func mergeInferredDataType(current, next string) string {
+	if current == "" || current == next {
+		return next
+	}
+	return "string"
+}
@@ -0,0 +1,178 @@
+package dataset
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gujiaweiguo/goreport/internal/models"
+)
+
+var ErrInvalidDrill = errors.New("invalid drill")
+
+// DrillState is a position in one of a dataset's drill paths. Values holds
+// the value clicked at each level drilled through so far, so an empty list
+// is the top level and ["North"] is the cities of region North.
+type DrillState struct {
+	Path   string        `json:"path"`
+	Values []interface{} `json:"values"`
+}
+
+// applyDrill resolves req.Drill in place: the field of the current level
+// replaces whichever path field the request groups, selects or sorts by,
+// each ancestor level becomes an eq filter, and Drill is cleared so the
+// request can be run again. Callers see the drilled fields in req.Fields.
+func applyDrill(dataset *models.Dataset, req *QueryRequest) error {
+	if req.Drill == nil {
+		return nil
+	}
+	var path *models.DrillPath
+	for i := range dataset.DrillPaths {
+		if dataset.DrillPaths[i].Name == req.Drill.Path {
+			path = &dataset.DrillPaths[i]
+			break
+		}
+	}
+	if path == nil {
+		return fmt.Errorf("%w: unknown drill path %q", ErrInvalidDrill, req.Drill.Path)
+	}
+	level := len(req.Drill.Values)
+	if level >= len(path.Fields) {
+		return fmt.Errorf("%w: %q has no level below %d", ErrInvalidDrill, path.Name, level)
+	}
+
+	current := path.Fields[level]
+	inPath := make(map[string]bool, len(path.Fields))
+	for _, field := range path.Fields {
+		inPath[field] = true
+	}
+	drillFields := func(fields []string) ([]string, bool) {
+		drilled := make([]string, 0, len(fields))
+		replaced := false
+		for _, field := range fields {
+			if !inPath[field] {
+				drilled = append(drilled, field)
+				continue
+			}
+			if !replaced {
+				drilled = append(drilled, current)
+				replaced = true
+			}
+		}
+		return drilled, replaced
+	}
+
+	grouped := len(req.GroupBy) > 0 || len(req.Aggregations) > 0
+	// Without fields every column is selected, the drilled one included.
+	if len(req.Fields) > 0 || grouped {
+		fields, replaced := drillFields(req.Fields)
+		if !replaced {
+			fields = append([]string{current}, fields...)
+		}
+		req.Fields = fields
+	}
+	if grouped {
+		groupBy, replaced := drillFields(req.GroupBy)
+		if !replaced {
+			groupBy = append([]string{current}, groupBy...)
+		}
+		req.GroupBy = groupBy
+	}
+	if inPath[req.SortBy] {
+		req.SortBy = current
+	}
+
+	filters := make([]Filter, 0, len(req.Filters)+level)
+	filters = append(filters, req.Filters...)
+	for i, value := range req.Drill.Values {
+		filters = append(filters, Filter{Field: path.Fields[i], Operator: "eq", Value: value})
+	}
+	req.Filters = filters
+	req.Drill = nil
+	return nil
+}
+
+// validateDrillPaths checks each path names at least two distinct fields of
+// the dataset.
+func validateDrillPaths(paths []models.DrillPath, fields []*models.DatasetField) error {
+	known := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		known[field.Name] = true
+	}
+	names := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		if path.Name == "" {
+			return fmt.Errorf("%w: drill path has no name", ErrInvalidDrill)
+		}
+		if names[path.Name] {
+			return fmt.Errorf("%w: duplicate drill path %q", ErrInvalidDrill, path.Name)
+		}
+		names[path.Name] = true
+		if len(path.Fields) < 2 {
+			return fmt.Errorf("%w: drill path %q needs at least two levels", ErrInvalidDrill, path.Name)
+		}
+		seen := make(map[string]bool, len(path.Fields))
+		for _, field := range path.Fields {
+			if !known[field] {
+				return fmt.Errorf("%w: drill path %q has unknown field %q", ErrInvalidDrill, path.Name, field)
+			}
+			if seen[field] {
+				return fmt.Errorf("%w: drill path %q repeats field %q", ErrInvalidDrill, path.Name, field)
+			}
+			seen[field] = true
+		}
+	}
+	return nil
+}
+
+const (
+	DrillThroughReport    = "report"
+	DrillThroughDashboard = "dashboard"
+)
+
+// DrillThrough opens a report or dashboard from a clicked row. Params maps
+// each parameter of the target to the row field its value is taken from.
+type DrillThrough struct {
+	TargetType string            `json:"targetType"`
+	TargetID   string            `json:"targetId"`
+	Params     map[string]string `json:"params"`
+}
+
+// DrillThroughTarget is a drill-through resolved for one row, for the client
+// to open.
+type DrillThroughTarget struct {
+	TargetType string                 `json:"targetType"`
+	TargetID   string                 `json:"targetId"`
+	Params     map[string]interface{} `json:"params"`
+}
+
+func (d *DrillThrough) Validate() error {
+	if d.TargetType != DrillThroughReport && d.TargetType != DrillThroughDashboard {
+		return fmt.Errorf("%w: unsupported drill-through target %q", ErrInvalidDrill, d.TargetType)
+	}
+	if d.TargetID == "" {
+		return fmt.Errorf("%w: drill-through has no target", ErrInvalidDrill)
+	}
+	for param, field := range d.Params {
+		if param == "" || field == "" {
+			return fmt.Errorf("%w: drill-through parameter %q has no field", ErrInvalidDrill, param)
+		}
+	}
+	return nil
+}
+
+// Resolve takes the target parameters from row; every mapped field must be
+// in it.
+func (d *DrillThrough) Resolve(row map[string]interface{}) (*DrillThroughTarget, error) {
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+	params := make(map[string]interface{}, len(d.Params))
+	for param, field := range d.Params {
+		value, ok := row[field]
+		if !ok {
+			return nil, fmt.Errorf("%w: clicked row has no field %q", ErrInvalidDrill, field)
+		}
+		params[param] = value
+	}
+	return &DrillThroughTarget{TargetType: d.TargetType, TargetID: d.TargetID, Params: params}, nil
+}
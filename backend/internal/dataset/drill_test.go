@@ -0,0 +1,123 @@
+package dataset
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gujiaweiguo/goreport/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func drillDataset() *models.Dataset {
+	return &models.Dataset{
+		ID: "ds-1",
+		DrillPaths: []models.DrillPath{
+			{Name: "geo", Fields: []string{"region", "city", "store"}},
+			{Name: "time", Fields: []string{"year", "quarter", "month"}},
+		},
+	}
+}
+
+func TestApplyDrill(t *testing.T) {
+	req := &QueryRequest{
+		Fields:       []string{"region", "channel"},
+		GroupBy:      []string{"region", "channel"},
+		Aggregations: map[string]Aggregation{"total": {Function: "SUM", Field: "amount"}},
+		Filters:      []Filter{{Field: "year", Operator: "eq", Value: 2026}},
+		SortBy:       "region",
+		Drill:        &DrillState{Path: "geo", Values: []interface{}{"North"}},
+	}
+	require.NoError(t, applyDrill(drillDataset(), req))
+	assert.Equal(t, []string{"city", "channel"}, req.Fields)
+	assert.Equal(t, []string{"city", "channel"}, req.GroupBy)
+	assert.Equal(t, "city", req.SortBy)
+	assert.Equal(t, []Filter{
+		{Field: "year", Operator: "eq", Value: 2026},
+		{Field: "region", Operator: "eq", Value: "North"},
+	}, req.Filters)
+	assert.Nil(t, req.Drill, "a resolved drill is not applied twice")
+
+	req = &QueryRequest{
+		Fields:       []string{"channel"},
+		GroupBy:      []string{"channel"},
+		Aggregations: map[string]Aggregation{"total": {Function: "SUM", Field: "amount"}},
+		Drill:        &DrillState{Path: "time", Values: []interface{}{2026.0, "Q1"}},
+	}
+	require.NoError(t, applyDrill(drillDataset(), req))
+	assert.Equal(t, []string{"month", "channel"}, req.Fields, "the level leads when the request has no path field")
+	assert.Equal(t, []string{"month", "channel"}, req.GroupBy)
+	assert.Equal(t, []Filter{
+		{Field: "year", Operator: "eq", Value: 2026.0},
+		{Field: "quarter", Operator: "eq", Value: "Q1"},
+	}, req.Filters)
+
+	req = &QueryRequest{Drill: &DrillState{Path: "geo", Values: []interface{}{"North", "Leeds"}}}
+	require.NoError(t, applyDrill(drillDataset(), req))
+	assert.Empty(t, req.Fields, "an unprojected query keeps every column")
+	assert.Len(t, req.Filters, 2)
+
+	for name, drill := range map[string]*DrillState{
+		"unknown path": {Path: "product"},
+		"too deep":     {Path: "geo", Values: []interface{}{"North", "Leeds", "Store 1"}},
+	} {
+		err := applyDrill(drillDataset(), &QueryRequest{Drill: drill})
+		assert.ErrorIs(t, err, ErrInvalidDrill, name)
+	}
+}
+
+func TestValidateDrillPaths(t *testing.T) {
+	fields := []*models.DatasetField{{Name: "region"}, {Name: "city"}, {Name: "store"}}
+	require.NoError(t, validateDrillPaths([]models.DrillPath{{Name: "geo", Fields: []string{"region", "city", "store"}}}, fields))
+
+	for name, paths := range map[string][]models.DrillPath{
+		"name":      {{Fields: []string{"region", "city"}}},
+		"duplicate": {{Name: "geo", Fields: []string{"region", "city"}}, {Name: "geo", Fields: []string{"city", "store"}}},
+		"one level": {{Name: "geo", Fields: []string{"region"}}},
+		"unknown":   {{Name: "geo", Fields: []string{"region", "country"}}},
+		"repeated":  {{Name: "geo", Fields: []string{"region", "region"}}},
+	} {
+		assert.ErrorIs(t, validateDrillPaths(paths, fields), ErrInvalidDrill, name)
+	}
+}
+
+func TestDatasetService_Update_DrillPaths(t *testing.T) {
+	datasetRepo := &mockDatasetRepository{}
+	fieldRepo := &mockDatasetFieldRepository{}
+	svc := NewService(datasetRepo, fieldRepo, nil, nil)
+
+	datasetRepo.On("GetByID", mock.Anything, "ds-1").Return(&models.Dataset{ID: "ds-1", TenantID: "tenant-1"}, nil)
+	fieldRepo.On("List", mock.Anything, "ds-1").Return([]*models.DatasetField{{Name: "region"}, {Name: "city"}}, nil)
+	datasetRepo.On("Update", mock.Anything, mock.MatchedBy(func(d *models.Dataset) bool {
+		return len(d.DrillPaths) == 1 && d.DrillPaths[0].Name == "geo"
+	})).Return(nil).Once()
+	datasetRepo.On("GetByIDWithFields", mock.Anything, "ds-1").Return(&models.Dataset{ID: "ds-1"}, nil)
+
+	paths := []models.DrillPath{{Name: "geo", Fields: []string{"region", "city"}}}
+	_, err := svc.Update(context.Background(), &UpdateRequest{ID: "ds-1", TenantID: "tenant-1", DrillPaths: &paths})
+	require.NoError(t, err)
+
+	invalid := []models.DrillPath{{Name: "geo", Fields: []string{"region", "store"}}}
+	_, err = svc.Update(context.Background(), &UpdateRequest{ID: "ds-1", TenantID: "tenant-1", DrillPaths: &invalid})
+	assert.ErrorIs(t, err, ErrInvalidDrill)
+	datasetRepo.AssertExpectations(t)
+}
+
+func TestDrillThrough_Resolve(t *testing.T) {
+	action := &DrillThrough{TargetType: DrillThroughReport, TargetID: "report-1", Params: map[string]string{"region": "region_name", "year": "year"}}
+	target, err := action.Resolve(map[string]interface{}{"region_name": "North", "year": 2026.0, "total": 10.0})
+	require.NoError(t, err)
+	assert.Equal(t, &DrillThroughTarget{
+		TargetType: DrillThroughReport,
+		TargetID:   "report-1",
+		Params:     map[string]interface{}{"region": "North", "year": 2026.0},
+	}, target)
+
+	_, err = action.Resolve(map[string]interface{}{"region_name": "North"})
+	assert.ErrorIs(t, err, ErrInvalidDrill)
+	_, err = (&DrillThrough{TargetType: "url", TargetID: "x"}).Resolve(nil)
+	assert.ErrorIs(t, err, ErrInvalidDrill)
+	_, err = (&DrillThrough{TargetType: DrillThroughDashboard}).Resolve(nil)
+	assert.ErrorIs(t, err, ErrInvalidDrill)
+}
@@ -5,6 +5,9 @@ import (
 	"time"
 )
 
+// Fallback bounds for dataset queries and previews. A datasource's own
+// QueryTimeoutSeconds takes precedence; these apply to datasources without
+// one and to sources that have no datasource, such as uploaded files.
 const (
 	datasetQueryTimeout   = 15 * time.Second
 	datasetPreviewTimeout = 8 * time.Second
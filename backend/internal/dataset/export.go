@@ -0,0 +1,455 @@
+package dataset
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gujiaweiguo/goreport/internal/config"
+	"github.com/gujiaweiguo/goreport/internal/models"
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	ExportFormatCSV  = "csv"
+	ExportFormatXLSX = "xlsx"
+
+	defaultExportMaxRows = 1000000
+	// xlsxMaxRows is the sheet row limit less the header row.
+	xlsxMaxRows = 1048576 - 1
+)
+
+// ErrExportTooLarge is returned, before anything reaches the client, when an
+// export would exceed the tenant's row ceiling.
+var ErrExportTooLarge = errors.New("export exceeds the row limit")
+
+var exportLimits = config.ExportConfig{MaxRows: defaultExportMaxRows}
+
+// InitExport configures the per-tenant export row ceilings.
+func InitExport(cfg *config.ExportConfig) {
+	exportLimits = *cfg
+}
+
+// exportRowLimit returns the most rows tenantID may export at once; zero
+// means unlimited.
+func exportRowLimit(tenantID string) int {
+	if limit, ok := exportLimits.TenantMaxRows[tenantID]; ok {
+		return limit
+	}
+	return exportLimits.MaxRows
+}
+
+// RowWriter receives an export one row at a time.
+type RowWriter interface {
+	WriteHeader(headers []string) error
+	WriteRow(values []interface{}) error
+	// Close flushes buffered output; the export is incomplete without it.
+	Close() error
+}
+
+// NewRowWriter returns a writer for format that streams to w.
+func NewRowWriter(format string, w io.Writer) (RowWriter, error) {
+	switch format {
+	case ExportFormatCSV:
+		return newCSVRowWriter(w), nil
+	case ExportFormatXLSX:
+		return newXLSXRowWriter(w)
+	default:
+		return nil, fmt.Errorf("unsupported export format: %s", format)
+	}
+}
+
+// ExportContentType is the MIME type of an export format.
+func ExportContentType(format string) string {
+	if format == ExportFormatXLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+type csvRowWriter struct {
+	w      io.Writer
+	csv    *csv.Writer
+	record []string
+}
+
+func newCSVRowWriter(w io.Writer) *csvRowWriter {
+	return &csvRowWriter{w: w, csv: csv.NewWriter(w)}
+}
+
+func (c *csvRowWriter) WriteHeader(headers []string) error {
+	// The BOM lets Excel detect UTF-8 when opening the file.
+	if _, err := io.WriteString(c.w, "\ufeff"); err != nil {
+		return err
+	}
+	record := make([]string, len(headers))
+	for i, header := range headers {
+		record[i] = csvText(header)
+	}
+	return c.csv.Write(record)
+}
+
+func (c *csvRowWriter) WriteRow(values []interface{}) error {
+	c.record = c.record[:0]
+	for _, value := range values {
+		c.record = append(c.record, csvValue(value))
+	}
+	return c.csv.Write(c.record)
+}
+
+func (c *csvRowWriter) Close() error {
+	c.csv.Flush()
+	return c.csv.Error()
+}
+
+func csvValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return csvText(v)
+	case []byte:
+		return csvText(string(v))
+	case time.Time:
+		if v.Hour() == 0 && v.Minute() == 0 && v.Second() == 0 && v.Nanosecond() == 0 {
+			return v.Format("2006-01-02")
+		}
+		return v.Format("2006-01-02 15:04:05")
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// csvNumber matches the plain decimal numbers a spreadsheet reads as values.
+var csvNumber = regexp.MustCompile(`^[+-]?(\d+\.?\d*|\.\d+)([eE][+-]?\d+)?$`)
+
+// csvText quotes text that a spreadsheet would otherwise run as a formula.
+// Numbers, such as DECIMAL columns scanned as text, are written as they are
+// so negative values stay numeric.
+func csvText(text string) string {
+	if text != "" && strings.ContainsRune("=+-@\t\r", rune(text[0])) && !csvNumber.MatchString(text) {
+		return "'" + text
+	}
+	return text
+}
+
+// xlsxRowWriter writes through excelize's stream writer, which spills rows
+// to a temporary file instead of holding the sheet in memory.
+type xlsxRowWriter struct {
+	w           io.Writer
+	file        *excelize.File
+	stream      *excelize.StreamWriter
+	headerStyle int
+	row         int
+	values      []interface{}
+}
+
+func newXLSXRowWriter(w io.Writer) (*xlsxRowWriter, error) {
+	file := excelize.NewFile()
+	stream, err := file.NewStreamWriter("Sheet1")
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	headerStyle, err := file.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &xlsxRowWriter{w: w, file: file, stream: stream, headerStyle: headerStyle}, nil
+}
+
+func (x *xlsxRowWriter) WriteHeader(headers []string) error {
+	values := make([]interface{}, len(headers))
+	for i, header := range headers {
+		values[i] = header
+	}
+	return x.setRow(values, excelize.RowOpts{StyleID: x.headerStyle})
+}
+
+func (x *xlsxRowWriter) WriteRow(values []interface{}) error {
+	if x.row > xlsxMaxRows {
+		return fmt.Errorf("%w: xlsx sheets hold at most %d rows", ErrExportTooLarge, xlsxMaxRows)
+	}
+	x.values = x.values[:0]
+	for _, value := range values {
+		if b, ok := value.([]byte); ok {
+			value = string(b)
+		}
+		x.values = append(x.values, value)
+	}
+	return x.setRow(x.values)
+}
+
+func (x *xlsxRowWriter) setRow(values []interface{}, opts ...excelize.RowOpts) error {
+	x.row++
+	cell, err := excelize.CoordinatesToCellName(1, x.row)
+	if err != nil {
+		return err
+	}
+	return x.stream.SetRow(cell, values, opts...)
+}
+
+func (x *xlsxRowWriter) Close() error {
+	defer x.file.Close()
+	if err := x.stream.Flush(); err != nil {
+		return err
+	}
+	return x.file.Write(x.w)
+}
+
+// recordWriter is a RowWriter that takes the result's column names, field
+// names and aggregation aliases, as its header rather than display names,
+// and may want only the first rows of the result.
+type recordWriter interface {
+	RowWriter
+	// maxRecords is how many leading rows are wanted, or 0 for all of them.
+	maxRecords() int
+}
+
+func writeExportHeader(w RowWriter, dataset *models.Dataset, columns []string) error {
+	if _, ok := w.(recordWriter); ok {
+		return w.WriteHeader(columns)
+	}
+	return w.WriteHeader(exportHeaders(dataset, columns))
+}
+
+// wantedRows returns how many leading rows w wants, or 0 for all of them.
+func wantedRows(w RowWriter) int {
+	if records, ok := w.(recordWriter); ok {
+		return records.maxRecords()
+	}
+	return 0
+}
+
+// exportTimeout is the fallback timeout of a query writing to w. File
+// exports run as long as the request allows unless the datasource sets its
+// own timeout; records back charts, dashboards and reports, which get the
+// dataset query timeout.
+func exportTimeout(w RowWriter) time.Duration {
+	if _, ok := w.(recordWriter); ok {
+		return datasetQueryTimeout
+	}
+	return 0
+}
+
+// exportHeaders labels result columns with their fields' display names.
+func exportHeaders(dataset *models.Dataset, columns []string) []string {
+	displayNames := make(map[string]string, len(dataset.Fields))
+	for _, field := range dataset.Fields {
+		if field.DisplayName != nil && *field.DisplayName != "" {
+			displayNames[field.Name] = *field.DisplayName
+		}
+	}
+
+	headers := make([]string, len(columns))
+	for i, column := range columns {
+		if name, ok := displayNames[column]; ok {
+			headers[i] = name
+		} else {
+			headers[i] = column
+		}
+	}
+	return headers
+}
+
+// Export runs req without paging and streams every resulting row to w. SQL
+// datasets stream straight from the database cursor; other datasets are
+// already held in memory by their loaders. The row ceiling is checked before
+// the header is written.
+func (q *queryExecutor) Export(ctx context.Context, tenantID string, req *QueryRequest, w RowWriter) (int64, error) {
+	dataset, err := q.datasetRepo.GetByIDWithFields(ctx, req.DatasetID)
+	if err != nil || dataset.TenantID != tenantID {
+		return 0, ErrNotFound
+	}
+	if err := applyDrill(dataset, req); err != nil {
+		return 0, err
+	}
+	limit := exportRowLimit(tenantID)
+
+	if len(dataset.Sources) > 0 {
+		return q.exportJoinedDataset(ctx, dataset, req, limit, w)
+	}
+	if dataset.Type == "sql" && dataset.DatasourceID != nil {
+		return q.exportSQLDataset(ctx, dataset, req, limit, w)
+	}
+	if dataset.Type == "api" || dataset.Type == "file" {
+		rows, err := q.loadDatasetRows(ctx, dataset)
+		if err != nil {
+			return 0, err
+		}
+		return q.exportRows(ctx, dataset, rows, req, limit, w)
+	}
+
+	return 0, fmt.Errorf("unsupported dataset type: %s", dataset.Type)
+}
+
+func (q *queryExecutor) exportSQLDataset(ctx context.Context, dataset *models.Dataset, req *QueryRequest, limit int, w RowWriter) (int64, error) {
+	ds, err := q.datasourceRepo.GetByID(ctx, *dataset.DatasourceID)
+	if err != nil {
+		return 0, fmt.Errorf("datasource not found: %w", err)
+	}
+	baseQuery, err := sqlDatasetQuery(dataset)
+	if err != nil {
+		return 0, err
+	}
+
+	pool, err := q.pools.Acquire(ctx, ds)
+	if err != nil {
+		return 0, err
+	}
+	defer pool.Release()
+
+	queryCtx, cancel := pool.WithTimeout(ctx, exportTimeout(w))
+	defer cancel()
+	count, err := q.withDialect(pool.Dialect).streamSQLQuery(queryCtx, pool.DB, dataset, baseQuery, req, limit, w)
+	return count, pool.Check(err)
+}
+
+func (q *queryExecutor) exportJoinedDataset(ctx context.Context, dataset *models.Dataset, req *QueryRequest, limit int, w RowWriter) (int64, error) {
+	plan, err := buildJoinPlan(dataset.Sources)
+	if err != nil {
+		return 0, err
+	}
+
+	if datasourceID, ok := sqlJoinDatasourceID(plan); ok {
+		ds, err := q.datasourceRepo.GetByID(ctx, datasourceID)
+		if err != nil || ds.TenantID != dataset.TenantID {
+			return 0, errors.New("datasource not found")
+		}
+		pool, err := q.pools.Acquire(ctx, ds)
+		if err != nil {
+			return 0, err
+		}
+		defer pool.Release()
+		queryCtx, cancel := pool.WithTimeout(ctx, exportTimeout(w))
+		defer cancel()
+
+		baseQuery, err := buildSQLJoin(queryCtx, pool.DB, pool.Dialect, plan)
+		if err != nil {
+			return 0, pool.Check(err)
+		}
+		count, err := q.withDialect(pool.Dialect).streamSQLQuery(queryCtx, pool.DB, dataset, baseQuery, req, limit, w)
+		return count, pool.Check(err)
+	}
+
+	_, rows, err := q.sourceLoader().loadJoined(ctx, dataset.TenantID, plan, datasetJoinMaxSourceRows, true)
+	if err != nil {
+		return 0, err
+	}
+	return q.exportRows(ctx, dataset, rows, req, limit, w)
+}
+
+// streamSQLQuery counts the request's rows against limit, then copies them
+// from the cursor to w one at a time.
+func (q *queryExecutor) streamSQLQuery(ctx context.Context, db *sql.DB, dataset *models.Dataset, baseQuery string, req *QueryRequest, limit int, w RowWriter) (int64, error) {
+	stmt, err := q.compileSQLQuery(dataset, baseQuery, req)
+	if err != nil {
+		return 0, err
+	}
+
+	query := stmt.query()
+	if wanted := wantedRows(w); wanted > 0 && (limit <= 0 || wanted <= limit) {
+		// Only the leading rows are read, so the row ceiling cannot be hit.
+		query = q.sqlDialect().Limit(query, wanted, 0)
+	} else if limit > 0 {
+		// Grouping changes the row count, so count the export query itself.
+		countQuery := fmt.Sprintf("SELECT COUNT(*) FROM (SELECT %s FROM (%s) AS dataset_query %s %s) AS export_query",
+			stmt.selectClause, stmt.baseQuery, stmt.whereClause, stmt.groupByClause)
+		var total int64
+		if err := db.QueryRowContext(ctx, countQuery, stmt.args...).Scan(&total); err != nil {
+			return 0, exportQueryError(err)
+		}
+		if total > int64(limit) {
+			return 0, fmt.Errorf("%w: %d rows match, the limit is %d", ErrExportTooLarge, total, limit)
+		}
+		// Rows added since the count are cut off rather than overrunning.
+		query = q.sqlDialect().Limit(query, limit, 0)
+	}
+
+	rows, err := db.QueryContext(ctx, query, stmt.args...)
+	if err != nil {
+		return 0, exportQueryError(err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	if err := writeExportHeader(w, dataset, columns); err != nil {
+		return 0, err
+	}
+
+	values := make([]interface{}, len(columns))
+	valuePtrs := make([]interface{}, len(columns))
+	for i := range values {
+		valuePtrs[i] = &values[i]
+	}
+
+	var written int64
+	for rows.Next() {
+		if err := rows.Scan(valuePtrs...); err != nil {
+			return written, err
+		}
+		if err := w.WriteRow(values); err != nil {
+			return written, err
+		}
+		written++
+	}
+	if err := rows.Err(); err != nil {
+		return written, exportQueryError(err)
+	}
+	return written, nil
+}
+
+// exportRows evaluates req over rows held in memory and writes the result.
+func (q *queryExecutor) exportRows(ctx context.Context, dataset *models.Dataset, rows []map[string]interface{}, req *QueryRequest, limit int, w RowWriter) (int64, error) {
+	result, err := evaluateInMemoryQuery(dataset, rows, req, q.apiBuilder)
+	if err != nil {
+		return 0, err
+	}
+	if wanted := wantedRows(w); wanted > 0 && len(result.rows) > wanted {
+		result.rows = result.rows[:wanted]
+	}
+	if limit > 0 && len(result.rows) > limit {
+		return 0, fmt.Errorf("%w: %d rows match, the limit is %d", ErrExportTooLarge, len(result.rows), limit)
+	}
+
+	if err := writeExportHeader(w, dataset, result.columns); err != nil {
+		return 0, err
+	}
+	values := make([]interface{}, len(result.columns))
+	var written int64
+	for _, row := range result.rows {
+		if err := ctx.Err(); err != nil {
+			return written, err
+		}
+		for i, column := range result.columns {
+			values[i] = row[column]
+		}
+		if err := w.WriteRow(values); err != nil {
+			return written, err
+		}
+		written++
+	}
+	return written, nil
+}
+
+func exportQueryError(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return errors.New("export query timeout")
+	}
+	return fmt.Errorf("export query failed: %w", err)
+}
@@ -0,0 +1,148 @@
+package dataset
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gujiaweiguo/goreport/internal/config"
+	"github.com/gujiaweiguo/goreport/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xuri/excelize/v2"
+)
+
+func useExportLimits(t *testing.T, tenantMaxRows map[string]int) {
+	t.Helper()
+	previous := exportLimits
+	InitExport(&config.ExportConfig{MaxRows: defaultExportMaxRows, TenantMaxRows: tenantMaxRows})
+	t.Cleanup(func() { exportLimits = previous })
+}
+
+func TestCSVRowWriter(t *testing.T) {
+	var out strings.Builder
+	w := newCSVRowWriter(&out)
+
+	require.NoError(t, w.WriteHeader([]string{"名称", "amount", "day", "at", "note"}))
+	require.NoError(t, w.WriteRow([]interface{}{
+		[]byte("east"),
+		float64(1.5),
+		time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 3, 1, 8, 30, 0, 0, time.UTC),
+		nil,
+	}))
+	require.NoError(t, w.WriteRow([]interface{}{"a,b", int64(2), nil, nil, `say "hi"`}))
+	require.NoError(t, w.WriteRow([]interface{}{"=HYPERLINK(\"x\")", float64(-3), "+1", []byte("@SUM(A1)"), "-2"}))
+	require.NoError(t, w.WriteRow([]interface{}{"-1+2", []byte("-12.50"), "+A1", []byte("-2+3"), "-1e3"}))
+	require.NoError(t, w.Close())
+
+	assert.Equal(t, "\ufeff名称,amount,day,at,note\n"+
+		"east,1.5,2024-03-01,2024-03-01 08:30:00,\n"+
+		`"a,b",2,,,"say ""hi"""`+"\n"+
+		`"'=HYPERLINK(""x"")",-3,+1,'@SUM(A1),-2`+"\n"+
+		`'-1+2,-12.50,'+A1,'-2+3,-1e3`+"\n", out.String())
+}
+
+func TestXLSXRowWriter(t *testing.T) {
+	var out bytes.Buffer
+	w, err := NewRowWriter(ExportFormatXLSX, &out)
+	require.NoError(t, err)
+
+	require.NoError(t, w.WriteHeader([]string{"region", "amount"}))
+	require.NoError(t, w.WriteRow([]interface{}{[]byte("east"), float64(120.5)}))
+	require.NoError(t, w.WriteRow([]interface{}{"west", int64(80)}))
+	assert.Zero(t, out.Len(), "nothing is sent before Close")
+	require.NoError(t, w.Close())
+
+	file, err := excelize.OpenReader(&out)
+	require.NoError(t, err)
+	defer file.Close()
+
+	rows, err := file.GetRows("Sheet1")
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"region", "amount"}, {"east", "120.5"}, {"west", "80"}}, rows)
+
+	cellType, err := file.GetCellType("Sheet1", "B2")
+	require.NoError(t, err)
+	assert.NotEqual(t, excelize.CellTypeSharedString, cellType)
+	assert.NotEqual(t, excelize.CellTypeInlineString, cellType)
+
+	_, err = NewRowWriter("pdf", &out)
+	assert.ErrorContains(t, err, "unsupported export format")
+}
+
+func TestExportHeaders(t *testing.T) {
+	display := "Region"
+	empty := ""
+	dataset := &models.Dataset{Fields: []models.DatasetField{
+		{Name: "region", DisplayName: &display},
+		{Name: "amount", DisplayName: &empty},
+	}}
+	assert.Equal(t, []string{"Region", "amount", "orders"}, exportHeaders(dataset, []string{"region", "amount", "orders"}))
+}
+
+func TestExportTimeout(t *testing.T) {
+	var out strings.Builder
+	assert.Zero(t, exportTimeout(newCSVRowWriter(&out)), "file exports are bounded by the request")
+	assert.Equal(t, datasetQueryTimeout, exportTimeout(&recordCollector{}))
+	assert.Equal(t, datasetQueryTimeout, exportTimeout(&recordCollector{max: 10}))
+}
+
+func TestQueryExecutor_Export_APIDataset(t *testing.T) {
+	server := newAPITestServer(t, nil)
+	dataset := apiTestDataset(`{"url":"`+server.URL+`","dataPath":"data.items"}`, nil)
+	regionName := "Region"
+	dataset.Fields[1].DisplayName = &regionName
+	executor := newAPITestExecutor(dataset, nil)
+
+	var out strings.Builder
+	writer := newCSVRowWriter(&out)
+	written, err := executor.Export(context.Background(), "tenant-1", &QueryRequest{
+		DatasetID:    "api-1",
+		GroupBy:      []string{"region"},
+		Aggregations: map[string]Aggregation{"amount": {Function: "SUM", Field: "amount"}},
+		SortBy:       "region",
+		SortOrder:    "asc",
+		PageSize:     1,
+	}, writer)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	assert.Equal(t, int64(3), written, "paging does not apply to exports")
+	assert.Equal(t, "\ufeffRegion,amount\neast,160.5\nnorth,\nwest,80\n", out.String())
+
+	out.Reset()
+	writer = newCSVRowWriter(&out)
+	_, err = executor.Export(context.Background(), "tenant-1", &QueryRequest{DatasetID: "api-1", Fields: []string{"id", "total"}}, writer)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	assert.Equal(t, "\ufeffid,total\n1,241\n2,80\n3,160\n4,\n", out.String())
+}
+
+func TestQueryExecutor_Export_Limits(t *testing.T) {
+	server := newAPITestServer(t, nil)
+	dataset := apiTestDataset(`{"url":"`+server.URL+`","dataPath":"data.items"}`, nil)
+	executor := newAPITestExecutor(dataset, nil)
+	useExportLimits(t, map[string]int{"tenant-1": 3})
+
+	var out strings.Builder
+	_, err := executor.Export(context.Background(), "tenant-1", &QueryRequest{DatasetID: "api-1"}, newCSVRowWriter(&out))
+	assert.ErrorIs(t, err, ErrExportTooLarge)
+	assert.Empty(t, out.String(), "the limit is checked before the header")
+
+	written, err := executor.Export(context.Background(), "tenant-1", &QueryRequest{
+		DatasetID: "api-1",
+		Filters:   []Filter{{Field: "region", Operator: "eq", Value: "east"}},
+	}, newCSVRowWriter(&out))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), written)
+
+	_, err = executor.Export(context.Background(), "tenant-2", &QueryRequest{DatasetID: "api-1"}, newCSVRowWriter(&out))
+	assert.EqualError(t, err, "dataset not found")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = executor.Export(ctx, "tenant-1", &QueryRequest{DatasetID: "api-1", Filters: []Filter{{Field: "region", Operator: "eq", Value: "east"}}}, newCSVRowWriter(&out))
+	assert.ErrorIs(t, err, context.Canceled)
+}
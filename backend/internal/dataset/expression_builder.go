@@ -2,90 +2,71 @@ package dataset
 
 import (
 	"fmt"
-	"regexp"
 	"strings"
+
+	"github.com/gujiaweiguo/goreport/internal/datasource"
+	"github.com/gujiaweiguo/goreport/internal/models"
 )
 
 type SQLExpressionBuilder interface {
 	Build(expression string, fields []string) (string, error)
+	BuildFields(expression string, fields []models.DatasetField) (string, error)
+	// WithDialect returns a builder that renders for the datasource's dialect.
+	WithDialect(dialect datasource.Dialect) SQLExpressionBuilder
 	Validate(expression string, fields []string) error
+	ValidateFields(expression string, fields []models.DatasetField) error
 	SubstituteFieldReferences(expression string, fieldMapping map[string]string) string
 	TranslateFunction(expression string, databaseType string) (string, error)
 }
 
 type sqlExpressionBuilder struct {
-	functionMap map[string]string
+	dialect datasource.Dialect
 }
 
+// NewSQLExpressionBuilder returns a builder for MySQL, the dialect of
+// datasources without a type.
 func NewSQLExpressionBuilder() SQLExpressionBuilder {
-	return &sqlExpressionBuilder{
-		functionMap: map[string]string{
-			"CONCAT":      "CONCAT",
-			"SUBSTRING":   "SUBSTRING",
-			"LENGTH":      "LENGTH",
-			"UPPER":       "UPPER",
-			"LOWER":       "LOWER",
-			"TRIM":        "TRIM",
-			"DATE_FORMAT": "DATE_FORMAT",
-			"DATE_ADD":    "DATE_ADD",
-			"DATE_SUB":    "DATE_SUB",
-			"DATEDIFF":    "DATEDIFF",
-			"NOW":         "NOW",
-			"CURDATE":     "CURDATE",
-			"CURTIME":     "CURTIME",
-			"YEAR":        "YEAR",
-			"MONTH":       "MONTH",
-			"DAY":         "DAY",
-			"HOUR":        "HOUR",
-			"MINUTE":      "MINUTE",
-			"SECOND":      "SECOND",
-			"ROUND":       "ROUND",
-			"CEIL":        "CEIL",
-			"FLOOR":       "FLOOR",
-			"ABS":         "ABS",
-			"SUM":         "SUM",
-			"AVG":         "AVG",
-			"COUNT":       "COUNT",
-			"MAX":         "MAX",
-			"MIN":         "MIN",
-			"IF":          "IF",
-			"CASE":        "CASE",
-		},
-	}
+	return &sqlExpressionBuilder{dialect: datasource.DefaultDialect()}
+}
+
+func (b *sqlExpressionBuilder) WithDialect(dialect datasource.Dialect) SQLExpressionBuilder {
+	return &sqlExpressionBuilder{dialect: dialect}
 }
 
+// Build renders the expression against untyped fields.
 func (b *sqlExpressionBuilder) Build(expression string, fields []string) (string, error) {
-	translated, err := b.TranslateFunction(expression, "mysql")
+	return b.build(expression, schemaFromNames(fields))
+}
+
+// BuildFields renders the expression against the dataset's fields.
+// References to other computed fields are inlined.
+func (b *sqlExpressionBuilder) BuildFields(expression string, fields []models.DatasetField) (string, error) {
+	return b.build(expression, schemaFromDatasetFields(fields))
+}
+
+func (b *sqlExpressionBuilder) build(expression string, schema *expressionSchema) (string, error) {
+	node, _, err := schema.compile(expression)
+	if err != nil {
+		return "", err
+	}
+
+	sql, err := renderExpressionSQL(node, b.dialect)
 	if err != nil {
 		return "", err
 	}
 
-	return fmt.Sprintf("(%s)", translated), nil
+	return fmt.Sprintf("(%s)", sql), nil
 }
 
 func (b *sqlExpressionBuilder) Validate(expression string, fields []string) error {
-	if expression == "" {
-		return fmt.Errorf("expression cannot be empty")
-	}
-
-	fieldPattern := regexp.MustCompile(`\[(\w+)\]`)
-	matches := fieldPattern.FindAllString(expression, -1)
-
-	for _, match := range matches {
-		fieldName := strings.Trim(match, "[]")
-		found := false
-		for _, field := range fields {
-			if field == fieldName {
-				found = true
-				break
-			}
-		}
-		if !found {
-			return fmt.Errorf("field reference '%s' not found in dataset fields", match)
-		}
-	}
+	_, _, err := schemaFromNames(fields).compile(expression)
+	return err
+}
 
-	return nil
+// ValidateFields type checks the expression against the dataset's fields.
+func (b *sqlExpressionBuilder) ValidateFields(expression string, fields []models.DatasetField) error {
+	_, _, err := schemaFromDatasetFields(fields).compile(expression)
+	return err
 }
 
 func (b *sqlExpressionBuilder) SubstituteFieldReferences(expression string, fieldMapping map[string]string) string {
@@ -96,15 +77,18 @@ func (b *sqlExpressionBuilder) SubstituteFieldReferences(expression string, fiel
 	return result
 }
 
+// TranslateFunction renders the expression for the given datasource type
+// without resolving field references.
 func (b *sqlExpressionBuilder) TranslateFunction(expression string, databaseType string) (string, error) {
-	result := expression
+	dialect, err := datasource.GetDialect(databaseType)
+	if err != nil {
+		return "", err
+	}
 
-	for genericFunc, sqlFunc := range b.functionMap {
-		pattern := fmt.Sprintf(`\b%s\b`, genericFunc)
-		if databaseType == "mysql" {
-			result = strings.ReplaceAll(result, pattern, sqlFunc)
-		}
+	node, err := parseExpression(expression)
+	if err != nil {
+		return "", err
 	}
 
-	return result, nil
+	return renderExpressionSQL(node, dialect)
 }
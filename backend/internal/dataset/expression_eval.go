@@ -0,0 +1,641 @@
+package dataset
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gujiaweiguo/goreport/internal/datasource"
+)
+
+// evaluateExpression computes a checked expression tree against one row.
+// Values are normalized to nil, float64, string, bool or time.Time, and NULL
+// propagates the way it does in SQL so API and SQL datasets agree.
+func evaluateExpression(node exprNode, row map[string]interface{}) (interface{}, error) {
+	switch n := node.(type) {
+	case *literalNode:
+		switch n.kind {
+		case literalNumber:
+			return n.number, nil
+		case literalString:
+			return n.text, nil
+		case literalBool:
+			return n.bool, nil
+		default:
+			return nil, nil
+		}
+	case *fieldNode:
+		value, ok := row[n.name]
+		if !ok {
+			return nil, exprErrorf(n.pos, "unresolved field reference [%s]", n.name)
+		}
+		return normalizeExpressionValue(value), nil
+	case *starNode:
+		return float64(1), nil
+	case *unaryNode:
+		operand, err := evaluateExpression(n.operand, row)
+		if err != nil || operand == nil {
+			return nil, err
+		}
+		if n.op == "NOT" {
+			truth, err := expressionBool(operand, n.operand.position())
+			if err != nil {
+				return nil, err
+			}
+			return !truth, nil
+		}
+		number, err := expressionNumber(operand, n.operand.position())
+		if err != nil {
+			return nil, err
+		}
+		return -number, nil
+	case *isNullNode:
+		operand, err := evaluateExpression(n.operand, row)
+		if err != nil {
+			return nil, err
+		}
+		return (operand == nil) != n.negated, nil
+	case *binaryNode:
+		return evaluateBinary(n, row)
+	case *callNode:
+		args := make([]interface{}, len(n.args))
+		for i, arg := range n.args {
+			value, err := evaluateExpression(arg, row)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = value
+		}
+		return evaluateCall(n, args)
+	case *caseNode:
+		return evaluateCase(n, row)
+	}
+	return nil, fmt.Errorf("unsupported expression node %T", node)
+}
+
+func evaluateBinary(n *binaryNode, row map[string]interface{}) (interface{}, error) {
+	left, err := evaluateExpression(n.left, row)
+	if err != nil {
+		return nil, err
+	}
+	right, err := evaluateExpression(n.right, row)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "AND", "OR":
+		return evaluateLogical(n, left, right)
+	}
+
+	if left == nil || right == nil {
+		return nil, nil
+	}
+
+	switch n.op {
+	case "+", "-", "*", "/", "%":
+		a, err := expressionNumber(left, n.left.position())
+		if err != nil {
+			return nil, err
+		}
+		b, err := expressionNumber(right, n.right.position())
+		if err != nil {
+			return nil, err
+		}
+		switch n.op {
+		case "+":
+			return a + b, nil
+		case "-":
+			return a - b, nil
+		case "*":
+			return a * b, nil
+		case "/":
+			if b == 0 {
+				return nil, nil
+			}
+			return a / b, nil
+		default:
+			if b == 0 {
+				return nil, nil
+			}
+			return math.Mod(a, b), nil
+		}
+	}
+
+	cmp, err := compareExpressionValues(left, right, n.right.position())
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "=":
+		return cmp == 0, nil
+	case "<>":
+		return cmp != 0, nil
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	default:
+		return cmp >= 0, nil
+	}
+}
+
+// evaluateLogical applies SQL three-valued logic.
+func evaluateLogical(n *binaryNode, left, right interface{}) (interface{}, error) {
+	var values [2]*bool
+	for i, operand := range []struct {
+		value interface{}
+		pos   int
+	}{{left, n.left.position()}, {right, n.right.position()}} {
+		if operand.value == nil {
+			continue
+		}
+		truth, err := expressionBool(operand.value, operand.pos)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = &truth
+	}
+
+	decisive := n.op == "OR"
+	for _, value := range values {
+		if value != nil && *value == decisive {
+			return decisive, nil
+		}
+	}
+	if values[0] == nil || values[1] == nil {
+		return nil, nil
+	}
+	return !decisive, nil
+}
+
+func evaluateCase(n *caseNode, row map[string]interface{}) (interface{}, error) {
+	var operand interface{}
+	if n.operand != nil {
+		var err error
+		if operand, err = evaluateExpression(n.operand, row); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, when := range n.whens {
+		cond, err := evaluateExpression(when.cond, row)
+		if err != nil {
+			return nil, err
+		}
+
+		matched := false
+		if n.operand != nil {
+			if operand != nil && cond != nil {
+				cmp, err := compareExpressionValues(operand, cond, when.cond.position())
+				if err != nil {
+					return nil, err
+				}
+				matched = cmp == 0
+			}
+		} else if cond != nil {
+			if matched, err = expressionBool(cond, when.cond.position()); err != nil {
+				return nil, err
+			}
+		}
+
+		if matched {
+			return evaluateExpression(when.result, row)
+		}
+	}
+
+	if n.elseExpr != nil {
+		return evaluateExpression(n.elseExpr, row)
+	}
+	return nil, nil
+}
+
+func evaluateCall(n *callNode, args []interface{}) (interface{}, error) {
+	argPos := func(i int) int { return n.args[i].position() }
+
+	switch n.name {
+	case "COALESCE":
+		for _, arg := range args {
+			if arg != nil {
+				return arg, nil
+			}
+		}
+		return nil, nil
+	case "IF":
+		if args[0] != nil {
+			truth, err := expressionBool(args[0], argPos(0))
+			if err != nil {
+				return nil, err
+			}
+			if truth {
+				return args[1], nil
+			}
+		}
+		return args[2], nil
+	case "COUNT":
+		if args[0] == nil {
+			return float64(0), nil
+		}
+		return float64(1), nil
+	case "NOW":
+		return time.Now(), nil
+	case "CURDATE":
+		now := time.Now()
+		return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()), nil
+	case "CURTIME":
+		return time.Now().Format("15:04:05"), nil
+	}
+
+	// Every remaining function returns NULL when any argument is NULL.
+	for _, arg := range args {
+		if arg == nil {
+			return nil, nil
+		}
+	}
+
+	switch n.name {
+	case "CONCAT":
+		var result strings.Builder
+		for _, arg := range args {
+			result.WriteString(formatExpressionValue(arg))
+		}
+		return result.String(), nil
+	case "SUBSTRING":
+		return evaluateSubstring(n, args)
+	case "LENGTH":
+		return float64(utf8.RuneCountInString(formatExpressionValue(args[0]))), nil
+	case "UPPER":
+		return strings.ToUpper(formatExpressionValue(args[0])), nil
+	case "LOWER":
+		return strings.ToLower(formatExpressionValue(args[0])), nil
+	case "TRIM":
+		return strings.TrimSpace(formatExpressionValue(args[0])), nil
+	case "SUM", "AVG", "MAX", "MIN":
+		// Row-level evaluation sees a single value per group.
+		return args[0], nil
+	case "DATE_FORMAT":
+		date, err := expressionTime(args[0], argPos(0))
+		if err != nil {
+			return nil, err
+		}
+		parts, err := datasource.ParseDateFormat(formatExpressionValue(args[1]))
+		if err != nil {
+			return nil, exprErrorf(argPos(1), "%v", err)
+		}
+		return formatMySQLDate(date, parts), nil
+	case "DATE_ADD", "DATE_SUB":
+		return evaluateDateArithmetic(n, args)
+	case "DATEDIFF":
+		end, err := expressionTime(args[0], argPos(0))
+		if err != nil {
+			return nil, err
+		}
+		start, err := expressionTime(args[1], argPos(1))
+		if err != nil {
+			return nil, err
+		}
+		endDay := time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, time.UTC)
+		startDay := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+		return math.Round(endDay.Sub(startDay).Hours() / 24), nil
+	case "YEAR", "MONTH", "DAY", "HOUR", "MINUTE", "SECOND":
+		date, err := expressionTime(args[0], argPos(0))
+		if err != nil {
+			return nil, err
+		}
+		return float64(datePart(date, n.name)), nil
+	}
+
+	numbers := make([]float64, len(args))
+	for i, arg := range args {
+		number, err := expressionNumber(arg, argPos(i))
+		if err != nil {
+			return nil, err
+		}
+		numbers[i] = number
+	}
+
+	switch n.name {
+	case "ROUND":
+		scale := 1.0
+		if len(numbers) > 1 {
+			scale = math.Pow(10, math.Round(numbers[1]))
+		}
+		return math.Round(numbers[0]*scale) / scale, nil
+	case "CEIL":
+		return math.Ceil(numbers[0]), nil
+	case "FLOOR":
+		return math.Floor(numbers[0]), nil
+	case "ABS":
+		return math.Abs(numbers[0]), nil
+	}
+
+	return nil, exprErrorf(n.pos, "function %s cannot be evaluated", n.name)
+}
+
+// evaluateSubstring follows SQL: positions are 1-based characters and a
+// negative start counts from the end of the string.
+func evaluateSubstring(n *callNode, args []interface{}) (interface{}, error) {
+	runes := []rune(formatExpressionValue(args[0]))
+	startValue, err := expressionNumber(args[1], n.args[1].position())
+	if err != nil {
+		return nil, err
+	}
+
+	start := int(startValue)
+	switch {
+	case start > 0:
+		start--
+	case start < 0:
+		start += len(runes)
+	default:
+		return "", nil
+	}
+	if start < 0 || start >= len(runes) {
+		return "", nil
+	}
+
+	end := len(runes)
+	if len(args) > 2 {
+		length, err := expressionNumber(args[2], n.args[2].position())
+		if err != nil {
+			return nil, err
+		}
+		if length <= 0 {
+			return "", nil
+		}
+		if start+int(length) < end {
+			end = start + int(length)
+		}
+	}
+	return string(runes[start:end]), nil
+}
+
+func evaluateDateArithmetic(n *callNode, args []interface{}) (interface{}, error) {
+	date, err := expressionTime(args[0], n.args[0].position())
+	if err != nil {
+		return nil, err
+	}
+	amount, err := expressionNumber(args[1], n.args[1].position())
+	if err != nil {
+		return nil, err
+	}
+	if n.name == "DATE_SUB" {
+		amount = -amount
+	}
+
+	unit := "day"
+	if len(args) > 2 {
+		normalized, ok := datasource.DateUnit(formatExpressionValue(args[2]))
+		if !ok {
+			return nil, exprErrorf(n.args[2].position(), "%s does not support unit %q", n.name, args[2])
+		}
+		unit = normalized
+	}
+
+	count := int(math.Round(amount))
+	switch unit {
+	case "second":
+		return date.Add(time.Duration(count) * time.Second), nil
+	case "minute":
+		return date.Add(time.Duration(count) * time.Minute), nil
+	case "hour":
+		return date.Add(time.Duration(count) * time.Hour), nil
+	case "day":
+		return date.AddDate(0, 0, count), nil
+	case "week":
+		return date.AddDate(0, 0, 7*count), nil
+	case "month":
+		return addMonths(date, count), nil
+	default:
+		return addMonths(date, 12*count), nil
+	}
+}
+
+// addMonths clamps to the last day of the target month like SQL engines do,
+// rather than overflowing into the next month as time.AddDate does.
+func addMonths(date time.Time, months int) time.Time {
+	first := time.Date(date.Year(), date.Month(), 1, date.Hour(), date.Minute(), date.Second(), date.Nanosecond(), date.Location())
+	target := first.AddDate(0, months, 0)
+	lastDay := target.AddDate(0, 1, -1).Day()
+	day := date.Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return target.AddDate(0, 0, day-1)
+}
+
+func datePart(date time.Time, part string) int {
+	switch part {
+	case "YEAR":
+		return date.Year()
+	case "MONTH":
+		return int(date.Month())
+	case "DAY":
+		return date.Day()
+	case "HOUR":
+		return date.Hour()
+	case "MINUTE":
+		return date.Minute()
+	default:
+		return date.Second()
+	}
+}
+
+func formatMySQLDate(date time.Time, parts []datasource.DateFormatPart) string {
+	var out strings.Builder
+	for _, part := range parts {
+		switch part.Specifier {
+		case 0:
+			out.WriteString(part.Literal)
+		case 'Y':
+			out.WriteString(date.Format("2006"))
+		case 'y':
+			out.WriteString(date.Format("06"))
+		case 'm':
+			out.WriteString(date.Format("01"))
+		case 'd':
+			out.WriteString(date.Format("02"))
+		case 'H':
+			out.WriteString(date.Format("15"))
+		case 'h':
+			out.WriteString(date.Format("03"))
+		case 'i':
+			out.WriteString(date.Format("04"))
+		case 's', 'S':
+			out.WriteString(date.Format("05"))
+		case 'M':
+			out.WriteString(date.Format("January"))
+		case 'b':
+			out.WriteString(date.Format("Jan"))
+		case 'p':
+			out.WriteString(date.Format("PM"))
+		}
+	}
+	return out.String()
+}
+
+func normalizeExpressionValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil, string, bool, float64, time.Time:
+		return v
+	case *time.Time:
+		if v == nil {
+			return nil
+		}
+		return *v
+	case float32:
+		return float64(v)
+	case int:
+		return float64(v)
+	case int8:
+		return float64(v)
+	case int16:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case uint:
+		return float64(v)
+	case uint8:
+		return float64(v)
+	case uint16:
+		return float64(v)
+	case uint32:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	case json.Number:
+		if number, err := v.Float64(); err == nil {
+			return number
+		}
+		return v.String()
+	case []byte:
+		return string(v)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+func formatExpressionValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		return v.Format("2006-01-02 15:04:05")
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+func expressionNumber(value interface{}, pos int) (float64, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	case string:
+		if number, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+			return number, nil
+		}
+	}
+	return 0, &ExpressionError{Pos: pos, Message: fmt.Sprintf("cannot use %q as a number", formatExpressionValue(value)), Expected: typeNumber}
+}
+
+func expressionBool(value interface{}, pos int) (bool, error) {
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case float64:
+		return v != 0, nil
+	case string:
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "true", "1":
+			return true, nil
+		case "false", "0", "":
+			return false, nil
+		}
+	}
+	return false, &ExpressionError{Pos: pos, Message: fmt.Sprintf("cannot use %q as a boolean", formatExpressionValue(value)), Expected: typeBoolean}
+}
+
+var expressionTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+func expressionTime(value interface{}, pos int) (time.Time, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		trimmed := strings.TrimSpace(v)
+		for _, layout := range expressionTimeLayouts {
+			if parsed, err := time.ParseInLocation(layout, trimmed, time.Local); err == nil {
+				return parsed, nil
+			}
+		}
+	}
+	return time.Time{}, &ExpressionError{Pos: pos, Message: fmt.Sprintf("cannot use %q as a date", formatExpressionValue(value)), Expected: typeDate}
+}
+
+// compareExpressionValues orders two non-NULL values, coercing the way SQL
+// does: numbers win over strings and dates win over strings.
+func compareExpressionValues(left, right interface{}, pos int) (int, error) {
+	_, leftNumber := left.(float64)
+	_, rightNumber := right.(float64)
+	_, leftTime := left.(time.Time)
+	_, rightTime := right.(time.Time)
+
+	switch {
+	case leftNumber || rightNumber:
+		a, err := expressionNumber(left, pos)
+		if err != nil {
+			return 0, err
+		}
+		b, err := expressionNumber(right, pos)
+		if err != nil {
+			return 0, err
+		}
+		return compareOrdered(a, b), nil
+	case leftTime || rightTime:
+		a, err := expressionTime(left, pos)
+		if err != nil {
+			return 0, err
+		}
+		b, err := expressionTime(right, pos)
+		if err != nil {
+			return 0, err
+		}
+		return a.Compare(b), nil
+	default:
+		return strings.Compare(formatExpressionValue(left), formatExpressionValue(right)), nil
+	}
+}
+
+func compareOrdered(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,606 @@
+package dataset
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ExpressionError reports a problem in a computed-field expression. Pos is
+// the 1-based character offset the problem was detected at.
+type ExpressionError struct {
+	Pos      int
+	Message  string
+	Expected exprType
+}
+
+func (e *ExpressionError) Error() string {
+	if e.Expected != "" {
+		return fmt.Sprintf("position %d: %s (expected %s)", e.Pos, e.Message, e.Expected)
+	}
+	return fmt.Sprintf("position %d: %s", e.Pos, e.Message)
+}
+
+func exprErrorf(pos int, format string, args ...interface{}) *ExpressionError {
+	return &ExpressionError{Pos: pos, Message: fmt.Sprintf(format, args...)}
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenString
+	tokenField
+	tokenIdent
+	tokenOperator
+	tokenLParen
+	tokenRParen
+	tokenComma
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	pos   int
+	upper string
+}
+
+var expressionKeywords = map[string]bool{
+	"AND": true, "OR": true, "NOT": true, "IS": true, "NULL": true,
+	"TRUE": true, "FALSE": true, "CASE": true, "WHEN": true, "THEN": true,
+	"ELSE": true, "END": true,
+}
+
+func tokenizeExpression(expression string) ([]token, error) {
+	runes := []rune(expression)
+	var tokens []token
+
+	for i := 0; i < len(runes); {
+		ch := runes[i]
+		pos := i + 1
+
+		switch {
+		case unicode.IsSpace(ch):
+			i++
+		case ch >= '0' && ch <= '9' || ch == '.' && i+1 < len(runes) && runes[i+1] >= '0' && runes[i+1] <= '9':
+			start := i
+			seenDot := false
+			for i < len(runes) && (runes[i] >= '0' && runes[i] <= '9' || runes[i] == '.' && !seenDot) {
+				if runes[i] == '.' {
+					seenDot = true
+				}
+				i++
+			}
+			if i < len(runes) && (unicode.IsLetter(runes[i]) || runes[i] == '_') {
+				return nil, exprErrorf(i+1, "unexpected character %q in number", runes[i])
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: string(runes[start:i]), pos: pos})
+		case ch == '\'':
+			var value strings.Builder
+			i++
+			closed := false
+			for i < len(runes) {
+				if runes[i] == '\'' {
+					if i+1 < len(runes) && runes[i+1] == '\'' {
+						value.WriteRune('\'')
+						i += 2
+						continue
+					}
+					i++
+					closed = true
+					break
+				}
+				value.WriteRune(runes[i])
+				i++
+			}
+			if !closed {
+				return nil, exprErrorf(pos, "unterminated string literal")
+			}
+			tokens = append(tokens, token{kind: tokenString, text: value.String(), pos: pos})
+		case ch == '[':
+			end := i + 1
+			for end < len(runes) && runes[end] != ']' {
+				end++
+			}
+			if end >= len(runes) {
+				return nil, exprErrorf(pos, "unterminated field reference")
+			}
+			name := strings.TrimSpace(string(runes[i+1 : end]))
+			if name == "" {
+				return nil, exprErrorf(pos, "empty field reference")
+			}
+			tokens = append(tokens, token{kind: tokenField, text: name, pos: pos})
+			i = end + 1
+		case unicode.IsLetter(ch) || ch == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+			text := string(runes[start:i])
+			tokens = append(tokens, token{kind: tokenIdent, text: text, upper: strings.ToUpper(text), pos: pos})
+		case ch == '(':
+			tokens = append(tokens, token{kind: tokenLParen, text: "(", pos: pos})
+			i++
+		case ch == ')':
+			tokens = append(tokens, token{kind: tokenRParen, text: ")", pos: pos})
+			i++
+		case ch == ',':
+			tokens = append(tokens, token{kind: tokenComma, text: ",", pos: pos})
+			i++
+		default:
+			op := ""
+			if i+1 < len(runes) {
+				switch two := string(runes[i : i+2]); two {
+				case "<=", ">=", "<>", "!=", "==":
+					op = two
+				}
+			}
+			if op == "" && strings.ContainsRune("+-*/%<>=", ch) {
+				op = string(ch)
+			}
+			if op == "" {
+				return nil, exprErrorf(pos, "unexpected character %q", ch)
+			}
+			tokens = append(tokens, token{kind: tokenOperator, text: op, pos: pos})
+			i += len([]rune(op))
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF, pos: len(runes) + 1}), nil
+}
+
+// exprNode is a node of a parsed computed-field expression.
+type exprNode interface {
+	position() int
+}
+
+type literalKind int
+
+const (
+	literalNumber literalKind = iota
+	literalString
+	literalBool
+	literalNull
+)
+
+type literalNode struct {
+	pos    int
+	kind   literalKind
+	text   string
+	number float64
+	bool   bool
+}
+
+type fieldNode struct {
+	pos  int
+	name string
+}
+
+// starNode is the '*' argument of COUNT(*).
+type starNode struct {
+	pos int
+}
+
+type unaryNode struct {
+	pos     int
+	op      string
+	operand exprNode
+}
+
+type binaryNode struct {
+	pos   int
+	op    string
+	left  exprNode
+	right exprNode
+}
+
+// isNullNode is "x IS NULL" or, when negated, "x IS NOT NULL".
+type isNullNode struct {
+	pos     int
+	operand exprNode
+	negated bool
+}
+
+type callNode struct {
+	pos  int
+	name string
+	args []exprNode
+}
+
+type whenClause struct {
+	cond   exprNode
+	result exprNode
+}
+
+// caseNode covers both searched CASE (operand nil) and simple CASE.
+type caseNode struct {
+	pos      int
+	operand  exprNode
+	whens    []whenClause
+	elseExpr exprNode
+}
+
+func (n *literalNode) position() int { return n.pos }
+func (n *fieldNode) position() int   { return n.pos }
+func (n *starNode) position() int    { return n.pos }
+func (n *unaryNode) position() int   { return n.pos }
+func (n *binaryNode) position() int  { return n.pos }
+func (n *isNullNode) position() int  { return n.pos }
+func (n *callNode) position() int    { return n.pos }
+func (n *caseNode) position() int    { return n.pos }
+
+// parseExpression turns an expression into a tree. Field references are
+// written as [name] or as a bare identifier; function calls are restricted to
+// the whitelist in expressionFunctions.
+func parseExpression(expression string) (exprNode, error) {
+	if strings.TrimSpace(expression) == "" {
+		return nil, fmt.Errorf("expression cannot be empty")
+	}
+
+	tokens, err := tokenizeExpression(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &expressionParser{tokens: tokens}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, exprErrorf(tok.pos, "unexpected %s", describeToken(tok))
+	}
+	return node, nil
+}
+
+type expressionParser struct {
+	tokens []token
+	index  int
+}
+
+func (p *expressionParser) peek() token {
+	return p.tokens[p.index]
+}
+
+func (p *expressionParser) next() token {
+	tok := p.tokens[p.index]
+	if tok.kind != tokenEOF {
+		p.index++
+	}
+	return tok
+}
+
+func (p *expressionParser) isKeyword(word string) bool {
+	tok := p.peek()
+	return tok.kind == tokenIdent && tok.upper == word
+}
+
+func (p *expressionParser) expectKeyword(word string) error {
+	if !p.isKeyword(word) {
+		tok := p.peek()
+		return exprErrorf(tok.pos, "expected %s, found %s", word, describeToken(tok))
+	}
+	p.next()
+	return nil
+}
+
+func (p *expressionParser) parseOr() (exprNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("OR") {
+		tok := p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{pos: tok.pos, op: "OR", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *expressionParser) parseAnd() (exprNode, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("AND") {
+		tok := p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{pos: tok.pos, op: "AND", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *expressionParser) parseNot() (exprNode, error) {
+	if p.isKeyword("NOT") {
+		tok := p.next()
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{pos: tok.pos, op: "NOT", operand: operand}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *expressionParser) parseComparison() (exprNode, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.isKeyword("IS") {
+		tok := p.next()
+		negated := false
+		if p.isKeyword("NOT") {
+			p.next()
+			negated = true
+		}
+		if err := p.expectKeyword("NULL"); err != nil {
+			return nil, err
+		}
+		return &isNullNode{pos: tok.pos, operand: left, negated: negated}, nil
+	}
+
+	tok := p.peek()
+	if tok.kind != tokenOperator {
+		return left, nil
+	}
+	op := tok.text
+	switch op {
+	case "==":
+		op = "="
+	case "!=":
+		op = "<>"
+	case "=", "<>", "<", "<=", ">", ">=":
+	default:
+		return left, nil
+	}
+	p.next()
+	right, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	return &binaryNode{pos: tok.pos, op: op, left: left, right: right}, nil
+}
+
+func (p *expressionParser) parseAdditive() (exprNode, error) {
+	left, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok := p.peek()
+		if tok.kind != tokenOperator || (tok.text != "+" && tok.text != "-") {
+			return left, nil
+		}
+		p.next()
+		right, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{pos: tok.pos, op: tok.text, left: left, right: right}
+	}
+}
+
+func (p *expressionParser) parseMultiplicative() (exprNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok := p.peek()
+		if tok.kind != tokenOperator || (tok.text != "*" && tok.text != "/" && tok.text != "%") {
+			return left, nil
+		}
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{pos: tok.pos, op: tok.text, left: left, right: right}
+	}
+}
+
+func (p *expressionParser) parseUnary() (exprNode, error) {
+	tok := p.peek()
+	if tok.kind == tokenOperator && (tok.text == "-" || tok.text == "+") {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if tok.text == "+" {
+			return operand, nil
+		}
+		return &unaryNode{pos: tok.pos, op: "-", operand: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *expressionParser) parsePrimary() (exprNode, error) {
+	tok := p.next()
+
+	switch tok.kind {
+	case tokenNumber:
+		value, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, exprErrorf(tok.pos, "invalid number %q", tok.text)
+		}
+		return &literalNode{pos: tok.pos, kind: literalNumber, text: tok.text, number: value}, nil
+	case tokenString:
+		return &literalNode{pos: tok.pos, kind: literalString, text: tok.text}, nil
+	case tokenField:
+		return &fieldNode{pos: tok.pos, name: tok.text}, nil
+	case tokenLParen:
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokenRParen {
+			return nil, exprErrorf(closing.pos, "expected ')', found %s", describeToken(closing))
+		}
+		return inner, nil
+	case tokenIdent:
+		switch tok.upper {
+		case "TRUE", "FALSE":
+			return &literalNode{pos: tok.pos, kind: literalBool, text: tok.upper, bool: tok.upper == "TRUE"}, nil
+		case "NULL":
+			return &literalNode{pos: tok.pos, kind: literalNull, text: "NULL"}, nil
+		case "CASE":
+			return p.parseCase(tok)
+		}
+		if p.peek().kind == tokenLParen {
+			return p.parseCall(tok)
+		}
+		if expressionKeywords[tok.upper] {
+			return nil, exprErrorf(tok.pos, "unexpected keyword %s", tok.upper)
+		}
+		return &fieldNode{pos: tok.pos, name: tok.text}, nil
+	}
+
+	return nil, exprErrorf(tok.pos, "unexpected %s", describeToken(tok))
+}
+
+func (p *expressionParser) parseCall(name token) (exprNode, error) {
+	if _, ok := expressionFunctions[name.upper]; !ok {
+		return nil, exprErrorf(name.pos, "unknown function %s", name.text)
+	}
+	p.next() // (
+
+	call := &callNode{pos: name.pos, name: name.upper}
+	if p.peek().kind == tokenRParen {
+		p.next()
+		return call, nil
+	}
+
+	for {
+		arg, err := p.parseArgument(call)
+		if err != nil {
+			return nil, err
+		}
+		call.args = append(call.args, arg)
+
+		tok := p.next()
+		switch tok.kind {
+		case tokenComma:
+			continue
+		case tokenRParen:
+			return call, nil
+		default:
+			return nil, exprErrorf(tok.pos, "expected ',' or ')' in call to %s, found %s", call.name, describeToken(tok))
+		}
+	}
+}
+
+func (p *expressionParser) parseArgument(call *callNode) (exprNode, error) {
+	if tok := p.peek(); call.name == "COUNT" && len(call.args) == 0 && tok.kind == tokenOperator && tok.text == "*" {
+		p.next()
+		return &starNode{pos: tok.pos}, nil
+	}
+	return p.parseOr()
+}
+
+func (p *expressionParser) parseCase(caseTok token) (exprNode, error) {
+	node := &caseNode{pos: caseTok.pos}
+	if !p.isKeyword("WHEN") {
+		operand, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		node.operand = operand
+	}
+
+	for p.isKeyword("WHEN") {
+		p.next()
+		cond, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectKeyword("THEN"); err != nil {
+			return nil, err
+		}
+		result, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		node.whens = append(node.whens, whenClause{cond: cond, result: result})
+	}
+	if len(node.whens) == 0 {
+		tok := p.peek()
+		return nil, exprErrorf(tok.pos, "expected WHEN, found %s", describeToken(tok))
+	}
+
+	if p.isKeyword("ELSE") {
+		p.next()
+		elseExpr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		node.elseExpr = elseExpr
+	}
+	if err := p.expectKeyword("END"); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+func describeToken(tok token) string {
+	switch tok.kind {
+	case tokenEOF:
+		return "end of expression"
+	case tokenString:
+		return fmt.Sprintf("string '%s'", tok.text)
+	case tokenField:
+		return fmt.Sprintf("field [%s]", tok.text)
+	default:
+		return fmt.Sprintf("'%s'", tok.text)
+	}
+}
+
+// collectFieldReferences returns the distinct field names used by a tree.
+func collectFieldReferences(node exprNode) []string {
+	var names []string
+	seen := make(map[string]bool)
+	walkExpression(node, func(n exprNode) {
+		if field, ok := n.(*fieldNode); ok && !seen[field.name] {
+			seen[field.name] = true
+			names = append(names, field.name)
+		}
+	})
+	return names
+}
+
+func walkExpression(node exprNode, visit func(exprNode)) {
+	if node == nil {
+		return
+	}
+	visit(node)
+	switch n := node.(type) {
+	case *unaryNode:
+		walkExpression(n.operand, visit)
+	case *binaryNode:
+		walkExpression(n.left, visit)
+		walkExpression(n.right, visit)
+	case *isNullNode:
+		walkExpression(n.operand, visit)
+	case *callNode:
+		for _, arg := range n.args {
+			walkExpression(arg, visit)
+		}
+	case *caseNode:
+		walkExpression(n.operand, visit)
+		for _, when := range n.whens {
+			walkExpression(when.cond, visit)
+			walkExpression(when.result, visit)
+		}
+		walkExpression(n.elseExpr, visit)
+	}
+}
@@ -0,0 +1,140 @@
+package dataset
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gujiaweiguo/goreport/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseExpression_Precedence(t *testing.T) {
+	builder := NewSQLExpressionBuilder()
+
+	tests := []struct {
+		expression string
+		want       string
+	}{
+		{"[a] + [b] * [c]", "(`a` + (`b` * `c`))"},
+		{"([a] + [b]) * [c]", "((`a` + `b`) * `c`)"},
+		{"-[a] - -2", "((-`a`) - (-2))"},
+		{"[a] > 1 AND [b] < 2 OR NOT [c] = 3", "(((`a` > 1) AND (`b` < 2)) OR (NOT (`c` = 3)))"},
+		{"[a] != 1", "(`a` <> 1)"},
+		{"[a] IS NOT NULL", "(`a` IS NOT NULL)"},
+		{"CASE WHEN [a] > 0 THEN 'pos' ELSE 'neg' END", "(CASE WHEN `a` > 0 THEN 'pos' ELSE 'neg' END)"},
+		{"COUNT(*)", "(COUNT(*))"},
+		{"[订单 金额] * 2", "(`订单 金额` * 2)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			got, err := builder.Build(tt.expression, []string{"a", "b", "c", "订单 金额"})
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseExpression_Errors(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		pos        int
+	}{
+		{name: "unterminated string", expression: "[a] + 'abc", pos: 7},
+		{name: "unterminated field", expression: "1 + [a", pos: 5},
+		{name: "unexpected character", expression: "[a] ; DROP TABLE x", pos: 5},
+		{name: "trailing token", expression: "[a] [b]", pos: 5},
+		{name: "unknown function", expression: "SLEEP(10)", pos: 1},
+		{name: "missing paren", expression: "ROUND([a], 2", pos: 13},
+		{name: "case without when", expression: "CASE ELSE 1 END", pos: 6},
+		{name: "case without end", expression: "CASE WHEN [a] THEN 1", pos: 21},
+		{name: "star outside count", expression: "SUM(*)", pos: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseExpression(tt.expression)
+			var exprErr *ExpressionError
+			require.True(t, errors.As(err, &exprErr), "expected ExpressionError, got %v", err)
+			assert.Equal(t, tt.pos, exprErr.Pos)
+		})
+	}
+}
+
+func TestExpressionSchema_TypeErrors(t *testing.T) {
+	fields := []models.DatasetField{
+		{Name: "amount", DataType: "number"},
+		{Name: "region", DataType: "string"},
+		{Name: "ordered_at", DataType: "date"},
+		{Name: "active", DataType: "boolean"},
+	}
+	schema := schemaFromDatasetFields(fields)
+
+	tests := []struct {
+		name       string
+		expression string
+		pos        int
+		expected   exprType
+	}{
+		{name: "string in arithmetic", expression: "[amount] + [region]", pos: 12, expected: typeNumber},
+		{name: "number passed to UPPER", expression: "UPPER([amount])", pos: 7, expected: typeString},
+		{name: "non boolean condition", expression: "IF([amount], 1, 2)", pos: 4, expected: typeBoolean},
+		{name: "mismatched branches", expression: "CASE WHEN [active] THEN 1 ELSE 'x' END", pos: 32, expected: typeNumber},
+		{name: "date from number", expression: "YEAR([amount])", pos: 6, expected: typeDate},
+		{name: "compare number with boolean", expression: "[amount] = [active]", pos: 12, expected: typeNumber},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := schema.compile(tt.expression)
+			var exprErr *ExpressionError
+			require.True(t, errors.As(err, &exprErr), "expected ExpressionError, got %v", err)
+			assert.Equal(t, tt.pos, exprErr.Pos)
+			assert.Equal(t, tt.expected, exprErr.Expected)
+		})
+	}
+
+	_, resultType, err := schema.compile("IF([ordered_at] > '2024-01-01', [amount], 0)")
+	require.NoError(t, err)
+	assert.Equal(t, typeNumber, resultType)
+}
+
+func TestExpressionSchema_ComputedFields(t *testing.T) {
+	a := "[b] + 1"
+	b := "[a] * 2"
+	fields := []models.DatasetField{
+		{Name: "a", IsComputed: true, Expression: &a},
+		{Name: "b", IsComputed: true, Expression: &b},
+	}
+
+	_, _, err := schemaFromDatasetFields(fields).compile("[a]")
+	assert.ErrorContains(t, err, "circular reference")
+}
+
+func TestSQLExpressionBuilder_RejectsInjection(t *testing.T) {
+	builder := NewSQLExpressionBuilder()
+	fields := []string{"amount"}
+
+	for _, expression := range []string{
+		"[amount]) FROM users; --",
+		"[amount] UNION SELECT password FROM users",
+		"1; DROP TABLE orders",
+		"[amount] -- comment",
+		"BENCHMARK(1000000, MD5('x'))",
+	} {
+		t.Run(expression, func(t *testing.T) {
+			_, err := builder.Build(expression, fields)
+			assert.Error(t, err)
+		})
+	}
+
+	got, err := builder.Build("CONCAT([amount], ''') OR 1=1 --')", fields)
+	require.NoError(t, err)
+	assert.Equal(t, "(CONCAT(`amount`, ''') OR 1=1 --'))", got)
+
+	got, err = builder.Build("[amount` FROM users --]", []string{"amount` FROM users --"})
+	require.NoError(t, err)
+	assert.Equal(t, "(`amount`` FROM users --`)", got)
+}
@@ -0,0 +1,118 @@
+package dataset
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gujiaweiguo/goreport/internal/datasource"
+)
+
+// renderExpressionSQL generates SQL for a checked expression tree. Every
+// identifier and literal is quoted by the dialect, so nothing from the
+// original expression text reaches the query verbatim.
+func renderExpressionSQL(node exprNode, dialect datasource.Dialect) (string, error) {
+	switch n := node.(type) {
+	case *literalNode:
+		switch n.kind {
+		case literalNumber:
+			return n.text, nil
+		case literalString:
+			return dialect.QuoteString(n.text), nil
+		case literalBool:
+			return n.text, nil
+		default:
+			return "NULL", nil
+		}
+	case *fieldNode:
+		return dialect.QuoteIdentifier(n.name), nil
+	case *starNode:
+		return "*", nil
+	case *unaryNode:
+		operand, err := renderOperandSQL(n.operand, dialect)
+		if err != nil {
+			return "", err
+		}
+		if n.op == "NOT" {
+			return "NOT " + operand, nil
+		}
+		return n.op + operand, nil
+	case *binaryNode:
+		left, err := renderOperandSQL(n.left, dialect)
+		if err != nil {
+			return "", err
+		}
+		right, err := renderOperandSQL(n.right, dialect)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s %s %s", left, n.op, right), nil
+	case *isNullNode:
+		operand, err := renderOperandSQL(n.operand, dialect)
+		if err != nil {
+			return "", err
+		}
+		if n.negated {
+			return operand + " IS NOT NULL", nil
+		}
+		return operand + " IS NULL", nil
+	case *callNode:
+		args := make([]string, len(n.args))
+		for i, arg := range n.args {
+			rendered, err := renderOperandSQL(arg, dialect)
+			if err != nil {
+				return "", err
+			}
+			args[i] = rendered
+		}
+		rendered, err := dialect.RenderFunction(n.name, args)
+		if err != nil {
+			return "", exprErrorf(n.pos, "%v", err)
+		}
+		return rendered, nil
+	case *caseNode:
+		var sql strings.Builder
+		sql.WriteString("CASE")
+		if n.operand != nil {
+			operand, err := renderOperandSQL(n.operand, dialect)
+			if err != nil {
+				return "", err
+			}
+			sql.WriteString(" " + operand)
+		}
+		for _, when := range n.whens {
+			cond, err := renderExpressionSQL(when.cond, dialect)
+			if err != nil {
+				return "", err
+			}
+			result, err := renderExpressionSQL(when.result, dialect)
+			if err != nil {
+				return "", err
+			}
+			fmt.Fprintf(&sql, " WHEN %s THEN %s", cond, result)
+		}
+		if n.elseExpr != nil {
+			elseSQL, err := renderExpressionSQL(n.elseExpr, dialect)
+			if err != nil {
+				return "", err
+			}
+			sql.WriteString(" ELSE " + elseSQL)
+		}
+		sql.WriteString(" END")
+		return sql.String(), nil
+	}
+	return "", fmt.Errorf("unsupported expression node %T", node)
+}
+
+// renderOperandSQL parenthesizes compound operands so the tree's precedence
+// survives regardless of how the dialect binds operators.
+func renderOperandSQL(node exprNode, dialect datasource.Dialect) (string, error) {
+	rendered, err := renderExpressionSQL(node, dialect)
+	if err != nil {
+		return "", err
+	}
+	switch node.(type) {
+	case *unaryNode, *binaryNode, *isNullNode:
+		return "(" + rendered + ")", nil
+	}
+	return rendered, nil
+}
@@ -0,0 +1,148 @@
+package dataset
+
+import (
+	"testing"
+
+	"github.com/gujiaweiguo/goreport/internal/datasource"
+	"github.com/gujiaweiguo/goreport/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLExpressionBuilder_TranslateFunction_Dialects(t *testing.T) {
+	builder := NewSQLExpressionBuilder()
+
+	tests := []struct {
+		name       string
+		expression string
+		want       map[string]string
+	}{
+		{
+			name:       "concat",
+			expression: "CONCAT([first_name], ' ', [last_name])",
+			want: map[string]string{
+				"mysql":      "CONCAT(`first_name`, ' ', `last_name`)",
+				"postgres":   `CONCAT("first_name", ' ', "last_name")`,
+				"sqlite":     `("first_name" || ' ' || "last_name")`,
+				"clickhouse": "CONCAT(`first_name`, ' ', `last_name`)",
+				"sqlserver":  `CONCAT("first_name", ' ', "last_name")`,
+			},
+		},
+		{
+			name:       "date format",
+			expression: "DATE_FORMAT([created_at], '%Y-%m-%d %H:%i')",
+			want: map[string]string{
+				"mysql":      "DATE_FORMAT(`created_at`, '%Y-%m-%d %H:%i')",
+				"postgres":   `TO_CHAR("created_at", 'YYYY-MM-DD HH24:MI')`,
+				"sqlite":     `STRFTIME('%Y-%m-%d %H:%M', "created_at")`,
+				"clickhouse": "formatDateTime(`created_at`, '%Y-%m-%d %H:%i')",
+				"sqlserver":  `FORMAT("created_at", 'yyyy-MM-dd HH":"mm')`,
+			},
+		},
+		{
+			name:       "if",
+			expression: "IF([amount] > 100, 'big', 'small')",
+			want: map[string]string{
+				"mysql":      "IF((`amount` > 100), 'big', 'small')",
+				"postgres":   `CASE WHEN ("amount" > 100) THEN 'big' ELSE 'small' END`,
+				"sqlite":     `CASE WHEN ("amount" > 100) THEN 'big' ELSE 'small' END`,
+				"clickhouse": "if((`amount` > 100), 'big', 'small')",
+				"sqlserver":  `CASE WHEN ("amount" > 100) THEN 'big' ELSE 'small' END`,
+			},
+		},
+		{
+			name:       "nested date arithmetic",
+			expression: "YEAR(DATE_ADD([ordered_at], 7))",
+			want: map[string]string{
+				"mysql":      "YEAR(DATE_ADD(`ordered_at`, INTERVAL (7) DAY))",
+				"postgres":   `CAST(EXTRACT(YEAR FROM ("ordered_at" + (7) * INTERVAL '1 day')) AS INTEGER)`,
+				"sqlite":     `CAST(STRFTIME('%Y', DATETIME("ordered_at", '+' || (7) || ' days')) AS INTEGER)`,
+				"clickhouse": "toYear(addDays(`ordered_at`, 7))",
+				"sqlserver":  `DATEPART(year, DATEADD(day, 7, "ordered_at"))`,
+			},
+		},
+		{
+			name:       "datediff",
+			expression: "DATEDIFF(NOW(), [created_at])",
+			want: map[string]string{
+				"mysql":      "DATEDIFF(NOW(), `created_at`)",
+				"postgres":   `(CAST(NOW() AS DATE) - CAST("created_at" AS DATE))`,
+				"sqlite":     `CAST(JULIANDAY(DATE(DATETIME('now'))) - JULIANDAY(DATE("created_at")) AS INTEGER)`,
+				"clickhouse": "dateDiff('day', toDate(`created_at`), toDate(now()))",
+				"sqlserver":  `DATEDIFF(day, "created_at", GETDATE())`,
+			},
+		},
+		{
+			name:       "string literals are escaped per dialect",
+			expression: `CONCAT('it''s \', LENGTH([name]))`,
+			want: map[string]string{
+				"mysql":     "CONCAT('it''s \\\\', LENGTH(`name`))",
+				"postgres":  `CONCAT('it''s \', LENGTH("name"))`,
+				"sqlserver": `CONCAT('it''s \', LEN("name"))`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		for databaseType, want := range tt.want {
+			t.Run(tt.name+"/"+databaseType, func(t *testing.T) {
+				got, err := builder.TranslateFunction(tt.expression, databaseType)
+				require.NoError(t, err)
+				assert.Equal(t, want, got)
+			})
+		}
+	}
+}
+
+func TestSQLExpressionBuilder_TranslateFunction_Errors(t *testing.T) {
+	builder := NewSQLExpressionBuilder()
+
+	tests := []struct {
+		name         string
+		expression   string
+		databaseType string
+	}{
+		{name: "unknown database", expression: "NOW()", databaseType: "oracle"},
+		{name: "unsupported specifier", expression: "DATE_FORMAT([d], '%x')", databaseType: "postgres"},
+		{name: "unsupported sqlite specifier", expression: "DATE_FORMAT([d], '%M')", databaseType: "sqlite"},
+		{name: "non literal pattern", expression: "DATE_FORMAT([d], [pattern])", databaseType: "postgres"},
+		{name: "unknown unit", expression: "DATE_ADD([d], 1, 'fortnight')", databaseType: "postgres"},
+		{name: "unbalanced", expression: "ROUND([a], 2", databaseType: "mysql"},
+		{name: "unterminated string", expression: "CONCAT('abc, [a])", databaseType: "mysql"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := builder.TranslateFunction(tt.expression, tt.databaseType)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestSQLExpressionBuilder_WithDialect(t *testing.T) {
+	builder := NewSQLExpressionBuilder()
+	tax := "[price] * 0.1"
+	gross := "[price] + [tax]"
+	fields := []models.DatasetField{
+		{Name: "price", DataType: "number"},
+		{Name: "quantity", DataType: "number"},
+		{Name: "tax", DataType: "number", IsComputed: true, Expression: &tax},
+		{Name: "gross", DataType: "number", IsComputed: true, Expression: &gross},
+	}
+
+	got, err := builder.BuildFields("ROUND([price] * [quantity], 2)", fields)
+	require.NoError(t, err)
+	assert.Equal(t, "(ROUND((`price` * `quantity`), 2))", got)
+
+	sqlServer, err := datasource.GetDialect("sqlserver")
+	require.NoError(t, err)
+	got, err = builder.WithDialect(sqlServer).BuildFields("ROUND([price] * [quantity], 2)", fields)
+	require.NoError(t, err)
+	assert.Equal(t, `(ROUND(("price" * "quantity"), 2))`, got)
+
+	postgres, err := datasource.GetDialect("postgres")
+	require.NoError(t, err)
+	got, err = builder.WithDialect(postgres).BuildFields("CEIL(gross)", fields)
+	require.NoError(t, err)
+	assert.Equal(t, `(CEIL(("price" + ("price" * 0.1))))`, got)
+}
@@ -0,0 +1,397 @@
+package dataset
+
+import (
+	"fmt"
+
+	"github.com/gujiaweiguo/goreport/internal/models"
+)
+
+// exprType is the static type of an expression node. typeAny is used for
+// fields whose type is unknown and for NULL, and is compatible with anything.
+type exprType string
+
+const (
+	typeAny     exprType = "any"
+	typeNumber  exprType = "number"
+	typeString  exprType = "string"
+	typeBoolean exprType = "boolean"
+	typeDate    exprType = "date"
+)
+
+// accepts reports whether a value of type actual may be used where expected
+// is required. Date parameters also take strings so literals such as
+// '2024-01-01' can be compared with and passed as dates.
+func (expected exprType) accepts(actual exprType) bool {
+	if expected == typeAny || actual == typeAny || expected == actual {
+		return true
+	}
+	return expected == typeDate && actual == typeString
+}
+
+// exprTypeForDataType maps a DatasetField.DataType onto the expression types.
+func exprTypeForDataType(dataType string) exprType {
+	switch dataType {
+	case "number":
+		return typeNumber
+	case "string":
+		return typeString
+	case "boolean":
+		return typeBoolean
+	case "date":
+		return typeDate
+	default:
+		return typeAny
+	}
+}
+
+// functionSignature describes a whitelisted function. When variadic is set
+// the last parameter may repeat. resultArgs, when non-empty, lists the
+// arguments whose unified type is the result type instead of result.
+type functionSignature struct {
+	params     []exprType
+	minArgs    int
+	variadic   bool
+	result     exprType
+	resultArgs []int
+}
+
+var expressionFunctions = map[string]functionSignature{
+	"CONCAT":      {params: []exprType{typeAny}, minArgs: 1, variadic: true, result: typeString},
+	"SUBSTRING":   {params: []exprType{typeString, typeNumber, typeNumber}, minArgs: 2, result: typeString},
+	"LENGTH":      {params: []exprType{typeString}, minArgs: 1, result: typeNumber},
+	"UPPER":       {params: []exprType{typeString}, minArgs: 1, result: typeString},
+	"LOWER":       {params: []exprType{typeString}, minArgs: 1, result: typeString},
+	"TRIM":        {params: []exprType{typeString}, minArgs: 1, result: typeString},
+	"ROUND":       {params: []exprType{typeNumber, typeNumber}, minArgs: 1, result: typeNumber},
+	"CEIL":        {params: []exprType{typeNumber}, minArgs: 1, result: typeNumber},
+	"FLOOR":       {params: []exprType{typeNumber}, minArgs: 1, result: typeNumber},
+	"ABS":         {params: []exprType{typeNumber}, minArgs: 1, result: typeNumber},
+	"SUM":         {params: []exprType{typeNumber}, minArgs: 1, result: typeNumber},
+	"AVG":         {params: []exprType{typeNumber}, minArgs: 1, result: typeNumber},
+	"COUNT":       {params: []exprType{typeAny}, minArgs: 1, result: typeNumber},
+	"MAX":         {params: []exprType{typeAny}, minArgs: 1, resultArgs: []int{0}},
+	"MIN":         {params: []exprType{typeAny}, minArgs: 1, resultArgs: []int{0}},
+	"COALESCE":    {params: []exprType{typeAny}, minArgs: 1, variadic: true, resultArgs: []int{-1}},
+	"IF":          {params: []exprType{typeBoolean, typeAny, typeAny}, minArgs: 3, resultArgs: []int{1, 2}},
+	"DATE_FORMAT": {params: []exprType{typeDate, typeString}, minArgs: 2, result: typeString},
+	"DATE_ADD":    {params: []exprType{typeDate, typeNumber, typeString}, minArgs: 2, result: typeDate},
+	"DATE_SUB":    {params: []exprType{typeDate, typeNumber, typeString}, minArgs: 2, result: typeDate},
+	"DATEDIFF":    {params: []exprType{typeDate, typeDate}, minArgs: 2, result: typeNumber},
+	"NOW":         {result: typeDate},
+	"CURDATE":     {result: typeDate},
+	"CURTIME":     {result: typeString},
+	"YEAR":        {params: []exprType{typeDate}, minArgs: 1, result: typeNumber},
+	"MONTH":       {params: []exprType{typeDate}, minArgs: 1, result: typeNumber},
+	"DAY":         {params: []exprType{typeDate}, minArgs: 1, result: typeNumber},
+	"HOUR":        {params: []exprType{typeDate}, minArgs: 1, result: typeNumber},
+	"MINUTE":      {params: []exprType{typeDate}, minArgs: 1, result: typeNumber},
+	"SECOND":      {params: []exprType{typeDate}, minArgs: 1, result: typeNumber},
+}
+
+// expressionChecker resolves field references against the dataset schema and
+// infers the type of every node.
+type expressionChecker struct {
+	fields map[string]exprType
+}
+
+func newExpressionChecker(fields map[string]exprType) *expressionChecker {
+	return &expressionChecker{fields: fields}
+}
+
+// fieldTypesFromNames builds an untyped schema for callers that only know
+// field names.
+func fieldTypesFromNames(names []string) map[string]exprType {
+	fields := make(map[string]exprType, len(names))
+	for _, name := range names {
+		fields[name] = typeAny
+	}
+	return fields
+}
+
+func (c *expressionChecker) check(node exprNode) (exprType, error) {
+	switch n := node.(type) {
+	case *literalNode:
+		switch n.kind {
+		case literalNumber:
+			return typeNumber, nil
+		case literalString:
+			return typeString, nil
+		case literalBool:
+			return typeBoolean, nil
+		default:
+			return typeAny, nil
+		}
+	case *fieldNode:
+		fieldType, ok := c.fields[n.name]
+		if !ok {
+			return "", exprErrorf(n.pos, "field reference '[%s]' not found in dataset fields", n.name)
+		}
+		return fieldType, nil
+	case *starNode:
+		return typeAny, nil
+	case *unaryNode:
+		expected := typeNumber
+		if n.op == "NOT" {
+			expected = typeBoolean
+		}
+		if _, err := c.expect(n.operand, expected); err != nil {
+			return "", err
+		}
+		return expected, nil
+	case *isNullNode:
+		if _, err := c.check(n.operand); err != nil {
+			return "", err
+		}
+		return typeBoolean, nil
+	case *binaryNode:
+		return c.checkBinary(n)
+	case *callNode:
+		return c.checkCall(n)
+	case *caseNode:
+		return c.checkCase(n)
+	}
+	return "", fmt.Errorf("unsupported expression node %T", node)
+}
+
+func (c *expressionChecker) expect(node exprNode, expected exprType) (exprType, error) {
+	actual, err := c.check(node)
+	if err != nil {
+		return "", err
+	}
+	if !expected.accepts(actual) {
+		return "", &ExpressionError{Pos: node.position(), Message: fmt.Sprintf("got %s", actual), Expected: expected}
+	}
+	return actual, nil
+}
+
+func (c *expressionChecker) checkBinary(n *binaryNode) (exprType, error) {
+	switch n.op {
+	case "AND", "OR":
+		if _, err := c.expect(n.left, typeBoolean); err != nil {
+			return "", err
+		}
+		if _, err := c.expect(n.right, typeBoolean); err != nil {
+			return "", err
+		}
+		return typeBoolean, nil
+	case "+", "-", "*", "/", "%":
+		if _, err := c.expect(n.left, typeNumber); err != nil {
+			return "", err
+		}
+		if _, err := c.expect(n.right, typeNumber); err != nil {
+			return "", err
+		}
+		return typeNumber, nil
+	default:
+		left, err := c.check(n.left)
+		if err != nil {
+			return "", err
+		}
+		right, err := c.check(n.right)
+		if err != nil {
+			return "", err
+		}
+		if !left.accepts(right) && !right.accepts(left) {
+			return "", &ExpressionError{Pos: n.right.position(), Message: fmt.Sprintf("cannot compare %s with %s", left, right), Expected: left}
+		}
+		return typeBoolean, nil
+	}
+}
+
+func (c *expressionChecker) checkCall(n *callNode) (exprType, error) {
+	sig := expressionFunctions[n.name]
+	if len(n.args) < sig.minArgs || (!sig.variadic && len(n.args) > len(sig.params)) {
+		return "", exprErrorf(n.pos, "%s expects %s, got %d", n.name, describeArity(sig), len(n.args))
+	}
+
+	argTypes := make([]exprType, len(n.args))
+	for i, arg := range n.args {
+		param := sig.params[len(sig.params)-1]
+		if i < len(sig.params) {
+			param = sig.params[i]
+		}
+		argType, err := c.expect(arg, param)
+		if err != nil {
+			return "", err
+		}
+		argTypes[i] = argType
+	}
+
+	if len(sig.resultArgs) == 0 {
+		return sig.result, nil
+	}
+	var nodes []exprNode
+	var types []exprType
+	for _, index := range sig.resultArgs {
+		if index < 0 {
+			// -1 means every argument.
+			return unifyTypes(n.args, argTypes)
+		}
+		nodes = append(nodes, n.args[index])
+		types = append(types, argTypes[index])
+	}
+	return unifyTypes(nodes, types)
+}
+
+func (c *expressionChecker) checkCase(n *caseNode) (exprType, error) {
+	var operandType exprType
+	if n.operand != nil {
+		var err error
+		if operandType, err = c.check(n.operand); err != nil {
+			return "", err
+		}
+	}
+
+	var results []exprNode
+	var resultTypes []exprType
+	for _, when := range n.whens {
+		if n.operand == nil {
+			if _, err := c.expect(when.cond, typeBoolean); err != nil {
+				return "", err
+			}
+		} else if _, err := c.expect(when.cond, operandType); err != nil {
+			return "", err
+		}
+		resultType, err := c.check(when.result)
+		if err != nil {
+			return "", err
+		}
+		results = append(results, when.result)
+		resultTypes = append(resultTypes, resultType)
+	}
+	if n.elseExpr != nil {
+		elseType, err := c.check(n.elseExpr)
+		if err != nil {
+			return "", err
+		}
+		results = append(results, n.elseExpr)
+		resultTypes = append(resultTypes, elseType)
+	}
+	return unifyTypes(results, resultTypes)
+}
+
+// unifyTypes returns the common type of several branches, failing at the
+// first branch that conflicts with an earlier one.
+func unifyTypes(nodes []exprNode, types []exprType) (exprType, error) {
+	result := typeAny
+	for i, t := range types {
+		if result == typeAny {
+			result = t
+			continue
+		}
+		if !result.accepts(t) {
+			return "", &ExpressionError{Pos: nodes[i].position(), Message: fmt.Sprintf("got %s", t), Expected: result}
+		}
+	}
+	return result, nil
+}
+
+func describeArity(sig functionSignature) string {
+	switch {
+	case sig.variadic:
+		return fmt.Sprintf("at least %d argument(s)", sig.minArgs)
+	case sig.minArgs == len(sig.params):
+		return fmt.Sprintf("%d argument(s)", sig.minArgs)
+	default:
+		return fmt.Sprintf("%d to %d arguments", sig.minArgs, len(sig.params))
+	}
+}
+
+// expressionSchema is the set of fields an expression may reference. Computed
+// fields are inlined, so generated SQL and row evaluation only ever see the
+// underlying columns.
+type expressionSchema struct {
+	types    map[string]exprType
+	computed map[string]string
+}
+
+func schemaFromNames(names []string) *expressionSchema {
+	return &expressionSchema{types: fieldTypesFromNames(names)}
+}
+
+func schemaFromDatasetFields(fields []models.DatasetField) *expressionSchema {
+	schema := &expressionSchema{
+		types:    make(map[string]exprType, len(fields)),
+		computed: make(map[string]string),
+	}
+	for _, field := range fields {
+		if field.IsGroupingField {
+			continue
+		}
+		if field.IsComputed {
+			if field.Expression != nil {
+				schema.computed[field.Name] = *field.Expression
+			}
+			continue
+		}
+		schema.types[field.Name] = exprTypeForDataType(field.DataType)
+	}
+	return schema
+}
+
+// compile parses an expression, inlines computed field references and type
+// checks the result.
+func (s *expressionSchema) compile(expression string) (exprNode, exprType, error) {
+	node, err := parseExpression(expression)
+	if err != nil {
+		return nil, "", err
+	}
+	node, err = s.expand(node, map[string]bool{})
+	if err != nil {
+		return nil, "", err
+	}
+	resultType, err := newExpressionChecker(s.types).check(node)
+	if err != nil {
+		return nil, "", err
+	}
+	return node, resultType, nil
+}
+
+func (s *expressionSchema) expand(node exprNode, visiting map[string]bool) (exprNode, error) {
+	var expandErr error
+	replace := func(child exprNode) exprNode {
+		if expandErr != nil || child == nil {
+			return child
+		}
+		var expanded exprNode
+		expanded, expandErr = s.expand(child, visiting)
+		return expanded
+	}
+
+	switch n := node.(type) {
+	case *fieldNode:
+		expression, ok := s.computed[n.name]
+		if !ok {
+			return n, nil
+		}
+		if visiting[n.name] {
+			return nil, exprErrorf(n.pos, "circular reference to computed field [%s]", n.name)
+		}
+		inner, err := parseExpression(expression)
+		if err != nil {
+			return nil, exprErrorf(n.pos, "computed field [%s] is invalid: %v", n.name, err)
+		}
+		visiting[n.name] = true
+		defer delete(visiting, n.name)
+		return s.expand(inner, visiting)
+	case *unaryNode:
+		return &unaryNode{pos: n.pos, op: n.op, operand: replace(n.operand)}, expandErr
+	case *binaryNode:
+		return &binaryNode{pos: n.pos, op: n.op, left: replace(n.left), right: replace(n.right)}, expandErr
+	case *isNullNode:
+		return &isNullNode{pos: n.pos, operand: replace(n.operand), negated: n.negated}, expandErr
+	case *callNode:
+		args := make([]exprNode, len(n.args))
+		for i, arg := range n.args {
+			args[i] = replace(arg)
+		}
+		return &callNode{pos: n.pos, name: n.name, args: args}, expandErr
+	case *caseNode:
+		expanded := &caseNode{pos: n.pos, operand: replace(n.operand), elseExpr: replace(n.elseExpr)}
+		for _, when := range n.whens {
+			expanded.whens = append(expanded.whens, whenClause{cond: replace(when.cond), result: replace(when.result)})
+		}
+		return expanded, expandErr
+	}
+	return node, nil
+}
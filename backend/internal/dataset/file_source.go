@@ -0,0 +1,491 @@
+package dataset
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gujiaweiguo/goreport/internal/config"
+	"github.com/gujiaweiguo/goreport/internal/models"
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	fileFormatCSV   = "csv"
+	fileFormatExcel = "excel"
+)
+
+// FileStorage keeps uploaded dataset files on local disk. Files live under
+// <root>/<tenantID>/<datasetID>/ and are addressed by a key relative to root.
+type FileStorage struct {
+	root    string
+	maxSize int64
+}
+
+var fileStorage *FileStorage
+
+// InitFileStorage configures where file datasets are stored.
+func InitFileStorage(cfg *config.StorageConfig) {
+	fileStorage = NewFileStorage(cfg.UploadDir, int64(cfg.MaxUploadSizeMB)<<20)
+}
+
+func NewFileStorage(root string, maxSize int64) *FileStorage {
+	return &FileStorage{root: root, maxSize: maxSize}
+}
+
+func getFileStorage() (*FileStorage, error) {
+	if fileStorage == nil {
+		return nil, errors.New("file storage not initialized")
+	}
+	return fileStorage, nil
+}
+
+// MaxSize is the upload limit in bytes; zero means unlimited.
+func (s *FileStorage) MaxSize() int64 {
+	return s.maxSize
+}
+
+// Save writes an upload and returns its storage key.
+func (s *FileStorage) Save(tenantID, datasetID, fileName string, r io.Reader) (string, error) {
+	if !isSafePathSegment(tenantID) || !isSafePathSegment(datasetID) {
+		return "", errors.New("invalid storage location")
+	}
+
+	dir := filepath.Join(s.root, tenantID, datasetID)
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return "", fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	name := fmt.Sprintf("%d%s", time.Now().UnixNano(), strings.ToLower(filepath.Ext(fileName)))
+	file, err := os.OpenFile(filepath.Join(dir, name), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o640)
+	if err != nil {
+		return "", fmt.Errorf("failed to store file: %w", err)
+	}
+
+	src := r
+	if s.maxSize > 0 {
+		src = io.LimitReader(r, s.maxSize+1)
+	}
+	written, err := io.Copy(file, src)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && s.maxSize > 0 && written > s.maxSize {
+		err = fmt.Errorf("file exceeds %d MB limit", s.maxSize>>20)
+	}
+	if err != nil {
+		_ = os.Remove(file.Name())
+		return "", err
+	}
+
+	return path.Join(tenantID, datasetID, name), nil
+}
+
+// Open opens a stored file, refusing keys outside the tenant's directory.
+func (s *FileStorage) Open(tenantID, key string) (*os.File, error) {
+	location, err := s.resolve(tenantID, key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(location)
+}
+
+func (s *FileStorage) Remove(tenantID, key string) error {
+	location, err := s.resolve(tenantID, key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(location); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *FileStorage) resolve(tenantID, key string) (string, error) {
+	parts := strings.Split(key, "/")
+	if len(parts) != 3 || parts[0] != tenantID {
+		return "", errors.New("file not found")
+	}
+	for _, part := range parts {
+		if !isSafePathSegment(part) {
+			return "", errors.New("file not found")
+		}
+	}
+	return filepath.Join(s.root, filepath.FromSlash(key)), nil
+}
+
+func isSafePathSegment(segment string) bool {
+	return segment != "" && segment != "." && segment != ".." &&
+		!strings.ContainsAny(segment, `/\`) && !strings.ContainsRune(segment, 0)
+}
+
+// fileDatasetConfig is the Config of a "file" dataset.
+type fileDatasetConfig struct {
+	FileName   string   `json:"fileName"`
+	StorageKey string   `json:"storageKey"`
+	Format     string   `json:"format"`
+	Delimiter  string   `json:"delimiter,omitempty"`
+	HasHeader  *bool    `json:"hasHeader,omitempty"`
+	Sheet      string   `json:"sheet,omitempty"`
+	Sheets     []string `json:"sheets,omitempty"`
+}
+
+func parseFileDatasetConfig(raw string) (*fileDatasetConfig, error) {
+	var config fileDatasetConfig
+	if strings.TrimSpace(raw) != "" {
+		if err := json.Unmarshal([]byte(raw), &config); err != nil {
+			return nil, fmt.Errorf("invalid dataset config: %w", err)
+		}
+	}
+	return &config, nil
+}
+
+func (c *fileDatasetConfig) hasHeader() bool {
+	return c.HasHeader == nil || *c.HasHeader
+}
+
+// fileFormatFor maps an upload's extension to a file format.
+func fileFormatFor(fileName string) (string, error) {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".csv", ".tsv", ".txt":
+		return fileFormatCSV, nil
+	case ".xlsx", ".xlsm":
+		return fileFormatExcel, nil
+	default:
+		return "", fmt.Errorf("unsupported file type: %s", filepath.Ext(fileName))
+	}
+}
+
+func csvDelimiter(value string) (rune, error) {
+	switch value {
+	case "":
+		return ',', nil
+	case `\t`, "tab":
+		return '\t', nil
+	}
+	delimiter, size := utf8.DecodeRuneInString(value)
+	if size != len(value) || delimiter == '"' || delimiter == '\r' || delimiter == '\n' || delimiter == utf8.RuneError {
+		return 0, fmt.Errorf("invalid delimiter: %q", value)
+	}
+	return delimiter, nil
+}
+
+// fileTable is the parsed content of a file dataset: column names and the
+// raw cell text of each record.
+type fileTable struct {
+	columns []string
+	records [][]string
+	sheets  []string
+}
+
+func readFileTable(ctx context.Context, r io.Reader, config *fileDatasetConfig) (*fileTable, error) {
+	var (
+		rows   [][]string
+		sheets []string
+		err    error
+	)
+	switch config.Format {
+	case fileFormatCSV:
+		rows, err = readCSVRows(ctx, r, config.Delimiter)
+	case fileFormatExcel:
+		rows, sheets, err = readExcelRows(r, config.Sheet)
+	default:
+		return nil, fmt.Errorf("unsupported file format: %s", config.Format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	width := 0
+	for _, row := range rows {
+		if len(row) > width {
+			width = len(row)
+		}
+	}
+
+	var header []string
+	if config.hasHeader() && len(rows) > 0 {
+		header, rows = rows[0], rows[1:]
+	}
+
+	table := &fileTable{columns: fileColumnNames(header, width), sheets: sheets}
+	for _, row := range rows {
+		if isBlankRow(row) {
+			continue
+		}
+		record := make([]string, width)
+		copy(record, row)
+		table.records = append(table.records, record)
+	}
+	return table, nil
+}
+
+func readCSVRows(ctx context.Context, r io.Reader, delimiter string) ([][]string, error) {
+	comma, err := csvDelimiter(delimiter)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := csv.NewReader(skipUTF8BOM(r))
+	reader.Comma = comma
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	var rows [][]string
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid csv file: %w", err)
+		}
+		rows = append(rows, row)
+		if len(rows)%1000 == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// readExcelRows reads one sheet, the first one when sheet is empty, and
+// returns the workbook's sheet names alongside.
+func readExcelRows(r io.Reader, sheet string) ([][]string, []string, error) {
+	workbook, err := excelize.OpenReader(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid excel file: %w", err)
+	}
+	defer workbook.Close()
+
+	sheets := workbook.GetSheetList()
+	if len(sheets) == 0 {
+		return nil, nil, errors.New("excel file has no sheets")
+	}
+	if sheet == "" {
+		sheet = sheets[0]
+	} else if !containsString(sheets, sheet) {
+		return nil, nil, fmt.Errorf("sheet not found: %s", sheet)
+	}
+
+	rows, err := workbook.GetRows(sheet)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read sheet %s: %w", sheet, err)
+	}
+	return rows, sheets, nil
+}
+
+func skipUTF8BOM(r io.Reader) io.Reader {
+	bom := []byte{0xEF, 0xBB, 0xBF}
+	head := make([]byte, len(bom))
+	n, _ := io.ReadFull(r, head)
+	if n == len(bom) && bytes.Equal(head, bom) {
+		return r
+	}
+	return io.MultiReader(bytes.NewReader(head[:n]), r)
+}
+
+// fileColumnNames names columns from the header row, falling back to
+// column_N for missing names and suffixing duplicates.
+func fileColumnNames(header []string, width int) []string {
+	names := make([]string, width)
+	seen := make(map[string]int, width)
+	for i := range names {
+		name := ""
+		if i < len(header) {
+			name = strings.TrimSpace(header[i])
+		}
+		if name == "" {
+			name = fmt.Sprintf("column_%d", i+1)
+		}
+		if count := seen[name]; count > 0 {
+			seen[name] = count + 1
+			name = fmt.Sprintf("%s_%d", name, count+1)
+		}
+		seen[name]++
+		names[i] = name
+	}
+	return names
+}
+
+func isBlankRow(row []string) bool {
+	for _, cell := range row {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func containsString(values []string, target string) bool {
+	for _, value := range values {
+		if value == target {
+			return true
+		}
+	}
+	return false
+}
+
+// inferColumnSQLType names the narrowest SQL type holding every non-empty
+// cell, so file columns are typed by the same rules as database columns.
+func inferColumnSQLType(values []string) string {
+	isInt, isFloat, isBool, isDate := true, true, true, true
+	seen := false
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		seen = true
+		if isInt {
+			_, err := strconv.ParseInt(value, 10, 64)
+			isInt = err == nil
+		}
+		if isFloat {
+			_, err := strconv.ParseFloat(value, 64)
+			isFloat = err == nil
+		}
+		if isBool {
+			_, ok := parseFileBool(value)
+			isBool = ok
+		}
+		if isDate {
+			_, err := expressionTime(value, 0)
+			isDate = err == nil
+		}
+		if !isInt && !isFloat && !isBool && !isDate {
+			break
+		}
+	}
+
+	switch {
+	case !seen:
+		return "VARCHAR"
+	case isInt:
+		return "BIGINT"
+	case isFloat:
+		return "DOUBLE"
+	case isBool:
+		return "BOOLEAN"
+	case isDate:
+		return "DATETIME"
+	default:
+		return "VARCHAR"
+	}
+}
+
+func parseFileBool(value string) (bool, bool) {
+	switch strings.ToLower(value) {
+	case "true":
+		return true, true
+	case "false":
+		return false, true
+	default:
+		return false, false
+	}
+}
+
+// inferFileFields derives dataset fields from a parsed table.
+func inferFileFields(table *fileTable) []models.DatasetField {
+	fields := make([]models.DatasetField, len(table.columns))
+	values := make([]string, len(table.records))
+	for i, column := range table.columns {
+		for j, record := range table.records {
+			values[j] = record[i]
+		}
+		sqlType := inferColumnSQLType(values)
+		fields[i] = models.DatasetField{
+			Name:      column,
+			Type:      inferFieldType(sqlType),
+			DataType:  mapSQLTypeToDataType(sqlType),
+			SortIndex: i,
+		}
+	}
+	return fields
+}
+
+// fileRows converts records into rows, typing cells by the dataset's fields
+// so a field's DataType can be overridden after upload.
+func fileRows(dataset *models.Dataset, table *fileTable) []map[string]interface{} {
+	dataTypes := make(map[string]string, len(dataset.Fields))
+	for _, field := range dataset.Fields {
+		if !field.IsComputed {
+			dataTypes[field.Name] = field.DataType
+		}
+	}
+
+	rows := make([]map[string]interface{}, len(table.records))
+	for i, record := range table.records {
+		row := make(map[string]interface{}, len(table.columns))
+		for j, column := range table.columns {
+			row[column] = fileCellValue(record[j], dataTypes[column])
+		}
+		rows[i] = row
+	}
+	return rows
+}
+
+func fileCellValue(value, dataType string) interface{} {
+	trimmed := strings.TrimSpace(value)
+	if trimmed == "" {
+		return nil
+	}
+
+	switch dataType {
+	case "number":
+		if integer, err := strconv.ParseInt(trimmed, 10, 64); err == nil {
+			return integer
+		}
+		if number, err := strconv.ParseFloat(trimmed, 64); err == nil {
+			return number
+		}
+		return nil
+	case "boolean":
+		if b, ok := parseFileBool(trimmed); ok {
+			return b
+		}
+		return nil
+	case "date":
+		return trimmed
+	default:
+		return value
+	}
+}
+
+// loadFileTable opens and parses the stored file of a file dataset.
+func loadFileTable(ctx context.Context, dataset *models.Dataset) (*fileTable, error) {
+	config, err := parseFileDatasetConfig(dataset.Config)
+	if err != nil {
+		return nil, err
+	}
+	return openFileTable(ctx, dataset.TenantID, config)
+}
+
+func openFileTable(ctx context.Context, tenantID string, config *fileDatasetConfig) (*fileTable, error) {
+	if config.StorageKey == "" {
+		return nil, errors.New("file dataset has no uploaded file")
+	}
+
+	storage, err := getFileStorage()
+	if err != nil {
+		return nil, err
+	}
+	file, err := storage.Open(tenantID, config.StorageKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dataset file: %w", err)
+	}
+	defer file.Close()
+
+	return readFileTable(ctx, file, config)
+}
@@ -0,0 +1,321 @@
+package dataset
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gujiaweiguo/goreport/internal/config"
+	"github.com/gujiaweiguo/goreport/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/xuri/excelize/v2"
+)
+
+func useTestFileStorage(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	previous := fileStorage
+	InitFileStorage(&config.StorageConfig{UploadDir: dir, MaxUploadSizeMB: 1})
+	t.Cleanup(func() { fileStorage = previous })
+	return dir
+}
+
+func boolPtr(value bool) *bool {
+	return &value
+}
+
+func TestReadFileTable_CSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		config  fileDatasetConfig
+		columns []string
+		records [][]string
+	}{
+		{
+			name:    "header with bom and blank lines",
+			content: "\xef\xbb\xbfregion,amount\neast,10\n\n,\nwest,2.5\n",
+			config:  fileDatasetConfig{Format: fileFormatCSV},
+			columns: []string{"region", "amount"},
+			records: [][]string{{"east", "10"}, {"west", "2.5"}},
+		},
+		{
+			name:    "semicolon delimiter and ragged rows",
+			content: "a;b;a\n1;2;3\n4\n",
+			config:  fileDatasetConfig{Format: fileFormatCSV, Delimiter: ";"},
+			columns: []string{"a", "b", "a_2"},
+			records: [][]string{{"1", "2", "3"}, {"4", "", ""}},
+		},
+		{
+			name:    "tab delimiter without header",
+			content: "x\t1\ny\t2\n",
+			config:  fileDatasetConfig{Format: fileFormatCSV, Delimiter: `\t`, HasHeader: boolPtr(false)},
+			columns: []string{"column_1", "column_2"},
+			records: [][]string{{"x", "1"}, {"y", "2"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table, err := readFileTable(context.Background(), strings.NewReader(tt.content), &tt.config)
+			require.NoError(t, err)
+			assert.Equal(t, tt.columns, table.columns)
+			assert.Equal(t, tt.records, table.records)
+		})
+	}
+
+	_, err := readFileTable(context.Background(), strings.NewReader("a\n"), &fileDatasetConfig{Format: fileFormatCSV, Delimiter: "ab"})
+	assert.ErrorContains(t, err, "invalid delimiter")
+}
+
+func TestReadFileTable_ExcelSheets(t *testing.T) {
+	workbook := excelize.NewFile()
+	require.NoError(t, workbook.SetSheetRow("Sheet1", "A1", &[]interface{}{"name", "score"}))
+	require.NoError(t, workbook.SetSheetRow("Sheet1", "A2", &[]interface{}{"alice", 90}))
+	_, err := workbook.NewSheet("Targets")
+	require.NoError(t, err)
+	require.NoError(t, workbook.SetSheetRow("Targets", "A1", &[]interface{}{"month", "target"}))
+	require.NoError(t, workbook.SetSheetRow("Targets", "A2", &[]interface{}{"2024-01-01", 1200.5}))
+	var buf bytes.Buffer
+	require.NoError(t, workbook.Write(&buf))
+
+	table, err := readFileTable(context.Background(), bytes.NewReader(buf.Bytes()), &fileDatasetConfig{Format: fileFormatExcel})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Sheet1", "Targets"}, table.sheets)
+	assert.Equal(t, []string{"name", "score"}, table.columns)
+
+	table, err = readFileTable(context.Background(), bytes.NewReader(buf.Bytes()), &fileDatasetConfig{Format: fileFormatExcel, Sheet: "Targets"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"month", "target"}, table.columns)
+	assert.Equal(t, [][]string{{"2024-01-01", "1200.5"}}, table.records)
+
+	fields := inferFileFields(table)
+	assert.Equal(t, "date", fields[0].DataType)
+	assert.Equal(t, "dimension", fields[0].Type)
+	assert.Equal(t, "number", fields[1].DataType)
+	assert.Equal(t, "measure", fields[1].Type)
+
+	_, err = readFileTable(context.Background(), bytes.NewReader(buf.Bytes()), &fileDatasetConfig{Format: fileFormatExcel, Sheet: "Missing"})
+	assert.ErrorContains(t, err, "sheet not found")
+}
+
+func TestInferColumnSQLType(t *testing.T) {
+	tests := []struct {
+		values []string
+		want   string
+	}{
+		{[]string{"1", "", "42"}, "BIGINT"},
+		{[]string{"1", "2.5"}, "DOUBLE"},
+		{[]string{"true", "FALSE"}, "BOOLEAN"},
+		{[]string{"2024-01-02", "2024-01-03 10:00:00"}, "DATETIME"},
+		{[]string{"1", "abc"}, "VARCHAR"},
+		{[]string{"", " "}, "VARCHAR"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, inferColumnSQLType(tt.values), tt.values)
+	}
+}
+
+func TestFileStorage_TenantIsolation(t *testing.T) {
+	storage := NewFileStorage(t.TempDir(), 16)
+
+	key, err := storage.Save("tenant-1", "dataset-1", "data.CSV", strings.NewReader("a,b\n"))
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(key, "tenant-1/dataset-1/"))
+	assert.Equal(t, ".csv", filepath.Ext(key))
+
+	file, err := storage.Open("tenant-1", key)
+	require.NoError(t, err)
+	file.Close()
+
+	_, err = storage.Open("tenant-2", key)
+	assert.Error(t, err)
+	_, err = storage.Open("tenant-1", "tenant-1/../tenant-2/x.csv")
+	assert.Error(t, err)
+	_, err = storage.Save("../tenant-2", "dataset-1", "data.csv", strings.NewReader("a"))
+	assert.Error(t, err)
+
+	_, err = storage.Save("tenant-1", "dataset-1", "big.csv", strings.NewReader(strings.Repeat("x", 17)))
+	assert.ErrorContains(t, err, "exceeds")
+	entries, err := os.ReadDir(filepath.Join(storage.root, "tenant-1", "dataset-1"))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+// fileDatasetMocks backs a single dataset with a testify mock and keeps its
+// fields in memory, so uploads can be followed by queries.
+type fileDatasetMocks struct {
+	datasetRepo *mockDatasetRepository
+	fieldRepo   *memoryFieldRepository
+	stored      *models.Dataset
+}
+
+type memoryFieldRepository struct {
+	mockDatasetFieldRepository
+	dataset *models.Dataset
+}
+
+func (r *memoryFieldRepository) Create(ctx context.Context, field *models.DatasetField) error {
+	r.dataset.Fields = append(r.dataset.Fields, *field)
+	return nil
+}
+
+func (r *memoryFieldRepository) List(ctx context.Context, datasetID string) ([]*models.DatasetField, error) {
+	fields := make([]*models.DatasetField, len(r.dataset.Fields))
+	for i := range r.dataset.Fields {
+		field := r.dataset.Fields[i]
+		fields[i] = &field
+	}
+	return fields, nil
+}
+
+func (r *memoryFieldRepository) Update(ctx context.Context, field *models.DatasetField) error {
+	for i := range r.dataset.Fields {
+		if r.dataset.Fields[i].ID == field.ID {
+			r.dataset.Fields[i] = *field
+		}
+	}
+	return nil
+}
+
+func (r *memoryFieldRepository) Delete(ctx context.Context, id string) error {
+	kept := r.dataset.Fields[:0]
+	for _, field := range r.dataset.Fields {
+		if field.ID != id {
+			kept = append(kept, field)
+		}
+	}
+	r.dataset.Fields = kept
+	return nil
+}
+
+func newFileDatasetMocks() *fileDatasetMocks {
+	stored := &models.Dataset{}
+	m := &fileDatasetMocks{
+		datasetRepo: new(mockDatasetRepository),
+		fieldRepo:   &memoryFieldRepository{dataset: stored},
+		stored:      stored,
+	}
+
+	m.datasetRepo.On("Create", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		*m.stored = *args.Get(1).(*models.Dataset)
+	}).Return(nil)
+	m.datasetRepo.On("Update", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		updated := *args.Get(1).(*models.Dataset)
+		updated.Fields = m.stored.Fields
+		*m.stored = updated
+	}).Return(nil)
+	m.datasetRepo.On("GetByID", mock.Anything, mock.Anything).Return(m.stored, nil)
+	m.datasetRepo.On("GetByIDWithFields", mock.Anything, mock.Anything).Return(m.stored, nil)
+	return m
+}
+
+func (m *fileDatasetMocks) fieldTypes() map[string]string {
+	types := make(map[string]string)
+	for _, field := range m.stored.Fields {
+		types[field.Name] = field.DataType
+	}
+	return types
+}
+
+func TestDatasetService_UploadFile(t *testing.T) {
+	useTestFileStorage(t)
+	mocks := newFileDatasetMocks()
+	svc := NewService(mocks.datasetRepo, mocks.fieldRepo, nil, nil)
+
+	created, err := svc.UploadFile(context.Background(), &UploadFileRequest{
+		FileName:  "sales.csv",
+		File:      strings.NewReader("region;amount;code\neast;10;A1\nwest;2.5;B2\neast;4;C3\n"),
+		Delimiter: ";",
+		TenantID:  "tenant-1",
+		CreatedBy: "user-1",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "sales", created.Dataset.Name)
+	assert.Equal(t, "file", created.Dataset.Type)
+	assert.Equal(t, []string{"region", "amount", "code"}, created.Diff.Added)
+	assert.Equal(t, map[string]string{"region": "string", "amount": "number", "code": "string"}, mocks.fieldTypes())
+
+	config, err := parseFileDatasetConfig(mocks.stored.Config)
+	require.NoError(t, err)
+	assert.Equal(t, ";", config.Delimiter)
+	firstKey := config.StorageKey
+
+	executor := NewQueryExecutor(mocks.datasetRepo, mocks.fieldRepo, nil, NewSQLExpressionBuilder(), NewComputedFieldCache())
+	resp, err := executor.Query(context.Background(), &QueryRequest{
+		DatasetID: mocks.stored.ID,
+		GroupBy:   []string{"region"},
+		Aggregations: map[string]Aggregation{
+			"total": {Field: "amount", Function: "SUM"},
+		},
+		SortBy:    "total",
+		SortOrder: "desc",
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.Data, 2)
+	assert.Equal(t, "east", resp.Data[0]["region"])
+	assert.Equal(t, float64(14), resp.Data[0]["total"])
+
+	// Re-upload keeps the delimiter, retypes code, drops amount and adds qty.
+	replaced, err := svc.UploadFile(context.Background(), &UploadFileRequest{
+		DatasetID: mocks.stored.ID,
+		FileName:  "sales-v2.csv",
+		File:      strings.NewReader("region;code;qty\neast;1;3\nwest;2;5\n"),
+		TenantID:  "tenant-1",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"qty"}, replaced.Diff.Added)
+	assert.Equal(t, []string{"amount"}, replaced.Diff.Removed)
+	assert.Equal(t, []FieldTypeChange{{Name: "code", From: "string", To: "number"}}, replaced.Diff.Changed)
+	assert.Equal(t, map[string]string{"region": "string", "code": "number", "qty": "number"}, mocks.fieldTypes())
+
+	config, err = parseFileDatasetConfig(mocks.stored.Config)
+	require.NoError(t, err)
+	assert.Equal(t, "sales-v2.csv", config.FileName)
+	assert.NotEqual(t, firstKey, config.StorageKey)
+	_, err = fileStorage.Open("tenant-1", firstKey)
+	assert.Error(t, err, "replaced file should be removed")
+
+	rows, err := svc.Preview(context.Background(), mocks.stored.ID, "tenant-1")
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, int64(5), rows[1]["qty"])
+}
+
+func TestDatasetService_UploadFile_Errors(t *testing.T) {
+	useTestFileStorage(t)
+	mocks := newFileDatasetMocks()
+	svc := NewService(mocks.datasetRepo, mocks.fieldRepo, nil, nil)
+
+	_, err := svc.UploadFile(context.Background(), &UploadFileRequest{
+		FileName: "report.pdf",
+		File:     strings.NewReader("%PDF"),
+		TenantID: "tenant-1",
+	})
+	assert.ErrorContains(t, err, "unsupported file type")
+
+	_, err = svc.UploadFile(context.Background(), &UploadFileRequest{
+		FileName: "broken.xlsx",
+		File:     strings.NewReader("not a workbook"),
+		TenantID: "tenant-1",
+	})
+	assert.ErrorContains(t, err, "invalid excel file")
+	mocks.datasetRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+
+	mocks.stored.ID = "dataset-sql"
+	mocks.stored.TenantID = "tenant-1"
+	mocks.stored.Type = "sql"
+	_, err = svc.UploadFile(context.Background(), &UploadFileRequest{
+		DatasetID: "dataset-sql",
+		FileName:  "data.csv",
+		File:      strings.NewReader("a\n1\n"),
+		TenantID:  "tenant-1",
+	})
+	assert.ErrorContains(t, err, "not a file dataset")
+}
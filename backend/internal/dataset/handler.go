@@ -1,6 +1,7 @@
 package dataset
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -123,6 +124,10 @@ func (h *Handler) Update(c *gin.Context) {
 	}
 
 	dataset, err := h.service.Update(c.Request.Context(), &req)
+	if errors.Is(err, ErrInvalidDrill) {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
 		return
@@ -200,6 +205,10 @@ func (h *Handler) QueryData(c *gin.Context) {
 	req.DatasetID = id
 
 	result, err := h.queryExecutor.Query(c.Request.Context(), &req)
+	if errors.Is(err, ErrInvalidDrill) {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "failed to query dataset"})
 		return
@@ -208,6 +217,111 @@ func (h *Handler) QueryData(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"success": true, "result": result, "message": "success"})
 }
 
+// Export streams the result of a QueryRequest, without the page cap, as a
+// CSV or XLSX download chosen by the format query parameter.
+func (h *Handler) Export(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "id is required"})
+		return
+	}
+
+	tenantID := auth.GetTenantID(c)
+	if tenantID == "" {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "message": "tenant not found"})
+		return
+	}
+
+	format := c.DefaultQuery("format", ExportFormatCSV)
+	if format != ExportFormatCSV && format != ExportFormatXLSX {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "format must be csv or xlsx"})
+		return
+	}
+
+	var req QueryRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": fmt.Sprintf("invalid request: %v", err)})
+			return
+		}
+	}
+	req.DatasetID = id
+
+	out := NewExportResponseWriter(c, ExportContentType(format), fmt.Sprintf("dataset-%s.%s", id, format))
+	writer, err := NewRowWriter(format, out)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "failed to export dataset"})
+		return
+	}
+
+	_, err = h.queryExecutor.Export(c.Request.Context(), tenantID, &req, writer)
+	if err == nil {
+		err = writer.Close()
+	} else {
+		// Release the writer without sending whatever it still buffers.
+		out.Discard()
+		_ = writer.Close()
+	}
+	if err == nil {
+		return
+	}
+
+	if out.Started() {
+		// The download is already under way; all we can do is stop it.
+		_ = c.Error(err)
+		c.Abort()
+		return
+	}
+	switch {
+	case errors.Is(err, ErrExportTooLarge):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+	case errors.Is(err, ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "failed to export dataset"})
+	}
+}
+
+// ExportResponseWriter sets the download headers on the first write, so an
+// export that fails before producing output can still answer with JSON.
+// Report exports share it.
+type ExportResponseWriter struct {
+	c           *gin.Context
+	contentType string
+	filename    string
+	started     bool
+	discard     bool
+}
+
+func NewExportResponseWriter(c *gin.Context, contentType, filename string) *ExportResponseWriter {
+	return &ExportResponseWriter{c: c, contentType: contentType, filename: filename}
+}
+
+func (w *ExportResponseWriter) Write(p []byte) (int, error) {
+	if w.discard {
+		return len(p), nil
+	}
+	if !w.started {
+		w.started = true
+		w.c.Header("Content-Type", w.contentType)
+		w.c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, w.filename))
+		w.c.Status(http.StatusOK)
+	}
+	return w.c.Writer.Write(p)
+}
+
+// Started reports whether the download has begun, after which an error can
+// no longer be answered with JSON.
+func (w *ExportResponseWriter) Started() bool {
+	return w.started
+}
+
+// Discard drops everything written from now on, so a failed export can
+// release its writer without sending what it still buffers.
+func (w *ExportResponseWriter) Discard() {
+	w.discard = true
+}
+
 func (h *Handler) GetDimensions(c *gin.Context) {
 	id := c.Param("id")
 	if id == "" {
@@ -375,7 +489,7 @@ func (h *Handler) BatchUpdateFields(c *gin.Context) {
 
 	result, err := h.service.BatchUpdateFields(c.Request.Context(), id, tenantID, &req)
 	if err != nil {
-		if err.Error() == "dataset not found" {
+		if errors.Is(err, ErrNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"success": false, "message": err.Error()})
 			return
 		}
@@ -422,6 +536,74 @@ func (h *Handler) DeleteField(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"success": true, "message": "field deleted"})
 }
 
+// Upload creates a file dataset from a multipart upload, or replaces the file
+// of dataset :id and reports the schema diff.
+func (h *Handler) Upload(c *gin.Context) {
+	tenantID := auth.GetTenantID(c)
+	if tenantID == "" {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "message": "tenant not found"})
+		return
+	}
+	if !canWriteDataset(c) {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "message": "insufficient permissions"})
+		return
+	}
+
+	if storage, err := getFileStorage(); err == nil && storage.MaxSize() > 0 {
+		// Leave headroom for the other multipart fields.
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, storage.MaxSize()+1<<20)
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"success": false, "message": "file too large"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "file is required"})
+		return
+	}
+
+	req := UploadFileRequest{
+		DatasetID: c.Param("id"),
+		Name:      c.PostForm("name"),
+		FileName:  header.Filename,
+		Delimiter: c.PostForm("delimiter"),
+		Sheet:     c.PostForm("sheet"),
+		TenantID:  tenantID,
+		CreatedBy: auth.GetUserID(c),
+	}
+	if value := c.PostForm("hasHeader"); value != "" {
+		hasHeader, err := strconv.ParseBool(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "invalid hasHeader"})
+			return
+		}
+		req.HasHeader = &hasHeader
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "invalid file"})
+		return
+	}
+	defer file.Close()
+	req.File = file
+
+	result, err := h.service.UploadFile(c.Request.Context(), &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+
+	if req.DatasetID == "" {
+		c.JSON(http.StatusCreated, gin.H{"success": true, "result": result, "message": "dataset created"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": result, "message": "dataset file replaced"})
+}
+
 func canWriteDataset(c *gin.Context) bool {
 	roles := auth.GetRoles(c)
 	for _, role := range roles {
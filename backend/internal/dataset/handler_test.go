@@ -1,8 +1,11 @@
 package dataset
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"fmt"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -12,6 +15,7 @@ import (
 	"github.com/gujiaweiguo/goreport/internal/models"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 type mockDatasetService struct {
@@ -132,10 +136,23 @@ func (m *mockDatasetService) ListFields(ctx context.Context, datasetID, tenantID
 	return args.Get(0).([]*models.DatasetField), args.Error(1)
 }
 
+func (m *mockDatasetService) UploadFile(ctx context.Context, req *UploadFileRequest) (*UploadFileResponse, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*UploadFileResponse), args.Error(1)
+}
+
 type mockQueryExecutor struct {
 	mock.Mock
 }
 
+func (m *mockQueryExecutor) Export(ctx context.Context, tenantID string, req *QueryRequest, w RowWriter) (int64, error) {
+	args := m.Called(ctx, tenantID, req, w)
+	return args.Get(0).(int64), args.Error(1)
+}
+
 func (m *mockQueryExecutor) Query(ctx context.Context, req *QueryRequest) (*QueryResponse, error) {
 	args := m.Called(ctx, req)
 	if args.Get(0) == nil {
@@ -499,6 +516,58 @@ func TestDatasetHandler_QueryData_Success(t *testing.T) {
 	mockExec.AssertExpectations(t)
 }
 
+func TestDatasetHandler_Export(t *testing.T) {
+	handler, _, mockExec := setupDatasetTestHandler()
+
+	mockExec.On("Export", mock.Anything, "tenant-1", mock.MatchedBy(func(req *QueryRequest) bool {
+		return req.DatasetID == "ds-1" && len(req.GroupBy) == 1
+	}), mock.Anything).Run(func(args mock.Arguments) {
+		w := args.Get(3).(RowWriter)
+		_ = w.WriteHeader([]string{"Region", "orders"})
+		_ = w.WriteRow([]interface{}{"east", int64(2)})
+	}).Return(int64(1), nil).Once()
+	mockExec.On("Export", mock.Anything, "tenant-1", mock.Anything, mock.Anything).
+		Return(int64(0), fmt.Errorf("%w: 5 rows match, the limit is 3", ErrExportTooLarge)).Once()
+	mockExec.On("Export", mock.Anything, "tenant-1", mock.Anything, mock.Anything).
+		Return(int64(0), ErrNotFound).Once()
+
+	router := gin.New()
+	router.POST("/:id/export", func(c *gin.Context) {
+		c.Set("tenantId", "tenant-1")
+		handler.Export(c)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/ds-1/export?format=csv", strings.NewReader(`{"groupBy":["region"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="dataset-ds-1.csv"`, w.Header().Get("Content-Disposition"))
+	assert.Equal(t, "\ufeffRegion,orders\neast,2\n", w.Body.String())
+
+	req = httptest.NewRequest(http.MethodPost, "/ds-1/export?format=xlsx", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "the limit is 3")
+
+	req = httptest.NewRequest(http.MethodPost, "/ds-2/export?format=csv", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/ds-1/export?format=pdf", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockExec.AssertExpectations(t)
+}
+
 func TestDatasetHandler_QueryData_Error(t *testing.T) {
 	handler, _, mockExec := setupDatasetTestHandler()
 
@@ -918,7 +987,7 @@ func TestDatasetHandler_BatchUpdateFields_EmptyFields(t *testing.T) {
 func TestDatasetHandler_BatchUpdateFields_DatasetNotFound(t *testing.T) {
 	handler, mockSvc, _ := setupDatasetTestHandler()
 
-	mockSvc.On("BatchUpdateFields", mock.Anything, "ds-1", "tenant-1", mock.Anything).Return(nil, errors.New("dataset not found"))
+	mockSvc.On("BatchUpdateFields", mock.Anything, "ds-1", "tenant-1", mock.Anything).Return(nil, ErrNotFound)
 
 	body := `{"fields":[{"fieldId":"f-1","displayName":"Name"}]}`
 	router := gin.New()
@@ -1006,3 +1075,81 @@ func TestDatasetHandler_DeleteField_ServiceError(t *testing.T) {
 	assert.Equal(t, http.StatusInternalServerError, w.Code)
 	mockSvc.AssertExpectations(t)
 }
+
+func newUploadRequest(t *testing.T, target string, fields map[string]string, fileName, content string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for key, value := range fields {
+		require.NoError(t, writer.WriteField(key, value))
+	}
+	if fileName != "" {
+		part, err := writer.CreateFormFile("file", fileName)
+		require.NoError(t, err)
+		_, err = part.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+
+	req := httptest.NewRequest(http.MethodPost, target, &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func TestDatasetHandler_Upload(t *testing.T) {
+	handler, mockSvc, _ := setupDatasetTestHandler()
+
+	mockSvc.On("UploadFile", mock.Anything, mock.MatchedBy(func(req *UploadFileRequest) bool {
+		return req.DatasetID == "" && req.FileName == "sales.csv" && req.Delimiter == ";" &&
+			req.HasHeader != nil && !*req.HasHeader && req.TenantID == "tenant-1" && req.CreatedBy == "user-1"
+	})).Return(&UploadFileResponse{Dataset: &models.Dataset{ID: "dataset-1", Type: "file"}}, nil)
+	mockSvc.On("UploadFile", mock.Anything, mock.MatchedBy(func(req *UploadFileRequest) bool {
+		return req.DatasetID == "dataset-1" && req.Sheet == "Q2"
+	})).Return(&UploadFileResponse{
+		Dataset: &models.Dataset{ID: "dataset-1", Type: "file"},
+		Diff:    &SchemaDiff{Added: []string{"qty"}},
+	}, nil)
+
+	router := gin.New()
+	withUser := func(c *gin.Context) {
+		c.Set("tenantId", "tenant-1")
+		c.Set("userId", "user-1")
+		c.Set("roles", []string{"user"})
+		handler.Upload(c)
+	}
+	router.POST("/upload", withUser)
+	router.POST("/:id/upload", withUser)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newUploadRequest(t, "/upload", map[string]string{"delimiter": ";", "hasHeader": "false"}, "sales.csv", "a;b\n"))
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, newUploadRequest(t, "/dataset-1/upload", map[string]string{"sheet": "Q2"}, "sales.xlsx", "xlsx"))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"added":["qty"]`)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, newUploadRequest(t, "/upload", nil, "", ""))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, newUploadRequest(t, "/upload", map[string]string{"hasHeader": "maybe"}, "sales.csv", "a\n"))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockSvc.AssertExpectations(t)
+}
+
+func TestDatasetHandler_Upload_Forbidden(t *testing.T) {
+	handler, _, _ := setupDatasetTestHandler()
+
+	router := gin.New()
+	router.POST("/upload", func(c *gin.Context) {
+		c.Set("tenantId", "tenant-1")
+		c.Set("roles", []string{"viewer"})
+		handler.Upload(c)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newUploadRequest(t, "/upload", nil, "sales.csv", "a\n"))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
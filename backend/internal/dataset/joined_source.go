@@ -0,0 +1,554 @@
+package dataset
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gujiaweiguo/goreport/internal/datasource"
+	"github.com/gujiaweiguo/goreport/internal/models"
+	"github.com/gujiaweiguo/goreport/internal/repository"
+)
+
+// Limits for joins run in process. A source with more than
+// datasetJoinMaxSourceRows rows, or a join producing more than
+// datasetJoinMaxRows rows, is rejected rather than held in memory.
+const (
+	datasetJoinMaxSourceRows = 100000
+	datasetJoinMaxRows       = 200000
+)
+
+var (
+	sourceAliasPattern   = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	joinConjunctionSplit = regexp.MustCompile(`(?i)\s+AND\s+`)
+)
+
+// datasetSourceConfig is the SourceConfig of a DatasetSource. Datasource
+// sources read Query or Table; api sources additionally decode the
+// apiDatasetConfig keys from the same JSON.
+type datasetSourceConfig struct {
+	Alias string `json:"alias"`
+	Query string `json:"query"`
+	Table string `json:"table"`
+}
+
+// joinSource is one DatasetSource resolved into a join step. keys relate it
+// to sources earlier in the plan and are empty for the first source.
+type joinSource struct {
+	source   *models.DatasetSource
+	alias    string
+	config   datasetSourceConfig
+	joinType string
+	keys     []joinKey
+}
+
+// joinKey equates leftAlias.leftColumn of an earlier source with rightColumn
+// of the source being joined.
+type joinKey struct {
+	leftAlias   string
+	leftColumn  string
+	rightColumn string
+}
+
+// buildJoinPlan orders sources by SortIndex and validates their aliases, join
+// types and join conditions.
+func buildJoinPlan(sources []models.DatasetSource) ([]*joinSource, error) {
+	ordered := make([]*models.DatasetSource, len(sources))
+	for i := range sources {
+		ordered[i] = &sources[i]
+	}
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].SortIndex < ordered[j].SortIndex
+	})
+
+	seen := make(map[string]bool, len(ordered))
+	plan := make([]*joinSource, 0, len(ordered))
+	for i, source := range ordered {
+		var config datasetSourceConfig
+		if strings.TrimSpace(source.SourceConfig) != "" {
+			if err := json.Unmarshal([]byte(source.SourceConfig), &config); err != nil {
+				return nil, fmt.Errorf("invalid source config: %w", err)
+			}
+		}
+
+		alias := config.Alias
+		if alias == "" {
+			alias = fmt.Sprintf("t%d", i+1)
+		}
+		if !sourceAliasPattern.MatchString(alias) {
+			return nil, fmt.Errorf("invalid source alias: %s", alias)
+		}
+		if seen[alias] {
+			return nil, fmt.Errorf("duplicate source alias: %s", alias)
+		}
+
+		switch source.SourceType {
+		case "datasource":
+			if source.SourceID == nil || *source.SourceID == "" {
+				return nil, fmt.Errorf("source %s requires a datasource", alias)
+			}
+			if config.Query == "" && config.Table == "" {
+				return nil, fmt.Errorf("source %s requires a query or table", alias)
+			}
+			if config.Query != "" {
+				if err := validateSQLSafety(config.Query); err != nil {
+					return nil, fmt.Errorf("source %s query validation failed: %w", alias, err)
+				}
+			}
+		case "file":
+			if source.SourceID == nil || *source.SourceID == "" {
+				return nil, fmt.Errorf("source %s requires a file dataset", alias)
+			}
+		case "api":
+		default:
+			return nil, fmt.Errorf("unsupported source type: %s", source.SourceType)
+		}
+
+		step := &joinSource{source: source, alias: alias, config: config}
+		if i > 0 {
+			step.joinType = strings.ToLower(source.JoinType)
+			if step.joinType == "" {
+				step.joinType = "inner"
+			}
+			switch step.joinType {
+			case "inner", "left", "right", "full":
+			default:
+				return nil, fmt.Errorf("unsupported join type: %s", source.JoinType)
+			}
+
+			if source.JoinCondition == nil || strings.TrimSpace(*source.JoinCondition) == "" {
+				return nil, fmt.Errorf("join condition is required for source %s", alias)
+			}
+			keys, err := parseJoinCondition(*source.JoinCondition, seen, alias)
+			if err != nil {
+				return nil, err
+			}
+			step.keys = keys
+		}
+
+		seen[alias] = true
+		plan = append(plan, step)
+	}
+	return plan, nil
+}
+
+// parseJoinCondition accepts equalities between qualified columns joined by
+// AND, e.g. "o.customer_id = c.id AND o.region = c.region". Every equality
+// must compare the joined source with an earlier one.
+func parseJoinCondition(condition string, earlier map[string]bool, alias string) ([]joinKey, error) {
+	var keys []joinKey
+	for _, part := range joinConjunctionSplit.Split(strings.TrimSpace(condition), -1) {
+		sides := strings.Split(part, "=")
+		if len(sides) != 2 {
+			return nil, fmt.Errorf("join condition must be equalities joined by AND: %s", condition)
+		}
+		leftAlias, leftColumn, err := parseJoinColumn(sides[0])
+		if err != nil {
+			return nil, err
+		}
+		rightAlias, rightColumn, err := parseJoinColumn(sides[1])
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case rightAlias == alias && earlier[leftAlias]:
+		case leftAlias == alias && earlier[rightAlias]:
+			leftAlias, leftColumn, rightColumn = rightAlias, rightColumn, leftColumn
+		default:
+			return nil, fmt.Errorf("join condition %q must compare %s with an earlier source", strings.TrimSpace(part), alias)
+		}
+		keys = append(keys, joinKey{leftAlias: leftAlias, leftColumn: leftColumn, rightColumn: rightColumn})
+	}
+	return keys, nil
+}
+
+func parseJoinColumn(text string) (string, string, error) {
+	text = strings.TrimSpace(text)
+	if len(text) >= 2 && text[0] == '[' && text[len(text)-1] == ']' {
+		text = text[1 : len(text)-1]
+	}
+	alias, column, ok := strings.Cut(text, ".")
+	alias, column = strings.TrimSpace(alias), strings.TrimSpace(column)
+	if !ok || !sourceAliasPattern.MatchString(alias) || column == "" {
+		return "", "", fmt.Errorf("join column must be written as alias.column: %s", text)
+	}
+	return alias, column, nil
+}
+
+// joinOutputColumns names the joined columns. A column keeps its own name
+// unless several sources have it; then every copy is qualified as
+// alias.column.
+func joinOutputColumns(plan []*joinSource, columns [][]string) [][]string {
+	counts := make(map[string]int)
+	for _, sourceColumns := range columns {
+		for _, column := range sourceColumns {
+			counts[column]++
+		}
+	}
+
+	names := make([][]string, len(columns))
+	for i, sourceColumns := range columns {
+		names[i] = make([]string, len(sourceColumns))
+		for j, column := range sourceColumns {
+			if counts[column] > 1 {
+				names[i][j] = plan[i].alias + "." + column
+			} else {
+				names[i][j] = column
+			}
+		}
+	}
+	return names
+}
+
+// sqlJoinDatasourceID reports the datasource shared by every source, in which
+// case the join can run as a single SQL statement.
+func sqlJoinDatasourceID(plan []*joinSource) (string, bool) {
+	var id string
+	for _, step := range plan {
+		if step.source.SourceType != "datasource" {
+			return "", false
+		}
+		if id != "" && *step.source.SourceID != id {
+			return "", false
+		}
+		id = *step.source.SourceID
+	}
+	return id, id != ""
+}
+
+func sourceSQL(step *joinSource, dialect datasource.Dialect) string {
+	if step.config.Query != "" {
+		return step.config.Query
+	}
+	parts := strings.Split(step.config.Table, ".")
+	for i, part := range parts {
+		parts[i] = dialect.QuoteIdentifier(part)
+	}
+	return "SELECT * FROM " + strings.Join(parts, ".")
+}
+
+// buildSQLJoin composes the sources into one SELECT with generated JOINs. The
+// column lists are read from the database so duplicate names can be aliased.
+func buildSQLJoin(ctx context.Context, db *sql.DB, dialect datasource.Dialect, plan []*joinSource) (string, error) {
+	fragments := make([]string, len(plan))
+	columns := make([][]string, len(plan))
+	for i, step := range plan {
+		fragments[i] = sourceSQL(step, dialect)
+		rows, err := db.QueryContext(ctx, fmt.Sprintf("SELECT * FROM (%s) AS source_columns WHERE 1 = 0", fragments[i]))
+		if err != nil {
+			return "", fmt.Errorf("source %s: %w", step.alias, err)
+		}
+		columns[i], err = rows.Columns()
+		rows.Close()
+		if err != nil {
+			return "", fmt.Errorf("source %s: %w", step.alias, err)
+		}
+	}
+
+	names := joinOutputColumns(plan, columns)
+	var selects []string
+	for i, step := range plan {
+		for j, column := range columns[i] {
+			selects = append(selects, fmt.Sprintf("%s.%s AS %s",
+				dialect.QuoteIdentifier(step.alias), dialect.QuoteIdentifier(column), dialect.QuoteIdentifier(names[i][j])))
+		}
+	}
+
+	var from strings.Builder
+	fmt.Fprintf(&from, "(%s) AS %s", fragments[0], dialect.QuoteIdentifier(plan[0].alias))
+	for i, step := range plan[1:] {
+		if step.joinType == "full" && dialect.Name() == "mysql" {
+			return "", errors.New("full join is not supported by mysql")
+		}
+		conditions := make([]string, len(step.keys))
+		for k, key := range step.keys {
+			conditions[k] = fmt.Sprintf("%s.%s = %s.%s",
+				dialect.QuoteIdentifier(key.leftAlias), dialect.QuoteIdentifier(key.leftColumn),
+				dialect.QuoteIdentifier(step.alias), dialect.QuoteIdentifier(key.rightColumn))
+		}
+		fmt.Fprintf(&from, " %s JOIN (%s) AS %s ON %s",
+			strings.ToUpper(step.joinType), fragments[i+1], dialect.QuoteIdentifier(step.alias), strings.Join(conditions, " AND "))
+	}
+
+	return fmt.Sprintf("SELECT %s FROM %s", strings.Join(selects, ", "), from.String()), nil
+}
+
+// sourceRowLoader loads the rows of individual sources for in-process joins.
+// Each load is bounded by its datasource's query timeout, or by timeout for
+// sources without one.
+type sourceRowLoader struct {
+	datasetRepo    repository.DatasetRepository
+	datasourceRepo repository.DatasourceRepository
+	httpClient     *http.Client
+	pools          *datasource.PoolManager
+	timeout        time.Duration
+}
+
+// load returns the source's columns and at most limit+1 rows, so callers can
+// tell a truncated source from a complete one.
+func (l *sourceRowLoader) load(ctx context.Context, tenantID string, step *joinSource, limit int) ([]string, []map[string]interface{}, error) {
+	switch step.source.SourceType {
+	case "datasource":
+		ds, err := l.tenantDatasource(ctx, tenantID, step.source.SourceID)
+		if err != nil {
+			return nil, nil, err
+		}
+		pool, err := l.pools.Acquire(ctx, ds)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer pool.Release()
+		poolCtx, cancel := pool.WithTimeout(ctx, l.timeout)
+		defer cancel()
+
+		query := pool.Dialect.Limit(fmt.Sprintf("SELECT * FROM (%s) AS source_rows", sourceSQL(step, pool.Dialect)), limit+1, 0)
+		rows, err := pool.DB.QueryContext(poolCtx, query)
+		if err != nil {
+			return nil, nil, fmt.Errorf("source %s: %w", step.alias, pool.Check(err))
+		}
+		defer rows.Close()
+		return scanSourceRows(rows)
+
+	case "api":
+		ds, err := l.tenantDatasource(ctx, tenantID, step.source.SourceID)
+		if err != nil {
+			return nil, nil, err
+		}
+		config, err := parseAPIDatasetConfig(step.source.SourceConfig)
+		if err != nil {
+			return nil, nil, err
+		}
+		apiCtx, cancel := datasource.WithQueryTimeout(ctx, ds, l.timeout)
+		defer cancel()
+		rows, err := fetchAPIRows(apiCtx, l.httpClient, ds, config)
+		if err != nil {
+			return nil, nil, fmt.Errorf("source %s: %w", step.alias, err)
+		}
+		if len(rows) > limit+1 {
+			rows = rows[:limit+1]
+		}
+		return rowColumns(rows), rows, nil
+
+	case "file":
+		fileDataset, err := l.datasetRepo.GetByIDWithFields(ctx, *step.source.SourceID)
+		if err != nil || fileDataset.TenantID != tenantID || fileDataset.Type != "file" {
+			return nil, nil, fmt.Errorf("source %s: file dataset not found", step.alias)
+		}
+		fileCtx, cancel := datasource.WithQueryTimeout(ctx, nil, l.timeout)
+		defer cancel()
+		table, err := loadFileTable(fileCtx, fileDataset)
+		if err != nil {
+			return nil, nil, fmt.Errorf("source %s: %w", step.alias, err)
+		}
+		if len(table.records) > limit+1 {
+			table.records = table.records[:limit+1]
+		}
+		return table.columns, fileRows(fileDataset, table), nil
+	}
+	return nil, nil, fmt.Errorf("unsupported source type: %s", step.source.SourceType)
+}
+
+// tenantDatasource resolves an optional datasource reference within a tenant.
+func (l *sourceRowLoader) tenantDatasource(ctx context.Context, tenantID string, id *string) (*models.DataSource, error) {
+	if id == nil || *id == "" {
+		return nil, nil
+	}
+	ds, err := l.datasourceRepo.GetByID(ctx, *id)
+	if err != nil || ds.TenantID != tenantID {
+		return nil, errors.New("datasource not found")
+	}
+	return ds, nil
+}
+
+// loadJoined loads every source and hash-joins them. With strict set, a
+// source over limit rows is an error; otherwise it is truncated, which is
+// enough for schema inference.
+func (l *sourceRowLoader) loadJoined(ctx context.Context, tenantID string, plan []*joinSource, limit int, strict bool) ([]string, []map[string]interface{}, error) {
+	columns := make([][]string, len(plan))
+	sourceRows := make([][]map[string]interface{}, len(plan))
+	for i, step := range plan {
+		stepColumns, rows, err := l.load(ctx, tenantID, step, limit)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(rows) > limit {
+			if strict {
+				return nil, nil, fmt.Errorf("source %s exceeds %d rows", step.alias, limit)
+			}
+			rows = rows[:limit]
+		}
+		columns[i], sourceRows[i] = stepColumns, rows
+	}
+
+	joined, err := hashJoinSources(plan, sourceRows, datasetJoinMaxRows)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	names := joinOutputColumns(plan, columns)
+	var outputColumns []string
+	for _, sourceNames := range names {
+		outputColumns = append(outputColumns, sourceNames...)
+	}
+
+	rows := make([]map[string]interface{}, len(joined))
+	for r, parts := range joined {
+		row := make(map[string]interface{}, len(outputColumns))
+		for i, part := range parts {
+			for j, column := range columns[i] {
+				var value interface{}
+				if part != nil {
+					value = part[column]
+				}
+				row[names[i][j]] = value
+			}
+		}
+		rows[r] = row
+	}
+	return outputColumns, rows, nil
+}
+
+// hashJoinSources joins source rows step by step. Each result row holds one
+// part per source, nil where an outer join found no match.
+func hashJoinSources(plan []*joinSource, sourceRows [][]map[string]interface{}, maxRows int) ([][]map[string]interface{}, error) {
+	positions := make(map[string]int, len(plan))
+	for i, step := range plan {
+		positions[step.alias] = i
+	}
+
+	result := make([][]map[string]interface{}, len(sourceRows[0]))
+	for i, row := range sourceRows[0] {
+		result[i] = []map[string]interface{}{row}
+	}
+
+	for i := 1; i < len(plan); i++ {
+		step, right := plan[i], sourceRows[i]
+
+		buckets := make(map[string][]int, len(right))
+		for r, row := range right {
+			values := make([]interface{}, len(step.keys))
+			for k, key := range step.keys {
+				values[k] = row[key.rightColumn]
+			}
+			if hash, ok := joinHash(values); ok {
+				buckets[hash] = append(buckets[hash], r)
+			}
+		}
+
+		matched := make([]bool, len(right))
+		next := make([][]map[string]interface{}, 0, len(result))
+		for _, left := range result {
+			values := make([]interface{}, len(step.keys))
+			for k, key := range step.keys {
+				if part := left[positions[key.leftAlias]]; part != nil {
+					values[k] = part[key.leftColumn]
+				}
+			}
+
+			var hits []int
+			if hash, ok := joinHash(values); ok {
+				hits = buckets[hash]
+			}
+			for _, r := range hits {
+				matched[r] = true
+				next = append(next, appendJoinPart(left, right[r]))
+			}
+			if len(hits) == 0 && (step.joinType == "left" || step.joinType == "full") {
+				next = append(next, appendJoinPart(left, nil))
+			}
+			if len(next) > maxRows {
+				return nil, fmt.Errorf("join result exceeds %d rows", maxRows)
+			}
+		}
+
+		if step.joinType == "right" || step.joinType == "full" {
+			for r, row := range right {
+				if matched[r] {
+					continue
+				}
+				parts := make([]map[string]interface{}, i+1)
+				parts[i] = row
+				next = append(next, parts)
+			}
+			if len(next) > maxRows {
+				return nil, fmt.Errorf("join result exceeds %d rows", maxRows)
+			}
+		}
+		result = next
+	}
+	return result, nil
+}
+
+func appendJoinPart(left []map[string]interface{}, part map[string]interface{}) []map[string]interface{} {
+	parts := make([]map[string]interface{}, len(left), len(left)+1)
+	copy(parts, left)
+	return append(parts, part)
+}
+
+// joinHash encodes key values for bucketing. Values compare by their text, so
+// 1, 1.0 and "1" from different sources match; a NULL key never matches.
+func joinHash(values []interface{}) (string, bool) {
+	parts := make([]string, len(values))
+	for i, value := range values {
+		value = normalizeExpressionValue(value)
+		if value == nil {
+			return "", false
+		}
+		parts[i] = formatExpressionValue(value)
+	}
+	return strings.Join(parts, "\x1f"), true
+}
+
+func scanSourceRows(rows *sql.Rows) ([]string, []map[string]interface{}, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var result []map[string]interface{}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		valuePtrs := make([]interface{}, len(columns))
+		for i := range columns {
+			valuePtrs[i] = &values[i]
+		}
+		if err := rows.Scan(valuePtrs...); err != nil {
+			return nil, nil, err
+		}
+
+		row := make(map[string]interface{}, len(columns))
+		for i, col := range columns {
+			if b, ok := values[i].([]byte); ok {
+				row[col] = string(b)
+			} else {
+				row[col] = values[i]
+			}
+		}
+		result = append(result, row)
+	}
+	return columns, result, rows.Err()
+}
+
+// rowColumns lists the keys present in any row, sorted for a stable order.
+func rowColumns(rows []map[string]interface{}) []string {
+	seen := make(map[string]bool)
+	var columns []string
+	for _, row := range rows {
+		for name := range row {
+			if !seen[name] {
+				seen[name] = true
+				columns = append(columns, name)
+			}
+		}
+	}
+	sort.Strings(columns)
+	return columns
+}
@@ -0,0 +1,284 @@
+package dataset
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/gujiaweiguo/goreport/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func strPtr(value string) *string {
+	return &value
+}
+
+func TestBuildJoinPlan(t *testing.T) {
+	plan, err := buildJoinPlan([]models.DatasetSource{
+		{SourceType: "file", SourceID: strPtr("file-1"), SourceConfig: `{"alias":"r"}`, JoinType: "LEFT", JoinCondition: strPtr("o.region = r.region and [o.year] = [r.year]"), SortIndex: 1},
+		{SourceType: "datasource", SourceID: strPtr("ds-1"), SourceConfig: `{"alias":"o","table":"orders"}`, SortIndex: 0},
+	})
+	require.NoError(t, err)
+	require.Len(t, plan, 2)
+	assert.Equal(t, "o", plan[0].alias)
+	assert.Equal(t, "left", plan[1].joinType)
+	assert.Equal(t, []joinKey{
+		{leftAlias: "o", leftColumn: "region", rightColumn: "region"},
+		{leftAlias: "o", leftColumn: "year", rightColumn: "year"},
+	}, plan[1].keys)
+
+	tests := []struct {
+		name    string
+		sources []models.DatasetSource
+		wantErr string
+	}{
+		{
+			name:    "unsupported source type",
+			sources: []models.DatasetSource{{SourceType: "ftp"}},
+			wantErr: "unsupported source type",
+		},
+		{
+			name:    "datasource without query",
+			sources: []models.DatasetSource{{SourceType: "datasource", SourceID: strPtr("ds-1")}},
+			wantErr: "requires a query or table",
+		},
+		{
+			name:    "unsafe query",
+			sources: []models.DatasetSource{{SourceType: "datasource", SourceID: strPtr("ds-1"), SourceConfig: `{"query":"DELETE FROM orders"}`}},
+			wantErr: "query validation failed",
+		},
+		{
+			name:    "file without dataset",
+			sources: []models.DatasetSource{{SourceType: "file"}},
+			wantErr: "requires a file dataset",
+		},
+		{
+			name: "missing join condition",
+			sources: []models.DatasetSource{
+				{SourceType: "api", SortIndex: 0},
+				{SourceType: "api", SortIndex: 1},
+			},
+			wantErr: "join condition is required",
+		},
+		{
+			name: "unknown alias",
+			sources: []models.DatasetSource{
+				{SourceType: "api", SortIndex: 0},
+				{SourceType: "api", JoinCondition: strPtr("x.id = t2.id"), SortIndex: 1},
+			},
+			wantErr: "earlier source",
+		},
+		{
+			name: "not an equality",
+			sources: []models.DatasetSource{
+				{SourceType: "api", SortIndex: 0},
+				{SourceType: "api", JoinCondition: strPtr("t1.id > t2.id"), SortIndex: 1},
+			},
+			wantErr: "equalities",
+		},
+		{
+			name: "unsupported join type",
+			sources: []models.DatasetSource{
+				{SourceType: "api", SortIndex: 0},
+				{SourceType: "api", JoinType: "cross", JoinCondition: strPtr("t1.id = t2.id"), SortIndex: 1},
+			},
+			wantErr: "unsupported join type",
+		},
+		{
+			name: "duplicate alias",
+			sources: []models.DatasetSource{
+				{SourceType: "api", SourceConfig: `{"alias":"a"}`, SortIndex: 0},
+				{SourceType: "api", SourceConfig: `{"alias":"a"}`, JoinCondition: strPtr("a.id = a.id"), SortIndex: 1},
+			},
+			wantErr: "duplicate source alias",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := buildJoinPlan(tt.sources)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestHashJoinSources(t *testing.T) {
+	left := []map[string]interface{}{{"k": int64(1)}, {"k": int64(2)}, {"k": nil}}
+	right := []map[string]interface{}{{"k": float64(1)}, {"k": "3"}, {"k": nil}}
+
+	tests := []struct {
+		joinType string
+		want     int
+	}{
+		{joinType: "inner", want: 1},
+		{joinType: "left", want: 3},
+		{joinType: "right", want: 3},
+		{joinType: "full", want: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.joinType, func(t *testing.T) {
+			plan := []*joinSource{
+				{alias: "a"},
+				{alias: "b", joinType: tt.joinType, keys: []joinKey{{leftAlias: "a", leftColumn: "k", rightColumn: "k"}}},
+			}
+			rows, err := hashJoinSources(plan, [][]map[string]interface{}{left, right}, 100)
+			require.NoError(t, err)
+			require.Len(t, rows, tt.want)
+			// 1 and 1.0 match across sources; NULL keys never do.
+			assert.Equal(t, int64(1), rows[0][0]["k"])
+			assert.Equal(t, float64(1), rows[0][1]["k"])
+		})
+	}
+
+	many := []map[string]interface{}{{"k": 1}, {"k": 1}, {"k": 1}}
+	plan := []*joinSource{
+		{alias: "a"},
+		{alias: "b", joinType: "inner", keys: []joinKey{{leftAlias: "a", leftColumn: "k", rightColumn: "k"}}},
+	}
+	_, err := hashJoinSources(plan, [][]map[string]interface{}{many, many}, 5)
+	assert.ErrorContains(t, err, "join result exceeds 5 rows")
+}
+
+// newJoinTestDatasets returns an API source of orders and a file dataset of
+// region managers, with a repository serving both and the joined dataset.
+func newJoinTestDatasets(t *testing.T) (*mockDatasetRepository, []SourceRequest) {
+	t.Helper()
+	useTestFileStorage(t)
+	server := newAPITestServer(t, nil)
+
+	storage, err := getFileStorage()
+	require.NoError(t, err)
+	key, err := storage.Save("tenant-1", "file-1", "regions.csv", strings.NewReader("region,manager,id\neast,Erin,10\nwest,Will,20\nsouth,Sam,30\n"))
+	require.NoError(t, err)
+
+	fileDataset := &models.Dataset{
+		ID:       "file-1",
+		TenantID: "tenant-1",
+		Type:     "file",
+		Config:   `{"storageKey":"` + key + `","format":"csv"}`,
+		Fields: []models.DatasetField{
+			{Name: "region", DataType: "string"},
+			{Name: "manager", DataType: "string"},
+			{Name: "id", DataType: "number"},
+		},
+	}
+	datasetRepo := new(mockDatasetRepository)
+	datasetRepo.On("GetByIDWithFields", mock.Anything, "file-1").Return(fileDataset, nil)
+
+	sources := []SourceRequest{
+		{SourceType: "api", SourceConfig: json.RawMessage(`{"alias":"o","url":"` + server.URL + `","dataPath":"data.items"}`)},
+		{SourceType: "file", SourceID: strPtr("file-1"), SourceConfig: json.RawMessage(`{"alias":"r"}`), JoinType: "left", JoinCondition: strPtr("r.region = o.region")},
+	}
+	return datasetRepo, sources
+}
+
+func TestQueryExecutor_JoinedDataset_HashJoin(t *testing.T) {
+	datasetRepo, requests := newJoinTestDatasets(t)
+	sources, err := buildDatasetSources("joined-1", requests)
+	require.NoError(t, err)
+
+	bonus := "[amount] * 2"
+	joined := &models.Dataset{
+		ID:       "joined-1",
+		TenantID: "tenant-1",
+		Type:     "sql",
+		Sources:  sources,
+		Fields: []models.DatasetField{
+			{Name: "o.id", DataType: "number"},
+			{Name: "amount", DataType: "number"},
+			{Name: "manager", DataType: "string"},
+			{Name: "bonus", DataType: "number", IsComputed: true, Expression: &bonus},
+		},
+	}
+	datasetRepo.On("GetByIDWithFields", mock.Anything, "joined-1").Return(joined, nil)
+	executor := NewQueryExecutor(datasetRepo, new(mockDatasetFieldRepository), new(mockDatasourceRepository), NewSQLExpressionBuilder(), NewComputedFieldCache())
+
+	resp, err := executor.Query(context.Background(), &QueryRequest{
+		DatasetID: "joined-1",
+		Fields:    []string{"o.id", "o.region", "r.region", "manager", "r.id", "bonus"},
+		SortBy:    "o.id",
+		SortOrder: "asc",
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.Data, 4)
+	assert.Equal(t, int64(4), resp.Total)
+	assert.Equal(t, "Erin", resp.Data[0]["manager"])
+	assert.Equal(t, int64(10), resp.Data[0]["r.id"])
+	assert.Equal(t, float64(241), resp.Data[0]["bonus"])
+	assert.Equal(t, "Will", resp.Data[1]["manager"])
+	assert.Equal(t, "north", resp.Data[3]["o.region"])
+	assert.Nil(t, resp.Data[3]["r.region"])
+	assert.Nil(t, resp.Data[3]["manager"])
+
+	resp, err = executor.Query(context.Background(), &QueryRequest{
+		DatasetID:    "joined-1",
+		Filters:      []Filter{{Field: "manager", Operator: "eq", Value: "Erin"}},
+		GroupBy:      []string{"manager"},
+		Aggregations: map[string]Aggregation{"orders": {Field: "*", Function: "COUNT"}},
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.Data, 1)
+	assert.Equal(t, int64(2), resp.Data[0]["orders"])
+}
+
+func TestQueryExecutor_JoinedDataset_SourceTenant(t *testing.T) {
+	datasetRepo, requests := newJoinTestDatasets(t)
+	sources, err := buildDatasetSources("joined-1", requests)
+	require.NoError(t, err)
+
+	joined := &models.Dataset{ID: "joined-1", TenantID: "tenant-2", Type: "sql", Sources: sources}
+	datasetRepo.On("GetByIDWithFields", mock.Anything, "joined-1").Return(joined, nil)
+	executor := NewQueryExecutor(datasetRepo, new(mockDatasetFieldRepository), new(mockDatasourceRepository), NewSQLExpressionBuilder(), NewComputedFieldCache())
+
+	_, err = executor.Query(context.Background(), &QueryRequest{DatasetID: "joined-1"})
+	assert.ErrorContains(t, err, "file dataset not found")
+}
+
+func TestDatasetService_Create_JoinedDataset(t *testing.T) {
+	datasetRepo, requests := newJoinTestDatasets(t)
+
+	stored := &models.Dataset{}
+	fieldRepo := &memoryFieldRepository{dataset: stored}
+	datasetRepo.On("Create", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		*stored = *args.Get(1).(*models.Dataset)
+	}).Return(nil)
+	datasetRepo.On("GetByIDWithFields", mock.Anything, mock.Anything).Return(stored, nil)
+
+	sourceRepo := new(mockDatasetSourceRepository)
+	sourceRepo.On("List", mock.Anything, mock.Anything).Return([]*models.DatasetSource{}, nil)
+	sourceRepo.On("Create", mock.Anything, mock.Anything).Return(nil)
+
+	svc := NewService(datasetRepo, fieldRepo, sourceRepo, new(mockDatasourceRepository))
+	dataset, err := svc.Create(context.Background(), &CreateRequest{
+		Name:     "orders with managers",
+		Type:     "sql",
+		Sources:  requests,
+		TenantID: "tenant-1",
+	})
+	require.NoError(t, err)
+	sourceRepo.AssertNumberOfCalls(t, "Create", 2)
+
+	var names []string
+	types := make(map[string]string)
+	for _, field := range dataset.Fields {
+		names = append(names, field.Name)
+		types[field.Name] = field.DataType
+	}
+	assert.Equal(t, []string{"amount", "customer.level", "customer.name", "o.id", "qty", "o.region", "r.region", "manager", "r.id"}, names)
+	assert.Equal(t, "number", types["o.id"])
+	assert.Equal(t, "number", types["r.id"])
+	assert.Equal(t, "string", types["manager"])
+
+	_, err = svc.Create(context.Background(), &CreateRequest{
+		Name:     "broken",
+		Type:     "sql",
+		Sources:  []SourceRequest{requests[0], {SourceType: "api", JoinCondition: strPtr("o.id < t2.id")}},
+		TenantID: "tenant-1",
+	})
+	assert.ErrorContains(t, err, "equalities")
+}
@@ -0,0 +1,498 @@
+package dataset
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gujiaweiguo/goreport/internal/models"
+)
+
+// runInMemoryQuery applies a QueryRequest to rows that were loaded outside a
+// database. It mirrors querySQLDataset: filters apply before grouping, Total
+// counts the filtered rows, and the page is cut after sorting.
+func runInMemoryQuery(dataset *models.Dataset, rows []map[string]interface{}, req *QueryRequest, evaluator APIExpressionBuilder) (*QueryResponse, error) {
+	evaluated, err := evaluateInMemoryQuery(dataset, rows, req, evaluator)
+	if err != nil {
+		return nil, err
+	}
+	result, total, aggregationAliases := evaluated.rows, evaluated.total, evaluated.aggregationAliases
+
+	page, pageSize := normalizePagination(req.Page, req.PageSize)
+	start := (page - 1) * pageSize
+	var data []map[string]interface{}
+	if start < len(result) {
+		end := start + pageSize
+		if end > len(result) {
+			end = len(result)
+		}
+		data = result[start:end]
+	}
+
+	var aggregations map[string]interface{}
+	if len(aggregationAliases) > 0 && len(data) > 0 {
+		aggregations = make(map[string]interface{})
+		for _, alias := range aggregationAliases {
+			if val, ok := data[0][alias]; ok {
+				aggregations[alias] = val
+			}
+		}
+	}
+
+	return &QueryResponse{
+		Data:         data,
+		Total:        total,
+		Page:         page,
+		PageSize:     pageSize,
+		Aggregations: aggregations,
+	}, nil
+}
+
+// inMemoryResult is a QueryRequest evaluated over in-memory rows, sorted but
+// not yet paged.
+type inMemoryResult struct {
+	rows               []map[string]interface{}
+	columns            []string
+	total              int64
+	aggregationAliases []string
+}
+
+func evaluateInMemoryQuery(dataset *models.Dataset, rows []map[string]interface{}, req *QueryRequest, evaluator APIExpressionBuilder) (*inMemoryResult, error) {
+	if err := applyComputedFields(dataset, rows, evaluator); err != nil {
+		return nil, err
+	}
+
+	filtered := make([]map[string]interface{}, 0, len(rows))
+	for _, row := range rows {
+		matched, err := matchFilters(row, req.Filters)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter condition: %w", err)
+		}
+		if matched {
+			filtered = append(filtered, row)
+		}
+	}
+	total := int64(len(filtered))
+
+	selectedFields := req.Fields
+	if len(selectedFields) == 0 && len(req.GroupBy) > 0 {
+		selectedFields = req.GroupBy
+	}
+
+	result := filtered
+	columns := selectedFields
+	var aggregationAliases []string
+	if len(req.GroupBy) > 0 || len(req.Aggregations) > 0 {
+		grouped, aliases, err := groupRows(filtered, selectedFields, req.GroupBy, req.Aggregations)
+		if err != nil {
+			return nil, err
+		}
+		result = grouped
+		aggregationAliases = aliases
+		if len(req.GroupBy) == 0 {
+			columns = aliases
+		} else {
+			columns = append(append([]string(nil), selectedFields...), aliases...)
+		}
+		sortRows(result, req.SortBy, req.SortOrder)
+	} else {
+		// Sort before projecting so rows can be ordered by unselected columns.
+		sortRows(result, req.SortBy, req.SortOrder)
+		if len(selectedFields) > 0 {
+			result = projectRows(result, selectedFields)
+		} else {
+			columns = rowColumnNames(dataset, result)
+		}
+	}
+
+	return &inMemoryResult{
+		rows:               result,
+		columns:            columns,
+		total:              total,
+		aggregationAliases: aggregationAliases,
+	}, nil
+}
+
+// rowColumnNames lists the columns of unprojected rows: the dataset's fields
+// in order, then any other keys the rows carry, sorted.
+func rowColumnNames(dataset *models.Dataset, rows []map[string]interface{}) []string {
+	seen := make(map[string]bool)
+	var columns []string
+	for _, field := range dataset.Fields {
+		if field.IsGroupingField || seen[field.Name] {
+			continue
+		}
+		seen[field.Name] = true
+		columns = append(columns, field.Name)
+	}
+
+	var extra []string
+	for _, row := range rows {
+		for key := range row {
+			if !seen[key] {
+				seen[key] = true
+				extra = append(extra, key)
+			}
+		}
+	}
+	sort.Strings(extra)
+	return append(columns, extra...)
+}
+
+// applyComputedFields evaluates computed fields row by row, ordering them so
+// that fields referencing other computed fields run after their inputs.
+func applyComputedFields(dataset *models.Dataset, rows []map[string]interface{}, evaluator APIExpressionBuilder) error {
+	var baseFields []string
+	computed := make(map[string]string)
+	for _, field := range dataset.Fields {
+		switch {
+		case field.IsGroupingField:
+		case field.IsComputed && field.Expression != nil:
+			computed[field.Name] = *field.Expression
+		case !field.IsComputed:
+			baseFields = append(baseFields, field.Name)
+		}
+	}
+	if len(computed) == 0 {
+		return nil
+	}
+
+	order, err := orderComputedFields(computed)
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		// Sparse JSON records may omit keys; treat them as NULL columns.
+		for _, name := range baseFields {
+			if _, ok := row[name]; !ok {
+				row[name] = nil
+			}
+		}
+		for _, name := range order {
+			value, err := evaluator.Evaluate(computed[name], row)
+			if err != nil {
+				return fmt.Errorf("computed field %s: %w", name, err)
+			}
+			row[name] = value
+		}
+	}
+	return nil
+}
+
+func orderComputedFields(computed map[string]string) ([]string, error) {
+	names := make([]string, 0, len(computed))
+	for name := range computed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var order []string
+	state := make(map[string]int)
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case 1:
+			return fmt.Errorf("circular dependency detected for field: %s", name)
+		case 2:
+			return nil
+		}
+		state[name] = 1
+		node, err := parseExpression(computed[name])
+		if err != nil {
+			return fmt.Errorf("computed field %s: %w", name, err)
+		}
+		for _, ref := range collectFieldReferences(node) {
+			if _, ok := computed[ref]; ok {
+				if err := visit(ref); err != nil {
+					return err
+				}
+			}
+		}
+		state[name] = 2
+		order = append(order, name)
+		return nil
+	}
+
+	for _, name := range names {
+		if err := visit(name); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+func matchFilters(row map[string]interface{}, filters []Filter) (bool, error) {
+	for _, filter := range filters {
+		matched, err := matchFilter(row, filter)
+		if err != nil || !matched {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// matchFilter follows SQL semantics: a NULL column never matches.
+func matchFilter(row map[string]interface{}, filter Filter) (bool, error) {
+	value := normalizeExpressionValue(row[filter.Field])
+
+	if filter.Operator == "in" {
+		inValues, ok := normalizeINValues(filter.Value)
+		if !ok || len(inValues) == 0 {
+			return false, fmt.Errorf("field %s expects non-empty array for IN", filter.Field)
+		}
+		if value == nil {
+			return false, nil
+		}
+		for _, candidate := range inValues {
+			candidate = normalizeExpressionValue(candidate)
+			if candidate == nil {
+				continue
+			}
+			if cmp, err := compareExpressionValues(value, candidate, 0); err == nil && cmp == 0 {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	target := normalizeExpressionValue(filter.Value)
+	if value == nil || target == nil {
+		return false, nil
+	}
+
+	if filter.Operator == "like" {
+		pattern, err := likePattern(formatExpressionValue(target))
+		if err != nil {
+			return false, err
+		}
+		return pattern.MatchString(formatExpressionValue(value)), nil
+	}
+
+	cmp, err := compareExpressionValues(value, target, 0)
+	if err != nil {
+		// Values that cannot be coerced are simply not equal, as in SQL.
+		return filter.Operator == "neq", nil
+	}
+
+	switch filter.Operator {
+	case "neq":
+		return cmp != 0, nil
+	case "gt":
+		return cmp > 0, nil
+	case "gte":
+		return cmp >= 0, nil
+	case "lt":
+		return cmp < 0, nil
+	case "lte":
+		return cmp <= 0, nil
+	default:
+		return cmp == 0, nil
+	}
+}
+
+// likePattern converts a SQL LIKE pattern into a case-insensitive regexp,
+// matching MySQL's default collation.
+func likePattern(pattern string) (*regexp.Regexp, error) {
+	var expr strings.Builder
+	expr.WriteString("(?is)^")
+	for _, ch := range pattern {
+		switch ch {
+		case '%':
+			expr.WriteString(".*")
+		case '_':
+			expr.WriteString(".")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(ch)))
+		}
+	}
+	expr.WriteString("$")
+	return regexp.Compile(expr.String())
+}
+
+func projectRows(rows []map[string]interface{}, fields []string) []map[string]interface{} {
+	projected := make([]map[string]interface{}, len(rows))
+	for i, row := range rows {
+		out := make(map[string]interface{}, len(fields))
+		for _, field := range fields {
+			out[field] = row[field]
+		}
+		projected[i] = out
+	}
+	return projected
+}
+
+type aggregateState struct {
+	function string
+	field    string
+	count    int64
+	sum      float64
+	numeric  int64
+	best     interface{}
+}
+
+func (s *aggregateState) add(row map[string]interface{}) error {
+	if s.field == "*" {
+		s.count++
+		return nil
+	}
+
+	value := normalizeExpressionValue(row[s.field])
+	if value == nil {
+		return nil
+	}
+	s.count++
+
+	switch s.function {
+	case "SUM", "AVG":
+		number, err := expressionNumber(value, 0)
+		if err != nil {
+			return fmt.Errorf("cannot %s non-numeric field %s", strings.ToLower(s.function), s.field)
+		}
+		s.sum += number
+		s.numeric++
+	case "MAX", "MIN":
+		if s.best == nil {
+			s.best = value
+			return nil
+		}
+		cmp, err := compareExpressionValues(value, s.best, 0)
+		if err != nil {
+			return err
+		}
+		if (s.function == "MAX" && cmp > 0) || (s.function == "MIN" && cmp < 0) {
+			s.best = value
+		}
+	}
+	return nil
+}
+
+func (s *aggregateState) result() interface{} {
+	switch s.function {
+	case "COUNT":
+		return s.count
+	case "SUM":
+		if s.numeric == 0 {
+			return nil
+		}
+		return s.sum
+	case "AVG":
+		if s.numeric == 0 {
+			return nil
+		}
+		return s.sum / float64(s.numeric)
+	default:
+		return s.best
+	}
+}
+
+// groupRows groups by the GroupBy columns and computes aggregations. Without
+// GroupBy every row falls into a single group that only carries aggregates.
+func groupRows(rows []map[string]interface{}, selectedFields, groupBy []string, aggregations map[string]Aggregation) ([]map[string]interface{}, []string, error) {
+	aliases := make([]string, 0, len(aggregations))
+	for alias, agg := range aggregations {
+		switch strings.ToUpper(agg.Function) {
+		case "SUM", "AVG", "COUNT", "MAX", "MIN":
+		default:
+			return nil, nil, fmt.Errorf("unsupported aggregation function: %s", agg.Function)
+		}
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+
+	type group struct {
+		first  map[string]interface{}
+		states map[string]*aggregateState
+	}
+	newGroup := func(first map[string]interface{}) *group {
+		g := &group{first: first, states: make(map[string]*aggregateState, len(aliases))}
+		for _, alias := range aliases {
+			agg := aggregations[alias]
+			g.states[alias] = &aggregateState{function: strings.ToUpper(agg.Function), field: agg.Field}
+		}
+		return g
+	}
+
+	var order []string
+	groups := make(map[string]*group)
+	if len(groupBy) == 0 {
+		order = append(order, "")
+		groups[""] = newGroup(nil)
+	}
+
+	for _, row := range rows {
+		key := ""
+		if len(groupBy) > 0 {
+			parts := make([]string, len(groupBy))
+			for i, field := range groupBy {
+				value := normalizeExpressionValue(row[field])
+				if value == nil {
+					parts[i] = "\x00"
+				} else {
+					parts[i] = fmt.Sprintf("%T:%s", value, formatExpressionValue(value))
+				}
+			}
+			key = strings.Join(parts, "\x1f")
+		}
+
+		g, ok := groups[key]
+		if !ok {
+			g = newGroup(row)
+			groups[key] = g
+			order = append(order, key)
+		}
+		for _, alias := range aliases {
+			if err := g.states[alias].add(row); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	result := make([]map[string]interface{}, 0, len(order))
+	for _, key := range order {
+		g := groups[key]
+		out := make(map[string]interface{})
+		if len(groupBy) > 0 {
+			for _, field := range selectedFields {
+				out[field] = g.first[field]
+			}
+		}
+		for _, alias := range aliases {
+			out[alias] = g.states[alias].result()
+		}
+		result = append(result, out)
+	}
+	return result, aliases, nil
+}
+
+// sortRows orders rows by one column; NULLs sort first ascending, as MySQL does.
+func sortRows(rows []map[string]interface{}, sortBy, sortOrder string) {
+	if sortBy == "" {
+		return
+	}
+	desc := sortOrder == "desc"
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		a := normalizeExpressionValue(rows[i][sortBy])
+		b := normalizeExpressionValue(rows[j][sortBy])
+		var cmp int
+		switch {
+		case a == nil && b == nil:
+			return false
+		case a == nil:
+			cmp = -1
+		case b == nil:
+			cmp = 1
+		default:
+			var err error
+			if cmp, err = compareExpressionValues(a, b, 0); err != nil {
+				cmp = strings.Compare(formatExpressionValue(a), formatExpressionValue(b))
+			}
+		}
+		if desc {
+			return cmp > 0
+		}
+		return cmp < 0
+	})
+}
@@ -6,15 +6,20 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
 	"reflect"
 	"strings"
 
+	"github.com/gujiaweiguo/goreport/internal/datasource"
 	"github.com/gujiaweiguo/goreport/internal/models"
 	"github.com/gujiaweiguo/goreport/internal/repository"
 )
 
 type QueryExecutor interface {
 	Query(ctx context.Context, req *QueryRequest) (*QueryResponse, error)
+	// Export streams every row matching req to w, ignoring paging, and
+	// returns the number of rows written.
+	Export(ctx context.Context, tenantID string, req *QueryRequest, w RowWriter) (int64, error)
 }
 
 type QueryRequest struct {
@@ -27,6 +32,9 @@ type QueryRequest struct {
 	PageSize     int                    `json:"pageSize"`
 	GroupBy      []string               `json:"groupBy"`
 	Aggregations map[string]Aggregation `json:"aggregations"`
+	// Drill is resolved against the dataset's drill paths before the query
+	// runs, rewriting the request in place; see applyDrill.
+	Drill *DrillState `json:"drill,omitempty"`
 }
 
 type Filter struct {
@@ -54,7 +62,11 @@ type queryExecutor struct {
 	fieldRepo      repository.DatasetFieldRepository
 	datasourceRepo repository.DatasourceRepository
 	sqlBuilder     SQLExpressionBuilder
+	apiBuilder     APIExpressionBuilder
 	cache          *ComputedFieldCache
+	dialect        datasource.Dialect
+	httpClient     *http.Client
+	pools          *datasource.PoolManager
 }
 
 func NewQueryExecutor(
@@ -69,7 +81,10 @@ func NewQueryExecutor(
 		fieldRepo:      fieldRepo,
 		datasourceRepo: datasourceRepo,
 		sqlBuilder:     sqlBuilder,
+		apiBuilder:     NewAPIExpressionBuilder(),
 		cache:          cache,
+		httpClient:     newAPIHTTPClient(),
+		pools:          datasource.Pools(),
 	}
 }
 
@@ -78,36 +93,173 @@ func (q *queryExecutor) Query(ctx context.Context, req *QueryRequest) (*QueryRes
 	if err != nil {
 		return nil, fmt.Errorf("dataset not found: %w", err)
 	}
+	if err := applyDrill(dataset, req); err != nil {
+		return nil, err
+	}
 
+	if len(dataset.Sources) > 0 {
+		return q.queryJoinedDataset(ctx, dataset, req)
+	}
 	if dataset.Type == "sql" && dataset.DatasourceID != nil {
 		return q.querySQLDataset(ctx, dataset, req)
 	}
+	if dataset.Type == "api" {
+		return q.queryAPIDataset(ctx, dataset, req)
+	}
+	if dataset.Type == "file" {
+		return q.queryFileDataset(ctx, dataset, req)
+	}
 
 	return nil, fmt.Errorf("unsupported dataset type: %s", dataset.Type)
 }
 
+// queryAPIDataset fetches the whole API result and runs the request over it
+// in memory; APIs give no general way to push filters or paging down.
+func (q *queryExecutor) queryAPIDataset(ctx context.Context, dataset *models.Dataset, req *QueryRequest) (*QueryResponse, error) {
+	rows, err := q.loadDatasetRows(ctx, dataset)
+	if err != nil {
+		return nil, err
+	}
+	return runInMemoryQuery(dataset, rows, req, q.apiBuilder)
+}
+
+// queryFileDataset parses the uploaded file and runs the request in memory,
+// with the same semantics as API datasets.
+func (q *queryExecutor) queryFileDataset(ctx context.Context, dataset *models.Dataset, req *QueryRequest) (*QueryResponse, error) {
+	rows, err := q.loadDatasetRows(ctx, dataset)
+	if err != nil {
+		return nil, err
+	}
+	return runInMemoryQuery(dataset, rows, req, q.apiBuilder)
+}
+
+// loadDatasetRows loads every row of an API or file dataset.
+func (q *queryExecutor) loadDatasetRows(ctx context.Context, dataset *models.Dataset) ([]map[string]interface{}, error) {
+	if dataset.Type == "file" {
+		queryCtx, cancel := withDatasetQueryTimeout(ctx)
+		defer cancel()
+		table, err := loadFileTable(queryCtx, dataset)
+		if err != nil {
+			return nil, err
+		}
+		return fileRows(dataset, table), nil
+	}
+
+	config, err := parseAPIDatasetConfig(dataset.Config)
+	if err != nil {
+		return nil, err
+	}
+	var ds *models.DataSource
+	if dataset.DatasourceID != nil {
+		if ds, err = q.datasourceRepo.GetByID(ctx, *dataset.DatasourceID); err != nil {
+			return nil, fmt.Errorf("datasource not found: %w", err)
+		}
+	}
+	queryCtx, cancel := datasource.WithQueryTimeout(ctx, ds, datasetQueryTimeout)
+	defer cancel()
+	return fetchAPIRows(queryCtx, q.httpClient, ds, config)
+}
+
+// queryJoinedDataset combines the dataset's sources. Sources on one
+// datasource are joined by the database in a single statement; any other mix
+// is loaded and hash-joined in process, then queried like an API dataset.
+func (q *queryExecutor) queryJoinedDataset(ctx context.Context, dataset *models.Dataset, req *QueryRequest) (*QueryResponse, error) {
+	plan, err := buildJoinPlan(dataset.Sources)
+	if err != nil {
+		return nil, err
+	}
+
+	if datasourceID, ok := sqlJoinDatasourceID(plan); ok {
+		ds, err := q.datasourceRepo.GetByID(ctx, datasourceID)
+		if err != nil || ds.TenantID != dataset.TenantID {
+			return nil, errors.New("datasource not found")
+		}
+		pool, err := q.pools.Acquire(ctx, ds)
+		if err != nil {
+			return nil, err
+		}
+		defer pool.Release()
+		queryCtx, cancel := pool.WithTimeout(ctx, datasetQueryTimeout)
+		defer cancel()
+
+		baseQuery, err := buildSQLJoin(queryCtx, pool.DB, pool.Dialect, plan)
+		if err != nil {
+			return nil, pool.Check(err)
+		}
+		resp, err := q.withDialect(pool.Dialect).executeSQLQuery(queryCtx, pool.DB, dataset, baseQuery, req)
+		return resp, pool.Check(err)
+	}
+
+	_, rows, err := q.sourceLoader().loadJoined(ctx, dataset.TenantID, plan, datasetJoinMaxSourceRows, true)
+	if err != nil {
+		return nil, err
+	}
+	return runInMemoryQuery(dataset, rows, req, q.apiBuilder)
+}
+
+func (q *queryExecutor) sourceLoader() *sourceRowLoader {
+	return &sourceRowLoader{
+		datasetRepo:    q.datasetRepo,
+		datasourceRepo: q.datasourceRepo,
+		httpClient:     q.httpClient,
+		pools:          q.pools,
+		timeout:        datasetQueryTimeout,
+	}
+}
+
 func (q *queryExecutor) querySQLDataset(ctx context.Context, dataset *models.Dataset, req *QueryRequest) (*QueryResponse, error) {
-	datasource, err := q.datasourceRepo.GetByID(ctx, *dataset.DatasourceID)
+	ds, err := q.datasourceRepo.GetByID(ctx, *dataset.DatasourceID)
 	if err != nil {
 		return nil, fmt.Errorf("datasource not found: %w", err)
 	}
 
-	db, err := q.getDBConnection(datasource)
+	pool, err := q.pools.Acquire(ctx, ds)
+	if err != nil {
+		return nil, err
+	}
+	defer pool.Release()
+	q = q.withDialect(pool.Dialect)
+
+	baseQuery, err := sqlDatasetQuery(dataset)
 	if err != nil {
 		return nil, err
 	}
-	defer db.Close()
 
+	queryCtx, cancel := pool.WithTimeout(ctx, datasetQueryTimeout)
+	defer cancel()
+	resp, err := q.executeSQLQuery(queryCtx, pool.DB, dataset, baseQuery, req)
+	return resp, pool.Check(err)
+}
+
+// sqlDatasetQuery returns the validated query of a SQL dataset.
+func sqlDatasetQuery(dataset *models.Dataset) (string, error) {
 	var config struct {
 		Query string `json:"query"`
 	}
 	if err := json.Unmarshal([]byte(dataset.Config), &config); err != nil {
-		return nil, fmt.Errorf("invalid dataset config: %w", err)
+		return "", fmt.Errorf("invalid dataset config: %w", err)
 	}
 	if err := validateSQLSafety(config.Query); err != nil {
-		return nil, fmt.Errorf("query validation failed: %w", err)
+		return "", fmt.Errorf("query validation failed: %w", err)
 	}
+	return config.Query, nil
+}
 
+// sqlStatement is a QueryRequest compiled against a dataset's base query,
+// which it wraps as a derived table.
+type sqlStatement struct {
+	baseQuery          string
+	selectClause       string
+	whereClause        string
+	groupByClause      string
+	orderByClause      string
+	args               []interface{}
+	aggregationAliases []string
+}
+
+// compileSQLQuery applies the request's projection, filters, grouping and
+// ordering to baseQuery; paging is left to the caller.
+func (q *queryExecutor) compileSQLQuery(dataset *models.Dataset, baseQuery string, req *QueryRequest) (*sqlStatement, error) {
 	selectedFields := req.Fields
 	if len(selectedFields) == 0 && len(req.GroupBy) > 0 {
 		selectedFields = req.GroupBy
@@ -128,17 +280,35 @@ func (q *queryExecutor) querySQLDataset(ctx context.Context, dataset *models.Dat
 	if err != nil {
 		return nil, fmt.Errorf("invalid filter condition: %w", err)
 	}
-	groupByClause := q.buildGroupByClause(req.GroupBy)
-	orderByClause := q.buildOrderByClause(req.SortBy, req.SortOrder)
-	limitClause, page, pageSize := q.buildLimitClause(req.Page, req.PageSize)
 
-	query := fmt.Sprintf("SELECT %s FROM (%s) AS dataset_query %s %s %s %s",
-		selectClause, config.Query, whereClause, groupByClause, orderByClause, limitClause)
+	return &sqlStatement{
+		baseQuery:          baseQuery,
+		selectClause:       selectClause,
+		whereClause:        whereClause,
+		groupByClause:      q.buildGroupByClause(req.GroupBy),
+		orderByClause:      q.buildOrderByClause(req.SortBy, req.SortOrder),
+		args:               whereArgs,
+		aggregationAliases: aggregationAliases,
+	}, nil
+}
 
-	queryCtx, cancel := withDatasetQueryTimeout(ctx)
-	defer cancel()
+// query renders the statement without a row limit.
+func (s *sqlStatement) query() string {
+	return fmt.Sprintf("SELECT %s FROM (%s) AS dataset_query %s %s %s",
+		s.selectClause, s.baseQuery, s.whereClause, s.groupByClause, s.orderByClause)
+}
 
-	rows, err := db.QueryContext(queryCtx, query, whereArgs...)
+// executeSQLQuery runs one page of the request against baseQuery. ctx
+// carries the datasource's query timeout.
+func (q *queryExecutor) executeSQLQuery(ctx context.Context, db *sql.DB, dataset *models.Dataset, baseQuery string, req *QueryRequest) (*QueryResponse, error) {
+	stmt, err := q.compileSQLQuery(dataset, baseQuery, req)
+	if err != nil {
+		return nil, err
+	}
+	query, page, pageSize := q.pageQuery(stmt.query(), req.Page, req.PageSize)
+	aggregationAliases := stmt.aggregationAliases
+
+	rows, err := db.QueryContext(ctx, query, stmt.args...)
 	if err != nil {
 		if errors.Is(err, context.DeadlineExceeded) {
 			return nil, errors.New("query execution timeout")
@@ -195,7 +365,7 @@ func (q *queryExecutor) querySQLDataset(ctx context.Context, dataset *models.Dat
 		return nil, err
 	}
 
-	total, err := q.countQueryResults(queryCtx, db, config.Query, whereClause, whereArgs)
+	total, err := q.countQueryResults(ctx, db, baseQuery, stmt.whereClause, stmt.args)
 	if err != nil {
 		if errors.Is(err, context.DeadlineExceeded) {
 			return nil, errors.New("query count timeout")
@@ -220,8 +390,13 @@ func (q *queryExecutor) buildAggregationSelects(aggregations map[string]Aggregat
 
 	aggregationSelects := make([]string, 0, len(aggregations))
 	aggregationAliases := make([]string, 0, len(aggregations))
+	dialect := q.sqlDialect()
 	for alias, agg := range aggregations {
-		aggregationSelects = append(aggregationSelects, fmt.Sprintf("%s(%s) AS `%s`", agg.Function, agg.Field, alias))
+		field := agg.Field
+		if field != "*" {
+			field = dialect.QuoteIdentifier(field)
+		}
+		aggregationSelects = append(aggregationSelects, fmt.Sprintf("%s(%s) AS %s", agg.Function, field, dialect.QuoteIdentifier(alias)))
 		aggregationAliases = append(aggregationAliases, alias)
 	}
 
@@ -237,6 +412,7 @@ func (q *queryExecutor) buildSelectClause(dataset *models.Dataset, requestedFiel
 		return "*"
 	}
 
+	dialect := q.sqlDialect()
 	var selectParts []string
 	fieldMap := make(map[string]*models.DatasetField)
 
@@ -248,38 +424,47 @@ func (q *queryExecutor) buildSelectClause(dataset *models.Dataset, requestedFiel
 	for _, fieldName := range requestedFields {
 		field, exists := fieldMap[fieldName]
 		if !exists {
-			selectParts = append(selectParts, fmt.Sprintf("`%s`", fieldName))
+			selectParts = append(selectParts, dialect.QuoteIdentifier(fieldName))
 			continue
 		}
 
 		if field.IsComputed && field.Expression != nil {
+			alias := dialect.QuoteIdentifier(field.Name)
 			if cachedSQL, ok := q.cache.GetSQL(field.ID); ok {
-				selectParts = append(selectParts, fmt.Sprintf("%s AS `%s`", cachedSQL, field.Name))
+				selectParts = append(selectParts, fmt.Sprintf("%s AS %s", cachedSQL, alias))
 			} else {
-				computedSQL, err := q.sqlBuilder.Build(*field.Expression, []string{field.Name})
+				computedSQL, err := q.buildComputedFieldSQL(dataset, *field.Expression)
 				if err != nil {
-					selectParts = append(selectParts, fmt.Sprintf("NULL AS `%s`", field.Name))
+					selectParts = append(selectParts, fmt.Sprintf("NULL AS %s", alias))
 				} else {
 					q.cache.SetSQL(field.ID, computedSQL, 3600000000000)
-					selectParts = append(selectParts, fmt.Sprintf("%s AS `%s`", computedSQL, field.Name))
+					selectParts = append(selectParts, fmt.Sprintf("%s AS %s", computedSQL, alias))
 				}
 			}
 		} else {
-			selectParts = append(selectParts, fmt.Sprintf("`%s`", field.Name))
+			selectParts = append(selectParts, dialect.QuoteIdentifier(field.Name))
 		}
 	}
 
 	return strings.Join(selectParts, ", ")
 }
 
+// buildComputedFieldSQL compiles the expression against the dataset's fields
+// and renders it for the active dialect.
+func (q *queryExecutor) buildComputedFieldSQL(dataset *models.Dataset, expression string) (string, error) {
+	return q.sqlBuilder.WithDialect(q.sqlDialect()).BuildFields(expression, dataset.Fields)
+}
+
 func (q *queryExecutor) buildWhereClause(filters []Filter) (string, []interface{}, error) {
 	if len(filters) == 0 {
 		return "", nil, nil
 	}
 
+	dialect := q.sqlDialect()
 	var conditions []string
 	var args []interface{}
 	for _, filter := range filters {
+		column := dialect.QuoteIdentifier(filter.Field)
 		op := q.translateOperator(filter.Operator)
 		if op == "IN" {
 			inValues, ok := normalizeINValues(filter.Value)
@@ -289,15 +474,15 @@ func (q *queryExecutor) buildWhereClause(filters []Filter) (string, []interface{
 
 			placeholders := make([]string, len(inValues))
 			for i := range inValues {
-				placeholders[i] = "?"
+				placeholders[i] = dialect.Placeholder(len(args) + i + 1)
 			}
 
-			conditions = append(conditions, fmt.Sprintf("`%s` IN (%s)", filter.Field, strings.Join(placeholders, ", ")))
+			conditions = append(conditions, fmt.Sprintf("%s IN (%s)", column, strings.Join(placeholders, ", ")))
 			args = append(args, inValues...)
 			continue
 		}
 
-		conditions = append(conditions, fmt.Sprintf("`%s` %s ?", filter.Field, op))
+		conditions = append(conditions, fmt.Sprintf("%s %s %s", column, op, dialect.Placeholder(len(args)+1)))
 		args = append(args, filter.Value)
 	}
 
@@ -309,9 +494,10 @@ func (q *queryExecutor) buildGroupByClause(groupBy []string) string {
 		return ""
 	}
 
+	dialect := q.sqlDialect()
 	var groupParts []string
 	for _, field := range groupBy {
-		groupParts = append(groupParts, fmt.Sprintf("`%s`", field))
+		groupParts = append(groupParts, dialect.QuoteIdentifier(field))
 	}
 
 	return fmt.Sprintf("GROUP BY %s", strings.Join(groupParts, ", "))
@@ -326,7 +512,7 @@ func (q *queryExecutor) buildOrderByClause(sortBy, sortOrder string) string {
 		sortOrder = "asc"
 	}
 
-	return fmt.Sprintf("ORDER BY `%s` %s", sortBy, strings.ToUpper(sortOrder))
+	return fmt.Sprintf("ORDER BY %s %s", q.sqlDialect().QuoteIdentifier(sortBy), strings.ToUpper(sortOrder))
 }
 
 func normalizePagination(page, pageSize int) (int, int) {
@@ -343,10 +529,12 @@ func normalizePagination(page, pageSize int) (int, int) {
 	return page, pageSize
 }
 
-func (q *queryExecutor) buildLimitClause(page, pageSize int) (string, int, int) {
+// pageQuery caps query at the requested page and returns the page
+// and page size it settled on.
+func (q *queryExecutor) pageQuery(query string, page, pageSize int) (string, int, int) {
 	page, pageSize = normalizePagination(page, pageSize)
 	offset := (page - 1) * pageSize
-	return fmt.Sprintf("LIMIT %d OFFSET %d", pageSize, offset), page, pageSize
+	return q.sqlDialect().Limit(query, pageSize, offset), page, pageSize
 }
 
 func (q *queryExecutor) countQueryResults(ctx context.Context, db *sql.DB, baseQuery, whereClause string, whereArgs []interface{}) (int64, error) {
@@ -377,16 +565,19 @@ func normalizeINValues(value interface{}) ([]interface{}, bool) {
 	return out, true
 }
 
-func (q *queryExecutor) getDBConnection(datasource *models.DataSource) (*sql.DB, error) {
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?parseTime=true",
-		datasource.Username,
-		datasource.Password,
-		datasource.Host,
-		datasource.Port,
-		datasource.Database,
-	)
+// withDialect returns a copy of the executor bound to one datasource dialect so
+// the clause builders can quote and bind without sharing state across queries.
+func (q *queryExecutor) withDialect(dialect datasource.Dialect) *queryExecutor {
+	scoped := *q
+	scoped.dialect = dialect
+	return &scoped
+}
 
-	return sql.Open("mysql", dsn)
+func (q *queryExecutor) sqlDialect() datasource.Dialect {
+	if q.dialect == nil {
+		return datasource.DefaultDialect()
+	}
+	return q.dialect
 }
 
 func (q *queryExecutor) translateOperator(operator string) string {
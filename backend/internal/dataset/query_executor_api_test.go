@@ -0,0 +1,251 @@
+package dataset
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gujiaweiguo/goreport/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const apiOrdersPayload = `{
+	"code": 0,
+	"data": {
+		"items": [
+			{"id": 1, "region": "east", "amount": 120.5, "qty": 2, "customer": {"name": "Alice", "level": "gold"}},
+			{"id": 2, "region": "west", "amount": 80, "qty": 1, "customer": {"name": "Bob", "level": "silver"}},
+			{"id": 3, "region": "east", "amount": 40, "qty": 4, "customer": {"name": "Carol", "level": "gold"}},
+			{"id": 4, "region": "north", "amount": null, "qty": 3, "customer": {"name": "Dave"}}
+		]
+	}
+}`
+
+func newAPITestServer(t *testing.T, check func(r *http.Request)) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if check != nil {
+			check(r)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(apiOrdersPayload))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newAPITestExecutor(dataset *models.Dataset, ds *models.DataSource) QueryExecutor {
+	datasetRepo := new(mockDatasetRepository)
+	datasetRepo.On("GetByIDWithFields", mock.Anything, dataset.ID).Return(dataset, nil)
+	datasourceRepo := new(mockDatasourceRepository)
+	if ds != nil {
+		datasourceRepo.On("GetByID", mock.Anything, ds.ID).Return(ds, nil)
+	}
+	return NewQueryExecutor(datasetRepo, new(mockDatasetFieldRepository), datasourceRepo, NewSQLExpressionBuilder(), NewComputedFieldCache())
+}
+
+func apiTestDataset(config string, datasourceID *string) *models.Dataset {
+	total := "[amount] * [qty]"
+	return &models.Dataset{
+		ID:           "api-1",
+		TenantID:     "tenant-1",
+		Type:         "api",
+		DatasourceID: datasourceID,
+		Config:       config,
+		Fields: []models.DatasetField{
+			{Name: "id", DataType: "number"},
+			{Name: "region", DataType: "string"},
+			{Name: "amount", DataType: "number"},
+			{Name: "qty", DataType: "number"},
+			{Name: "customer.name", DataType: "string"},
+			{Name: "total", DataType: "number", IsComputed: true, Expression: &total},
+		},
+	}
+}
+
+func TestQueryExecutor_APIDataset_FilterSortPage(t *testing.T) {
+	server := newAPITestServer(t, func(r *http.Request) {
+		assert.Equal(t, "/orders", r.URL.Path)
+		assert.Equal(t, "2024", r.URL.Query().Get("year"))
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+	})
+
+	datasourceID := "api-ds"
+	ds := &models.DataSource{
+		ID:     datasourceID,
+		Type:   "api",
+		Config: `{"url":"` + server.URL + `/","token":"secret"}`,
+	}
+	dataset := apiTestDataset(`{"url":"orders","params":{"year":"2024"},"dataPath":"data.items"}`, &datasourceID)
+	executor := newAPITestExecutor(dataset, ds)
+
+	resp, err := executor.Query(context.Background(), &QueryRequest{
+		DatasetID: "api-1",
+		Fields:    []string{"id", "customer.name", "total"},
+		Filters:   []Filter{{Field: "qty", Operator: "gte", Value: 2}},
+		SortBy:    "total",
+		SortOrder: "desc",
+		Page:      1,
+		PageSize:  2,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(3), resp.Total)
+	require.Len(t, resp.Data, 2)
+	assert.Equal(t, int64(1), resp.Data[0]["id"])
+	assert.Equal(t, "Alice", resp.Data[0]["customer.name"])
+	assert.Equal(t, float64(241), resp.Data[0]["total"])
+	assert.Equal(t, "Carol", resp.Data[1]["customer.name"])
+	assert.Equal(t, float64(160), resp.Data[1]["total"])
+
+	resp, err = executor.Query(context.Background(), &QueryRequest{
+		DatasetID: "api-1",
+		Fields:    []string{"id"},
+		SortBy:    "total",
+		SortOrder: "desc",
+		Page:      2,
+		PageSize:  2,
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.Data, 2)
+	assert.Equal(t, int64(2), resp.Data[0]["id"])
+	// A NULL amount makes the computed total NULL, which sorts last descending.
+	assert.Equal(t, int64(4), resp.Data[1]["id"])
+}
+
+func TestQueryExecutor_APIDataset_GroupAggregate(t *testing.T) {
+	server := newAPITestServer(t, nil)
+	dataset := apiTestDataset(`{"url":"`+server.URL+`/orders","dataPath":"$.data.items"}`, nil)
+	executor := newAPITestExecutor(dataset, nil)
+
+	resp, err := executor.Query(context.Background(), &QueryRequest{
+		DatasetID: "api-1",
+		GroupBy:   []string{"region"},
+		Aggregations: map[string]Aggregation{
+			"sum_amount": {Field: "amount", Function: "SUM"},
+			"orders":     {Field: "*", Function: "COUNT"},
+		},
+		SortBy:    "region",
+		SortOrder: "asc",
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(4), resp.Total)
+	require.Len(t, resp.Data, 3)
+	assert.Equal(t, "east", resp.Data[0]["region"])
+	assert.Equal(t, 160.5, resp.Data[0]["sum_amount"])
+	assert.Equal(t, int64(2), resp.Data[0]["orders"])
+	assert.Equal(t, "north", resp.Data[1]["region"])
+	assert.Nil(t, resp.Data[1]["sum_amount"])
+	assert.Equal(t, 160.5, resp.Aggregations["sum_amount"])
+
+	resp, err = executor.Query(context.Background(), &QueryRequest{
+		DatasetID: "api-1",
+		Filters:   []Filter{{Field: "customer.name", Operator: "like", Value: "%o%"}},
+		Aggregations: map[string]Aggregation{
+			"avg_total": {Field: "total", Function: "AVG"},
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.Data, 1)
+	assert.Equal(t, float64(120), resp.Data[0]["avg_total"])
+}
+
+func TestQueryExecutor_APIDataset_BasicAuth(t *testing.T) {
+	server := newAPITestServer(t, func(r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "reporter", username)
+		assert.Equal(t, "p@ss", password)
+		assert.Equal(t, "goreport", r.Header.Get("X-Client"))
+	})
+
+	datasourceID := "api-ds"
+	ds := &models.DataSource{
+		ID:       datasourceID,
+		Type:     "api",
+		Username: "reporter",
+		Password: "p@ss",
+		Config:   `{"url":"` + server.URL + `","headers":{"X-Client":"goreport"}}`,
+	}
+	dataset := apiTestDataset(`{"url":"/orders","dataPath":"data.items"}`, &datasourceID)
+	executor := newAPITestExecutor(dataset, ds)
+
+	resp, err := executor.Query(context.Background(), &QueryRequest{
+		DatasetID: "api-1",
+		Filters:   []Filter{{Field: "region", Operator: "in", Value: []interface{}{"west", "north"}}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), resp.Total)
+}
+
+func TestQueryExecutor_APIDataset_Errors(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	}))
+	defer failing.Close()
+	server := newAPITestServer(t, nil)
+
+	tests := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{name: "non 2xx status", config: `{"url":"` + failing.URL + `"}`, wantErr: "status 401"},
+		{name: "missing data path", config: `{"url":"` + server.URL + `","dataPath":"data.rows"}`, wantErr: "not found"},
+		{name: "scalar data path", config: `{"url":"` + server.URL + `","dataPath":"code"}`, wantErr: "object or array"},
+		{name: "missing url", config: `{}`, wantErr: "api url is required"},
+		{name: "unsupported scheme", config: `{"url":"file:///etc/passwd"}`, wantErr: "http or https"},
+		{name: "unsupported method", config: `{"url":"` + server.URL + `","method":"DELETE"}`, wantErr: "unsupported api method"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			executor := newAPITestExecutor(apiTestDataset(tt.config, nil), nil)
+			_, err := executor.Query(context.Background(), &QueryRequest{DatasetID: "api-1"})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestInferRowFields(t *testing.T) {
+	rows := []map[string]interface{}{
+		{"id": int64(1), "name": "a", "created_at": "2024-01-02", "active": true, "note": nil},
+		{"id": int64(2), "name": int64(5), "created_at": "2024-02-03 10:00:00", "active": false},
+	}
+
+	fields := inferRowFields(rows)
+	require.Len(t, fields, 5)
+
+	byName := make(map[string]models.DatasetField)
+	for _, field := range fields {
+		byName[field.Name] = field
+	}
+	assert.Equal(t, "boolean", byName["active"].DataType)
+	assert.Equal(t, "date", byName["created_at"].DataType)
+	assert.Equal(t, "number", byName["id"].DataType)
+	assert.Equal(t, "measure", byName["id"].Type)
+	assert.Equal(t, "string", byName["name"].DataType)
+	assert.Equal(t, "string", byName["note"].DataType)
+	assert.Equal(t, "dimension", byName["note"].Type)
+}
+
+func TestDatasetService_Preview_APIDataset(t *testing.T) {
+	server := newAPITestServer(t, nil)
+	dataset := apiTestDataset(`{"url":"`+server.URL+`","dataPath":"data.items"}`, nil)
+
+	mockDatasetRepo := &mockDatasetRepository{}
+	mockDatasetRepo.On("GetByIDWithFields", mock.Anything, "api-1").Return(dataset, nil)
+	svc := NewService(mockDatasetRepo, nil, nil, nil)
+
+	rows, err := svc.Preview(context.Background(), "api-1", "tenant-1")
+	require.NoError(t, err)
+	require.Len(t, rows, 4)
+	assert.Equal(t, "gold", rows[0]["customer.level"])
+	assert.Equal(t, float64(241), rows[0]["total"])
+	assert.Nil(t, rows[3]["total"])
+}
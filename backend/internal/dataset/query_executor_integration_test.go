@@ -400,3 +400,84 @@ func TestQueryExecutor_Query_WithPagination(t *testing.T) {
 	assert.Equal(t, 2, resp.PageSize)
 	assert.Len(t, resp.Data, 2)
 }
+
+func TestQueryExecutor_Export_SQLIntegration(t *testing.T) {
+	skipIfNoDBForQuery(t)
+
+	db, datasetRepo, fieldRepo, datasourceRepo := setupQueryIntegrationTest(t)
+	defer func() {
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+	}()
+
+	ctx := context.Background()
+	tenantID := "test-export-integration"
+	ensureTenantExists(db, t, tenantID)
+	now := time.Now()
+	ts := fmt.Sprintf("%08x", now.UnixNano()&0xFFFFFFFF)
+
+	datasourceID := fmt.Sprintf("ds-%s", ts)
+	require.NoError(t, datasourceRepo.Create(ctx, &models.DataSource{
+		ID:       datasourceID,
+		TenantID: tenantID,
+		Name:     "Export Test DataSource",
+		Type:     "mysql",
+		Host:     "127.0.0.1",
+		Port:     3306,
+		Database: getTestDatabaseNameForQuery(),
+		Username: "root",
+		Password: "root",
+	}))
+
+	datasetID := fmt.Sprintf("dt-%s", ts)
+	require.NoError(t, db.Create(&models.Dataset{
+		ID:           datasetID,
+		TenantID:     tenantID,
+		Name:         "Export Test Dataset",
+		Type:         "sql",
+		DatasourceID: &datasourceID,
+		Config:       `{"query": "SELECT 1 AS id, 'east' AS region UNION ALL SELECT 2, 'west' UNION ALL SELECT 3, 'east'"}`,
+		Status:       1,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}).Error)
+	displayName := "Region"
+	require.NoError(t, db.Create(&models.DatasetField{
+		ID:          fmt.Sprintf("fld-%s", ts),
+		DatasetID:   datasetID,
+		Name:        "region",
+		DisplayName: &displayName,
+		Type:        "dimension",
+		DataType:    "string",
+		Config:      "{}",
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}).Error)
+
+	t.Cleanup(func() {
+		db.Exec("DELETE FROM dataset_fields WHERE dataset_id = ?", datasetID)
+		db.Exec("DELETE FROM datasets WHERE id = ?", datasetID)
+		db.Exec("DELETE FROM data_sources WHERE id = ?", datasourceID)
+	})
+
+	executor := NewQueryExecutor(datasetRepo, fieldRepo, datasourceRepo, NewSQLExpressionBuilder(), NewComputedFieldCache())
+	req := &QueryRequest{
+		DatasetID:    datasetID,
+		GroupBy:      []string{"region"},
+		Aggregations: map[string]Aggregation{"orders": {Function: "COUNT", Field: "*"}},
+		SortBy:       "region",
+		SortOrder:    "asc",
+	}
+
+	var out strings.Builder
+	writer := newCSVRowWriter(&out)
+	written, err := executor.Export(ctx, tenantID, req, writer)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	assert.Equal(t, int64(2), written)
+	assert.Equal(t, "\ufeffRegion,orders\neast,2\nwest,1\n", out.String())
+
+	useExportLimits(t, map[string]int{tenantID: 1})
+	_, err = executor.Export(ctx, tenantID, req, newCSVRowWriter(&strings.Builder{}))
+	assert.ErrorIs(t, err, ErrExportTooLarge)
+}
@@ -3,6 +3,7 @@ package dataset
 import (
 	"testing"
 
+	"github.com/gujiaweiguo/goreport/internal/datasource"
 	"github.com/gujiaweiguo/goreport/internal/models"
 	"github.com/stretchr/testify/assert"
 )
@@ -127,39 +128,39 @@ func TestQueryExecutor_BuildWhereClause(t *testing.T) {
 	executor := &queryExecutor{}
 
 	tests := []struct {
-		name         string
-		filters      []Filter
-		expectClause bool
-		expectedArg  interface{}
-		expectError  bool
+		name        string
+		filters     []Filter
+		expectQuery bool
+		expectedArg interface{}
+		expectError bool
 	}{
 		{
-			name:         "empty filters",
-			filters:      []Filter{},
-			expectClause: false,
+			name:        "empty filters",
+			filters:     []Filter{},
+			expectQuery: false,
 		},
 		{
 			name: "single equals filter",
 			filters: []Filter{
 				{Field: "status", Operator: "eq", Value: "active"},
 			},
-			expectClause: true,
-			expectedArg:  "active",
+			expectQuery: true,
+			expectedArg: "active",
 		},
 		{
 			name: "greater than filter",
 			filters: []Filter{
 				{Field: "amount", Operator: "gt", Value: 100},
 			},
-			expectClause: true,
-			expectedArg:  100,
+			expectQuery: true,
+			expectedArg: 100,
 		},
 		{
 			name: "IN filter with array",
 			filters: []Filter{
 				{Field: "id", Operator: "in", Value: []interface{}{1, 2, 3}},
 			},
-			expectClause: true,
+			expectQuery: true,
 		},
 		{
 			name: "IN filter with invalid value",
@@ -181,7 +182,7 @@ func TestQueryExecutor_BuildWhereClause(t *testing.T) {
 				{Field: "status", Operator: "eq", Value: "active"},
 				{Field: "amount", Operator: "gte", Value: 50},
 			},
-			expectClause: true,
+			expectQuery: true,
 		},
 	}
 
@@ -193,7 +194,7 @@ func TestQueryExecutor_BuildWhereClause(t *testing.T) {
 				return
 			}
 			assert.NoError(t, err)
-			if tt.expectClause {
+			if tt.expectQuery {
 				assert.Contains(t, clause, "WHERE")
 				if tt.expectedArg != nil {
 					assert.Contains(t, args, tt.expectedArg)
@@ -401,7 +402,7 @@ func TestNormalizeINValues(t *testing.T) {
 	}
 }
 
-func TestQueryExecutor_BuildLimitClause(t *testing.T) {
+func TestQueryExecutor_PageQuery(t *testing.T) {
 	executor := &queryExecutor{}
 
 	tests := []struct {
@@ -410,7 +411,7 @@ func TestQueryExecutor_BuildLimitClause(t *testing.T) {
 		pageSize     int
 		expectedPage int
 		expectedSize int
-		expectClause string
+		expectQuery  string
 	}{
 		{
 			name:         "zero values normalized",
@@ -418,7 +419,7 @@ func TestQueryExecutor_BuildLimitClause(t *testing.T) {
 			pageSize:     0,
 			expectedPage: 1,
 			expectedSize: 10,
-			expectClause: "LIMIT 10 OFFSET 0",
+			expectQuery:  "SELECT 1 LIMIT 10 OFFSET 0",
 		},
 		{
 			name:         "valid pagination",
@@ -426,7 +427,7 @@ func TestQueryExecutor_BuildLimitClause(t *testing.T) {
 			pageSize:     20,
 			expectedPage: 2,
 			expectedSize: 20,
-			expectClause: "LIMIT 20 OFFSET 20",
+			expectQuery:  "SELECT 1 LIMIT 20 OFFSET 20",
 		},
 		{
 			name:         "over limit normalized",
@@ -434,7 +435,7 @@ func TestQueryExecutor_BuildLimitClause(t *testing.T) {
 			pageSize:     2000,
 			expectedPage: 1,
 			expectedSize: 1000,
-			expectClause: "LIMIT 1000 OFFSET 0",
+			expectQuery:  "SELECT 1 LIMIT 1000 OFFSET 0",
 		},
 		{
 			name:         "third page",
@@ -442,16 +443,16 @@ func TestQueryExecutor_BuildLimitClause(t *testing.T) {
 			pageSize:     50,
 			expectedPage: 3,
 			expectedSize: 50,
-			expectClause: "LIMIT 50 OFFSET 100",
+			expectQuery:  "SELECT 1 LIMIT 50 OFFSET 100",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			clause, page, pageSize := executor.buildLimitClause(tt.page, tt.pageSize)
+			query, page, pageSize := executor.pageQuery("SELECT 1", tt.page, tt.pageSize)
 			assert.Equal(t, tt.expectedPage, page)
 			assert.Equal(t, tt.expectedSize, pageSize)
-			assert.Equal(t, tt.expectClause, clause)
+			assert.Equal(t, tt.expectQuery, query)
 		})
 	}
 }
@@ -519,3 +520,35 @@ func TestQueryExecutor_BuildSelectClause_ComputedField_NoExpression(t *testing.T
 	result := executor.buildSelectClause(dataset, []string{"total"})
 	assert.Contains(t, result, "`total`")
 }
+
+func TestQueryExecutor_PostgresDialect(t *testing.T) {
+	dialect, err := datasource.GetDialect("postgres")
+	assert.NoError(t, err)
+	executor := (&queryExecutor{cache: NewComputedFieldCache(), sqlBuilder: NewSQLExpressionBuilder()}).withDialect(dialect)
+
+	expr := "[price] * [quantity]"
+	dataset := &models.Dataset{
+		Fields: []models.DatasetField{
+			{ID: "pg-f1", Name: "price"},
+			{ID: "pg-f2", Name: "quantity"},
+			{ID: "pg-f3", Name: "total", IsComputed: true, Expression: &expr},
+		},
+	}
+
+	assert.Equal(t, `"price", ("price" * "quantity") AS "total"`, executor.buildSelectClause(dataset, []string{"price", "total"}))
+
+	clause, args, err := executor.buildWhereClause([]Filter{
+		{Field: "region", Operator: "eq", Value: "east"},
+		{Field: "id", Operator: "in", Value: []int{1, 2}},
+		{Field: "amount", Operator: "gt", Value: 10},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, `WHERE "region" = $1 AND "id" IN ($2, $3) AND "amount" > $4`, clause)
+	assert.Equal(t, []interface{}{"east", 1, 2, 10}, args)
+
+	assert.Equal(t, `GROUP BY "region"`, executor.buildGroupByClause([]string{"region"}))
+	assert.Equal(t, `ORDER BY "amount" DESC`, executor.buildOrderByClause("amount", "desc"))
+
+	aggregations, _ := executor.buildAggregationSelects(map[string]Aggregation{"total": {Function: "SUM", Field: "amount"}})
+	assert.Equal(t, `SUM("amount") AS "total"`, aggregations)
+}
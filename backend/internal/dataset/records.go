@@ -0,0 +1,62 @@
+package dataset
+
+import "context"
+
+// QueryRecords fetches every record of a dataset matching req, keyed by
+// result column: field names, and aggregation aliases for aggregated values.
+// It goes through Export, which checks the dataset belongs to tenantID and
+// enforces the tenant's row limit; Query does neither.
+func QueryRecords(ctx context.Context, executor QueryExecutor, tenantID string, req *QueryRequest) ([]map[string]interface{}, error) {
+	return QueryTopRecords(ctx, executor, tenantID, req, 0)
+}
+
+// QueryTopRecords is QueryRecords for only the first n records, or all of
+// them when n is 0. The cap is pushed into the query, so a top n of a large
+// dataset neither reads every row nor runs into the tenant's row limit.
+func QueryTopRecords(ctx context.Context, executor QueryExecutor, tenantID string, req *QueryRequest, n int) ([]map[string]interface{}, error) {
+	collector := &recordCollector{max: n}
+	if _, err := executor.Export(ctx, tenantID, req, collector); err != nil {
+		return nil, err
+	}
+	return collector.records, nil
+}
+
+// recordCollector is a RowWriter that keeps rows as records keyed by the
+// result's column names.
+type recordCollector struct {
+	max     int
+	columns []string
+	records []map[string]interface{}
+}
+
+func (c *recordCollector) maxRecords() int {
+	return c.max
+}
+
+func (c *recordCollector) WriteHeader(columns []string) error {
+	c.columns = append([]string(nil), columns...)
+	return nil
+}
+
+func (c *recordCollector) WriteRow(values []interface{}) error {
+	if c.max > 0 && len(c.records) >= c.max {
+		return nil
+	}
+	record := make(map[string]interface{}, len(values))
+	for i, value := range values {
+		if i >= len(c.columns) {
+			break
+		}
+		// Text and decimal columns scan as []byte, which JSON encodes as base64.
+		if raw, ok := value.([]byte); ok {
+			value = string(raw)
+		}
+		record[c.columns[i]] = value
+	}
+	c.records = append(c.records, record)
+	return nil
+}
+
+func (c *recordCollector) Close() error {
+	return nil
+}
@@ -0,0 +1,93 @@
+package dataset
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gujiaweiguo/goreport/internal/datasource"
+	"github.com/gujiaweiguo/goreport/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryRecords(t *testing.T) {
+	server := newAPITestServer(t, nil)
+	dataset := apiTestDataset(`{"url":"`+server.URL+`","dataPath":"data.items"}`, nil)
+	regionName, amountName := "Region", "Amount (USD)"
+	dataset.Fields[1].DisplayName = &regionName
+	dataset.Fields[2].DisplayName = &amountName
+	executor := newAPITestExecutor(dataset, nil)
+
+	// Records are keyed by field names and aliases, not display names.
+	records, err := QueryRecords(context.Background(), executor, "tenant-1", &QueryRequest{
+		DatasetID:    "api-1",
+		GroupBy:      []string{"region"},
+		Aggregations: map[string]Aggregation{"amount": {Function: "SUM", Field: "amount"}},
+		SortBy:       "region",
+		SortOrder:    "asc",
+	})
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	assert.Equal(t, map[string]interface{}{"region": "east", "amount": 160.5}, records[0])
+
+	// An ungrouped aggregation yields only its alias, whatever fields are asked for.
+	records, err = QueryRecords(context.Background(), executor, "tenant-1", &QueryRequest{
+		DatasetID:    "api-1",
+		Fields:       []string{"region"},
+		Aggregations: map[string]Aggregation{"sum_amount": {Function: "SUM", Field: "amount"}},
+	})
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, map[string]interface{}{"sum_amount": 240.5}, records[0])
+
+	records, err = QueryRecords(context.Background(), executor, "tenant-1", &QueryRequest{
+		DatasetID: "api-1",
+		Fields:    []string{"id", "amount"},
+	})
+	require.NoError(t, err)
+	require.Len(t, records, 4)
+	assert.Contains(t, records[0], "amount")
+	assert.NotContains(t, records[0], "Amount (USD)")
+}
+
+func TestQueryTopRecords(t *testing.T) {
+	server := newAPITestServer(t, nil)
+	dataset := apiTestDataset(`{"url":"`+server.URL+`","dataPath":"data.items"}`, nil)
+	executor := newAPITestExecutor(dataset, nil)
+	useExportLimits(t, map[string]int{"tenant-1": 3})
+
+	req := &QueryRequest{DatasetID: "api-1", Fields: []string{"id"}, SortBy: "id", SortOrder: "desc"}
+	_, err := QueryRecords(context.Background(), executor, "tenant-1", req)
+	assert.ErrorIs(t, err, ErrExportTooLarge)
+
+	// Only the wanted rows count against the tenant's row limit.
+	records, err := QueryTopRecords(context.Background(), executor, "tenant-1", req, 2)
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.EqualValues(t, 4, records[0]["id"])
+	assert.EqualValues(t, 3, records[1]["id"])
+}
+
+func TestRecordCollector_SQLBytes(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// MySQL returns VARCHAR and DECIMAL columns as []byte.
+	sqlMock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"region", "amount", "qty"}).
+		AddRow([]byte("east"), []byte("-12.50"), int64(3)))
+
+	dataset := &models.Dataset{ID: "sql-1", TenantID: "tenant-1", Type: "sql", Fields: []models.DatasetField{
+		{Name: "region", DataType: "string"},
+		{Name: "amount", DataType: "number"},
+		{Name: "qty", DataType: "number"},
+	}}
+	q := (&queryExecutor{sqlBuilder: NewSQLExpressionBuilder()}).withDialect(datasource.DefaultDialect())
+	collector := &recordCollector{}
+	_, err = q.streamSQLQuery(context.Background(), db, dataset, "SELECT * FROM sales", &QueryRequest{DatasetID: "sql-1"}, 0, collector)
+	require.NoError(t, err)
+	require.Len(t, collector.records, 1)
+	assert.Equal(t, map[string]interface{}{"region": "east", "amount": "-12.50", "qty": int64(3)}, collector.records[0])
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
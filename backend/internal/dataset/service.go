@@ -2,18 +2,25 @@ package dataset
 
 import (
 	"context"
-	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"regexp"
+	"io"
+	"net/http"
+	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/gujiaweiguo/goreport/internal/datasource"
 	"github.com/gujiaweiguo/goreport/internal/models"
 	"github.com/gujiaweiguo/goreport/internal/repository"
 )
 
+// ErrNotFound is returned for a dataset that does not exist or belongs to
+// another tenant.
+var ErrNotFound = errors.New("dataset not found")
+
 type Service interface {
 	Create(ctx context.Context, req *CreateRequest) (*models.Dataset, error)
 	Get(ctx context.Context, id, tenantID string) (*models.Dataset, error)
@@ -31,8 +38,13 @@ type Service interface {
 	ListDimensions(ctx context.Context, datasetID, tenantID string) ([]*models.DatasetField, error)
 	ListMeasures(ctx context.Context, datasetID, tenantID string) ([]*models.DatasetField, error)
 	ListFields(ctx context.Context, datasetID, tenantID string) ([]*models.DatasetField, error)
+
+	UploadFile(ctx context.Context, req *UploadFileRequest) (*UploadFileResponse, error)
 }
 
+// previewRowLimit caps the rows returned by Preview.
+const previewRowLimit = 100
+
 type service struct {
 	datasetRepo    repository.DatasetRepository
 	fieldRepo      repository.DatasetFieldRepository
@@ -41,6 +53,8 @@ type service struct {
 	sqlBuilder     SQLExpressionBuilder
 	apiBuilder     APIExpressionBuilder
 	cache          *ComputedFieldCache
+	httpClient     *http.Client
+	pools          *datasource.PoolManager
 }
 
 func NewService(
@@ -57,6 +71,8 @@ func NewService(
 		sqlBuilder:     NewSQLExpressionBuilder(),
 		apiBuilder:     NewAPIExpressionBuilder(),
 		cache:          NewComputedFieldCache(),
+		httpClient:     newAPIHTTPClient(),
+		pools:          datasource.Pools(),
 	}
 }
 
@@ -65,17 +81,67 @@ type CreateRequest struct {
 	Type         string          `json:"type"`
 	DatasourceID *string         `json:"datasourceId"`
 	Config       json.RawMessage `json:"config"`
+	Sources      []SourceRequest `json:"sources"`
 	TenantID     string          `json:"-"`
 	CreatedBy    string          `json:"-"`
 }
 
 type UpdateRequest struct {
-	ID       string          `json:"id"`
-	Name     *string         `json:"name"`
-	Config   json.RawMessage `json:"config"`
-	Status   *int            `json:"status"`
-	Action   *string         `json:"action"`
-	TenantID string          `json:"-"`
+	ID     string          `json:"id"`
+	Name   *string         `json:"name"`
+	Config json.RawMessage `json:"config"`
+	// Sources replaces the dataset's sources when non-nil; an empty list
+	// turns a joined dataset back into a single-source one.
+	Sources []SourceRequest `json:"sources"`
+	Status  *int            `json:"status"`
+	Action  *string         `json:"action"`
+	// DrillPaths replaces the dataset's drill paths when non-nil.
+	DrillPaths *[]models.DrillPath `json:"drillPaths"`
+	TenantID   string              `json:"-"`
+}
+
+// SourceRequest describes one source of a joined dataset. Sources are joined
+// in list order; JoinType and JoinCondition are ignored for the first one.
+type SourceRequest struct {
+	SourceType    string          `json:"sourceType"`
+	SourceID      *string         `json:"sourceId"`
+	SourceConfig  json.RawMessage `json:"sourceConfig"`
+	JoinType      string          `json:"joinType"`
+	JoinCondition *string         `json:"joinCondition"`
+}
+
+// UploadFileRequest creates a file dataset, or replaces the file of an
+// existing one when DatasetID is set. Empty options keep their previous values.
+type UploadFileRequest struct {
+	DatasetID string
+	Name      string
+	FileName  string
+	File      io.Reader
+	Delimiter string
+	HasHeader *bool
+	Sheet     string
+	TenantID  string
+	CreatedBy string
+}
+
+type UploadFileResponse struct {
+	Dataset *models.Dataset `json:"dataset"`
+	Sheets  []string        `json:"sheets,omitempty"`
+	Diff    *SchemaDiff     `json:"diff"`
+}
+
+// SchemaDiff lists how the fields inferred from an upload differ from the
+// dataset's previous fields.
+type SchemaDiff struct {
+	Added   []string          `json:"added"`
+	Removed []string          `json:"removed"`
+	Changed []FieldTypeChange `json:"changed"`
+}
+
+type FieldTypeChange struct {
+	Name string `json:"name"`
+	From string `json:"from"`
+	To   string `json:"to"`
 }
 
 type SchemaResponse struct {
@@ -93,16 +159,16 @@ func (s *service) Create(ctx context.Context, req *CreateRequest) (*models.Datas
 		return nil, errors.New("type is required")
 	}
 
-	if req.Type == "sql" && req.DatasourceID == nil {
+	if req.Type == "sql" && req.DatasourceID == nil && len(req.Sources) == 0 {
 		return nil, errors.New("datasourceId is required for SQL datasets")
 	}
 
 	if req.DatasourceID != nil {
-		datasource, err := s.datasourceRepo.GetByID(ctx, *req.DatasourceID)
+		ds, err := s.datasourceRepo.GetByID(ctx, *req.DatasourceID)
 		if err != nil {
 			return nil, fmt.Errorf("datasource not found: %w", err)
 		}
-		if datasource.TenantID != req.TenantID {
+		if ds.TenantID != req.TenantID {
 			return nil, errors.New("datasource does not belong to this tenant")
 		}
 	}
@@ -120,10 +186,22 @@ func (s *service) Create(ctx context.Context, req *CreateRequest) (*models.Datas
 		UpdatedAt:    time.Now(),
 	}
 
+	sources, err := buildDatasetSources(dataset.ID, req.Sources)
+	if err != nil {
+		return nil, err
+	}
+
 	if err := s.datasetRepo.Create(ctx, dataset); err != nil {
 		return nil, err
 	}
 
+	if len(sources) > 0 {
+		if err := s.saveSources(ctx, dataset, sources); err != nil {
+			_ = s.datasetRepo.Delete(ctx, dataset.ID)
+			return nil, err
+		}
+	}
+
 	if err := s.extractFields(ctx, dataset); err != nil {
 		// Keep create flow consistent: if field extraction fails, remove the dataset created in this request.
 		if rollbackErr := s.datasetRepo.Delete(ctx, dataset.ID); rollbackErr != nil {
@@ -142,7 +220,7 @@ func (s *service) Get(ctx context.Context, id, tenantID string) (*models.Dataset
 	}
 
 	if dataset.TenantID != tenantID {
-		return nil, errors.New("dataset not found")
+		return nil, ErrNotFound
 	}
 
 	return dataset, nil
@@ -155,7 +233,7 @@ func (s *service) GetWithFields(ctx context.Context, id, tenantID string) (*mode
 	}
 
 	if dataset.TenantID != tenantID {
-		return nil, errors.New("dataset not found")
+		return nil, ErrNotFound
 	}
 
 	return dataset, nil
@@ -176,13 +254,27 @@ func (s *service) Update(ctx context.Context, req *UpdateRequest) (*models.Datas
 	}
 
 	if dataset.TenantID != req.TenantID {
-		return nil, errors.New("dataset not found")
+		return nil, ErrNotFound
 	}
 
 	if req.Name != nil {
 		dataset.Name = *req.Name
 	}
-	if req.Config != nil {
+	if req.Sources != nil {
+		sources, err := buildDatasetSources(dataset.ID, req.Sources)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.saveSources(ctx, dataset, sources); err != nil {
+			return nil, err
+		}
+		if req.Config != nil {
+			dataset.Config = string(req.Config)
+		}
+		if err := s.extractFields(ctx, dataset); err != nil {
+			return nil, fmt.Errorf("failed to re-extract fields: %w", err)
+		}
+	} else if req.Config != nil {
 		oldConfig := dataset.Config
 		dataset.Config = string(req.Config)
 		if oldConfig != dataset.Config {
@@ -194,6 +286,16 @@ func (s *service) Update(ctx context.Context, req *UpdateRequest) (*models.Datas
 	if req.Status != nil {
 		dataset.Status = *req.Status
 	}
+	if req.DrillPaths != nil {
+		fields, err := s.fieldRepo.List(ctx, dataset.ID)
+		if err != nil {
+			return nil, err
+		}
+		if err := validateDrillPaths(*req.DrillPaths, fields); err != nil {
+			return nil, err
+		}
+		dataset.DrillPaths = append([]models.DrillPath{}, *req.DrillPaths...)
+	}
 	dataset.UpdatedAt = time.Now()
 
 	if err := s.datasetRepo.Update(ctx, dataset); err != nil {
@@ -210,7 +312,7 @@ func (s *service) Delete(ctx context.Context, id, tenantID string) error {
 	}
 
 	if dataset.TenantID != tenantID {
-		return errors.New("dataset not found")
+		return ErrNotFound
 	}
 
 	if err := s.datasetRepo.SoftDelete(ctx, id); err != nil {
@@ -226,9 +328,18 @@ func (s *service) Preview(ctx context.Context, id, tenantID string) ([]map[strin
 		return nil, err
 	}
 
+	if len(dataset.Sources) > 0 {
+		return s.executeJoinedPreview(ctx, dataset)
+	}
 	if dataset.Type == "sql" && dataset.DatasourceID != nil {
 		return s.executeSQLPreview(ctx, dataset)
 	}
+	if dataset.Type == "api" {
+		return s.executeAPIPreview(ctx, dataset)
+	}
+	if dataset.Type == "file" {
+		return s.executeFilePreview(ctx, dataset)
+	}
 
 	return nil, errors.New("preview not implemented for this dataset type")
 }
@@ -262,14 +373,466 @@ func (s *service) GetSchema(ctx context.Context, id, tenantID string) (*SchemaRe
 }
 
 func (s *service) extractFields(ctx context.Context, dataset *models.Dataset) error {
+	if len(dataset.Sources) > 0 {
+		return s.extractJoinedFields(ctx, dataset)
+	}
 	if dataset.Type == "sql" && dataset.DatasourceID != nil {
 		return s.extractSQLFields(ctx, dataset)
 	}
+	if dataset.Type == "api" && dataset.Config != "" {
+		return s.extractAPIFields(ctx, dataset)
+	}
+	if dataset.Type == "file" && dataset.Config != "" {
+		return s.extractFileFields(ctx, dataset)
+	}
+	return nil
+}
+
+// fetchAPIPreviewRows loads an API dataset under the preview timeout and
+// keeps at most previewRowLimit rows.
+func (s *service) fetchAPIPreviewRows(ctx context.Context, dataset *models.Dataset) ([]map[string]interface{}, error) {
+	config, err := parseAPIDatasetConfig(dataset.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	var ds *models.DataSource
+	if dataset.DatasourceID != nil {
+		if ds, err = s.datasourceRepo.GetByID(ctx, *dataset.DatasourceID); err != nil {
+			return nil, err
+		}
+	}
+
+	previewCtx, cancel := datasource.WithQueryTimeout(ctx, ds, datasetPreviewTimeout)
+	defer cancel()
+
+	rows, err := fetchAPIRows(previewCtx, s.httpClient, ds, config)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) > previewRowLimit {
+		rows = rows[:previewRowLimit]
+	}
+	return rows, nil
+}
+
+func (s *service) extractAPIFields(ctx context.Context, dataset *models.Dataset) error {
+	rows, err := s.fetchAPIPreviewRows(ctx, dataset)
+	if err != nil {
+		return err
+	}
+
+	for _, inferred := range inferRowFields(rows) {
+		name := inferred.Name
+		field := inferred
+		field.ID = fmt.Sprintf("field-%d", time.Now().UnixNano())
+		field.DatasetID = dataset.ID
+		field.DisplayName = &name
+		field.Config = "{}"
+		field.CreatedAt = time.Now()
+		field.UpdatedAt = time.Now()
+
+		if err := s.fieldRepo.Create(ctx, &field); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *service) executeAPIPreview(ctx context.Context, dataset *models.Dataset) ([]map[string]interface{}, error) {
+	rows, err := s.fetchAPIPreviewRows(ctx, dataset)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := applyComputedFields(dataset, rows, s.apiBuilder); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func (s *service) executeFilePreview(ctx context.Context, dataset *models.Dataset) ([]map[string]interface{}, error) {
+	previewCtx, cancel := withDatasetPreviewTimeout(ctx)
+	defer cancel()
+
+	table, err := loadFileTable(previewCtx, dataset)
+	if err != nil {
+		return nil, err
+	}
+	if len(table.records) > previewRowLimit {
+		table.records = table.records[:previewRowLimit]
+	}
+
+	rows := fileRows(dataset, table)
+	if err := applyComputedFields(dataset, rows, s.apiBuilder); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// extractFileFields re-infers the schema after a file dataset's options change.
+func (s *service) extractFileFields(ctx context.Context, dataset *models.Dataset) error {
+	config, err := parseFileDatasetConfig(dataset.Config)
+	if err != nil {
+		return err
+	}
+	if config.StorageKey == "" {
+		return nil
+	}
+
+	table, err := openFileTable(ctx, dataset.TenantID, config)
+	if err != nil {
+		return err
+	}
+	_, err = s.syncInferredFields(ctx, dataset.ID, inferFileFields(table))
+	return err
+}
+
+func (s *service) UploadFile(ctx context.Context, req *UploadFileRequest) (*UploadFileResponse, error) {
+	if req.File == nil || req.FileName == "" {
+		return nil, errors.New("file is required")
+	}
+	format, err := fileFormatFor(req.FileName)
+	if err != nil {
+		return nil, err
+	}
+	storage, err := getFileStorage()
+	if err != nil {
+		return nil, err
+	}
+
+	isNew := req.DatasetID == ""
+	var dataset *models.Dataset
+	if isNew {
+		name := req.Name
+		if name == "" {
+			name = strings.TrimSuffix(filepath.Base(req.FileName), filepath.Ext(req.FileName))
+		}
+		dataset = &models.Dataset{
+			ID:        fmt.Sprintf("dataset-%d", time.Now().UnixNano()),
+			TenantID:  req.TenantID,
+			Name:      name,
+			Type:      "file",
+			Status:    1,
+			CreatedBy: req.CreatedBy,
+			CreatedAt: time.Now(),
+		}
+	} else {
+		if dataset, err = s.Get(ctx, req.DatasetID, req.TenantID); err != nil {
+			return nil, err
+		}
+		if dataset.Type != "file" {
+			return nil, errors.New("dataset is not a file dataset")
+		}
+		if req.Name != "" {
+			dataset.Name = req.Name
+		}
+	}
+	dataset.UpdatedAt = time.Now()
+
+	previous, err := parseFileDatasetConfig(dataset.Config)
+	if err != nil {
+		return nil, err
+	}
+	config := &fileDatasetConfig{
+		FileName:  filepath.Base(req.FileName),
+		Format:    format,
+		Delimiter: previous.Delimiter,
+		HasHeader: previous.HasHeader,
+		Sheet:     previous.Sheet,
+	}
+	if req.Delimiter != "" {
+		config.Delimiter = req.Delimiter
+	}
+	if req.HasHeader != nil {
+		config.HasHeader = req.HasHeader
+	}
+	if req.Sheet != "" {
+		config.Sheet = req.Sheet
+	}
+	if format != fileFormatCSV {
+		config.Delimiter = ""
+	}
+	if format != fileFormatExcel {
+		config.Sheet = ""
+	}
+
+	key, err := storage.Save(dataset.TenantID, dataset.ID, req.FileName, req.File)
+	if err != nil {
+		return nil, err
+	}
+	config.StorageKey = key
+
+	table, err := openFileTable(ctx, dataset.TenantID, config)
+	if err != nil {
+		_ = storage.Remove(dataset.TenantID, key)
+		return nil, err
+	}
+	if format == fileFormatExcel && config.Sheet == "" {
+		config.Sheet = table.sheets[0]
+	}
+	config.Sheets = table.sheets
+
+	encoded, err := json.Marshal(config)
+	if err != nil {
+		_ = storage.Remove(dataset.TenantID, key)
+		return nil, err
+	}
+	dataset.Config = string(encoded)
+
+	if isNew {
+		if err := s.datasetRepo.Create(ctx, dataset); err != nil {
+			_ = storage.Remove(dataset.TenantID, key)
+			return nil, err
+		}
+	}
+
+	diff, err := s.syncInferredFields(ctx, dataset.ID, inferFileFields(table))
+	if err != nil {
+		_ = storage.Remove(dataset.TenantID, key)
+		if isNew {
+			_ = s.datasetRepo.Delete(ctx, dataset.ID)
+		}
+		return nil, fmt.Errorf("failed to extract fields: %w", err)
+	}
+
+	if !isNew {
+		if err := s.datasetRepo.Update(ctx, dataset); err != nil {
+			return nil, err
+		}
+		if previous.StorageKey != "" && previous.StorageKey != key {
+			_ = storage.Remove(dataset.TenantID, previous.StorageKey)
+		}
+	}
+
+	result, err := s.datasetRepo.GetByIDWithFields(ctx, dataset.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &UploadFileResponse{Dataset: result, Sheets: config.Sheets, Diff: diff}, nil
+}
+
+// buildDatasetSources converts source requests into models and checks that
+// they form a valid join plan.
+func buildDatasetSources(datasetID string, requests []SourceRequest) ([]models.DatasetSource, error) {
+	sources := make([]models.DatasetSource, len(requests))
+	for i, req := range requests {
+		config := string(req.SourceConfig)
+		if config == "" {
+			config = "{}"
+		}
+		joinType := req.JoinType
+		if joinType == "" {
+			joinType = "inner"
+		}
+		sources[i] = models.DatasetSource{
+			ID:            fmt.Sprintf("source-%d-%d", time.Now().UnixNano(), i),
+			DatasetID:     datasetID,
+			SourceType:    req.SourceType,
+			SourceID:      req.SourceID,
+			SourceConfig:  config,
+			JoinType:      joinType,
+			JoinCondition: req.JoinCondition,
+			SortIndex:     i,
+			CreatedAt:     time.Now(),
+			UpdatedAt:     time.Now(),
+		}
+	}
+
+	if len(sources) > 0 {
+		if _, err := buildJoinPlan(sources); err != nil {
+			return nil, err
+		}
+	}
+	return sources, nil
+}
+
+// saveSources replaces the stored sources of a dataset.
+func (s *service) saveSources(ctx context.Context, dataset *models.Dataset, sources []models.DatasetSource) error {
+	current, err := s.sourceRepo.List(ctx, dataset.ID)
+	if err != nil {
+		return err
+	}
+	for _, source := range current {
+		if err := s.sourceRepo.Delete(ctx, source.ID); err != nil {
+			return err
+		}
+	}
+	for i := range sources {
+		if err := s.sourceRepo.Create(ctx, &sources[i]); err != nil {
+			return err
+		}
+	}
+	dataset.Sources = sources
 	return nil
 }
 
+// extractJoinedFields infers the fields of a joined dataset. A join on one
+// datasource is typed by the database; other joins are sampled, joining at
+// most previewRowLimit rows from each source.
+func (s *service) extractJoinedFields(ctx context.Context, dataset *models.Dataset) error {
+	plan, err := buildJoinPlan(dataset.Sources)
+	if err != nil {
+		return err
+	}
+
+	var inferred []models.DatasetField
+	if datasourceID, ok := sqlJoinDatasourceID(plan); ok {
+		if inferred, err = s.inferSQLJoinFields(ctx, dataset.TenantID, datasourceID, plan); err != nil {
+			return err
+		}
+	} else {
+		loader := &sourceRowLoader{
+			datasetRepo:    s.datasetRepo,
+			datasourceRepo: s.datasourceRepo,
+			httpClient:     s.httpClient,
+			pools:          s.pools,
+			timeout:        datasetPreviewTimeout,
+		}
+		columns, rows, err := loader.loadJoined(ctx, dataset.TenantID, plan, previewRowLimit, false)
+		if err != nil {
+			return err
+		}
+
+		byName := make(map[string]models.DatasetField)
+		for _, field := range inferRowFields(rows) {
+			byName[field.Name] = field
+		}
+		for i, column := range columns {
+			field, ok := byName[column]
+			if !ok {
+				field = models.DatasetField{Name: column, Type: "dimension", DataType: "string"}
+			}
+			field.SortIndex = i
+			inferred = append(inferred, field)
+		}
+	}
+
+	_, err = s.syncInferredFields(ctx, dataset.ID, inferred)
+	return err
+}
+
+func (s *service) inferSQLJoinFields(ctx context.Context, tenantID, datasourceID string, plan []*joinSource) ([]models.DatasetField, error) {
+	ds, err := s.datasourceRepo.GetByID(ctx, datasourceID)
+	if err != nil || ds.TenantID != tenantID {
+		return nil, errors.New("datasource not found")
+	}
+
+	pool, err := s.pools.Acquire(ctx, ds)
+	if err != nil {
+		return nil, err
+	}
+	defer pool.Release()
+	queryCtx, cancel := pool.WithTimeout(ctx, datasetPreviewTimeout)
+	defer cancel()
+
+	query, err := buildSQLJoin(queryCtx, pool.DB, pool.Dialect, plan)
+	if err != nil {
+		return nil, pool.Check(err)
+	}
+	rows, err := pool.DB.QueryContext(queryCtx, pool.Dialect.Limit(fmt.Sprintf("SELECT * FROM (%s) AS tmp", query), 0, 0))
+	if err != nil {
+		return nil, pool.Check(err)
+	}
+	defer rows.Close()
+
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make([]models.DatasetField, len(columnTypes))
+	for i, columnType := range columnTypes {
+		fields[i] = models.DatasetField{
+			Name:      columnType.Name(),
+			Type:      inferFieldType(columnType.DatabaseTypeName()),
+			DataType:  mapSQLTypeToDataType(columnType.DatabaseTypeName()),
+			SortIndex: i,
+		}
+	}
+	return fields, nil
+}
+
+// executeJoinedPreview runs the first page of a joined dataset through the
+// query executor, which owns the join strategy.
+func (s *service) executeJoinedPreview(ctx context.Context, dataset *models.Dataset) ([]map[string]interface{}, error) {
+	executor := NewQueryExecutor(s.datasetRepo, s.fieldRepo, s.datasourceRepo, s.sqlBuilder, s.cache)
+	resp, err := executor.Query(ctx, &QueryRequest{DatasetID: dataset.ID, Page: 1, PageSize: previewRowLimit})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// syncInferredFields reconciles the stored, non-computed fields with fields
+// inferred from a file or a join: new columns are added, missing ones removed
+// and columns whose data type changed are retyped.
+func (s *service) syncInferredFields(ctx context.Context, datasetID string, inferred []models.DatasetField) (*SchemaDiff, error) {
+	current, err := s.fieldRepo.List(ctx, datasetID)
+	if err != nil {
+		return nil, err
+	}
+
+	existing := make(map[string]*models.DatasetField, len(current))
+	for _, field := range current {
+		if !field.IsComputed && !field.IsGroupingField {
+			existing[field.Name] = field
+		}
+	}
+
+	diff := &SchemaDiff{Added: []string{}, Removed: []string{}, Changed: []FieldTypeChange{}}
+	for _, inferredField := range inferred {
+		field, ok := existing[inferredField.Name]
+		if !ok {
+			name := inferredField.Name
+			created := inferredField
+			created.ID = fmt.Sprintf("field-%d", time.Now().UnixNano())
+			created.DatasetID = datasetID
+			created.DisplayName = &name
+			created.Config = "{}"
+			created.CreatedAt = time.Now()
+			created.UpdatedAt = time.Now()
+			if err := s.fieldRepo.Create(ctx, &created); err != nil {
+				return nil, err
+			}
+			diff.Added = append(diff.Added, name)
+			continue
+		}
+		delete(existing, inferredField.Name)
+
+		if field.DataType == inferredField.DataType && field.SortIndex == inferredField.SortIndex {
+			continue
+		}
+		if field.DataType != inferredField.DataType {
+			diff.Changed = append(diff.Changed, FieldTypeChange{
+				Name: field.Name,
+				From: field.DataType,
+				To:   inferredField.DataType,
+			})
+			field.DataType = inferredField.DataType
+			field.Type = inferredField.Type
+		}
+		field.SortIndex = inferredField.SortIndex
+		field.UpdatedAt = time.Now()
+		if err := s.fieldRepo.Update(ctx, field); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, field := range existing {
+		if err := s.fieldRepo.Delete(ctx, field.ID); err != nil {
+			return nil, err
+		}
+		diff.Removed = append(diff.Removed, field.Name)
+	}
+	sort.Strings(diff.Removed)
+
+	return diff, nil
+}
+
 func (s *service) extractSQLFields(ctx context.Context, dataset *models.Dataset) error {
-	datasource, err := s.datasourceRepo.GetByID(ctx, *dataset.DatasourceID)
+	ds, err := s.datasourceRepo.GetByID(ctx, *dataset.DatasourceID)
 	if err != nil {
 		return err
 	}
@@ -281,16 +844,18 @@ func (s *service) extractSQLFields(ctx context.Context, dataset *models.Dataset)
 		return err
 	}
 
-	db, err := s.getDBConnection(datasource)
+	pool, err := s.pools.Acquire(ctx, ds)
 	if err != nil {
 		return err
 	}
-	defer db.Close()
+	defer pool.Release()
+	queryCtx, cancel := pool.WithTimeout(ctx, datasetPreviewTimeout)
+	defer cancel()
 
-	query := fmt.Sprintf("SELECT * FROM (%s) AS tmp LIMIT 0", config.Query)
-	rows, err := db.Query(query)
+	query := pool.Dialect.Limit(fmt.Sprintf("SELECT * FROM (%s) AS tmp", config.Query), 0, 0)
+	rows, err := pool.DB.QueryContext(queryCtx, query)
 	if err != nil {
-		return err
+		return pool.Check(err)
 	}
 	defer rows.Close()
 
@@ -333,7 +898,7 @@ func (s *service) extractSQLFields(ctx context.Context, dataset *models.Dataset)
 }
 
 func (s *service) executeSQLPreview(ctx context.Context, dataset *models.Dataset) ([]map[string]interface{}, error) {
-	datasource, err := s.datasourceRepo.GetByID(ctx, *dataset.DatasourceID)
+	ds, err := s.datasourceRepo.GetByID(ctx, *dataset.DatasourceID)
 	if err != nil {
 		return nil, err
 	}
@@ -348,22 +913,22 @@ func (s *service) executeSQLPreview(ctx context.Context, dataset *models.Dataset
 		return nil, fmt.Errorf("query validation failed: %w", err)
 	}
 
-	db, err := s.getDBConnection(datasource)
+	pool, err := s.pools.Acquire(ctx, ds)
 	if err != nil {
 		return nil, err
 	}
-	defer db.Close()
+	defer pool.Release()
 
-	previewCtx, cancel := withDatasetPreviewTimeout(ctx)
+	previewCtx, cancel := pool.WithTimeout(ctx, datasetPreviewTimeout)
 	defer cancel()
 
-	query := fmt.Sprintf("%s LIMIT 100", config.Query)
-	rows, err := db.QueryContext(previewCtx, query)
+	query := pool.Dialect.Limit(fmt.Sprintf("SELECT * FROM (%s) AS preview_query", config.Query), previewRowLimit, 0)
+	rows, err := pool.DB.QueryContext(previewCtx, query)
 	if err != nil {
 		if errors.Is(err, context.DeadlineExceeded) {
 			return nil, errors.New("preview query timeout")
 		}
-		return nil, err
+		return nil, pool.Check(err)
 	}
 	defer rows.Close()
 
@@ -406,25 +971,17 @@ func (s *service) executeSQLPreview(ctx context.Context, dataset *models.Dataset
 	return results, nil
 }
 
-func (s *service) getDBConnection(datasource *models.DataSource) (*sql.DB, error) {
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?parseTime=true",
-		datasource.Username,
-		datasource.Password,
-		datasource.Host,
-		datasource.Port,
-		datasource.Database,
-	)
-
-	return sql.Open("mysql", dsn)
-}
-
+// Type names are the DatabaseTypeName values reported by the MySQL and pgx
+// drivers; PostgreSQL uses its internal names (INT4, FLOAT8, TIMESTAMPTZ...).
 func mapSQLTypeToDataType(sqlType string) string {
 	switch sqlType {
-	case "INT", "TINYINT", "SMALLINT", "MEDIUMINT", "BIGINT", "FLOAT", "DOUBLE", "DECIMAL":
+	case "INT", "TINYINT", "SMALLINT", "MEDIUMINT", "BIGINT", "FLOAT", "DOUBLE", "DECIMAL",
+		"INT2", "INT4", "INT8", "FLOAT4", "FLOAT8", "NUMERIC", "MONEY":
 		return "number"
-	case "DATE", "DATETIME", "TIMESTAMP", "TIME", "YEAR":
+	case "DATE", "DATETIME", "TIMESTAMP", "TIME", "YEAR",
+		"TIMESTAMPTZ", "TIMETZ", "INTERVAL":
 		return "date"
-	case "BOOLEAN", "TINYINT(1)":
+	case "BOOLEAN", "TINYINT(1)", "BOOL":
 		return "boolean"
 	default:
 		return "string"
@@ -435,7 +992,8 @@ func inferFieldType(sqlType string) string {
 	switch sqlType {
 	case "VARCHAR", "CHAR", "TEXT", "DATE", "DATETIME", "TIMESTAMP":
 		return "dimension"
-	case "INT", "TINYINT", "SMALLINT", "MEDIUMINT", "BIGINT", "FLOAT", "DOUBLE", "DECIMAL":
+	case "INT", "TINYINT", "SMALLINT", "MEDIUMINT", "BIGINT", "FLOAT", "DOUBLE", "DECIMAL",
+		"INT2", "INT4", "INT8", "FLOAT4", "FLOAT8", "NUMERIC", "MONEY":
 		return "measure"
 	case "BOOLEAN", "TINYINT(1)":
 		return "dimension"
@@ -511,7 +1069,7 @@ func (s *service) CreateComputedField(ctx context.Context, req *CreateFieldReque
 	}
 
 	if dataset.TenantID != req.TenantID {
-		return nil, errors.New("dataset not found")
+		return nil, ErrNotFound
 	}
 
 	if !req.IsGroupingField {
@@ -520,17 +1078,23 @@ func (s *service) CreateComputedField(ctx context.Context, req *CreateFieldReque
 			return nil, err
 		}
 
-		var fieldNames []string
+		datasetFields := make([]models.DatasetField, 0, len(fields))
 		for _, f := range fields {
-			fieldNames = append(fieldNames, f.Name)
+			datasetFields = append(datasetFields, *f)
 		}
 
-		if err := s.sqlBuilder.Validate(*req.Expression, fieldNames); err != nil {
+		if err := s.sqlBuilder.ValidateFields(*req.Expression, datasetFields); err != nil {
 			return nil, fmt.Errorf("invalid expression: %w", err)
 		}
 
-		if strings.Contains(*req.Expression, fmt.Sprintf("[%s]", req.Name)) {
-			return nil, errors.New("expression cannot reference itself")
+		node, err := parseExpression(*req.Expression)
+		if err != nil {
+			return nil, fmt.Errorf("invalid expression: %w", err)
+		}
+		for _, ref := range collectFieldReferences(node) {
+			if ref == req.Name {
+				return nil, errors.New("expression cannot reference itself")
+			}
 		}
 	}
 
@@ -628,7 +1192,7 @@ func (s *service) BatchUpdateFields(ctx context.Context, datasetID, tenantID str
 		return nil, err
 	}
 	if dataset.TenantID != tenantID {
-		return nil, errors.New("dataset not found")
+		return nil, ErrNotFound
 	}
 
 	fields, err := s.fieldRepo.List(ctx, datasetID)
@@ -701,7 +1265,7 @@ func (s *service) ListDimensions(ctx context.Context, datasetID, tenantID string
 	}
 
 	if dataset.TenantID != tenantID {
-		return nil, errors.New("dataset not found")
+		return nil, ErrNotFound
 	}
 
 	return s.fieldRepo.ListByType(ctx, datasetID, "dimension")
@@ -714,7 +1278,7 @@ func (s *service) ListMeasures(ctx context.Context, datasetID, tenantID string)
 	}
 
 	if dataset.TenantID != tenantID {
-		return nil, errors.New("dataset not found")
+		return nil, ErrNotFound
 	}
 
 	return s.fieldRepo.ListByType(ctx, datasetID, "measure")
@@ -727,7 +1291,7 @@ func (s *service) ListFields(ctx context.Context, datasetID, tenantID string) ([
 	}
 
 	if dataset.TenantID != tenantID {
-		return nil, errors.New("dataset not found")
+		return nil, ErrNotFound
 	}
 
 	return s.fieldRepo.List(ctx, datasetID)
@@ -764,24 +1328,22 @@ func (s *service) resolveFieldDependencies(ctx context.Context, datasetID, field
 	}
 
 	if field.Expression != nil {
-		expression := *field.Expression
-		fieldPattern := regexp.MustCompile(`\[(\w+)\]`)
-		matches := fieldPattern.FindAllStringSubmatch(expression, -1)
-
-		for _, match := range matches {
-			if len(match) > 1 {
-				refFieldName := match[1]
-				fields, err := s.fieldRepo.List(ctx, datasetID)
-				if err != nil {
-					return err
-				}
+		node, err := parseExpression(*field.Expression)
+		if err != nil {
+			return fmt.Errorf("field %s has an invalid expression: %w", field.Name, err)
+		}
+
+		for _, refFieldName := range collectFieldReferences(node) {
+			fields, err := s.fieldRepo.List(ctx, datasetID)
+			if err != nil {
+				return err
+			}
 
-				for _, f := range fields {
-					if f.Name == refFieldName {
-						if f.IsComputed {
-							if err := s.resolveFieldDependencies(ctx, datasetID, f.ID, dependencies, visited); err != nil {
-								return err
-							}
+			for _, f := range fields {
+				if f.Name == refFieldName {
+					if f.IsComputed {
+						if err := s.resolveFieldDependencies(ctx, datasetID, f.ID, dependencies, visited); err != nil {
+							return err
 						}
 					}
 				}
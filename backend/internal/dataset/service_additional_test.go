@@ -17,7 +17,7 @@ func TestDatasetService_Preview_NotSQLDataset(t *testing.T) {
 	expectedDataset := &models.Dataset{
 		ID:       "ds-1",
 		TenantID: "tenant-1",
-		Type:     "api",
+		Type:     "static",
 	}
 
 	mockDatasetRepo.On("GetByIDWithFields", mock.Anything, "ds-1").Return(expectedDataset, nil)
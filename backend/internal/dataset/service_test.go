@@ -145,7 +145,7 @@ func (m *mockDatasetSourceRepository) GetByID(ctx context.Context, id string) (*
 	return args.Get(0).(*models.DatasetSource), args.Error(1)
 }
 
-func (m *mockDatasetSourceRepository) ListByDatasetID(ctx context.Context, datasetID string) ([]*models.DatasetSource, error) {
+func (m *mockDatasetSourceRepository) List(ctx context.Context, datasetID string) ([]*models.DatasetSource, error) {
 	args := m.Called(ctx, datasetID)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -153,6 +153,11 @@ func (m *mockDatasetSourceRepository) ListByDatasetID(ctx context.Context, datas
 	return args.Get(0).([]*models.DatasetSource), args.Error(1)
 }
 
+func (m *mockDatasetSourceRepository) Update(ctx context.Context, source *models.DatasetSource) error {
+	args := m.Called(ctx, source)
+	return args.Error(0)
+}
+
 func (m *mockDatasetSourceRepository) Delete(ctx context.Context, id string) error {
 	args := m.Called(ctx, id)
 	return args.Error(0)
@@ -7,7 +7,7 @@ import (
 	"time"
 
 	"github.com/gujiaweiguo/goreport/internal/cache"
-	"gorm.io/driver/mysql"
+	"github.com/gujiaweiguo/goreport/internal/models"
 	"gorm.io/gorm"
 )
 
@@ -38,6 +38,43 @@ func extractDatabaseFromDSN(dsn string) string {
 }
 
 func (s *CachedMetadataService) GetTables(ctx context.Context, tenantID, datasourceID, dsn string) ([]string, error) {
+	return s.GetTablesWithDialect(ctx, tenantID, datasourceID, DefaultDialect(), dsn, extractDatabaseFromDSN(dsn))
+}
+
+func (s *CachedMetadataService) GetTablesWithDialect(ctx context.Context, tenantID, datasourceID string, dialect Dialect, dsn, database string) ([]string, error) {
+	return s.cachedTables(ctx, tenantID, datasourceID, func() ([]string, error) {
+		db, err := gorm.Open(dialect.GormDialector(dsn), &gorm.Config{})
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			if sqlDB, err := db.DB(); err == nil {
+				sqlDB.Close()
+			}
+		}()
+
+		return GetTablesWithDialect(ctx, db, dialect, database)
+	})
+}
+
+// GetDatasourceTables lists the tables of a saved datasource through its
+// shared pool, so SSH tunnels and QueryTimeoutSeconds apply.
+func (s *CachedMetadataService) GetDatasourceTables(ctx context.Context, pools *PoolManager, ds *models.DataSource) ([]string, error) {
+	return s.cachedTables(ctx, ds.TenantID, ds.ID, func() ([]string, error) {
+		pool, db, err := acquireGorm(ctx, pools, ds)
+		if err != nil {
+			return nil, err
+		}
+		defer pool.Release()
+
+		queryCtx, cancel := pool.WithTimeout(ctx, DefaultQueryTimeout)
+		defer cancel()
+		tables, err := GetTablesWithDialect(queryCtx, db, pool.Dialect, ds.Database)
+		return tables, pool.Check(err)
+	})
+}
+
+func (s *CachedMetadataService) cachedTables(ctx context.Context, tenantID, datasourceID string, load func() ([]string, error)) ([]string, error) {
 	domain := "datasource:tables"
 	identity := datasourceID
 
@@ -48,18 +85,7 @@ func (s *CachedMetadataService) GetTables(ctx context.Context, tenantID, datasou
 		}
 	}
 
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
-	if err != nil {
-		return nil, err
-	}
-	defer func() {
-		if sqlDB, err := db.DB(); err == nil {
-			sqlDB.Close()
-		}
-	}()
-
-	database := extractDatabaseFromDSN(dsn)
-	tables, err := GetTables(ctx, db, database)
+	tables, err := load()
 	if err != nil {
 		return nil, err
 	}
@@ -71,6 +97,43 @@ func (s *CachedMetadataService) GetTables(ctx context.Context, tenantID, datasou
 }
 
 func (s *CachedMetadataService) GetFields(ctx context.Context, tenantID, datasourceID, dsn, tableName string) ([]FieldInfo, error) {
+	return s.GetFieldsWithDialect(ctx, tenantID, datasourceID, DefaultDialect(), dsn, extractDatabaseFromDSN(dsn), tableName)
+}
+
+func (s *CachedMetadataService) GetFieldsWithDialect(ctx context.Context, tenantID, datasourceID string, dialect Dialect, dsn, database, tableName string) ([]FieldInfo, error) {
+	return s.cachedFields(ctx, tenantID, datasourceID, tableName, func() ([]FieldInfo, error) {
+		db, err := gorm.Open(dialect.GormDialector(dsn), &gorm.Config{})
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			if sqlDB, err := db.DB(); err == nil {
+				sqlDB.Close()
+			}
+		}()
+
+		return GetFieldsWithDialect(ctx, db, dialect, database, tableName)
+	})
+}
+
+// GetDatasourceFields lists the columns of a table through the datasource's
+// shared pool.
+func (s *CachedMetadataService) GetDatasourceFields(ctx context.Context, pools *PoolManager, ds *models.DataSource, tableName string) ([]FieldInfo, error) {
+	return s.cachedFields(ctx, ds.TenantID, ds.ID, tableName, func() ([]FieldInfo, error) {
+		pool, db, err := acquireGorm(ctx, pools, ds)
+		if err != nil {
+			return nil, err
+		}
+		defer pool.Release()
+
+		queryCtx, cancel := pool.WithTimeout(ctx, DefaultQueryTimeout)
+		defer cancel()
+		fields, err := GetFieldsWithDialect(queryCtx, db, pool.Dialect, ds.Database, tableName)
+		return fields, pool.Check(err)
+	})
+}
+
+func (s *CachedMetadataService) cachedFields(ctx context.Context, tenantID, datasourceID, tableName string, load func() ([]FieldInfo, error)) ([]FieldInfo, error) {
 	domain := "datasource:fields"
 	identity := datasourceID + ":" + tableName
 
@@ -81,18 +144,7 @@ func (s *CachedMetadataService) GetFields(ctx context.Context, tenantID, datasou
 		}
 	}
 
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
-	if err != nil {
-		return nil, err
-	}
-	defer func() {
-		if sqlDB, err := db.DB(); err == nil {
-			sqlDB.Close()
-		}
-	}()
-
-	database := extractDatabaseFromDSN(dsn)
-	fields, err := GetFields(ctx, db, database, tableName)
+	fields, err := load()
 	if err != nil {
 		return nil, err
 	}
@@ -102,3 +154,16 @@ func (s *CachedMetadataService) GetFields(ctx context.Context, tenantID, datasou
 
 	return fields, nil
 }
+
+func acquireGorm(ctx context.Context, pools *PoolManager, ds *models.DataSource) (*Pool, *gorm.DB, error) {
+	pool, err := pools.Acquire(ctx, ds)
+	if err != nil {
+		return nil, nil, err
+	}
+	db, err := pool.Gorm()
+	if err != nil {
+		pool.Release()
+		return nil, nil, err
+	}
+	return pool, db, nil
+}
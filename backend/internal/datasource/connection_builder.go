@@ -2,13 +2,13 @@ package datasource
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"net"
 	"strconv"
 	"time"
 
 	"github.com/gujiaweiguo/goreport/internal/models"
-	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
@@ -20,6 +20,11 @@ func NewConnectionBuilder() *ConnectionBuilder {
 }
 
 func (b *ConnectionBuilder) BuildDSN(ctx context.Context, ds *models.DataSource) (string, *SSHTunnel, error) {
+	dialect, err := GetDialect(ds.Type)
+	if err != nil {
+		return "", nil, err
+	}
+
 	var tunnel *SSHTunnel
 
 	if ds.SSHHost != "" && ds.SSHPort > 0 {
@@ -55,23 +60,51 @@ func (b *ConnectionBuilder) BuildDSN(ctx context.Context, ds *models.DataSource)
 		ds.Port = portStr
 	}
 
-	port := strconv.Itoa(ds.Port)
 	effectiveHost := ds.Host
 	if tunnel == nil {
 		effectiveHost = ResolveHost(ds.Host)
 	}
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
-		ds.Username,
-		ds.Password,
-		effectiveHost,
-		port,
-		ds.Database,
-	)
+	dsn := dialect.BuildDSN(effectiveHost, ds.Port, ds.Database, ds.Username, ds.Password)
 
 	return dsn, tunnel, nil
 }
 
+// Open is the connection factory behind every query path: it sets up the SSH
+// tunnel when the datasource has one and applies MaxConnections. The caller
+// owns the returned db and tunnel. ds is not modified.
+func (b *ConnectionBuilder) Open(ctx context.Context, ds *models.DataSource) (*sql.DB, Dialect, *SSHTunnel, error) {
+	dialect, err := GetDialect(ds.Type)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	conn := *ds
+	dsn, tunnel, err := b.BuildDSN(ctx, &conn)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	db, err := sql.Open(dialect.DriverName(), dsn)
+	if err != nil {
+		if tunnel != nil {
+			tunnel.Close()
+		}
+		return nil, nil, nil, err
+	}
+	if ds.MaxConnections > 0 {
+		db.SetMaxOpenConns(ds.MaxConnections)
+		db.SetMaxIdleConns(min(ds.MaxConnections, 5))
+	}
+
+	return db, dialect, tunnel, nil
+}
+
 func (b *ConnectionBuilder) Connect(ctx context.Context, ds *models.DataSource) (*gorm.DB, *SSHTunnel, error) {
+	dialect, err := GetDialect(ds.Type)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	dsn, tunnel, err := b.BuildDSN(ctx, ds)
 	if err != nil {
 		return nil, nil, err
@@ -87,7 +120,7 @@ func (b *ConnectionBuilder) Connect(ctx context.Context, ds *models.DataSource)
 	errChan := make(chan error, 1)
 
 	go func() {
-		openedDB, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
+		openedDB, err := gorm.Open(dialect.GormDialector(dsn), &gorm.Config{
 			Logger: logger.Default.LogMode(logger.Silent),
 			NowFunc: func() time.Time {
 				return time.Now().Local()
@@ -140,22 +173,20 @@ func (b *ConnectionBuilder) Connect(ctx context.Context, ds *models.DataSource)
 }
 
 func (b *ConnectionBuilder) TestConnection(ctx context.Context, ds *models.DataSource) error {
-	db, tunnel, err := b.Connect(ctx, ds)
+	db, _, tunnel, err := b.Open(ctx, ds)
 	if err != nil {
 		return err
 	}
 	defer func() {
+		db.Close()
 		if tunnel != nil {
 			tunnel.Close()
 		}
 	}()
 
-	sqlDB, err := db.DB()
-	if err != nil {
-		return err
-	}
-
-	if err := sqlDB.Ping(); err != nil {
+	pingCtx, cancel := WithQueryTimeout(ctx, ds, DefaultQueryTimeout)
+	defer cancel()
+	if err := db.PingContext(pingCtx); err != nil {
 		return fmt.Errorf("database ping failed: %w", err)
 	}
 
@@ -0,0 +1,492 @@
+package datasource
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/driver/clickhouse"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlserver"
+	"gorm.io/gorm"
+)
+
+// Dialect captures the engine-specific SQL details shared by the dataset
+// executor, computed-field rendering, schema extraction and metadata
+// browsing.
+type Dialect interface {
+	Name() string
+	DriverName() string
+	BuildDSN(host string, port int, database, username, password string) string
+	GormDialector(dsn string) gorm.Dialector
+	// GormConnDialector wraps an already open connection pool.
+	GormConnDialector(conn gorm.ConnPool) gorm.Dialector
+	QuoteIdentifier(name string) string
+	QuoteString(value string) string
+	// RenderFunction maps a generic computed-field function, such as
+	// DATE_FORMAT or IF, to the engine's syntax.
+	RenderFunction(name string, args []string) (string, error)
+	Placeholder(index int) string
+	// Limit caps query, a complete SELECT statement, at limit rows after
+	// skipping offset.
+	Limit(query string, limit, offset int) string
+	TablesQuery() string
+	FieldsQuery() string
+}
+
+var dialects = map[string]Dialect{
+	"mysql":      mysqlDialect{},
+	"postgres":   postgresDialect{},
+	"sqlite":     sqliteDialect{},
+	"clickhouse": clickHouseDialect{},
+	"sqlserver":  sqlServerDialect{},
+}
+
+var dialectAliases = map[string]string{
+	"":           "mysql",
+	"postgresql": "postgres",
+	"sqlite3":    "sqlite",
+	"mssql":      "sqlserver",
+}
+
+// GetDialect returns the dialect registered for a datasource type. An empty
+// type falls back to MySQL, which is what datasources created before typed
+// connectors were introduced always used.
+func GetDialect(dsType string) (Dialect, error) {
+	normalized := strings.ToLower(strings.TrimSpace(dsType))
+	if alias, ok := dialectAliases[normalized]; ok {
+		normalized = alias
+	}
+
+	dialect, ok := dialects[normalized]
+	if !ok {
+		return nil, fmt.Errorf("unsupported sql dialect: %s", dsType)
+	}
+	return dialect, nil
+}
+
+// DefaultDialect returns the MySQL dialect.
+func DefaultDialect() Dialect {
+	return dialects["mysql"]
+}
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) Name() string { return "mysql" }
+
+func (mysqlDialect) DriverName() string { return "mysql" }
+
+// BuildDSN keeps the parameters dataset queries have always connected with;
+// DATETIME values are read in UTC, as the driver does by default.
+func (mysqlDialect) BuildDSN(host string, port int, database, username, password string) string {
+	return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true",
+		username,
+		password,
+		host,
+		strconv.Itoa(port),
+		database,
+	)
+}
+
+func (mysqlDialect) GormDialector(dsn string) gorm.Dialector {
+	return mysql.Open(dsn)
+}
+
+func (mysqlDialect) GormConnDialector(conn gorm.ConnPool) gorm.Dialector {
+	return mysql.New(mysql.Config{Conn: conn})
+}
+
+func (mysqlDialect) QuoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// QuoteString escapes backslashes too, which MySQL treats as an escape
+// character in string literals.
+func (mysqlDialect) QuoteString(value string) string {
+	return quoteSQLString(strings.ReplaceAll(value, `\`, `\\`))
+}
+
+func (mysqlDialect) RenderFunction(name string, args []string) (string, error) {
+	return renderFunction("mysql", mysqlFunctions, name, args)
+}
+
+func (mysqlDialect) Placeholder(int) string { return "?" }
+
+func (mysqlDialect) Limit(query string, limit, offset int) string {
+	return limitOffset(query, limit, offset)
+}
+
+func (mysqlDialect) TablesQuery() string {
+	return `
+		SELECT table_name
+		FROM information_schema.tables
+		WHERE table_schema = ? AND table_type = 'BASE TABLE'
+		ORDER BY table_name
+	`
+}
+
+func (mysqlDialect) FieldsQuery() string {
+	return `
+		SELECT
+			column_name AS name,
+			data_type AS type,
+			(is_nullable = 'YES') AS nullable,
+			column_comment AS comment
+		FROM information_schema.columns
+		WHERE table_schema = ? AND table_name = ?
+		ORDER BY ordinal_position
+	`
+}
+
+type postgresDialect struct{}
+
+func (postgresDialect) Name() string { return "postgres" }
+
+// DriverName is the database/sql name registered by pgx's stdlib package,
+// which the gorm postgres driver imports.
+func (postgresDialect) DriverName() string { return "pgx" }
+
+func (postgresDialect) BuildDSN(host string, port int, database, username, password string) string {
+	dsn := url.URL{
+		Scheme:   "postgres",
+		Host:     fmt.Sprintf("%s:%d", host, port),
+		Path:     "/" + database,
+		RawQuery: "sslmode=disable",
+	}
+	if username != "" {
+		if password != "" {
+			dsn.User = url.UserPassword(username, password)
+		} else {
+			dsn.User = url.User(username)
+		}
+	}
+	return dsn.String()
+}
+
+func (postgresDialect) GormDialector(dsn string) gorm.Dialector {
+	return postgres.Open(dsn)
+}
+
+func (postgresDialect) GormConnDialector(conn gorm.ConnPool) gorm.Dialector {
+	return postgres.New(postgres.Config{Conn: conn})
+}
+
+func (postgresDialect) QuoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func (postgresDialect) QuoteString(value string) string {
+	return quoteSQLString(value)
+}
+
+func (postgresDialect) RenderFunction(name string, args []string) (string, error) {
+	return renderFunction("postgres", postgresFunctions, name, args)
+}
+
+func (postgresDialect) Placeholder(index int) string {
+	return "$" + strconv.Itoa(index)
+}
+
+func (postgresDialect) Limit(query string, limit, offset int) string {
+	return limitOffset(query, limit, offset)
+}
+
+// TablesQuery lists base tables of every user schema in the connected
+// database; the schema is kept in the name so search_path does not matter.
+func (postgresDialect) TablesQuery() string {
+	return `
+		SELECT CASE WHEN table_schema = 'public' THEN table_name ELSE table_schema || '.' || table_name END AS table_name
+		FROM information_schema.tables
+		WHERE table_catalog = ? AND table_type = 'BASE TABLE'
+			AND table_schema NOT IN ('pg_catalog', 'information_schema')
+		ORDER BY table_schema, table_name
+	`
+}
+
+func (postgresDialect) FieldsQuery() string {
+	return `
+		SELECT
+			c.column_name AS name,
+			c.data_type AS type,
+			(c.is_nullable = 'YES') AS nullable,
+			COALESCE(col_description(format('%I.%I', c.table_schema, c.table_name)::regclass, c.ordinal_position), '') AS comment
+		FROM information_schema.columns c
+		WHERE c.table_catalog = ?
+			AND (CASE WHEN c.table_schema = 'public' THEN c.table_name ELSE c.table_schema || '.' || c.table_name END) = ?
+		ORDER BY c.ordinal_position
+	`
+}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Name() string { return "sqlite" }
+
+// DriverName is the pure-Go driver registered by glebarez/sqlite, so the
+// server still builds without cgo.
+func (sqliteDialect) DriverName() string { return sqlite.DriverName }
+
+// BuildDSN opens the database file read-only; SQLite has no server, so the
+// host, port and credentials are unused.
+func (sqliteDialect) BuildDSN(host string, port int, database, username, password string) string {
+	return "file:" + database + "?mode=ro"
+}
+
+func (sqliteDialect) GormDialector(dsn string) gorm.Dialector {
+	return sqlite.Open(dsn)
+}
+
+func (sqliteDialect) GormConnDialector(conn gorm.ConnPool) gorm.Dialector {
+	return &sqlite.Dialector{Conn: conn}
+}
+
+func (sqliteDialect) QuoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func (sqliteDialect) QuoteString(value string) string {
+	return quoteSQLString(value)
+}
+
+func (sqliteDialect) RenderFunction(name string, args []string) (string, error) {
+	return renderFunction("sqlite", sqliteFunctions, name, args)
+}
+
+func (sqliteDialect) Placeholder(int) string { return "?" }
+
+func (sqliteDialect) Limit(query string, limit, offset int) string {
+	return limitOffset(query, limit, offset)
+}
+
+// TablesQuery ignores its database argument: the database is the file the
+// connection opened.
+func (sqliteDialect) TablesQuery() string {
+	return `
+		SELECT name AS table_name
+		FROM sqlite_master, (SELECT ? AS database_name) AS source
+		WHERE type = 'table' AND name NOT LIKE 'sqlite_%'
+		ORDER BY name
+	`
+}
+
+func (sqliteDialect) FieldsQuery() string {
+	return `
+		SELECT
+			c.name AS name,
+			c.type AS type,
+			(c."notnull" = 0) AS nullable,
+			'' AS comment
+		FROM (SELECT ? AS database_name) AS source, pragma_table_info(?) AS c
+		ORDER BY c.cid
+	`
+}
+
+type clickHouseDialect struct{}
+
+func (clickHouseDialect) Name() string { return "clickhouse" }
+
+// DriverName is the database/sql name registered by clickhouse-go, which
+// the gorm clickhouse driver imports.
+func (clickHouseDialect) DriverName() string { return "clickhouse" }
+
+func (clickHouseDialect) BuildDSN(host string, port int, database, username, password string) string {
+	dsn := url.URL{
+		Scheme: "clickhouse",
+		Host:   fmt.Sprintf("%s:%d", host, port),
+		Path:   "/" + database,
+	}
+	if username != "" {
+		dsn.User = url.UserPassword(username, password)
+	}
+	return dsn.String()
+}
+
+func (clickHouseDialect) GormDialector(dsn string) gorm.Dialector {
+	return clickhouse.Open(dsn)
+}
+
+func (clickHouseDialect) GormConnDialector(conn gorm.ConnPool) gorm.Dialector {
+	return clickhouse.New(clickhouse.Config{Conn: conn})
+}
+
+func (clickHouseDialect) QuoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// QuoteString escapes backslashes too, which ClickHouse treats as an escape
+// character in string literals.
+func (clickHouseDialect) QuoteString(value string) string {
+	return quoteSQLString(strings.ReplaceAll(value, `\`, `\\`))
+}
+
+func (clickHouseDialect) RenderFunction(name string, args []string) (string, error) {
+	return renderFunction("clickhouse", clickHouseFunctions, name, args)
+}
+
+func (clickHouseDialect) Placeholder(int) string { return "?" }
+
+func (clickHouseDialect) Limit(query string, limit, offset int) string {
+	return limitOffset(query, limit, offset)
+}
+
+func (clickHouseDialect) TablesQuery() string {
+	return `
+		SELECT name AS table_name
+		FROM system.tables
+		WHERE database = ? AND NOT is_temporary AND engine NOT IN ('View', 'MaterializedView')
+		ORDER BY name
+	`
+}
+
+func (clickHouseDialect) FieldsQuery() string {
+	return `
+		SELECT
+			name,
+			type,
+			startsWith(type, 'Nullable(') AS nullable,
+			comment
+		FROM system.columns
+		WHERE database = ? AND table = ?
+		ORDER BY position
+	`
+}
+
+type sqlServerDialect struct{}
+
+func (sqlServerDialect) Name() string { return "sqlserver" }
+
+// DriverName is the database/sql name registered by go-mssqldb, which the
+// gorm sqlserver driver imports; it takes @pN placeholders.
+func (sqlServerDialect) DriverName() string { return "sqlserver" }
+
+func (sqlServerDialect) BuildDSN(host string, port int, database, username, password string) string {
+	dsn := url.URL{
+		Scheme:   "sqlserver",
+		Host:     fmt.Sprintf("%s:%d", host, port),
+		RawQuery: url.Values{"database": {database}}.Encode(),
+	}
+	if username != "" {
+		dsn.User = url.UserPassword(username, password)
+	}
+	return dsn.String()
+}
+
+func (sqlServerDialect) GormDialector(dsn string) gorm.Dialector {
+	return sqlserver.Open(dsn)
+}
+
+func (sqlServerDialect) GormConnDialector(conn gorm.ConnPool) gorm.Dialector {
+	return sqlserver.New(sqlserver.Config{Conn: conn})
+}
+
+func (sqlServerDialect) QuoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func (sqlServerDialect) QuoteString(value string) string {
+	return quoteSQLString(value)
+}
+
+func (sqlServerDialect) RenderFunction(name string, args []string) (string, error) {
+	return renderFunction("sqlserver", sqlServerFunctions, name, args)
+}
+
+func (sqlServerDialect) Placeholder(index int) string {
+	return "@p" + strconv.Itoa(index)
+}
+
+// Limit uses TOP for the leading rows. Skipping rows takes OFFSET ... FETCH,
+// which SQL Server only accepts after an ORDER BY.
+func (sqlServerDialect) Limit(query string, limit, offset int) string {
+	if offset == 0 || limit == 0 {
+		return selectTop(query, limit)
+	}
+	if !hasOrderBy(query) {
+		query += " ORDER BY (SELECT NULL)"
+	}
+	return fmt.Sprintf("%s OFFSET %d ROWS FETCH NEXT %d ROWS ONLY", query, offset, limit)
+}
+
+// TablesQuery lists base tables of every schema in the connected database;
+// tables outside dbo keep their schema in the name.
+func (sqlServerDialect) TablesQuery() string {
+	return `
+		SELECT CASE WHEN TABLE_SCHEMA = 'dbo' THEN TABLE_NAME ELSE TABLE_SCHEMA + '.' + TABLE_NAME END AS table_name
+		FROM INFORMATION_SCHEMA.TABLES
+		WHERE TABLE_CATALOG = ? AND TABLE_TYPE = 'BASE TABLE'
+		ORDER BY TABLE_SCHEMA, TABLE_NAME
+	`
+}
+
+func (sqlServerDialect) FieldsQuery() string {
+	return `
+		SELECT
+			c.COLUMN_NAME AS name,
+			c.DATA_TYPE AS type,
+			CAST(CASE WHEN c.IS_NULLABLE = 'YES' THEN 1 ELSE 0 END AS BIT) AS nullable,
+			COALESCE(CAST(p.value AS NVARCHAR(4000)), '') AS comment
+		FROM INFORMATION_SCHEMA.COLUMNS c
+		LEFT JOIN sys.extended_properties p
+			ON p.major_id = OBJECT_ID(QUOTENAME(c.TABLE_SCHEMA) + '.' + QUOTENAME(c.TABLE_NAME))
+			AND p.minor_id = COLUMNPROPERTY(p.major_id, c.COLUMN_NAME, 'ColumnId')
+			AND p.name = 'MS_Description'
+		WHERE c.TABLE_CATALOG = ?
+			AND (CASE WHEN c.TABLE_SCHEMA = 'dbo' THEN c.TABLE_NAME ELSE c.TABLE_SCHEMA + '.' + c.TABLE_NAME END) = ?
+		ORDER BY c.ORDINAL_POSITION
+	`
+}
+
+func limitOffset(query string, limit, offset int) string {
+	return fmt.Sprintf("%s LIMIT %d OFFSET %d", query, limit, offset)
+}
+
+var (
+	selectPrefix  = regexp.MustCompile(`(?is)^\s*SELECT(\s+DISTINCT)?\s`)
+	orderByClause = regexp.MustCompile(`(?i)\bORDER\s+BY\b`)
+)
+
+// selectTop puts TOP right after the query's SELECT [DISTINCT], so an
+// ORDER BY still applies; other statements are wrapped.
+func selectTop(query string, limit int) string {
+	if loc := selectPrefix.FindStringIndex(query); loc != nil {
+		return fmt.Sprintf("%sTOP (%d) %s", query[:loc[1]], limit, query[loc[1]:])
+	}
+	return fmt.Sprintf("SELECT TOP (%d) * FROM (%s) AS limited_query", limit, query)
+}
+
+// hasOrderBy reports whether query ends in its own ORDER BY, as opposed to
+// one in a subquery, string or quoted name.
+func hasOrderBy(query string) bool {
+	var outer strings.Builder
+	depth := 0
+	var quote byte
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+			c = ' '
+		case c == '\'' || c == '"':
+			quote = c
+			c = ' '
+		case c == '[':
+			quote = ']'
+			c = ' '
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+			c = ' '
+		}
+		if depth > 0 {
+			c = ' '
+		}
+		outer.WriteByte(c)
+	}
+	return orderByClause.MatchString(outer.String())
+}
@@ -0,0 +1,457 @@
+package datasource
+
+import (
+	"fmt"
+	"strings"
+)
+
+// functionRenderer renders one computed-field function call. Arguments
+// arrive already rendered for the same dialect.
+type functionRenderer func(args []string) (string, error)
+
+func renderFunction(dialect string, functions map[string]functionRenderer, name string, args []string) (string, error) {
+	render, ok := functions[strings.ToUpper(name)]
+	if !ok {
+		return "", fmt.Errorf("function %s is not supported by %s", strings.ToUpper(name), dialect)
+	}
+	return render(args)
+}
+
+// genericFunctions are rendered the same way by every engine; dialects copy
+// this table and override the entries whose syntax differs.
+func genericFunctions() map[string]functionRenderer {
+	return map[string]functionRenderer{
+		"CONCAT":    call("CONCAT", 1, -1),
+		"SUBSTRING": call("SUBSTRING", 2, 3),
+		"LENGTH":    call("LENGTH", 1, 1),
+		"UPPER":     call("UPPER", 1, 1),
+		"LOWER":     call("LOWER", 1, 1),
+		"TRIM":      call("TRIM", 1, 1),
+		"ROUND":     call("ROUND", 1, 2),
+		"CEIL":      call("CEIL", 1, 1),
+		"FLOOR":     call("FLOOR", 1, 1),
+		"ABS":       call("ABS", 1, 1),
+		"SUM":       call("SUM", 1, 1),
+		"AVG":       call("AVG", 1, 1),
+		"COUNT":     call("COUNT", 1, 1),
+		"MAX":       call("MAX", 1, 1),
+		"MIN":       call("MIN", 1, 1),
+		"COALESCE":  call("COALESCE", 1, -1),
+		"IF":        caseWhen,
+	}
+}
+
+var mysqlFunctions = func() map[string]functionRenderer {
+	functions := genericFunctions()
+	functions["IF"] = call("IF", 3, 3)
+	functions["DATE_FORMAT"] = func(args []string) (string, error) {
+		if err := arity("DATE_FORMAT", args, 2, 2); err != nil {
+			return "", err
+		}
+		if _, err := parseQuotedDateFormat(args[1]); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("DATE_FORMAT(%s, %s)", args[0], args[1]), nil
+	}
+	functions["DATE_ADD"] = dateArithmetic("DATE_ADD", func(date, amount, unit string) string {
+		return fmt.Sprintf("DATE_ADD(%s, INTERVAL (%s) %s)", date, amount, strings.ToUpper(unit))
+	})
+	functions["DATE_SUB"] = dateArithmetic("DATE_SUB", func(date, amount, unit string) string {
+		return fmt.Sprintf("DATE_SUB(%s, INTERVAL (%s) %s)", date, amount, strings.ToUpper(unit))
+	})
+	functions["DATEDIFF"] = call("DATEDIFF", 2, 2)
+	functions["NOW"] = call("NOW", 0, 0)
+	functions["CURDATE"] = call("CURDATE", 0, 0)
+	functions["CURTIME"] = call("CURTIME", 0, 0)
+	for _, part := range []string{"YEAR", "MONTH", "DAY", "HOUR", "MINUTE", "SECOND"} {
+		functions[part] = call(part, 1, 1)
+	}
+	return functions
+}()
+
+var postgresFunctions = func() map[string]functionRenderer {
+	functions := genericFunctions()
+	functions["SUBSTRING"] = call("SUBSTR", 2, 3)
+	functions["DATE_FORMAT"] = dateFormat(postgresDateFormat, func(date, format string) string {
+		return fmt.Sprintf("TO_CHAR(%s, %s)", date, format)
+	})
+	functions["DATE_ADD"] = dateArithmetic("DATE_ADD", func(date, amount, unit string) string {
+		return fmt.Sprintf("(%s + (%s) * INTERVAL '1 %s')", date, amount, unit)
+	})
+	functions["DATE_SUB"] = dateArithmetic("DATE_SUB", func(date, amount, unit string) string {
+		return fmt.Sprintf("(%s - (%s) * INTERVAL '1 %s')", date, amount, unit)
+	})
+	functions["DATEDIFF"] = fixed("DATEDIFF", 2, func(args []string) string {
+		return fmt.Sprintf("(CAST(%s AS DATE) - CAST(%s AS DATE))", args[0], args[1])
+	})
+	functions["NOW"] = constant("NOW", "NOW()")
+	functions["CURDATE"] = constant("CURDATE", "CURRENT_DATE")
+	functions["CURTIME"] = constant("CURTIME", "CURRENT_TIME")
+	for _, part := range []string{"YEAR", "MONTH", "DAY", "HOUR", "MINUTE", "SECOND"} {
+		part := part
+		functions[part] = fixed(part, 1, func(args []string) string {
+			return fmt.Sprintf("CAST(EXTRACT(%s FROM %s) AS INTEGER)", part, args[0])
+		})
+	}
+	return functions
+}()
+
+var sqliteFunctions = func() map[string]functionRenderer {
+	functions := genericFunctions()
+	functions["CONCAT"] = func(args []string) (string, error) {
+		if err := arity("CONCAT", args, 1, -1); err != nil {
+			return "", err
+		}
+		return "(" + strings.Join(args, " || ") + ")", nil
+	}
+	functions["SUBSTRING"] = call("SUBSTR", 2, 3)
+	functions["CEIL"] = fixed("CEIL", 1, func(args []string) string {
+		return fmt.Sprintf("(CAST(%[1]s AS INTEGER) + ((%[1]s) > CAST(%[1]s AS INTEGER)))", args[0])
+	})
+	functions["FLOOR"] = fixed("FLOOR", 1, func(args []string) string {
+		return fmt.Sprintf("(CAST(%[1]s AS INTEGER) - ((%[1]s) < CAST(%[1]s AS INTEGER)))", args[0])
+	})
+	functions["DATE_FORMAT"] = dateFormat(sqliteDateFormat, func(date, format string) string {
+		return fmt.Sprintf("STRFTIME(%s, %s)", format, date)
+	})
+	functions["DATE_ADD"] = dateArithmetic("DATE_ADD", func(date, amount, unit string) string {
+		return fmt.Sprintf("DATETIME(%s, '+' || (%s) || ' %ss')", date, amount, unit)
+	})
+	functions["DATE_SUB"] = dateArithmetic("DATE_SUB", func(date, amount, unit string) string {
+		return fmt.Sprintf("DATETIME(%s, '-' || (%s) || ' %ss')", date, amount, unit)
+	})
+	functions["DATEDIFF"] = fixed("DATEDIFF", 2, func(args []string) string {
+		return fmt.Sprintf("CAST(JULIANDAY(DATE(%s)) - JULIANDAY(DATE(%s)) AS INTEGER)", args[0], args[1])
+	})
+	functions["NOW"] = constant("NOW", "DATETIME('now')")
+	functions["CURDATE"] = constant("CURDATE", "DATE('now')")
+	functions["CURTIME"] = constant("CURTIME", "TIME('now')")
+	for part, format := range map[string]string{"YEAR": "%Y", "MONTH": "%m", "DAY": "%d", "HOUR": "%H", "MINUTE": "%M", "SECOND": "%S"} {
+		part, format := part, format
+		functions[part] = fixed(part, 1, func(args []string) string {
+			return fmt.Sprintf("CAST(STRFTIME('%s', %s) AS INTEGER)", format, args[0])
+		})
+	}
+	return functions
+}()
+
+var clickHouseFunctions = func() map[string]functionRenderer {
+	functions := genericFunctions()
+	functions["LENGTH"] = call("lengthUTF8", 1, 1)
+	functions["UPPER"] = call("upperUTF8", 1, 1)
+	functions["LOWER"] = call("lowerUTF8", 1, 1)
+	functions["TRIM"] = call("trimBoth", 1, 1)
+	functions["SUBSTRING"] = call("substringUTF8", 2, 3)
+	functions["IF"] = call("if", 3, 3)
+	functions["DATE_FORMAT"] = dateFormat(clickHouseDateFormat, func(date, format string) string {
+		return fmt.Sprintf("formatDateTime(%s, %s)", date, format)
+	})
+	functions["DATE_ADD"] = dateArithmetic("DATE_ADD", func(date, amount, unit string) string {
+		return fmt.Sprintf("add%ss(%s, %s)", titleUnit(unit), date, amount)
+	})
+	functions["DATE_SUB"] = dateArithmetic("DATE_SUB", func(date, amount, unit string) string {
+		return fmt.Sprintf("subtract%ss(%s, %s)", titleUnit(unit), date, amount)
+	})
+	functions["DATEDIFF"] = fixed("DATEDIFF", 2, func(args []string) string {
+		return fmt.Sprintf("dateDiff('day', toDate(%s), toDate(%s))", args[1], args[0])
+	})
+	functions["NOW"] = constant("NOW", "now()")
+	functions["CURDATE"] = constant("CURDATE", "today()")
+	functions["CURTIME"] = constant("CURTIME", "formatDateTime(now(), '%H:%i:%S')")
+	for part, fn := range map[string]string{"YEAR": "toYear", "MONTH": "toMonth", "DAY": "toDayOfMonth", "HOUR": "toHour", "MINUTE": "toMinute", "SECOND": "toSecond"} {
+		functions[part] = call(fn, 1, 1)
+	}
+	return functions
+}()
+
+var sqlServerFunctions = func() map[string]functionRenderer {
+	functions := genericFunctions()
+	functions["LENGTH"] = call("LEN", 1, 1)
+	functions["TRIM"] = fixed("TRIM", 1, func(args []string) string {
+		return fmt.Sprintf("LTRIM(RTRIM(%s))", args[0])
+	})
+	functions["SUBSTRING"] = func(args []string) (string, error) {
+		if err := arity("SUBSTRING", args, 2, 3); err != nil {
+			return "", err
+		}
+		if len(args) == 2 {
+			return fmt.Sprintf("SUBSTRING(%s, %s, LEN(%s))", args[0], args[1], args[0]), nil
+		}
+		return fmt.Sprintf("SUBSTRING(%s, %s, %s)", args[0], args[1], args[2]), nil
+	}
+	functions["CEIL"] = call("CEILING", 1, 1)
+	functions["ROUND"] = func(args []string) (string, error) {
+		if err := arity("ROUND", args, 1, 2); err != nil {
+			return "", err
+		}
+		if len(args) == 1 {
+			return fmt.Sprintf("ROUND(%s, 0)", args[0]), nil
+		}
+		return fmt.Sprintf("ROUND(%s, %s)", args[0], args[1]), nil
+	}
+	functions["DATE_FORMAT"] = dateFormat(sqlServerDateFormat, func(date, format string) string {
+		return fmt.Sprintf("FORMAT(%s, %s)", date, format)
+	})
+	functions["DATE_ADD"] = dateArithmetic("DATE_ADD", func(date, amount, unit string) string {
+		return fmt.Sprintf("DATEADD(%s, %s, %s)", unit, amount, date)
+	})
+	functions["DATE_SUB"] = dateArithmetic("DATE_SUB", func(date, amount, unit string) string {
+		return fmt.Sprintf("DATEADD(%s, -(%s), %s)", unit, amount, date)
+	})
+	functions["DATEDIFF"] = fixed("DATEDIFF", 2, func(args []string) string {
+		return fmt.Sprintf("DATEDIFF(day, %s, %s)", args[1], args[0])
+	})
+	functions["NOW"] = constant("NOW", "GETDATE()")
+	functions["CURDATE"] = constant("CURDATE", "CAST(GETDATE() AS DATE)")
+	functions["CURTIME"] = constant("CURTIME", "CAST(GETDATE() AS TIME)")
+	for _, part := range []string{"YEAR", "MONTH", "DAY", "HOUR", "MINUTE", "SECOND"} {
+		part := part
+		functions[part] = fixed(part, 1, func(args []string) string {
+			return fmt.Sprintf("DATEPART(%s, %s)", strings.ToLower(part), args[0])
+		})
+	}
+	return functions
+}()
+
+func arity(name string, args []string, minArgs, maxArgs int) error {
+	if len(args) < minArgs || (maxArgs >= 0 && len(args) > maxArgs) {
+		switch {
+		case minArgs == maxArgs:
+			return fmt.Errorf("%s expects %d argument(s), got %d", name, minArgs, len(args))
+		case maxArgs < 0:
+			return fmt.Errorf("%s expects at least %d argument(s), got %d", name, minArgs, len(args))
+		default:
+			return fmt.Errorf("%s expects %d to %d arguments, got %d", name, minArgs, maxArgs, len(args))
+		}
+	}
+	return nil
+}
+
+// call renders a plain function call, optionally under a different name.
+func call(target string, minArgs, maxArgs int) functionRenderer {
+	return func(args []string) (string, error) {
+		if err := arity(target, args, minArgs, maxArgs); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s(%s)", target, strings.Join(args, ", ")), nil
+	}
+}
+
+func fixed(name string, argCount int, render func(args []string) string) functionRenderer {
+	return func(args []string) (string, error) {
+		if err := arity(name, args, argCount, argCount); err != nil {
+			return "", err
+		}
+		return render(args), nil
+	}
+}
+
+func constant(name, sql string) functionRenderer {
+	return fixed(name, 0, func([]string) string { return sql })
+}
+
+func caseWhen(args []string) (string, error) {
+	if err := arity("IF", args, 3, 3); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("CASE WHEN %s THEN %s ELSE %s END", args[0], args[1], args[2]), nil
+}
+
+var dateUnits = map[string]string{
+	"SECOND": "second",
+	"MINUTE": "minute",
+	"HOUR":   "hour",
+	"DAY":    "day",
+	"WEEK":   "week",
+	"MONTH":  "month",
+	"YEAR":   "year",
+}
+
+// DateUnit normalizes the unit of DATE_ADD and DATE_SUB, such as 'DAY', to
+// the lower-case name the dialects and the in-memory evaluator use.
+func DateUnit(unit string) (string, bool) {
+	normalized, ok := dateUnits[strings.ToUpper(strings.TrimSpace(unit))]
+	return normalized, ok
+}
+
+// dateArithmetic handles DATE_ADD(date, amount[, 'unit']); the unit defaults
+// to day and must be a string literal so it can be inlined per dialect.
+func dateArithmetic(name string, render func(date, amount, unit string) string) functionRenderer {
+	return func(args []string) (string, error) {
+		if err := arity(name, args, 2, 3); err != nil {
+			return "", err
+		}
+		unit := "day"
+		if len(args) == 3 {
+			literal, ok := unquoteSQLString(args[2])
+			if !ok {
+				return "", fmt.Errorf("%s unit must be a string literal", name)
+			}
+			normalized, ok := DateUnit(literal)
+			if !ok {
+				return "", fmt.Errorf("%s does not support unit %q", name, literal)
+			}
+			unit = normalized
+		}
+		return render(args[0], args[1], unit), nil
+	}
+}
+
+func titleUnit(unit string) string {
+	return strings.ToUpper(unit[:1]) + unit[1:]
+}
+
+func unquoteSQLString(value string) (string, bool) {
+	value = strings.TrimSpace(value)
+	if len(value) < 2 || value[0] != '\'' || value[len(value)-1] != '\'' {
+		return "", false
+	}
+	return strings.ReplaceAll(value[1:len(value)-1], "''", "'"), true
+}
+
+func quoteSQLString(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+// DateFormatPart is either a MySQL DATE_FORMAT specifier (without the leading
+// '%') or a run of literal text.
+type DateFormatPart struct {
+	Specifier byte
+	Literal   string
+}
+
+// ParseDateFormat splits a MySQL DATE_FORMAT pattern. DATE_FORMAT is the
+// canonical syntax for computed fields, so the other dialects and the
+// in-memory evaluator translate from it.
+func ParseDateFormat(pattern string) ([]DateFormatPart, error) {
+	var parts []DateFormatPart
+	var literal strings.Builder
+	flush := func() {
+		if literal.Len() > 0 {
+			parts = append(parts, DateFormatPart{Literal: literal.String()})
+			literal.Reset()
+		}
+	}
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] != '%' {
+			literal.WriteByte(pattern[i])
+			continue
+		}
+		if i+1 >= len(pattern) {
+			return nil, fmt.Errorf("DATE_FORMAT pattern ends with a dangling %%")
+		}
+		i++
+		spec := pattern[i]
+		if spec == '%' {
+			literal.WriteByte('%')
+			continue
+		}
+		if !strings.ContainsRune("YymdHhisSMbp", rune(spec)) {
+			return nil, fmt.Errorf("DATE_FORMAT specifier %%%c is not supported", spec)
+		}
+		flush()
+		parts = append(parts, DateFormatPart{Specifier: spec})
+	}
+	flush()
+	return parts, nil
+}
+
+func parseQuotedDateFormat(arg string) ([]DateFormatPart, error) {
+	pattern, ok := unquoteSQLString(arg)
+	if !ok {
+		return nil, fmt.Errorf("DATE_FORMAT pattern must be a string literal")
+	}
+	return ParseDateFormat(pattern)
+}
+
+type dateFormatConverter struct {
+	dialect    string
+	specifiers map[byte]string
+	literal    func(text string) string
+}
+
+func (c dateFormatConverter) convert(parts []DateFormatPart) (string, error) {
+	var out strings.Builder
+	for _, part := range parts {
+		if part.Specifier == 0 {
+			out.WriteString(c.literal(part.Literal))
+			continue
+		}
+		mapped, ok := c.specifiers[part.Specifier]
+		if !ok {
+			return "", fmt.Errorf("DATE_FORMAT specifier %%%c is not supported by %s", part.Specifier, c.dialect)
+		}
+		out.WriteString(mapped)
+	}
+	return out.String(), nil
+}
+
+// quoteLetters wraps literal text in double quotes when it contains anything
+// the target engine could mistake for a pattern character.
+func quoteLetters(text string) string {
+	if strings.Trim(text, "-/:., ") == "" {
+		return text
+	}
+	return `"` + strings.ReplaceAll(text, `"`, `\"`) + `"`
+}
+
+func escapePercent(text string) string {
+	return strings.ReplaceAll(text, "%", "%%")
+}
+
+var (
+	postgresDateFormat = dateFormatConverter{
+		dialect: "postgres",
+		specifiers: map[byte]string{
+			'Y': "YYYY", 'y': "YY", 'm': "MM", 'd': "DD", 'H': "HH24", 'h': "HH12",
+			'i': "MI", 's': "SS", 'S': "SS", 'M': "FMMonth", 'b': "Mon", 'p': "AM",
+		},
+		literal: quoteLetters,
+	}
+	sqliteDateFormat = dateFormatConverter{
+		dialect: "sqlite",
+		specifiers: map[byte]string{
+			'Y': "%Y", 'm': "%m", 'd': "%d", 'H': "%H", 'i': "%M", 's': "%S", 'S': "%S",
+		},
+		literal: escapePercent,
+	}
+	clickHouseDateFormat = dateFormatConverter{
+		dialect: "clickhouse",
+		specifiers: map[byte]string{
+			'Y': "%Y", 'y': "%y", 'm': "%m", 'd': "%d", 'H': "%H", 'h': "%I",
+			'i': "%i", 's': "%S", 'S': "%S", 'p': "%p",
+		},
+		literal: escapePercent,
+	}
+	sqlServerDateFormat = dateFormatConverter{
+		dialect: "sqlserver",
+		specifiers: map[byte]string{
+			'Y': "yyyy", 'y': "yy", 'm': "MM", 'd': "dd", 'H': "HH", 'h': "hh",
+			'i': "mm", 's': "ss", 'S': "ss", 'M': "MMMM", 'b': "MMM", 'p': "tt",
+		},
+		literal: func(text string) string {
+			// .NET treats '/' and ':' as culture-specific separators.
+			if strings.Trim(text, "-., ") == "" {
+				return text
+			}
+			return `"` + strings.ReplaceAll(text, `"`, `\"`) + `"`
+		},
+	}
+)
+
+func dateFormat(converter dateFormatConverter, render func(date, format string) string) functionRenderer {
+	return func(args []string) (string, error) {
+		if err := arity("DATE_FORMAT", args, 2, 2); err != nil {
+			return "", err
+		}
+		parts, err := parseQuotedDateFormat(args[1])
+		if err != nil {
+			return "", err
+		}
+		format, err := converter.convert(parts)
+		if err != nil {
+			return "", err
+		}
+		return render(args[0], quoteSQLString(format)), nil
+	}
+}
@@ -13,14 +13,15 @@ import (
 type Handler struct {
 	service  Service
 	metadata *CachedMetadataService
+	pools    *PoolManager
 }
 
 func NewHandler(service Service) *Handler {
-	return &Handler{service: service}
+	return &Handler{service: service, pools: Pools()}
 }
 
 func NewHandlerWithMetadata(service Service, metadata *CachedMetadataService) *Handler {
-	return &Handler{service: service, metadata: metadata}
+	return &Handler{service: service, metadata: metadata, pools: Pools()}
 }
 
 var connectionBuilder = NewConnectionBuilder()
@@ -44,8 +45,7 @@ func (h *Handler) Get(c *gin.Context) {
 		return
 	}
 
-	ds.Password = ""
-	c.JSON(http.StatusOK, gin.H{"success": true, "result": ds, "message": "success"})
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": NewResponse(ds), "message": "success"})
 }
 
 func (h *Handler) Create(c *gin.Context) {
@@ -73,7 +73,7 @@ func (h *Handler) Create(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"success": true, "result": ds, "message": "datasource created"})
+	c.JSON(http.StatusCreated, gin.H{"success": true, "result": NewResponse(ds), "message": "datasource created"})
 }
 
 func (h *Handler) List(c *gin.Context) {
@@ -98,7 +98,7 @@ func (h *Handler) List(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"result": gin.H{
-			"datasources": datasources,
+			"datasources": NewResponses(datasources),
 			"total":       total,
 			"page":        pageInt,
 			"pageSize":    pageSizeInt,
@@ -136,7 +136,7 @@ func (h *Handler) Update(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"success": true, "result": ds, "message": "datasource updated"})
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": NewResponse(ds), "message": "datasource updated"})
 }
 
 func (h *Handler) Delete(c *gin.Context) {
@@ -183,7 +183,7 @@ func (h *Handler) Search(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"result": gin.H{
-			"datasources": datasources,
+			"datasources": NewResponses(datasources),
 			"total":       total,
 			"page":        pageInt,
 			"pageSize":    pageSizeInt,
@@ -214,7 +214,7 @@ func (h *Handler) Copy(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"success": true, "result": ds, "message": "datasource copied"})
+	c.JSON(http.StatusCreated, gin.H{"success": true, "result": NewResponse(ds), "message": "datasource copied"})
 }
 
 func (h *Handler) Move(c *gin.Context) {
@@ -270,7 +270,7 @@ func (h *Handler) Rename(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"success": true, "result": ds, "message": "datasource renamed"})
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": NewResponse(ds), "message": "datasource renamed"})
 }
 
 func (h *Handler) GetTables(c *gin.Context) {
@@ -296,15 +296,12 @@ func (h *Handler) GetTables(c *gin.Context) {
 		return
 	}
 
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local",
-		ds.Username,
-		ds.Password,
-		ds.Host,
-		ds.Port,
-		ds.Database,
-	)
+	if _, err := GetDialect(ds.Type); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
 
-	tables, err := h.metadata.GetTables(c.Request.Context(), ds.TenantID, id, dsn)
+	tables, err := h.metadata.GetDatasourceTables(c.Request.Context(), h.pools, ds)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
 		return
@@ -338,15 +335,12 @@ func (h *Handler) GetFields(c *gin.Context) {
 		return
 	}
 
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local",
-		ds.Username,
-		ds.Password,
-		ds.Host,
-		ds.Port,
-		ds.Database,
-	)
+	if _, err := GetDialect(ds.Type); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
 
-	fields, err := h.metadata.GetFields(c.Request.Context(), ds.TenantID, id, dsn, tableName)
+	fields, err := h.metadata.GetDatasourceFields(c.Request.Context(), h.pools, ds, tableName)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
 		return
@@ -395,6 +389,25 @@ func (h *Handler) TestConnection(c *gin.Context) {
 		testDS.SSHPort = 0
 	}
 
+	// An edit form tests with SecretUnchanged in place of the stored secrets.
+	// They are only sent to the hosts they were saved for.
+	if id := c.Query("id"); id != "" {
+		stored, err := h.service.GetByID(c.Request.Context(), id)
+		if err != nil || stored.TenantID != tenantID {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "datasource not found"})
+			return
+		}
+		if sameEndpoint(stored, testDS) {
+			testDS.Password = resolveSecret(stored.Password, testDS.Password)
+			testDS.SSHPassword = resolveSecret(stored.SSHPassword, testDS.SSHPassword)
+			testDS.SSHKey = resolveSecret(stored.SSHKey, testDS.SSHKey)
+			testDS.SSHKeyPhrase = resolveSecret(stored.SSHKeyPhrase, testDS.SSHKeyPhrase)
+		} else if hasUnchangedSecret(testDS) {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "secrets must be re-entered when the host or port changes"})
+			return
+		}
+	}
+
 	if err := connectionBuilder.TestConnection(c.Request.Context(), testDS); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": fmt.Sprintf("connection test failed: %v", err)})
 		return
@@ -403,6 +416,22 @@ func (h *Handler) TestConnection(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"success": true, "message": "connection successful"})
 }
 
+// sameEndpoint reports whether ds connects to the database and SSH hosts the
+// stored datasource was saved with.
+func sameEndpoint(stored, ds *models.DataSource) bool {
+	return stored.Host == ds.Host && stored.Port == ds.Port &&
+		stored.SSHHost == ds.SSHHost && stored.SSHPort == ds.SSHPort
+}
+
+func hasUnchangedSecret(ds *models.DataSource) bool {
+	for _, secret := range []string{ds.Password, ds.SSHPassword, ds.SSHKey, ds.SSHKeyPhrase} {
+		if secret == SecretUnchanged {
+			return true
+		}
+	}
+	return false
+}
+
 func (h *Handler) TestSavedConnection(c *gin.Context) {
 	id := c.Param("id")
 	if id == "" {
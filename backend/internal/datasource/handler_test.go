@@ -2,6 +2,7 @@ package datasource
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
@@ -15,6 +16,7 @@ import (
 	"github.com/gujiaweiguo/goreport/internal/config"
 	"github.com/gujiaweiguo/goreport/internal/models"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type mockService struct {
@@ -137,6 +139,43 @@ func TestDatasourceHandler_Get_Success(t *testing.T) {
 	assert.Contains(t, w.Body.String(), `"success":true`)
 }
 
+func TestDatasourceHandler_Get_RedactsSecrets(t *testing.T) {
+	svc := &mockService{
+		datasource: &models.DataSource{
+			ID:           "ds-1",
+			TenantID:     "tenant-1",
+			Password:     "db-secret",
+			SSHKey:       "key-material",
+			SSHKeyPhrase: "phrase",
+			Config:       `{"url":"https://api.example.com","token":"api-token"}`,
+		},
+	}
+	handler, router := setupHandlerTest(t, svc)
+
+	router.GET("/:id", func(c *gin.Context) {
+		c.Set("tenantId", "tenant-1")
+		handler.Get(c)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/ds-1", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	for _, secret := range []string{"db-secret", "key-material", "phrase", "api-token"} {
+		assert.NotContains(t, w.Body.String(), secret)
+	}
+
+	var resp struct {
+		Result map[string]interface{} `json:"result"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, SecretUnchanged, resp.Result["password"])
+	assert.Equal(t, SecretUnchanged, resp.Result["sshKey"])
+	assert.NotContains(t, resp.Result, "sshPassword")
+	assert.JSONEq(t, `{"url":"https://api.example.com","token":"`+SecretUnchanged+`"}`, resp.Result["config"].(string))
+}
+
 func TestDatasourceHandler_Get_NoID(t *testing.T) {
 	svc := &mockService{}
 	handler, router := setupHandlerTest(t, svc)
@@ -1079,6 +1118,47 @@ func TestDatasourceHandler_TestConnection_Failure(t *testing.T) {
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 }
 
+func TestDatasourceHandler_TestConnection_StoredSecrets(t *testing.T) {
+	svc := &mockService{
+		datasource: &models.DataSource{
+			ID:       "ds-1",
+			TenantID: "tenant-1",
+			Type:     "mysql",
+			Host:     "127.0.0.1",
+			Port:     1,
+			Password: "stored-secret",
+		},
+	}
+	handler, router := setupHandlerTest(t, svc)
+
+	router.POST("/test", func(c *gin.Context) {
+		c.Set("tenantId", "tenant-1")
+		handler.TestConnection(c)
+	})
+
+	send := func(host string, port int) *httptest.ResponseRecorder {
+		body := fmt.Sprintf(`{"name":"Test","type":"mysql","host":%q,"port":%d,"database":"goreport","username":"root","password":%q}`, host, port, SecretUnchanged)
+		req := httptest.NewRequest(http.MethodPost, "/test?id=ds-1", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// The stored password is only used against the saved host and port.
+	w := send("attacker.example.com", 1)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "secrets must be re-entered")
+
+	w = send("127.0.0.1", 2)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "secrets must be re-entered")
+
+	w = send("127.0.0.1", 1)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "connection test failed")
+}
+
 func TestDatasourceHandler_TestConnection_Success(t *testing.T) {
 	dsn := os.Getenv("TEST_DB_DSN")
 	if dsn == "" {
@@ -15,15 +15,12 @@ type FieldInfo struct {
 }
 
 func GetTables(ctx context.Context, db *gorm.DB, database string) ([]string, error) {
-	query := `
-		SELECT table_name
-		FROM information_schema.tables
-		WHERE table_schema = ? AND table_type = 'BASE TABLE'
-		ORDER BY table_name
-	`
+	return GetTablesWithDialect(ctx, db, DefaultDialect(), database)
+}
 
+func GetTablesWithDialect(ctx context.Context, db *gorm.DB, dialect Dialect, database string) ([]string, error) {
 	var tables []string
-	if err := db.WithContext(ctx).Raw(query, database).Scan(&tables).Error; err != nil {
+	if err := db.WithContext(ctx).Raw(dialect.TablesQuery(), database).Scan(&tables).Error; err != nil {
 		return nil, err
 	}
 
@@ -31,19 +28,12 @@ func GetTables(ctx context.Context, db *gorm.DB, database string) ([]string, err
 }
 
 func GetFields(ctx context.Context, db *gorm.DB, database, tableName string) ([]FieldInfo, error) {
-	query := `
-		SELECT
-			column_name AS name,
-			data_type AS type,
-			(is_nullable = 'YES') AS nullable,
-			column_comment AS comment
-		FROM information_schema.columns
-		WHERE table_schema = ? AND table_name = ?
-		ORDER BY ordinal_position
-	`
+	return GetFieldsWithDialect(ctx, db, DefaultDialect(), database, tableName)
+}
 
+func GetFieldsWithDialect(ctx context.Context, db *gorm.DB, dialect Dialect, database, tableName string) ([]FieldInfo, error) {
 	var fields []FieldInfo
-	if err := db.WithContext(ctx).Raw(query, database, tableName).Scan(&fields).Error; err != nil {
+	if err := db.WithContext(ctx).Raw(dialect.FieldsQuery(), database, tableName).Scan(&fields).Error; err != nil {
 		return nil, fmt.Errorf("failed to query fields: %w", err)
 	}
 
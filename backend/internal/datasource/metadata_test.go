@@ -2,7 +2,9 @@ package datasource
 
 import (
 	"context"
+	"database/sql"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -10,6 +12,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
 )
 
 func getTestDSNForMetadata() string {
@@ -115,3 +118,38 @@ func TestGetFields_NonExistentTable(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Empty(t, fields)
 }
+
+func TestMetadata_SQLite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sales.db")
+	setup, err := sql.Open("sqlite", path)
+	require.NoError(t, err)
+	_, err = setup.Exec(`CREATE TABLE orders (id INTEGER NOT NULL, region TEXT)`)
+	require.NoError(t, err)
+	_, err = setup.Exec(`INSERT INTO orders VALUES (1, 'east'), (2, 'west'), (3, 'north')`)
+	require.NoError(t, err)
+	require.NoError(t, setup.Close())
+
+	dialect, err := GetDialect("sqlite")
+	require.NoError(t, err)
+	db, err := gorm.Open(dialect.GormDialector(dialect.BuildDSN("", 0, path, "", "")), &gorm.Config{Logger: logger.Discard})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	tables, err := GetTablesWithDialect(ctx, db, dialect, path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"orders"}, tables)
+
+	fields, err := GetFieldsWithDialect(ctx, db, dialect, path, "orders")
+	require.NoError(t, err)
+	assert.Equal(t, []FieldInfo{
+		{Name: "id", Type: "INTEGER", Nullable: false},
+		{Name: "region", Type: "TEXT", Nullable: true},
+	}, fields)
+
+	var regions []string
+	query := dialect.Limit("SELECT region FROM orders ORDER BY id", 1, 1)
+	require.NoError(t, db.WithContext(ctx).Raw(query).Scan(&regions).Error)
+	assert.Equal(t, []string{"west"}, regions)
+
+	assert.Error(t, db.Exec(`INSERT INTO orders VALUES (4, 'south')`).Error, "datasources open read-only")
+}
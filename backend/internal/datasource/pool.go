@@ -0,0 +1,369 @@
+package datasource
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/gujiaweiguo/goreport/internal/config"
+	"github.com/gujiaweiguo/goreport/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const defaultPoolIdleTimeout = 10 * time.Minute
+
+// DefaultQueryTimeout bounds queries against a datasource that sets no
+// QueryTimeoutSeconds, where the caller has no tighter fallback of its own.
+const DefaultQueryTimeout = 30 * time.Second
+
+var errPoolManagerClosed = errors.New("datasource pool manager closed")
+
+var (
+	poolsMu sync.Mutex
+	pools   *PoolManager
+)
+
+// InitPools replaces the shared pool manager, closing any pools held by the
+// previous one.
+func InitPools(cfg *config.DatasourceConfig) {
+	idleTimeout := time.Duration(cfg.PoolIdleTimeout) * time.Second
+	if idleTimeout <= 0 {
+		idleTimeout = defaultPoolIdleTimeout
+	}
+
+	poolsMu.Lock()
+	previous := pools
+	pools = NewPoolManager(idleTimeout)
+	poolsMu.Unlock()
+
+	if previous != nil {
+		previous.Close()
+	}
+}
+
+// Pools returns the shared pool manager, creating one with default settings
+// if InitPools has not been called.
+func Pools() *PoolManager {
+	poolsMu.Lock()
+	defer poolsMu.Unlock()
+	if pools == nil {
+		pools = NewPoolManager(defaultPoolIdleTimeout)
+	}
+	return pools
+}
+
+// PoolManager keeps one connection pool per datasource and tenant. A pool is
+// rebuilt when the datasource's connection settings change, and closed once
+// it has been idle for longer than the idle timeout.
+type PoolManager struct {
+	mu          sync.Mutex
+	pools       map[string]*Pool
+	idleTimeout time.Duration
+	builder     *ConnectionBuilder
+	now         func() time.Time
+	stop        chan struct{}
+	closeOnce   sync.Once
+	closed      bool
+}
+
+// Pool is a shared connection pool. Callers must Release it when done and
+// must not close DB themselves.
+type Pool struct {
+	DB      *sql.DB
+	Dialect Dialect
+
+	manager      *PoolManager
+	key          string
+	datasourceID string
+	version      string
+	queryTimeout time.Duration
+	tunnel       *SSHTunnel
+
+	ready chan struct{}
+	err   error
+
+	gormOnce sync.Once
+	gormDB   *gorm.DB
+	gormErr  error
+
+	// Guarded by manager.mu.
+	refs     int
+	lastUsed time.Time
+	retired  bool
+}
+
+func NewPoolManager(idleTimeout time.Duration) *PoolManager {
+	m := &PoolManager{
+		pools:       make(map[string]*Pool),
+		idleTimeout: idleTimeout,
+		builder:     NewConnectionBuilder(),
+		now:         time.Now,
+		stop:        make(chan struct{}),
+	}
+	go m.evictLoop()
+	return m
+}
+
+// Acquire returns the pool for ds, opening it on first use. Concurrent
+// callers for the same datasource share a single open.
+func (m *PoolManager) Acquire(ctx context.Context, ds *models.DataSource) (*Pool, error) {
+	key := poolKey(ds.TenantID, ds.ID)
+	version := poolVersion(ds)
+
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil, errPoolManagerClosed
+	}
+	pool, ok := m.pools[key]
+	if ok && pool.version != version {
+		m.retireLocked(pool)
+		ok = false
+	}
+	if !ok {
+		pool = &Pool{
+			manager:      m,
+			key:          key,
+			datasourceID: ds.ID,
+			version:      version,
+			ready:        make(chan struct{}),
+		}
+		m.pools[key] = pool
+		go m.open(ctx, pool, ds)
+	}
+	pool.refs++
+	m.mu.Unlock()
+
+	select {
+	case <-pool.ready:
+	case <-ctx.Done():
+		pool.Release()
+		return nil, ctx.Err()
+	}
+
+	if pool.err != nil {
+		m.mu.Lock()
+		if m.pools[key] == pool {
+			delete(m.pools, key)
+		}
+		m.mu.Unlock()
+		pool.Release()
+		return nil, pool.err
+	}
+	return pool, nil
+}
+
+// open connects the pool. It runs detached from the caller so an SSH tunnel
+// outlives the request that first needed it.
+func (m *PoolManager) open(ctx context.Context, pool *Pool, ds *models.DataSource) {
+	defer close(pool.ready)
+
+	db, dialect, tunnel, err := m.builder.Open(context.WithoutCancel(ctx), ds)
+	if err != nil {
+		pool.err = err
+		return
+	}
+	db.SetConnMaxIdleTime(m.idleTimeout)
+
+	pool.DB = db
+	pool.Dialect = dialect
+	pool.tunnel = tunnel
+	pool.queryTimeout = QueryTimeout(ds, 0)
+
+	if tunnel != nil {
+		go m.watchTunnel(pool)
+	}
+}
+
+// watchTunnel drops the pool once its SSH tunnel is lost, so the next
+// Acquire opens a new tunnel instead of dialing a dead one.
+func (m *PoolManager) watchTunnel(pool *Pool) {
+	<-pool.tunnel.Done()
+	m.mu.Lock()
+	m.retireLocked(pool)
+	m.mu.Unlock()
+}
+
+// Release returns the pool to the manager. A pool that was replaced while in
+// use is closed by its last user.
+func (p *Pool) Release() {
+	m := p.manager
+	m.mu.Lock()
+	p.refs--
+	p.lastUsed = m.now()
+	closeNow := p.retired && p.refs == 0
+	m.mu.Unlock()
+
+	if closeNow {
+		p.close()
+	}
+}
+
+// Check returns err unchanged. When err shows the datasource cannot be
+// reached (a broken connection or a failed dial), the pool is dropped so the
+// next Acquire rebuilds it rather than reusing a dead pool.
+func (p *Pool) Check(err error) error {
+	if isConnectionFailure(err) {
+		m := p.manager
+		m.mu.Lock()
+		m.retireLocked(p)
+		m.mu.Unlock()
+	}
+	return err
+}
+
+func isConnectionFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// WithTimeout bounds ctx by the datasource's QueryTimeoutSeconds, or by
+// fallback when the datasource sets none.
+func (p *Pool) WithTimeout(ctx context.Context, fallback time.Duration) (context.Context, context.CancelFunc) {
+	timeout := p.queryTimeout
+	if timeout <= 0 {
+		timeout = fallback
+	}
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// Gorm returns a gorm handle sharing the pool's connections.
+func (p *Pool) Gorm() (*gorm.DB, error) {
+	p.gormOnce.Do(func() {
+		p.gormDB, p.gormErr = gorm.Open(p.Dialect.GormConnDialector(p.DB), &gorm.Config{
+			Logger: logger.Default.LogMode(logger.Silent),
+		})
+	})
+	return p.gormDB, p.gormErr
+}
+
+func (p *Pool) close() {
+	<-p.ready
+	if p.DB != nil {
+		p.DB.Close()
+	}
+	if p.tunnel != nil {
+		p.tunnel.Close()
+	}
+}
+
+// Invalidate drops every pool of a datasource, e.g. after it is updated or
+// deleted. Pools still in use are closed when released.
+func (m *PoolManager) Invalidate(datasourceID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, pool := range m.pools {
+		if pool.datasourceID == datasourceID {
+			m.retireLocked(pool)
+		}
+	}
+}
+
+// retireLocked removes pool from the map. An unused pool is closed right
+// away; one still in use is closed by its last Release.
+func (m *PoolManager) retireLocked(pool *Pool) {
+	if m.pools[pool.key] == pool {
+		delete(m.pools, pool.key)
+	}
+	if pool.retired {
+		return
+	}
+	pool.retired = true
+	if pool.refs == 0 {
+		// close waits for a pending open, so it must not hold the lock.
+		go pool.close()
+	}
+}
+
+// evictIdle closes pools unused for longer than the idle timeout.
+func (m *PoolManager) evictIdle() {
+	now := m.now()
+	m.mu.Lock()
+	for _, pool := range m.pools {
+		if pool.refs == 0 && now.Sub(pool.lastUsed) > m.idleTimeout {
+			m.retireLocked(pool)
+		}
+	}
+	m.mu.Unlock()
+}
+
+func (m *PoolManager) evictLoop() {
+	interval := m.idleTimeout / 2
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			m.evictIdle()
+		}
+	}
+}
+
+// Close stops idle eviction and closes every pool.
+func (m *PoolManager) Close() {
+	m.closeOnce.Do(func() {
+		close(m.stop)
+		m.mu.Lock()
+		m.closed = true
+		for _, pool := range m.pools {
+			m.retireLocked(pool)
+		}
+		m.mu.Unlock()
+	})
+}
+
+// QueryTimeout returns the datasource's QueryTimeoutSeconds, or fallback when
+// ds is nil or sets none.
+func QueryTimeout(ds *models.DataSource, fallback time.Duration) time.Duration {
+	if ds != nil && ds.QueryTimeoutSeconds > 0 {
+		return time.Duration(ds.QueryTimeoutSeconds) * time.Second
+	}
+	return fallback
+}
+
+// WithQueryTimeout bounds ctx by QueryTimeout(ds, fallback).
+func WithQueryTimeout(ctx context.Context, ds *models.DataSource, fallback time.Duration) (context.Context, context.CancelFunc) {
+	timeout := QueryTimeout(ds, fallback)
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+func poolKey(tenantID, datasourceID string) string {
+	return tenantID + "/" + datasourceID
+}
+
+// poolVersion fingerprints the settings a pool is built from, so any change
+// to them yields a fresh pool.
+func poolVersion(ds *models.DataSource) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%s\x00%d\x00%s\x00%s\x00%s\x00%s\x00%d\x00%s\x00%s\x00%s\x00%s\x00%d\x00%d",
+		ds.Type, ds.Host, ds.Port, ds.Database, ds.Username, ds.Password,
+		ds.SSHHost, ds.SSHPort, ds.SSHUsername, ds.SSHPassword, ds.SSHKey, ds.SSHKeyPhrase,
+		ds.MaxConnections, ds.QueryTimeoutSeconds)))
+	return hex.EncodeToString(sum[:8])
+}
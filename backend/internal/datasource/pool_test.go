@@ -0,0 +1,234 @@
+package datasource
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gujiaweiguo/goreport/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func poolTestDatasource() *models.DataSource {
+	return &models.DataSource{
+		ID:                  "ds-1",
+		TenantID:            "tenant-1",
+		Type:                "mysql",
+		Host:                "db.invalid",
+		Port:                3306,
+		Database:            "sales",
+		Username:            "reader",
+		Password:            "secret",
+		MaxConnections:      3,
+		QueryTimeoutSeconds: 7,
+	}
+}
+
+func newTestPoolManager(t *testing.T) *PoolManager {
+	t.Helper()
+	m := NewPoolManager(time.Minute)
+	t.Cleanup(m.Close)
+	return m
+}
+
+func TestPoolManager_AcquireSharesPool(t *testing.T) {
+	m := newTestPoolManager(t)
+	ds := poolTestDatasource()
+
+	first, err := m.Acquire(context.Background(), ds)
+	require.NoError(t, err)
+	second, err := m.Acquire(context.Background(), ds)
+	require.NoError(t, err)
+
+	assert.Same(t, first, second)
+	assert.Equal(t, 3, first.DB.Stats().MaxOpenConnections)
+	assert.Equal(t, "mysql", first.Dialect.Name())
+
+	ctx, cancel := first.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	deadline, ok := ctx.Deadline()
+	require.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(7*time.Second), deadline, time.Second)
+
+	other := poolTestDatasource()
+	other.TenantID = "tenant-2"
+	third, err := m.Acquire(context.Background(), other)
+	require.NoError(t, err)
+	assert.NotSame(t, first, third)
+
+	first.Release()
+	second.Release()
+	third.Release()
+	assert.Len(t, m.pools, 2)
+}
+
+func TestPoolManager_RebuildsOnConfigChange(t *testing.T) {
+	m := newTestPoolManager(t)
+	ds := poolTestDatasource()
+
+	old, err := m.Acquire(context.Background(), ds)
+	require.NoError(t, err)
+
+	ds.Password = "rotated"
+	current, err := m.Acquire(context.Background(), ds)
+	require.NoError(t, err)
+	defer current.Release()
+	assert.NotSame(t, old, current)
+
+	// The replaced pool stays usable until its last user releases it.
+	assert.NotEqual(t, "sql: database is closed", pingError(old))
+	old.Release()
+	assert.Equal(t, "sql: database is closed", pingError(old))
+}
+
+func TestPoolManager_Invalidate(t *testing.T) {
+	m := newTestPoolManager(t)
+
+	pool, err := m.Acquire(context.Background(), poolTestDatasource())
+	require.NoError(t, err)
+	pool.Release()
+
+	m.Invalidate("ds-1")
+	assert.Empty(t, m.pools)
+	assert.Eventually(t, func() bool { return pingError(pool) == "sql: database is closed" }, time.Second, 10*time.Millisecond)
+
+	next, err := m.Acquire(context.Background(), poolTestDatasource())
+	require.NoError(t, err)
+	defer next.Release()
+	assert.NotSame(t, pool, next)
+}
+
+func TestPoolManager_EvictIdle(t *testing.T) {
+	m := newTestPoolManager(t)
+	now := time.Now()
+	m.now = func() time.Time { return now }
+
+	idle, err := m.Acquire(context.Background(), poolTestDatasource())
+	require.NoError(t, err)
+	idle.Release()
+
+	busyDS := poolTestDatasource()
+	busyDS.ID = "ds-2"
+	busy, err := m.Acquire(context.Background(), busyDS)
+	require.NoError(t, err)
+	defer busy.Release()
+
+	now = now.Add(2 * time.Minute)
+	m.evictIdle()
+
+	assert.Len(t, m.pools, 1)
+	assert.Contains(t, m.pools, poolKey("tenant-1", "ds-2"))
+	assert.Eventually(t, func() bool { return pingError(idle) == "sql: database is closed" }, time.Second, 10*time.Millisecond)
+}
+
+func TestPoolManager_DropsUnreachablePool(t *testing.T) {
+	m := newTestPoolManager(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := listener.Addr().(*net.TCPAddr).Port
+	require.NoError(t, listener.Close())
+
+	ds := poolTestDatasource()
+	ds.Host = "127.0.0.1"
+	ds.Port = port
+	pool, err := m.Acquire(context.Background(), ds)
+	require.NoError(t, err)
+
+	// Query errors leave the pool in place.
+	assert.ErrorIs(t, pool.Check(context.DeadlineExceeded), context.DeadlineExceeded)
+	assert.Len(t, m.pools, 1)
+
+	pingErr := pool.DB.PingContext(context.Background())
+	require.Error(t, pingErr)
+	assert.Equal(t, pingErr, pool.Check(pingErr))
+	assert.Empty(t, m.pools)
+	pool.Release()
+	assert.Equal(t, "sql: database is closed", pingError(pool))
+
+	next, err := m.Acquire(context.Background(), ds)
+	require.NoError(t, err)
+	assert.NotSame(t, pool, next)
+	next.Check(fmt.Errorf("query: %w", driver.ErrBadConn))
+	assert.Empty(t, m.pools)
+	next.Release()
+}
+
+func TestPoolManager_DropsPoolWhenTunnelIsLost(t *testing.T) {
+	m := newTestPoolManager(t)
+
+	pool, err := m.Acquire(context.Background(), poolTestDatasource())
+	require.NoError(t, err)
+	defer pool.Release()
+
+	pool.tunnel = &SSHTunnel{done: make(chan struct{})}
+	go m.watchTunnel(pool)
+	require.NoError(t, pool.tunnel.Close())
+
+	assert.Eventually(t, func() bool {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		return len(m.pools) == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestPoolManager_Errors(t *testing.T) {
+	m := newTestPoolManager(t)
+
+	ds := poolTestDatasource()
+	ds.Type = "oracle"
+	_, err := m.Acquire(context.Background(), ds)
+	assert.ErrorContains(t, err, "unsupported sql dialect")
+	assert.Empty(t, m.pools)
+
+	// SSH datasources are opened through the tunnel, never directly.
+	ds = poolTestDatasource()
+	ds.SSHHost = "bastion.invalid"
+	ds.SSHPort = 22
+	ds.SSHUsername = "jump"
+	_, err = m.Acquire(context.Background(), ds)
+	assert.ErrorContains(t, err, "no SSH authentication method provided")
+	assert.Empty(t, m.pools)
+
+	m.Close()
+	_, err = m.Acquire(context.Background(), poolTestDatasource())
+	assert.ErrorIs(t, err, errPoolManagerClosed)
+}
+
+func TestQueryTimeout(t *testing.T) {
+	ds := poolTestDatasource()
+	assert.Equal(t, 7*time.Second, QueryTimeout(ds, time.Second))
+
+	ds.QueryTimeoutSeconds = 0
+	assert.Equal(t, time.Second, QueryTimeout(ds, time.Second))
+	assert.Equal(t, time.Second, QueryTimeout(nil, time.Second))
+
+	m := newTestPoolManager(t)
+	pool, err := m.Acquire(context.Background(), ds)
+	require.NoError(t, err)
+	defer pool.Release()
+
+	ctx, cancel := pool.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	deadline, ok := ctx.Deadline()
+	require.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(3*time.Second), deadline, time.Second)
+
+	ctx, cancel = WithQueryTimeout(context.Background(), nil, 0)
+	defer cancel()
+	_, ok = ctx.Deadline()
+	assert.False(t, ok)
+}
+
+func pingError(pool *Pool) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := pool.DB.PingContext(ctx); err != nil {
+		return err.Error()
+	}
+	return ""
+}
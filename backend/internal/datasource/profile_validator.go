@@ -28,6 +28,27 @@ var ConnectorProfiles = map[string]ConnectorProfile{
 		Optional:    []string{"username", "password"},
 		SupportsSSH: true,
 	},
+	"sqlite": {
+		Name:        "sqlite",
+		DisplayName: "SQLite",
+		Required:    []string{"database"},
+		Optional:    []string{},
+		SupportsSSH: false,
+	},
+	"clickhouse": {
+		Name:        "clickhouse",
+		DisplayName: "ClickHouse",
+		Required:    []string{"host", "port", "database"},
+		Optional:    []string{"username", "password"},
+		SupportsSSH: true,
+	},
+	"sqlserver": {
+		Name:        "sqlserver",
+		DisplayName: "SQL Server",
+		Required:    []string{"host", "port", "database"},
+		Optional:    []string{"username", "password"},
+		SupportsSSH: true,
+	},
 	"mongodb": {
 		Name:        "mongodb",
 		DisplayName: "MongoDB",
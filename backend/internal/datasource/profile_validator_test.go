@@ -245,6 +245,21 @@ func TestConnectorProfiles(t *testing.T) {
 		assert.True(t, profile.SupportsSSH)
 	})
 
+	t.Run("sqlite profile", func(t *testing.T) {
+		profile := ConnectorProfiles["sqlite"]
+		assert.Equal(t, "SQLite", profile.DisplayName)
+		assert.Equal(t, []string{"database"}, profile.Required)
+		assert.False(t, profile.SupportsSSH)
+	})
+
+	t.Run("server profiles", func(t *testing.T) {
+		for _, name := range []string{"clickhouse", "sqlserver"} {
+			profile := ConnectorProfiles[name]
+			assert.Equal(t, []string{"host", "port", "database"}, profile.Required, name)
+			assert.True(t, profile.SupportsSSH, name)
+		}
+	})
+
 	t.Run("api profile", func(t *testing.T) {
 		profile := ConnectorProfiles["api"]
 		assert.Equal(t, "API", profile.DisplayName)
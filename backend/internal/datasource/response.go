@@ -0,0 +1,80 @@
+package datasource
+
+import (
+	"encoding/json"
+
+	"github.com/gujiaweiguo/goreport/internal/models"
+	"github.com/gujiaweiguo/goreport/internal/repository"
+)
+
+// SecretUnchanged stands in for a stored secret in API output. Sending it
+// back on update keeps the stored value, so clients never round-trip secrets.
+const SecretUnchanged = "__unchanged__"
+
+// Response is the API view of a datasource. A secret that is set reads as
+// SecretUnchanged; one that is not set is omitted.
+type Response struct {
+	*models.DataSource
+	Password     string `json:"password,omitempty"`
+	SSHPassword  string `json:"sshPassword,omitempty"`
+	SSHKey       string `json:"sshKey,omitempty"`
+	SSHKeyPhrase string `json:"sshKeyPhrase,omitempty"`
+	Config       string `json:"config,omitempty"`
+}
+
+func NewResponse(ds *models.DataSource) *Response {
+	return &Response{
+		DataSource:   ds,
+		Password:     redactSecret(ds.Password),
+		SSHPassword:  redactSecret(ds.SSHPassword),
+		SSHKey:       redactSecret(ds.SSHKey),
+		SSHKeyPhrase: redactSecret(ds.SSHKeyPhrase),
+		Config:       redactConnectorConfig(ds.Config),
+	}
+}
+
+func NewResponses(datasources []*models.DataSource) []*Response {
+	responses := make([]*Response, 0, len(datasources))
+	for _, ds := range datasources {
+		responses = append(responses, NewResponse(ds))
+	}
+	return responses
+}
+
+func redactSecret(value string) string {
+	if value == "" {
+		return ""
+	}
+	return SecretUnchanged
+}
+
+// redactConnectorConfig masks the secret entries of a connector config. A
+// config that is not a JSON object cannot be inspected and is dropped.
+func redactConnectorConfig(config string) string {
+	if config == "" {
+		return ""
+	}
+	var values map[string]interface{}
+	if err := json.Unmarshal([]byte(config), &values); err != nil {
+		return ""
+	}
+	for _, key := range repository.ConnectorSecretKeys {
+		if value, ok := values[key]; ok && value != "" && value != nil {
+			values[key] = SecretUnchanged
+		}
+	}
+	encoded, err := json.Marshal(values)
+	if err != nil {
+		return ""
+	}
+	return string(encoded)
+}
+
+// resolveSecret returns the secret to store on update: an empty value or
+// SecretUnchanged keeps the current one.
+func resolveSecret(current, requested string) string {
+	if requested == "" || requested == SecretUnchanged {
+		return current
+	}
+	return requested
+}
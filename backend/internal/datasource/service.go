@@ -2,6 +2,7 @@ package datasource
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"time"
@@ -34,6 +35,9 @@ type CreateRequest struct {
 	CreatedBy string `json:"createdBy"`
 
 	Advanced *AdvancedConfig `json:"advanced,omitempty"`
+	// Config holds connector-specific settings such as the url and token of
+	// an api datasource.
+	Config map[string]interface{} `json:"config,omitempty"`
 }
 
 type UpdateRequest struct {
@@ -47,7 +51,8 @@ type UpdateRequest struct {
 	Database string `json:"database"`
 	TenantID string `json:"tenantId"` // Set by handler from auth token
 
-	Advanced *AdvancedConfig `json:"advanced,omitempty"`
+	Advanced *AdvancedConfig        `json:"advanced,omitempty"`
+	Config   map[string]interface{} `json:"config,omitempty"`
 }
 
 type AdvancedConfig struct {
@@ -64,12 +69,14 @@ type AdvancedConfig struct {
 type service struct {
 	dsRepo           repository.DatasourceRepository
 	profileValidator *ProfileValidator
+	pools            *PoolManager
 }
 
 func NewService(dsRepo repository.DatasourceRepository) Service {
 	return &service{
 		dsRepo:           dsRepo,
 		profileValidator: NewProfileValidator(),
+		pools:            Pools(),
 	}
 }
 
@@ -92,11 +99,21 @@ func (s *service) Create(ctx context.Context, req *CreateRequest) (*models.DataS
 		config["max_connections"] = req.Advanced.MaxConnections
 		config["query_timeout_seconds"] = req.Advanced.QueryTimeoutSeconds
 	}
+	for key, value := range req.Config {
+		if _, exists := config[key]; !exists {
+			config[key] = value
+		}
+	}
 
 	if err := s.profileValidator.Validate(req.Type, config); err != nil {
 		return nil, err
 	}
 
+	connectorConfig, err := mergeConnectorConfig("", req.Config)
+	if err != nil {
+		return nil, err
+	}
+
 	ds := &models.DataSource{
 		ID:        fmt.Sprintf("ds-%d", time.Now().UnixNano()),
 		Name:      req.Name,
@@ -108,6 +125,7 @@ func (s *service) Create(ctx context.Context, req *CreateRequest) (*models.DataS
 		Database:  req.Database,
 		TenantID:  req.TenantID,
 		CreatedBy: req.CreatedBy,
+		Config:    connectorConfig,
 	}
 
 	if req.Advanced != nil {
@@ -162,23 +180,40 @@ func (s *service) Update(ctx context.Context, req *UpdateRequest) (*models.DataS
 		"port":     req.Port,
 		"database": req.Database,
 		"username": req.Username,
-		"password": req.Password,
+		"password": resolveSecret(ds.Password, req.Password),
 	}
 
 	if req.Advanced != nil {
 		config["ssh_host"] = req.Advanced.SSHHost
 		config["ssh_port"] = req.Advanced.SSHPort
 		config["ssh_username"] = req.Advanced.SSHUsername
-		config["ssh_password"] = req.Advanced.SSHPassword
-		config["ssh_key"] = req.Advanced.SSHKey
-		config["ssh_key_phrase"] = req.Advanced.SSHKeyPhrase
+		config["ssh_password"] = resolveSecret(ds.SSHPassword, req.Advanced.SSHPassword)
+		config["ssh_key"] = resolveSecret(ds.SSHKey, req.Advanced.SSHKey)
+		config["ssh_key_phrase"] = resolveSecret(ds.SSHKeyPhrase, req.Advanced.SSHKeyPhrase)
 		config["max_connections"] = req.Advanced.MaxConnections
 		config["query_timeout_seconds"] = req.Advanced.QueryTimeoutSeconds
 	}
 
+	connectorConfig, err := mergeConnectorConfig(ds.Config, req.Config)
+	if err != nil {
+		return nil, err
+	}
+	if connectorConfig != "" {
+		var stored map[string]interface{}
+		if err := json.Unmarshal([]byte(connectorConfig), &stored); err != nil {
+			return nil, err
+		}
+		for key, value := range stored {
+			if _, exists := config[key]; !exists {
+				config[key] = value
+			}
+		}
+	}
+
 	if err := s.profileValidator.Validate(ds.Type, config); err != nil {
 		return nil, err
 	}
+	ds.Config = connectorConfig
 
 	if req.Name != "" {
 		ds.Name = req.Name
@@ -195,9 +230,7 @@ func (s *service) Update(ctx context.Context, req *UpdateRequest) (*models.DataS
 	if req.Username != "" {
 		ds.Username = req.Username
 	}
-	if req.Password != "" {
-		ds.Password = req.Password
-	}
+	ds.Password = resolveSecret(ds.Password, req.Password)
 	if req.Database != "" {
 		ds.Database = req.Database
 	}
@@ -212,15 +245,9 @@ func (s *service) Update(ctx context.Context, req *UpdateRequest) (*models.DataS
 		if req.Advanced.SSHUsername != "" {
 			ds.SSHUsername = req.Advanced.SSHUsername
 		}
-		if req.Advanced.SSHPassword != "" {
-			ds.SSHPassword = req.Advanced.SSHPassword
-		}
-		if req.Advanced.SSHKey != "" {
-			ds.SSHKey = req.Advanced.SSHKey
-		}
-		if req.Advanced.SSHKeyPhrase != "" {
-			ds.SSHKeyPhrase = req.Advanced.SSHKeyPhrase
-		}
+		ds.SSHPassword = resolveSecret(ds.SSHPassword, req.Advanced.SSHPassword)
+		ds.SSHKey = resolveSecret(ds.SSHKey, req.Advanced.SSHKey)
+		ds.SSHKeyPhrase = resolveSecret(ds.SSHKeyPhrase, req.Advanced.SSHKeyPhrase)
 		if req.Advanced.MaxConnections > 0 {
 			ds.MaxConnections = req.Advanced.MaxConnections
 		}
@@ -232,12 +259,44 @@ func (s *service) Update(ctx context.Context, req *UpdateRequest) (*models.DataS
 	if err := s.dsRepo.Update(ctx, ds); err != nil {
 		return nil, err
 	}
+	s.pools.Invalidate(ds.ID)
 
 	return ds, nil
 }
 
+// mergeConnectorConfig overlays updates on the stored connector config JSON.
+// Entries set to SecretUnchanged keep their stored value.
+func mergeConnectorConfig(stored string, updates map[string]interface{}) (string, error) {
+	if len(updates) == 0 {
+		return stored, nil
+	}
+
+	merged := make(map[string]interface{})
+	if stored != "" {
+		if err := json.Unmarshal([]byte(stored), &merged); err != nil {
+			return "", fmt.Errorf("invalid stored config: %w", err)
+		}
+	}
+	for key, value := range updates {
+		if value == SecretUnchanged {
+			continue
+		}
+		merged[key] = value
+	}
+
+	encoded, err := json.Marshal(merged)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
 func (s *service) Delete(ctx context.Context, id, tenantID string) error {
-	return s.dsRepo.Delete(ctx, id, tenantID)
+	if err := s.dsRepo.Delete(ctx, id, tenantID); err != nil {
+		return err
+	}
+	s.pools.Invalidate(id)
+	return nil
 }
 
 func (s *service) Search(ctx context.Context, tenantID, keyword string, page, pageSize int) ([]*models.DataSource, int64, error) {
@@ -8,6 +8,7 @@ import (
 	"github.com/gujiaweiguo/goreport/internal/models"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 type mockDatasourceRepo struct {
@@ -686,6 +687,34 @@ func TestService_Update_WithAdvanced(t *testing.T) {
 	})
 }
 
+func TestService_Update_SecretUnchanged(t *testing.T) {
+	repo := &mockDatasourceRepo{
+		datasource: &models.DataSource{
+			ID:           "ds-1",
+			Type:         "api",
+			TenantID:     "tenant-1",
+			Password:     "db-secret",
+			SSHKey:       "key-material",
+			SSHKeyPhrase: "phrase",
+			Config:       `{"url":"https://api.example.com","token":"api-token"}`,
+		},
+	}
+	service := NewService(repo)
+
+	datasource, err := service.Update(context.Background(), &UpdateRequest{
+		ID:       "ds-1",
+		Password: SecretUnchanged,
+		TenantID: "tenant-1",
+		Advanced: &AdvancedConfig{SSHKey: SecretUnchanged, SSHKeyPhrase: "new-phrase", MaxConnections: 5, QueryTimeoutSeconds: 30},
+		Config:   map[string]interface{}{"token": SecretUnchanged, "url": "https://api.example.org"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "db-secret", datasource.Password)
+	assert.Equal(t, "key-material", datasource.SSHKey)
+	assert.Equal(t, "new-phrase", datasource.SSHKeyPhrase)
+	assert.JSONEq(t, `{"url":"https://api.example.org","token":"api-token"}`, datasource.Config)
+}
+
 func TestService_List_Pagination(t *testing.T) {
 	t.Run("列表分页-第一页", func(t *testing.T) {
 		datasources := []*models.DataSource{
@@ -844,3 +873,36 @@ func TestService_Create_SpecialTypes(t *testing.T) {
 		assert.Equal(t, "excel", datasource.Type)
 	})
 }
+
+func TestService_APIConnectorConfig(t *testing.T) {
+	repo := &mockDatasourceRepo{}
+	service := NewService(repo)
+
+	created, err := service.Create(context.Background(), &CreateRequest{
+		Name:      "Orders API",
+		Type:      "api",
+		TenantID:  "tenant-1",
+		CreatedBy: "user-1",
+		Config: map[string]interface{}{
+			"url":   "https://api.example.com/v1/",
+			"token": "secret",
+		},
+	})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"url":"https://api.example.com/v1/","token":"secret"}`, created.Config)
+
+	updated, err := service.Update(context.Background(), &UpdateRequest{
+		ID:       created.ID,
+		TenantID: "tenant-1",
+		Config:   map[string]interface{}{"token": "rotated"},
+	})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"url":"https://api.example.com/v1/","token":"rotated"}`, updated.Config)
+
+	_, err = service.Create(context.Background(), &CreateRequest{
+		Name:     "Broken API",
+		Type:     "api",
+		TenantID: "tenant-1",
+	})
+	assert.Error(t, err)
+}
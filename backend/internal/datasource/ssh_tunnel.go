@@ -14,11 +14,17 @@ import (
 type SSHTunnel struct {
 	client     *ssh.Client
 	forwarder  net.Listener
+	listener   net.Listener
 	localAddr  string
 	remoteAddr string
 	closeOnce  sync.Once
+	done       chan struct{}
 }
 
+// sshKeepAliveInterval is how often an open tunnel checks that the SSH server
+// still answers; a tunnel that stops answering closes itself.
+var sshKeepAliveInterval = 30 * time.Second
+
 type SSHTunnelConfig struct {
 	Host     string
 	Port     int
@@ -32,6 +38,7 @@ func NewSSHTunnel(config *SSHTunnelConfig) *SSHTunnel {
 	return &SSHTunnel{
 		localAddr:  "127.0.0.1:0",
 		remoteAddr: fmt.Sprintf("127.0.0.1:%d", config.Port),
+		done:       make(chan struct{}),
 	}
 }
 
@@ -110,9 +117,11 @@ func (t *SSHTunnel) Connect(ctx context.Context, config *SSHTunnelConfig, target
 		return "", fmt.Errorf("failed to listen on local address: %w", err)
 	}
 
+	t.listener = localListener
 	t.localAddr = localListener.Addr().String()
 
 	go t.forwardConnections(ctx, listener, localListener, targetHost, targetPort)
+	go t.keepAlive()
 
 	return t.localAddr, nil
 }
@@ -161,6 +170,50 @@ func (t *SSHTunnel) forwardConnections(ctx context.Context, remote net.Listener,
 	}
 }
 
+// keepAlive closes the tunnel once the SSH connection drops or stops
+// answering keepalive requests.
+func (t *SSHTunnel) keepAlive() {
+	client := t.client
+	lost := make(chan struct{})
+	go func() {
+		_ = client.Wait()
+		close(lost)
+	}()
+
+	ticker := time.NewTicker(sshKeepAliveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.done:
+			return
+		case <-lost:
+			t.Close()
+			return
+		case <-ticker.C:
+			if !ping(client) {
+				t.Close()
+				return
+			}
+		}
+	}
+}
+
+// ping sends an SSH keepalive and reports whether the server answered within
+// the keepalive interval.
+func ping(client *ssh.Client) bool {
+	answered := make(chan error, 1)
+	go func() {
+		_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+		answered <- err
+	}()
+	select {
+	case err := <-answered:
+		return err == nil
+	case <-time.After(sshKeepAliveInterval):
+		return false
+	}
+}
+
 func copyData(dst net.Conn, src net.Conn) (written int64, err error) {
 	defer dst.Close()
 	defer src.Close()
@@ -194,6 +247,12 @@ func (t *SSHTunnel) Close() error {
 	var errs []error
 
 	t.closeOnce.Do(func() {
+		if t.done != nil {
+			close(t.done)
+		}
+		if t.listener != nil {
+			_ = t.listener.Close()
+		}
 		if t.forwarder != nil {
 			if err := t.forwarder.Close(); err != nil {
 				errs = append(errs, err)
@@ -212,6 +271,12 @@ func (t *SSHTunnel) Close() error {
 	return nil
 }
 
+// Done is closed once the tunnel is closed, whether by Close or because the
+// SSH connection was lost.
+func (t *SSHTunnel) Done() <-chan struct{} {
+	return t.done
+}
+
 func (t *SSHTunnel) LocalAddr() string {
 	return t.localAddr
 }
@@ -0,0 +1,199 @@
+package embed
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gujiaweiguo/goreport/internal/auth"
+	"github.com/gujiaweiguo/goreport/internal/dashboard"
+	"github.com/gujiaweiguo/goreport/internal/report"
+	"github.com/gujiaweiguo/goreport/internal/version"
+)
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+// CreateToken issues an embed token for a published dashboard or report.
+func (h *Handler) CreateToken(c *gin.Context) {
+	var req CreateTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "invalid request"})
+		return
+	}
+
+	req.TenantID = auth.GetTenantID(c)
+	if req.TenantID == "" {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "message": "tenant not found"})
+		return
+	}
+	if !version.CanEdit(auth.GetRoles(c)) {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "message": "insufficient permissions"})
+		return
+	}
+
+	token, err := h.service.CreateToken(c.Request.Context(), &req)
+	switch {
+	case errors.Is(err, ErrInvalidRequest):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	case errors.Is(err, ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "failed to create embed token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": token, "message": "embed token created"})
+}
+
+type revokeRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// RevokeToken blacklists an embed token until it expires.
+func (h *Handler) RevokeToken(c *gin.Context) {
+	var req revokeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "invalid request"})
+		return
+	}
+
+	tenantID := auth.GetTenantID(c)
+	if tenantID == "" {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "message": "tenant not found"})
+		return
+	}
+	if !version.CanEdit(auth.GetRoles(c)) {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "message": "insufficient permissions"})
+		return
+	}
+
+	err := h.service.RevokeToken(c.Request.Context(), tenantID, req.Token)
+	switch {
+	case errors.Is(err, ErrInvalidToken):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	case errors.Is(err, ErrRevocationUnavailable):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "message": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "failed to revoke token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "embed token revoked"})
+}
+
+func (h *Handler) Dashboard(c *gin.Context) {
+	claims := auth.GetEmbedClaims(c)
+	if claims == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "message": "missing embed token"})
+		return
+	}
+
+	result, err := h.service.Dashboard(c.Request.Context(), claims)
+	if writeError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": result, "message": "success"})
+}
+
+// DashboardData takes the filter state as the dashboard data route does;
+// the token's fixed filter values win over it.
+func (h *Handler) DashboardData(c *gin.Context) {
+	claims := auth.GetEmbedClaims(c)
+	if claims == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "message": "missing embed token"})
+		return
+	}
+
+	state, err := dashboard.BindFilterState(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "invalid filter state"})
+		return
+	}
+
+	data, err := h.service.DashboardData(c.Request.Context(), claims, state)
+	if writeError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": data, "message": "success"})
+}
+
+func (h *Handler) Report(c *gin.Context) {
+	claims := auth.GetEmbedClaims(c)
+	if claims == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "message": "missing embed token"})
+		return
+	}
+
+	result, err := h.service.Report(c.Request.Context(), claims)
+	if writeError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": result, "message": "success"})
+}
+
+type previewRequest struct {
+	Page   int                    `json:"page"`
+	Params map[string]interface{} `json:"params"`
+}
+
+// Preview renders a page of the report; the token's fixed parameters win
+// over those in the body.
+func (h *Handler) Preview(c *gin.Context) {
+	claims := auth.GetEmbedClaims(c)
+	if claims == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "message": "missing embed token"})
+		return
+	}
+
+	var req previewRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "invalid request"})
+			return
+		}
+	}
+
+	resp, err := h.service.Preview(c.Request.Context(), claims, req.Page, req.Params)
+	if writeError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": resp, "message": "success"})
+}
+
+// Preflight answers OPTIONS requests; the CORS middleware answers those a
+// browser sends before it gets here.
+func (h *Handler) Preflight(c *gin.Context) {
+	c.Status(http.StatusNoContent)
+}
+
+// writeError writes the response for a failed embed read and reports
+// whether there was one.
+func writeError(c *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, ErrWrongResource):
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "message": err.Error()})
+	case errors.Is(err, ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": err.Error()})
+	case errors.Is(err, dashboard.ErrInvalidFilter), report.IsBadInput(err):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "failed to load embedded resource"})
+	}
+	return true
+}
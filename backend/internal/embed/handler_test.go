@@ -0,0 +1,130 @@
+package embed
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gujiaweiguo/goreport/internal/auth"
+	"github.com/gujiaweiguo/goreport/internal/middleware"
+	"github.com/gujiaweiguo/goreport/internal/version"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupRouter wires the handler as the server does, with the token routes
+// called by a tenant-1 user of the given role.
+func setupRouter(role string) (*gin.Engine, *stubReports) {
+	gin.SetMode(gin.TestMode)
+	svc, _, reports := newTestService()
+	handler := NewHandler(svc)
+
+	r := gin.New()
+	tokens := r.Group("/api/v1/embed-tokens", func(c *gin.Context) {
+		c.Set(string(auth.TenantIDKey), "tenant-1")
+		c.Set(string(auth.RolesKey), []string{role})
+	})
+	tokens.POST("", handler.CreateToken)
+	tokens.POST("/revoke", handler.RevokeToken)
+
+	embeds := r.Group(PathPrefix, middleware.CORS([]string{"https://app.example.com"}, auth.EmbedPasswordHeader))
+	embeds.OPTIONS("/*path", handler.Preflight)
+	embedded := embeds.Group("", auth.EmbedMiddleware())
+	embedded.GET("/dashboard", handler.Dashboard)
+	embedded.POST("/dashboard/data", handler.DashboardData)
+	embedded.GET("/report", handler.Report)
+	embedded.POST("/report/preview", handler.Preview)
+	return r, reports
+}
+
+func createToken(t *testing.T, r *gin.Engine, body map[string]interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	payload, err := json.Marshal(body)
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/embed-tokens", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestHandler_CreateToken(t *testing.T) {
+	r, _ := setupRouter("viewer")
+	w := createToken(t, r, map[string]interface{}{"resourceType": version.ResourceReport, "resourceId": "report-1"})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	r, _ = setupRouter("user")
+	w = createToken(t, r, map[string]interface{}{"resourceType": version.ResourceReport, "resourceId": "report-2"})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = createToken(t, r, map[string]interface{}{"resourceType": version.ResourceReport, "resourceId": "report-1", "expiresIn": -5})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = createToken(t, r, map[string]interface{}{"resourceType": version.ResourceReport, "resourceId": "report-1"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"token"`)
+}
+
+func TestHandler_RevokeToken_WithoutCache(t *testing.T) {
+	auth.InitBlacklist(nil)
+	r, _ := setupRouter("admin")
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/embed-tokens/revoke", bytes.NewBufferString(`{"token":"abc"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+func TestHandler_EmbeddedReport(t *testing.T) {
+	auth.InitBlacklist(nil)
+	r, reports := setupRouter("admin")
+
+	w := createToken(t, r, map[string]interface{}{
+		"resourceType": version.ResourceReport,
+		"resourceId":   "report-1",
+		"params":       map[string]interface{}{"customer": "c-1"},
+		"origins":      []string{"https://app.example.com"},
+	})
+	require.Equal(t, http.StatusOK, w.Code)
+	var created struct {
+		Result TokenResponse `json:"result"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	token := created.Result.Token
+
+	send := func(method, path, origin, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w = send(http.MethodOptions, "/api/v1/embed/report/preview", "https://app.example.com", "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+
+	w = send(http.MethodPost, "/api/v1/embed/report/preview", "https://evil.example.com", "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = send(http.MethodGet, "/api/v1/embed/report", "https://app.example.com", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"name":"Orders"`)
+
+	w = send(http.MethodPost, "/api/v1/embed/report/preview", "https://app.example.com", `{"page":1,"params":{"customer":"c-2"}}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "c-1", reports.preview.Params["customer"])
+
+	w = send(http.MethodGet, "/api/v1/embed/dashboard", "https://app.example.com", "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
@@ -0,0 +1,215 @@
+package embed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gujiaweiguo/goreport/internal/auth"
+	"github.com/gujiaweiguo/goreport/internal/config"
+	"github.com/gujiaweiguo/goreport/internal/dashboard"
+	"github.com/gujiaweiguo/goreport/internal/models"
+	"github.com/gujiaweiguo/goreport/internal/report"
+	"github.com/gujiaweiguo/goreport/internal/version"
+)
+
+// PathPrefix is where the embed routes live. They authenticate with embed
+// tokens, so the user token middleware leaves them alone.
+const PathPrefix = "/api/v1/embed"
+
+var (
+	ErrInvalidRequest        = errors.New("invalid embed token request")
+	ErrNotFound              = errors.New("resource not found or not published")
+	ErrInvalidToken          = errors.New("invalid embed token")
+	ErrWrongResource         = errors.New("token does not grant access to this resource")
+	ErrRevocationUnavailable = errors.New("token revocation requires the cache to be enabled")
+)
+
+// Service issues embed tokens and serves the published dashboard or report
+// a token grants, with the token's fixed values applied.
+type Service interface {
+	CreateToken(ctx context.Context, req *CreateTokenRequest) (*TokenResponse, error)
+	RevokeToken(ctx context.Context, tenantID, token string) error
+	Dashboard(ctx context.Context, claims *auth.EmbedClaims) (*models.Dashboard, error)
+	DashboardData(ctx context.Context, claims *auth.EmbedClaims, state *dashboard.FilterState) (*dashboard.DataResponse, error)
+	Report(ctx context.Context, claims *auth.EmbedClaims) (*report.Report, error)
+	Preview(ctx context.Context, claims *auth.EmbedClaims, page int, params map[string]interface{}) (*report.PreviewResponse, error)
+}
+
+type service struct {
+	dashboards dashboard.Service
+	reports    report.Service
+	cfg        config.EmbedConfig
+}
+
+func NewService(dashboards dashboard.Service, reports report.Service, cfg config.EmbedConfig) Service {
+	return &service{dashboards: dashboards, reports: reports, cfg: cfg}
+}
+
+// CreateTokenRequest describes an embed token. Params fix report parameters
+// and Filters dashboard filter values by filter ID. ExpiresIn is in seconds;
+// 0 takes the configured default.
+type CreateTokenRequest struct {
+	TenantID     string                 `json:"-"`
+	ResourceType string                 `json:"resourceType" binding:"required"`
+	ResourceID   string                 `json:"resourceId" binding:"required"`
+	Params       map[string]interface{} `json:"params"`
+	Filters      map[string]interface{} `json:"filters"`
+	Origins      []string               `json:"origins"`
+	Password     string                 `json:"password"`
+	ExpiresIn    int                    `json:"expiresIn"`
+}
+
+type TokenResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+func (s *service) CreateToken(ctx context.Context, req *CreateTokenRequest) (*TokenResponse, error) {
+	claims := &auth.EmbedClaims{
+		TenantID:     req.TenantID,
+		ResourceType: req.ResourceType,
+		ResourceID:   req.ResourceID,
+		Params:       req.Params,
+		Filters:      req.Filters,
+	}
+
+	switch req.ResourceType {
+	case version.ResourceDashboard:
+		if len(req.Params) > 0 {
+			return nil, fmt.Errorf("%w: dashboards take filters, not params", ErrInvalidRequest)
+		}
+		if _, err := s.Dashboard(ctx, claims); err != nil {
+			return nil, err
+		}
+	case version.ResourceReport:
+		if len(req.Filters) > 0 {
+			return nil, fmt.Errorf("%w: reports take params, not filters", ErrInvalidRequest)
+		}
+		if _, err := s.Report(ctx, claims); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%w: unsupported resource type %q", ErrInvalidRequest, req.ResourceType)
+	}
+
+	ttl := req.ExpiresIn
+	if ttl == 0 {
+		ttl = s.cfg.TokenTTL
+	}
+	if ttl <= 0 || (s.cfg.MaxTokenTTL > 0 && ttl > s.cfg.MaxTokenTTL) {
+		return nil, fmt.Errorf("%w: expiry must be between 1 and %d seconds", ErrInvalidRequest, s.cfg.MaxTokenTTL)
+	}
+
+	for _, origin := range req.Origins {
+		normalized := auth.NormalizeOrigin(origin)
+		if normalized == "" {
+			return nil, fmt.Errorf("%w: invalid origin %q", ErrInvalidRequest, origin)
+		}
+		claims.Origins = append(claims.Origins, normalized)
+	}
+
+	if req.Password != "" {
+		if err := claims.SetPassword(req.Password); err != nil {
+			return nil, err
+		}
+	}
+
+	token, err := auth.GenerateEmbedToken(claims, time.Duration(ttl)*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenResponse{Token: token, ExpiresAt: claims.ExpiresAt.Time}, nil
+}
+
+// RevokeToken blacklists an embed token of the tenant until it expires.
+func (s *service) RevokeToken(ctx context.Context, tenantID, token string) error {
+	if !auth.RevocationAvailable() {
+		return ErrRevocationUnavailable
+	}
+	claims, err := auth.ValidateEmbedToken(token)
+	if err != nil || claims.TenantID != tenantID {
+		return ErrInvalidToken
+	}
+	return auth.RevokeToken(ctx, token, claims.ExpiresAt.Time)
+}
+
+// Dashboard returns the published version of the token's dashboard.
+func (s *service) Dashboard(ctx context.Context, claims *auth.EmbedClaims) (*models.Dashboard, error) {
+	if claims.ResourceType != version.ResourceDashboard {
+		return nil, ErrWrongResource
+	}
+	result, err := s.dashboards.Get(version.WithPublished(ctx), claims.ResourceID, claims.TenantID)
+	if errors.Is(err, dashboard.ErrNotFound) {
+		return nil, ErrNotFound
+	}
+	return result, err
+}
+
+// DashboardData resolves the token's dashboard with its fixed filter values
+// in place of those asked for.
+func (s *service) DashboardData(ctx context.Context, claims *auth.EmbedClaims, state *dashboard.FilterState) (*dashboard.DataResponse, error) {
+	if claims.ResourceType != version.ResourceDashboard {
+		return nil, ErrWrongResource
+	}
+
+	fixed := dashboard.FilterState{}
+	if state != nil {
+		fixed = *state
+	}
+	fixed.Filters = merge(fixed.Filters, claims.Filters)
+
+	data, err := s.dashboards.Data(version.WithPublished(ctx), claims.ResourceID, claims.TenantID, &fixed)
+	if errors.Is(err, dashboard.ErrNotFound) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+// Report returns the published version of the token's report.
+func (s *service) Report(ctx context.Context, claims *auth.EmbedClaims) (*report.Report, error) {
+	if claims.ResourceType != version.ResourceReport {
+		return nil, ErrWrongResource
+	}
+	result, err := s.reports.Get(version.WithPublished(ctx), claims.ResourceID, claims.TenantID)
+	if errors.Is(err, report.ErrNotFound) {
+		return nil, ErrNotFound
+	}
+	return result, err
+}
+
+// Preview renders a page of the token's report with its fixed parameters in
+// place of those asked for.
+func (s *service) Preview(ctx context.Context, claims *auth.EmbedClaims, page int, params map[string]interface{}) (*report.PreviewResponse, error) {
+	if claims.ResourceType != version.ResourceReport {
+		return nil, ErrWrongResource
+	}
+
+	resp, err := s.reports.Preview(version.WithPublished(ctx), &report.PreviewRequest{
+		TenantID: claims.TenantID,
+		ID:       claims.ResourceID,
+		Page:     page,
+		Params:   merge(params, claims.Params),
+	})
+	if errors.Is(err, report.ErrNotFound) {
+		return nil, ErrNotFound
+	}
+	return resp, err
+}
+
+// merge copies values and sets the fixed ones over them.
+func merge(values, fixed map[string]interface{}) map[string]interface{} {
+	if len(fixed) == 0 {
+		return values
+	}
+	merged := make(map[string]interface{}, len(values)+len(fixed))
+	for key, value := range values {
+		merged[key] = value
+	}
+	for key, value := range fixed {
+		merged[key] = value
+	}
+	return merged
+}
@@ -0,0 +1,189 @@
+package embed
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gujiaweiguo/goreport/internal/auth"
+	"github.com/gujiaweiguo/goreport/internal/cache"
+	"github.com/gujiaweiguo/goreport/internal/config"
+	"github.com/gujiaweiguo/goreport/internal/dashboard"
+	"github.com/gujiaweiguo/goreport/internal/models"
+	"github.com/gujiaweiguo/goreport/internal/report"
+	"github.com/gujiaweiguo/goreport/internal/version"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubDashboards serves the published dashboard "dash-1" of tenant-1 and
+// records the filter state its data is asked for with.
+type stubDashboards struct {
+	dashboard.Service
+	state *dashboard.FilterState
+}
+
+func (s *stubDashboards) Get(ctx context.Context, id, tenantID string) (*models.Dashboard, error) {
+	if !version.Published(ctx) || id != "dash-1" || tenantID != "tenant-1" {
+		return nil, dashboard.ErrNotFound
+	}
+	return &models.Dashboard{ID: id, TenantID: tenantID, Name: "Sales"}, nil
+}
+
+func (s *stubDashboards) Data(ctx context.Context, id, tenantID string, state *dashboard.FilterState) (*dashboard.DataResponse, error) {
+	if _, err := s.Get(ctx, id, tenantID); err != nil {
+		return nil, err
+	}
+	s.state = state
+	return &dashboard.DataResponse{}, nil
+}
+
+// stubReports serves the published report "report-1" of tenant-1 and records
+// the preview asked for.
+type stubReports struct {
+	report.Service
+	preview *report.PreviewRequest
+}
+
+func (s *stubReports) Get(ctx context.Context, id, tenantID string) (*report.Report, error) {
+	if !version.Published(ctx) || id != "report-1" || tenantID != "tenant-1" {
+		return nil, report.ErrNotFound
+	}
+	return &report.Report{ID: id, TenantID: tenantID, Name: "Orders"}, nil
+}
+
+func (s *stubReports) Preview(ctx context.Context, req *report.PreviewRequest) (*report.PreviewResponse, error) {
+	if _, err := s.Get(ctx, req.ID, req.TenantID); err != nil {
+		return nil, err
+	}
+	s.preview = req
+	return &report.PreviewResponse{HTML: "<table></table>", Page: 1, TotalPages: 1}, nil
+}
+
+func newTestService() (*service, *stubDashboards, *stubReports) {
+	auth.InitJWT(&config.JWTConfig{Secret: "test-secret", Issuer: "test", Audience: "test"})
+	dashboards, reports := &stubDashboards{}, &stubReports{}
+	svc := NewService(dashboards, reports, config.EmbedConfig{TokenTTL: 3600, MaxTokenTTL: 86400}).(*service)
+	return svc, dashboards, reports
+}
+
+func TestService_CreateToken(t *testing.T) {
+	svc, _, _ := newTestService()
+	ctx := context.Background()
+
+	resp, err := svc.CreateToken(ctx, &CreateTokenRequest{
+		TenantID:     "tenant-1",
+		ResourceType: version.ResourceDashboard,
+		ResourceID:   "dash-1",
+		Filters:      map[string]interface{}{"region": "east"},
+		Origins:      []string{"https://App.example.com/"},
+		Password:     "secret",
+	})
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), resp.ExpiresAt, time.Minute)
+
+	claims, err := auth.ValidateEmbedToken(resp.Token)
+	require.NoError(t, err)
+	assert.Equal(t, "tenant-1", claims.TenantID)
+	assert.Equal(t, version.ResourceDashboard, claims.ResourceType)
+	assert.Equal(t, []string{"https://app.example.com"}, claims.Origins)
+	assert.Equal(t, "east", claims.Filters["region"])
+	assert.True(t, claims.CheckPassword("secret"))
+	assert.False(t, claims.CheckPassword("wrong"))
+	assert.NotContains(t, resp.Token, "$2a$", "the token carries no password hash")
+
+	tests := []struct {
+		name string
+		req  CreateTokenRequest
+		want error
+	}{
+		{name: "unknown type", req: CreateTokenRequest{ResourceType: "chart", ResourceID: "chart-1"}, want: ErrInvalidRequest},
+		{name: "unpublished", req: CreateTokenRequest{ResourceType: version.ResourceReport, ResourceID: "report-2"}, want: ErrNotFound},
+		{name: "other tenant", req: CreateTokenRequest{ResourceType: version.ResourceReport, ResourceID: "report-1", TenantID: "tenant-2"}, want: ErrNotFound},
+		{name: "params on dashboard", req: CreateTokenRequest{ResourceType: version.ResourceDashboard, ResourceID: "dash-1", Params: map[string]interface{}{"a": 1}}, want: ErrInvalidRequest},
+		{name: "filters on report", req: CreateTokenRequest{ResourceType: version.ResourceReport, ResourceID: "report-1", Filters: map[string]interface{}{"a": 1}}, want: ErrInvalidRequest},
+		{name: "expiry too long", req: CreateTokenRequest{ResourceType: version.ResourceReport, ResourceID: "report-1", ExpiresIn: 86401}, want: ErrInvalidRequest},
+		{name: "negative expiry", req: CreateTokenRequest{ResourceType: version.ResourceReport, ResourceID: "report-1", ExpiresIn: -1}, want: ErrInvalidRequest},
+		{name: "invalid origin", req: CreateTokenRequest{ResourceType: version.ResourceReport, ResourceID: "report-1", Origins: []string{"example.com"}}, want: ErrInvalidRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := tt.req
+			if req.TenantID == "" {
+				req.TenantID = "tenant-1"
+			}
+			_, err := svc.CreateToken(ctx, &req)
+			assert.ErrorIs(t, err, tt.want)
+		})
+	}
+}
+
+func TestService_FixedValues(t *testing.T) {
+	svc, dashboards, reports := newTestService()
+	ctx := context.Background()
+
+	dashboardClaims := &auth.EmbedClaims{
+		TenantID:     "tenant-1",
+		ResourceType: version.ResourceDashboard,
+		ResourceID:   "dash-1",
+		Filters:      map[string]interface{}{"region": "east"},
+	}
+	_, err := svc.DashboardData(ctx, dashboardClaims, &dashboard.FilterState{
+		Filters: map[string]interface{}{"region": nil, "year": 2024},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"region": "east", "year": 2024}, dashboards.state.Filters)
+
+	_, err = svc.DashboardData(ctx, dashboardClaims, nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"region": "east"}, dashboards.state.Filters)
+
+	reportClaims := &auth.EmbedClaims{
+		TenantID:     "tenant-1",
+		ResourceType: version.ResourceReport,
+		ResourceID:   "report-1",
+		Params:       map[string]interface{}{"customer": "c-1"},
+	}
+	_, err = svc.Preview(ctx, reportClaims, 2, map[string]interface{}{"customer": "c-2", "month": "2024-01"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, reports.preview.Page)
+	assert.Equal(t, map[string]interface{}{"customer": "c-1", "month": "2024-01"}, reports.preview.Params)
+
+	_, err = svc.Dashboard(ctx, reportClaims)
+	assert.ErrorIs(t, err, ErrWrongResource)
+	_, err = svc.Preview(ctx, dashboardClaims, 1, nil)
+	assert.ErrorIs(t, err, ErrWrongResource)
+}
+
+func TestService_RevokeToken(t *testing.T) {
+	svc, _, _ := newTestService()
+	ctx := context.Background()
+
+	resp, err := svc.CreateToken(ctx, &CreateTokenRequest{TenantID: "tenant-1", ResourceType: version.ResourceReport, ResourceID: "report-1"})
+	require.NoError(t, err)
+
+	// Without a cache nothing would remember the revocation.
+	auth.InitBlacklist(nil)
+	assert.ErrorIs(t, svc.RevokeToken(ctx, "tenant-1", resp.Token), ErrRevocationUnavailable)
+
+	addr := os.Getenv("TEST_REDIS_ADDR")
+	if addr == "" {
+		addr = os.Getenv("REDIS_ADDR")
+	}
+	if addr == "" {
+		t.Skip("TEST_REDIS_ADDR or REDIS_ADDR not set")
+	}
+	c, err := cache.New(config.CacheConfig{Enabled: true, Addr: addr, Password: os.Getenv("REDIS_PASSWORD"), DefaultTTL: 60})
+	require.NoError(t, err)
+	defer c.Close()
+	if c.IsDegraded() {
+		t.Skip("redis unavailable, cache degraded to noop")
+	}
+	auth.InitBlacklist(c)
+	defer auth.InitBlacklist(nil)
+
+	assert.ErrorIs(t, svc.RevokeToken(ctx, "tenant-2", resp.Token), ErrInvalidToken)
+	require.NoError(t, svc.RevokeToken(ctx, "tenant-1", resp.Token))
+	assert.True(t, auth.IsTokenRevoked(ctx, resp.Token))
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/gujiaweiguo/goreport/internal/config"
 	"github.com/gujiaweiguo/goreport/internal/models"
 	"github.com/gujiaweiguo/goreport/internal/report"
+	"github.com/gujiaweiguo/goreport/internal/version"
 	"github.com/stretchr/testify/assert"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
@@ -36,7 +37,7 @@ func setupTestServer(t *testing.T) (*Server, *gorm.DB) {
 	}
 
 	// 自动迁移
-	db.AutoMigrate(&models.User{}, &models.Tenant{}, &models.DataSource{}, &models.Dataset{}, &models.Dashboard{}, &report.Report{})
+	db.AutoMigrate(&models.User{}, &models.Tenant{}, &models.DataSource{}, &models.Dataset{}, &models.Dashboard{}, &report.Report{}, &version.Version{})
 
 	cfg := &config.Config{
 		Server: config.ServerConfig{
@@ -508,6 +509,12 @@ func TestAPI_EndpointsSummary(t *testing.T) {
 		{http.MethodPost, "/api/v1/jmreport/update"},
 		{http.MethodDelete, "/api/v1/jmreport/delete"},
 		{http.MethodPost, "/api/v1/jmreport/preview"},
+		{http.MethodGet, "/api/v1/jmreport/parameters"},
+		{http.MethodGet, "/api/v1/jmreport/versions?id=123"},
+		{http.MethodGet, "/api/v1/jmreport/version?id=123&version=1"},
+		{http.MethodGet, "/api/v1/jmreport/diff?id=123&from=1"},
+		{http.MethodPost, "/api/v1/jmreport/publish"},
+		{http.MethodPost, "/api/v1/jmreport/rollback"},
 		{http.MethodGet, "/api/v1/dashboard/list"},
 		{http.MethodPost, "/api/v1/dashboard/create"},
 		{http.MethodGet, "/api/v1/dashboard/123"},
@@ -527,6 +534,42 @@ func TestAPI_EndpointsSummary(t *testing.T) {
 		{http.MethodPatch, "/api/v1/datasets/123/fields"},
 		{http.MethodPut, "/api/v1/datasets/123/fields/456"},
 		{http.MethodDelete, "/api/v1/datasets/123/fields/456"},
+		{http.MethodGet, "/api/v1/dashboard/123/data"},
+		{http.MethodPost, "/api/v1/dashboard/123/data"},
+		{http.MethodGet, "/api/v1/dashboard/123/filters/456/options"},
+		{http.MethodPost, "/api/v1/dashboard/123/components/456/drill-through"},
+		{http.MethodGet, "/api/v1/dashboard/123/versions"},
+		{http.MethodGet, "/api/v1/dashboard/123/versions/1"},
+		{http.MethodGet, "/api/v1/dashboard/123/diff?from=1"},
+		{http.MethodPost, "/api/v1/dashboard/123/publish"},
+		{http.MethodPost, "/api/v1/dashboard/123/rollback"},
+		{http.MethodGet, "/api/v1/charts"},
+		{http.MethodGet, "/api/v1/charts/get?id=123"},
+		{http.MethodPost, "/api/v1/charts/create"},
+		{http.MethodPost, "/api/v1/charts/update"},
+		{http.MethodDelete, "/api/v1/charts/delete?id=123"},
+		{http.MethodGet, "/api/v1/charts/render?id=123"},
+		{http.MethodPost, "/api/v1/charts/drill-through?id=123"},
+		{http.MethodGet, "/api/v1/charts/versions?id=123"},
+		{http.MethodGet, "/api/v1/charts/version?id=123&version=1"},
+		{http.MethodGet, "/api/v1/charts/diff?id=123&from=1"},
+		{http.MethodPost, "/api/v1/charts/publish"},
+		{http.MethodPost, "/api/v1/charts/rollback"},
+		{http.MethodGet, "/api/v1/schedules"},
+		{http.MethodPost, "/api/v1/schedules"},
+		{http.MethodGet, "/api/v1/schedules/123"},
+		{http.MethodPut, "/api/v1/schedules/123"},
+		{http.MethodDelete, "/api/v1/schedules/123"},
+		{http.MethodPost, "/api/v1/schedules/123/run"},
+		{http.MethodGet, "/api/v1/schedules/123/runs"},
+		{http.MethodPost, "/api/v1/embed-tokens"},
+		{http.MethodPost, "/api/v1/embed-tokens/revoke"},
+		{http.MethodGet, "/api/v1/embed/dashboard"},
+		{http.MethodGet, "/api/v1/embed/dashboard/data"},
+		{http.MethodPost, "/api/v1/embed/dashboard/data"},
+		{http.MethodGet, "/api/v1/embed/report"},
+		{http.MethodPost, "/api/v1/embed/report/preview"},
+		{http.MethodOptions, "/api/v1/embed/report/preview"},
 	}
 
 	var passed, failed int
@@ -2,20 +2,26 @@ package httpserver
 
 import (
 	"context"
+	"log"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gujiaweiguo/goreport/internal/auth"
 	"github.com/gujiaweiguo/goreport/internal/cache"
+	"github.com/gujiaweiguo/goreport/internal/chart"
 	"github.com/gujiaweiguo/goreport/internal/config"
 	"github.com/gujiaweiguo/goreport/internal/dashboard"
 	"github.com/gujiaweiguo/goreport/internal/dataset"
 	"github.com/gujiaweiguo/goreport/internal/datasource"
+	"github.com/gujiaweiguo/goreport/internal/embed"
 	"github.com/gujiaweiguo/goreport/internal/httpserver/handlers"
 	"github.com/gujiaweiguo/goreport/internal/middleware"
 	"github.com/gujiaweiguo/goreport/internal/render"
 	"github.com/gujiaweiguo/goreport/internal/report"
 	"github.com/gujiaweiguo/goreport/internal/repository"
+	"github.com/gujiaweiguo/goreport/internal/schedule"
+	"github.com/gujiaweiguo/goreport/internal/secrets"
+	"github.com/gujiaweiguo/goreport/internal/version"
 	"gorm.io/gorm"
 )
 
@@ -24,6 +30,9 @@ type Server struct {
 	Engine *gin.Engine
 	Server *http.Server
 	Cache  *cache.Cache
+
+	Scheduler        *schedule.Runner
+	schedulerEnabled bool
 }
 
 // NewServer 创建新的 HTTP 服务器
@@ -36,6 +45,14 @@ func NewServer(cfg *config.Config, db *gorm.DB) (*Server, error) {
 	}
 	auth.InitBlacklist(cache)
 
+	// 数据源敏感字段加密主密钥，须在创建数据源仓库之前初始化
+	if err := secrets.Init(&cfg.Datasource); err != nil {
+		return nil, err
+	}
+	if !secrets.Default().Enabled() {
+		log.Println("DATASOURCE_SECRET_KEY is not set, datasource secrets will be stored unencrypted")
+	}
+
 	r := gin.Default()
 
 	// 全局中间件
@@ -43,7 +60,7 @@ func NewServer(cfg *config.Config, db *gorm.DB) (*Server, error) {
 	r.Use(gin.Logger())
 	r.Use(middleware.ErrorHandler())
 	r.Use(middleware.RecoveryHandler())
-	r.Use(auth.AuthMiddleware())
+	r.Use(auth.AuthMiddleware(embed.PathPrefix + "/"))
 
 	// 健康检查
 	healthHandler := handlers.NewHealthHandler(db)
@@ -51,10 +68,10 @@ func NewServer(cfg *config.Config, db *gorm.DB) (*Server, error) {
 
 	// 认证路由
 	authHandler := handlers.NewAuthHandler(db)
-	auth := r.Group("/api/v1/auth")
+	authRoutes := r.Group("/api/v1/auth")
 	{
-		auth.POST("/login", authHandler.Login)
-		auth.POST("/logout", authHandler.Logout)
+		authRoutes.POST("/login", authHandler.Login)
+		authRoutes.POST("/logout", authHandler.Logout)
 	}
 
 	// 用户与租户路由
@@ -72,6 +89,7 @@ func NewServer(cfg *config.Config, db *gorm.DB) (*Server, error) {
 	}
 
 	// 数据源路由（新的 datasource 包）
+	datasource.InitPools(&cfg.Datasource)
 	datasourceRepo := repository.NewDatasourceRepository(db)
 	datasourceService := datasource.NewService(datasourceRepo)
 	datasourceHandler := datasource.NewHandlerWithMetadata(datasourceService, datasource.NewCachedMetadataService(cache))
@@ -97,35 +115,9 @@ func NewServer(cfg *config.Config, db *gorm.DB) (*Server, error) {
 	cacheHandler := handlers.NewCacheHandler(cache)
 	r.GET("/api/v1/cache/metrics", cacheHandler.GetMetrics)
 
-	// 报表路由
-	reportRepo := report.NewRepository(db)
-	reportEngine := render.NewEngine(db, cache)
-	reportService := report.NewService(reportRepo, reportEngine, cache)
-	reportHandler := report.NewHandler(reportService)
-	reports := r.Group("/api/v1/jmreport")
-	{
-		reports.GET("/list", reportHandler.List)
-		reports.GET("/get", reportHandler.Get)
-		reports.POST("/create", reportHandler.Create)
-		reports.POST("/update", reportHandler.Update)
-		reports.DELETE("/delete", reportHandler.Delete)
-		reports.POST("/preview", reportHandler.Preview)
-	}
-
-	// 仪表盘路由
-	dashboardRepo := dashboard.NewRepository(db)
-	dashboardService := dashboard.NewService(dashboardRepo)
-	dashboardHandler := dashboard.NewHandler(dashboardService)
-	dashboards := r.Group("/api/v1/dashboard")
-	{
-		dashboards.GET("/list", dashboardHandler.List)
-		dashboards.POST("/create", dashboardHandler.Create)
-		dashboards.GET("/:id", dashboardHandler.Get)
-		dashboards.PUT("/:id", dashboardHandler.Update)
-		dashboards.DELETE("/:id", dashboardHandler.Delete)
-	}
-
 	// 数据集路由
+	dataset.InitFileStorage(&cfg.Storage)
+	dataset.InitExport(&cfg.Export)
 	datasetRepo := repository.NewDatasetRepository(db)
 	fieldRepo := repository.NewDatasetFieldRepository(db)
 	sourceRepo := repository.NewDatasetSourceRepository(db)
@@ -137,11 +129,14 @@ func NewServer(cfg *config.Config, db *gorm.DB) (*Server, error) {
 	{
 		datasets.GET("", datasetHandler.List)
 		datasets.POST("", datasetHandler.Create)
+		datasets.POST("/upload", datasetHandler.Upload)
 		datasets.GET("/:id", datasetHandler.Get)
 		datasets.PUT("/:id", datasetHandler.Update)
 		datasets.DELETE("/:id", datasetHandler.Delete)
 		datasets.GET("/:id/preview", datasetHandler.Preview)
 		datasets.POST("/:id/data", datasetHandler.QueryData)
+		datasets.POST("/:id/export", datasetHandler.Export)
+		datasets.POST("/:id/upload", datasetHandler.Upload)
 		datasets.GET("/:id/dimensions", datasetHandler.GetDimensions)
 		datasets.GET("/:id/measures", datasetHandler.GetMeasures)
 		datasets.GET("/:id/schema", datasetHandler.GetSchema)
@@ -151,6 +146,111 @@ func NewServer(cfg *config.Config, db *gorm.DB) (*Server, error) {
 		datasets.DELETE("/:id/fields/:fieldId", datasetHandler.DeleteField)
 	}
 
+	// 仪表盘、图表与报表每次保存都记录版本，查看者只能看到已发布版本
+	versionRepo := version.NewRepository(db)
+
+	// 仪表盘路由
+	dashboardRepo := dashboard.NewRepository(db)
+	dashboardService := dashboard.NewService(dashboardRepo, queryExecutor, versionRepo)
+	dashboardHandler := dashboard.NewHandler(dashboardService)
+	dashboards := r.Group("/api/v1/dashboard")
+	{
+		dashboards.GET("/list", dashboardHandler.List)
+		dashboards.POST("/create", dashboardHandler.Create)
+		dashboards.GET("/:id", dashboardHandler.Get)
+		dashboards.PUT("/:id", dashboardHandler.Update)
+		dashboards.DELETE("/:id", dashboardHandler.Delete)
+		dashboards.GET("/:id/data", dashboardHandler.Data)
+		dashboards.POST("/:id/data", dashboardHandler.Data)
+		dashboards.GET("/:id/filters/:filterId/options", dashboardHandler.FilterOptions)
+		dashboards.POST("/:id/components/:componentId/drill-through", dashboardHandler.DrillThrough)
+		dashboards.GET("/:id/versions", dashboardHandler.Versions)
+		dashboards.GET("/:id/versions/:version", dashboardHandler.Version)
+		dashboards.GET("/:id/diff", dashboardHandler.Diff)
+		dashboards.POST("/:id/publish", dashboardHandler.Publish)
+		dashboards.POST("/:id/rollback", dashboardHandler.Rollback)
+	}
+
+	// 图表路由，渲染结果为可直接交给 ECharts 的 option
+	chartService := chart.NewService(chart.NewRepository(db), queryExecutor, versionRepo)
+	chartHandler := chart.NewHandler(chartService)
+	charts := r.Group("/api/v1/charts")
+	{
+		charts.GET("", chartHandler.List)
+		charts.GET("/get", chartHandler.Get)
+		charts.POST("/create", chartHandler.Create)
+		charts.POST("/update", chartHandler.Update)
+		charts.DELETE("/delete", chartHandler.Delete)
+		charts.GET("/render", chartHandler.Render)
+		charts.POST("/drill-through", chartHandler.DrillThrough)
+		charts.GET("/versions", chartHandler.Versions)
+		charts.GET("/version", chartHandler.Version)
+		charts.GET("/diff", chartHandler.Diff)
+		charts.POST("/publish", chartHandler.Publish)
+		charts.POST("/rollback", chartHandler.Rollback)
+	}
+
+	// 报表路由
+	if err := render.InitExport(&cfg.Export); err != nil {
+		return nil, err
+	}
+	reportRepo := report.NewRepository(db)
+	reportEngine := render.NewEngine(db, cache, queryExecutor)
+	reportService := report.NewService(reportRepo, reportEngine, cache, versionRepo)
+	reportHandler := report.NewHandler(reportService)
+	reports := r.Group("/api/v1/jmreport")
+	{
+		reports.GET("/list", reportHandler.List)
+		reports.GET("/get", reportHandler.Get)
+		reports.POST("/create", reportHandler.Create)
+		reports.POST("/update", reportHandler.Update)
+		reports.DELETE("/delete", reportHandler.Delete)
+		reports.POST("/preview", reportHandler.Preview)
+		reports.POST("/export", reportHandler.Export)
+		reports.GET("/parameters", reportHandler.Parameters)
+		reports.GET("/versions", reportHandler.Versions)
+		reports.GET("/version", reportHandler.Version)
+		reports.GET("/diff", reportHandler.Diff)
+		reports.POST("/publish", reportHandler.Publish)
+		reports.POST("/rollback", reportHandler.Rollback)
+	}
+
+	// 嵌入路由：令牌管理需要用户登录，嵌入接口只认嵌入令牌并按配置校验跨域来源
+	embedHandler := embed.NewHandler(embed.NewService(dashboardService, reportService, cfg.Embed))
+	embedTokens := r.Group("/api/v1/embed-tokens")
+	{
+		embedTokens.POST("", embedHandler.CreateToken)
+		embedTokens.POST("/revoke", embedHandler.RevokeToken)
+	}
+	embeds := r.Group(embed.PathPrefix, middleware.CORS(cfg.Embed.AllowedOrigins, auth.EmbedPasswordHeader))
+	embeds.OPTIONS("/*path", embedHandler.Preflight)
+	embedded := embeds.Group("", auth.EmbedMiddleware())
+	{
+		embedded.GET("/dashboard", embedHandler.Dashboard)
+		embedded.GET("/dashboard/data", embedHandler.DashboardData)
+		embedded.POST("/dashboard/data", embedHandler.DashboardData)
+		embedded.GET("/report", embedHandler.Report)
+		embedded.POST("/report/preview", embedHandler.Preview)
+	}
+
+	// 定时报表路由，调度轮询在 Run 时启动
+	scheduleRepo := schedule.NewRepository(db)
+	scheduleRunner := schedule.NewRunner(scheduleRepo, schedule.NewRenderer(reportService, dashboardService), map[string]schedule.Deliverer{
+		schedule.ChannelEmail:   schedule.NewEmailDeliverer(cfg.SMTP),
+		schedule.ChannelWebhook: schedule.NewWebhookDeliverer(nil),
+	}, cfg.Scheduler)
+	scheduleHandler := schedule.NewHandler(schedule.NewService(scheduleRepo, scheduleRunner))
+	schedules := r.Group("/api/v1/schedules")
+	{
+		schedules.GET("", scheduleHandler.List)
+		schedules.POST("", scheduleHandler.Create)
+		schedules.GET("/:id", scheduleHandler.Get)
+		schedules.PUT("/:id", scheduleHandler.Update)
+		schedules.DELETE("/:id", scheduleHandler.Delete)
+		schedules.POST("/:id/run", scheduleHandler.Run)
+		schedules.GET("/:id/runs", scheduleHandler.Runs)
+	}
+
 	srv := &http.Server{
 		Addr:    cfg.Server.Addr,
 		Handler: r,
@@ -160,20 +260,32 @@ func NewServer(cfg *config.Config, db *gorm.DB) (*Server, error) {
 		Engine: r,
 		Server: srv,
 		Cache:  cache,
+
+		Scheduler:        scheduleRunner,
+		schedulerEnabled: cfg.Scheduler.Enabled,
 	}, nil
 }
 
 // Run 启动 HTTP 服务器
 func (s *Server) Run(addr string) error {
 	s.Server.Addr = addr
+	if s.Scheduler != nil && s.schedulerEnabled {
+		s.Scheduler.Start()
+	}
 	return s.Server.ListenAndServe()
 }
 
 // Shutdown 关闭 HTTP 服务器
 func (s *Server) Shutdown(ctx context.Context) error {
+	if s.Scheduler != nil {
+		if err := s.Scheduler.Stop(ctx); err != nil {
+			log.Printf("scheduler did not stop in time: %v", err)
+		}
+	}
 	if s.Cache != nil {
 		_ = s.Cache.Close()
 	}
+	datasource.Pools().Close()
 	return s.Server.Shutdown(ctx)
 }
 
@@ -52,9 +52,32 @@ func (d *Dashboard) deserialize() error {
 }
 
 type DashboardConfig struct {
-	Width           int    `json:"width"`
-	Height          int    `json:"height"`
-	BackgroundColor string `json:"backgroundColor"`
+	Width           int               `json:"width"`
+	Height          int               `json:"height"`
+	BackgroundColor string            `json:"backgroundColor"`
+	Filters         []DashboardFilter `json:"filters,omitempty"`
+}
+
+// DashboardFilter is a global filter control: a date range, a dropdown
+// whose options come from a dataset field, or a search box. Each target
+// maps it onto a field of the dataset a component is bound to.
+type DashboardFilter struct {
+	ID      string              `json:"id"`
+	Label   string              `json:"label"`
+	Type    string              `json:"type"`
+	Options *FilterOptionSource `json:"options,omitempty"`
+	Default interface{}         `json:"default,omitempty"`
+	Targets []FilterTarget      `json:"targets"`
+}
+
+type FilterOptionSource struct {
+	DatasetID string `json:"datasetId"`
+	Field     string `json:"field"`
+}
+
+type FilterTarget struct {
+	ComponentID string `json:"componentId"`
+	Field       string `json:"field"`
 }
 
 type DashboardComponent struct {
@@ -73,19 +96,20 @@ type DashboardComponent struct {
 }
 
 type Dashboard struct {
-	ID             string               `gorm:"primaryKey;type:varchar(36)" json:"id"`
-	TenantID       string               `gorm:"index;type:varchar(36)" json:"tenantId"`
-	Name           string               `gorm:"type:varchar(200)" json:"name"`
-	Code           string               `gorm:"type:varchar(100)" json:"code"`
-	Config         DashboardConfig      `gorm:"-" json:"config"`
-	ConfigJSON     string               `gorm:"type:json;column:config" json:"-"`
-	Components     []DashboardComponent `gorm:"-" json:"components"`
-	ComponentsJSON string               `gorm:"type:json;column:components" json:"-"`
-	Thumbnail      string               `gorm:"type:varchar(500)" json:"thumbnail"`
-	Status         int                  `gorm:"type:tinyint" json:"status"`
-	ViewCount      int                  `gorm:"type:int" json:"viewCount"`
-	CreatedBy      string               `gorm:"type:varchar(36)" json:"createdBy"`
-	CreatedAt      time.Time            `json:"createdAt"`
-	UpdatedAt      time.Time            `json:"updatedAt"`
-	DeletedAt      gorm.DeletedAt       `gorm:"index" json:"-"`
+	ID               string               `gorm:"primaryKey;type:varchar(36)" json:"id"`
+	TenantID         string               `gorm:"index;type:varchar(36)" json:"tenantId"`
+	Name             string               `gorm:"type:varchar(200)" json:"name"`
+	Code             string               `gorm:"type:varchar(100)" json:"code"`
+	Config           DashboardConfig      `gorm:"-" json:"config"`
+	ConfigJSON       string               `gorm:"type:json;column:config" json:"-"`
+	Components       []DashboardComponent `gorm:"-" json:"components"`
+	ComponentsJSON   string               `gorm:"type:json;column:components" json:"-"`
+	Thumbnail        string               `gorm:"type:varchar(500)" json:"thumbnail"`
+	Status           int                  `gorm:"type:tinyint" json:"status"`
+	PublishedVersion int                  `gorm:"type:int" json:"publishedVersion"`
+	ViewCount        int                  `gorm:"type:int" json:"viewCount"`
+	CreatedBy        string               `gorm:"type:varchar(36)" json:"createdBy"`
+	CreatedAt        time.Time            `json:"createdAt"`
+	UpdatedAt        time.Time            `json:"updatedAt"`
+	DeletedAt        gorm.DeletedAt       `gorm:"index" json:"-"`
 }
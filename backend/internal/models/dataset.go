@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"gorm.io/gorm"
@@ -22,8 +23,39 @@ type Dataset struct {
 
 	Fields  []DatasetField  `gorm:"foreignKey:DatasetID" json:"fields,omitempty"`
 	Sources []DatasetSource `gorm:"foreignKey:DatasetID" json:"sources,omitempty"`
+
+	// DrillPaths are saved only when set, so a partial update keeps them.
+	DrillPaths     []DrillPath `gorm:"-" json:"drillPaths,omitempty"`
+	DrillPathsJSON *string     `gorm:"column:drill_paths;type:json" json:"-"`
+}
+
+// DrillPath is a hierarchy of dimension fields, from the coarsest down, such
+// as region, city, store. Charts drill from one level to the next.
+type DrillPath struct {
+	Name   string   `json:"name"`
+	Fields []string `json:"fields"`
 }
 
 func (Dataset) TableName() string {
 	return "datasets"
 }
+
+func (d *Dataset) BeforeSave(tx *gorm.DB) error {
+	if d.DrillPaths == nil {
+		return nil
+	}
+	paths, err := json.Marshal(d.DrillPaths)
+	if err != nil {
+		return err
+	}
+	encoded := string(paths)
+	d.DrillPathsJSON = &encoded
+	return nil
+}
+
+func (d *Dataset) AfterFind(tx *gorm.DB) error {
+	if d.DrillPathsJSON == nil || *d.DrillPathsJSON == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(*d.DrillPathsJSON), &d.DrillPaths)
+}
@@ -59,3 +59,23 @@ func TestDatasetSource_BasicProperties(t *testing.T) {
 		t.Errorf("Expected joinType 'inner', got '%s'", s.JoinType)
 	}
 }
+
+func TestDataset_DrillPathsRoundTrip(t *testing.T) {
+	d := &Dataset{}
+	if err := d.BeforeSave(nil); err != nil || d.DrillPathsJSON != nil {
+		t.Fatalf("Expected unset drill paths to stay unsaved, got %v, %v", d.DrillPathsJSON, err)
+	}
+
+	d.DrillPaths = []DrillPath{{Name: "geo", Fields: []string{"region", "city", "store"}}}
+	if err := d.BeforeSave(nil); err != nil {
+		t.Fatalf("BeforeSave() error = %v", err)
+	}
+
+	loaded := &Dataset{DrillPathsJSON: d.DrillPathsJSON}
+	if err := loaded.AfterFind(nil); err != nil {
+		t.Fatalf("AfterFind() error = %v", err)
+	}
+	if len(loaded.DrillPaths) != 1 || loaded.DrillPaths[0].Name != "geo" || len(loaded.DrillPaths[0].Fields) != 3 {
+		t.Errorf("Expected the geo drill path, got %+v", loaded.DrillPaths)
+	}
+}
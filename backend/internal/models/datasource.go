@@ -5,6 +5,8 @@ import (
 	"time"
 )
 
+// DataSource is an external connection. Password, SSHPassword, SSHKey and
+// SSHKeyPhrase are encrypted at rest by the repository and never serialized.
 type DataSource struct {
 	ID           string `gorm:"primaryKey;type:varchar(36)" json:"id"`
 	Name         string `gorm:"type:varchar(100)" json:"name"`
@@ -14,7 +16,7 @@ type DataSource struct {
 	Database     string `gorm:"column:database;type:varchar(100)" json:"database"`
 	DatabaseName string `gorm:"column:database_name;type:varchar(100)" json:"databaseName"`
 	Username     string `gorm:"type:varchar(100)" json:"username"`
-	Password     string `gorm:"type:varchar(255)" json:"-"`
+	Password     string `gorm:"type:text" json:"-"`
 	TenantID     string `gorm:"column:tenant_id;index;type:varchar(36)" json:"tenantId"`
 	CreatedBy    string `gorm:"column:created_by;type:varchar(36)" json:"createdBy"`
 
@@ -26,9 +28,9 @@ type DataSource struct {
 	SSHHost      string `gorm:"column:ssh_host;type:varchar(255)" json:"sshHost,omitempty"`
 	SSHPort      int    `gorm:"column:ssh_port;type:int" json:"sshPort,omitempty"`
 	SSHUsername  string `gorm:"column:ssh_username;type:varchar(100)" json:"sshUsername,omitempty"`
-	SSHPassword  string `gorm:"column:ssh_password;type:varchar(255)" json:"-"`
-	SSHKey       string `gorm:"column:ssh_key;type:text" json:"sshKey,omitempty"`
-	SSHKeyPhrase string `gorm:"column:ssh_key_phrase;type:varchar(255)" json:"sshKeyPhrase,omitempty"`
+	SSHPassword  string `gorm:"column:ssh_password;type:text" json:"-"`
+	SSHKey       string `gorm:"column:ssh_key;type:text" json:"-"`
+	SSHKeyPhrase string `gorm:"column:ssh_key_phrase;type:text" json:"-"`
 
 	// Runtime Controls
 	MaxConnections      int `gorm:"column:max_connections;type:int;default:10" json:"maxConnections,omitempty"`
@@ -3,18 +3,24 @@ package render
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 
+	"github.com/gujiaweiguo/goreport/internal/dataset"
+	"github.com/gujiaweiguo/goreport/internal/datasource"
 	"github.com/gujiaweiguo/goreport/internal/models"
-	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 )
 
 func (e *Engine) fetchCellValue(ctx context.Context, cell Cell, tenantID string) (string, error) {
-	var ds models.DataSource
-	if err := e.db.WithContext(ctx).Where("id = ?", *cell.DatasourceID).First(&ds).Error; err != nil {
+	found, err := e.datasources.GetByID(ctx, *cell.DatasourceID)
+	if err != nil {
 		return "", err
 	}
+	if found.TenantID != tenantID {
+		return "", gorm.ErrRecordNotFound
+	}
+	ds := *found
 
 	if e.cache == nil {
 		return e.fetchCellValueFromDB(ctx, cell, ds)
@@ -48,28 +54,20 @@ func (e *Engine) fetchCellValue(ctx context.Context, cell Cell, tenantID string)
 }
 
 func (e *Engine) fetchCellValueFromDB(ctx context.Context, cell Cell, ds models.DataSource) (string, error) {
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local",
-		ds.Username,
-		ds.Password,
-		ds.Host,
-		ds.Port,
-		ds.Database,
-	)
-
-	dataDB, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	pool, err := e.pools.Acquire(ctx, &ds)
 	if err != nil {
 		return "", err
 	}
-
-	sqlDB, err := dataDB.DB()
-	if err == nil {
-		defer sqlDB.Close()
-	}
-
-	query := fmt.Sprintf("SELECT `%s` FROM `%s` LIMIT 1", *cell.FieldName, *cell.TableName)
-	rows, err := dataDB.WithContext(ctx).Raw(query).Rows()
+	defer pool.Release()
+	queryCtx, cancel := pool.WithTimeout(ctx, datasource.DefaultQueryTimeout)
+	defer cancel()
+
+	query := pool.Dialect.Limit(fmt.Sprintf("SELECT %s FROM %s",
+		pool.Dialect.QuoteIdentifier(*cell.FieldName),
+		pool.Dialect.QuoteIdentifier(*cell.TableName)), 1, 0)
+	rows, err := pool.DB.QueryContext(queryCtx, query)
 	if err != nil {
-		return "", err
+		return "", pool.Check(err)
 	}
 	defer rows.Close()
 
@@ -99,3 +97,16 @@ func (e *Engine) fetchCellValueFromDB(ctx context.Context, cell Cell, ds models.
 
 	return "", nil
 }
+
+// ErrNoQueryExecutor is returned when a report binds cells to a dataset but
+// the engine was built without a dataset query executor.
+var ErrNoQueryExecutor = errors.New("dataset queries are not available")
+
+// queryDataset fetches every record of a dataset matching req, keyed by
+// field name. Export enforces the tenant and the export row limit.
+func (e *Engine) queryDataset(ctx context.Context, tenantID string, req *dataset.QueryRequest) ([]map[string]interface{}, error) {
+	if e.queryExecutor == nil {
+		return nil, ErrNoQueryExecutor
+	}
+	return dataset.QueryRecords(ctx, e.queryExecutor, tenantID, req)
+}
@@ -73,7 +73,7 @@ func TestEngine_FetchCellValueFromDB_Success(t *testing.T) {
 		db.Exec("DELETE FROM data_sources WHERE id = ?", datasourceID)
 	})
 
-	engine := NewEngine(db, nil)
+	engine := NewEngine(db, nil, nil)
 	// Query the database field from data_sources (should always have data)
 	tableName := "data_sources"
 	fieldName := "database"
@@ -126,7 +126,7 @@ func TestEngine_FetchCellValueFromDB_EmptyResult(t *testing.T) {
 		db.Exec("DELETE FROM data_sources WHERE id = ?", datasourceID)
 	})
 
-	engine := NewEngine(db, nil)
+	engine := NewEngine(db, nil, nil)
 
 	// Query a non-existent table
 	tableName := "non_existent_table_xyz"
@@ -180,7 +180,7 @@ func TestEngine_FetchCellValueFromDB_NullField(t *testing.T) {
 		db.Exec("DELETE FROM data_sources WHERE id = ?", datasourceID)
 	})
 
-	engine := NewEngine(db, nil)
+	engine := NewEngine(db, nil, nil)
 
 	// Query deleted_at which is typically NULL for most records
 	tableName := "data_sources"
@@ -235,7 +235,7 @@ func TestEngine_FetchCellValue_Integration(t *testing.T) {
 		db.Exec("DELETE FROM data_sources WHERE id = ?", datasourceID)
 	})
 
-	engine := NewEngine(db, nil)
+	engine := NewEngine(db, nil, nil)
 
 	tableName := "data_sources"
 	fieldName := "type"
@@ -274,7 +274,7 @@ func TestEngine_FetchCellValue_DataSourceNotFound(t *testing.T) {
 		FieldName:    &fieldName,
 	}
 
-	engine := NewEngine(db, nil)
+	engine := NewEngine(db, nil, nil)
 
 	value, err := engine.fetchCellValue(ctx, cell, tenantID)
 
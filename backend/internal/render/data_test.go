@@ -38,7 +38,7 @@ func TestEngine_Render_WithDatabase(t *testing.T) {
 	cacheCfg := config.CacheConfig{Enabled: false}
 	c, _ := cache.New(cacheCfg)
 
-	engine := NewEngine(db, c)
+	engine := NewEngine(db, c, nil)
 	ctx := context.Background()
 
 	t.Run("render simple config", func(t *testing.T) {
@@ -128,7 +128,7 @@ func TestEngine_Render_WithDatabase(t *testing.T) {
 
 func TestEngine_WithNilDependencies(t *testing.T) {
 	t.Run("with nil db and cache", func(t *testing.T) {
-		engine := NewEngine(nil, nil)
+		engine := NewEngine(nil, nil, nil)
 		assert.NotNil(t, engine)
 		assert.Nil(t, engine.db)
 		assert.Nil(t, engine.cache)
@@ -0,0 +1,262 @@
+package render
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// docxCharWidth approximates the width of one display column of 9pt text in
+// millimetres; Word lays out the text itself, so column widths only need to
+// be in proportion.
+const docxCharWidth = 1.6
+
+const docxNamespaces = `xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" ` +
+	`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"`
+
+var docxStaticParts = []struct{ name, content string }{
+	{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/>` +
+		`<Override PartName="/word/header1.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.header+xml"/>` +
+		`<Override PartName="/word/footer1.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.footer+xml"/>` +
+		`</Types>`},
+	{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="word/document.xml"/>` +
+		`</Relationships>`},
+	{"word/_rels/document.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rIdHeader" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/header" Target="header1.xml"/>` +
+		`<Relationship Id="rIdFooter" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/footer" Target="footer1.xml"/>` +
+		`</Relationships>`},
+	{"word/footer1.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:ftr ` + docxNamespaces + `><w:p><w:pPr><w:jc w:val="center"/></w:pPr>` +
+		`<w:fldSimple w:instr=" PAGE "><w:r><w:t>1</w:t></w:r></w:fldSimple>` +
+		`<w:r><w:t xml:space="preserve"> / </w:t></w:r>` +
+		`<w:fldSimple w:instr=" NUMPAGES "><w:r><w:t>1</w:t></w:r></w:fldSimple>` +
+		`</w:p></w:ftr>`},
+}
+
+// WriteDOCX writes the grid as a Word document holding a single table on
+// pages set up from the grid's print settings. The header rows repeat on
+// every page Word breaks the table across, the page header shows the
+// report title and the footer shows "page / total".
+func WriteDOCX(w io.Writer, grid *Grid) error {
+	settings, err := NormalizePrintConfig(grid.Print)
+	if err != nil {
+		return err
+	}
+
+	archive := zip.NewWriter(w)
+	for _, part := range docxStaticParts {
+		if err := writeZipPart(archive, part.name, part.content); err != nil {
+			return err
+		}
+	}
+	header := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:hdr ` + docxNamespaces + `><w:p><w:pPr><w:jc w:val="center"/></w:pPr>` + docxRuns(grid.Title, nil, false) + `</w:p></w:hdr>`
+	if err := writeZipPart(archive, "word/header1.xml", header); err != nil {
+		return err
+	}
+	if err := writeZipPart(archive, "word/document.xml", docxDocument(grid, settings)); err != nil {
+		return err
+	}
+	return archive.Close()
+}
+
+func writeZipPart(archive *zip.Writer, name, content string) error {
+	part, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(part, content)
+	return err
+}
+
+func docxDocument(grid *Grid, settings PrintConfig) string {
+	width, height := pageDimensions(settings)
+	headerRows := settings.HeaderRows
+	if headerRows > len(grid.Rows) {
+		headerRows = len(grid.Rows)
+	}
+	widths := columnWidths(grid, docxMeasurer{}, width-2*pageMargin)
+
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>`)
+	b.WriteString(`<w:document ` + docxNamespaces + `><w:body><w:tbl>`)
+	b.WriteString(`<w:tblPr><w:tblW w:w="0" w:type="auto"/><w:tblBorders>`)
+	for _, side := range []string{"top", "left", "bottom", "right", "insideH", "insideV"} {
+		fmt.Fprintf(&b, `<w:%s w:val="single" w:sz="4" w:space="0" w:color="000000"/>`, side)
+	}
+	b.WriteString(`</w:tblBorders><w:tblLayout w:type="fixed"/></w:tblPr><w:tblGrid>`)
+	for _, width := range widths {
+		fmt.Fprintf(&b, `<w:gridCol w:w="%d"/>`, twips(width))
+	}
+	b.WriteString(`</w:tblGrid>`)
+
+	for r, row := range grid.Rows {
+		header := r < headerRows
+		b.WriteString(`<w:tr>`)
+		if height := grid.rowHeight(r); header || height > 0 {
+			b.WriteString(`<w:trPr>`)
+			if header {
+				b.WriteString(`<w:tblHeader/>`)
+			}
+			if height > 0 {
+				fmt.Fprintf(&b, `<w:trHeight w:val="%d" w:hRule="atLeast"/>`, twips(pxToMM(height)))
+			}
+			b.WriteString(`</w:trPr>`)
+		}
+		for c := 0; c < len(row); {
+			cell := &row[c]
+			merge := ""
+			if cell.Merged {
+				anchor := cell.anchor
+				if anchor[0] == r {
+					c++
+					continue
+				}
+				// Rows below the first of a merged range continue it.
+				cell, merge = &grid.Rows[anchor[0]][anchor[1]], `<w:vMerge/>`
+			} else if cell.rowSpan() > 1 {
+				merge = `<w:vMerge w:val="restart"/>`
+			}
+			span := min(cell.colSpan(), len(row)-c)
+			fmt.Fprintf(&b, `<w:tc><w:tcPr><w:tcW w:w="%d" w:type="dxa"/>`, twips(sumFloats(widths[c:c+span])))
+			if span > 1 {
+				fmt.Fprintf(&b, `<w:gridSpan w:val="%d"/>`, span)
+			}
+			b.WriteString(merge)
+			b.WriteString(docxCellProperties(cell.Style, header))
+			b.WriteString(`</w:tcPr><w:p>`)
+			if align := cell.Style.align(); align != "left" {
+				fmt.Fprintf(&b, `<w:pPr><w:jc w:val="%s"/></w:pPr>`, align)
+			}
+			if merge != `<w:vMerge/>` {
+				b.WriteString(docxRuns(cell.Text, cell.Style, header))
+			}
+			b.WriteString(`</w:p></w:tc>`)
+			c += span
+		}
+		b.WriteString(`</w:tr>`)
+	}
+	b.WriteString(`</w:tbl><w:p/>`)
+
+	b.WriteString(`<w:sectPr><w:headerReference w:type="default" r:id="rIdHeader"/><w:footerReference w:type="default" r:id="rIdFooter"/>`)
+	orient := ""
+	if settings.Orientation == "landscape" {
+		orient = ` w:orient="landscape"`
+	}
+	fmt.Fprintf(&b, `<w:pgSz w:w="%d" w:h="%d"%s/>`, twips(width), twips(height), orient)
+	margin := twips(pageMargin)
+	fmt.Fprintf(&b, `<w:pgMar w:top="%d" w:right="%d" w:bottom="%d" w:left="%d" w:header="%d" w:footer="%d" w:gutter="0"/>`,
+		margin, margin, margin, margin, margin/2, margin/2)
+	b.WriteString(`</w:sectPr></w:body></w:document>`)
+	return b.String()
+}
+
+// docxCellProperties renders a cell's borders, shading and vertical
+// alignment. Cells without a style keep the table borders.
+func docxCellProperties(style *CellStyle, header bool) string {
+	var b strings.Builder
+	if style != nil {
+		b.WriteString(`<w:tcBorders>`)
+		border, ok := style.border()
+		for _, side := range []string{"top", "left", "bottom", "right"} {
+			if !ok {
+				fmt.Fprintf(&b, `<w:%s w:val="nil"/>`, side)
+				continue
+			}
+			val := "single"
+			if border.dashed {
+				val = "dashed"
+			} else if border.dotted {
+				val = "dotted"
+			}
+			// Border sizes are eighths of a point.
+			size := max(int(math.Round(border.width*72/25.4*8)), 2)
+			fmt.Fprintf(&b, `<w:%s w:val="%s" w:sz="%d" w:space="0" w:color="%s"/>`, side, val, size, hexColor(border.color))
+		}
+		b.WriteString(`</w:tcBorders>`)
+	}
+	if fill, ok := style.background(); ok {
+		fmt.Fprintf(&b, `<w:shd w:val="clear" w:color="auto" w:fill="%s"/>`, hexColor(fill))
+	} else if header {
+		b.WriteString(`<w:shd w:val="clear" w:color="auto" w:fill="EBEBEB"/>`)
+	}
+	vAlign := map[string]string{"top": "top", "middle": "center", "bottom": "bottom"}[style.verticalAlign()]
+	fmt.Fprintf(&b, `<w:vAlign w:val="%s"/>`, vAlign)
+	return b.String()
+}
+
+// docxRuns renders text as runs in the cell's font, turning newlines into
+// line breaks. Header text is bold.
+func docxRuns(text string, style *CellStyle, header bool) string {
+	if text == "" {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString(`<w:r>`)
+	if properties := docxRunProperties(style, header); properties != "" {
+		b.WriteString(`<w:rPr>` + properties + `</w:rPr>`)
+	}
+	for i, line := range strings.Split(text, "\n") {
+		if i > 0 {
+			b.WriteString(`<w:br/>`)
+		}
+		b.WriteString(`<w:t xml:space="preserve">`)
+		_ = xml.EscapeText(&b, []byte(line))
+		b.WriteString(`</w:t>`)
+	}
+	b.WriteString(`</w:r>`)
+	return b.String()
+}
+
+func docxRunProperties(style *CellStyle, header bool) string {
+	var b strings.Builder
+	if style != nil && style.FontFamily != "" {
+		family := strings.TrimSpace(strings.Trim(strings.Split(style.FontFamily, ",")[0], ` "'`))
+		b.WriteString(`<w:rFonts w:ascii="`)
+		_ = xml.EscapeText(&b, []byte(family))
+		b.WriteString(`" w:hAnsi="`)
+		_ = xml.EscapeText(&b, []byte(family))
+		b.WriteString(`" w:eastAsia="`)
+		_ = xml.EscapeText(&b, []byte(family))
+		b.WriteString(`"/>`)
+	}
+	font := style.font()
+	if header || font.bold {
+		b.WriteString(`<w:b/>`)
+	}
+	if font.italic {
+		b.WriteString(`<w:i/>`)
+	}
+	if style != nil {
+		if c, ok := parseColor(style.Color); ok {
+			fmt.Fprintf(&b, `<w:color w:val="%s"/>`, hexColor(c))
+		}
+		if style.FontSize > 0 {
+			// Font sizes are half points.
+			fmt.Fprintf(&b, `<w:sz w:val="%d"/>`, int(math.Round(font.size*2)))
+		}
+	}
+	return b.String()
+}
+
+// twips converts millimetres to the twentieths of a point Word measures in.
+func twips(mm float64) int {
+	return int(math.Round(mm * 1440 / 25.4))
+}
+
+// docxMeasurer estimates text width from display columns.
+type docxMeasurer struct{}
+
+func (docxMeasurer) textWidth(text string, font fontSpec) float64 {
+	return displayWidth(text) * docxCharWidth * font.size / fontSizePt
+}
@@ -0,0 +1,76 @@
+package render
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readDOCXPart(t *testing.T, doc []byte, name string) string {
+	t.Helper()
+	archive, err := zip.NewReader(bytes.NewReader(doc), int64(len(doc)))
+	require.NoError(t, err)
+	part, err := archive.Open(name)
+	require.NoError(t, err)
+	defer part.Close()
+	content, err := io.ReadAll(part)
+	require.NoError(t, err)
+	return string(content)
+}
+
+func TestWriteDOCX(t *testing.T) {
+	grid := &Grid{
+		Title: "Sales <Q1>",
+		Rows: textRows([][]string{
+			{"Region", "Amount"},
+			{"east & west", "120"},
+			{"line one\nline two", ""},
+		}),
+		Print: PrintConfig{Orientation: "landscape", HeaderRows: 1},
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, WriteDOCX(&out, grid))
+
+	document := readDOCXPart(t, out.Bytes(), "word/document.xml")
+	assert.Equal(t, 3, strings.Count(document, "<w:tr>"))
+	assert.Equal(t, 1, strings.Count(document, "<w:tblHeader/>"), "header rows repeat across pages")
+	assert.Contains(t, document, "east &amp; west")
+	assert.Contains(t, document, `line one</w:t><w:br/><w:t xml:space="preserve">line two`)
+	assert.Contains(t, document, `<w:pgSz w:w="16838" w:h="11906" w:orient="landscape"/>`)
+	assert.Equal(t, 2, strings.Count(document, "<w:gridCol "))
+
+	header := readDOCXPart(t, out.Bytes(), "word/header1.xml")
+	assert.Contains(t, header, "Sales &lt;Q1&gt;")
+	footer := readDOCXPart(t, out.Bytes(), "word/footer1.xml")
+	assert.Contains(t, footer, `w:instr=" PAGE "`)
+	assert.Contains(t, footer, `w:instr=" NUMPAGES "`)
+	types := readDOCXPart(t, out.Bytes(), "[Content_Types].xml")
+	assert.Contains(t, types, "wordprocessingml.document.main+xml")
+
+	grid.Print = PrintConfig{PageSize: "b9"}
+	assert.ErrorIs(t, WriteDOCX(&bytes.Buffer{}, grid), ErrInvalidPrintConfig)
+}
+
+func TestWriteDOCX_StylesAndMerges(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, WriteDOCX(&out, styledGrid()))
+	document := readDOCXPart(t, out.Bytes(), "word/document.xml")
+
+	assert.Contains(t, document, `<w:gridSpan w:val="3"/>`)
+	assert.Contains(t, document, `<w:shd w:val="clear" w:color="auto" w:fill="1F4E79"/>`)
+	assert.Contains(t, document, `<w:jc w:val="center"/>`)
+	assert.Contains(t, document, `<w:rPr><w:b/><w:color w:val="FFFFFF"/><w:sz w:val="30"/></w:rPr>`)
+	assert.Contains(t, document, `<w:vMerge w:val="restart"/>`)
+	assert.Equal(t, 1, strings.Count(document, `<w:vMerge/>`), "the row below continues the merged cell")
+	assert.Contains(t, document, `<w:vAlign w:val="top"/>`)
+	assert.Contains(t, document, `<w:top w:val="dashed" w:sz="5" w:space="0" w:color="FF0000"/>`)
+	assert.Contains(t, document, `<w:trHeight w:val="900" w:hRule="atLeast"/>`)
+	assert.Contains(t, document, "¥2,000.00")
+	assert.Equal(t, 1, strings.Count(document, "Quarterly sales"))
+}
@@ -2,28 +2,168 @@ package render
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
 
 	"github.com/gujiaweiguo/goreport/internal/cache"
+	"github.com/gujiaweiguo/goreport/internal/dataset"
+	"github.com/gujiaweiguo/goreport/internal/datasource"
+	"github.com/gujiaweiguo/goreport/internal/repository"
 	"gorm.io/gorm"
 )
 
 type Engine struct {
-	db    *gorm.DB
-	cache *cache.Cache
+	db            *gorm.DB
+	cache         *cache.Cache
+	pools         *datasource.PoolManager
+	datasources   repository.DatasourceRepository
+	queryExecutor dataset.QueryExecutor
 }
 
-func NewEngine(db *gorm.DB, cache *cache.Cache) *Engine {
+// NewEngine creates a report engine. queryExecutor runs the dataset queries
+// of list regions and dataset-bound cells; it may be nil for reports that
+// use neither.
+func NewEngine(db *gorm.DB, cache *cache.Cache, queryExecutor dataset.QueryExecutor) *Engine {
 	return &Engine{
-		db:    db,
-		cache: cache,
+		db:            db,
+		cache:         cache,
+		pools:         datasource.Pools(),
+		datasources:   repository.NewDatasourceRepository(db),
+		queryExecutor: queryExecutor,
 	}
 }
 
 func (e *Engine) Render(ctx context.Context, configJSON string, params map[string]interface{}, tenantID string) (string, error) {
+	config, cellValues, err := e.resolveCells(ctx, configJSON, params, tenantID)
+	if err != nil {
+		return "", err
+	}
+
+	page := 0
+	pageSize := 0
+	if params != nil {
+		if pageVal, ok := params["page"].(float64); ok {
+			page = int(pageVal)
+		}
+		if pageSizeVal, ok := params["pageSize"].(float64); ok {
+			pageSize = int(pageSizeVal)
+		}
+	}
+
+	return buildHTML(config, cellValues, page, pageSize), nil
+}
+
+// Page is one page of a report preview laid out on the report's paper.
+type Page struct {
+	HTML       string `json:"html"`
+	Page       int    `json:"page"`
+	TotalPages int    `json:"totalPages"`
+}
+
+// ErrPageOutOfRange is returned for a page past the end of the report.
+var ErrPageOutOfRange = errors.New("page out of range")
+
+// pagesCacheDomain holds rendered previews, every page of one rendering, so
+// fetching another page of the same report and parameters does not render
+// it again.
+const pagesCacheDomain = "report:pages"
+
+// RenderPage renders page, counted from 1, of the report laid out on the
+// paper of its print settings, with the header rows repeated on every page.
+func (e *Engine) RenderPage(ctx context.Context, configJSON string, params map[string]interface{}, tenantID string, page int) (*Page, error) {
+	pages, err := e.renderPages(ctx, configJSON, params, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	if page < 1 {
+		page = 1
+	}
+	if page > len(pages) {
+		return nil, fmt.Errorf("%w: page %d of %d", ErrPageOutOfRange, page, len(pages))
+	}
+	return &Page{HTML: pages[page-1], Page: page, TotalPages: len(pages)}, nil
+}
+
+func (e *Engine) renderPages(ctx context.Context, configJSON string, params map[string]interface{}, tenantID string) ([]string, error) {
+	// Paging parameters pick a page of the result, so they are not part
+	// of what is rendered.
+	key := make(map[string]interface{}, len(params))
+	for name, value := range params {
+		if name != "page" && name != "pageSize" {
+			key[name] = value
+		}
+	}
+	digest := sha256.Sum256([]byte(configJSON))
+	identity := hex.EncodeToString(digest[:])
+
+	if e.cache != nil {
+		if cached, hit, err := e.cache.Get(ctx, tenantID, pagesCacheDomain, identity, key); err == nil && hit {
+			var pages []string
+			if err := json.Unmarshal(cached, &pages); err == nil && len(pages) > 0 {
+				return pages, nil
+			}
+		}
+	}
+
+	grid, err := e.RenderGrid(ctx, configJSON, params, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	settings, err := NormalizePrintConfig(grid.Print)
+	if err != nil {
+		return nil, err
+	}
+	// HTML has no fonts to measure, so text is sized the way the DOCX
+	// export estimates it.
+	layout := layoutPages(grid, settings, docxMeasurer{})
+	pages := make([]string, len(layout.pages))
+	for page := range layout.pages {
+		pages[page] = buildPageHTML(layout, page)
+	}
+
+	if e.cache != nil {
+		if data, err := json.Marshal(pages); err == nil {
+			_ = e.cache.Set(ctx, tenantID, pagesCacheDomain, identity, key, data)
+		}
+	}
+	return pages, nil
+}
+
+// RenderGrid renders the whole report, unpaginated, as the cell grid the
+// exporters lay out. The grid carries the report's print settings.
+func (e *Engine) RenderGrid(ctx context.Context, configJSON string, params map[string]interface{}, tenantID string) (*Grid, error) {
+	config, cellValues, err := e.resolveCells(ctx, configJSON, params, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	return buildGrid(config, cellValues), nil
+}
+
+func parseConfig(configJSON string) (*ReportConfig, error) {
 	var config ReportConfig
 	if err := json.Unmarshal([]byte(configJSON), &config); err != nil {
-		return "", err
+		return nil, err
+	}
+	return &config, nil
+}
+
+// resolveCells binds the report's data. params holds the values of the
+// report's parameters, which are validated before any dataset is queried.
+func (e *Engine) resolveCells(ctx context.Context, configJSON string, params map[string]interface{}, tenantID string) (*ReportConfig, map[string]string, error) {
+	config, err := parseConfig(configJSON)
+	if err != nil {
+		return nil, nil, err
+	}
+	values, err := e.resolveParameters(ctx, config.Parameters, params, tenantID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := e.bindDatasets(ctx, config, values, tenantID); err != nil {
+		return nil, nil, err
 	}
 
 	cellValues := make(map[string]string)
@@ -40,17 +180,133 @@ func (e *Engine) Render(ctx context.Context, configJSON string, params map[strin
 		}
 		cellValues[cellKey(cell.Row, cell.Col)] = value
 	}
+	return config, cellValues, nil
+}
 
-	page := 0
-	pageSize := 0
-	if params != nil {
-		if pageVal, ok := params["page"].(float64); ok {
-			page = int(pageVal)
+// bindDatasets expands the list regions and pivots of config over their
+// datasets, filtered by the parameter values, and binds the dataset cells
+// outside them. Vertical regions and pivots are expanded bottom up, then
+// horizontal regions right to left, so expanding one never moves one still
+// to come.
+func (e *Engine) bindDatasets(ctx context.Context, config *ReportConfig, values map[string]interface{}, tenantID string) error {
+	if err := normalizeRegions(config.Regions); err != nil {
+		return err
+	}
+	if err := normalizePivots(config.Pivots, config.Regions); err != nil {
+		return err
+	}
+
+	type expansion struct {
+		line       int
+		horizontal bool
+		expand     func() error
+	}
+	var expansions []expansion
+	for i := range config.Regions {
+		region := &config.Regions[i]
+		filters, err := substituteFilters(region.Filters, config.Parameters, values)
+		if err != nil {
+			return err
 		}
-		if pageSizeVal, ok := params["pageSize"].(float64); ok {
-			pageSize = int(pageSizeVal)
+		region.Filters = filters
+		top, _ := region.span()
+		expansions = append(expansions, expansion{top, region.horizontal(), func() error {
+			return e.expandRegion(ctx, config, region, tenantID)
+		}})
+	}
+	for i := range config.Pivots {
+		pivot := &config.Pivots[i]
+		filters, err := substituteFilters(pivot.Filters, config.Parameters, values)
+		if err != nil {
+			return err
 		}
+		pivot.Filters = filters
+		expansions = append(expansions, expansion{pivot.Row, false, func() error {
+			records, err := e.queryDataset(ctx, tenantID, pivot.queryRequest())
+			if err != nil {
+				return err
+			}
+			config.Cells, config.Grid.RowHeights, err = expandPivot(config.Cells, config.Grid.RowHeights, pivot, records)
+			return err
+		}})
 	}
+	sort.SliceStable(expansions, func(i, j int) bool {
+		if expansions[i].horizontal != expansions[j].horizontal {
+			return !expansions[i].horizontal
+		}
+		return expansions[i].line > expansions[j].line
+	})
+	for _, expansion := range expansions {
+		if err := expansion.expand(); err != nil {
+			return err
+		}
+	}
+
+	datasetFields := make(map[string]map[string]bool)
+	for _, cell := range config.Cells {
+		if cell.DatasetID != nil && cell.FieldName != nil {
+			if datasetFields[*cell.DatasetID] == nil {
+				datasetFields[*cell.DatasetID] = make(map[string]bool)
+			}
+			datasetFields[*cell.DatasetID][*cell.FieldName] = true
+		}
+	}
+	for datasetID, fields := range datasetFields {
+		req := &dataset.QueryRequest{DatasetID: datasetID, Fields: sortedKeys(fields)}
+		records, err := e.queryDataset(ctx, tenantID, req)
+		if err != nil {
+			return err
+		}
+		for i := range config.Cells {
+			cell := &config.Cells[i]
+			if cell.DatasetID != nil && *cell.DatasetID == datasetID && cell.FieldName != nil {
+				bindCell(cell, *cell.FieldName, records, nil)
+			}
+		}
+	}
+	return nil
+}
 
-	return buildHTML(&config, cellValues, page, pageSize), nil
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// expandRegion queries the fields a list region's cells and groups use and
+// expands the region over the records.
+func (e *Engine) expandRegion(ctx context.Context, config *ReportConfig, region *Region, tenantID string) error {
+	top, bottom := region.span()
+	fields := make(map[string]bool)
+	for _, group := range region.Groups {
+		fields[group.Field] = true
+	}
+	for _, cell := range config.Cells {
+		line := cell.Row
+		if region.horizontal() {
+			line = cell.Col
+		}
+		if line >= top && line < bottom && cell.FieldName != nil && cell.DatasourceID == nil {
+			fields[*cell.FieldName] = true
+		}
+	}
+	records, err := e.queryDataset(ctx, tenantID, &dataset.QueryRequest{
+		DatasetID: region.DatasetID,
+		Fields:    sortedKeys(fields),
+		Filters:   region.Filters,
+		SortBy:    region.SortBy,
+		SortOrder: region.SortOrder,
+	})
+	if err != nil {
+		return err
+	}
+	if region.horizontal() {
+		config.Cells, config.Grid.ColumnWidths = expandRegion(config.Cells, config.Grid.ColumnWidths, region, records)
+	} else {
+		config.Cells, config.Grid.RowHeights = expandRegion(config.Cells, config.Grid.RowHeights, region, records)
+	}
+	return nil
 }
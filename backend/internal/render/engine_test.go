@@ -2,11 +2,14 @@ package render
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/gujiaweiguo/goreport/internal/cache"
 	"github.com/gujiaweiguo/goreport/internal/config"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 )
@@ -16,7 +19,7 @@ func TestNewEngine(t *testing.T) {
 	cfg := config.CacheConfig{Enabled: false}
 	cacheObj, _ := cache.New(cfg)
 
-	engine := NewEngine(db, cacheObj)
+	engine := NewEngine(db, cacheObj, nil)
 	assert.NotNil(t, engine)
 	assert.NotNil(t, engine.db)
 	assert.NotNil(t, engine.cache)
@@ -25,7 +28,7 @@ func TestNewEngine(t *testing.T) {
 func TestNewEngine_NilCache(t *testing.T) {
 	db, _ := gorm.Open(mysql.Open("user:pass@tcp(localhost:3306)/db"), &gorm.Config{})
 
-	engine := NewEngine(db, nil)
+	engine := NewEngine(db, nil, nil)
 	assert.NotNil(t, engine)
 	assert.NotNil(t, engine.db)
 	assert.Nil(t, engine.cache)
@@ -33,7 +36,7 @@ func TestNewEngine_NilCache(t *testing.T) {
 
 func TestEngine_Render_EmptyConfig(t *testing.T) {
 	db, _ := gorm.Open(mysql.Open("user:pass@tcp(localhost:3306)/db"), &gorm.Config{})
-	engine := NewEngine(db, nil)
+	engine := NewEngine(db, nil, nil)
 
 	html, err := engine.Render(context.Background(), `{"cells":[]}`, nil, "tenant-1")
 
@@ -45,7 +48,7 @@ func TestEngine_Render_EmptyConfig(t *testing.T) {
 
 func TestEngine_Render_InvalidJSON(t *testing.T) {
 	db, _ := gorm.Open(mysql.Open("user:pass@tcp(localhost:3306)/db"), &gorm.Config{})
-	engine := NewEngine(db, nil)
+	engine := NewEngine(db, nil, nil)
 
 	html, err := engine.Render(context.Background(), `{invalid json`, nil, "tenant-1")
 
@@ -55,7 +58,7 @@ func TestEngine_Render_InvalidJSON(t *testing.T) {
 
 func TestEngine_Render_StaticCells(t *testing.T) {
 	db, _ := gorm.Open(mysql.Open("user:pass@tcp(localhost:3306)/db"), &gorm.Config{})
-	engine := NewEngine(db, nil)
+	engine := NewEngine(db, nil, nil)
 
 	config := `{
 		"cells": [
@@ -80,7 +83,7 @@ func TestEngine_Render_StaticCells(t *testing.T) {
 
 func TestEngine_Render_CellWithValue(t *testing.T) {
 	db, _ := gorm.Open(mysql.Open("user:pass@tcp(localhost:3306)/db"), &gorm.Config{})
-	engine := NewEngine(db, nil)
+	engine := NewEngine(db, nil, nil)
 
 	config := `{
 		"cells": [
@@ -97,7 +100,7 @@ func TestEngine_Render_CellWithValue(t *testing.T) {
 
 func TestEngine_Render_CellWithTextNoValue(t *testing.T) {
 	db, _ := gorm.Open(mysql.Open("user:pass@tcp(localhost:3306)/db"), &gorm.Config{})
-	engine := NewEngine(db, nil)
+	engine := NewEngine(db, nil, nil)
 
 	config := `{
 		"cells": [
@@ -113,7 +116,7 @@ func TestEngine_Render_CellWithTextNoValue(t *testing.T) {
 
 func TestEngine_Render_WithPagination(t *testing.T) {
 	db, _ := gorm.Open(mysql.Open("user:pass@tcp(localhost:3306)/db"), &gorm.Config{})
-	engine := NewEngine(db, nil)
+	engine := NewEngine(db, nil, nil)
 
 	config := `{
 		"cells": [
@@ -146,7 +149,7 @@ func TestEngine_Render_WithPagination(t *testing.T) {
 
 func TestEngine_Render_WithInvalidPagination(t *testing.T) {
 	db, _ := gorm.Open(mysql.Open("user:pass@tcp(localhost:3306)/db"), &gorm.Config{})
-	engine := NewEngine(db, nil)
+	engine := NewEngine(db, nil, nil)
 
 	config := `{
 		"cells": [
@@ -168,7 +171,7 @@ func TestEngine_Render_WithInvalidPagination(t *testing.T) {
 func TestEngine_Render_CellWithDatasource_NoDatabase(t *testing.T) {
 	t.Skip("Requires actual database connection")
 	db, _ := gorm.Open(mysql.Open("user:pass@tcp(localhost:3306)/db"), &gorm.Config{})
-	engine := NewEngine(db, nil)
+	engine := NewEngine(db, nil, nil)
 
 	dsID := "ds-1"
 	tableName := "users"
@@ -187,7 +190,7 @@ func TestEngine_Render_CellWithDatasource_NoDatabase(t *testing.T) {
 
 func TestEngine_Render_CellWithMissingDatasourceFields(t *testing.T) {
 	db, _ := gorm.Open(mysql.Open("user:pass@tcp(localhost:3306)/db"), &gorm.Config{})
-	engine := NewEngine(db, nil)
+	engine := NewEngine(db, nil, nil)
 
 	dsID := "ds-1"
 	tableName := "users"
@@ -206,7 +209,7 @@ func TestEngine_Render_CellWithMissingDatasourceFields(t *testing.T) {
 
 func TestEngine_Render_ParamsInt(t *testing.T) {
 	db, _ := gorm.Open(mysql.Open("user:pass@tcp(localhost:3306)/db"), &gorm.Config{})
-	engine := NewEngine(db, nil)
+	engine := NewEngine(db, nil, nil)
 
 	config := `{
 		"cells": [
@@ -226,7 +229,7 @@ func TestEngine_Render_ParamsInt(t *testing.T) {
 
 func TestEngine_Render_NilParams(t *testing.T) {
 	db, _ := gorm.Open(mysql.Open("user:pass@tcp(localhost:3306)/db"), &gorm.Config{})
-	engine := NewEngine(db, nil)
+	engine := NewEngine(db, nil, nil)
 
 	config := `{
 		"cells": [
@@ -243,7 +246,7 @@ func TestEngine_Render_NilParams(t *testing.T) {
 func TestEngine_Render_MixedCells(t *testing.T) {
 	t.Skip("Requires actual database connection")
 	db, _ := gorm.Open(mysql.Open("user:pass@tcp(localhost:3306)/db"), &gorm.Config{})
-	engine := NewEngine(db, nil)
+	engine := NewEngine(db, nil, nil)
 
 	config := `{
 		"cells": [
@@ -266,7 +269,7 @@ func TestEngine_Render_MixedCells(t *testing.T) {
 func TestEngine_Render_LargeDataset(t *testing.T) {
 	t.Skip("Requires actual database connection")
 	db, _ := gorm.Open(mysql.Open("user:pass@tcp(localhost:3306)/db"), &gorm.Config{})
-	engine := NewEngine(db, nil)
+	engine := NewEngine(db, nil, nil)
 
 	var configJSON string
 	configJSON = `{"cells": [`
@@ -286,7 +289,7 @@ func TestEngine_Render_LargeDataset(t *testing.T) {
 
 func TestEngine_Render_EmptyRowCol(t *testing.T) {
 	db, _ := gorm.Open(mysql.Open("user:pass@tcp(localhost:3306)/db"), &gorm.Config{})
-	engine := NewEngine(db, nil)
+	engine := NewEngine(db, nil, nil)
 
 	config := `{
 		"cells": [
@@ -303,7 +306,7 @@ func TestEngine_Render_EmptyRowCol(t *testing.T) {
 
 func TestEngine_Render_ContextCancellation(t *testing.T) {
 	db, _ := gorm.Open(mysql.Open("user:pass@tcp(localhost:3306)/db"), &gorm.Config{})
-	engine := NewEngine(db, nil)
+	engine := NewEngine(db, nil, nil)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel()
@@ -356,7 +359,7 @@ func TestReportConfig_Fields(t *testing.T) {
 func TestEngine_Render_WithDatasourceCell(t *testing.T) {
 	t.Skip("Requires actual database connection")
 	db, _ := gorm.Open(mysql.Open("user:pass@tcp(localhost:3306)/db"), &gorm.Config{})
-	engine := NewEngine(db, nil)
+	engine := NewEngine(db, nil, nil)
 
 	dsID := "ds-1"
 	tableName := "users"
@@ -379,7 +382,7 @@ func TestEngine_Render_WithDatasourceCell(t *testing.T) {
 func TestEngine_Render_WithAllOptionalFields(t *testing.T) {
 	t.Skip("Requires actual database connection")
 	db, _ := gorm.Open(mysql.Open("user:pass@tcp(localhost:3306)/db"), &gorm.Config{})
-	engine := NewEngine(db, nil)
+	engine := NewEngine(db, nil, nil)
 
 	dsID := "ds-1"
 	tableName := "users"
@@ -408,7 +411,7 @@ func TestEngine_Render_WithAllOptionalFields(t *testing.T) {
 
 func TestEngine_Render_WithNegativePage(t *testing.T) {
 	db, _ := gorm.Open(mysql.Open("user:pass@tcp(localhost:3306)/db"), &gorm.Config{})
-	engine := NewEngine(db, nil)
+	engine := NewEngine(db, nil, nil)
 
 	config := `{
 		"cells": [
@@ -428,7 +431,7 @@ func TestEngine_Render_WithNegativePage(t *testing.T) {
 
 func TestEngine_Render_WithZeroPageSize(t *testing.T) {
 	db, _ := gorm.Open(mysql.Open("user:pass@tcp(localhost:3306)/db"), &gorm.Config{})
-	engine := NewEngine(db, nil)
+	engine := NewEngine(db, nil, nil)
 
 	config := `{
 		"cells": [
@@ -449,7 +452,7 @@ func TestEngine_Render_WithZeroPageSize(t *testing.T) {
 
 func TestEngine_Render_WithCacheNil(t *testing.T) {
 	db, _ := gorm.Open(mysql.Open("user:pass@tcp(localhost:3306)/db"), &gorm.Config{})
-	engine := NewEngine(db, nil)
+	engine := NewEngine(db, nil, nil)
 
 	// Verify cache is nil
 	assert.Nil(t, engine.cache)
@@ -470,7 +473,7 @@ func TestEngine_Render_WithCacheEnabled(t *testing.T) {
 	db, _ := gorm.Open(mysql.Open("user:pass@tcp(localhost:3306)/db"), &gorm.Config{})
 	cfg := config.CacheConfig{Enabled: false}
 	cacheObj, _ := cache.New(cfg)
-	engine := NewEngine(db, cacheObj)
+	engine := NewEngine(db, cacheObj, nil)
 
 	// Verify cache is not nil (but degraded since disabled)
 	assert.NotNil(t, engine.cache)
@@ -489,7 +492,7 @@ func TestEngine_Render_WithCacheEnabled(t *testing.T) {
 
 func TestEngine_Render_LargeRowCol(t *testing.T) {
 	db, _ := gorm.Open(mysql.Open("user:pass@tcp(localhost:3306)/db"), &gorm.Config{})
-	engine := NewEngine(db, nil)
+	engine := NewEngine(db, nil, nil)
 
 	config := `{
 		"cells": [
@@ -505,7 +508,7 @@ func TestEngine_Render_LargeRowCol(t *testing.T) {
 
 func TestEngine_Render_OnlyTextNoValue(t *testing.T) {
 	db, _ := gorm.Open(mysql.Open("user:pass@tcp(localhost:3306)/db"), &gorm.Config{})
-	engine := NewEngine(db, nil)
+	engine := NewEngine(db, nil, nil)
 
 	config := `{
 		"cells": [
@@ -521,7 +524,7 @@ func TestEngine_Render_OnlyTextNoValue(t *testing.T) {
 
 func TestEngine_Render_OnlyValueNoText(t *testing.T) {
 	db, _ := gorm.Open(mysql.Open("user:pass@tcp(localhost:3306)/db"), &gorm.Config{})
-	engine := NewEngine(db, nil)
+	engine := NewEngine(db, nil, nil)
 
 	config := `{
 		"cells": [
@@ -537,7 +540,7 @@ func TestEngine_Render_OnlyValueNoText(t *testing.T) {
 
 func TestEngine_Render_EmptyValueAndText(t *testing.T) {
 	db, _ := gorm.Open(mysql.Open("user:pass@tcp(localhost:3306)/db"), &gorm.Config{})
-	engine := NewEngine(db, nil)
+	engine := NewEngine(db, nil, nil)
 
 	config := `{
 		"cells": [
@@ -550,3 +553,59 @@ func TestEngine_Render_EmptyValueAndText(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Contains(t, html, "<td></td>")
 }
+
+func TestEngine_RenderGrid(t *testing.T) {
+	engine := NewEngine(nil, nil, nil)
+
+	config := `{
+		"cells": [
+			{"row": 0, "col": 0, "text": "Region"},
+			{"row": 0, "col": 2, "text": "Amount"},
+			{"row": 2, "col": 1, "value": "east", "text": "ignored"}
+		],
+		"print": {"pageSize": "A3", "orientation": "landscape", "headerRows": 1}
+	}`
+
+	grid, err := engine.RenderGrid(context.Background(), config, nil, "tenant-1")
+
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"Region", "", "Amount"},
+		{"", "", ""},
+		{"", "east", ""},
+	}, gridText(grid))
+	assert.Equal(t, 3, grid.Columns())
+	assert.Equal(t, PrintConfig{PageSize: "A3", Orientation: "landscape", HeaderRows: 1}, grid.Print)
+
+	_, err = engine.RenderGrid(context.Background(), `{invalid json`, nil, "tenant-1")
+	assert.Error(t, err)
+}
+
+func TestEngine_RenderPage(t *testing.T) {
+	engine := NewEngine(nil, nil, nil)
+	cells := []string{`{"row": 0, "col": 0, "text": "Header"}`}
+	for r := 1; r < 120; r++ {
+		cells = append(cells, fmt.Sprintf(`{"row": %d, "col": 0, "text": "Row %d"}`, r, r))
+	}
+	config := `{"print": {"headerRows": 1}, "cells": [` + strings.Join(cells, ",") + `]}`
+
+	first, err := engine.RenderPage(context.Background(), config, nil, "tenant-1", 0)
+	require.NoError(t, err)
+	assert.Equal(t, 1, first.Page)
+	assert.Equal(t, 4, first.TotalPages)
+	assert.Contains(t, first.HTML, ">Row 34<")
+	assert.NotContains(t, first.HTML, ">Row 35<")
+
+	second, err := engine.RenderPage(context.Background(), config, map[string]interface{}{"page": float64(2)}, "tenant-1", 2)
+	require.NoError(t, err)
+	assert.Equal(t, 2, second.Page)
+	assert.Contains(t, second.HTML, ">Header<")
+	assert.Contains(t, second.HTML, ">Row 35<")
+	assert.NotContains(t, second.HTML, ">Row 34<")
+
+	_, err = engine.RenderPage(context.Background(), config, nil, "tenant-1", 5)
+	assert.ErrorIs(t, err, ErrPageOutOfRange)
+
+	_, err = engine.RenderPage(context.Background(), `{"print": {"pageSize": "b9"}}`, nil, "tenant-1", 1)
+	assert.ErrorIs(t, err, ErrInvalidPrintConfig)
+}
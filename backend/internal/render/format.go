@@ -0,0 +1,182 @@
+package render
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// numberMask is a parsed number or currency mask such as "¥#,##0.00" or
+// "0.0%": literal prefix and suffix text around a digit pattern.
+type numberMask struct {
+	prefix      string
+	suffix      string
+	grouping    bool
+	minDecimals int
+	maxDecimals int
+	percent     bool
+}
+
+// dateMaskTokens maps date mask tokens to Go layout elements, longest first.
+var dateMaskTokens = []struct{ token, layout string }{
+	{"yyyy", "2006"}, {"yy", "06"},
+	{"MM", "01"}, {"M", "1"},
+	{"dd", "02"}, {"d", "2"},
+	{"HH", "15"}, {"hh", "03"},
+	{"mm", "04"}, {"ss", "05"},
+	{"a", "PM"},
+}
+
+// isDateMask reports whether mask formats dates rather than numbers.
+func isDateMask(mask string) bool {
+	return strings.ContainsAny(mask, "yMdHhs")
+}
+
+// formatValue renders a number or date with a format mask. It reports false
+// when the mask does not apply to the value, which is then shown as is.
+func formatValue(value interface{}, mask string) (string, bool) {
+	if mask == "" {
+		return "", false
+	}
+	if isDateMask(mask) {
+		t, ok := value.(time.Time)
+		if !ok {
+			return "", false
+		}
+		return t.Format(dateLayout(mask)), true
+	}
+	number, ok := toFloat(value)
+	if !ok {
+		return "", false
+	}
+	parsed, ok := parseNumberMask(mask)
+	if !ok {
+		return "", false
+	}
+	return parsed.format(number), true
+}
+
+func dateLayout(mask string) string {
+	var b strings.Builder
+	for i := 0; i < len(mask); {
+		matched := false
+		for _, t := range dateMaskTokens {
+			if strings.HasPrefix(mask[i:], t.token) {
+				b.WriteString(t.layout)
+				i += len(t.token)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			b.WriteByte(mask[i])
+			i++
+		}
+	}
+	return b.String()
+}
+
+func parseNumberMask(mask string) (numberMask, bool) {
+	start := strings.IndexAny(mask, "#0")
+	if start < 0 {
+		return numberMask{}, false
+	}
+	end := start
+	for end < len(mask) && strings.ContainsRune("#0,.", rune(mask[end])) {
+		end++
+	}
+	parsed := numberMask{prefix: mask[:start], suffix: mask[end:]}
+	pattern := mask[start:end]
+	integer, decimals, _ := strings.Cut(pattern, ".")
+	parsed.grouping = strings.Contains(integer, ",")
+	parsed.minDecimals = strings.Count(decimals, "0")
+	parsed.maxDecimals = parsed.minDecimals + strings.Count(decimals, "#")
+	parsed.percent = strings.Contains(parsed.suffix, "%")
+	return parsed, true
+}
+
+func (m numberMask) format(number float64) string {
+	if m.percent {
+		number *= 100
+	}
+	negative := number < 0
+	text := strconv.FormatFloat(math.Abs(number), 'f', m.maxDecimals, 64)
+	integer, decimals, _ := strings.Cut(text, ".")
+	decimals = strings.TrimRight(decimals, "0")
+	for len(decimals) < m.minDecimals {
+		decimals += "0"
+	}
+	if m.grouping {
+		integer = groupThousands(integer)
+	}
+	if decimals != "" {
+		integer += "." + decimals
+	}
+	if negative && strings.Trim(integer, "0.,") != "" {
+		return "-" + m.prefix + integer + m.suffix
+	}
+	return m.prefix + integer + m.suffix
+}
+
+func groupThousands(digits string) string {
+	if len(digits) <= 3 {
+		return digits
+	}
+	var b strings.Builder
+	lead := len(digits) % 3
+	if lead > 0 {
+		b.WriteString(digits[:lead])
+	}
+	for i := lead; i < len(digits); i += 3 {
+		if b.Len() > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(digits[i : i+3])
+	}
+	return b.String()
+}
+
+// excelNumberFormat translates a format mask to an Excel number format.
+func excelNumberFormat(mask string) string {
+	if isDateMask(mask) {
+		return strings.NewReplacer("MM", "mm", "M", "m", "HH", "hh", "a", "AM/PM").Replace(mask)
+	}
+	parsed, ok := parseNumberMask(mask)
+	if !ok {
+		return ""
+	}
+	pattern := mask[len(parsed.prefix) : len(mask)-len(parsed.suffix)]
+	return excelLiteral(parsed.prefix) + pattern + excelLiteral(parsed.suffix)
+}
+
+// excelLiteral quotes mask text so Excel shows it verbatim; a percent sign
+// keeps its meaning.
+func excelLiteral(text string) string {
+	if text == "" {
+		return ""
+	}
+	var b strings.Builder
+	for _, part := range strings.SplitAfter(text, "%") {
+		literal := strings.TrimSuffix(part, "%")
+		if literal != "" {
+			b.WriteString(`"` + strings.ReplaceAll(literal, `"`, `""`) + `"`)
+		}
+		if strings.HasSuffix(part, "%") {
+			b.WriteByte('%')
+		}
+	}
+	return b.String()
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	}
+	return 0, false
+}
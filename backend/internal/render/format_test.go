@@ -0,0 +1,52 @@
+package render
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatValue(t *testing.T) {
+	day := time.Date(2024, 3, 1, 8, 5, 9, 0, time.UTC)
+	tests := []struct {
+		value interface{}
+		mask  string
+		want  string
+	}{
+		{int64(1234567), "#,##0", "1,234,567"},
+		{1234.5, "#,##0.00", "1,234.50"},
+		{1234.5, "¥#,##0.00", "¥1,234.50"},
+		{-1234.5, "$#,##0.00", "-$1,234.50"},
+		{0.256, "0.0%", "25.6%"},
+		{2.5, "0.##", "2.5"},
+		{2.0, "0.##", "2"},
+		{-0.001, "0.00", "0.00"},
+		{12.0, "0.00 元", "12.00 元"},
+		{day, "yyyy-MM-dd", "2024-03-01"},
+		{day, "yyyy/M/d HH:mm:ss", "2024/3/1 08:05:09"},
+		{day, "yy年MM月dd日", "24年03月01日"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mask, func(t *testing.T) {
+			got, ok := formatValue(tt.value, tt.mask)
+			assert.True(t, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, ok := formatValue("east", "#,##0")
+	assert.False(t, ok, "text is shown as is")
+	_, ok = formatValue(int64(3), "yyyy-MM-dd")
+	assert.False(t, ok, "numbers ignore date masks")
+	_, ok = formatValue(int64(3), "")
+	assert.False(t, ok)
+}
+
+func TestExcelNumberFormat(t *testing.T) {
+	assert.Equal(t, `"¥"#,##0.00`, excelNumberFormat("¥#,##0.00"))
+	assert.Equal(t, `0.0%`, excelNumberFormat("0.0%"))
+	assert.Equal(t, `0.00" 元"`, excelNumberFormat("0.00 元"))
+	assert.Equal(t, "yyyy-mm-dd hh:mm", excelNumberFormat("yyyy-MM-dd HH:mm"))
+	assert.Equal(t, "", excelNumberFormat("text"))
+}
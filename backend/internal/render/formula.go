@@ -0,0 +1,438 @@
+package render
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// formulaError is a spreadsheet error value, shown in place of the result.
+type formulaError string
+
+func (e formulaError) Error() string { return string(e) }
+
+const (
+	errFormulaRef   formulaError = "#REF!"
+	errFormulaDiv0  formulaError = "#DIV/0!"
+	errFormulaValue formulaError = "#VALUE!"
+	errFormulaName  formulaError = "#NAME?"
+)
+
+// formulaSheet evaluates the formulas of a grid. Formulas use A1 references
+// to report cells: column letters from A and rows numbered from 1.
+type formulaSheet struct {
+	grid     *Grid
+	results  map[[2]int]float64
+	errors   map[[2]int]error
+	visiting map[[2]int]bool
+}
+
+func evaluateFormulas(grid *Grid) {
+	sheet := &formulaSheet{
+		grid:     grid,
+		results:  make(map[[2]int]float64),
+		errors:   make(map[[2]int]error),
+		visiting: make(map[[2]int]bool),
+	}
+	for r, row := range grid.Rows {
+		for c := range row {
+			cell := &grid.Rows[r][c]
+			if cell.Formula == "" {
+				continue
+			}
+			result, err := sheet.evaluate(r, c)
+			if err != nil {
+				cell.Value, cell.Text = nil, err.Error()
+				continue
+			}
+			cell.Value, cell.Text = result, formatNumber(result)
+		}
+	}
+}
+
+func (s *formulaSheet) evaluate(r, c int) (float64, error) {
+	key := [2]int{r, c}
+	if result, ok := s.results[key]; ok {
+		return result, nil
+	}
+	if err, ok := s.errors[key]; ok {
+		return 0, err
+	}
+	if s.visiting[key] {
+		return 0, errFormulaRef
+	}
+	s.visiting[key] = true
+	defer delete(s.visiting, key)
+
+	result, err := parseFormula(s, s.grid.Rows[r][c].Formula)
+	if err != nil {
+		s.errors[key] = err
+		return 0, err
+	}
+	s.results[key] = result
+	return result, nil
+}
+
+// value returns the number in a cell. Blank and text cells report false.
+func (s *formulaSheet) value(r, c int) (float64, bool, error) {
+	if r >= len(s.grid.Rows) || c >= len(s.grid.Rows[r]) {
+		return 0, false, nil
+	}
+	cell := &s.grid.Rows[r][c]
+	if cell.Formula != "" {
+		result, err := s.evaluate(r, c)
+		return result, err == nil, err
+	}
+	number, ok := toFloat(cell.Value)
+	return number, ok, nil
+}
+
+// numbers collects the numbers in a range, skipping blank and text cells.
+func (s *formulaSheet) numbers(from, to [2]int) ([]float64, error) {
+	r0, r1 := min(from[0], to[0]), max(from[0], to[0])
+	c0, c1 := min(from[1], to[1]), max(from[1], to[1])
+	r1 = min(r1, len(s.grid.Rows)-1)
+	var numbers []float64
+	for r := r0; r <= r1; r++ {
+		for c := c0; c <= min(c1, len(s.grid.Rows[r])-1); c++ {
+			number, ok, err := s.value(r, c)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				numbers = append(numbers, number)
+			}
+		}
+	}
+	return numbers, nil
+}
+
+// formulaParser is a recursive-descent evaluator for arithmetic over
+// numbers, cell references and the SUM, AVG, MIN, MAX and COUNT functions.
+type formulaParser struct {
+	sheet  *formulaSheet
+	tokens []string
+	pos    int
+}
+
+func parseFormula(sheet *formulaSheet, formula string) (float64, error) {
+	tokens, err := tokenizeFormula(strings.TrimPrefix(formula, "="))
+	if err != nil {
+		return 0, err
+	}
+	p := &formulaParser{sheet: sheet, tokens: tokens}
+	result, err := p.expression()
+	if err != nil {
+		return 0, err
+	}
+	if p.pos != len(p.tokens) {
+		return 0, errFormulaValue
+	}
+	if math.IsInf(result, 0) || math.IsNaN(result) {
+		return 0, errFormulaValue
+	}
+	return result, nil
+}
+
+func tokenizeFormula(formula string) ([]string, error) {
+	var tokens []string
+	runes := []rune(formula)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case strings.ContainsRune("+-*/(),:", r):
+			tokens = append(tokens, string(r))
+			i++
+		case unicode.IsDigit(r) || r == '.':
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, string(runes[start:i]))
+		case r == '$' || (r < unicode.MaxASCII && unicode.IsLetter(r)):
+			start := i
+			for i < len(runes) && runes[i] < unicode.MaxASCII && (runes[i] == '$' || unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i])) {
+				i++
+			}
+			tokens = append(tokens, strings.ToUpper(string(runes[start:i])))
+		default:
+			return nil, errFormulaValue
+		}
+	}
+	return tokens, nil
+}
+
+func (p *formulaParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *formulaParser) next() string {
+	token := p.peek()
+	p.pos++
+	return token
+}
+
+func (p *formulaParser) expression() (float64, error) {
+	result, err := p.term()
+	if err != nil {
+		return 0, err
+	}
+	for p.peek() == "+" || p.peek() == "-" {
+		op := p.next()
+		operand, err := p.term()
+		if err != nil {
+			return 0, err
+		}
+		if op == "+" {
+			result += operand
+		} else {
+			result -= operand
+		}
+	}
+	return result, nil
+}
+
+func (p *formulaParser) term() (float64, error) {
+	result, err := p.factor()
+	if err != nil {
+		return 0, err
+	}
+	for p.peek() == "*" || p.peek() == "/" {
+		op := p.next()
+		operand, err := p.factor()
+		if err != nil {
+			return 0, err
+		}
+		if op == "*" {
+			result *= operand
+		} else if operand == 0 {
+			return 0, errFormulaDiv0
+		} else {
+			result /= operand
+		}
+	}
+	return result, nil
+}
+
+func (p *formulaParser) factor() (float64, error) {
+	token := p.next()
+	switch {
+	case token == "-":
+		result, err := p.factor()
+		return -result, err
+	case token == "+":
+		return p.factor()
+	case token == "(":
+		result, err := p.expression()
+		if err != nil {
+			return 0, err
+		}
+		if p.next() != ")" {
+			return 0, errFormulaValue
+		}
+		return result, nil
+	case token == "":
+		return 0, errFormulaValue
+	case unicode.IsDigit(rune(token[0])) || token[0] == '.':
+		result, err := strconv.ParseFloat(token, 64)
+		if err != nil {
+			return 0, errFormulaValue
+		}
+		return result, nil
+	}
+	if ref, ok, err := parseCellRef(token); ok {
+		if err != nil {
+			return 0, err
+		}
+		number, isNumber, err := p.sheet.value(ref[0], ref[1])
+		if err != nil {
+			return 0, err
+		}
+		if !isNumber && p.sheet.hasText(ref[0], ref[1]) {
+			return 0, errFormulaValue
+		}
+		return number, nil
+	}
+	if p.peek() != "(" {
+		return 0, errFormulaName
+	}
+	p.next()
+	return p.function(token)
+}
+
+func (p *formulaParser) function(name string) (float64, error) {
+	var numbers []float64
+	for p.peek() != ")" {
+		values, err := p.argument()
+		if err != nil {
+			return 0, err
+		}
+		numbers = append(numbers, values...)
+		if p.peek() == "," {
+			p.next()
+		} else if p.peek() != ")" {
+			return 0, errFormulaValue
+		}
+	}
+	p.next()
+
+	switch name {
+	case "SUM":
+		return sumNumbers(numbers), nil
+	case "AVG", "AVERAGE":
+		if len(numbers) == 0 {
+			return 0, errFormulaDiv0
+		}
+		return sumNumbers(numbers) / float64(len(numbers)), nil
+	case "MIN", "MAX":
+		if len(numbers) == 0 {
+			return 0, nil
+		}
+		result := numbers[0]
+		for _, n := range numbers[1:] {
+			if (name == "MIN") == (n < result) {
+				result = n
+			}
+		}
+		return result, nil
+	case "COUNT":
+		return float64(len(numbers)), nil
+	}
+	return 0, errFormulaName
+}
+
+// argument evaluates one function argument. References and ranges yield the
+// numbers they hold; anything else is an expression.
+func (p *formulaParser) argument() ([]float64, error) {
+	if from, ok, err := parseCellRef(p.peek()); ok {
+		next := ""
+		if p.pos+1 < len(p.tokens) {
+			next = p.tokens[p.pos+1]
+		}
+		switch next {
+		case ":":
+			p.pos += 2
+			to, ok, toErr := parseCellRef(p.next())
+			if !ok {
+				return nil, errFormulaValue
+			}
+			if err != nil {
+				return nil, err
+			}
+			if toErr != nil {
+				return nil, toErr
+			}
+			return p.sheet.numbers(from, to)
+		case ",", ")":
+			p.pos++
+			if err != nil {
+				return nil, err
+			}
+			return p.sheet.numbers(from, from)
+		}
+	}
+	result, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	return []float64{result}, nil
+}
+
+func (s *formulaSheet) hasText(r, c int) bool {
+	return r < len(s.grid.Rows) && c < len(s.grid.Rows[r]) && s.grid.Rows[r][c].Text != ""
+}
+
+// parseCellRef reads an A1 reference, ignoring "$" markers. It reports false
+// when token is not a reference.
+func parseCellRef(token string) ([2]int, bool, error) {
+	token = strings.ReplaceAll(token, "$", "")
+	letters := strings.TrimRightFunc(token, unicode.IsDigit)
+	digits := token[len(letters):]
+	if letters == "" || digits == "" || len(letters) > 3 || strings.IndexFunc(letters, func(r rune) bool { return r < 'A' || r > 'Z' }) >= 0 {
+		return [2]int{}, false, nil
+	}
+	col := 0
+	for _, r := range letters {
+		col = col*26 + int(r-'A'+1)
+	}
+	row, err := strconv.Atoi(digits)
+	if err != nil || row < 1 {
+		return [2]int{}, true, errFormulaRef
+	}
+	return [2]int{row - 1, col - 1}, true, nil
+}
+
+func sumNumbers(numbers []float64) float64 {
+	total := 0.0
+	for _, n := range numbers {
+		total += n
+	}
+	return total
+}
+
+// formatNumber shows a formula result without binary rounding noise, so
+// 0.1+0.2 reads 0.3.
+func formatNumber(number float64) string {
+	rounded, _ := strconv.ParseFloat(strconv.FormatFloat(number, 'g', 15, 64), 64)
+	return strconv.FormatFloat(rounded, 'f', -1, 64)
+}
+
+var formulaRefPattern = regexp.MustCompile(`(\$?)([A-Za-z]{1,3})(\$?)([0-9]+)(?::(\$?)([A-Za-z]{1,3})(\$?)([0-9]+))?`)
+
+// mapFormulaLines rewrites the row numbers of a formula's references, or
+// the columns when horizontal, through fn. fn gets each 0-based line,
+// whether it is marked absolute with "$" and whether it ends a range.
+func mapFormulaLines(formula string, horizontal bool, fn func(line int, absolute, rangeEnd bool) int) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range formulaRefPattern.FindAllStringSubmatchIndex(formula, -1) {
+		start, end := m[0], m[1]
+		// Skip names that merely contain a reference, such as LOG10(.
+		if start > 0 && isFormulaNameByte(formula[start-1]) || end < len(formula) && (isFormulaNameByte(formula[end]) || formula[end] == '(') {
+			continue
+		}
+		b.WriteString(formula[last:start])
+		b.WriteString(mapFormulaRef(formula, m[2:10], horizontal, false, fn))
+		if m[10] >= 0 {
+			b.WriteString(":")
+			b.WriteString(mapFormulaRef(formula, m[10:18], horizontal, true, fn))
+		}
+		last = end
+	}
+	b.WriteString(formula[last:])
+	return b.String()
+}
+
+// mapFormulaRef rewrites one reference from its submatch indexes: column
+// marker, column, row marker and row.
+func mapFormulaRef(formula string, m []int, horizontal, rangeEnd bool, fn func(int, bool, bool) int) string {
+	colMark, col := formula[m[0]:m[1]], formula[m[2]:m[3]]
+	rowMark, row := formula[m[4]:m[5]], formula[m[6]:m[7]]
+	if horizontal {
+		ref, _, _ := parseCellRef(strings.ToUpper(col) + "1")
+		col = columnName(fn(ref[1], colMark != "", rangeEnd))
+	} else if n, err := strconv.Atoi(row); err == nil {
+		row = strconv.Itoa(fn(n-1, rowMark != "", rangeEnd) + 1)
+	}
+	return colMark + col + rowMark + row
+}
+
+func isFormulaNameByte(c byte) bool {
+	return c == '_' || c == '$' || c >= '0' && c <= '9' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z'
+}
+
+// columnName returns the letters of a 0-based column.
+func columnName(col int) string {
+	name := ""
+	for col++; col > 0; col = (col - 1) / 26 {
+		name = string(rune('A'+(col-1)%26)) + name
+	}
+	return name
+}
@@ -0,0 +1,69 @@
+package render
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func formulaGrid(cells ...Cell) *Grid {
+	values := make(map[string]string)
+	for _, cell := range cells {
+		values[cellKey(cell.Row, cell.Col)] = cell.Text
+	}
+	return buildGrid(&ReportConfig{Cells: cells}, values)
+}
+
+func TestEvaluateFormulas(t *testing.T) {
+	grid := formulaGrid(
+		Cell{Row: 0, Col: 0, Text: "Item"}, Cell{Row: 0, Col: 1, Text: "Price"}, Cell{Row: 0, Col: 2, Text: "Qty"},
+		Cell{Row: 1, Col: 1, Text: "2.5"}, Cell{Row: 1, Col: 2, Text: "4"}, Cell{Row: 1, Col: 3, Text: "=B2*C2"},
+		Cell{Row: 2, Col: 1, Text: "0.1"}, Cell{Row: 2, Col: 2, Text: "0.2"}, Cell{Row: 2, Col: 3, Text: "=b3+$C$3"},
+		Cell{Row: 3, Col: 3, Text: "=SUM(D2:D3)"},
+		Cell{Row: 4, Col: 3, Text: "=AVG(B2:C3)"},
+		Cell{Row: 5, Col: 3, Text: "=MAX(B2:B3, 7) - MIN(C2:C3) + COUNT(A1:C3)"},
+		Cell{Row: 6, Col: 3, Text: "=-(D2 + 2) / 4"},
+		Cell{Row: 7, Col: 3, Text: "=SUM(A1:A2)"},
+	)
+
+	assert.Equal(t, "10", grid.Rows[1][3].Text)
+	assert.Equal(t, 10.0, grid.Rows[1][3].Value)
+	assert.Equal(t, "0.3", grid.Rows[2][3].Text, "results are shown without rounding noise")
+	assert.Equal(t, "10.3", grid.Rows[3][3].Text, "formulas can use other formulas")
+	assert.Equal(t, "1.7", grid.Rows[4][3].Text)
+	assert.Equal(t, "10.8", grid.Rows[5][3].Text)
+	assert.Equal(t, "-3", grid.Rows[6][3].Text)
+	assert.Equal(t, "0", grid.Rows[7][3].Text, "text cells are skipped by functions")
+}
+
+func TestEvaluateFormulas_Errors(t *testing.T) {
+	grid := formulaGrid(
+		Cell{Row: 0, Col: 0, Text: "east"},
+		Cell{Row: 0, Col: 1, Text: "=A1*2"},
+		Cell{Row: 1, Col: 1, Text: "=1/0"},
+		Cell{Row: 2, Col: 1, Text: "=B4"},
+		Cell{Row: 3, Col: 1, Text: "=B3"},
+		Cell{Row: 4, Col: 1, Text: "=TOTAL(A1)"},
+		Cell{Row: 5, Col: 1, Text: "=A0"},
+		Cell{Row: 6, Col: 1, Text: "=(1+2"},
+		Cell{Row: 7, Col: 1, Text: "=B2+1"},
+	)
+
+	assert.Equal(t, "#VALUE!", grid.Rows[0][1].Text)
+	assert.Equal(t, "#DIV/0!", grid.Rows[1][1].Text)
+	assert.Equal(t, "#REF!", grid.Rows[2][1].Text, "circular references")
+	assert.Equal(t, "#REF!", grid.Rows[3][1].Text)
+	assert.Equal(t, "#NAME?", grid.Rows[4][1].Text)
+	assert.Equal(t, "#REF!", grid.Rows[5][1].Text)
+	assert.Equal(t, "#VALUE!", grid.Rows[6][1].Text)
+	assert.Equal(t, "#DIV/0!", grid.Rows[7][1].Text, "errors propagate")
+	assert.Nil(t, grid.Rows[7][1].Value)
+}
+
+func TestBuildGrid_BoundDataReplacesFormula(t *testing.T) {
+	config := &ReportConfig{Cells: []Cell{{Row: 0, Col: 0, Text: "=1+1"}}}
+	grid := buildGrid(config, map[string]string{"0:0": "=bound"})
+
+	assert.Equal(t, "=bound", grid.Rows[0][0].Text)
+	assert.Empty(t, grid.Rows[0][0].Formula)
+}
@@ -0,0 +1,200 @@
+package render
+
+import (
+	"sort"
+	"strings"
+)
+
+// Grid is a rendered report as rows of cells, the same cells the HTML
+// preview shows, for the exporters to lay out. Column widths and row heights
+// are the designer's sizes in pixels; zero means sized to the content.
+type Grid struct {
+	Title        string
+	Rows         [][]GridCell
+	ColumnWidths []float64
+	RowHeights   []float64
+	Print        PrintConfig
+}
+
+// GridCell is one rendered cell. Text is what is shown: bound data or
+// static text, a formula result, formatted by the cell's mask. Value holds
+// the number or time.Time behind the text when there is one.
+//
+// A cell with RowSpan or ColSpan above one is the top left of a merged
+// range; the other cells of the range are marked Merged and left empty.
+type GridCell struct {
+	Text    string
+	Value   interface{}
+	Format  string
+	Formula string
+	Style   *CellStyle
+	RowSpan int
+	ColSpan int
+	Merged  bool
+
+	// anchor is the top left cell of the range a merged cell belongs to.
+	anchor [2]int
+}
+
+// Columns returns the width of the grid in cells.
+func (g *Grid) Columns() int {
+	if len(g.Rows) == 0 {
+		return 0
+	}
+	return len(g.Rows[0])
+}
+
+func (c *GridCell) rowSpan() int {
+	return max(c.RowSpan, 1)
+}
+
+func (c *GridCell) colSpan() int {
+	return max(c.ColSpan, 1)
+}
+
+func (g *Grid) columnWidth(c int) float64 {
+	if c < len(g.ColumnWidths) {
+		return g.ColumnWidths[c]
+	}
+	return 0
+}
+
+func (g *Grid) rowHeight(r int) float64 {
+	if r < len(g.RowHeights) {
+		return g.RowHeights[r]
+	}
+	return 0
+}
+
+func buildGrid(config *ReportConfig, cellValues map[string]string) *Grid {
+	maxRow, maxCol := gridBounds(config)
+	grid := &Grid{Rows: make([][]GridCell, maxRow+1), Print: config.Print}
+	for r := range grid.Rows {
+		grid.Rows[r] = make([]GridCell, maxCol+1)
+		for c := range grid.Rows[r] {
+			grid.Rows[r][c].Text = cellValues[cellKey(r, c)]
+		}
+	}
+
+	for _, cell := range config.Cells {
+		if cell.Row < 0 || cell.Col < 0 {
+			continue
+		}
+		target := &grid.Rows[cell.Row][cell.Col]
+		target.Format = cell.Format
+		target.Style = cell.Style
+		target.RowSpan = cell.RowSpan
+		target.ColSpan = cell.ColSpan
+		static := cell.Value
+		if static == "" {
+			static = cell.Text
+		}
+		// Bound data replaces the static text, so only a formula that is
+		// still there is evaluated.
+		if !cell.bound && strings.HasPrefix(static, "=") && target.Text == static {
+			target.Formula = static
+		}
+	}
+	grid.merge()
+
+	for r := range grid.Rows {
+		for c := range grid.Rows[r] {
+			cell := &grid.Rows[r][c]
+			if cell.Formula == "" {
+				if value, kind := parseCellValue(cell.Text); kind != cellText {
+					cell.Value = value
+				}
+			}
+		}
+	}
+	evaluateFormulas(grid)
+	for r := range grid.Rows {
+		for c := range grid.Rows[r] {
+			cell := &grid.Rows[r][c]
+			if text, ok := formatValue(cell.Value, cell.Format); ok {
+				cell.Text = text
+			}
+		}
+	}
+
+	grid.ColumnWidths = make([]float64, maxCol+1)
+	for c := range grid.ColumnWidths {
+		grid.ColumnWidths[c] = config.Grid.CellWidth
+		if width, ok := config.Grid.ColumnWidths[c]; ok {
+			grid.ColumnWidths[c] = width
+		}
+	}
+	grid.RowHeights = make([]float64, maxRow+1)
+	for r := range grid.RowHeights {
+		grid.RowHeights[r] = config.Grid.CellHeight
+		if height, ok := config.Grid.RowHeights[r]; ok {
+			grid.RowHeights[r] = height
+		}
+	}
+	return grid
+}
+
+// merge marks the cells covered by merged ranges. Ranges are applied top to
+// bottom, left to right; one that overlaps an earlier range is dropped.
+func (g *Grid) merge() {
+	var anchors [][2]int
+	for r, row := range g.Rows {
+		for c := range row {
+			if row[c].rowSpan() > 1 || row[c].colSpan() > 1 {
+				anchors = append(anchors, [2]int{r, c})
+			}
+		}
+	}
+	sort.Slice(anchors, func(i, j int) bool {
+		if anchors[i][0] != anchors[j][0] {
+			return anchors[i][0] < anchors[j][0]
+		}
+		return anchors[i][1] < anchors[j][1]
+	})
+
+	for _, anchor := range anchors {
+		cell := &g.Rows[anchor[0]][anchor[1]]
+		if cell.Merged {
+			cell.RowSpan, cell.ColSpan = 0, 0
+			continue
+		}
+		rows, cols := cell.rowSpan(), cell.colSpan()
+		overlaps := false
+		for r := anchor[0]; r < anchor[0]+rows; r++ {
+			for c := anchor[1]; c < anchor[1]+cols; c++ {
+				if g.Rows[r][c].Merged {
+					overlaps = true
+				}
+			}
+		}
+		if overlaps {
+			cell.RowSpan, cell.ColSpan = 0, 0
+			continue
+		}
+		for r := anchor[0]; r < anchor[0]+rows; r++ {
+			for c := anchor[1]; c < anchor[1]+cols; c++ {
+				if r == anchor[0] && c == anchor[1] {
+					continue
+				}
+				g.Rows[r][c] = GridCell{Merged: true, anchor: anchor}
+			}
+		}
+	}
+}
+
+func gridBounds(config *ReportConfig) (int, int) {
+	maxRow := 0
+	maxCol := 0
+	for _, cell := range config.Cells {
+		if cell.Row < 0 || cell.Col < 0 {
+			continue
+		}
+		if end := cell.Row + max(cell.RowSpan, 1) - 1; end > maxRow {
+			maxRow = end
+		}
+		if end := cell.Col + max(cell.ColSpan, 1) - 1; end > maxCol {
+			maxCol = end
+		}
+	}
+	return maxRow, maxCol
+}
@@ -0,0 +1,70 @@
+package render
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// styledGrid is a small invoice: a merged, styled title, bound amounts with
+// currency masks and a formula total.
+func styledGrid() *Grid {
+	config := &ReportConfig{
+		Grid: GridConfig{CellWidth: 120, CellHeight: 44, ColumnWidths: map[int]float64{0: 200}, RowHeights: map[int]float64{0: 60}},
+		Cells: []Cell{
+			{Row: 0, Col: 0, Text: "Quarterly sales", ColSpan: 3, Style: &CellStyle{
+				FontSize: 20, FontWeight: "bold", Align: "center", Color: "#ffffff", Background: "#1f4e79",
+			}},
+			{Row: 1, Col: 0, Text: "Region"}, {Row: 1, Col: 1, Text: "Amount"}, {Row: 1, Col: 2, Text: "Share"},
+			{Row: 2, Col: 0, Text: "East", RowSpan: 2, Style: &CellStyle{VerticalAlign: "top"}},
+			{Row: 2, Col: 1, Text: "1234.5", Format: "¥#,##0.00"},
+			{Row: 2, Col: 2, Text: "=B3/B5", Format: "0.0%"},
+			{Row: 3, Col: 1, Text: "765.5", Format: "¥#,##0.00"},
+			{Row: 3, Col: 2, Text: "=B4/B5", Format: "0.0%"},
+			{Row: 4, Col: 0, Text: "Total", Style: &CellStyle{FontWeight: "bold", BorderStyle: "dashed", BorderColor: "#ff0000"}},
+			{Row: 4, Col: 1, Text: "=SUM(B3:B4)", Format: "¥#,##0.00", Style: &CellStyle{FontWeight: "bold"}},
+		},
+		Print: PrintConfig{HeaderRows: 2},
+	}
+	values := make(map[string]string)
+	for _, cell := range config.Cells {
+		values[cellKey(cell.Row, cell.Col)] = cell.Text
+	}
+	return buildGrid(config, values)
+}
+
+func TestBuildGrid_StylesMergesAndFormats(t *testing.T) {
+	grid := styledGrid()
+
+	assert.Equal(t, [][]string{
+		{"Quarterly sales", "", ""},
+		{"Region", "Amount", "Share"},
+		{"East", "¥1,234.50", "61.7%"},
+		{"", "¥765.50", "38.3%"},
+		{"Total", "¥2,000.00", ""},
+	}, gridText(grid))
+	assert.Equal(t, 3, grid.Rows[0][0].colSpan())
+	assert.True(t, grid.Rows[0][1].Merged)
+	assert.True(t, grid.Rows[3][0].Merged)
+	assert.Equal(t, [2]int{2, 0}, grid.Rows[3][0].anchor)
+	assert.Equal(t, 2000.0, grid.Rows[4][1].Value, "formula results keep their number")
+	assert.Equal(t, int64(765), int64(grid.Rows[3][1].Value.(float64)))
+	assert.Equal(t, []float64{200, 120, 120}, grid.ColumnWidths)
+	assert.Equal(t, []float64{60, 44, 44, 44, 44}, grid.RowHeights)
+}
+
+func TestBuildGrid_OverlappingMerges(t *testing.T) {
+	config := &ReportConfig{Cells: []Cell{
+		{Row: 0, Col: 0, Text: "a", ColSpan: 2, RowSpan: 2},
+		{Row: 1, Col: 1, Text: "b", ColSpan: 2},
+		{Row: 0, Col: 2, Text: "c", RowSpan: 3},
+	}}
+	grid := buildGrid(config, map[string]string{"0:0": "a", "1:1": "b", "0:2": "c"})
+
+	require.Len(t, grid.Rows, 3)
+	assert.Equal(t, 3, grid.Columns(), "spans count towards the grid size")
+	assert.True(t, grid.Rows[1][1].Merged, "a cell inside a range is covered")
+	assert.Equal(t, 3, grid.Rows[0][2].rowSpan())
+	assert.True(t, grid.Rows[2][2].Merged)
+}
@@ -3,42 +3,73 @@ package render
 import (
 	"fmt"
 	"html"
+	"image/color"
+	"strconv"
 	"strings"
+	"unicode"
 )
 
+// buildHTML renders the report as one table. With page and pageSize it
+// shows only that window of pageSize rows; RenderPage paginates by paper.
 func buildHTML(config *ReportConfig, cellValues map[string]string, page, pageSize int) string {
-	maxRow := 0
-	maxCol := 0
-	for _, cell := range config.Cells {
-		if cell.Row > maxRow {
-			maxRow = cell.Row
-		}
-		if cell.Col > maxCol {
-			maxCol = cell.Col
-		}
-	}
+	grid := buildGrid(config, cellValues)
 
 	var b strings.Builder
-	b.WriteString("<table>")
+	writeHTMLTableStart(&b, grid)
 
 	startRow := 0
-	endRow := maxRow + 1
+	endRow := len(grid.Rows)
 
 	if page > 0 && pageSize > 0 {
 		startRow = (page - 1) * pageSize
 		endRow = page * pageSize
-		if endRow > maxRow+1 {
-			endRow = maxRow + 1
+		if endRow > len(grid.Rows) {
+			endRow = len(grid.Rows)
 		}
 	}
 
 	for r := startRow; r < endRow; r++ {
-		b.WriteString("<tr>")
-		for c := 0; c <= maxCol; c++ {
-			value := cellValues[cellKey(r, c)]
-			b.WriteString("<td>")
-			b.WriteString(html.EscapeString(value))
-			b.WriteString("</td>")
+		writeHTMLRowStart(&b, grid, r)
+		for c := range grid.Rows[r] {
+			cell := &grid.Rows[r][c]
+			rowSpan := cell.rowSpan()
+			if cell.Merged {
+				// A range merged from a row on an earlier page continues
+				// at the top of this one.
+				anchor := cell.anchor
+				if r != startRow || anchor[0] >= startRow || anchor[1] != c {
+					continue
+				}
+				cell = &grid.Rows[anchor[0]][anchor[1]]
+				rowSpan = anchor[0] + cell.rowSpan() - r
+			}
+			writeHTMLCell(&b, cell, min(rowSpan, endRow-r))
+		}
+		b.WriteString("</tr>")
+	}
+	b.WriteString("</table>")
+	return b.String()
+}
+
+// buildPageHTML renders one page of a laid out grid: the repeated header
+// rows, then the rows of the page.
+func buildPageHTML(layout *pageLayout, page int) string {
+	grid := layout.grid
+	var b strings.Builder
+	writeHTMLTableStart(&b, grid)
+	for _, r := range layout.rows(page) {
+		writeHTMLRowStart(&b, grid, r)
+		for c := range grid.Rows[r] {
+			cell := &grid.Rows[r][c]
+			if cell.Merged {
+				// Header ranges stop at the end of the header, leaving the
+				// body cells they covered empty.
+				if r >= layout.headerRows && cell.anchor[0] < layout.headerRows {
+					b.WriteString("<td></td>")
+				}
+				continue
+			}
+			writeHTMLCell(&b, cell, layout.rowSpan(r, c))
 		}
 		b.WriteString("</tr>")
 	}
@@ -46,6 +77,127 @@ func buildHTML(config *ReportConfig, cellValues map[string]string, page, pageSiz
 	return b.String()
 }
 
+func writeHTMLTableStart(b *strings.Builder, grid *Grid) {
+	b.WriteString("<table>")
+	if hasSizes(grid.ColumnWidths) {
+		b.WriteString("<colgroup>")
+		for _, width := range grid.ColumnWidths {
+			if width > 0 {
+				fmt.Fprintf(b, `<col style="width:%spx">`, cssNumber(width))
+			} else {
+				b.WriteString("<col>")
+			}
+		}
+		b.WriteString("</colgroup>")
+	}
+}
+
+func writeHTMLRowStart(b *strings.Builder, grid *Grid, r int) {
+	if height := grid.rowHeight(r); height > 0 {
+		fmt.Fprintf(b, `<tr style="height:%spx">`, cssNumber(height))
+	} else {
+		b.WriteString("<tr>")
+	}
+}
+
+func writeHTMLCell(b *strings.Builder, cell *GridCell, rowSpan int) {
+	b.WriteString("<td")
+	if rowSpan > 1 {
+		fmt.Fprintf(b, ` rowspan="%d"`, rowSpan)
+	}
+	if cell.colSpan() > 1 {
+		fmt.Fprintf(b, ` colspan="%d"`, cell.colSpan())
+	}
+	if css := cssStyle(cell.Style); css != "" {
+		b.WriteString(` style="` + html.EscapeString(css) + `"`)
+	}
+	b.WriteString(">")
+	b.WriteString(html.EscapeString(cell.Text))
+	b.WriteString("</td>")
+}
+
+// cssStyle renders a cell style as inline CSS. Only values that parse are
+// written, so a style cannot inject other declarations.
+func cssStyle(s *CellStyle) string {
+	if s == nil {
+		return ""
+	}
+	var rules []string
+	if family := cssFontFamily(s.FontFamily); family != "" {
+		rules = append(rules, "font-family:"+family)
+	}
+	if s.FontSize > 0 {
+		rules = append(rules, "font-size:"+cssNumber(s.FontSize)+"px")
+	}
+	font := s.font()
+	if font.bold {
+		rules = append(rules, "font-weight:bold")
+	}
+	if font.italic {
+		rules = append(rules, "font-style:italic")
+	}
+	if c, ok := parseColor(s.Color); ok {
+		rules = append(rules, "color:"+cssColor(c))
+	}
+	if c, ok := s.background(); ok {
+		rules = append(rules, "background:"+cssColor(c))
+	}
+	if s.Align != "" {
+		rules = append(rules, "text-align:"+s.align())
+	}
+	if s.VerticalAlign != "" {
+		rules = append(rules, "vertical-align:"+s.verticalAlign())
+	}
+	if s.BorderColor != "" || s.BorderWidth > 0 || s.BorderStyle != "" {
+		if border, ok := s.border(); ok {
+			style := "solid"
+			if border.dashed {
+				style = "dashed"
+			} else if border.dotted {
+				style = "dotted"
+			}
+			width := s.BorderWidth
+			if width <= 0 {
+				width = 1
+			}
+			rules = append(rules, fmt.Sprintf("border:%spx %s %s", cssNumber(width), style, cssColor(border.color)))
+		} else {
+			rules = append(rules, "border:none")
+		}
+	}
+	return strings.Join(rules, ";")
+}
+
+func cssColor(c color.NRGBA) string {
+	if c.A == 255 {
+		return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+	}
+	return fmt.Sprintf("rgba(%d,%d,%d,%s)", c.R, c.G, c.B, strconv.FormatFloat(float64(c.A)/255, 'f', 3, 64))
+}
+
+// cssFontFamily keeps the characters a font family list needs.
+func cssFontFamily(family string) string {
+	return strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune(" ,-_'", r) {
+			return r
+		}
+		return -1
+	}, family))
+}
+
+func cssNumber(n float64) string {
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
+
+func hasSizes(sizes []float64) bool {
+	for _, size := range sizes {
+		if size > 0 {
+			return true
+		}
+	}
+	return false
+}
+
 func cellKey(row, col int) string {
 	return fmt.Sprintf("%d:%d", row, col)
 }
@@ -255,3 +255,36 @@ func TestBuildHTML_UnicodeContent(t *testing.T) {
 	assert.Contains(t, result, "你好世界")
 	assert.Contains(t, result, "🌍")
 }
+
+func TestBuildHTML_StylesAndMerges(t *testing.T) {
+	config := &ReportConfig{
+		Grid: GridConfig{ColumnWidths: map[int]float64{1: 80}, RowHeights: map[int]float64{0: 30}},
+		Cells: []Cell{
+			{Row: 0, Col: 0, Text: "Title", ColSpan: 2, Style: &CellStyle{FontSize: 18, FontWeight: "bold", Align: "center", Background: "#eeeeee"}},
+			{Row: 1, Col: 0, Text: "East", RowSpan: 3},
+			{Row: 1, Col: 1, Text: "1234.5", Format: "#,##0.00"},
+			{Row: 2, Col: 1, Text: "=B2*2", Style: &CellStyle{Color: "red;background:url(x)", BorderStyle: "dotted"}},
+		},
+	}
+	cellValues := map[string]string{"0:0": "Title", "1:0": "East", "1:1": "1234.5", "2:1": "=B2*2"}
+
+	result := buildHTML(config, cellValues, 0, 0)
+	assert.Contains(t, result, `<colgroup><col><col style="width:80px"></colgroup>`)
+	assert.Contains(t, result, `<tr style="height:30px"><td colspan="2" style="font-size:18px;font-weight:bold;background:#eeeeee;text-align:center">Title</td></tr>`)
+	assert.Contains(t, result, `<td rowspan="3">East</td><td>1,234.50</td>`)
+	assert.Contains(t, result, `<tr><td style="border:1px dotted #000000">2469</td></tr>`, "covered cells are left out and invalid colors dropped")
+	assert.NotContains(t, result, "url(")
+
+	page2 := buildHTML(config, cellValues, 2, 2)
+	assert.Contains(t, page2, `<tr><td rowspan="2">East</td><td style="border:1px dotted #000000">2469</td></tr>`, "a range merged on an earlier page continues")
+}
+
+func TestBuildPageHTML_HeaderMergeStopsAtHeader(t *testing.T) {
+	grid := &Grid{Rows: textRows([][]string{{"Title", "B"}, {"", "b1"}, {"c", "c1"}})}
+	grid.Rows[0][0].RowSpan = 2
+	grid.merge()
+	layout := layoutPages(grid, PrintConfig{PageSize: "a4", Orientation: "portrait", HeaderRows: 1}, docxMeasurer{})
+
+	html := buildPageHTML(layout, 0)
+	assert.Equal(t, `<table><tr><td>Title</td><td>B</td></tr><tr><td></td><td>b1</td></tr><tr><td>c</td><td>c1</td></tr></table>`, html)
+}
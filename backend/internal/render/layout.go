@@ -0,0 +1,315 @@
+package render
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidPrintConfig is returned for page setups the exporters cannot lay
+// out, such as an unknown page size.
+var ErrInvalidPrintConfig = errors.New("invalid print settings")
+
+// Page layout shared by the paginated exporters, in millimetres except for
+// the font size.
+const (
+	pageMargin       = 10.0
+	pageFooterHeight = 8.0
+	fontSizePt       = 9.0
+	lineHeight       = 4.5
+	cellPadding      = 1.5
+	minColumnWidth   = 8.0
+)
+
+// pageSizes are portrait width and height in millimetres.
+var pageSizes = map[string][2]float64{
+	"a3":     {297, 420},
+	"a4":     {210, 297},
+	"a5":     {148, 210},
+	"letter": {215.9, 279.4},
+	"legal":  {215.9, 355.6},
+}
+
+// NormalizePrintConfig checks a page setup and fills in the defaults: A4,
+// portrait, no repeating header rows.
+func NormalizePrintConfig(settings PrintConfig) (PrintConfig, error) {
+	size := strings.ToLower(strings.TrimSpace(settings.PageSize))
+	if size == "" {
+		size = "a4"
+	}
+	if _, ok := pageSizes[size]; !ok {
+		return settings, fmt.Errorf("%w: unsupported page size %q", ErrInvalidPrintConfig, settings.PageSize)
+	}
+	settings.PageSize = size
+
+	orientation := strings.ToLower(strings.TrimSpace(settings.Orientation))
+	switch orientation {
+	case "":
+		orientation = "portrait"
+	case "portrait", "landscape":
+	default:
+		return settings, fmt.Errorf("%w: unsupported orientation %q", ErrInvalidPrintConfig, settings.Orientation)
+	}
+	settings.Orientation = orientation
+
+	if settings.HeaderRows < 0 {
+		return settings, fmt.Errorf("%w: headerRows must not be negative", ErrInvalidPrintConfig)
+	}
+	return settings, nil
+}
+
+// pageDimensions returns the width and height of a normalized page setup.
+func pageDimensions(settings PrintConfig) (float64, float64) {
+	size := pageSizes[settings.PageSize]
+	if settings.Orientation == "landscape" {
+		return size[1], size[0]
+	}
+	return size[0], size[1]
+}
+
+// textMeasurer measures text in the exporter's fonts, in millimetres.
+type textMeasurer interface {
+	textWidth(text string, font fontSpec) float64
+}
+
+// pageLayout is a grid placed on pages: column widths, the wrapped lines of
+// every cell, row heights, and the body rows that land on each page. The
+// header rows are repeated at the top of every page. Rows joined by a merged
+// range always land on the same page.
+type pageLayout struct {
+	grid       *Grid
+	width      float64
+	height     float64
+	widths     []float64
+	lines      [][][]string
+	heights    []float64
+	headerRows int
+	pages      [][]int
+}
+
+func layoutPages(grid *Grid, settings PrintConfig, m textMeasurer) *pageLayout {
+	width, height := pageDimensions(settings)
+	layout := &pageLayout{grid: grid, width: width, height: height, headerRows: settings.HeaderRows}
+	if layout.headerRows > len(grid.Rows) {
+		layout.headerRows = len(grid.Rows)
+	}
+	layout.widths = columnWidths(grid, m, width-2*pageMargin)
+
+	layout.lines = make([][][]string, len(grid.Rows))
+	layout.heights = make([]float64, len(grid.Rows))
+	for r, row := range grid.Rows {
+		layout.lines[r] = make([][]string, len(row))
+		layout.heights[r] = max(pxToMM(grid.rowHeight(r)), lineHeight+2*cellPadding)
+		for c := range row {
+			cell := &row[c]
+			if cell.Merged {
+				continue
+			}
+			font := cell.Style.font()
+			layout.lines[r][c] = wrapText(cell.Text, layout.spanWidth(r, c)-2*cellPadding, font, m)
+			if layout.rowSpan(r, c) == 1 {
+				layout.heights[r] = max(layout.heights[r], textHeight(layout.lines[r][c], font))
+			}
+		}
+	}
+	// Merged cells too tall for the rows they span grow their last row.
+	for r, row := range grid.Rows {
+		for c := range row {
+			if row[c].Merged || layout.rowSpan(r, c) == 1 {
+				continue
+			}
+			last := r + layout.rowSpan(r, c) - 1
+			needed := textHeight(layout.lines[r][c], row[c].Style.font())
+			if spanned := sumFloats(layout.heights[r : last+1]); needed > spanned {
+				layout.heights[last] += needed - spanned
+			}
+		}
+	}
+
+	headerHeight := sumFloats(layout.heights[:layout.headerRows])
+	bodyHeight := height - 2*pageMargin - pageFooterHeight - headerHeight
+	page, used := []int{}, 0.0
+	for r := layout.headerRows; r < len(grid.Rows); {
+		end := r
+		for block := r; block <= end; block++ {
+			for c := range grid.Rows[block] {
+				if !grid.Rows[block][c].Merged {
+					end = max(end, block+layout.rowSpan(block, c)-1)
+				}
+			}
+		}
+		// A block taller than a whole page gets a page to itself rather
+		// than being split.
+		blockHeight := sumFloats(layout.heights[r : end+1])
+		if len(page) > 0 && used+blockHeight > bodyHeight {
+			layout.pages = append(layout.pages, page)
+			page, used = []int{}, 0
+		}
+		for ; r <= end; r++ {
+			page = append(page, r)
+		}
+		used += blockHeight
+	}
+	layout.pages = append(layout.pages, page)
+	return layout
+}
+
+// rows returns the grid rows drawn on a page, header rows first.
+func (l *pageLayout) rows(page int) []int {
+	rows := make([]int, 0, l.headerRows+len(l.pages[page]))
+	for r := 0; r < l.headerRows; r++ {
+		rows = append(rows, r)
+	}
+	return append(rows, l.pages[page]...)
+}
+
+// rowSpan is the number of rows a cell covers on a page. Ranges merged in
+// the header rows stop at the end of the header.
+func (l *pageLayout) rowSpan(r, c int) int {
+	span := l.grid.Rows[r][c].rowSpan()
+	if r < l.headerRows {
+		span = min(span, l.headerRows-r)
+	}
+	return min(span, len(l.grid.Rows)-r)
+}
+
+func (l *pageLayout) spanWidth(r, c int) float64 {
+	return sumFloats(l.widths[c:min(c+l.grid.Rows[r][c].colSpan(), len(l.widths))])
+}
+
+// cellBox returns the size of a cell including the cells merged into it.
+func (l *pageLayout) cellBox(r, c int) (float64, float64) {
+	return l.spanWidth(r, c), sumFloats(l.heights[r : r+l.rowSpan(r, c)])
+}
+
+// eachCell calls fn with the position and size of every cell drawn on a
+// page, skipping the cells covered by merged ranges.
+func (l *pageLayout) eachCell(page int, fn func(r, c int, x, y, width, height float64)) {
+	y := pageMargin
+	for _, r := range l.rows(page) {
+		x := pageMargin
+		for c := range l.grid.Rows[r] {
+			if !l.grid.Rows[r][c].Merged {
+				width, height := l.cellBox(r, c)
+				fn(r, c, x, y, width, height)
+			}
+			x += l.widths[c]
+		}
+		y += l.heights[r]
+	}
+}
+
+// textTop returns where the first line of a cell's text starts.
+func textTop(y, height float64, lines []string, font fontSpec, verticalAlign string) float64 {
+	switch verticalAlign {
+	case "top":
+		return y + cellPadding
+	case "bottom":
+		return y + height - cellPadding - float64(len(lines))*font.lineHeight()
+	}
+	return y + (height-float64(len(lines))*font.lineHeight())/2
+}
+
+func textHeight(lines []string, font fontSpec) float64 {
+	return float64(len(lines))*font.lineHeight() + 2*cellPadding
+}
+
+func sumFloats(values []float64) float64 {
+	total := 0.0
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
+
+// columnWidths gives each column its designer width, or its natural width
+// when it has none. When the table is wider than available, columns narrower
+// than an even share keep their width and the wider ones shrink
+// proportionally into the space left.
+func columnWidths(grid *Grid, m textMeasurer, available float64) []float64 {
+	widths := make([]float64, grid.Columns())
+	for c := range widths {
+		widths[c] = pxToMM(grid.columnWidth(c))
+	}
+	for _, row := range grid.Rows {
+		for c := range row {
+			cell := &row[c]
+			if cell.Merged || cell.colSpan() > 1 || grid.columnWidth(c) > 0 {
+				continue
+			}
+			font := cell.Style.font()
+			for _, line := range strings.Split(cell.Text, "\n") {
+				if width := m.textWidth(line, font) + 2*cellPadding; width > widths[c] {
+					widths[c] = width
+				}
+			}
+		}
+	}
+
+	total := 0.0
+	for c := range widths {
+		if widths[c] < minColumnWidth {
+			widths[c] = minColumnWidth
+		}
+		total += widths[c]
+	}
+	if total <= available {
+		return widths
+	}
+
+	fixed := make([]bool, len(widths))
+	remaining, wide := available, len(widths)
+	for changed := true; changed && wide > 0; {
+		changed = false
+		share := remaining / float64(wide)
+		for c := range widths {
+			if !fixed[c] && widths[c] <= share {
+				fixed[c] = true
+				remaining -= widths[c]
+				total -= widths[c]
+				wide--
+				changed = true
+			}
+		}
+	}
+	for c := range widths {
+		if !fixed[c] {
+			widths[c] *= remaining / total
+		}
+	}
+	return widths
+}
+
+// wrapText splits text into lines no wider than width, breaking at spaces
+// where it can and mid-word otherwise.
+func wrapText(text string, width float64, font fontSpec, m textMeasurer) []string {
+	// Widths are summed rune by rune, so allow for rounding against the
+	// width the whole line was measured at.
+	limit := width + 1e-6
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		units := strings.Split(paragraph, "")
+		start, lineWidth, lastSpace := 0, 0.0, -1
+		for i := 0; i < len(units); i++ {
+			unitWidth := m.textWidth(units[i], font)
+			if lineWidth+unitWidth > limit && i > start {
+				end, next := i, i
+				if units[i] == " " {
+					next = i + 1
+				} else if lastSpace > start {
+					end, next = lastSpace, lastSpace+1
+				}
+				lines = append(lines, strings.Join(units[start:end], ""))
+				start, lineWidth, lastSpace = next, 0, -1
+				i = next - 1
+				continue
+			}
+			if units[i] == " " {
+				lastSpace = i
+			}
+			lineWidth += unitWidth
+		}
+		lines = append(lines, strings.Join(units[start:], ""))
+	}
+	return lines
+}
@@ -0,0 +1,77 @@
+package render
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fixedMeasurer gives every rune the same width.
+type fixedMeasurer float64
+
+func (m fixedMeasurer) textWidth(text string, font fontSpec) float64 {
+	return float64(len([]rune(text))) * float64(m)
+}
+
+func TestNormalizePrintConfig(t *testing.T) {
+	settings, err := NormalizePrintConfig(PrintConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, PrintConfig{PageSize: "a4", Orientation: "portrait"}, settings)
+
+	settings, err = NormalizePrintConfig(PrintConfig{PageSize: " Letter", Orientation: "LANDSCAPE", HeaderRows: 2})
+	require.NoError(t, err)
+	assert.Equal(t, PrintConfig{PageSize: "letter", Orientation: "landscape", HeaderRows: 2}, settings)
+
+	for _, invalid := range []PrintConfig{{PageSize: "b9"}, {Orientation: "sideways"}, {HeaderRows: -1}} {
+		_, err := NormalizePrintConfig(invalid)
+		assert.ErrorIs(t, err, ErrInvalidPrintConfig)
+	}
+}
+
+func TestWrapText(t *testing.T) {
+	m := fixedMeasurer(1)
+
+	assert.Equal(t, []string{"alpha beta", "gamma"}, wrapText("alpha beta gamma", 10, fontSpec{}, m))
+	assert.Equal(t, []string{"one", "two"}, wrapText("one\ntwo", 10, fontSpec{}, m))
+	assert.Equal(t, []string{""}, wrapText("", 10, fontSpec{}, m))
+	assert.Equal(t, []string{"abcdefghij", "klmnop"}, wrapText("abcdefghijklmnop", 10, fontSpec{}, m), "words longer than the cell break mid-word")
+	assert.Equal(t, []string{"华东区域", "销售"}, wrapText("华东区域销售", 4, fontSpec{}, m))
+}
+
+func TestLayoutPages(t *testing.T) {
+	// A4 portrait leaves 269mm for rows; header and body rows are 7.5mm.
+	grid := testGrid(80)
+	settings, err := NormalizePrintConfig(PrintConfig{HeaderRows: 1})
+	require.NoError(t, err)
+
+	layout := layoutPages(grid, settings, fixedMeasurer(2))
+	require.Len(t, layout.pages, 3)
+	assert.Len(t, layout.pages[0], 34)
+	assert.Equal(t, 1, layout.pages[0][0])
+	assert.Equal(t, 79, layout.pages[2][len(layout.pages[2])-1])
+	assert.Equal(t, 0, layout.rows(1)[0], "pages start with the header rows")
+	assert.Equal(t, []float64{23, 15}, layout.widths)
+
+	wide := layoutPages(grid, settings, fixedMeasurer(20))
+	assert.InDelta(t, 190.0, sum(wide.widths), 1e-9, "columns shrink to the printable width")
+	mixed := layoutPages(&Grid{Rows: textRows([][]string{{strings.Repeat("x", 200), "Amount"}})}, settings, fixedMeasurer(1))
+	assert.InDeltaSlice(t, []float64{181, 9}, mixed.widths, 1e-9, "narrow columns keep their width")
+
+	empty := layoutPages(&Grid{Rows: textRows([][]string{{"Region"}})}, settings, fixedMeasurer(2))
+	assert.Len(t, empty.pages, 1)
+	assert.Empty(t, empty.pages[0])
+
+	tall := &Grid{Rows: textRows([][]string{{"Note"}, {strings.Repeat("x", 20000)}, {"after"}})}
+	layout = layoutPages(tall, settings, fixedMeasurer(2))
+	assert.Equal(t, [][]int{{1}, {2}}, layout.pages, "a row taller than a page gets a page of its own")
+}
+
+func sum(values []float64) float64 {
+	total := 0.0
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
@@ -0,0 +1,396 @@
+package render
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gujiaweiguo/goreport/internal/dataset"
+)
+
+// ErrInvalidParameter is returned when a report's parameters are declared
+// wrongly or a supplied value does not fit its parameter.
+var ErrInvalidParameter = errors.New("invalid report parameter")
+
+// Parameter types.
+const (
+	ParamString      = "string"
+	ParamNumber      = "number"
+	ParamDate        = "date"
+	ParamDateRange   = "dateRange"
+	ParamSelect      = "select"
+	ParamMultiSelect = "multiSelect"
+)
+
+// parameterDateLayout is the form date parameters are given in and passed
+// to filters as.
+const parameterDateLayout = "2006-01-02"
+
+var (
+	parameterNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	parameterRefPattern  = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(?:\.(start|end))?\}`)
+)
+
+// Parameter is a typed report parameter. Region filters refer to it by a
+// value of "${name}", or "${name.start}" and "${name.end}" for the bounds of
+// a date range; the reference may also be part of a longer text, as in
+// "%${name}%" for a like filter. A filter whose parameter has no value is
+// left out, so an optional parameter that is not given filters nothing.
+//
+// Select parameters take one of Options, multi-select parameters a list of
+// them for an "in" filter; OptionsSource takes the options from the
+// distinct values of a dataset field instead.
+type Parameter struct {
+	Name          string           `json:"name"`
+	Label         string           `json:"label"`
+	Type          string           `json:"type"`
+	Required      bool             `json:"required"`
+	Default       interface{}      `json:"default"`
+	Options       []interface{}    `json:"options"`
+	OptionsSource *ParameterSource `json:"optionsSource,omitempty"`
+}
+
+// ParameterSource is the dataset field a select parameter's options come from.
+type ParameterSource struct {
+	DatasetID string `json:"datasetId"`
+	Field     string `json:"field"`
+}
+
+// normalizeParameters checks the parameter declarations, defaulting the
+// type to string.
+func normalizeParameters(parameters []Parameter) error {
+	seen := make(map[string]bool)
+	for i := range parameters {
+		p := &parameters[i]
+		if !parameterNamePattern.MatchString(p.Name) {
+			return fmt.Errorf("%w: invalid name %q", ErrInvalidParameter, p.Name)
+		}
+		if p.Name == "page" || p.Name == "pageSize" {
+			return fmt.Errorf("%w: %q is reserved for paging", ErrInvalidParameter, p.Name)
+		}
+		if seen[p.Name] {
+			return fmt.Errorf("%w: %q is declared twice", ErrInvalidParameter, p.Name)
+		}
+		seen[p.Name] = true
+
+		switch p.Type {
+		case "":
+			p.Type = ParamString
+		case ParamString, ParamNumber, ParamDate, ParamDateRange, ParamSelect, ParamMultiSelect:
+		default:
+			return fmt.Errorf("%w: %s has unsupported type %q", ErrInvalidParameter, p.Name, p.Type)
+		}
+		if p.OptionsSource != nil && (p.OptionsSource.DatasetID == "" || p.OptionsSource.Field == "") {
+			return fmt.Errorf("%w: %s options need a datasetId and field", ErrInvalidParameter, p.Name)
+		}
+	}
+	return nil
+}
+
+// resolveParameters validates params against the report's parameters and
+// returns their values by name, defaults filled in. Every value is checked
+// before the options of select parameters are looked up, so a bad value
+// fails before any dataset is queried.
+func (e *Engine) resolveParameters(ctx context.Context, parameters []Parameter, params map[string]interface{}, tenantID string) (map[string]interface{}, error) {
+	if err := normalizeParameters(parameters); err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]interface{})
+	for _, p := range parameters {
+		raw := params[p.Name]
+		if isEmptyParameter(raw) {
+			raw = p.Default
+		}
+		if isEmptyParameter(raw) {
+			if p.Required {
+				return nil, fmt.Errorf("%w: %s is required", ErrInvalidParameter, p.Name)
+			}
+			continue
+		}
+		value, err := coerceParameter(p, raw)
+		if err != nil {
+			return nil, err
+		}
+		values[p.Name] = value
+	}
+
+	for _, p := range parameters {
+		value, ok := values[p.Name]
+		if !ok || (p.Type != ParamSelect && p.Type != ParamMultiSelect) {
+			continue
+		}
+		options, err := e.parameterOptions(ctx, p, tenantID)
+		if err != nil {
+			return nil, err
+		}
+		if options == nil {
+			continue
+		}
+		if p.Type == ParamSelect {
+			if values[p.Name], err = matchOption(p, value, options); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		selected := value.([]interface{})
+		for i := range selected {
+			if selected[i], err = matchOption(p, selected[i], options); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return values, nil
+}
+
+// Parameters returns the parameters a report declares, with the options of
+// dataset-backed select parameters filled in.
+func (e *Engine) Parameters(ctx context.Context, configJSON string, tenantID string) ([]Parameter, error) {
+	config, err := parseConfig(configJSON)
+	if err != nil {
+		return nil, err
+	}
+	if err := normalizeParameters(config.Parameters); err != nil {
+		return nil, err
+	}
+	parameters := config.Parameters
+	if parameters == nil {
+		parameters = []Parameter{}
+	}
+	for i := range parameters {
+		if parameters[i].OptionsSource == nil {
+			continue
+		}
+		options, err := e.parameterOptions(ctx, parameters[i], tenantID)
+		if err != nil {
+			return nil, err
+		}
+		parameters[i].Options = options
+	}
+	return parameters, nil
+}
+
+// parameterOptions returns the values a select parameter may take, or nil
+// when any value is allowed.
+func (e *Engine) parameterOptions(ctx context.Context, p Parameter, tenantID string) ([]interface{}, error) {
+	if p.OptionsSource == nil {
+		return p.Options, nil
+	}
+	field := p.OptionsSource.Field
+	records, err := e.queryDataset(ctx, tenantID, &dataset.QueryRequest{
+		DatasetID: p.OptionsSource.DatasetID,
+		Fields:    []string{field},
+		GroupBy:   []string{field},
+		SortBy:    field,
+		SortOrder: "asc",
+	})
+	if err != nil {
+		return nil, err
+	}
+	options := make([]interface{}, 0, len(records))
+	for _, record := range records {
+		if value := record[field]; value != nil {
+			if b, ok := value.([]byte); ok {
+				value = string(b)
+			}
+			options = append(options, value)
+		}
+	}
+	return options, nil
+}
+
+// matchOption returns the option equal to value, compared as text so "3"
+// picks the option 3.
+func matchOption(p Parameter, value interface{}, options []interface{}) (interface{}, error) {
+	text := valueText(value)
+	for _, option := range options {
+		if valueText(option) == text {
+			return option, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %q is not an option of %s", ErrInvalidParameter, text, p.Name)
+}
+
+// coerceParameter converts a supplied value to its parameter's type:
+// float64 for numbers, "2006-01-02" text for dates, a [2]string for date
+// ranges and a list for multi-select.
+func coerceParameter(p Parameter, raw interface{}) (interface{}, error) {
+	invalid := func(reason string) error {
+		return fmt.Errorf("%w: %s %s", ErrInvalidParameter, p.Name, reason)
+	}
+	switch p.Type {
+	case ParamString:
+		if isScalarParameter(raw) {
+			return valueText(raw), nil
+		}
+		return nil, invalid("must be text")
+	case ParamNumber:
+		if number, ok := toFloat(raw); ok {
+			return number, nil
+		}
+		if text, ok := raw.(string); ok {
+			if number, err := strconv.ParseFloat(strings.TrimSpace(text), 64); err == nil {
+				return number, nil
+			}
+		}
+		return nil, invalid("must be a number")
+	case ParamDate:
+		date, ok := parameterDate(raw)
+		if !ok {
+			return nil, invalid("must be a date (yyyy-MM-dd)")
+		}
+		return date, nil
+	case ParamDateRange:
+		var start, end interface{}
+		switch v := raw.(type) {
+		case []interface{}:
+			if len(v) != 2 {
+				return nil, invalid("must be a start and end date")
+			}
+			start, end = v[0], v[1]
+		case map[string]interface{}:
+			start, end = v["start"], v["end"]
+		default:
+			return nil, invalid("must be a start and end date")
+		}
+		startDate, ok1 := parameterDate(start)
+		endDate, ok2 := parameterDate(end)
+		if !ok1 || !ok2 {
+			return nil, invalid("must be a start and end date (yyyy-MM-dd)")
+		}
+		if startDate > endDate {
+			return nil, invalid("starts after it ends")
+		}
+		return [2]string{startDate, endDate}, nil
+	case ParamSelect:
+		if isScalarParameter(raw) {
+			return raw, nil
+		}
+		return nil, invalid("must be a single value")
+	case ParamMultiSelect:
+		list, ok := raw.([]interface{})
+		if !ok {
+			if !isScalarParameter(raw) {
+				return nil, invalid("must be a list of values")
+			}
+			list = []interface{}{raw}
+		}
+		selected := make([]interface{}, 0, len(list))
+		for _, item := range list {
+			if !isScalarParameter(item) {
+				return nil, invalid("must be a list of values")
+			}
+			selected = append(selected, item)
+		}
+		return selected, nil
+	}
+	return nil, invalid("has an unsupported type")
+}
+
+// parameterDate reads a date given as "2006-01-02" or an RFC 3339 time.
+func parameterDate(value interface{}) (string, bool) {
+	text, ok := value.(string)
+	if !ok {
+		return "", false
+	}
+	text = strings.TrimSpace(text)
+	if t, err := time.Parse(parameterDateLayout, text); err == nil {
+		return t.Format(parameterDateLayout), true
+	}
+	if t, err := time.Parse(time.RFC3339, text); err == nil {
+		return t.Format(parameterDateLayout), true
+	}
+	return "", false
+}
+
+func isScalarParameter(value interface{}) bool {
+	switch value.(type) {
+	case string, float64, int, int64, bool:
+		return true
+	}
+	return false
+}
+
+func isEmptyParameter(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return strings.TrimSpace(v) == ""
+	case []interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+// substituteFilters returns filters with parameter references replaced by
+// values, leaving out the filters that refer to a parameter with no value.
+func substituteFilters(filters []dataset.Filter, parameters []Parameter, values map[string]interface{}) ([]dataset.Filter, error) {
+	declared := make(map[string]string, len(parameters))
+	for _, p := range parameters {
+		declared[p.Name] = p.Type
+	}
+
+	substituted := make([]dataset.Filter, 0, len(filters))
+	for _, filter := range filters {
+		text, ok := filter.Value.(string)
+		if !ok || !parameterRefPattern.MatchString(text) {
+			substituted = append(substituted, filter)
+			continue
+		}
+
+		missing := false
+		var refErr error
+		lookup := func(name, bound string) interface{} {
+			kind, ok := declared[name]
+			switch {
+			case !ok:
+				refErr = fmt.Errorf("%w: filter on %s refers to undeclared %q", ErrInvalidParameter, filter.Field, name)
+				return nil
+			case (kind == ParamDateRange) != (bound != ""):
+				refErr = fmt.Errorf("%w: only a date range parameter takes .start or .end, and it needs one", ErrInvalidParameter)
+				return nil
+			}
+			value, ok := values[name]
+			if !ok {
+				missing = true
+				return nil
+			}
+			if dates, ok := value.([2]string); ok {
+				if bound == "start" {
+					return dates[0]
+				}
+				return dates[1]
+			}
+			return value
+		}
+
+		if match := parameterRefPattern.FindStringSubmatch(text); match[0] == text {
+			filter.Value = lookup(match[1], match[2])
+		} else {
+			filter.Value = parameterRefPattern.ReplaceAllStringFunc(text, func(ref string) string {
+				match := parameterRefPattern.FindStringSubmatch(ref)
+				value := lookup(match[1], match[2])
+				if list, ok := value.([]interface{}); ok {
+					parts := make([]string, len(list))
+					for i, item := range list {
+						parts[i] = valueText(item)
+					}
+					return strings.Join(parts, ",")
+				}
+				return valueText(value)
+			})
+		}
+		if refErr != nil {
+			return nil, refErr
+		}
+		if !missing {
+			substituted = append(substituted, filter)
+		}
+	}
+	return substituted, nil
+}
@@ -0,0 +1,158 @@
+package render
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gujiaweiguo/goreport/internal/dataset"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCoerceParameter(t *testing.T) {
+	tests := []struct {
+		param Parameter
+		raw   interface{}
+		want  interface{}
+	}{
+		{Parameter{Type: ParamString}, 42.0, "42"},
+		{Parameter{Type: ParamNumber}, "3.5", 3.5},
+		{Parameter{Type: ParamNumber}, 7.0, 7.0},
+		{Parameter{Type: ParamDate}, "2026-03-01T10:00:00Z", "2026-03-01"},
+		{Parameter{Type: ParamDateRange}, []interface{}{"2026-01-01", "2026-01-31"}, [2]string{"2026-01-01", "2026-01-31"}},
+		{Parameter{Type: ParamDateRange}, map[string]interface{}{"start": "2026-02-01", "end": "2026-02-01"}, [2]string{"2026-02-01", "2026-02-01"}},
+		{Parameter{Type: ParamMultiSelect}, "North", []interface{}{"North"}},
+	}
+	for _, tt := range tests {
+		got, err := coerceParameter(tt.param, tt.raw)
+		require.NoError(t, err, tt.param.Type)
+		assert.Equal(t, tt.want, got, tt.param.Type)
+	}
+
+	invalid := []struct {
+		param Parameter
+		raw   interface{}
+	}{
+		{Parameter{Type: ParamNumber}, "ten"},
+		{Parameter{Type: ParamDate}, "01/03/2026"},
+		{Parameter{Type: ParamDateRange}, []interface{}{"2026-02-01", "2026-01-01"}},
+		{Parameter{Type: ParamDateRange}, "2026-02-01"},
+		{Parameter{Type: ParamSelect}, []interface{}{"a"}},
+		{Parameter{Type: ParamString}, map[string]interface{}{}},
+	}
+	for _, tt := range invalid {
+		_, err := coerceParameter(tt.param, tt.raw)
+		assert.ErrorIs(t, err, ErrInvalidParameter, tt.param.Type)
+	}
+}
+
+func TestNormalizeParameters(t *testing.T) {
+	parameters := []Parameter{{Name: "region"}}
+	require.NoError(t, normalizeParameters(parameters))
+	assert.Equal(t, ParamString, parameters[0].Type)
+
+	invalid := [][]Parameter{
+		{{Name: "bad name"}},
+		{{Name: "page"}},
+		{{Name: "a"}, {Name: "a"}},
+		{{Name: "a", Type: "color"}},
+		{{Name: "a", Type: ParamSelect, OptionsSource: &ParameterSource{DatasetID: "ds-1"}}},
+	}
+	for _, parameters := range invalid {
+		assert.ErrorIs(t, normalizeParameters(parameters), ErrInvalidParameter)
+	}
+}
+
+func TestEngine_ResolveParameters(t *testing.T) {
+	executor := &fakeQueryExecutor{records: salesRecords()}
+	engine := NewEngine(nil, nil, executor)
+	parameters := []Parameter{
+		{Name: "region", Type: ParamSelect, OptionsSource: &ParameterSource{DatasetID: "ds-1", Field: "region"}},
+		{Name: "minAmount", Type: ParamNumber, Default: 5.0},
+		{Name: "month", Type: ParamDate, Required: true},
+		{Name: "size", Type: ParamSelect, Options: []interface{}{1.0, 2.0}},
+		{Name: "note"},
+	}
+
+	values, err := engine.resolveParameters(context.Background(), parameters, map[string]interface{}{
+		"region": "South", "month": "2026-05-01", "size": "2", "page": 1.0,
+	}, "tenant-1")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"region": "South", "minAmount": 5.0, "month": "2026-05-01", "size": 2.0}, values)
+	require.Len(t, executor.requests, 1)
+	assert.Equal(t, []string{"region"}, executor.requests[0].GroupBy)
+
+	_, err = engine.resolveParameters(context.Background(), parameters, map[string]interface{}{"region": "East", "month": "2026-05-01"}, "tenant-1")
+	assert.ErrorIs(t, err, ErrInvalidParameter)
+
+	// A missing value fails before the options are queried.
+	executor.requests = nil
+	_, err = engine.resolveParameters(context.Background(), parameters, map[string]interface{}{"region": "South"}, "tenant-1")
+	assert.ErrorIs(t, err, ErrInvalidParameter)
+	assert.Empty(t, executor.requests)
+}
+
+func TestSubstituteFilters(t *testing.T) {
+	parameters := []Parameter{
+		{Name: "region", Type: ParamMultiSelect},
+		{Name: "period", Type: ParamDateRange},
+		{Name: "product"},
+		{Name: "minAmount", Type: ParamNumber},
+	}
+	values := map[string]interface{}{
+		"region":  []interface{}{"North", "South"},
+		"period":  [2]string{"2026-01-01", "2026-01-31"},
+		"product": "tea",
+	}
+	filters := []dataset.Filter{
+		{Field: "region", Operator: "in", Value: "${region}"},
+		{Field: "day", Operator: "gte", Value: "${period.start}"},
+		{Field: "day", Operator: "lte", Value: "${period.end}"},
+		{Field: "product", Operator: "like", Value: "%${product}%"},
+		{Field: "amount", Operator: "gte", Value: "${minAmount}"},
+		{Field: "status", Operator: "eq", Value: "active"},
+	}
+
+	substituted, err := substituteFilters(filters, parameters, values)
+	require.NoError(t, err)
+	assert.Equal(t, []dataset.Filter{
+		{Field: "region", Operator: "in", Value: []interface{}{"North", "South"}},
+		{Field: "day", Operator: "gte", Value: "2026-01-01"},
+		{Field: "day", Operator: "lte", Value: "2026-01-31"},
+		{Field: "product", Operator: "like", Value: "%tea%"},
+		{Field: "status", Operator: "eq", Value: "active"},
+	}, substituted)
+
+	for _, value := range []string{"${unknown}", "${period}", "${product.start}"} {
+		_, err := substituteFilters([]dataset.Filter{{Field: "x", Value: value}}, parameters, values)
+		assert.ErrorIs(t, err, ErrInvalidParameter, value)
+	}
+}
+
+func TestEngine_RenderGrid_Parameters(t *testing.T) {
+	executor := &fakeQueryExecutor{records: salesRecords()[:1]}
+	engine := NewEngine(nil, nil, executor)
+	config := `{
+		"parameters": [{"name": "region", "required": true}, {"name": "period", "type": "dateRange"}],
+		"cells": [{"row": 0, "col": 0, "fieldName": "product"}],
+		"regions": [{"datasetId": "ds-1", "detail": {"start": 0}, "filters": [
+			{"field": "region", "operator": "eq", "value": "${region}"},
+			{"field": "day", "operator": "gte", "value": "${period.start}"}
+		]}]
+	}`
+
+	grid, err := engine.RenderGrid(context.Background(), config, map[string]interface{}{"region": "North"}, "tenant-1")
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"Tea"}}, gridText(grid))
+	require.Len(t, executor.requests, 1)
+	assert.Equal(t, []dataset.Filter{{Field: "region", Operator: "eq", Value: "North"}}, executor.requests[0].Filters)
+
+	_, err = engine.Render(context.Background(), config, nil, "tenant-1")
+	assert.ErrorIs(t, err, ErrInvalidParameter)
+	assert.Len(t, executor.requests, 1)
+
+	parameters, err := engine.Parameters(context.Background(), config, "tenant-1")
+	require.NoError(t, err)
+	require.Len(t, parameters, 2)
+	assert.Equal(t, ParamString, parameters[0].Type)
+}
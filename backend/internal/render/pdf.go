@@ -0,0 +1,188 @@
+package render
+
+import (
+	"fmt"
+	"image/color"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/gujiaweiguo/goreport/internal/config"
+	"github.com/jung-kurt/gofpdf"
+)
+
+const pdfFontFamily = "report"
+
+var (
+	exportFontMu sync.RWMutex
+	exportFont   []byte
+
+	// pdfCompression is switched off in tests so page content can be read.
+	pdfCompression = true
+)
+
+// InitExport loads the TrueType font used for PDF and image exports. Without
+// one the exporters fall back to fonts that only cover Western text.
+func InitExport(cfg *config.ExportConfig) error {
+	var font []byte
+	if cfg.PDFFont != "" {
+		data, err := os.ReadFile(cfg.PDFFont)
+		if err != nil {
+			return fmt.Errorf("failed to load export font: %w", err)
+		}
+		font = data
+	}
+	exportFontMu.Lock()
+	exportFont = font
+	exportFontMu.Unlock()
+	return nil
+}
+
+func loadExportFont() []byte {
+	exportFontMu.RLock()
+	defer exportFontMu.RUnlock()
+	return exportFont
+}
+
+// WritePDF lays the grid out as a paginated PDF using the grid's print
+// settings. Header rows repeat at the top of every page and each page
+// carries a "page / total" footer.
+func WritePDF(w io.Writer, grid *Grid) error {
+	settings, err := NormalizePrintConfig(grid.Print)
+	if err != nil {
+		return err
+	}
+
+	orientation := "P"
+	if settings.Orientation == "landscape" {
+		orientation = "L"
+	}
+	size := pageSizes[settings.PageSize]
+	pdf := gofpdf.NewCustom(&gofpdf.InitType{
+		OrientationStr: orientation,
+		UnitStr:        "mm",
+		Size:           gofpdf.SizeType{Wd: size[0], Ht: size[1]},
+	})
+	pdf.SetCompression(pdfCompression)
+	pdf.SetMargins(pageMargin, pageMargin, pageMargin)
+	pdf.SetAutoPageBreak(false, 0)
+	pdf.SetCellMargin(0)
+	pdf.AliasNbPages("")
+	if grid.Title != "" {
+		pdf.SetTitle(grid.Title, true)
+	}
+
+	m := newPDFMeasurer(pdf)
+	pdf.SetFooterFunc(func() {
+		m.setFont(fontSpec{size: fontSizePt})
+		pdf.SetTextColor(0, 0, 0)
+		pdf.SetY(-pageMargin - lineHeight)
+		pdf.CellFormat(0, lineHeight, fmt.Sprintf("%d / {nb}", pdf.PageNo()), "", 0, "C", false, 0, "")
+	})
+
+	layout := layoutPages(grid, settings, m)
+	for page := range layout.pages {
+		pdf.AddPage()
+		// Borders go on after every fill so a neighbour's background
+		// cannot paint over them.
+		layout.eachCell(page, func(r, c int, x, y, width, height float64) {
+			cell := &grid.Rows[r][c]
+			if fill, ok := cellFill(cell, r < layout.headerRows); ok {
+				setPDFColor(pdf.SetFillColor, fill)
+				pdf.Rect(x, y, width, height, "F")
+			}
+			font := cell.Style.font()
+			m.setFont(font)
+			setPDFColor(pdf.SetTextColor, cell.Style.textColor())
+			align := map[string]string{"left": "L", "center": "C", "right": "R"}[cell.Style.align()]
+			lines := layout.lines[r][c]
+			top := textTop(y, height, lines, font, cell.Style.verticalAlign())
+			for i, line := range lines {
+				pdf.SetXY(x+cellPadding, top+float64(i)*font.lineHeight())
+				pdf.CellFormat(width-2*cellPadding, font.lineHeight(), m.translate(line), "", 0, align, false, 0, "")
+			}
+		})
+		layout.eachCell(page, func(r, c int, x, y, width, height float64) {
+			border, ok := grid.Rows[r][c].Style.border()
+			if !ok {
+				return
+			}
+			setPDFColor(pdf.SetDrawColor, border.color)
+			pdf.SetLineWidth(border.width)
+			switch {
+			case border.dashed:
+				pdf.SetDashPattern([]float64{max(3*border.width, 1), max(2*border.width, 0.6)}, 0)
+			case border.dotted:
+				pdf.SetDashPattern([]float64{border.width, 2 * border.width}, 0)
+			default:
+				pdf.SetDashPattern([]float64{}, 0)
+			}
+			pdf.Rect(x, y, width, height, "D")
+		})
+		pdf.SetDashPattern([]float64{}, 0)
+	}
+
+	return pdf.Output(w)
+}
+
+// cellFill returns the background of a cell: its own, or the shading of a
+// header row.
+func cellFill(cell *GridCell, header bool) (color.NRGBA, bool) {
+	if fill, ok := cell.Style.background(); ok {
+		return fill, true
+	}
+	if header {
+		return headerFill, true
+	}
+	return color.NRGBA{}, false
+}
+
+func setPDFColor(set func(r, g, b int), c color.NRGBA) {
+	c = opaque(c)
+	set(int(c.R), int(c.G), int(c.B))
+}
+
+// pdfMeasurer measures and selects text in the PDF's fonts. With the
+// Helvetica fallback text is translated to cp1252 first, as it is when
+// drawn. The export font has a single face, registered once per style.
+type pdfMeasurer struct {
+	pdf        *gofpdf.Fpdf
+	font       []byte
+	registered map[string]bool
+	translate  func(string) string
+}
+
+func newPDFMeasurer(pdf *gofpdf.Fpdf) *pdfMeasurer {
+	m := &pdfMeasurer{pdf: pdf, font: loadExportFont(), registered: make(map[string]bool)}
+	if len(m.font) > 0 {
+		m.translate = func(s string) string { return s }
+	} else {
+		m.translate = pdf.UnicodeTranslatorFromDescriptor("")
+	}
+	m.setFont(fontSpec{size: fontSizePt})
+	return m
+}
+
+func (m *pdfMeasurer) setFont(font fontSpec) {
+	style := ""
+	if font.bold {
+		style += "B"
+	}
+	if font.italic {
+		style += "I"
+	}
+	if len(m.font) == 0 {
+		m.pdf.SetFont("Helvetica", style, font.size)
+		return
+	}
+	if !m.registered[style] {
+		m.pdf.AddUTF8FontFromBytes(pdfFontFamily, style, m.font)
+		m.registered[style] = true
+	}
+	m.pdf.SetFont(pdfFontFamily, style, font.size)
+}
+
+func (m *pdfMeasurer) textWidth(text string, font fontSpec) float64 {
+	m.setFont(font)
+	return m.pdf.GetStringWidth(m.translate(text))
+}
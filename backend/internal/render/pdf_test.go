@@ -0,0 +1,107 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gujiaweiguo/goreport/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestPDF(t *testing.T, grid *Grid) string {
+	t.Helper()
+	pdfCompression = false
+	t.Cleanup(func() { pdfCompression = true })
+
+	var out bytes.Buffer
+	require.NoError(t, WritePDF(&out, grid))
+	return out.String()
+}
+
+func testGrid(rows int) *Grid {
+	text := [][]string{{"Region", "Amount"}}
+	for i := 1; i < rows; i++ {
+		text = append(text, []string{fmt.Sprintf("region-%03d", i), fmt.Sprintf("%d", i*10)})
+	}
+	return &Grid{Rows: textRows(text)}
+}
+
+// textRows builds unstyled grid rows from cell text.
+func textRows(text [][]string) [][]GridCell {
+	rows := make([][]GridCell, len(text))
+	for r := range text {
+		rows[r] = make([]GridCell, len(text[r]))
+		for c := range text[r] {
+			rows[r][c].Text = text[r][c]
+		}
+	}
+	return rows
+}
+
+// gridText returns the text of every grid cell.
+func gridText(grid *Grid) [][]string {
+	text := make([][]string, len(grid.Rows))
+	for r, row := range grid.Rows {
+		text[r] = make([]string, len(row))
+		for c := range row {
+			text[r][c] = row[c].Text
+		}
+	}
+	return text
+}
+
+func TestWritePDF_Pagination(t *testing.T) {
+	grid := testGrid(120)
+	grid.Print = PrintConfig{HeaderRows: 1}
+	doc := writeTestPDF(t, grid)
+
+	assert.True(t, strings.HasPrefix(doc, "%PDF-"))
+	pages := strings.Count(doc, "/Type /Page\n")
+	assert.Equal(t, 4, pages)
+	assert.Equal(t, pages, strings.Count(doc, "(Region)"), "header row repeats on every page")
+	assert.Contains(t, doc, "(1 / 4)")
+	assert.Contains(t, doc, "(4 / 4)")
+	assert.Equal(t, 1, strings.Count(doc, "(region-119)"))
+
+	grid.Print = PrintConfig{Orientation: "landscape"}
+	doc = writeTestPDF(t, grid)
+	landscapePages := strings.Count(doc, "/Type /Page\n")
+	assert.Greater(t, landscapePages, pages)
+	assert.Equal(t, 1, strings.Count(doc, "(Region)"), "no header rows configured")
+	assert.Contains(t, doc, "/MediaBox [0 0 841.89 595.28]")
+
+	grid.Print = PrintConfig{PageSize: "tabloid"}
+	assert.ErrorIs(t, WritePDF(&bytes.Buffer{}, grid), ErrInvalidPrintConfig)
+}
+
+func TestWritePDF_WrapsWideTables(t *testing.T) {
+	long := strings.Repeat("quarterly revenue ", 40)
+	grid := &Grid{Rows: textRows([][]string{{"Note", "Total"}, {long, "1"}})}
+	doc := writeTestPDF(t, grid)
+
+	assert.Equal(t, 1, strings.Count(doc, "/Type /Page\n"))
+	assert.NotContains(t, doc, "("+strings.TrimSpace(long)+")", "long text is wrapped, not drawn past the page")
+	assert.Contains(t, doc, "(quarterly revenue quarterly revenue")
+}
+
+func TestInitExport(t *testing.T) {
+	err := InitExport(&config.ExportConfig{PDFFont: "/nonexistent/font.ttf"})
+	assert.ErrorContains(t, err, "failed to load export font")
+
+	require.NoError(t, InitExport(&config.ExportConfig{}))
+	assert.Empty(t, loadExportFont())
+}
+
+func TestWritePDF_StylesAndMerges(t *testing.T) {
+	doc := writeTestPDF(t, styledGrid())
+
+	assert.Contains(t, doc, "0.122 0.306 0.475 rg", "title background")
+	assert.Contains(t, doc, "1.000 0.000 0.000 RG", "red border on the total")
+	assert.Contains(t, doc, "[2.83 1.70] 0.00 d", "dashed border")
+	assert.Contains(t, doc, "/Helvetica-Bold")
+	assert.Equal(t, 1, strings.Count(doc, "(Quarterly sales)"))
+	assert.Contains(t, doc, "(61.7%)")
+}
@@ -0,0 +1,491 @@
+package render
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gujiaweiguo/goreport/internal/dataset"
+)
+
+// defaultPivotColumns is the widest a pivot may grow when it sets no
+// MaxColumns; maxPivotColumns is the most MaxColumns may allow.
+const (
+	defaultPivotColumns = 100
+	maxPivotColumns     = 1000
+)
+
+// Pivot is a cross-tab anchored at a template cell: the distinct values of
+// the Rows fields run down, those of the Columns fields across, and each
+// measure is aggregated where they meet. The pivot takes the anchor's row
+// and as many more as it needs; template rows below it move down, and cells
+// right of the anchor move right.
+//
+// With Subtotals every dimension but the innermost gets a subtotal after
+// each of its values. RowTotals adds a total column at the right and
+// ColumnTotals a total row at the bottom. A pivot wider than MaxColumns,
+// row headers included, fails rather than drop columns.
+type Pivot struct {
+	DatasetID     string            `json:"datasetId"`
+	Row           int               `json:"row"`
+	Col           int               `json:"col"`
+	Rows          []string          `json:"rows"`
+	Columns       []string          `json:"columns"`
+	Measures      []PivotMeasure    `json:"measures"`
+	Filters       []dataset.Filter  `json:"filters"`
+	Labels        map[string]string `json:"labels"`
+	Subtotals     bool              `json:"subtotals"`
+	RowTotals     bool              `json:"rowTotals"`
+	ColumnTotals  bool              `json:"columnTotals"`
+	TotalLabel    string            `json:"totalLabel"`
+	SubtotalLabel string            `json:"subtotalLabel"`
+	MaxColumns    int               `json:"maxColumns"`
+	HeaderStyle   *CellStyle        `json:"headerStyle"`
+	Style         *CellStyle        `json:"style"`
+}
+
+// PivotMeasure is a field aggregated in a pivot's cells with SUM, AVG,
+// COUNT, MIN or MAX. Format is the mask of its cells.
+type PivotMeasure struct {
+	Field       string `json:"field"`
+	Aggregation string `json:"aggregation"`
+	Label       string `json:"label"`
+	Format      string `json:"format"`
+}
+
+// normalizePivots fills in pivot defaults and checks that each pivot can be
+// queried and has a row of its own.
+func normalizePivots(pivots []Pivot, regions []Region) error {
+	anchors := make(map[int]bool)
+	for i := range pivots {
+		p := &pivots[i]
+		if p.DatasetID == "" {
+			return fmt.Errorf("%w: pivot datasetId is required", ErrInvalidRegion)
+		}
+		if p.Row < 0 || p.Col < 0 {
+			return fmt.Errorf("%w: pivot anchor must not be negative", ErrInvalidRegion)
+		}
+		if anchors[p.Row] {
+			return fmt.Errorf("%w: pivots share row %d", ErrInvalidRegion, p.Row)
+		}
+		anchors[p.Row] = true
+		for _, region := range regions {
+			top, bottom := region.span()
+			if !region.horizontal() && p.Row >= top && p.Row < bottom {
+				return fmt.Errorf("%w: pivot at row %d is inside a list region", ErrInvalidRegion, p.Row)
+			}
+		}
+
+		seen := make(map[string]bool)
+		for _, field := range append(append([]string(nil), p.Rows...), p.Columns...) {
+			if field == "" || seen[field] {
+				return fmt.Errorf("%w: pivot dimensions must be distinct fields", ErrInvalidRegion)
+			}
+			seen[field] = true
+		}
+		if len(p.Measures) == 0 {
+			return fmt.Errorf("%w: pivot needs a measure", ErrInvalidRegion)
+		}
+		for m := range p.Measures {
+			measure := &p.Measures[m]
+			measure.Aggregation = strings.ToUpper(measure.Aggregation)
+			switch measure.Aggregation {
+			case "":
+				measure.Aggregation = "SUM"
+			case "SUM", "AVG", "COUNT", "MIN", "MAX":
+			default:
+				return fmt.Errorf("%w: unsupported aggregation %q", ErrInvalidRegion, measure.Aggregation)
+			}
+			if measure.Field == "" {
+				return fmt.Errorf("%w: pivot measure field is required", ErrInvalidRegion)
+			}
+		}
+
+		switch {
+		case p.MaxColumns == 0:
+			p.MaxColumns = defaultPivotColumns
+		case p.MaxColumns < 0 || p.MaxColumns > maxPivotColumns:
+			return fmt.Errorf("%w: maxColumns must be between 1 and %d", ErrInvalidRegion, maxPivotColumns)
+		}
+		if p.TotalLabel == "" {
+			p.TotalLabel = "Total"
+		}
+		if p.SubtotalLabel == "" {
+			p.SubtotalLabel = "Subtotal"
+		}
+	}
+	return nil
+}
+
+// queryRequest groups the dataset by every dimension. An average is asked
+// for as a sum and a count so that totals can be averaged correctly.
+func (p *Pivot) queryRequest() *dataset.QueryRequest {
+	dimensions := append(append([]string(nil), p.Rows...), p.Columns...)
+	aggregations := make(map[string]dataset.Aggregation)
+	for i, measure := range p.Measures {
+		for _, function := range measure.functions() {
+			aggregations[pivotAlias(i, function)] = dataset.Aggregation{Function: function, Field: measure.Field}
+		}
+	}
+	return &dataset.QueryRequest{
+		DatasetID:    p.DatasetID,
+		Fields:       dimensions,
+		GroupBy:      dimensions,
+		Aggregations: aggregations,
+		Filters:      p.Filters,
+	}
+}
+
+func (m PivotMeasure) functions() []string {
+	if m.Aggregation == "AVG" {
+		return []string{"SUM", "COUNT"}
+	}
+	return []string{m.Aggregation}
+}
+
+func pivotAlias(measure int, function string) string {
+	return fmt.Sprintf("pivot_%d_%s", measure, strings.ToLower(function))
+}
+
+func (p *Pivot) label(field string) string {
+	if label := p.Labels[field]; label != "" {
+		return label
+	}
+	return field
+}
+
+// pivotSlot is one row, or one column group, of a pivot: the dimension
+// values it stands for, fewer than the dimensions for a subtotal or total.
+type pivotSlot struct {
+	values []string
+}
+
+// pivotTuple is the values of one record's dimensions.
+type pivotTuple struct {
+	raw  []interface{}
+	text []string
+}
+
+// pivotSlots orders the distinct tuples and lays them out as slots, with
+// subtotals after each value of the outer dimensions and a total at the end.
+func pivotSlots(tuples []pivotTuple, dimensions int, subtotals, total bool) []pivotSlot {
+	sort.SliceStable(tuples, func(i, j int) bool {
+		for d := 0; d < dimensions; d++ {
+			if c := compareValues(tuples[i].raw[d], tuples[j].raw[d]); c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+
+	var slots []pivotSlot
+	var walk func(group []pivotTuple, level int)
+	walk = func(group []pivotTuple, level int) {
+		if level == dimensions {
+			slots = append(slots, pivotSlot{values: group[0].text})
+			return
+		}
+		for start := 0; start < len(group); {
+			end := start + 1
+			for end < len(group) && group[end].text[level] == group[start].text[level] {
+				end++
+			}
+			walk(group[start:end], level+1)
+			if subtotals && level+1 < dimensions {
+				slots = append(slots, pivotSlot{values: group[start].text[:level+1]})
+			}
+			start = end
+		}
+	}
+	if dimensions > 0 && len(tuples) > 0 {
+		walk(tuples, 0)
+	}
+	if total || dimensions == 0 {
+		slots = append(slots, pivotSlot{})
+	}
+	return slots
+}
+
+// pivotValue accumulates one measure over the grouped rows a cell covers.
+type pivotValue struct {
+	sum, count, min, max float64
+	has                  bool
+}
+
+func (v *pivotValue) add(measure PivotMeasure, record map[string]interface{}, index int) {
+	number := func(function string) (float64, bool) {
+		return toFloat(parsedValue(record[pivotAlias(index, function)]))
+	}
+	switch measure.Aggregation {
+	case "SUM", "AVG":
+		if n, ok := number("SUM"); ok {
+			v.sum += n
+			v.has = true
+		}
+		if measure.Aggregation == "SUM" {
+			return
+		}
+		fallthrough
+	case "COUNT":
+		if n, ok := number("COUNT"); ok {
+			v.count += n
+			v.has = true
+		}
+	case "MIN", "MAX":
+		n, ok := number(measure.Aggregation)
+		if !ok {
+			return
+		}
+		if !v.has || n < v.min {
+			v.min = n
+		}
+		if !v.has || n > v.max {
+			v.max = n
+		}
+		v.has = true
+	}
+}
+
+func (v *pivotValue) result(aggregation string) (float64, bool) {
+	if !v.has {
+		return 0, false
+	}
+	switch aggregation {
+	case "SUM":
+		return v.sum, true
+	case "COUNT":
+		return v.count, true
+	case "AVG":
+		if v.count == 0 {
+			return 0, false
+		}
+		return v.sum / v.count, true
+	case "MIN":
+		return v.min, true
+	case "MAX":
+		return v.max, true
+	}
+	return 0, false
+}
+
+func pivotKey(values []string, depth int) string {
+	return strconv.Itoa(depth) + "\x1f" + strings.Join(values[:depth], "\x1f")
+}
+
+// layout pivots the grouped records into cells relative to the anchor, and
+// returns them with the pivot's height.
+func (p *Pivot) layout(records []map[string]interface{}) ([]Cell, int, error) {
+	dimensions := len(p.Rows) + len(p.Columns)
+	var rowTuples, colTuples []pivotTuple
+	seenRows, seenCols := make(map[string]bool), make(map[string]bool)
+	values := make(map[[2]string][]pivotValue)
+	for _, record := range records {
+		tuple := pivotTuple{raw: make([]interface{}, dimensions), text: make([]string, dimensions)}
+		for d, field := range append(append([]string(nil), p.Rows...), p.Columns...) {
+			tuple.raw[d] = record[field]
+			tuple.text[d] = valueText(record[field])
+		}
+		row := pivotTuple{raw: tuple.raw[:len(p.Rows)], text: tuple.text[:len(p.Rows)]}
+		col := pivotTuple{raw: tuple.raw[len(p.Rows):], text: tuple.text[len(p.Rows):]}
+		if key := pivotKey(row.text, len(row.text)); !seenRows[key] {
+			seenRows[key] = true
+			rowTuples = append(rowTuples, row)
+		}
+		if key := pivotKey(col.text, len(col.text)); !seenCols[key] {
+			seenCols[key] = true
+			colTuples = append(colTuples, col)
+		}
+
+		for rd := 0; rd <= len(p.Rows); rd++ {
+			for cd := 0; cd <= len(p.Columns); cd++ {
+				key := [2]string{pivotKey(row.text, rd), pivotKey(col.text, cd)}
+				if values[key] == nil {
+					values[key] = make([]pivotValue, len(p.Measures))
+				}
+				for m, measure := range p.Measures {
+					values[key][m].add(measure, record, m)
+				}
+			}
+		}
+	}
+
+	rowSlots := pivotSlots(rowTuples, len(p.Rows), p.Subtotals, p.ColumnTotals)
+	colSlots := pivotSlots(colTuples, len(p.Columns), p.Subtotals, p.RowTotals)
+	measures := len(p.Measures)
+	rowHeaderCols := max(len(p.Rows), 1)
+	measureRow := measures > 1 || len(p.Columns) == 0
+	headerRows := len(p.Columns)
+	if measureRow {
+		headerRows++
+	}
+	if width := rowHeaderCols + len(colSlots)*measures; width > p.MaxColumns {
+		return nil, 0, fmt.Errorf("%w: pivot needs %d columns, the limit is %d", ErrInvalidRegion, width, p.MaxColumns)
+	}
+
+	var cells []Cell
+	header := func(row, col int, text string, rowSpan, colSpan int) {
+		cells = append(cells, Cell{Row: row, Col: col, Value: text, RowSpan: rowSpan, ColSpan: colSpan, Style: p.HeaderStyle})
+	}
+	for l, field := range p.Rows {
+		header(0, l, p.label(field), headerRows, 0)
+	}
+	if len(p.Rows) == 0 && headerRows > 1 {
+		header(0, 0, "", headerRows, 0)
+	}
+
+	for l := range p.Columns {
+		for j := 0; j < len(colSlots); {
+			slot := colSlots[j]
+			col := rowHeaderCols + j*measures
+			if len(slot.values) <= l {
+				if len(slot.values) == l {
+					header(l, col, p.totalLabel(l), len(p.Columns)-l, measures)
+				}
+				j++
+				continue
+			}
+			end := j + 1
+			for end < len(colSlots) && len(colSlots[end].values) > l && samePrefix(colSlots[end].values, slot.values, l+1) {
+				end++
+			}
+			header(l, col, slot.values[l], 0, (end-j)*measures)
+			j = end
+		}
+	}
+	if measureRow {
+		for j := range colSlots {
+			for m, measure := range p.Measures {
+				label := measure.Label
+				if label == "" {
+					label = p.label(measure.Field)
+				}
+				header(len(p.Columns), rowHeaderCols+j*measures+m, label, 0, 0)
+			}
+		}
+	}
+
+	for i, slot := range rowSlots {
+		row := headerRows + i
+		if len(p.Rows) == 0 {
+			header(row, 0, p.TotalLabel, 0, 0)
+		}
+		for l := range p.Rows {
+			if len(slot.values) < l {
+				break
+			}
+			if len(slot.values) == l {
+				header(row, l, p.totalLabel(l), 0, len(p.Rows)-l)
+				break
+			}
+			// The first row of a run of the same value shows it.
+			if i > 0 && len(rowSlots[i-1].values) > l && samePrefix(rowSlots[i-1].values, slot.values, l+1) {
+				continue
+			}
+			end := i + 1
+			for end < len(rowSlots) && len(rowSlots[end].values) > l && samePrefix(rowSlots[end].values, slot.values, l+1) {
+				end++
+			}
+			header(row, l, slot.values[l], end-i, 0)
+		}
+
+		for j, colSlot := range colSlots {
+			cellValues := values[[2]string{pivotKey(slot.values, len(slot.values)), pivotKey(colSlot.values, len(colSlot.values))}]
+			for m, measure := range p.Measures {
+				cell := Cell{Row: row, Col: rowHeaderCols + j*measures + m, Format: measure.Format, Style: p.Style}
+				if cellValues != nil {
+					if number, ok := cellValues[m].result(measure.Aggregation); ok {
+						cell.Value = formatNumber(number)
+					}
+				}
+				cells = append(cells, cell)
+			}
+		}
+	}
+
+	for i := range cells {
+		cells[i].bound = true
+	}
+	return cells, headerRows + len(rowSlots), nil
+}
+
+// totalLabel labels the total of a dimension level: the grand total at the
+// outermost level, a subtotal below it.
+func (p *Pivot) totalLabel(level int) string {
+	if level == 0 {
+		return p.TotalLabel
+	}
+	return p.SubtotalLabel
+}
+
+func samePrefix(a, b []string, n int) bool {
+	for i := 0; i < n; i++ {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// expandPivot replaces the pivot's anchor with the pivot. Rows below it, and
+// the rows formulas refer to there, move down by the rows it adds; cells
+// right of the anchor move right past it. heights are the template's row
+// heights, the anchor row's height applying to every row of the pivot.
+func expandPivot(cells []Cell, heights map[int]float64, p *Pivot, records []map[string]interface{}) ([]Cell, map[int]float64, error) {
+	pivotCells, height, err := p.layout(records)
+	if err != nil {
+		return nil, nil, err
+	}
+	width := 0
+	for _, cell := range pivotCells {
+		width = max(width, cell.Col+max(cell.ColSpan, 1))
+	}
+	delta := height - 1
+
+	expanded := make([]Cell, 0, len(cells)+len(pivotCells))
+	for _, cell := range cells {
+		switch {
+		case cell.Row == p.Row && cell.Col == p.Col:
+			continue
+		case cell.Row == p.Row && cell.Col > p.Col:
+			cell.Col += width - 1
+		case cell.Row > p.Row:
+			cell.Row += delta
+		}
+		if !cell.bound {
+			relocate := func(text string) string {
+				if !strings.HasPrefix(text, "=") {
+					return text
+				}
+				return mapFormulaLines(text, false, func(line int, absolute, rangeEnd bool) int {
+					if line > p.Row {
+						return line + delta
+					}
+					return line
+				})
+			}
+			cell.Value, cell.Text = relocate(cell.Value), relocate(cell.Text)
+		}
+		expanded = append(expanded, cell)
+	}
+	for _, cell := range pivotCells {
+		cell.Row += p.Row
+		cell.Col += p.Col
+		expanded = append(expanded, cell)
+	}
+
+	moved := make(map[int]float64, len(heights))
+	for line, size := range heights {
+		switch {
+		case line < p.Row:
+			moved[line] = size
+		case line > p.Row:
+			moved[line+delta] = size
+		default:
+			for r := p.Row; r < p.Row+height; r++ {
+				moved[r] = size
+			}
+		}
+	}
+	return expanded, moved, nil
+}
@@ -0,0 +1,148 @@
+package render
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gujiaweiguo/goreport/internal/dataset"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngine_RenderGrid_Pivot(t *testing.T) {
+	executor := &fakeQueryExecutor{records: []map[string]interface{}{
+		{"region": "North", "year": int64(2025), "pivot_0_sum": 10.0},
+		{"region": "North", "year": int64(2024), "pivot_0_sum": []byte("4")},
+		{"region": "South", "year": int64(2025), "pivot_0_sum": 6.0},
+	}}
+	engine := NewEngine(nil, nil, executor)
+	config := `{
+		"cells": [
+			{"row": 0, "col": 0, "value": "Sales"},
+			{"row": 2, "col": 0, "value": "End"},
+			{"row": 2, "col": 1, "value": "=B4*2"},
+			{"row": 3, "col": 0, "value": "x"},
+			{"row": 3, "col": 1, "value": "5"}
+		],
+		"grid": {"rowHeights": {"1": 30, "3": 50}},
+		"pivots": [{
+			"datasetId": "ds-1", "row": 1, "col": 0,
+			"rows": ["region"], "columns": ["year"], "labels": {"region": "Region"},
+			"measures": [{"field": "amount", "aggregation": "sum"}],
+			"rowTotals": true, "columnTotals": true
+		}]
+	}`
+
+	grid, err := engine.RenderGrid(context.Background(), config, nil, "tenant-1")
+	require.NoError(t, err)
+
+	assert.Equal(t, [][]string{
+		{"Sales", "", "", ""},
+		{"Region", "2024", "2025", "Total"},
+		{"North", "4", "10", "14"},
+		{"South", "", "6", "6"},
+		{"Total", "4", "16", "20"},
+		{"End", "10", "", ""},
+		{"x", "5", "", ""},
+	}, gridText(grid))
+	assert.Equal(t, []float64{0, 30, 30, 30, 30, 0, 50}, grid.RowHeights)
+
+	require.Len(t, executor.requests, 1)
+	req := executor.requests[0]
+	assert.Equal(t, []string{"region", "year"}, req.GroupBy)
+	assert.Equal(t, map[string]dataset.Aggregation{"pivot_0_sum": {Function: "SUM", Field: "amount"}}, req.Aggregations)
+}
+
+func TestPivot_SubtotalsAndAverages(t *testing.T) {
+	pivots := []Pivot{{
+		DatasetID: "ds-1",
+		Rows:      []string{"region", "product"},
+		Measures: []PivotMeasure{
+			{Field: "amount", Label: "Amount"},
+			{Field: "price", Aggregation: "avg", Format: "0.0"},
+		},
+		Subtotals:    true,
+		ColumnTotals: true,
+	}}
+	require.NoError(t, normalizePivots(pivots, nil))
+	records := []map[string]interface{}{
+		{"region": "N", "product": "Tea", "pivot_0_sum": 10.0, "pivot_1_sum": 6.0, "pivot_1_count": int64(2)},
+		{"region": "N", "product": "Milk", "pivot_0_sum": 5.0, "pivot_1_sum": 3.0, "pivot_1_count": int64(1)},
+		{"region": "S", "product": "Rice", "pivot_0_sum": 7.0, "pivot_1_sum": 8.0, "pivot_1_count": int64(4)},
+	}
+
+	cells, heights, err := expandPivot(nil, nil, &pivots[0], records)
+	require.NoError(t, err)
+	assert.Empty(t, heights)
+	grid := buildGrid(&ReportConfig{Cells: cells}, cellTexts(cells))
+
+	assert.Equal(t, [][]string{
+		{"region", "product", "Amount", "price"},
+		{"N", "Milk", "5", "3.0"},
+		{"", "Tea", "10", "3.0"},
+		{"", "Subtotal", "15", "3.0"},
+		{"S", "Rice", "7", "2.0"},
+		{"", "Subtotal", "7", "2.0"},
+		{"Total", "", "22", "2.4"},
+	}, gridText(grid))
+	assert.Equal(t, 3, grid.Rows[1][0].RowSpan)
+	assert.Equal(t, 2, grid.Rows[4][0].RowSpan)
+	assert.Equal(t, 2, grid.Rows[6][0].ColSpan)
+}
+
+func TestPivot_ColumnSubtotalsAndMeasureRow(t *testing.T) {
+	pivots := []Pivot{{
+		DatasetID:     "ds-1",
+		Columns:       []string{"year", "quarter"},
+		Measures:      []PivotMeasure{{Field: "amount"}, {Field: "amount", Aggregation: "COUNT", Label: "Orders"}},
+		Subtotals:     true,
+		TotalLabel:    "合计",
+		SubtotalLabel: "小计",
+	}}
+	require.NoError(t, normalizePivots(pivots, nil))
+	records := []map[string]interface{}{
+		{"year": "2025", "quarter": "Q1", "pivot_0_sum": 1.0, "pivot_1_count": 1.0},
+		{"year": "2025", "quarter": "Q2", "pivot_0_sum": 2.0, "pivot_1_count": 3.0},
+	}
+
+	cells, _, err := pivots[0].layout(records)
+	require.NoError(t, err)
+	grid := buildGrid(&ReportConfig{Cells: cells}, cellTexts(cells))
+
+	assert.Equal(t, [][]string{
+		{"", "2025", "", "", "", "", ""},
+		{"", "Q1", "", "Q2", "", "小计", ""},
+		{"", "amount", "Orders", "amount", "Orders", "amount", "Orders"},
+		{"合计", "1", "1", "2", "3", "3", "4"},
+	}, gridText(grid))
+	assert.Equal(t, 6, grid.Rows[0][1].ColSpan)
+	assert.Equal(t, 3, grid.Rows[0][0].RowSpan)
+}
+
+func TestPivot_MaxColumns(t *testing.T) {
+	pivots := []Pivot{{DatasetID: "ds-1", Columns: []string{"year"}, Measures: []PivotMeasure{{Field: "amount"}}, MaxColumns: 3}}
+	require.NoError(t, normalizePivots(pivots, nil))
+	records := []map[string]interface{}{{"year": "2023"}, {"year": "2024"}, {"year": "2025"}}
+
+	_, _, err := expandPivot(nil, nil, &pivots[0], records)
+	assert.ErrorIs(t, err, ErrInvalidRegion)
+}
+
+func TestNormalizePivots(t *testing.T) {
+	measure := []PivotMeasure{{Field: "amount"}}
+	invalid := map[string][]Pivot{
+		"dataset":     {{Measures: measure}},
+		"measure":     {{DatasetID: "ds-1"}},
+		"aggregation": {{DatasetID: "ds-1", Measures: []PivotMeasure{{Field: "amount", Aggregation: "median"}}}},
+		"dimensions":  {{DatasetID: "ds-1", Rows: []string{"year"}, Columns: []string{"year"}, Measures: measure}},
+		"maxColumns":  {{DatasetID: "ds-1", Measures: measure, MaxColumns: maxPivotColumns + 1}},
+		"sharedRow":   {{DatasetID: "ds-1", Measures: measure}, {DatasetID: "ds-2", Col: 3, Measures: measure}},
+	}
+	for name, pivots := range invalid {
+		assert.ErrorIs(t, normalizePivots(pivots, nil), ErrInvalidRegion, name)
+	}
+
+	inRegion := []Pivot{{DatasetID: "ds-1", Row: 2, Measures: measure}}
+	regions := []Region{{DatasetID: "ds-2", Direction: "vertical", Detail: Band{Start: 1, Size: 2}}}
+	assert.ErrorIs(t, normalizePivots(inRegion, regions), ErrInvalidRegion)
+}
@@ -0,0 +1,215 @@
+package render
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gobolditalic"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	DefaultImageDPI = 150
+	minImageDPI     = 36
+	maxImageDPI     = 300
+)
+
+var headerFill = color.NRGBA{R: 235, G: 235, B: 235, A: 255}
+
+// WritePNG rasterizes one page of the grid, laid out exactly as the PDF
+// export paginates it, at the given DPI. page is 1-based; zero values pick
+// the first page and DefaultImageDPI.
+func WritePNG(w io.Writer, grid *Grid, page, dpi int) error {
+	settings, err := NormalizePrintConfig(grid.Print)
+	if err != nil {
+		return err
+	}
+	if dpi == 0 {
+		dpi = DefaultImageDPI
+	}
+	if dpi < minImageDPI || dpi > maxImageDPI {
+		return fmt.Errorf("%w: dpi must be between %d and %d", ErrInvalidPrintConfig, minImageDPI, maxImageDPI)
+	}
+
+	fonts, err := loadImageFonts()
+	if err != nil {
+		return err
+	}
+	r := &pngRenderer{dpi: float64(dpi), fonts: fonts, faces: make(map[fontSpec]font.Face)}
+	defer r.close()
+	layout := layoutPages(grid, settings, r)
+	if page == 0 {
+		page = 1
+	}
+	if page < 1 || page > len(layout.pages) {
+		return fmt.Errorf("%w: page %d is out of range 1-%d", ErrInvalidPrintConfig, page, len(layout.pages))
+	}
+
+	r.img = image.NewRGBA(image.Rect(0, 0, r.px(layout.width), r.px(layout.height)))
+	draw.Draw(r.img, r.img.Bounds(), image.White, image.Point{}, draw.Src)
+
+	layout.eachCell(page-1, func(row, c int, x, y, width, height float64) {
+		cell := &grid.Rows[row][c]
+		if fill, ok := cellFill(cell, row < layout.headerRows); ok {
+			r.fill(x, y, x+width, y+height, fill)
+		}
+		font := cell.Style.font()
+		lines := layout.lines[row][c]
+		top := textTop(y, height, lines, font, cell.Style.verticalAlign())
+		for i, line := range lines {
+			left := x + cellPadding
+			switch cell.Style.align() {
+			case "center":
+				left = x + (width-r.textWidth(line, font))/2
+			case "right":
+				left = x + width - cellPadding - r.textWidth(line, font)
+			}
+			r.drawText(left, top+float64(i)*font.lineHeight(), line, font, cell.Style.textColor())
+		}
+	})
+	layout.eachCell(page-1, func(row, c int, x, y, width, height float64) {
+		if border, ok := grid.Rows[row][c].Style.border(); ok {
+			r.strokeRect(x, y, width, height, border)
+		}
+	})
+	footer := fmt.Sprintf("%d / %d", page, len(layout.pages))
+	footerFont := fontSpec{size: fontSizePt}
+	r.drawText((layout.width-r.textWidth(footer, footerFont))/2, layout.height-pageMargin-lineHeight, footer, footerFont, defaultTextColor)
+	if r.err != nil {
+		return r.err
+	}
+
+	return png.Encode(w, r.img)
+}
+
+// imageFontStyle indexes the faces of a font family: regular, bold, italic
+// and bold italic.
+func imageFontStyle(f fontSpec) int {
+	style := 0
+	if f.bold {
+		style |= 1
+	}
+	if f.italic {
+		style |= 2
+	}
+	return style
+}
+
+// loadImageFonts parses the export font, used for every style, or the Go
+// fonts when no export font is configured.
+func loadImageFonts() ([4]*opentype.Font, error) {
+	var fonts [4]*opentype.Font
+	if data := loadExportFont(); len(data) > 0 {
+		parsed, err := opentype.Parse(data)
+		if err != nil {
+			return fonts, fmt.Errorf("failed to parse export font: %w", err)
+		}
+		return [4]*opentype.Font{parsed, parsed, parsed, parsed}, nil
+	}
+	for i, data := range [4][]byte{goregular.TTF, gobold.TTF, goitalic.TTF, gobolditalic.TTF} {
+		parsed, err := opentype.Parse(data)
+		if err != nil {
+			return fonts, fmt.Errorf("failed to parse export font: %w", err)
+		}
+		fonts[i] = parsed
+	}
+	return fonts, nil
+}
+
+// pngRenderer draws a page layout, converting millimetres to pixels. Faces
+// are opened per font size and style as cells ask for them.
+type pngRenderer struct {
+	img   *image.RGBA
+	dpi   float64
+	fonts [4]*opentype.Font
+	faces map[fontSpec]font.Face
+	err   error
+}
+
+func (r *pngRenderer) face(f fontSpec) font.Face {
+	if face, ok := r.faces[f]; ok {
+		return face
+	}
+	face, err := opentype.NewFace(r.fonts[imageFontStyle(f)], &opentype.FaceOptions{Size: f.size, DPI: r.dpi, Hinting: font.HintingFull})
+	if err != nil {
+		if r.err == nil {
+			r.err = fmt.Errorf("failed to open export font: %w", err)
+		}
+		face = basicfont.Face7x13
+	}
+	r.faces[f] = face
+	return face
+}
+
+func (r *pngRenderer) close() {
+	for _, face := range r.faces {
+		face.Close()
+	}
+}
+
+func (r *pngRenderer) px(mm float64) int {
+	return int(math.Round(mm * r.dpi / 25.4))
+}
+
+func (r *pngRenderer) textWidth(text string, f fontSpec) float64 {
+	width := font.MeasureString(r.face(f), text)
+	return float64(width) / 64 * 25.4 / r.dpi
+}
+
+func (r *pngRenderer) fill(x0, y0, x1, y1 float64, c color.NRGBA) {
+	rect := image.Rect(r.px(x0), r.px(y0), r.px(x1), r.px(y1))
+	draw.Draw(r.img, rect, image.NewUniform(c), image.Point{}, draw.Over)
+}
+
+// strokeRect draws a cell border, dashed or dotted when the style asks.
+func (r *pngRenderer) strokeRect(x, y, width, height float64, border borderSpec) {
+	x0, y0, x1, y1 := r.px(x), r.px(y), r.px(x+width), r.px(y+height)
+	t := max(r.px(border.width), 1)
+	dash, gap := 0, 0
+	switch {
+	case border.dashed:
+		dash, gap = 3*t, 2*t
+	case border.dotted:
+		dash, gap = t, t
+	}
+	src := image.NewUniform(border.color)
+	segments := func(from, to int, rect func(a, b int) image.Rectangle) {
+		if dash == 0 {
+			draw.Draw(r.img, rect(from, to), src, image.Point{}, draw.Over)
+			return
+		}
+		for a := from; a < to; a += dash + gap {
+			draw.Draw(r.img, rect(a, min(a+dash, to)), src, image.Point{}, draw.Over)
+		}
+	}
+	segments(x0, x1, func(a, b int) image.Rectangle { return image.Rect(a, y0, b, y0+t) })
+	segments(x0, x1, func(a, b int) image.Rectangle { return image.Rect(a, y1-t, b, y1) })
+	segments(y0, y1, func(a, b int) image.Rectangle { return image.Rect(x0, a, x0+t, b) })
+	segments(y0, y1, func(a, b int) image.Rectangle { return image.Rect(x1-t, a, x1, b) })
+}
+
+// drawText draws one line of text vertically centred in a line box whose
+// top left corner is at x, y.
+func (r *pngRenderer) drawText(x, y float64, text string, f fontSpec, c color.NRGBA) {
+	face := r.face(f)
+	metrics := face.Metrics()
+	baseline := fixed.I(r.px(y)) + (fixed.I(r.px(f.lineHeight()))+metrics.Ascent-metrics.Descent)/2
+	d := &font.Drawer{
+		Dst:  r.img,
+		Src:  image.NewUniform(c),
+		Face: face,
+		Dot:  fixed.Point26_6{X: fixed.I(r.px(x)), Y: baseline},
+	}
+	d.DrawString(text)
+}
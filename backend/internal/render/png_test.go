@@ -0,0 +1,57 @@
+package render
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWritePNG(t *testing.T) {
+	grid := testGrid(120)
+	grid.Print = PrintConfig{HeaderRows: 1}
+
+	var out bytes.Buffer
+	require.NoError(t, WritePNG(&out, grid, 0, 0))
+	img, err := png.Decode(&out)
+	require.NoError(t, err)
+	// A4 at the default 150 DPI.
+	assert.Equal(t, image.Rect(0, 0, 1240, 1754), img.Bounds())
+
+	headerPixel := color.GrayModel.Convert(img.At(200, 70)).(color.Gray)
+	assert.Equal(t, headerFill.G, headerPixel.Y, "header row is shaded")
+	marginPixel := color.GrayModel.Convert(img.At(10, 10)).(color.Gray)
+	assert.Equal(t, uint8(255), marginPixel.Y)
+
+	out.Reset()
+	require.NoError(t, WritePNG(&out, grid, 4, 72))
+	img, err = png.Decode(&out)
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 595, 842), img.Bounds())
+
+	grid.Print.Orientation = "landscape"
+	out.Reset()
+	require.NoError(t, WritePNG(&out, grid, 1, 72))
+	img, err = png.Decode(&out)
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 842, 595), img.Bounds())
+
+	assert.ErrorIs(t, WritePNG(&bytes.Buffer{}, grid, 99, 72), ErrInvalidPrintConfig)
+	assert.ErrorIs(t, WritePNG(&bytes.Buffer{}, grid, 1, 1200), ErrInvalidPrintConfig)
+}
+
+func TestWritePNG_Styles(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, WritePNG(&out, styledGrid(), 1, 72))
+	img, err := png.Decode(&out)
+	require.NoError(t, err)
+
+	// The merged title spans all three columns of 200px and 120px at 96
+	// DPI, so its background reaches past the first column.
+	title := color.NRGBAModel.Convert(img.At(250, 32)).(color.NRGBA)
+	assert.Equal(t, color.NRGBA{R: 0x1f, G: 0x4e, B: 0x79, A: 255}, title)
+}
@@ -0,0 +1,426 @@
+package render
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidRegion is returned for list regions that cannot be expanded,
+// such as bands that overlap.
+var ErrInvalidRegion = errors.New("invalid report region")
+
+func (r *Region) horizontal() bool {
+	return r.Direction == "horizontal"
+}
+
+// bands returns the region's bands in template order.
+func (r *Region) bands() []Band {
+	bands := []Band{r.Detail}
+	for _, group := range r.Groups {
+		if group.Header != nil {
+			bands = append(bands, *group.Header)
+		}
+		if group.Footer != nil {
+			bands = append(bands, *group.Footer)
+		}
+	}
+	if r.Total != nil {
+		bands = append(bands, *r.Total)
+	}
+	sort.Slice(bands, func(i, j int) bool { return bands[i].Start < bands[j].Start })
+	return bands
+}
+
+// span returns the template lines the region occupies, end exclusive.
+func (r *Region) span() (int, int) {
+	bands := r.bands()
+	last := bands[len(bands)-1]
+	return bands[0].Start, last.Start + last.Size
+}
+
+// normalizeRegions fills in band defaults and checks that no two bands, or
+// two regions running the same way, share a template line.
+func normalizeRegions(regions []Region) error {
+	for i := range regions {
+		region := &regions[i]
+		switch region.Direction {
+		case "":
+			region.Direction = "vertical"
+		case "vertical", "horizontal":
+		default:
+			return fmt.Errorf("%w: unsupported direction %q", ErrInvalidRegion, region.Direction)
+		}
+		if region.DatasetID == "" {
+			return fmt.Errorf("%w: datasetId is required", ErrInvalidRegion)
+		}
+		bands := []*Band{&region.Detail, region.Total}
+		for g := range region.Groups {
+			if region.Groups[g].Field == "" {
+				return fmt.Errorf("%w: group field is required", ErrInvalidRegion)
+			}
+			bands = append(bands, region.Groups[g].Header, region.Groups[g].Footer)
+		}
+		for _, band := range bands {
+			if band == nil {
+				continue
+			}
+			if band.Size == 0 {
+				band.Size = 1
+			}
+			if band.Start < 0 || band.Size < 0 {
+				return fmt.Errorf("%w: bands must not be negative", ErrInvalidRegion)
+			}
+		}
+		sorted := region.bands()
+		for b := 1; b < len(sorted); b++ {
+			if sorted[b].Start < sorted[b-1].Start+sorted[b-1].Size {
+				return fmt.Errorf("%w: bands overlap", ErrInvalidRegion)
+			}
+		}
+	}
+	for i := range regions {
+		for j := i + 1; j < len(regions); j++ {
+			if regions[i].Direction != regions[j].Direction {
+				continue
+			}
+			start1, end1 := regions[i].span()
+			start2, end2 := regions[j].span()
+			if start1 < end2 && start2 < end1 {
+				return fmt.Errorf("%w: regions overlap", ErrInvalidRegion)
+			}
+		}
+	}
+	return nil
+}
+
+// regionExpansion expands one region of a template: the template lines of
+// its bands are copied once per instance, and the lines after the region
+// move by however much it grew.
+type regionExpansion struct {
+	region    *Region
+	top       int
+	bottom    int
+	templates map[int][]Cell
+	sizes     map[int]float64
+	placed    []placedCell
+	newSizes  map[int]float64
+	next      int
+
+	// first and last are the lines of the first and last copies of each
+	// template line.
+	first map[int]int
+	last  map[int]int
+}
+
+// placedCell is a band cell copied to the expanded region, with the offset
+// of its copy from the template.
+type placedCell struct {
+	cell   Cell
+	band   Band
+	offset int
+}
+
+// expandRegion returns cells with region expanded over its records, and
+// sizes, the template's row heights or column widths, moved to match.
+func expandRegion(cells []Cell, sizes map[int]float64, region *Region, records []map[string]interface{}) ([]Cell, map[int]float64) {
+	x := &regionExpansion{region: region, templates: make(map[int][]Cell), sizes: sizes, newSizes: make(map[int]float64),
+		first: make(map[int]int), last: make(map[int]int)}
+	x.top, x.bottom = region.span()
+	x.next = x.top
+
+	var before, after []Cell
+	for _, cell := range cells {
+		switch line := x.line(&cell); {
+		case line < x.top:
+			before = append(before, cell)
+		case line >= x.bottom:
+			after = append(after, cell)
+		default:
+			x.templates[line] = append(x.templates[line], cell)
+		}
+	}
+
+	x.emitGroup(0, sortByGroups(records, region.Groups))
+	if region.Total != nil {
+		x.place(*region.Total, records, nil)
+	}
+	delta := x.next - x.bottom
+
+	expanded := make([]Cell, 0, len(before)+len(x.placed)+len(after))
+	for _, cell := range before {
+		expanded = append(expanded, x.relocateCell(cell, nil, 0))
+	}
+	for _, placed := range x.placed {
+		expanded = append(expanded, x.relocateCell(placed.cell, &placed.band, placed.offset))
+	}
+	for _, cell := range after {
+		x.setLine(&cell, x.line(&cell)+delta)
+		expanded = append(expanded, x.relocateCell(cell, nil, 0))
+	}
+	for line, size := range sizes {
+		switch {
+		case line < x.top:
+			x.newSizes[line] = size
+		case line >= x.bottom:
+			x.newSizes[line+delta] = size
+		}
+	}
+	return expanded, x.newSizes
+}
+
+func (x *regionExpansion) line(cell *Cell) int {
+	if x.region.horizontal() {
+		return cell.Col
+	}
+	return cell.Row
+}
+
+func (x *regionExpansion) setLine(cell *Cell, line int) {
+	if x.region.horizontal() {
+		cell.Col = line
+	} else {
+		cell.Row = line
+	}
+}
+
+// emitGroup places the sections of group level over records: header, the
+// nested groups or detail rows, then footer.
+func (x *regionExpansion) emitGroup(level int, records []map[string]interface{}) {
+	if level == len(x.region.Groups) {
+		for _, record := range records {
+			x.place(x.region.Detail, []map[string]interface{}{record}, record)
+		}
+		return
+	}
+	group := x.region.Groups[level]
+	for start := 0; start < len(records); {
+		end := start + 1
+		key := valueText(records[start][group.Field])
+		for end < len(records) && valueText(records[end][group.Field]) == key {
+			end++
+		}
+		section := records[start:end]
+		if group.Header != nil {
+			x.place(*group.Header, section, section[0])
+		}
+		x.emitGroup(level+1, section)
+		if group.Footer != nil {
+			x.place(*group.Footer, section, section[0])
+		}
+		start = end
+	}
+}
+
+// place copies a band's template lines to the next free lines, binding
+// its cells to record, or to records when they aggregate.
+func (x *regionExpansion) place(band Band, records []map[string]interface{}, record map[string]interface{}) {
+	offset := x.next - band.Start
+	for line := band.Start; line < band.Start+band.Size; line++ {
+		for _, template := range x.templates[line] {
+			cell := template
+			x.setLine(&cell, line+offset)
+			if cell.FieldName != nil && cell.DatasourceID == nil {
+				bindCell(&cell, *cell.FieldName, records, record)
+			}
+			x.placed = append(x.placed, placedCell{cell: cell, band: band, offset: offset})
+		}
+		if _, ok := x.first[line]; !ok {
+			x.first[line] = line + offset
+		}
+		x.last[line] = line + offset
+		if size, ok := x.sizes[line]; ok {
+			x.newSizes[line+offset] = size
+		}
+	}
+	x.next += band.Size
+}
+
+// relocateCell moves the references of a formula the way a spreadsheet
+// does when rows are inserted: references after the region move with it, and
+// a reference into the region points at the first copy of its template line,
+// or the last copy when it ends a range, so ranges grow with the region. In a
+// copied band, relative references to the band itself stay in the same copy.
+// A formula that refers to a line with no copies shows #REF!.
+func (x *regionExpansion) relocateCell(cell Cell, band *Band, offset int) Cell {
+	if cell.bound {
+		return cell
+	}
+	delta := x.next - x.bottom
+	relocate := func(text string) string {
+		if !strings.HasPrefix(text, "=") {
+			return text
+		}
+		missing := false
+		text = mapFormulaLines(text, x.region.horizontal(), func(line int, absolute, rangeEnd bool) int {
+			switch {
+			case band != nil && !absolute && line >= band.Start && line < band.Start+band.Size:
+				return line + offset
+			case line >= x.bottom:
+				return line + delta
+			case line < x.top:
+				return line
+			}
+			copies := x.first
+			if rangeEnd {
+				copies = x.last
+			}
+			copied, ok := copies[line]
+			if !ok {
+				missing = true
+			}
+			return copied
+		})
+		if missing {
+			return errFormulaRef.Error()
+		}
+		return text
+	}
+	cell.Value, cell.Text = relocate(cell.Value), relocate(cell.Text)
+	return cell
+}
+
+// bindCell sets a bound template cell's value from the records of its band.
+func bindCell(cell *Cell, field string, records []map[string]interface{}, record map[string]interface{}) {
+	var value string
+	switch {
+	case cell.Aggregation != "":
+		value = aggregate(records, field, cell.Aggregation)
+	case record != nil:
+		value = valueText(record[field])
+	case len(records) > 0:
+		value = valueText(records[0][field])
+	}
+	cell.Value, cell.Text = value, value
+	cell.FieldName, cell.DatasetID, cell.Aggregation = nil, nil, ""
+	cell.bound = true
+}
+
+// aggregate computes SUM, AVG, COUNT, MIN or MAX of a field over records.
+// COUNT counts non-null values; the others skip values that are not numbers.
+func aggregate(records []map[string]interface{}, field, function string) string {
+	var numbers []float64
+	count := 0
+	for _, record := range records {
+		value := record[field]
+		if value == nil {
+			continue
+		}
+		count++
+		if number, ok := toFloat(parsedValue(value)); ok {
+			numbers = append(numbers, number)
+		}
+	}
+
+	switch strings.ToUpper(function) {
+	case "COUNT":
+		return strconv.Itoa(count)
+	case "SUM":
+		return formatNumber(sumNumbers(numbers))
+	case "AVG":
+		if len(numbers) == 0 {
+			return ""
+		}
+		return formatNumber(sumNumbers(numbers) / float64(len(numbers)))
+	case "MIN", "MAX":
+		if len(numbers) == 0 {
+			return ""
+		}
+		result := numbers[0]
+		for _, n := range numbers[1:] {
+			if (strings.EqualFold(function, "MIN")) == (n < result) {
+				result = n
+			}
+		}
+		return formatNumber(result)
+	}
+	return ""
+}
+
+// sortByGroups orders records by the group fields, keeping the dataset's
+// order within each group.
+func sortByGroups(records []map[string]interface{}, groups []RegionGroup) []map[string]interface{} {
+	if len(groups) == 0 {
+		return records
+	}
+	sorted := append([]map[string]interface{}(nil), records...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		for _, group := range groups {
+			if c := compareValues(sorted[i][group.Field], sorted[j][group.Field]); c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+	return sorted
+}
+
+// compareValues orders numbers and dates by value and anything else by its
+// text; nulls come first.
+func compareValues(a, b interface{}) int {
+	if a == nil || b == nil {
+		switch {
+		case a == nil && b == nil:
+			return 0
+		case a == nil:
+			return -1
+		}
+		return 1
+	}
+	pa, pb := parsedValue(a), parsedValue(b)
+	if x, ok := toFloat(pa); ok {
+		if y, ok := toFloat(pb); ok {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	}
+	if x, ok := pa.(time.Time); ok {
+		if y, ok := pb.(time.Time); ok {
+			return x.Compare(y)
+		}
+	}
+	return strings.Compare(valueText(a), valueText(b))
+}
+
+// parsedValue returns the number or time behind a dataset value.
+func parsedValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case time.Time:
+		return v
+	case float64, int64, int:
+		return v
+	}
+	parsed, _ := parseCellValue(valueText(value))
+	return parsed
+}
+
+// valueText renders a dataset value as cell text. Dates without a time of
+// day are shown as dates.
+func valueText(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case time.Time:
+		if v.Hour() == 0 && v.Minute() == 0 && v.Second() == 0 && v.Nanosecond() == 0 {
+			return v.Format("2006-01-02")
+		}
+		return v.Format("2006-01-02 15:04:05")
+	}
+	return fmt.Sprintf("%v", value)
+}
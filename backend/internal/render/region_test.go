@@ -0,0 +1,253 @@
+package render
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/gujiaweiguo/goreport/internal/dataset"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func field(name string) *string {
+	return &name
+}
+
+func salesRecords() []map[string]interface{} {
+	return []map[string]interface{}{
+		{"region": "North", "product": "Tea", "amount": 10.0},
+		{"region": "South", "product": "Rice", "amount": 7.0},
+		{"region": "North", "product": "Milk", "amount": 5.0},
+	}
+}
+
+// salesTemplate is a grouped list: a heading row, a group header, detail
+// and group footer band, a total band and a row after the region.
+func salesTemplate() ([]Cell, *Region) {
+	cells := []Cell{
+		{Row: 0, Col: 0, Value: "Product"},
+		{Row: 0, Col: 1, Value: "Amount"},
+		{Row: 1, Col: 0, FieldName: field("region")},
+		{Row: 2, Col: 0, FieldName: field("product")},
+		{Row: 2, Col: 1, FieldName: field("amount")},
+		{Row: 2, Col: 2, Value: "=B3*2"},
+		{Row: 3, Col: 0, Value: "Subtotal"},
+		{Row: 3, Col: 1, FieldName: field("amount"), Aggregation: "SUM"},
+		{Row: 4, Col: 0, Value: "Total"},
+		{Row: 4, Col: 1, FieldName: field("amount"), Aggregation: "sum"},
+		{Row: 5, Col: 0, Value: "Detail sum"},
+		{Row: 5, Col: 1, Value: "=SUM(B3:B3)"},
+	}
+	region := &Region{
+		DatasetID: "ds-1",
+		Detail:    Band{Start: 2, Size: 1},
+		Groups:    []RegionGroup{{Field: "region", Header: &Band{Start: 1}, Footer: &Band{Start: 3}}},
+		Total:     &Band{Start: 4},
+	}
+	return cells, region
+}
+
+func cellTexts(cells []Cell) map[string]string {
+	texts := make(map[string]string)
+	for _, cell := range cells {
+		text := cell.Value
+		if text == "" {
+			text = cell.Text
+		}
+		texts[cellKey(cell.Row, cell.Col)] = text
+	}
+	return texts
+}
+
+func TestExpandRegion_GroupsAndTotal(t *testing.T) {
+	cells, region := salesTemplate()
+	require.NoError(t, normalizeRegions([]Region{*region}))
+
+	expanded, heights := expandRegion(cells, map[int]float64{0: 30, 2: 20, 5: 40}, region, salesRecords())
+	texts := cellTexts(expanded)
+
+	assert.Equal(t, "Product", texts["0:0"])
+	assert.Equal(t, "North", texts["1:0"])
+	assert.Equal(t, "Tea", texts["2:0"])
+	assert.Equal(t, "=B3*2", texts["2:2"])
+	assert.Equal(t, "Milk", texts["3:0"])
+	assert.Equal(t, "=B4*2", texts["3:2"])
+	assert.Equal(t, "Subtotal", texts["4:0"])
+	assert.Equal(t, "15", texts["4:1"])
+	assert.Equal(t, "South", texts["5:0"])
+	assert.Equal(t, "Rice", texts["6:0"])
+	assert.Equal(t, "7", texts["7:1"])
+	assert.Equal(t, "Total", texts["8:0"])
+	assert.Equal(t, "22", texts["8:1"])
+	assert.Equal(t, "Detail sum", texts["9:0"])
+	assert.Equal(t, "=SUM(B3:B7)", texts["9:1"])
+
+	assert.Equal(t, map[int]float64{0: 30, 2: 20, 3: 20, 6: 20, 9: 40}, heights)
+}
+
+func TestExpandRegion_Horizontal(t *testing.T) {
+	cells := []Cell{
+		{Row: 0, Col: 0, Value: "Month"},
+		{Row: 0, Col: 1, FieldName: field("month")},
+		{Row: 1, Col: 1, FieldName: field("amount")},
+		{Row: 1, Col: 2, Value: "=SUM(B2:B2)"},
+	}
+	region := &Region{DatasetID: "ds-1", Direction: "horizontal", Detail: Band{Start: 1, Size: 1}}
+	records := []map[string]interface{}{
+		{"month": "Jan", "amount": int64(3)},
+		{"month": "Feb", "amount": int64(4)},
+	}
+
+	expanded, widths := expandRegion(cells, map[int]float64{1: 80, 2: 60}, region, records)
+	texts := cellTexts(expanded)
+
+	assert.Equal(t, "Jan", texts["0:1"])
+	assert.Equal(t, "Feb", texts["0:2"])
+	assert.Equal(t, "4", texts["1:2"])
+	assert.Equal(t, "=SUM(B2:C2)", texts["1:3"])
+	assert.Equal(t, map[int]float64{1: 80, 2: 80, 3: 60}, widths)
+}
+
+func TestExpandRegion_NoRecords(t *testing.T) {
+	cells, region := salesTemplate()
+	require.NoError(t, normalizeRegions([]Region{*region}))
+
+	expanded, _ := expandRegion(cells, nil, region, nil)
+	texts := cellTexts(expanded)
+
+	assert.Equal(t, "Total", texts["1:0"])
+	assert.Equal(t, "0", texts["1:1"])
+	assert.Equal(t, "#REF!", texts["2:1"])
+}
+
+func TestNormalizeRegions(t *testing.T) {
+	regions := []Region{{DatasetID: "ds-1", Detail: Band{Start: 2}}}
+	require.NoError(t, normalizeRegions(regions))
+	assert.Equal(t, "vertical", regions[0].Direction)
+	assert.Equal(t, 1, regions[0].Detail.Size)
+
+	invalid := map[string][]Region{
+		"dataset":   {{Detail: Band{Start: 0}}},
+		"direction": {{DatasetID: "ds-1", Direction: "diagonal"}},
+		"negative":  {{DatasetID: "ds-1", Detail: Band{Start: -1}}},
+		"group":     {{DatasetID: "ds-1", Groups: []RegionGroup{{}}}},
+		"bands":     {{DatasetID: "ds-1", Detail: Band{Start: 1, Size: 2}, Total: &Band{Start: 2}}},
+		"regions":   {{DatasetID: "ds-1", Detail: Band{Start: 1}}, {DatasetID: "ds-2", Detail: Band{Start: 1}}},
+	}
+	for name, regions := range invalid {
+		err := normalizeRegions(regions)
+		assert.ErrorIs(t, err, ErrInvalidRegion, name)
+	}
+
+	crossing := []Region{
+		{DatasetID: "ds-1", Detail: Band{Start: 1}},
+		{DatasetID: "ds-2", Direction: "horizontal", Detail: Band{Start: 1}},
+	}
+	assert.NoError(t, normalizeRegions(crossing))
+}
+
+func TestMapFormulaLines(t *testing.T) {
+	shift := func(line int, absolute, rangeEnd bool) int {
+		if absolute {
+			return line
+		}
+		if rangeEnd {
+			return line + 10
+		}
+		return line + 1
+	}
+
+	assert.Equal(t, "=SUM(A2:A12)+B$1+LOG10(2)", mapFormulaLines("=SUM(A1:A2)+B$1+LOG10(2)", false, shift))
+	assert.Equal(t, "=B1+$A1+AA1", mapFormulaLines("=A1+$A1+Z1", true, shift))
+}
+
+// fakeQueryExecutor serves Export from fixed records, keeping the requests.
+type fakeQueryExecutor struct {
+	records  []map[string]interface{}
+	err      error
+	tenants  []string
+	requests []*dataset.QueryRequest
+}
+
+func (f *fakeQueryExecutor) Query(ctx context.Context, req *dataset.QueryRequest) (*dataset.QueryResponse, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *fakeQueryExecutor) Export(ctx context.Context, tenantID string, req *dataset.QueryRequest, w dataset.RowWriter) (int64, error) {
+	f.tenants = append(f.tenants, tenantID)
+	f.requests = append(f.requests, req)
+	if f.err != nil {
+		return 0, f.err
+	}
+	// Grouped rows are given already aggregated, keyed by alias.
+	columns := append([]string(nil), req.Fields...)
+	for alias := range req.Aggregations {
+		columns = append(columns, alias)
+	}
+	sort.Strings(columns[len(req.Fields):])
+	if err := w.WriteHeader(columns); err != nil {
+		return 0, err
+	}
+	for _, record := range f.records {
+		row := make([]interface{}, len(columns))
+		for i, name := range columns {
+			row[i] = record[name]
+		}
+		if err := w.WriteRow(row); err != nil {
+			return 0, err
+		}
+	}
+	return int64(len(f.records)), w.Close()
+}
+
+func TestEngine_RenderGrid_Regions(t *testing.T) {
+	executor := &fakeQueryExecutor{records: salesRecords()}
+	engine := NewEngine(nil, nil, executor)
+	config := `{
+		"cells": [
+			{"row": 0, "col": 0, "value": "Report"},
+			{"row": 1, "col": 0, "fieldName": "product"},
+			{"row": 1, "col": 1, "fieldName": "amount", "format": "0.00"},
+			{"row": 2, "col": 0, "value": "Total"},
+			{"row": 2, "col": 1, "value": "=SUM(B2:B2)", "format": "0.00"},
+			{"row": 3, "col": 0, "datasetId": "ds-2", "fieldName": "amount", "aggregation": "COUNT"}
+		],
+		"regions": [{"datasetId": "ds-1", "sortBy": "amount", "filters": [{"field": "region", "operator": "eq", "value": "North"}], "detail": {"start": 1}}]
+	}`
+
+	grid, err := engine.RenderGrid(context.Background(), config, nil, "tenant-1")
+	require.NoError(t, err)
+
+	assert.Equal(t, [][]string{
+		{"Report", ""},
+		{"Tea", "10.00"},
+		{"Rice", "7.00"},
+		{"Milk", "5.00"},
+		{"Total", "22.00"},
+		{"3", ""},
+	}, gridText(grid))
+
+	require.Len(t, executor.requests, 2)
+	assert.Equal(t, []string{"tenant-1", "tenant-1"}, executor.tenants)
+	assert.Equal(t, "ds-1", executor.requests[0].DatasetID)
+	assert.Equal(t, []string{"amount", "product"}, executor.requests[0].Fields)
+	assert.Equal(t, "amount", executor.requests[0].SortBy)
+	assert.Equal(t, "North", executor.requests[0].Filters[0].Value)
+	assert.Equal(t, "ds-2", executor.requests[1].DatasetID)
+}
+
+func TestEngine_Render_RegionErrors(t *testing.T) {
+	config := `{"cells": [{"row": 0, "col": 0, "fieldName": "product"}], "regions": [{"datasetId": "ds-1"}]}`
+
+	_, err := NewEngine(nil, nil, nil).Render(context.Background(), config, nil, "tenant-1")
+	assert.ErrorIs(t, err, ErrNoQueryExecutor)
+
+	executor := &fakeQueryExecutor{err: dataset.ErrExportTooLarge}
+	_, err = NewEngine(nil, nil, executor).Render(context.Background(), config, nil, "tenant-1")
+	assert.ErrorIs(t, err, dataset.ErrExportTooLarge)
+
+	_, err = NewEngine(nil, nil, executor).Render(context.Background(), `{"regions": [{"datasetId": ""}]}`, nil, "tenant-1")
+	assert.ErrorIs(t, err, ErrInvalidRegion)
+}
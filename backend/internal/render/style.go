@@ -0,0 +1,199 @@
+package render
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// CellStyle is the designer's cell style. Sizes are CSS pixels and colors
+// CSS hex or rgb()/rgba() values; unset fields fall back to the defaults
+// of a plain cell.
+type CellStyle struct {
+	FontFamily    string  `json:"fontFamily"`
+	FontSize      float64 `json:"fontSize"`
+	FontWeight    string  `json:"fontWeight"`
+	FontStyle     string  `json:"fontStyle"`
+	Color         string  `json:"color"`
+	Background    string  `json:"background"`
+	Align         string  `json:"align"`
+	VerticalAlign string  `json:"verticalAlign"`
+	BorderColor   string  `json:"borderColor"`
+	BorderWidth   float64 `json:"borderWidth"`
+	BorderStyle   string  `json:"borderStyle"`
+}
+
+// Resolved style values used by the exporters. Lengths are millimetres.
+type fontSpec struct {
+	size   float64 // points
+	bold   bool
+	italic bool
+}
+
+type borderSpec struct {
+	width  float64
+	color  color.NRGBA
+	dashed bool
+	dotted bool
+}
+
+var (
+	defaultTextColor   = color.NRGBA{A: 255}
+	defaultBorderColor = color.NRGBA{A: 255}
+)
+
+// pxToMM converts CSS pixels (96 per inch) to millimetres.
+func pxToMM(px float64) float64 {
+	return px * 25.4 / 96
+}
+
+func (s *CellStyle) font() fontSpec {
+	font := fontSpec{size: fontSizePt}
+	if s == nil {
+		return font
+	}
+	if s.FontSize > 0 {
+		font.size = s.FontSize * 0.75
+	}
+	font.bold = s.FontWeight == "bold" || s.FontWeight == "bolder" || s.FontWeight == "600" || s.FontWeight == "700"
+	font.italic = s.FontStyle == "italic" || s.FontStyle == "oblique"
+	return font
+}
+
+// lineHeight is the height of one line of text in millimetres.
+func (f fontSpec) lineHeight() float64 {
+	return f.size * lineHeight / fontSizePt
+}
+
+func (s *CellStyle) textColor() color.NRGBA {
+	if s != nil {
+		if c, ok := parseColor(s.Color); ok {
+			return c
+		}
+	}
+	return defaultTextColor
+}
+
+// background returns the fill color, if the cell has a visible one.
+func (s *CellStyle) background() (color.NRGBA, bool) {
+	if s == nil {
+		return color.NRGBA{}, false
+	}
+	c, ok := parseColor(s.Background)
+	if !ok || c.A == 0 {
+		return color.NRGBA{}, false
+	}
+	return c, true
+}
+
+// border returns the cell border, if it has one.
+func (s *CellStyle) border() (borderSpec, bool) {
+	border := borderSpec{width: pxToMM(0.75), color: defaultBorderColor}
+	if s == nil {
+		return border, true
+	}
+	switch s.BorderStyle {
+	case "none", "hidden":
+		return border, false
+	case "dashed":
+		border.dashed = true
+	case "dotted":
+		border.dotted = true
+	}
+	if s.BorderWidth > 0 {
+		border.width = pxToMM(s.BorderWidth)
+	}
+	if c, ok := parseColor(s.BorderColor); ok {
+		border.color = c
+	}
+	return border, true
+}
+
+func (s *CellStyle) align() string {
+	if s != nil && (s.Align == "center" || s.Align == "right") {
+		return s.Align
+	}
+	return "left"
+}
+
+func (s *CellStyle) verticalAlign() string {
+	if s != nil && (s.VerticalAlign == "top" || s.VerticalAlign == "bottom") {
+		return s.VerticalAlign
+	}
+	return "middle"
+}
+
+// parseColor reads #rgb, #rrggbb, #rrggbbaa, rgb() and rgba() colors.
+func parseColor(value string) (color.NRGBA, bool) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	switch {
+	case value == "transparent":
+		return color.NRGBA{}, true
+	case strings.HasPrefix(value, "#"):
+		hex := value[1:]
+		if len(hex) == 3 {
+			hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+		}
+		if len(hex) == 6 {
+			hex += "ff"
+		}
+		if len(hex) != 8 {
+			return color.NRGBA{}, false
+		}
+		n, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil {
+			return color.NRGBA{}, false
+		}
+		return color.NRGBA{R: uint8(n >> 24), G: uint8(n >> 16), B: uint8(n >> 8), A: uint8(n)}, true
+	case strings.HasPrefix(value, "rgb"):
+		open, end := strings.Index(value, "("), strings.LastIndex(value, ")")
+		if open < 0 || end < open {
+			return color.NRGBA{}, false
+		}
+		parts := strings.Split(value[open+1:end], ",")
+		if len(parts) != 3 && len(parts) != 4 {
+			return color.NRGBA{}, false
+		}
+		var channels [4]float64
+		channels[3] = 1
+		for i, part := range parts {
+			n, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+			if err != nil {
+				return color.NRGBA{}, false
+			}
+			channels[i] = n
+		}
+		return color.NRGBA{
+			R: clampChannel(channels[0]),
+			G: clampChannel(channels[1]),
+			B: clampChannel(channels[2]),
+			A: clampChannel(channels[3] * 255),
+		}, true
+	}
+	return color.NRGBA{}, false
+}
+
+func clampChannel(v float64) uint8 {
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return uint8(v + 0.5)
+}
+
+// opaque blends a translucent color onto white, for formats without alpha.
+func opaque(c color.NRGBA) color.NRGBA {
+	blend := func(v uint8) uint8 {
+		return uint8((int(v)*int(c.A) + 255*(255-int(c.A)) + 127) / 255)
+	}
+	return color.NRGBA{R: blend(c.R), G: blend(c.G), B: blend(c.B), A: 255}
+}
+
+// hexColor formats a color as RRGGBB.
+func hexColor(c color.NRGBA) string {
+	c = opaque(c)
+	return fmt.Sprintf("%02X%02X%02X", c.R, c.G, c.B)
+}
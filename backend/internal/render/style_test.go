@@ -0,0 +1,60 @@
+package render
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		value string
+		want  color.NRGBA
+		ok    bool
+	}{
+		{"#f00", color.NRGBA{R: 255, A: 255}, true},
+		{"#1E90FF", color.NRGBA{R: 30, G: 144, B: 255, A: 255}, true},
+		{"#00000080", color.NRGBA{A: 128}, true},
+		{"rgb(10, 20, 30)", color.NRGBA{R: 10, G: 20, B: 30, A: 255}, true},
+		{"rgba(10,20,30,0.5)", color.NRGBA{R: 10, G: 20, B: 30, A: 128}, true},
+		{"transparent", color.NRGBA{}, true},
+		{"red", color.NRGBA{}, false},
+		{"#12345", color.NRGBA{}, false},
+		{"", color.NRGBA{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, ok := parseColor(tt.value)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+	assert.Equal(t, "FF7F7F", hexColor(color.NRGBA{R: 255, A: 128}), "translucent colors are blended onto white")
+}
+
+func TestCellStyle(t *testing.T) {
+	var unstyled *CellStyle
+	assert.Equal(t, fontSpec{size: fontSizePt}, unstyled.font())
+	assert.Equal(t, "left", unstyled.align())
+	assert.Equal(t, "middle", unstyled.verticalAlign())
+	_, ok := unstyled.background()
+	assert.False(t, ok)
+	border, ok := unstyled.border()
+	assert.True(t, ok, "plain cells keep a thin border")
+	assert.False(t, border.dashed)
+
+	style := &CellStyle{FontSize: 16, FontWeight: "700", FontStyle: "italic", Align: "right", VerticalAlign: "top",
+		Background: "transparent", BorderStyle: "dashed", BorderWidth: 2, BorderColor: "#00f"}
+	assert.Equal(t, fontSpec{size: 12, bold: true, italic: true}, style.font())
+	assert.Equal(t, "right", style.align())
+	assert.Equal(t, "top", style.verticalAlign())
+	_, ok = style.background()
+	assert.False(t, ok, "transparent backgrounds are not painted")
+	border, ok = style.border()
+	assert.True(t, ok)
+	assert.Equal(t, borderSpec{width: pxToMM(2), color: color.NRGBA{B: 255, A: 255}, dashed: true}, border)
+
+	_, ok = (&CellStyle{BorderStyle: "none"}).border()
+	assert.False(t, ok)
+}
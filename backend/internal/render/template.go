@@ -1,17 +1,90 @@
 package render
 
+import "github.com/gujiaweiguo/goreport/internal/dataset"
+
 type ReportConfig struct {
-	Cells []Cell `json:"cells"`
+	Grid       GridConfig  `json:"grid"`
+	Cells      []Cell      `json:"cells"`
+	Regions    []Region    `json:"regions"`
+	Pivots     []Pivot     `json:"pivots"`
+	Parameters []Parameter `json:"parameters"`
+	Print      PrintConfig `json:"print"`
+}
+
+// Region is a list region: bands of template rows, or columns when the
+// direction is "horizontal", repeated for the records of a dataset. The
+// detail band repeats once per record. Each group starts a new section
+// whenever its field changes, with an optional header band before and
+// footer band after the section; the total band follows all records.
+//
+// Cells in a band bound with fieldName show the record's value, or with an
+// aggregation (SUM, AVG, COUNT, MIN or MAX) the aggregate over the records
+// of the section: the group in group bands, every record in the total.
+type Region struct {
+	DatasetID string           `json:"datasetId"`
+	Direction string           `json:"direction"`
+	Filters   []dataset.Filter `json:"filters"`
+	SortBy    string           `json:"sortBy"`
+	SortOrder string           `json:"sortOrder"`
+	Detail    Band             `json:"detail"`
+	Groups    []RegionGroup    `json:"groups"`
+	Total     *Band            `json:"total"`
+}
+
+// Band is a run of template rows, or columns, starting at Start.
+type Band struct {
+	Start int `json:"start"`
+	Size  int `json:"size"`
+}
+
+type RegionGroup struct {
+	Field  string `json:"field"`
+	Header *Band  `json:"header"`
+	Footer *Band  `json:"footer"`
+}
+
+// GridConfig sizes the designer grid in pixels. Per-column widths and
+// per-row heights override the defaults; row heights are minimums that grow
+// to fit wrapped text.
+type GridConfig struct {
+	CellWidth    float64         `json:"cellWidth"`
+	CellHeight   float64         `json:"cellHeight"`
+	ColumnWidths map[int]float64 `json:"columnWidths"`
+	RowHeights   map[int]float64 `json:"rowHeights"`
 }
 
+// PrintConfig holds the page setup used when a report is exported to a
+// paginated format.
+type PrintConfig struct {
+	PageSize    string `json:"pageSize"`
+	Orientation string `json:"orientation"`
+	HeaderRows  int    `json:"headerRows"`
+}
+
+// Cell is one designer cell. A value or text starting with "=" is a formula
+// evaluated once data is bound. Format is a number, currency or date mask
+// such as "#,##0.00", "¥#,##0.00", "0.0%" or "yyyy-MM-dd".
+//
+// A cell with datasetId and fieldName outside any region shows the field of
+// the dataset's first record, or its aggregation over every record.
 type Cell struct {
-	Row          int     `json:"row"`
-	Col          int     `json:"col"`
-	Value        string  `json:"value"`
-	Text         string  `json:"text"`
-	DatasourceID *string `json:"datasourceId"`
-	TableName    *string `json:"tableName"`
-	FieldName    *string `json:"fieldName"`
+	Row          int        `json:"row"`
+	Col          int        `json:"col"`
+	Value        string     `json:"value"`
+	Text         string     `json:"text"`
+	RowSpan      int        `json:"rowSpan"`
+	ColSpan      int        `json:"colSpan"`
+	Format       string     `json:"format"`
+	Style        *CellStyle `json:"style"`
+	DatasourceID *string    `json:"datasourceId"`
+	TableName    *string    `json:"tableName"`
+	FieldName    *string    `json:"fieldName"`
+	DatasetID    *string    `json:"datasetId"`
+	Aggregation  string     `json:"aggregation"`
+
+	// bound marks a cell whose value came from a dataset, so it is shown
+	// as data even if it looks like a formula.
+	bound bool
 }
 
 func GetTotalRows(config *ReportConfig) int {
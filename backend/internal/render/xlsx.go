@@ -0,0 +1,370 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	xlsxSheet       = "Sheet1"
+	xlsxMaxRows     = 1048576
+	xlsxMinColWidth = 8.0
+	xlsxMaxColWidth = 80.0
+	// xlsxMaxDigits is the precision Excel keeps; longer numbers such as
+	// account numbers stay text so no digits are lost.
+	xlsxMaxDigits = 15
+)
+
+var xlsxAvgPattern = regexp.MustCompile(`(?i)(^|[^A-Za-z0-9_$])AVG\s*\(`)
+
+var xlsxNumberPattern = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][-+]?[0-9]+)?$`)
+
+var (
+	xlsxDateLayouts     = []string{"2006-01-02"}
+	xlsxDateTimeLayouts = []string{
+		"2006-01-02 15:04:05",
+		"2006-01-02 15:04:05 -0700 MST",
+		time.RFC3339,
+		time.RFC3339Nano,
+	}
+)
+
+// WriteXLSX writes the grid to a single worksheet, each cell at its report
+// coordinate. Numbers and dates are stored as native Excel values, columns
+// are sized to their content and the header rows are bold and frozen. Rows
+// go through excelize's stream writer so large reports are not built up as
+// an in-memory sheet.
+func WriteXLSX(w io.Writer, grid *Grid) error {
+	if len(grid.Rows) > xlsxMaxRows {
+		return fmt.Errorf("report has %d rows, an xlsx sheet holds at most %d", len(grid.Rows), xlsxMaxRows)
+	}
+
+	file := excelize.NewFile()
+	defer file.Close()
+	stream, err := file.NewStreamWriter(xlsxSheet)
+	if err != nil {
+		return err
+	}
+	styles := newXLSXStyles(file)
+
+	for c, width := range xlsxColumnWidths(grid) {
+		if err := stream.SetColWidth(c+1, c+1, width); err != nil {
+			return err
+		}
+	}
+	headerRows := grid.Print.HeaderRows
+	if headerRows > len(grid.Rows) {
+		headerRows = len(grid.Rows)
+	}
+	if headerRows > 0 {
+		if err := stream.SetPanes(&excelize.Panes{
+			Freeze:      true,
+			YSplit:      headerRows,
+			TopLeftCell: fmt.Sprintf("A%d", headerRows+1),
+			ActivePane:  "bottomLeft",
+		}); err != nil {
+			return err
+		}
+	}
+
+	values := make([]interface{}, grid.Columns())
+	for r, row := range grid.Rows {
+		header := r < headerRows
+		for c := range row {
+			if values[c], err = styles.cell(&row[c], header); err != nil {
+				return err
+			}
+		}
+		cell, err := excelize.CoordinatesToCellName(1, r+1)
+		if err != nil {
+			return err
+		}
+		var opts []excelize.RowOpts
+		if height := grid.rowHeight(r); height > 0 {
+			// Row heights are points.
+			opts = append(opts, excelize.RowOpts{Height: min(height*0.75, 409)})
+		}
+		if err := stream.SetRow(cell, values, opts...); err != nil {
+			return err
+		}
+	}
+	if err := mergeXLSXCells(stream, grid); err != nil {
+		return err
+	}
+
+	if err := stream.Flush(); err != nil {
+		return err
+	}
+	return file.Write(w)
+}
+
+func mergeXLSXCells(stream *excelize.StreamWriter, grid *Grid) error {
+	for r, row := range grid.Rows {
+		for c := range row {
+			cell := &row[c]
+			if cell.Merged || (cell.rowSpan() == 1 && cell.colSpan() == 1) {
+				continue
+			}
+			topLeft, err := excelize.CoordinatesToCellName(c+1, r+1)
+			if err != nil {
+				return err
+			}
+			bottomRight, err := excelize.CoordinatesToCellName(c+cell.colSpan(), r+cell.rowSpan())
+			if err != nil {
+				return err
+			}
+			if err := stream.MergeCell(topLeft, bottomRight); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// xlsxStyleKey identifies a cell style; excelize styles are created once
+// per distinct key.
+type xlsxStyleKey struct {
+	style  CellStyle
+	format string
+	header bool
+}
+
+type xlsxStyles struct {
+	file *excelize.File
+	ids  map[xlsxStyleKey]int
+}
+
+func newXLSXStyles(file *excelize.File) *xlsxStyles {
+	return &xlsxStyles{file: file, ids: make(map[xlsxStyleKey]int)}
+}
+
+// cell converts a grid cell to the value excelize writes: a formula with
+// its computed result, a number, a date, or the text itself, styled after
+// the cell. Header cells stay text.
+func (s *xlsxStyles) cell(cell *GridCell, header bool) (interface{}, error) {
+	key := xlsxStyleKey{header: header}
+	if cell.Style != nil {
+		key.style = *cell.Style
+	}
+	var value interface{} = cell.Text
+	switch {
+	case cell.Merged:
+		value = nil
+	case header:
+	case cell.Value != nil && cell.Format != "" && excelNumberFormat(cell.Format) != "":
+		value, key.format = cell.Value, excelNumberFormat(cell.Format)
+	case cell.Formula != "":
+		if cell.Value != nil {
+			value = cell.Value
+		}
+	default:
+		var kind cellKind
+		value, kind = parseCellValue(cell.Text)
+		switch kind {
+		case cellDate:
+			key.format = "yyyy-mm-dd"
+		case cellDateTime:
+			key.format = "yyyy-mm-dd hh:mm:ss"
+		}
+	}
+	if value == "" {
+		value = nil
+	}
+
+	id, err := s.id(key)
+	if err != nil {
+		return nil, err
+	}
+	if cell.Formula != "" && !header {
+		return excelize.Cell{StyleID: id, Formula: xlsxFormula(cell.Formula), Value: value}, nil
+	}
+	if id == 0 {
+		return value, nil
+	}
+	return excelize.Cell{StyleID: id, Value: value}, nil
+}
+
+func (s *xlsxStyles) id(key xlsxStyleKey) (int, error) {
+	if key == (xlsxStyleKey{}) {
+		return 0, nil
+	}
+	if id, ok := s.ids[key]; ok {
+		return id, nil
+	}
+	id, err := s.file.NewStyle(xlsxStyle(key))
+	if err != nil {
+		return 0, err
+	}
+	s.ids[key] = id
+	return id, nil
+}
+
+func xlsxStyle(key xlsxStyleKey) *excelize.Style {
+	style := key.style
+	font := style.font()
+	result := &excelize.Style{
+		Font: &excelize.Font{Bold: key.header || font.bold, Italic: font.italic},
+	}
+	if style.FontSize > 0 {
+		result.Font.Size = font.size
+	}
+	if family := cssFontFamily(style.FontFamily); family != "" {
+		result.Font.Family = strings.TrimSpace(strings.Split(family, ",")[0])
+	}
+	if c, ok := parseColor(style.Color); ok {
+		result.Font.Color = hexColor(c)
+	}
+	if fill, ok := style.background(); ok {
+		result.Fill = excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{hexColor(fill)}}
+	}
+	if style != (CellStyle{}) {
+		result.Alignment = &excelize.Alignment{
+			Horizontal: style.align(),
+			Vertical:   map[string]string{"top": "top", "middle": "center", "bottom": "bottom"}[style.verticalAlign()],
+			WrapText:   true,
+		}
+		if border, ok := style.border(); ok {
+			for _, side := range []string{"left", "top", "right", "bottom"} {
+				result.Border = append(result.Border, excelize.Border{Type: side, Color: hexColor(border.color), Style: xlsxBorderStyle(border)})
+			}
+		}
+	}
+	if key.format != "" {
+		format := key.format
+		result.CustomNumFmt = &format
+	}
+	return result
+}
+
+// xlsxBorderStyle maps a border to excelize's line style index.
+func xlsxBorderStyle(border borderSpec) int {
+	switch {
+	case border.dashed:
+		return 3
+	case border.dotted:
+		return 4
+	case border.width >= pxToMM(3):
+		return 5
+	case border.width >= pxToMM(2):
+		return 2
+	}
+	return 1
+}
+
+// xlsxFormula converts a report formula to Excel's dialect.
+func xlsxFormula(formula string) string {
+	formula = strings.TrimPrefix(formula, "=")
+	return xlsxAvgPattern.ReplaceAllString(formula, "${1}AVERAGE(")
+}
+
+type cellKind int
+
+const (
+	cellText cellKind = iota
+	cellNumber
+	cellDate
+	cellDateTime
+)
+
+// parseCellValue recognises numbers and dates in rendered cell text. Text
+// that only looks numeric, like a code with leading zeros, stays text.
+func parseCellValue(text string) (interface{}, cellKind) {
+	if xlsxNumberPattern.MatchString(text) && significantDigits(text) <= xlsxMaxDigits {
+		if i, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return i, cellNumber
+		}
+		if f, err := strconv.ParseFloat(text, 64); err == nil {
+			return f, cellNumber
+		}
+	}
+	for _, layout := range xlsxDateLayouts {
+		if t, err := time.Parse(layout, text); err == nil {
+			return t, cellDate
+		}
+	}
+	for _, layout := range xlsxDateTimeLayouts {
+		if t, err := time.Parse(layout, text); err == nil {
+			return t, cellDateTime
+		}
+	}
+	return text, cellText
+}
+
+func significantDigits(number string) int {
+	digits := 0
+	for _, r := range number {
+		if r == 'e' || r == 'E' {
+			break
+		}
+		if r >= '0' && r <= '9' {
+			digits++
+		}
+	}
+	return digits
+}
+
+// xlsxColumnWidths sizes each column to its widest cell, counting wide
+// (CJK) characters double, in Excel's character-width units.
+func xlsxColumnWidths(grid *Grid) []float64 {
+	widths := make([]float64, grid.Columns())
+	for _, row := range grid.Rows {
+		for c := range row {
+			cell := &row[c]
+			if cell.Merged || cell.colSpan() > 1 {
+				continue
+			}
+			width := displayWidth(cell.Text)
+			switch _, kind := parseCellValue(cell.Text); kind {
+			case cellDate:
+				width = 10
+			case cellDateTime:
+				width = 19
+			}
+			if width > widths[c] {
+				widths[c] = width
+			}
+		}
+	}
+	for c := range widths {
+		if configured := grid.columnWidth(c); configured > 0 {
+			// A character is about seven pixels wide in Excel's default
+			// font.
+			widths[c] = configured / 7
+			continue
+		}
+		widths[c] += 2
+		if widths[c] < xlsxMinColWidth {
+			widths[c] = xlsxMinColWidth
+		}
+		if widths[c] > xlsxMaxColWidth {
+			widths[c] = xlsxMaxColWidth
+		}
+	}
+	return widths
+}
+
+// displayWidth is the width of the longest line of text.
+func displayWidth(text string) float64 {
+	widest, width := 0.0, 0.0
+	for _, r := range text {
+		switch {
+		case r == '\n':
+			width = 0
+		case unicode.In(r, unicode.Han, unicode.Hangul, unicode.Hiragana, unicode.Katakana) || (r >= 0xFF00 && r <= 0xFFEF):
+			width += 2
+		default:
+			width++
+		}
+		if width > widest {
+			widest = width
+		}
+	}
+	return widest
+}
@@ -0,0 +1,154 @@
+package render
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xuri/excelize/v2"
+)
+
+func TestParseCellValue(t *testing.T) {
+	tests := []struct {
+		text  string
+		value interface{}
+		kind  cellKind
+	}{
+		{"120", int64(120), cellNumber},
+		{"-3.25", -3.25, cellNumber},
+		{"1e3", float64(1000), cellNumber},
+		{"0.5", 0.5, cellNumber},
+		{"00123", "00123", cellText},
+		{"1234567890123456", "1234567890123456", cellText},
+		{"12,000", "12,000", cellText},
+		{"2024-03-01", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), cellDate},
+		{"2024-03-01 08:30:00", time.Date(2024, 3, 1, 8, 30, 0, 0, time.UTC), cellDateTime},
+		{"2024-03-01 08:30:00 +0000 UTC", time.Date(2024, 3, 1, 8, 30, 0, 0, time.UTC), cellDateTime},
+		{"east", "east", cellText},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			value, kind := parseCellValue(tt.text)
+			assert.Equal(t, tt.kind, kind)
+			if want, ok := tt.value.(time.Time); ok {
+				assert.True(t, want.Equal(value.(time.Time)), "got %v", value)
+				return
+			}
+			assert.Equal(t, tt.value, value)
+		})
+	}
+}
+
+func TestWriteXLSX(t *testing.T) {
+	grid := &Grid{
+		Rows: textRows([][]string{
+			{"地区", "Amount", "Day", ""},
+			{"east", "120.5", "2024-03-01", "00123"},
+			{"华东区域销售汇总", "80", "", "note"},
+		}),
+		Print: PrintConfig{HeaderRows: 1},
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, WriteXLSX(&out, grid))
+
+	file, err := excelize.OpenReader(&out)
+	require.NoError(t, err)
+	defer file.Close()
+
+	rows, err := file.GetRows(xlsxSheet)
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"地区", "Amount", "Day"},
+		{"east", "120.5", "2024-03-01", "00123"},
+		{"华东区域销售汇总", "80", "", "note"},
+	}, rows)
+
+	for _, ref := range []string{"B2", "B3", "C2"} {
+		cellType, err := file.GetCellType(xlsxSheet, ref)
+		require.NoError(t, err)
+		assert.NotEqual(t, excelize.CellTypeSharedString, cellType, ref)
+		assert.NotEqual(t, excelize.CellTypeInlineString, cellType, ref)
+	}
+	raw, err := file.GetCellValue(xlsxSheet, "C2", excelize.Options{RawCellValue: true})
+	require.NoError(t, err)
+	assert.Equal(t, "45352", raw, "dates are stored as serial numbers")
+	cellType, err := file.GetCellType(xlsxSheet, "D2")
+	require.NoError(t, err)
+	assert.Equal(t, excelize.CellTypeInlineString, cellType)
+
+	wide, err := file.GetColWidth(xlsxSheet, "A")
+	require.NoError(t, err)
+	assert.Equal(t, 18.0, wide, "CJK characters count double")
+	narrow, err := file.GetColWidth(xlsxSheet, "B")
+	require.NoError(t, err)
+	assert.Equal(t, xlsxMinColWidth, narrow)
+
+	panes, err := file.GetPanes(xlsxSheet)
+	require.NoError(t, err)
+	assert.True(t, panes.Freeze)
+	assert.Equal(t, 1, panes.YSplit)
+}
+
+func TestWriteXLSX_StylesMergesAndFormulas(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, WriteXLSX(&out, styledGrid()))
+
+	file, err := excelize.OpenReader(&out)
+	require.NoError(t, err)
+	defer file.Close()
+
+	merged, err := file.GetMergeCells(xlsxSheet)
+	require.NoError(t, err)
+	var ranges []string
+	for _, cell := range merged {
+		ranges = append(ranges, cell.GetStartAxis()+":"+cell.GetEndAxis())
+	}
+	assert.ElementsMatch(t, []string{"A1:C1", "A3:A4"}, ranges)
+
+	formula, err := file.GetCellFormula(xlsxSheet, "B5")
+	require.NoError(t, err)
+	assert.Equal(t, "SUM(B3:B4)", formula)
+	raw, err := file.GetCellValue(xlsxSheet, "B5", excelize.Options{RawCellValue: true})
+	require.NoError(t, err)
+	assert.Equal(t, "2000", raw, "formulas carry their computed value")
+	shown, err := file.GetCellValue(xlsxSheet, "B3")
+	require.NoError(t, err)
+	assert.Equal(t, "¥1,234.50", shown)
+	raw, err = file.GetCellValue(xlsxSheet, "B3", excelize.Options{RawCellValue: true})
+	require.NoError(t, err)
+	assert.Equal(t, "1234.5", raw, "formatted amounts stay numbers")
+
+	styleID, err := file.GetCellStyle(xlsxSheet, "A1")
+	require.NoError(t, err)
+	style, err := file.GetStyle(styleID)
+	require.NoError(t, err)
+	assert.True(t, style.Font.Bold)
+	assert.Equal(t, 15.0, style.Font.Size)
+	assert.Equal(t, "FFFFFF", style.Font.Color)
+	assert.Equal(t, []string{"1F4E79"}, style.Fill.Color)
+	assert.Equal(t, "center", style.Alignment.Horizontal)
+
+	styleID, err = file.GetCellStyle(xlsxSheet, "A5")
+	require.NoError(t, err)
+	style, err = file.GetStyle(styleID)
+	require.NoError(t, err)
+	require.Len(t, style.Border, 4)
+	assert.Equal(t, 3, style.Border[0].Style, "dashed border")
+	assert.Equal(t, "FF0000", style.Border[0].Color)
+
+	width, err := file.GetColWidth(xlsxSheet, "A")
+	require.NoError(t, err)
+	assert.InDelta(t, 200.0/7, width, 0.01)
+	height, err := file.GetRowHeight(xlsxSheet, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 45.0, height)
+}
+
+func TestXLSXFormula(t *testing.T) {
+	assert.Equal(t, "AVERAGE(B2:B4)+1", xlsxFormula("=AVG(B2:B4)+1"))
+	assert.Equal(t, "SUM(A1, AVERAGE(C1:C2))", xlsxFormula("=SUM(A1, avg(C1:C2))"))
+}
@@ -0,0 +1,90 @@
+package report
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/gujiaweiguo/goreport/internal/render"
+)
+
+const (
+	ExportFormatPDF  = "pdf"
+	ExportFormatXLSX = "xlsx"
+	ExportFormatDOCX = "docx"
+	ExportFormatPNG  = "png"
+)
+
+var ErrUnsupportedFormat = errors.New("unsupported export format")
+
+// ExportRequest renders a report to a file. The page setup fields override
+// the report's own print settings when set; Page and DPI only apply to PNG,
+// which renders a single page.
+type ExportRequest struct {
+	TenantID    string                 `json:"-"`
+	ID          string                 `json:"id" binding:"required"`
+	Format      string                 `json:"-"`
+	Params      map[string]interface{} `json:"params"`
+	PageSize    string                 `json:"pageSize"`
+	Orientation string                 `json:"orientation"`
+	HeaderRows  *int                   `json:"headerRows"`
+	Page        int                    `json:"page"`
+	DPI         int                    `json:"dpi"`
+}
+
+// ExportContentType returns the MIME type of an export format.
+func ExportContentType(format string) string {
+	switch format {
+	case ExportFormatPDF:
+		return "application/pdf"
+	case ExportFormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	case ExportFormatDOCX:
+		return "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	case ExportFormatPNG:
+		return "image/png"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+func (s *service) Export(ctx context.Context, req *ExportRequest, w io.Writer) error {
+	var write func(io.Writer, *render.Grid) error
+	switch req.Format {
+	case ExportFormatPDF:
+		write = render.WritePDF
+	case ExportFormatXLSX:
+		write = render.WriteXLSX
+	case ExportFormatDOCX:
+		write = render.WriteDOCX
+	case ExportFormatPNG:
+		write = func(w io.Writer, grid *render.Grid) error {
+			return render.WritePNG(w, grid, req.Page, req.DPI)
+		}
+	default:
+		return fmt.Errorf("%w %q", ErrUnsupportedFormat, req.Format)
+	}
+
+	report, err := s.load(ctx, req.ID, req.TenantID)
+	if err != nil {
+		return err
+	}
+
+	grid, err := s.render.RenderGrid(ctx, report.Config, req.Params, req.TenantID)
+	if err != nil {
+		return err
+	}
+	grid.Title = report.Name
+	if req.PageSize != "" {
+		grid.Print.PageSize = req.PageSize
+	}
+	if req.Orientation != "" {
+		grid.Print.Orientation = req.Orientation
+	}
+	if req.HeaderRows != nil {
+		grid.Print.HeaderRows = *req.HeaderRows
+	}
+
+	return write(w, grid)
+}
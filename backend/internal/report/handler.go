@@ -1,10 +1,17 @@
 package report
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gujiaweiguo/goreport/internal/auth"
+	"github.com/gujiaweiguo/goreport/internal/dataset"
+	"github.com/gujiaweiguo/goreport/internal/render"
+	"github.com/gujiaweiguo/goreport/internal/version"
 )
 
 type Handler struct {
@@ -27,6 +34,7 @@ func (h *Handler) Create(c *gin.Context) {
 		c.JSON(http.StatusForbidden, gin.H{"success": false, "message": "tenant not found"})
 		return
 	}
+	req.CreatedBy = auth.GetUserID(c)
 
 	report, err := h.service.Create(c.Request.Context(), &req)
 	if err != nil {
@@ -49,6 +57,7 @@ func (h *Handler) Update(c *gin.Context) {
 		c.JSON(http.StatusForbidden, gin.H{"success": false, "message": "tenant not found"})
 		return
 	}
+	req.UpdatedBy = auth.GetUserID(c)
 
 	report, err := h.service.Update(c.Request.Context(), &req)
 	if err != nil {
@@ -93,7 +102,7 @@ func (h *Handler) Get(c *gin.Context) {
 		return
 	}
 
-	report, err := h.service.Get(c.Request.Context(), id, tenantID)
+	report, err := h.service.Get(version.ViewContext(c), id, tenantID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "report not found"})
 		return
@@ -109,7 +118,7 @@ func (h *Handler) List(c *gin.Context) {
 		return
 	}
 
-	reports, err := h.service.List(c.Request.Context(), tenantID)
+	reports, err := h.service.List(version.ViewContext(c), tenantID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "failed to list reports"})
 		return
@@ -131,11 +140,215 @@ func (h *Handler) Preview(c *gin.Context) {
 		return
 	}
 
-	resp, err := h.service.Preview(c.Request.Context(), &req)
+	resp, err := h.service.Preview(version.ViewContext(c), &req)
 	if err != nil {
+		if IsBadInput(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "failed to preview report"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"success": true, "result": resp, "message": "success"})
 }
+
+func (h *Handler) Export(c *gin.Context) {
+	var req ExportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "invalid request"})
+		return
+	}
+
+	req.TenantID = auth.GetTenantID(c)
+	if req.TenantID == "" {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "message": "tenant not found"})
+		return
+	}
+
+	req.Format = c.DefaultQuery("format", ExportFormatPDF)
+	out := dataset.NewExportResponseWriter(c, ExportContentType(req.Format), fmt.Sprintf("%s.%s", req.ID, req.Format))
+	err := h.service.Export(version.ViewContext(c), &req, out)
+	if err == nil {
+		return
+	}
+
+	if out.Started() {
+		// The download is already under way; all we can do is stop it.
+		_ = c.Error(err)
+		c.Abort()
+		return
+	}
+	switch {
+	case errors.Is(err, ErrUnsupportedFormat), IsBadInput(err):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+	case errors.Is(err, ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "report not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "failed to export report"})
+	}
+}
+
+// Parameters returns the parameters of a report with their options.
+func (h *Handler) Parameters(c *gin.Context) {
+	id := c.Query("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "id is required"})
+		return
+	}
+
+	tenantID := auth.GetTenantID(c)
+	if tenantID == "" {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "message": "tenant not found"})
+		return
+	}
+
+	parameters, err := h.service.Parameters(version.ViewContext(c), id, tenantID)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "report not found"})
+		case IsBadInput(err):
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "failed to load report parameters"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": parameters, "message": "success"})
+}
+
+func (h *Handler) Versions(c *gin.Context) {
+	id := c.Query("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "id is required"})
+		return
+	}
+
+	tenantID := auth.GetTenantID(c)
+	if tenantID == "" {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "message": "tenant not found"})
+		return
+	}
+
+	versions, err := h.service.Versions(c.Request.Context(), id, tenantID)
+	if writeVersionError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": versions, "message": "success"})
+}
+
+func (h *Handler) Version(c *gin.Context) {
+	id := c.Query("id")
+	number, err := strconv.Atoi(c.Query("version"))
+	if id == "" || err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "id and version are required"})
+		return
+	}
+
+	tenantID := auth.GetTenantID(c)
+	if tenantID == "" {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "message": "tenant not found"})
+		return
+	}
+
+	result, err := h.service.Version(c.Request.Context(), id, tenantID, number)
+	if writeVersionError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": result, "message": "success"})
+}
+
+// Diff compares the versions given as the from and to query parameters; an
+// omitted or 0 version is the current draft.
+func (h *Handler) Diff(c *gin.Context) {
+	id := c.Query("id")
+	from, fromErr := strconv.Atoi(c.DefaultQuery("from", "0"))
+	to, toErr := strconv.Atoi(c.DefaultQuery("to", "0"))
+	if id == "" || fromErr != nil || toErr != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "invalid versions"})
+		return
+	}
+
+	tenantID := auth.GetTenantID(c)
+	if tenantID == "" {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "message": "tenant not found"})
+		return
+	}
+
+	diff, err := h.service.Diff(c.Request.Context(), id, tenantID, from, to)
+	if writeVersionError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": diff, "message": "success"})
+}
+
+type versionRequest struct {
+	ID      string `json:"id" binding:"required"`
+	Version int    `json:"version"`
+}
+
+// Publish pins a version for viewers; without one the latest save is
+// published.
+func (h *Handler) Publish(c *gin.Context) {
+	h.changeVersion(c, h.service.Publish, "report published")
+}
+
+// Rollback restores a version into the draft.
+func (h *Handler) Rollback(c *gin.Context) {
+	h.changeVersion(c, h.service.Rollback, "report rolled back")
+}
+
+func (h *Handler) changeVersion(c *gin.Context, change func(ctx context.Context, id, tenantID string, number int, userID string) (*Report, error), message string) {
+	var req versionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "invalid request"})
+		return
+	}
+
+	tenantID := auth.GetTenantID(c)
+	if tenantID == "" {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "message": "tenant not found"})
+		return
+	}
+	if !version.CanEdit(auth.GetRoles(c)) {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "message": "insufficient permissions"})
+		return
+	}
+
+	report, err := change(c.Request.Context(), req.ID, tenantID, req.Version, auth.GetUserID(c))
+	if writeVersionError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": report, "message": message})
+}
+
+// writeVersionError writes the response for a failed version operation and
+// reports whether there was one.
+func writeVersionError(c *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "report not found"})
+	case errors.Is(err, version.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "version not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "version operation failed"})
+	}
+	return true
+}
+
+// IsBadInput reports whether a render failed because of what was
+// asked for: the report's regions, parameters or print settings, a page
+// past the end, or more data than can be rendered.
+func IsBadInput(err error) bool {
+	return errors.Is(err, render.ErrInvalidRegion) || errors.Is(err, render.ErrInvalidParameter) ||
+		errors.Is(err, render.ErrInvalidPrintConfig) || errors.Is(err, render.ErrPageOutOfRange) ||
+		errors.Is(err, dataset.ErrExportTooLarge)
+}
@@ -3,12 +3,16 @@ package report
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gujiaweiguo/goreport/internal/render"
+	"github.com/gujiaweiguo/goreport/internal/version"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -62,6 +66,59 @@ func (m *mockReportService) Preview(ctx context.Context, req *PreviewRequest) (*
 	return args.Get(0).(*PreviewResponse), args.Error(1)
 }
 
+func (m *mockReportService) Export(ctx context.Context, req *ExportRequest, w io.Writer) error {
+	args := m.Called(ctx, req, w)
+	return args.Error(0)
+}
+
+func (m *mockReportService) Parameters(ctx context.Context, id, tenantID string) ([]render.Parameter, error) {
+	args := m.Called(ctx, id, tenantID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]render.Parameter), args.Error(1)
+}
+
+func (m *mockReportService) Versions(ctx context.Context, id, tenantID string) ([]*version.Version, error) {
+	args := m.Called(ctx, id, tenantID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*version.Version), args.Error(1)
+}
+
+func (m *mockReportService) Version(ctx context.Context, id, tenantID string, number int) (*version.Version, error) {
+	args := m.Called(ctx, id, tenantID, number)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*version.Version), args.Error(1)
+}
+
+func (m *mockReportService) Diff(ctx context.Context, id, tenantID string, from, to int) (*version.Diff, error) {
+	args := m.Called(ctx, id, tenantID, from, to)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*version.Diff), args.Error(1)
+}
+
+func (m *mockReportService) Publish(ctx context.Context, id, tenantID string, number int, userID string) (*Report, error) {
+	args := m.Called(ctx, id, tenantID, number, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Report), args.Error(1)
+}
+
+func (m *mockReportService) Rollback(ctx context.Context, id, tenantID string, number int, userID string) (*Report, error) {
+	args := m.Called(ctx, id, tenantID, number, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Report), args.Error(1)
+}
+
 func setupReportTestHandler() (*Handler, *mockReportService) {
 	gin.SetMode(gin.TestMode)
 	mockSvc := &mockReportService{}
@@ -364,6 +421,59 @@ func TestReportHandler_Preview_Error(t *testing.T) {
 	mockSvc.AssertExpectations(t)
 }
 
+func TestReportHandler_Preview_InvalidParameter(t *testing.T) {
+	handler, mockSvc := setupReportTestHandler()
+
+	mockSvc.On("Preview", mock.Anything, mock.MatchedBy(func(req *PreviewRequest) bool {
+		return req.Params["region"] == "North"
+	})).Return(nil, fmt.Errorf("%w: month is required", render.ErrInvalidParameter))
+
+	body := `{"id":"r-1","params":{"region":"North"}}`
+	router := gin.New()
+	router.POST("/preview", func(c *gin.Context) {
+		c.Set("tenantId", "tenant-1")
+		handler.Preview(c)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/preview", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "month is required")
+	mockSvc.AssertExpectations(t)
+}
+
+func TestReportHandler_Parameters(t *testing.T) {
+	handler, mockSvc := setupReportTestHandler()
+
+	mockSvc.On("Parameters", mock.Anything, "r-1", "tenant-1").Return([]render.Parameter{
+		{Name: "region", Type: render.ParamSelect, Options: []interface{}{"North", "South"}},
+	}, nil)
+	mockSvc.On("Parameters", mock.Anything, "missing", "tenant-1").Return(nil, ErrNotFound)
+
+	router := gin.New()
+	router.GET("/parameters", func(c *gin.Context) {
+		c.Set("tenantId", "tenant-1")
+		handler.Parameters(c)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/parameters?id=r-1", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"options":["North","South"]`)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/parameters?id=missing", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/parameters", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockSvc.AssertExpectations(t)
+}
+
 func TestReportHandler_Update_NoTenant(t *testing.T) {
 	handler, _ := setupReportTestHandler()
 
@@ -439,3 +549,108 @@ func TestReportHandler_Get_MissingID(t *testing.T) {
 
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 }
+
+func TestReportHandler_Export(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		body       string
+		err        error
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "pdf", query: "?format=pdf", body: `{"id":"r-1","pageSize":"A3","orientation":"landscape"}`, wantStatus: http.StatusOK, wantBody: "%PDF-"},
+		{name: "default format", body: `{"id":"r-1"}`, wantStatus: http.StatusOK, wantBody: "%PDF-"},
+		{name: "unsupported format", query: "?format=bmp", body: `{"id":"r-1"}`, err: fmt.Errorf("%w %q", ErrUnsupportedFormat, "bmp"), wantStatus: http.StatusBadRequest, wantBody: "unsupported export format"},
+		{name: "invalid page size", body: `{"id":"r-1","pageSize":"B9"}`, err: fmt.Errorf("%w: unsupported page size", render.ErrInvalidPrintConfig), wantStatus: http.StatusBadRequest},
+		{name: "not found", body: `{"id":"r-1"}`, err: ErrNotFound, wantStatus: http.StatusNotFound},
+		{name: "render error", body: `{"id":"r-1"}`, err: errors.New("boom"), wantStatus: http.StatusInternalServerError, wantBody: "failed to export report"},
+		{name: "missing id", body: `{}`, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, mockSvc := setupReportTestHandler()
+			mockSvc.On("Export", mock.Anything, mock.MatchedBy(func(req *ExportRequest) bool {
+				return req.ID == "r-1" && req.TenantID == "tenant-1"
+			}), mock.Anything).Run(func(args mock.Arguments) {
+				if tt.err == nil {
+					_, _ = args.Get(2).(io.Writer).Write([]byte("%PDF-1.3"))
+				}
+			}).Return(tt.err)
+
+			router := gin.New()
+			router.POST("/export", func(c *gin.Context) {
+				c.Set("tenantId", "tenant-1")
+				handler.Export(c)
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/export"+tt.query, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			assert.Contains(t, w.Body.String(), tt.wantBody)
+			if tt.wantStatus == http.StatusOK {
+				assert.Equal(t, "application/pdf", w.Header().Get("Content-Type"))
+				assert.Equal(t, `attachment; filename="r-1.pdf"`, w.Header().Get("Content-Disposition"))
+			}
+		})
+	}
+}
+
+func TestReportHandler_Versions(t *testing.T) {
+	handler, mockSvc := setupReportTestHandler()
+	mockSvc.On("Versions", mock.Anything, "r-1", "tenant-1").Return([]*version.Version{{Number: 1}}, nil)
+	mockSvc.On("Diff", mock.Anything, "r-1", "tenant-1", 1, 0).Return(&version.Diff{From: 1}, nil)
+	mockSvc.On("Version", mock.Anything, "r-1", "tenant-1", 5).Return(nil, version.ErrNotFound)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/jmreport/versions?id=r-1", nil)
+	c.Set("tenantId", "tenant-1")
+	handler.Versions(c)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"number":1`)
+
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/jmreport/diff?id=r-1&from=1", nil)
+	c.Set("tenantId", "tenant-1")
+	handler.Diff(c)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/jmreport/version?id=r-1&version=5", nil)
+	c.Set("tenantId", "tenant-1")
+	handler.Version(c)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestReportHandler_Publish(t *testing.T) {
+	handler, mockSvc := setupReportTestHandler()
+	mockSvc.On("Publish", mock.Anything, "r-1", "tenant-1", 2, "user-1").Return(&Report{ID: "r-1", PublishedVersion: 2}, nil)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/jmreport/publish", strings.NewReader(`{"id":"r-1","version":2}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Set("tenantId", "tenant-1")
+	c.Set("userId", "user-1")
+	handler.Publish(c)
+	assert.Equal(t, http.StatusForbidden, w.Code, "viewers cannot publish")
+
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/jmreport/publish", strings.NewReader(`{"id":"r-1","version":2}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Set("tenantId", "tenant-1")
+	c.Set("userId", "user-1")
+	c.Set("roles", []string{"admin"})
+	handler.Publish(c)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"publishedVersion":2`)
+	mockSvc.AssertExpectations(t)
+}
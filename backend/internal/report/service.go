@@ -5,10 +5,12 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"time"
 
 	"github.com/gujiaweiguo/goreport/internal/cache"
 	"github.com/gujiaweiguo/goreport/internal/render"
+	"github.com/gujiaweiguo/goreport/internal/version"
 )
 
 var ErrNotFound = errors.New("report not found")
@@ -20,43 +22,58 @@ type Service interface {
 	Get(ctx context.Context, id, tenantID string) (*Report, error)
 	List(ctx context.Context, tenantID string) ([]*Report, error)
 	Preview(ctx context.Context, req *PreviewRequest) (*PreviewResponse, error)
+	Export(ctx context.Context, req *ExportRequest, w io.Writer) error
+	Parameters(ctx context.Context, id, tenantID string) ([]render.Parameter, error)
+	Versions(ctx context.Context, id, tenantID string) ([]*version.Version, error)
+	Version(ctx context.Context, id, tenantID string, number int) (*version.Version, error)
+	Diff(ctx context.Context, id, tenantID string, from, to int) (*version.Diff, error)
+	Publish(ctx context.Context, id, tenantID string, number int, userID string) (*Report, error)
+	Rollback(ctx context.Context, id, tenantID string, number int, userID string) (*Report, error)
 }
 
 type service struct {
-	repo   Repository
-	render *render.Engine
-	cache  *cache.Cache
+	repo    Repository
+	render  *render.Engine
+	cache   *cache.Cache
+	history *version.History
 }
 
-func NewService(repo Repository, engine *render.Engine, cache *cache.Cache) Service {
-	return &service{repo: repo, render: engine, cache: cache}
+func NewService(repo Repository, engine *render.Engine, cache *cache.Cache, versions version.Repository) Service {
+	return &service{repo: repo, render: engine, cache: cache, history: version.NewHistory(versions, version.ResourceReport)}
 }
 
 type CreateRequest struct {
-	TenantID string          `json:"-"`
-	Name     string          `json:"name" binding:"required"`
-	Code     string          `json:"code"`
-	Type     string          `json:"type"`
-	Config   json.RawMessage `json:"config" binding:"required"`
+	TenantID  string          `json:"-"`
+	CreatedBy string          `json:"-"`
+	Name      string          `json:"name" binding:"required"`
+	Code      string          `json:"code"`
+	Type      string          `json:"type"`
+	Config    json.RawMessage `json:"config" binding:"required"`
 }
 
 type UpdateRequest struct {
-	TenantID string          `json:"-"`
-	ID       string          `json:"id" binding:"required"`
-	Name     string          `json:"name"`
-	Code     string          `json:"code"`
-	Type     string          `json:"type"`
-	Config   json.RawMessage `json:"config"`
+	TenantID  string          `json:"-"`
+	UpdatedBy string          `json:"-"`
+	ID        string          `json:"id" binding:"required"`
+	Name      string          `json:"name"`
+	Code      string          `json:"code"`
+	Type      string          `json:"type"`
+	Config    json.RawMessage `json:"config"`
 }
 
+// PreviewRequest asks for one page of a report, counted from 1. Page may
+// also be given as params.page; it defaults to the first page.
 type PreviewRequest struct {
 	TenantID string                 `json:"-"`
 	ID       string                 `json:"id" binding:"required"`
+	Page     int                    `json:"page"`
 	Params   map[string]interface{} `json:"params"`
 }
 
 type PreviewResponse struct {
-	HTML string `json:"html"`
+	HTML       string `json:"html"`
+	Page       int    `json:"page"`
+	TotalPages int    `json:"totalPages"`
 }
 
 func (s *service) Create(ctx context.Context, req *CreateRequest) (*Report, error) {
@@ -67,16 +84,20 @@ func (s *service) Create(ctx context.Context, req *CreateRequest) (*Report, erro
 		Code:     req.Code,
 		Type:     defaultReportType(req.Type),
 		Config:   string(req.Config),
-		Status:   1,
+		Status:   version.StatusDraft,
 	}
 
-	if err := s.repo.Create(ctx, report); err != nil {
+	if err := s.inTx(ctx, func(repo Repository, history *version.History) error {
+		if err := repo.Create(ctx, report); err != nil {
+			return err
+		}
+		_, err := history.Record(ctx, report.TenantID, report.ID, req.CreatedBy, "", snapshotOf(report))
+		return err
+	}); err != nil {
 		return nil, err
 	}
 
-	if s.cache != nil {
-		_ = s.cache.Invalidate(ctx, req.TenantID, "report:data")
-	}
+	s.invalidate(ctx, req.TenantID)
 
 	return report, nil
 }
@@ -86,27 +107,34 @@ func (s *service) Update(ctx context.Context, req *UpdateRequest) (*Report, erro
 	if err != nil {
 		return nil, ErrNotFound
 	}
-
-	if req.Name != "" {
-		report.Name = req.Name
-	}
-	if req.Code != "" {
-		report.Code = req.Code
-	}
-	if req.Type != "" {
-		report.Type = req.Type
-	}
-	if len(req.Config) > 0 {
-		report.Config = string(req.Config)
-	}
-
-	if err := s.repo.Update(ctx, report); err != nil {
+	if err := s.inTx(ctx, func(repo Repository, history *version.History) error {
+		if err := s.pinPublished(ctx, history, report, req.UpdatedBy); err != nil {
+			return err
+		}
+
+		if req.Name != "" {
+			report.Name = req.Name
+		}
+		if req.Code != "" {
+			report.Code = req.Code
+		}
+		if req.Type != "" {
+			report.Type = req.Type
+		}
+		if len(req.Config) > 0 {
+			report.Config = string(req.Config)
+		}
+
+		if err := repo.Update(ctx, report); err != nil {
+			return err
+		}
+		_, err := history.Record(ctx, report.TenantID, report.ID, req.UpdatedBy, "", snapshotOf(report))
+		return err
+	}); err != nil {
 		return nil, err
 	}
 
-	if s.cache != nil {
-		_ = s.cache.Invalidate(ctx, req.TenantID, "report:data")
-	}
+	s.invalidate(ctx, req.TenantID)
 
 	return report, nil
 }
@@ -118,30 +146,61 @@ func (s *service) Delete(ctx context.Context, id, tenantID string) error {
 	return nil
 }
 
+// Get returns the draft of a report, or to a viewer its published version.
 func (s *service) Get(ctx context.Context, id, tenantID string) (*Report, error) {
-	report, err := s.repo.Get(ctx, id, tenantID)
-	if err != nil {
-		return nil, ErrNotFound
-	}
-	return report, nil
+	return s.load(ctx, id, tenantID)
 }
 
+// List returns the reports of a tenant; a viewer sees the published ones.
 func (s *service) List(ctx context.Context, tenantID string) ([]*Report, error) {
-	return s.repo.List(ctx, tenantID)
+	reports, err := s.repo.List(ctx, tenantID)
+	if err != nil || !version.Published(ctx) {
+		return reports, err
+	}
+	published := make([]*Report, 0, len(reports))
+	for _, report := range reports {
+		if report.Status == version.StatusPublished {
+			published = append(published, report)
+		}
+	}
+	return published, nil
 }
 
 func (s *service) Preview(ctx context.Context, req *PreviewRequest) (*PreviewResponse, error) {
-	report, err := s.repo.Get(ctx, req.ID, req.TenantID)
+	report, err := s.load(ctx, req.ID, req.TenantID)
 	if err != nil {
-		return nil, ErrNotFound
+		return nil, err
 	}
 
-	html, err := s.render.Render(ctx, report.Config, req.Params, req.TenantID)
+	page := req.Page
+	if page == 0 {
+		if value, ok := req.Params["page"].(float64); ok {
+			page = int(value)
+		}
+	}
+	rendered, err := s.render.RenderPage(ctx, report.Config, req.Params, req.TenantID, page)
 	if err != nil {
 		return nil, err
 	}
 
-	return &PreviewResponse{HTML: html}, nil
+	return &PreviewResponse{HTML: rendered.HTML, Page: rendered.Page, TotalPages: rendered.TotalPages}, nil
+}
+
+// Parameters returns the parameters the report declares, for the form shown
+// before preview, export or scheduling.
+func (s *service) Parameters(ctx context.Context, id, tenantID string) ([]render.Parameter, error) {
+	report, err := s.load(ctx, id, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	return s.render.Parameters(ctx, report.Config, tenantID)
+}
+
+// invalidate drops the cached report data of a tenant after a change.
+func (s *service) invalidate(ctx context.Context, tenantID string) {
+	if s.cache != nil {
+		_ = s.cache.Invalidate(ctx, tenantID, "report:data")
+	}
 }
 
 func defaultReportType(value string) string {
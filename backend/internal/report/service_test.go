@@ -1,13 +1,16 @@
 package report
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"testing"
 
 	"github.com/gujiaweiguo/goreport/internal/render"
+	"github.com/gujiaweiguo/goreport/internal/version"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
 )
 
 type mockReportRepository struct {
@@ -24,6 +27,10 @@ func (m *mockReportRepository) Update(ctx context.Context, report *Report) error
 	return args.Error(0)
 }
 
+func (m *mockReportRepository) Transaction(ctx context.Context, fn func(repo Repository, tx *gorm.DB) error) error {
+	return fn(m, nil)
+}
+
 func (m *mockReportRepository) Delete(ctx context.Context, id, tenantID string) error {
 	args := m.Called(ctx, id, tenantID)
 	return args.Error(0)
@@ -47,7 +54,7 @@ func (m *mockReportRepository) List(ctx context.Context, tenantID string) ([]*Re
 
 func TestReportService_Create_Success(t *testing.T) {
 	mockRepo := &mockReportRepository{}
-	svc := NewService(mockRepo, nil, nil)
+	svc := NewService(mockRepo, nil, nil, nil)
 
 	req := &CreateRequest{
 		TenantID: "tenant-1",
@@ -65,13 +72,13 @@ func TestReportService_Create_Success(t *testing.T) {
 	assert.NotNil(t, report)
 	assert.Equal(t, "Test Report", report.Name)
 	assert.Equal(t, "tenant-1", report.TenantID)
-	assert.Equal(t, 1, report.Status)
+	assert.Equal(t, version.StatusDraft, report.Status, "new reports start as drafts")
 	mockRepo.AssertExpectations(t)
 }
 
 func TestReportService_Create_RepoError(t *testing.T) {
 	mockRepo := &mockReportRepository{}
-	svc := NewService(mockRepo, nil, nil)
+	svc := NewService(mockRepo, nil, nil, nil)
 
 	req := &CreateRequest{
 		TenantID: "tenant-1",
@@ -90,7 +97,7 @@ func TestReportService_Create_RepoError(t *testing.T) {
 
 func TestReportService_Update_Success(t *testing.T) {
 	mockRepo := &mockReportRepository{}
-	svc := NewService(mockRepo, nil, nil)
+	svc := NewService(mockRepo, nil, nil, nil)
 
 	req := &UpdateRequest{
 		TenantID: "tenant-1",
@@ -124,7 +131,7 @@ func TestReportService_Update_Success(t *testing.T) {
 
 func TestReportService_Update_NotFound(t *testing.T) {
 	mockRepo := &mockReportRepository{}
-	svc := NewService(mockRepo, nil, nil)
+	svc := NewService(mockRepo, nil, nil, nil)
 
 	req := &UpdateRequest{
 		TenantID: "tenant-1",
@@ -144,7 +151,7 @@ func TestReportService_Update_NotFound(t *testing.T) {
 
 func TestReportService_Delete_Success(t *testing.T) {
 	mockRepo := &mockReportRepository{}
-	svc := NewService(mockRepo, nil, nil)
+	svc := NewService(mockRepo, nil, nil, nil)
 
 	mockRepo.On("Delete", mock.Anything, "r-1", "tenant-1").Return(nil)
 
@@ -156,7 +163,7 @@ func TestReportService_Delete_Success(t *testing.T) {
 
 func TestReportService_Delete_Error(t *testing.T) {
 	mockRepo := &mockReportRepository{}
-	svc := NewService(mockRepo, nil, nil)
+	svc := NewService(mockRepo, nil, nil, nil)
 
 	mockRepo.On("Delete", mock.Anything, "r-1", "tenant-1").Return(errors.New("db error"))
 
@@ -168,7 +175,7 @@ func TestReportService_Delete_Error(t *testing.T) {
 
 func TestReportService_Get_Success(t *testing.T) {
 	mockRepo := &mockReportRepository{}
-	svc := NewService(mockRepo, nil, nil)
+	svc := NewService(mockRepo, nil, nil, nil)
 
 	expectedReport := &Report{
 		ID:       "r-1",
@@ -188,7 +195,7 @@ func TestReportService_Get_Success(t *testing.T) {
 
 func TestReportService_Get_NotFound(t *testing.T) {
 	mockRepo := &mockReportRepository{}
-	svc := NewService(mockRepo, nil, nil)
+	svc := NewService(mockRepo, nil, nil, nil)
 
 	mockRepo.On("Get", mock.Anything, "not-exist", "tenant-1").Return(nil, errors.New("not found"))
 
@@ -202,7 +209,7 @@ func TestReportService_Get_NotFound(t *testing.T) {
 
 func TestReportService_List_Success(t *testing.T) {
 	mockRepo := &mockReportRepository{}
-	svc := NewService(mockRepo, nil, nil)
+	svc := NewService(mockRepo, nil, nil, nil)
 
 	expectedReports := []*Report{
 		{ID: "r-1", TenantID: "tenant-1", Name: "Report 1"},
@@ -220,7 +227,7 @@ func TestReportService_List_Success(t *testing.T) {
 
 func TestReportService_List_Error(t *testing.T) {
 	mockRepo := &mockReportRepository{}
-	svc := NewService(mockRepo, nil, nil)
+	svc := NewService(mockRepo, nil, nil, nil)
 
 	mockRepo.On("List", mock.Anything, "tenant-1").Return(nil, errors.New("db error"))
 
@@ -239,8 +246,8 @@ func TestDefaultReportType(t *testing.T) {
 
 func TestReportService_Preview_Success(t *testing.T) {
 	mockRepo := &mockReportRepository{}
-	renderEngine := render.NewEngine(nil, nil)
-	svc := NewService(mockRepo, renderEngine, nil)
+	renderEngine := render.NewEngine(nil, nil, nil)
+	svc := NewService(mockRepo, renderEngine, nil, nil)
 
 	existingReport := &Report{
 		ID:       "r-1",
@@ -262,12 +269,14 @@ func TestReportService_Preview_Success(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotNil(t, resp)
 	assert.Contains(t, resp.HTML, "Hello")
+	assert.Equal(t, 1, resp.Page)
+	assert.Equal(t, 1, resp.TotalPages)
 	mockRepo.AssertExpectations(t)
 }
 
 func TestReportService_Preview_ReportNotFound(t *testing.T) {
 	mockRepo := &mockReportRepository{}
-	svc := NewService(mockRepo, nil, nil)
+	svc := NewService(mockRepo, nil, nil, nil)
 
 	req := &PreviewRequest{
 		ID:       "not-exist",
@@ -287,8 +296,8 @@ func TestReportService_Preview_ReportNotFound(t *testing.T) {
 
 func TestReportService_Preview_InvalidConfig(t *testing.T) {
 	mockRepo := &mockReportRepository{}
-	renderEngine := render.NewEngine(nil, nil)
-	svc := NewService(mockRepo, renderEngine, nil)
+	renderEngine := render.NewEngine(nil, nil, nil)
+	svc := NewService(mockRepo, renderEngine, nil, nil)
 
 	existingReport := &Report{
 		ID:       "r-1",
@@ -311,3 +320,49 @@ func TestReportService_Preview_InvalidConfig(t *testing.T) {
 	assert.Nil(t, resp)
 	mockRepo.AssertExpectations(t)
 }
+
+func TestReportService_Export(t *testing.T) {
+	mockRepo := &mockReportRepository{}
+	svc := NewService(mockRepo, render.NewEngine(nil, nil, nil), nil, nil)
+
+	mockRepo.On("Get", mock.Anything, "r-1", "tenant-1").Return(&Report{
+		ID:       "r-1",
+		TenantID: "tenant-1",
+		Config:   `{"cells":[{"row":0,"col":0,"text":"Region"},{"row":1,"col":0,"text":"east"}],"print":{"pageSize":"a4"}}`,
+	}, nil)
+	mockRepo.On("Get", mock.Anything, "missing", "tenant-1").Return(nil, errors.New("not found"))
+
+	var out bytes.Buffer
+	err := svc.Export(context.Background(), &ExportRequest{ID: "r-1", TenantID: "tenant-1", Format: ExportFormatPDF, Orientation: "landscape"}, &out)
+	assert.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(out.Bytes(), []byte("%PDF-")))
+
+	out.Reset()
+	err = svc.Export(context.Background(), &ExportRequest{ID: "r-1", TenantID: "tenant-1", Format: ExportFormatXLSX}, &out)
+	assert.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(out.Bytes(), []byte("PK")), "xlsx is a zip archive")
+
+	out.Reset()
+	err = svc.Export(context.Background(), &ExportRequest{ID: "r-1", TenantID: "tenant-1", Format: ExportFormatDOCX}, &out)
+	assert.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(out.Bytes(), []byte("PK")), "docx is a zip archive")
+
+	out.Reset()
+	err = svc.Export(context.Background(), &ExportRequest{ID: "r-1", TenantID: "tenant-1", Format: ExportFormatPNG, DPI: 72}, &out)
+	assert.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(out.Bytes(), []byte("\x89PNG")))
+
+	err = svc.Export(context.Background(), &ExportRequest{ID: "r-1", TenantID: "tenant-1", Format: ExportFormatPNG, Page: 2}, &out)
+	assert.ErrorIs(t, err, render.ErrInvalidPrintConfig, "the report has a single page")
+
+	out.Reset()
+	err = svc.Export(context.Background(), &ExportRequest{ID: "r-1", TenantID: "tenant-1", Format: ExportFormatPDF, PageSize: "B9"}, &out)
+	assert.ErrorIs(t, err, render.ErrInvalidPrintConfig)
+	assert.Zero(t, out.Len())
+
+	err = svc.Export(context.Background(), &ExportRequest{ID: "r-1", TenantID: "tenant-1", Format: "bmp"}, &out)
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+
+	err = svc.Export(context.Background(), &ExportRequest{ID: "missing", TenantID: "tenant-1", Format: ExportFormatPDF}, &out)
+	assert.Equal(t, ErrNotFound, err)
+}
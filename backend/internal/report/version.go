@@ -0,0 +1,182 @@
+package report
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/gujiaweiguo/goreport/internal/version"
+	"gorm.io/gorm"
+)
+
+// Snapshot is what a report version records.
+type Snapshot struct {
+	Name   string          `json:"name"`
+	Type   string          `json:"type"`
+	Config json.RawMessage `json:"config"`
+}
+
+func snapshotOf(report *Report) *Snapshot {
+	config := json.RawMessage(report.Config)
+	if len(config) == 0 {
+		config = json.RawMessage("null")
+	}
+	return &Snapshot{Name: report.Name, Type: report.Type, Config: config}
+}
+
+func (s *Snapshot) apply(report *Report) {
+	report.Name = s.Name
+	report.Type = s.Type
+	report.Config = string(s.Config)
+}
+
+// load reads a report as the caller may see it: the draft, or for a viewer
+// the published version.
+func (s *service) load(ctx context.Context, id, tenantID string) (*Report, error) {
+	report, err := s.repo.Get(ctx, id, tenantID)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	if !version.Published(ctx) {
+		return report, nil
+	}
+	if report.Status != version.StatusPublished {
+		return nil, ErrNotFound
+	}
+	if report.PublishedVersion == 0 {
+		return report, nil
+	}
+	var snapshot Snapshot
+	if _, err := s.history.Load(ctx, tenantID, id, report.PublishedVersion, &snapshot); err != nil {
+		return nil, err
+	}
+	snapshot.apply(report)
+	return report, nil
+}
+
+// inTx runs fn with the repository and history bound to one transaction,
+// so a report and the versions it records are saved together.
+func (s *service) inTx(ctx context.Context, fn func(repo Repository, history *version.History) error) error {
+	return s.repo.Transaction(ctx, func(repo Repository, tx *gorm.DB) error {
+		return fn(repo, s.history.WithTx(tx))
+	})
+}
+
+// pinPublished keeps what viewers of a report published before versioning
+// see when its draft first changes.
+func (s *service) pinPublished(ctx context.Context, history *version.History, report *Report, userID string) error {
+	if report.Status != version.StatusPublished || report.PublishedVersion != 0 {
+		return nil
+	}
+	recorded, err := history.Record(ctx, report.TenantID, report.ID, userID, "published", snapshotOf(report))
+	if err != nil || recorded == nil {
+		return err
+	}
+	report.PublishedVersion = recorded.Number
+	return nil
+}
+
+func (s *service) Versions(ctx context.Context, id, tenantID string) ([]*version.Version, error) {
+	if _, err := s.repo.Get(ctx, id, tenantID); err != nil {
+		return nil, ErrNotFound
+	}
+	return s.history.List(ctx, tenantID, id)
+}
+
+func (s *service) Version(ctx context.Context, id, tenantID string, number int) (*version.Version, error) {
+	if _, err := s.repo.Get(ctx, id, tenantID); err != nil {
+		return nil, ErrNotFound
+	}
+	return s.history.Get(ctx, tenantID, id, number)
+}
+
+// Diff compares two versions of a report; version 0 is the current draft.
+func (s *service) Diff(ctx context.Context, id, tenantID string, from, to int) (*version.Diff, error) {
+	report, err := s.repo.Get(ctx, id, tenantID)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	snapshotAt := func(number int) ([]byte, error) {
+		if number == 0 {
+			return json.Marshal(snapshotOf(report))
+		}
+		recorded, err := s.history.Get(ctx, tenantID, id, number)
+		if err != nil {
+			return nil, err
+		}
+		return recorded.Snapshot, nil
+	}
+	before, err := snapshotAt(from)
+	if err != nil {
+		return nil, err
+	}
+	after, err := snapshotAt(to)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := version.DiffJSON(before, after)
+	if err != nil {
+		return nil, err
+	}
+	return &version.Diff{From: from, To: to, Changes: changes}, nil
+}
+
+// Publish pins a version for viewers; version 0 publishes the latest save.
+func (s *service) Publish(ctx context.Context, id, tenantID string, number int, userID string) (*Report, error) {
+	report, err := s.repo.Get(ctx, id, tenantID)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	if err := s.inTx(ctx, func(repo Repository, history *version.History) error {
+		published, err := history.Get(ctx, tenantID, id, number)
+		if errors.Is(err, version.ErrNotFound) && number == 0 {
+			// Saved before versioning: publish the draft as its first version.
+			published, err = history.Record(ctx, tenantID, id, userID, "published", snapshotOf(report))
+			if err == nil && published == nil {
+				err = version.ErrNotFound
+			}
+		}
+		if err != nil {
+			return err
+		}
+		report.PublishedVersion = published.Number
+		report.Status = version.StatusPublished
+		return repo.Update(ctx, report)
+	}); err != nil {
+		return nil, err
+	}
+	s.invalidate(ctx, tenantID)
+	return report, nil
+}
+
+// Rollback restores a version into the draft and records it as a new
+// version; viewers keep the published one until it is published again.
+func (s *service) Rollback(ctx context.Context, id, tenantID string, number int, userID string) (*Report, error) {
+	report, err := s.repo.Get(ctx, id, tenantID)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	if number <= 0 {
+		return nil, version.ErrNotFound
+	}
+	var snapshot Snapshot
+	if _, err := s.history.Load(ctx, tenantID, id, number, &snapshot); err != nil {
+		return nil, err
+	}
+	if err := s.inTx(ctx, func(repo Repository, history *version.History) error {
+		if err := s.pinPublished(ctx, history, report, userID); err != nil {
+			return err
+		}
+		snapshot.apply(report)
+		if err := repo.Update(ctx, report); err != nil {
+			return err
+		}
+		_, err := history.Record(ctx, tenantID, id, userID, fmt.Sprintf("rollback to version %d", number), snapshotOf(report))
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	s.invalidate(ctx, tenantID)
+	return report, nil
+}
@@ -0,0 +1,112 @@
+package report
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/gujiaweiguo/goreport/internal/testutil"
+	"github.com/gujiaweiguo/goreport/internal/version"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// storedReportRepository keeps one report and hands out copies of it, as the
+// database does.
+type storedReportRepository struct {
+	Repository
+	report *Report
+}
+
+func (r *storedReportRepository) Get(ctx context.Context, id, tenantID string) (*Report, error) {
+	if r.report == nil || r.report.ID != id {
+		return nil, ErrNotFound
+	}
+	copied := *r.report
+	return &copied, nil
+}
+
+func (r *storedReportRepository) Update(ctx context.Context, report *Report) error {
+	copied := *report
+	r.report = &copied
+	return nil
+}
+
+// Transaction puts the stored report back when fn fails.
+func (r *storedReportRepository) Transaction(ctx context.Context, fn func(repo Repository, tx *gorm.DB) error) error {
+	saved := r.report
+	if err := fn(r, nil); err != nil {
+		r.report = saved
+		return err
+	}
+	return nil
+}
+
+func TestReportService_Versions(t *testing.T) {
+	repo := &storedReportRepository{report: &Report{ID: "r-1", TenantID: "tenant-1", Name: "Sales", Type: "report", Config: `{"cells":[{"text":"A"}]}`, Status: version.StatusPublished}}
+	svc := NewService(repo, nil, nil, testutil.NewVersionRepository())
+	ctx := context.Background()
+
+	_, err := svc.Update(ctx, &UpdateRequest{TenantID: "tenant-1", UpdatedBy: "user-1", ID: "r-1", Config: []byte(`{"cells":[{"text":"B"}]}`)})
+	require.NoError(t, err)
+	assert.Equal(t, 1, repo.report.PublishedVersion, "the state published before versioning is pinned")
+
+	viewed, err := svc.Get(version.WithPublished(ctx), "r-1", "tenant-1")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"cells":[{"text":"A"}]}`, viewed.Config)
+	draft, err := svc.Get(ctx, "r-1", "tenant-1")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"cells":[{"text":"B"}]}`, draft.Config)
+
+	diff, err := svc.Diff(ctx, "r-1", "tenant-1", 1, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []version.Change{{Path: "config.cells[0].text", Kind: version.ChangeModified, From: "A", To: "B"}}, diff.Changes)
+
+	published, err := svc.Publish(ctx, "r-1", "tenant-1", 0, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, 2, published.PublishedVersion)
+
+	restored, err := svc.Rollback(ctx, "r-1", "tenant-1", 1, "user-1")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"cells":[{"text":"A"}]}`, restored.Config)
+	history, err := svc.Versions(ctx, "r-1", "tenant-1")
+	require.NoError(t, err)
+	assert.Len(t, history, 3)
+	assert.Equal(t, 2, repo.report.PublishedVersion, "rolling back leaves the published version")
+
+	_, err = svc.Version(ctx, "r-1", "tenant-1", 9)
+	assert.ErrorIs(t, err, version.ErrNotFound)
+}
+
+func TestReportService_VersionsSavedWithReport(t *testing.T) {
+	repo := &storedReportRepository{report: &Report{ID: "r-1", TenantID: "tenant-1", Name: "Sales", Type: "report", Config: `{"cells":[{"text":"A"}]}`, Status: version.StatusPublished}}
+	versions := testutil.NewVersionRepository()
+	versions.CreateErr = errors.New("duplicate version")
+	svc := NewService(repo, nil, nil, versions)
+	ctx := context.Background()
+
+	_, err := svc.Update(ctx, &UpdateRequest{TenantID: "tenant-1", ID: "r-1", Config: []byte(`{"cells":[{"text":"B"}]}`)})
+	assert.Error(t, err)
+	assert.JSONEq(t, `{"cells":[{"text":"A"}]}`, repo.report.Config, "a failed version insert rolls the report back")
+	assert.Zero(t, repo.report.PublishedVersion)
+
+	_, err = svc.Publish(ctx, "r-1", "tenant-1", 0, "user-1")
+	assert.Error(t, err)
+	assert.Zero(t, repo.report.PublishedVersion)
+}
+
+func TestReportService_Get_UnpublishedHiddenFromViewers(t *testing.T) {
+	mockRepo := &mockReportRepository{}
+	svc := NewService(mockRepo, nil, nil, nil)
+	mockRepo.On("Get", mock.Anything, "r-1", "tenant-1").Return(&Report{ID: "r-1", Status: version.StatusDraft}, nil)
+	mockRepo.On("List", mock.Anything, "tenant-1").Return([]*Report{{ID: "r-1", Status: version.StatusDraft}, {ID: "r-2", Status: version.StatusPublished}}, nil)
+
+	_, err := svc.Get(version.WithPublished(context.Background()), "r-1", "tenant-1")
+	assert.ErrorIs(t, err, ErrNotFound)
+	reports, err := svc.List(version.WithPublished(context.Background()), "tenant-1")
+	require.NoError(t, err)
+	require.Len(t, reports, 1)
+	assert.Equal(t, "r-2", reports[0].ID)
+}
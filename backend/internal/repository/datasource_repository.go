@@ -1,10 +1,14 @@
 package repository
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/gujiaweiguo/goreport/internal/models"
+	"github.com/gujiaweiguo/goreport/internal/secrets"
 	"gorm.io/gorm"
 )
 
@@ -20,18 +24,29 @@ type DatasourceRepository interface {
 	Rename(ctx context.Context, id, tenantID string, newName string) error
 }
 
+// datasourceRepository stores secrets sealed by keyring and hands them back
+// decrypted; callers only ever see plaintext.
 type datasourceRepository struct {
-	db *gorm.DB
+	db      *gorm.DB
+	keyring *secrets.Keyring
 }
 
 func NewDatasourceRepository(db *gorm.DB) DatasourceRepository {
-	return &datasourceRepository{db: db}
+	return NewDatasourceRepositoryWithKeyring(db, secrets.Default())
+}
+
+func NewDatasourceRepositoryWithKeyring(db *gorm.DB, keyring *secrets.Keyring) DatasourceRepository {
+	return &datasourceRepository{db: db, keyring: keyring}
 }
 
 func (r *datasourceRepository) Create(ctx context.Context, ds *models.DataSource) error {
 	ds.CreatedAt = time.Now()
 	ds.UpdatedAt = time.Now()
-	return r.db.WithContext(ctx).Create(ds).Error
+	row, err := r.seal(ds)
+	if err != nil {
+		return err
+	}
+	return r.db.WithContext(ctx).Create(row).Error
 }
 
 func (r *datasourceRepository) GetByID(ctx context.Context, id string) (*models.DataSource, error) {
@@ -40,6 +55,9 @@ func (r *datasourceRepository) GetByID(ctx context.Context, id string) (*models.
 	if err != nil {
 		return nil, err
 	}
+	if err := r.open(&ds); err != nil {
+		return nil, err
+	}
 	return &ds, nil
 }
 
@@ -62,13 +80,22 @@ func (r *datasourceRepository) List(ctx context.Context, tenantID string, page,
 	if err != nil {
 		return nil, 0, err
 	}
+	for _, ds := range datasources {
+		if err := r.open(ds); err != nil {
+			return nil, 0, err
+		}
+	}
 
 	return datasources, total, nil
 }
 
 func (r *datasourceRepository) Update(ctx context.Context, ds *models.DataSource) error {
 	ds.UpdatedAt = time.Now()
-	return r.db.WithContext(ctx).Model(ds).Updates(ds).Error
+	row, err := r.seal(ds)
+	if err != nil {
+		return err
+	}
+	return r.db.WithContext(ctx).Model(row).Updates(row).Error
 }
 
 func (r *datasourceRepository) Delete(ctx context.Context, id, tenantID string) error {
@@ -98,6 +125,11 @@ func (r *datasourceRepository) Search(ctx context.Context, tenantID, keyword str
 	if err != nil {
 		return nil, 0, err
 	}
+	for _, ds := range datasources {
+		if err := r.open(ds); err != nil {
+			return nil, 0, err
+		}
+	}
 
 	return datasources, total, nil
 }
@@ -151,7 +183,11 @@ func (r *datasourceRepository) Move(ctx context.Context, id, tenantID string) er
 	}
 
 	ds.UpdatedAt = time.Now()
-	return r.db.WithContext(ctx).Where("id = ?", ds.ID).Updates(ds).Error
+	row, err := r.seal(ds)
+	if err != nil {
+		return err
+	}
+	return r.db.WithContext(ctx).Where("id = ?", ds.ID).Updates(row).Error
 }
 
 func (r *datasourceRepository) Rename(ctx context.Context, id, tenantID string, newName string) error {
@@ -181,5 +217,142 @@ func (r *datasourceRepository) Rename(ctx context.Context, id, tenantID string,
 
 	ds.Name = newName
 	ds.UpdatedAt = time.Now()
-	return r.db.WithContext(ctx).Model(ds).Where("id = ? AND tenant_id = ?", id, tenantID).Updates(ds).Error
+	row, err := r.seal(ds)
+	if err != nil {
+		return err
+	}
+	return r.db.WithContext(ctx).Model(row).Where("id = ? AND tenant_id = ?", id, tenantID).Updates(row).Error
+}
+
+// seal returns a copy of ds with its secrets encrypted, leaving ds itself in
+// plaintext for the caller.
+func (r *datasourceRepository) seal(ds *models.DataSource) (*models.DataSource, error) {
+	row := *ds
+	for _, field := range secretFields(&row) {
+		sealed, err := r.keyring.Seal(*field)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt datasource secrets: %w", err)
+		}
+		*field = sealed
+	}
+	config, err := mapConnectorSecrets(row.Config, r.keyring.Seal)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt datasource secrets: %w", err)
+	}
+	row.Config = config
+	return &row, nil
+}
+
+func (r *datasourceRepository) open(ds *models.DataSource) error {
+	for _, field := range secretFields(ds) {
+		opened, err := r.keyring.Open(*field)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt secrets of datasource %s: %w", ds.ID, err)
+		}
+		*field = opened
+	}
+	config, err := mapConnectorSecrets(ds.Config, r.keyring.Open)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt secrets of datasource %s: %w", ds.ID, err)
+	}
+	ds.Config = config
+	return nil
+}
+
+// secretFields lists the DataSource fields encrypted at rest.
+func secretFields(ds *models.DataSource) []*string {
+	return []*string{&ds.Password, &ds.SSHPassword, &ds.SSHKey, &ds.SSHKeyPhrase}
+}
+
+// ConnectorSecretKeys are the connector config entries, such as an API
+// datasource's credentials, encrypted at rest and redacted in responses.
+var ConnectorSecretKeys = []string{"password", "token"}
+
+// mapConnectorSecrets applies fn to the non-empty string entries of a
+// connector config JSON object named by ConnectorSecretKeys. A config that
+// is not a JSON object, or has no such entries, is returned as is.
+func mapConnectorSecrets(config string, fn func(string) (string, error)) (string, error) {
+	if config == "" {
+		return config, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader([]byte(config)))
+	decoder.UseNumber()
+	var values map[string]interface{}
+	if err := decoder.Decode(&values); err != nil {
+		return config, nil
+	}
+	changed := false
+	for _, key := range ConnectorSecretKeys {
+		value, ok := values[key].(string)
+		if !ok || value == "" {
+			continue
+		}
+		mapped, err := fn(value)
+		if err != nil {
+			return "", fmt.Errorf("config %s: %w", key, err)
+		}
+		values[key] = mapped
+		changed = true
+	}
+	if !changed {
+		return config, nil
+	}
+	encoded, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+// RewrapDatasourceSecrets brings the secrets of every datasource, deleted
+// ones included, under the keyring's current master key: legacy plaintext
+// is encrypted and values sealed under a previous key are re-wrapped. It
+// returns how many datasources were rewritten.
+func RewrapDatasourceSecrets(ctx context.Context, db *gorm.DB, keyring *secrets.Keyring) (int, error) {
+	if !keyring.Enabled() {
+		return 0, secrets.ErrNoMasterKey
+	}
+
+	var rows []*models.DataSource
+	updated := 0
+	result := db.WithContext(ctx).Unscoped().
+		Select("id", "password", "ssh_password", "ssh_key", "ssh_key_phrase", "config").
+		FindInBatches(&rows, 100, func(tx *gorm.DB, batch int) error {
+			for _, ds := range rows {
+				changed := false
+				for _, field := range secretFields(ds) {
+					rewrapped, fieldChanged, err := keyring.Rewrap(*field)
+					if err != nil {
+						return fmt.Errorf("datasource %s: %w", ds.ID, err)
+					}
+					*field = rewrapped
+					changed = changed || fieldChanged
+				}
+				config, err := mapConnectorSecrets(ds.Config, func(value string) (string, error) {
+					rewrapped, valueChanged, err := keyring.Rewrap(value)
+					changed = changed || valueChanged
+					return rewrapped, err
+				})
+				if err != nil {
+					return fmt.Errorf("datasource %s: %w", ds.ID, err)
+				}
+				ds.Config = config
+				if !changed {
+					continue
+				}
+				err = db.WithContext(ctx).Unscoped().Model(&models.DataSource{}).Where("id = ?", ds.ID).UpdateColumns(map[string]interface{}{
+					"password":       ds.Password,
+					"ssh_password":   ds.SSHPassword,
+					"ssh_key":        ds.SSHKey,
+					"ssh_key_phrase": ds.SSHKeyPhrase,
+					"config":         ds.Config,
+				}).Error
+				if err != nil {
+					return err
+				}
+				updated++
+			}
+			return nil
+		})
+	return updated, result.Error
 }
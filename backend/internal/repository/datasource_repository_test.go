@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/gujiaweiguo/goreport/internal/models"
+	"github.com/gujiaweiguo/goreport/internal/secrets"
 	"github.com/gujiaweiguo/goreport/internal/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -100,6 +101,83 @@ func TestDataSourceRepository_Update(t *testing.T) {
 	assert.Equal(t, 3307, updated.Port)
 }
 
+func TestDataSourceRepository_EncryptsSecrets(t *testing.T) {
+	db, _ := setupDataSourceRepo(t)
+	ctx := context.Background()
+	tenantID := setupTenant(t, db)
+
+	oldKey, err := secrets.GenerateKey()
+	require.NoError(t, err)
+	keyring, err := secrets.NewKeyring(oldKey)
+	require.NoError(t, err)
+	repo := NewDatasourceRepositoryWithKeyring(db, keyring)
+
+	ds := newTestDataSource(testID("ds"), tenantID, "Sealed")
+	ds.SSHKey = "key-material"
+	ds.Config = `{"token":"api-token","url":"https://api.example.com"}`
+	require.NoError(t, repo.Create(ctx, ds))
+	assert.Equal(t, "root", ds.Password, "the caller's copy stays in plaintext")
+
+	var raw models.DataSource
+	require.NoError(t, db.Where("id = ?", ds.ID).First(&raw).Error)
+	assert.True(t, secrets.IsSealed(raw.Password))
+	assert.True(t, secrets.IsSealed(raw.SSHKey))
+	assert.Empty(t, raw.SSHPassword)
+	assert.NotContains(t, raw.Config, "api-token")
+
+	fetched, err := repo.GetByID(ctx, ds.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "root", fetched.Password)
+	assert.Equal(t, "key-material", fetched.SSHKey)
+	assert.JSONEq(t, ds.Config, fetched.Config)
+
+	// Legacy plaintext rows are encrypted and old keys rotated out.
+	require.NoError(t, db.Model(&models.DataSource{}).Where("id = ?", ds.ID).UpdateColumn("ssh_password", "legacy").Error)
+	newKey, err := secrets.GenerateKey()
+	require.NoError(t, err)
+	rotated, err := secrets.NewKeyring(newKey, oldKey)
+	require.NoError(t, err)
+	_, err = RewrapDatasourceSecrets(ctx, db.Where("tenant_id = ?", tenantID), rotated)
+	require.NoError(t, err)
+
+	current, err := secrets.NewKeyring(newKey)
+	require.NoError(t, err)
+	fetched, err = NewDatasourceRepositoryWithKeyring(db, current).GetByID(ctx, ds.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "root", fetched.Password)
+	assert.Equal(t, "legacy", fetched.SSHPassword)
+	assert.Equal(t, "key-material", fetched.SSHKey)
+	assert.JSONEq(t, ds.Config, fetched.Config)
+}
+
+func TestMapConnectorSecrets(t *testing.T) {
+	key, err := secrets.GenerateKey()
+	require.NoError(t, err)
+	keyring, err := secrets.NewKeyring(key)
+	require.NoError(t, err)
+
+	config := `{"url":"https://api.example.com","token":"api-token","password":"pw","username":"svc","timeout":12345678901234}`
+	sealed, err := mapConnectorSecrets(config, keyring.Seal)
+	require.NoError(t, err)
+	assert.NotContains(t, sealed, "api-token")
+	assert.NotContains(t, sealed, `"pw"`)
+	assert.Contains(t, sealed, `"username":"svc"`)
+	assert.Contains(t, sealed, "12345678901234", "numbers keep their precision")
+
+	opened, err := mapConnectorSecrets(sealed, keyring.Open)
+	require.NoError(t, err)
+	assert.JSONEq(t, config, opened)
+
+	for _, unchanged := range []string{"", `{"url":"https://api.example.com","token":""}`, "not json"} {
+		mapped, err := mapConnectorSecrets(unchanged, keyring.Seal)
+		require.NoError(t, err)
+		assert.Equal(t, unchanged, mapped)
+	}
+
+	_, err = mapConnectorSecrets(`{"token":"enc:v1:forged"}`, keyring.Seal)
+	assert.ErrorIs(t, err, secrets.ErrReserved)
+}
+
 func TestDataSourceRepository_List_ByTenant(t *testing.T) {
 	db, repo := setupDataSourceRepo(t)
 	ctx := context.Background()
@@ -0,0 +1,212 @@
+package schedule
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gujiaweiguo/goreport/internal/config"
+)
+
+var ErrDeliveryNotConfigured = errors.New("delivery channel not configured")
+
+// Deliverer sends the file of a run over one channel.
+type Deliverer interface {
+	Deliver(ctx context.Context, schedule *Schedule, run *Run, artifact *Artifact) error
+}
+
+// EmailDeliverer sends files as attachments through an SMTP server, using
+// STARTTLS when the server offers it.
+type EmailDeliverer struct {
+	cfg config.SMTPConfig
+}
+
+func NewEmailDeliverer(cfg config.SMTPConfig) *EmailDeliverer {
+	return &EmailDeliverer{cfg: cfg}
+}
+
+func (d *EmailDeliverer) Deliver(ctx context.Context, schedule *Schedule, run *Run, artifact *Artifact) error {
+	if d.cfg.Host == "" || d.cfg.From == "" {
+		return fmt.Errorf("%w: SMTP_HOST and SMTP_FROM are required", ErrDeliveryNotConfigured)
+	}
+
+	message, err := buildEmail(d.cfg.From, schedule, artifact, time.Now())
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if d.cfg.Username != "" {
+		auth = smtp.PlainAuth("", d.cfg.Username, d.cfg.Password, d.cfg.Host)
+	}
+	addr := net.JoinHostPort(d.cfg.Host, strconv.Itoa(d.cfg.Port))
+
+	// net/smtp has no context support; run it aside so a cancelled run does
+	// not wait on a stalled server.
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(addr, auth, d.cfg.From, schedule.Delivery.Recipients, message)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func buildEmail(from string, schedule *Schedule, artifact *Artifact, now time.Time) ([]byte, error) {
+	subject := schedule.Delivery.Subject
+	if subject == "" {
+		subject = schedule.Name
+	}
+	body := schedule.Delivery.Body
+	if body == "" {
+		body = fmt.Sprintf("%s, generated at %s.", schedule.Name, now.Format("2006-01-02 15:04:05 MST"))
+	}
+
+	var buf bytes.Buffer
+	parts := multipart.NewWriter(&buf)
+	header := []string{
+		"From: " + from,
+		"To: " + strings.Join(schedule.Delivery.Recipients, ", "),
+		"Subject: " + mime.QEncoding.Encode("utf-8", subject),
+		"Date: " + now.Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		fmt.Sprintf(`Content-Type: multipart/mixed; boundary="%s"`, parts.Boundary()),
+	}
+	buf.WriteString(strings.Join(header, "\r\n") + "\r\n\r\n")
+
+	text, err := parts.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := writeBase64Lines(text, []byte(body)); err != nil {
+		return nil, err
+	}
+
+	attachment, err := parts.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {artifact.ContentType},
+		"Content-Transfer-Encoding": {"base64"},
+		"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": artifact.FileName})},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := writeBase64Lines(attachment, artifact.Data); err != nil {
+		return nil, err
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeBase64Lines writes data base64 encoded in lines of 76 characters, the
+// limit MIME sets.
+func writeBase64Lines(w io.Writer, data []byte) error {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 0 {
+		n := min(76, len(encoded))
+		if _, err := w.Write([]byte(encoded[:n] + "\r\n")); err != nil {
+			return err
+		}
+		encoded = encoded[n:]
+	}
+	return nil
+}
+
+// WebhookDeliverer POSTs the file as the request body.
+type WebhookDeliverer struct {
+	client *http.Client
+}
+
+func NewWebhookDeliverer(client *http.Client) *WebhookDeliverer {
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &WebhookDeliverer{client: client}
+}
+
+func (d *WebhookDeliverer) Deliver(ctx context.Context, schedule *Schedule, run *Run, artifact *Artifact) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, schedule.Delivery.URL, bytes.NewReader(artifact.Data))
+	if err != nil {
+		return err
+	}
+	for name, value := range schedule.Delivery.Headers {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("Content-Type", artifact.ContentType)
+	req.Header.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": artifact.FileName}))
+	req.Header.Set("X-Goreport-Schedule", schedule.ID)
+	req.Header.Set("X-Goreport-Run", run.ID)
+	if schedule.Delivery.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(schedule.Delivery.Secret))
+		mac.Write(artifact.Data)
+		req.Header.Set("X-Goreport-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// validateDelivery checks a delivery's shape; whether it works is only
+// known once a run tries it.
+func validateDelivery(delivery *Delivery) error {
+	switch delivery.Channel {
+	case ChannelEmail:
+		if len(delivery.Recipients) == 0 {
+			return fmt.Errorf("%w: email delivery needs recipients", ErrInvalidSchedule)
+		}
+		for _, recipient := range delivery.Recipients {
+			addr, err := mail.ParseAddress(recipient)
+			if err != nil || addr.Name != "" {
+				return fmt.Errorf("%w: invalid recipient %q", ErrInvalidSchedule, recipient)
+			}
+		}
+		if strings.ContainsAny(delivery.Subject, "\r\n") {
+			return fmt.Errorf("%w: subject must be a single line", ErrInvalidSchedule)
+		}
+	case ChannelWebhook:
+		if !strings.HasPrefix(delivery.URL, "http://") && !strings.HasPrefix(delivery.URL, "https://") {
+			return fmt.Errorf("%w: webhook url must be http or https", ErrInvalidSchedule)
+		}
+		if _, err := http.NewRequest(http.MethodPost, delivery.URL, nil); err != nil {
+			return fmt.Errorf("%w: invalid webhook url", ErrInvalidSchedule)
+		}
+		for name, value := range delivery.Headers {
+			if strings.ContainsAny(name+value, "\r\n") {
+				return fmt.Errorf("%w: invalid webhook header %q", ErrInvalidSchedule, name)
+			}
+		}
+	default:
+		return fmt.Errorf("%w: unknown delivery channel %q", ErrInvalidSchedule, delivery.Channel)
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+package schedule
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gujiaweiguo/goreport/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// smtpStandIn is a local SMTP server speaking just enough of the protocol
+// for net/smtp.SendMail; it records every message it accepts.
+type smtpStandIn struct {
+	listener net.Listener
+	reject   bool
+
+	mu       sync.Mutex
+	from     string
+	rcpts    []string
+	messages [][]byte
+}
+
+func newSMTPStandIn(t *testing.T) *smtpStandIn {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := &smtpStandIn{listener: listener}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *smtpStandIn) config() config.SMTPConfig {
+	host, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	p, _ := strconv.Atoi(port)
+	return config.SMTPConfig{Host: host, Port: p, From: "reports@example.com"}
+}
+
+func (s *smtpStandIn) serve(conn net.Conn) {
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	_ = tp.PrintfLine("220 localhost ESMTP")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		s.mu.Lock()
+		switch {
+		case verb == "EHLO" || verb == "HELO":
+			_ = tp.PrintfLine("250 localhost")
+		case verb == "MAIL" && s.reject:
+			_ = tp.PrintfLine("451 try again later")
+		case verb == "MAIL":
+			s.from = line
+			_ = tp.PrintfLine("250 OK")
+		case verb == "RCPT":
+			s.rcpts = append(s.rcpts, line)
+			_ = tp.PrintfLine("250 OK")
+		case verb == "DATA":
+			_ = tp.PrintfLine("354 go ahead")
+			s.mu.Unlock()
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.messages = append(s.messages, data)
+			_ = tp.PrintfLine("250 OK")
+		case verb == "QUIT":
+			_ = tp.PrintfLine("221 bye")
+			s.mu.Unlock()
+			return
+		default:
+			_ = tp.PrintfLine("250 OK")
+		}
+		s.mu.Unlock()
+	}
+}
+
+func testArtifact() *Artifact {
+	return &Artifact{FileName: "report-1-20260301-080000.pdf", ContentType: "application/pdf", Data: bytes.Repeat([]byte("%PDF-1.4 "), 20)}
+}
+
+func TestEmailDeliverer_SendsAttachment(t *testing.T) {
+	server := newSMTPStandIn(t)
+	schedule := &Schedule{
+		ID:       "schedule-1",
+		Name:     "Daily sales",
+		Delivery: Delivery{Channel: ChannelEmail, Recipients: []string{"a@example.com", "b@example.com"}, Subject: "销售日报"},
+	}
+	artifact := testArtifact()
+
+	err := NewEmailDeliverer(server.config()).Deliver(context.Background(), schedule, &Run{ID: "run-1"}, artifact)
+	require.NoError(t, err)
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	assert.Equal(t, "MAIL FROM:<reports@example.com>", server.from)
+	assert.Equal(t, []string{"RCPT TO:<a@example.com>", "RCPT TO:<b@example.com>"}, server.rcpts)
+	require.Len(t, server.messages, 1)
+
+	msg, err := mail.ReadMessage(bytes.NewReader(server.messages[0]))
+	require.NoError(t, err)
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	require.NoError(t, err)
+	assert.Equal(t, "销售日报", subject)
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	assert.Equal(t, "multipart/mixed", mediaType)
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	_, err = reader.NextPart()
+	require.NoError(t, err)
+	part, err := reader.NextPart()
+	require.NoError(t, err)
+	assert.Equal(t, artifact.FileName, part.FileName())
+	data, err := io.ReadAll(base64.NewDecoder(base64.StdEncoding, bufio.NewReader(part)))
+	require.NoError(t, err)
+	assert.Equal(t, artifact.Data, data)
+}
+
+func TestEmailDeliverer_Errors(t *testing.T) {
+	schedule := &Schedule{Delivery: Delivery{Channel: ChannelEmail, Recipients: []string{"a@example.com"}}}
+
+	err := NewEmailDeliverer(config.SMTPConfig{}).Deliver(context.Background(), schedule, &Run{}, testArtifact())
+	assert.ErrorIs(t, err, ErrDeliveryNotConfigured)
+
+	server := newSMTPStandIn(t)
+	server.reject = true
+	err = NewEmailDeliverer(server.config()).Deliver(context.Background(), schedule, &Run{}, testArtifact())
+	assert.ErrorContains(t, err, "451")
+}
+
+func TestWebhookDeliverer(t *testing.T) {
+	var got *http.Request
+	var body []byte
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	schedule := &Schedule{
+		ID: "schedule-1",
+		Delivery: Delivery{
+			Channel: ChannelWebhook,
+			URL:     server.URL + "/hook",
+			Headers: map[string]string{"Authorization": "Bearer token"},
+			Secret:  "s3cret",
+		},
+	}
+	artifact := testArtifact()
+	deliverer := NewWebhookDeliverer(server.Client())
+
+	require.NoError(t, deliverer.Deliver(context.Background(), schedule, &Run{ID: "run-1"}, artifact))
+	assert.Equal(t, http.MethodPost, got.Method)
+	assert.Equal(t, "/hook", got.URL.Path)
+	assert.Equal(t, artifact.Data, body)
+	assert.Equal(t, "application/pdf", got.Header.Get("Content-Type"))
+	assert.Equal(t, "Bearer token", got.Header.Get("Authorization"))
+	assert.Equal(t, "schedule-1", got.Header.Get("X-Goreport-Schedule"))
+	assert.Equal(t, "run-1", got.Header.Get("X-Goreport-Run"))
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(artifact.Data)
+	assert.Equal(t, "sha256="+hex.EncodeToString(mac.Sum(nil)), got.Header.Get("X-Goreport-Signature"))
+
+	status = http.StatusBadGateway
+	err := deliverer.Deliver(context.Background(), schedule, &Run{ID: "run-2"}, artifact)
+	assert.ErrorContains(t, err, "502")
+}
+
+func TestValidateDelivery(t *testing.T) {
+	valid := []Delivery{
+		{Channel: ChannelEmail, Recipients: []string{"ops@example.com"}},
+		{Channel: ChannelWebhook, URL: "https://example.com/hook"},
+	}
+	for _, delivery := range valid {
+		assert.NoError(t, validateDelivery(&delivery), delivery.Channel)
+	}
+
+	invalid := map[string]Delivery{
+		"channel":    {Channel: "sms"},
+		"recipients": {Channel: ChannelEmail},
+		"recipient":  {Channel: ChannelEmail, Recipients: []string{"not an address"}},
+		"named":      {Channel: ChannelEmail, Recipients: []string{"Ops <ops@example.com>"}},
+		"subject":    {Channel: ChannelEmail, Recipients: []string{"ops@example.com"}, Subject: "a\r\nBcc: x@example.com"},
+		"scheme":     {Channel: ChannelWebhook, URL: "ftp://example.com"},
+		"header":     {Channel: ChannelWebhook, URL: "https://example.com", Headers: map[string]string{"X": "a\nb"}},
+	}
+	for name, delivery := range invalid {
+		assert.ErrorIs(t, validateDelivery(&delivery), ErrInvalidSchedule, name)
+	}
+}
@@ -0,0 +1,159 @@
+package schedule
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gujiaweiguo/goreport/internal/auth"
+)
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+func (h *Handler) List(c *gin.Context) {
+	tenantID := auth.GetTenantID(c)
+	if tenantID == "" {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "message": "tenant not found"})
+		return
+	}
+
+	schedules, err := h.service.List(c.Request.Context(), tenantID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "failed to list schedules"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": NewResponses(schedules), "message": "success"})
+}
+
+func (h *Handler) Create(c *gin.Context) {
+	var req CreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "invalid request"})
+		return
+	}
+
+	req.TenantID = auth.GetTenantID(c)
+	if req.TenantID == "" {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "message": "tenant not found"})
+		return
+	}
+	req.CreatedBy = auth.GetUserID(c)
+
+	schedule, err := h.service.Create(c.Request.Context(), &req)
+	if err != nil {
+		if errors.Is(err, ErrInvalidSchedule) {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "failed to create schedule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": NewResponse(schedule), "message": "schedule created"})
+}
+
+func (h *Handler) Get(c *gin.Context) {
+	tenantID := auth.GetTenantID(c)
+	if tenantID == "" {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "message": "tenant not found"})
+		return
+	}
+
+	schedule, err := h.service.Get(c.Request.Context(), c.Param("id"), tenantID)
+	if err != nil {
+		writeError(c, err, "failed to get schedule")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": NewResponse(schedule), "message": "success"})
+}
+
+func (h *Handler) Update(c *gin.Context) {
+	var req UpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "invalid request"})
+		return
+	}
+
+	req.ID = c.Param("id")
+	req.TenantID = auth.GetTenantID(c)
+	if req.TenantID == "" {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "message": "tenant not found"})
+		return
+	}
+
+	schedule, err := h.service.Update(c.Request.Context(), &req)
+	if err != nil {
+		writeError(c, err, "failed to update schedule")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": NewResponse(schedule), "message": "schedule updated"})
+}
+
+func (h *Handler) Delete(c *gin.Context) {
+	tenantID := auth.GetTenantID(c)
+	if tenantID == "" {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "message": "tenant not found"})
+		return
+	}
+
+	if err := h.service.Delete(c.Request.Context(), c.Param("id"), tenantID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "failed to delete schedule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "schedule deleted"})
+}
+
+// Run starts a run right away and answers with it before it finishes; its
+// outcome shows in the run history.
+func (h *Handler) Run(c *gin.Context) {
+	tenantID := auth.GetTenantID(c)
+	if tenantID == "" {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "message": "tenant not found"})
+		return
+	}
+
+	run, err := h.service.RunNow(c.Request.Context(), c.Param("id"), tenantID)
+	if err != nil {
+		writeError(c, err, "failed to start run")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"success": true, "result": run, "message": "run started"})
+}
+
+func (h *Handler) Runs(c *gin.Context) {
+	tenantID := auth.GetTenantID(c)
+	if tenantID == "" {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "message": "tenant not found"})
+		return
+	}
+
+	runs, err := h.service.Runs(c.Request.Context(), c.Param("id"), tenantID)
+	if err != nil {
+		writeError(c, err, "failed to list runs")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": runs, "message": "success"})
+}
+
+func writeError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "schedule not found"})
+	case errors.Is(err, ErrInvalidSchedule):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": message})
+	}
+}
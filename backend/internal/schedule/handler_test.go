@@ -0,0 +1,213 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockScheduleService struct {
+	mock.Mock
+}
+
+func (m *mockScheduleService) Create(ctx context.Context, req *CreateRequest) (*Schedule, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Schedule), args.Error(1)
+}
+
+func (m *mockScheduleService) Update(ctx context.Context, req *UpdateRequest) (*Schedule, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Schedule), args.Error(1)
+}
+
+func (m *mockScheduleService) Delete(ctx context.Context, id, tenantID string) error {
+	args := m.Called(ctx, id, tenantID)
+	return args.Error(0)
+}
+
+func (m *mockScheduleService) Get(ctx context.Context, id, tenantID string) (*Schedule, error) {
+	args := m.Called(ctx, id, tenantID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Schedule), args.Error(1)
+}
+
+func (m *mockScheduleService) List(ctx context.Context, tenantID string) ([]*Schedule, error) {
+	args := m.Called(ctx, tenantID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*Schedule), args.Error(1)
+}
+
+func (m *mockScheduleService) RunNow(ctx context.Context, id, tenantID string) (*Run, error) {
+	args := m.Called(ctx, id, tenantID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Run), args.Error(1)
+}
+
+func (m *mockScheduleService) Runs(ctx context.Context, id, tenantID string) ([]*Run, error) {
+	args := m.Called(ctx, id, tenantID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*Run), args.Error(1)
+}
+
+func setupScheduleTestRouter(tenantID string) (*gin.Engine, *mockScheduleService) {
+	gin.SetMode(gin.TestMode)
+	mockSvc := &mockScheduleService{}
+	handler := NewHandler(mockSvc)
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		if tenantID != "" {
+			c.Set("tenantId", tenantID)
+			c.Set("userId", "user-1")
+		}
+	})
+	router.GET("/schedules", handler.List)
+	router.POST("/schedules", handler.Create)
+	router.GET("/schedules/:id", handler.Get)
+	router.PUT("/schedules/:id", handler.Update)
+	router.DELETE("/schedules/:id", handler.Delete)
+	router.POST("/schedules/:id/run", handler.Run)
+	router.GET("/schedules/:id/runs", handler.Runs)
+	return router, mockSvc
+}
+
+func serve(router *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestScheduleHandler_Create(t *testing.T) {
+	router, mockSvc := setupScheduleTestRouter("tenant-1")
+	mockSvc.On("Create", mock.Anything, mock.MatchedBy(func(req *CreateRequest) bool {
+		return req.TenantID == "tenant-1" && req.CreatedBy == "user-1" && req.Delivery.Channel == ChannelWebhook
+	})).Return(&Schedule{ID: "schedule-1"}, nil).Once()
+
+	body := `{"name":"Daily","cron":"0 8 * * *","targetType":"report","targetId":"report-1","delivery":{"channel":"webhook","url":"https://example.com"}}`
+	w := serve(router, http.MethodPost, "/schedules", body)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"id":"schedule-1"`)
+
+	mockSvc.On("Create", mock.Anything, mock.Anything).Return(nil, fmt.Errorf("%w: cron: bad", ErrInvalidSchedule)).Once()
+	w = serve(router, http.MethodPost, "/schedules", body)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "cron: bad")
+
+	w = serve(router, http.MethodPost, "/schedules", `{"name":"Daily"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockSvc.AssertExpectations(t)
+}
+
+func TestScheduleHandler_NoTenant(t *testing.T) {
+	router, _ := setupScheduleTestRouter("")
+	for _, route := range [][2]string{
+		{http.MethodGet, "/schedules"},
+		{http.MethodGet, "/schedules/s-1"},
+		{http.MethodDelete, "/schedules/s-1"},
+		{http.MethodPost, "/schedules/s-1/run"},
+		{http.MethodGet, "/schedules/s-1/runs"},
+	} {
+		w := serve(router, route[0], route[1], "")
+		assert.Equal(t, http.StatusForbidden, w.Code, route[1])
+	}
+}
+
+func TestScheduleHandler_Update(t *testing.T) {
+	router, mockSvc := setupScheduleTestRouter("tenant-1")
+	mockSvc.On("Update", mock.Anything, mock.MatchedBy(func(req *UpdateRequest) bool {
+		return req.ID == "schedule-1" && req.TenantID == "tenant-1" && req.Cron == "@daily"
+	})).Return(&Schedule{ID: "schedule-1", Cron: "@daily"}, nil)
+	mockSvc.On("Update", mock.Anything, mock.MatchedBy(func(req *UpdateRequest) bool {
+		return req.ID == "missing"
+	})).Return(nil, ErrNotFound)
+
+	w := serve(router, http.MethodPut, "/schedules/schedule-1", `{"cron":"@daily"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = serve(router, http.MethodPut, "/schedules/missing", `{"cron":"@daily"}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestScheduleHandler_Run(t *testing.T) {
+	router, mockSvc := setupScheduleTestRouter("tenant-1")
+	mockSvc.On("RunNow", mock.Anything, "schedule-1", "tenant-1").Return(&Run{ID: "run-1", Status: RunRunning}, nil)
+	mockSvc.On("RunNow", mock.Anything, "missing", "tenant-1").Return(nil, ErrNotFound)
+	mockSvc.On("RunNow", mock.Anything, "broken", "tenant-1").Return(nil, errors.New("db down"))
+
+	w := serve(router, http.MethodPost, "/schedules/schedule-1/run", "")
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Contains(t, w.Body.String(), `"status":"running"`)
+
+	w = serve(router, http.MethodPost, "/schedules/missing/run", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = serve(router, http.MethodPost, "/schedules/broken/run", "")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestScheduleHandler_RedactsDeliverySecrets(t *testing.T) {
+	router, mockSvc := setupScheduleTestRouter("tenant-1")
+	schedule := &Schedule{ID: "schedule-1", Delivery: Delivery{
+		Channel: ChannelWebhook,
+		URL:     "https://example.com/hook",
+		Headers: map[string]string{"Authorization": "Bearer abc"},
+		Secret:  "hook-secret",
+	}}
+	mockSvc.On("List", mock.Anything, "tenant-1").Return([]*Schedule{schedule}, nil)
+	mockSvc.On("Get", mock.Anything, "schedule-1", "tenant-1").Return(schedule, nil)
+
+	for _, path := range []string{"/schedules", "/schedules/schedule-1"} {
+		w := serve(router, http.MethodGet, path, "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"secret":"__unchanged__"`)
+		assert.Contains(t, w.Body.String(), `"Authorization":"__unchanged__"`)
+		assert.NotContains(t, w.Body.String(), "hook-secret")
+		assert.NotContains(t, w.Body.String(), "Bearer abc")
+	}
+	assert.Equal(t, "hook-secret", schedule.Delivery.Secret, "the schedule itself is not modified")
+	assert.Equal(t, "Bearer abc", schedule.Delivery.Headers["Authorization"])
+}
+
+func TestScheduleHandler_ListGetRunsDelete(t *testing.T) {
+	router, mockSvc := setupScheduleTestRouter("tenant-1")
+	mockSvc.On("List", mock.Anything, "tenant-1").Return([]*Schedule{{ID: "schedule-1"}}, nil)
+	mockSvc.On("Get", mock.Anything, "schedule-1", "tenant-1").Return(&Schedule{ID: "schedule-1"}, nil)
+	mockSvc.On("Get", mock.Anything, "missing", "tenant-1").Return(nil, ErrNotFound)
+	mockSvc.On("Runs", mock.Anything, "schedule-1", "tenant-1").Return([]*Run{{ID: "run-1", Status: RunFailed, Attempts: 4}}, nil)
+	mockSvc.On("Delete", mock.Anything, "schedule-1", "tenant-1").Return(nil)
+
+	assert.Equal(t, http.StatusOK, serve(router, http.MethodGet, "/schedules", "").Code)
+	assert.Equal(t, http.StatusOK, serve(router, http.MethodGet, "/schedules/schedule-1", "").Code)
+	assert.Equal(t, http.StatusNotFound, serve(router, http.MethodGet, "/schedules/missing", "").Code)
+
+	w := serve(router, http.MethodGet, "/schedules/schedule-1/runs", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"attempts":4`)
+
+	assert.Equal(t, http.StatusOK, serve(router, http.MethodDelete, "/schedules/schedule-1", "").Code)
+	mockSvc.AssertExpectations(t)
+}
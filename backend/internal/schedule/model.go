@@ -0,0 +1,123 @@
+package schedule
+
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	TargetReport    = "report"
+	TargetDashboard = "dashboard"
+
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+
+	TriggerCron   = "cron"
+	TriggerManual = "manual"
+
+	RunRunning   = "running"
+	RunSucceeded = "succeeded"
+	RunFailed    = "failed"
+)
+
+// Schedule renders a report or snapshots a dashboard on a cron expression
+// and delivers the file by email or webhook. Params are the report
+// parameters; string values starting with "@" are relative dates resolved
+// in the schedule's timezone at run time, see ResolveParams.
+type Schedule struct {
+	ID         string                 `gorm:"primaryKey;type:varchar(36)" json:"id"`
+	TenantID   string                 `gorm:"index;type:varchar(36)" json:"tenantId"`
+	Name       string                 `gorm:"type:varchar(200)" json:"name"`
+	Cron       string                 `gorm:"type:varchar(100)" json:"cron"`
+	Timezone   string                 `gorm:"type:varchar(64)" json:"timezone"`
+	TargetType string                 `gorm:"type:varchar(20)" json:"targetType"`
+	TargetID   string                 `gorm:"type:varchar(36)" json:"targetId"`
+	Format     string                 `gorm:"type:varchar(20)" json:"format"`
+	Params     map[string]interface{} `gorm:"-" json:"params"`
+	Delivery   Delivery               `gorm:"-" json:"delivery"`
+	MaxRetries int                    `gorm:"type:int" json:"maxRetries"`
+	Enabled    bool                   `json:"enabled"`
+	NextRunAt  *time.Time             `gorm:"index" json:"nextRunAt"`
+	LastRunAt  *time.Time             `json:"lastRunAt"`
+	CreatedBy  string                 `gorm:"type:varchar(36)" json:"createdBy"`
+	CreatedAt  time.Time              `json:"createdAt"`
+	UpdatedAt  time.Time              `json:"updatedAt"`
+	DeletedAt  gorm.DeletedAt         `gorm:"index" json:"-"`
+
+	ParamsJSON   string `gorm:"column:params;type:json" json:"-"`
+	DeliveryJSON string `gorm:"column:delivery;type:json" json:"-"`
+}
+
+func (Schedule) TableName() string {
+	return "report_schedules"
+}
+
+func (s *Schedule) BeforeSave(tx *gorm.DB) error {
+	params, err := json.Marshal(s.Params)
+	if err != nil {
+		return err
+	}
+	delivery, err := json.Marshal(s.Delivery)
+	if err != nil {
+		return err
+	}
+	s.ParamsJSON = string(params)
+	s.DeliveryJSON = string(delivery)
+	return nil
+}
+
+func (s *Schedule) AfterFind(tx *gorm.DB) error {
+	if s.ParamsJSON != "" {
+		if err := json.Unmarshal([]byte(s.ParamsJSON), &s.Params); err != nil {
+			return err
+		}
+	}
+	if s.DeliveryJSON != "" {
+		if err := json.Unmarshal([]byte(s.DeliveryJSON), &s.Delivery); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delivery says where a run's file goes. Email sends it as an attachment to
+// the recipients; webhook POSTs it to URL, signed with an HMAC-SHA256 of the
+// body in X-Goreport-Signature when a secret is set.
+type Delivery struct {
+	Channel    string            `json:"channel"`
+	Recipients []string          `json:"recipients,omitempty"`
+	Subject    string            `json:"subject,omitempty"`
+	Body       string            `json:"body,omitempty"`
+	URL        string            `json:"url,omitempty"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	Secret     string            `json:"secret,omitempty"`
+}
+
+// Run is one execution of a schedule. Attempts counts deliveries tried,
+// including retries.
+type Run struct {
+	ID         string     `gorm:"primaryKey;type:varchar(36)" json:"id"`
+	ScheduleID string     `gorm:"index;type:varchar(36)" json:"scheduleId"`
+	TenantID   string     `gorm:"index;type:varchar(36)" json:"tenantId"`
+	Trigger    string     `gorm:"type:varchar(20)" json:"trigger"`
+	Status     string     `gorm:"type:varchar(20)" json:"status"`
+	Attempts   int        `gorm:"type:int" json:"attempts"`
+	FileName   string     `gorm:"type:varchar(255)" json:"fileName"`
+	FileSize   int64      `json:"fileSize"`
+	Error      string     `gorm:"type:text" json:"error"`
+	StartedAt  time.Time  `json:"startedAt"`
+	FinishedAt *time.Time `json:"finishedAt"`
+}
+
+func (Run) TableName() string {
+	return "schedule_runs"
+}
+
+// Artifact is the rendered file of a run.
+type Artifact struct {
+	FileName    string
+	ContentType string
+	Data        []byte
+}
@@ -0,0 +1,138 @@
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidRelativeDate = errors.New("invalid relative date")
+
+var relativeOffsetPattern = regexp.MustCompile(`([+-])(\d+)([dwmy])`)
+
+// ResolveParams replaces relative dates in schedule parameters with dates as
+// of now. A relative date is "@" followed by an anchor and any number of
+// offsets: "@today-7d", "@startOfMonth-1m", "@endOfYear". The offsets move
+// today by days, weeks, months or years first, then the anchor picks the
+// day of that period; "@endOfMonth-1m" is the last day of last month.
+// Strings inside lists and {start,end} objects are resolved too, so a date
+// range may be given as ["@startOfMonth-1m", "@endOfMonth-1m"].
+func ResolveParams(params map[string]interface{}, now time.Time) (map[string]interface{}, error) {
+	if params == nil {
+		return nil, nil
+	}
+	resolved := make(map[string]interface{}, len(params))
+	for name, value := range params {
+		v, err := resolveValue(value, now)
+		if err != nil {
+			return nil, fmt.Errorf("parameter %q: %w", name, err)
+		}
+		resolved[name] = v
+	}
+	return resolved, nil
+}
+
+func resolveValue(value interface{}, now time.Time) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		if !strings.HasPrefix(v, "@") {
+			return v, nil
+		}
+		return relativeDate(v, now)
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			resolved, err := resolveValue(item, now)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = resolved
+		}
+		return out, nil
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			resolved, err := resolveValue(item, now)
+			if err != nil {
+				return nil, err
+			}
+			out[key] = resolved
+		}
+		return out, nil
+	default:
+		return value, nil
+	}
+}
+
+func relativeDate(expr string, now time.Time) (string, error) {
+	body := expr[1:]
+	cut := strings.IndexAny(body, "+-")
+	anchor, offsets := body, ""
+	if cut >= 0 {
+		anchor, offsets = body[:cut], body[cut:]
+	}
+	if relativeOffsetPattern.ReplaceAllString(offsets, "") != "" {
+		return "", fmt.Errorf("%w %q", ErrInvalidRelativeDate, expr)
+	}
+
+	y, m, d := now.Date()
+	day := time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+	for _, match := range relativeOffsetPattern.FindAllStringSubmatch(offsets, -1) {
+		n, err := strconv.Atoi(match[2])
+		if err != nil {
+			return "", fmt.Errorf("%w %q", ErrInvalidRelativeDate, expr)
+		}
+		if match[1] == "-" {
+			n = -n
+		}
+		switch match[3] {
+		case "d":
+			day = day.AddDate(0, 0, n)
+		case "w":
+			day = day.AddDate(0, 0, 7*n)
+		case "m":
+			day = addMonths(day, n)
+		case "y":
+			day = addMonths(day, 12*n)
+		}
+	}
+
+	y, m, _ = day.Date()
+	loc := day.Location()
+	switch anchor {
+	case "today":
+	case "startOfWeek":
+		day = day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	case "endOfWeek":
+		day = day.AddDate(0, 0, 6-(int(day.Weekday())+6)%7)
+	case "startOfMonth":
+		day = time.Date(y, m, 1, 0, 0, 0, 0, loc)
+	case "endOfMonth":
+		day = time.Date(y, m+1, 0, 0, 0, 0, 0, loc)
+	case "startOfQuarter":
+		day = time.Date(y, m-(m-1)%3, 1, 0, 0, 0, 0, loc)
+	case "endOfQuarter":
+		day = time.Date(y, m-(m-1)%3+3, 0, 0, 0, 0, 0, loc)
+	case "startOfYear":
+		day = time.Date(y, time.January, 1, 0, 0, 0, 0, loc)
+	case "endOfYear":
+		day = time.Date(y, time.December, 31, 0, 0, 0, 0, loc)
+	default:
+		return "", fmt.Errorf("%w %q", ErrInvalidRelativeDate, expr)
+	}
+	return day.Format("2006-01-02"), nil
+}
+
+// addMonths moves t by n months, keeping the day within the target month so
+// that March 31 minus a month is February 28 rather than March 3.
+func addMonths(t time.Time, n int) time.Time {
+	y, m, d := t.Date()
+	last := time.Date(y, m+time.Month(n)+1, 0, 0, 0, 0, 0, t.Location()).Day()
+	if d > last {
+		d = last
+	}
+	return time.Date(y, m+time.Month(n), d, 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,67 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveParams(t *testing.T) {
+	// A Tuesday at the end of a month.
+	now := time.Date(2026, time.March, 31, 8, 0, 0, 0, time.UTC)
+	tests := map[string]string{
+		"@today":              "2026-03-31",
+		"@today-1d":           "2026-03-30",
+		"@today-7d":           "2026-03-24",
+		"@today+2w":           "2026-04-14",
+		"@today-1m":           "2026-02-28",
+		"@today-1y":           "2025-03-31",
+		"@startOfWeek":        "2026-03-30",
+		"@endOfWeek":          "2026-04-05",
+		"@startOfMonth":       "2026-03-01",
+		"@startOfMonth-1m":    "2026-02-01",
+		"@endOfMonth-1m":      "2026-02-28",
+		"@startOfQuarter":     "2026-01-01",
+		"@endOfQuarter+1m":    "2026-06-30",
+		"@startOfYear":        "2026-01-01",
+		"@endOfYear-1y":       "2025-12-31",
+		"@startOfMonth-1y+1m": "2025-04-01",
+	}
+	for expr, want := range tests {
+		resolved, err := ResolveParams(map[string]interface{}{"day": expr}, now)
+		require.NoError(t, err, expr)
+		assert.Equal(t, want, resolved["day"], expr)
+	}
+
+	resolved, err := ResolveParams(map[string]interface{}{
+		"period": []interface{}{"@startOfMonth-1m", "@endOfMonth-1m"},
+		"range":  map[string]interface{}{"start": "@today-7d", "end": "@today"},
+		"region": "North",
+		"limit":  10.0,
+	}, now)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"period": []interface{}{"2026-02-01", "2026-02-28"},
+		"range":  map[string]interface{}{"start": "2026-03-24", "end": "2026-03-31"},
+		"region": "North",
+		"limit":  10.0,
+	}, resolved)
+
+	for _, expr := range []string{"@tomorrow", "@today-", "@today-1h", "@today 1d", "@"} {
+		_, err := ResolveParams(map[string]interface{}{"day": expr}, now)
+		assert.ErrorIs(t, err, ErrInvalidRelativeDate, expr)
+	}
+}
+
+func TestResolveParams_UsesLocation(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	require.NoError(t, err)
+	// Still the 1st in UTC, already the 2nd in Shanghai.
+	now := time.Date(2026, time.May, 1, 20, 0, 0, 0, time.UTC)
+
+	resolved, err := ResolveParams(map[string]interface{}{"day": "@today"}, now.In(shanghai))
+	require.NoError(t, err)
+	assert.Equal(t, "2026-05-02", resolved["day"])
+}
@@ -0,0 +1,237 @@
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gujiaweiguo/goreport/internal/secrets"
+	"gorm.io/gorm"
+)
+
+type Repository interface {
+	Create(ctx context.Context, schedule *Schedule) error
+	Update(ctx context.Context, schedule *Schedule) error
+	Delete(ctx context.Context, id, tenantID string) error
+	Get(ctx context.Context, id, tenantID string) (*Schedule, error)
+	List(ctx context.Context, tenantID string) ([]*Schedule, error)
+	// Due returns enabled schedules of every tenant whose next run is at or
+	// before now.
+	Due(ctx context.Context, now time.Time, limit int) ([]*Schedule, error)
+	// Claim moves a schedule's next run from prev to next, and reports
+	// whether this caller won it when several instances poll at once.
+	Claim(ctx context.Context, id string, prev, next time.Time) (bool, error)
+	// Disable stops a schedule from being due again until it is edited.
+	Disable(ctx context.Context, id string) error
+	CreateRun(ctx context.Context, run *Run) error
+	UpdateRun(ctx context.Context, run *Run) error
+	ListRuns(ctx context.Context, scheduleID, tenantID string, limit int) ([]*Run, error)
+}
+
+// repository stores delivery secrets sealed by keyring and hands them back
+// decrypted; callers only ever see plaintext.
+type repository struct {
+	db      *gorm.DB
+	keyring *secrets.Keyring
+}
+
+func NewRepository(db *gorm.DB) Repository {
+	return NewRepositoryWithKeyring(db, secrets.Default())
+}
+
+func NewRepositoryWithKeyring(db *gorm.DB, keyring *secrets.Keyring) Repository {
+	return &repository{db: db, keyring: keyring}
+}
+
+func (r *repository) Create(ctx context.Context, schedule *Schedule) error {
+	row, err := r.seal(schedule)
+	if err != nil {
+		return err
+	}
+	return r.db.WithContext(ctx).Create(row).Error
+}
+
+func (r *repository) Update(ctx context.Context, schedule *Schedule) error {
+	row, err := r.seal(schedule)
+	if err != nil {
+		return err
+	}
+	return r.db.WithContext(ctx).Model(row).
+		Where("tenant_id = ?", schedule.TenantID).
+		Select("*").Omit("created_at", "deleted_at").
+		Updates(row).Error
+}
+
+func (r *repository) Delete(ctx context.Context, id, tenantID string) error {
+	return r.db.WithContext(ctx).
+		Where("id = ? AND tenant_id = ?", id, tenantID).
+		Delete(&Schedule{}).Error
+}
+
+func (r *repository) Get(ctx context.Context, id, tenantID string) (*Schedule, error) {
+	var schedule Schedule
+	if err := r.db.WithContext(ctx).
+		Where("id = ? AND tenant_id = ?", id, tenantID).
+		First(&schedule).Error; err != nil {
+		return nil, err
+	}
+	if err := r.open(&schedule); err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+func (r *repository) List(ctx context.Context, tenantID string) ([]*Schedule, error) {
+	var schedules []*Schedule
+	if err := r.db.WithContext(ctx).
+		Where("tenant_id = ?", tenantID).
+		Order("updated_at desc").
+		Find(&schedules).Error; err != nil {
+		return nil, err
+	}
+	return r.openAll(schedules)
+}
+
+func (r *repository) Due(ctx context.Context, now time.Time, limit int) ([]*Schedule, error) {
+	var schedules []*Schedule
+	if err := r.db.WithContext(ctx).
+		Where("enabled = ? AND next_run_at IS NOT NULL AND next_run_at <= ?", true, now).
+		Order("next_run_at").
+		Limit(limit).
+		Find(&schedules).Error; err != nil {
+		return nil, err
+	}
+	return r.openAll(schedules)
+}
+
+func (r *repository) Claim(ctx context.Context, id string, prev, next time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&Schedule{}).
+		Where("id = ? AND next_run_at = ?", id, prev).
+		UpdateColumns(map[string]interface{}{"next_run_at": next, "last_run_at": prev})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *repository) Disable(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).Model(&Schedule{}).
+		Where("id = ?", id).
+		UpdateColumns(map[string]interface{}{"enabled": false, "next_run_at": nil}).Error
+}
+
+func (r *repository) CreateRun(ctx context.Context, run *Run) error {
+	return r.db.WithContext(ctx).Create(run).Error
+}
+
+func (r *repository) UpdateRun(ctx context.Context, run *Run) error {
+	return r.db.WithContext(ctx).Model(run).
+		Select("status", "attempts", "file_name", "file_size", "error", "finished_at").
+		Updates(run).Error
+}
+
+func (r *repository) ListRuns(ctx context.Context, scheduleID, tenantID string, limit int) ([]*Run, error) {
+	var runs []*Run
+	if err := r.db.WithContext(ctx).
+		Where("schedule_id = ? AND tenant_id = ?", scheduleID, tenantID).
+		Order("started_at desc").
+		Limit(limit).
+		Find(&runs).Error; err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+// seal returns a copy of schedule with its delivery secrets encrypted,
+// leaving schedule itself in plaintext for the caller.
+func (r *repository) seal(schedule *Schedule) (*Schedule, error) {
+	row := *schedule
+	delivery, err := mapDeliverySecrets(schedule.Delivery, r.keyring.Seal)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt schedule secrets: %w", err)
+	}
+	row.Delivery = delivery
+	return &row, nil
+}
+
+func (r *repository) open(schedule *Schedule) error {
+	delivery, err := mapDeliverySecrets(schedule.Delivery, r.keyring.Open)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt secrets of schedule %s: %w", schedule.ID, err)
+	}
+	schedule.Delivery = delivery
+	return nil
+}
+
+func (r *repository) openAll(schedules []*Schedule) ([]*Schedule, error) {
+	for _, schedule := range schedules {
+		if err := r.open(schedule); err != nil {
+			return nil, err
+		}
+	}
+	return schedules, nil
+}
+
+// mapDeliverySecrets returns a copy of delivery with fn applied to the
+// secrets encrypted at rest: the webhook secret and the header values.
+func mapDeliverySecrets(delivery Delivery, fn func(string) (string, error)) (Delivery, error) {
+	secret, err := fn(delivery.Secret)
+	if err != nil {
+		return Delivery{}, err
+	}
+	delivery.Secret = secret
+	if delivery.Headers != nil {
+		headers := make(map[string]string, len(delivery.Headers))
+		for name, value := range delivery.Headers {
+			if headers[name], err = fn(value); err != nil {
+				return Delivery{}, fmt.Errorf("header %s: %w", name, err)
+			}
+		}
+		delivery.Headers = headers
+	}
+	return delivery, nil
+}
+
+// RewrapSecrets brings the delivery secrets of every schedule, deleted ones
+// included, under the keyring's current master key, like
+// repository.RewrapDatasourceSecrets does for datasources. It returns how
+// many schedules were rewritten.
+func RewrapSecrets(ctx context.Context, db *gorm.DB, keyring *secrets.Keyring) (int, error) {
+	if !keyring.Enabled() {
+		return 0, secrets.ErrNoMasterKey
+	}
+
+	var rows []*Schedule
+	updated := 0
+	result := db.WithContext(ctx).Unscoped().
+		Select("id", "delivery").
+		FindInBatches(&rows, 100, func(tx *gorm.DB, batch int) error {
+			for _, schedule := range rows {
+				changed := false
+				delivery, err := mapDeliverySecrets(schedule.Delivery, func(value string) (string, error) {
+					rewrapped, valueChanged, err := keyring.Rewrap(value)
+					changed = changed || valueChanged
+					return rewrapped, err
+				})
+				if err != nil {
+					return fmt.Errorf("schedule %s: %w", schedule.ID, err)
+				}
+				if !changed {
+					continue
+				}
+				encoded, err := json.Marshal(delivery)
+				if err != nil {
+					return err
+				}
+				err = db.WithContext(ctx).Unscoped().Model(&Schedule{}).Where("id = ?", schedule.ID).
+					UpdateColumn("delivery", string(encoded)).Error
+				if err != nil {
+					return err
+				}
+				updated++
+			}
+			return nil
+		})
+	return updated, result.Error
+}
@@ -0,0 +1,201 @@
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gujiaweiguo/goreport/internal/secrets"
+	"github.com/gujiaweiguo/goreport/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestNewRepository(t *testing.T) {
+	repo := NewRepository(nil)
+	assert.NotNil(t, repo)
+}
+
+func setupRepo(t *testing.T) (*gorm.DB, Repository) {
+	t.Helper()
+	db := testutil.SetupMySQLTestDB(t)
+	require.NoError(t, db.AutoMigrate(&Schedule{}, &Run{}))
+	t.Cleanup(func() {
+		testutil.CloseDB(db)
+	})
+	return db, NewRepository(db)
+}
+
+func setupTenant(t *testing.T, db *gorm.DB) string {
+	t.Helper()
+	tenantID := uniqueID("tenant")
+	err := db.Exec(
+		"INSERT IGNORE INTO tenants (id, name, code, status) VALUES (?, ?, ?, 1)",
+		tenantID,
+		"Test "+tenantID,
+		tenantID,
+	).Error
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		db.Unscoped().Where("tenant_id = ?", tenantID).Delete(&Run{})
+		db.Unscoped().Where("tenant_id = ?", tenantID).Delete(&Schedule{})
+		testutil.CleanupTenantData(db, []string{tenantID})
+	})
+
+	return tenantID
+}
+
+func uniqueID(prefix string) string {
+	return fmt.Sprintf("%s-%d", prefix, time.Now().UnixNano())
+}
+
+func newTestSchedule(tenantID string, next time.Time) *Schedule {
+	return &Schedule{
+		ID:         uniqueID("schedule"),
+		TenantID:   tenantID,
+		Name:       "Daily sales",
+		Cron:       "0 8 * * *",
+		TargetType: TargetReport,
+		TargetID:   "report-1",
+		Format:     "pdf",
+		Params:     map[string]interface{}{"day": "@today-1d"},
+		Delivery:   Delivery{Channel: ChannelWebhook, URL: "https://example.com/hook"},
+		MaxRetries: 2,
+		Enabled:    true,
+		NextRunAt:  &next,
+	}
+}
+
+func TestRepository_CreateAndGet(t *testing.T) {
+	db, repo := setupRepo(t)
+	ctx := context.Background()
+	tenantID := setupTenant(t, db)
+
+	schedule := newTestSchedule(tenantID, time.Now().Add(time.Hour))
+	require.NoError(t, repo.Create(ctx, schedule))
+
+	fetched, err := repo.Get(ctx, schedule.ID, tenantID)
+	require.NoError(t, err)
+	assert.Equal(t, "Daily sales", fetched.Name)
+	assert.Equal(t, map[string]interface{}{"day": "@today-1d"}, fetched.Params)
+	assert.Equal(t, schedule.Delivery, fetched.Delivery)
+
+	fetched.Enabled = false
+	fetched.NextRunAt = nil
+	fetched.Delivery.URL = "https://example.com/other"
+	require.NoError(t, repo.Update(ctx, fetched))
+
+	updated, err := repo.Get(ctx, schedule.ID, tenantID)
+	require.NoError(t, err)
+	assert.False(t, updated.Enabled)
+	assert.Nil(t, updated.NextRunAt)
+	assert.Equal(t, "https://example.com/other", updated.Delivery.URL)
+
+	_, err = repo.Get(ctx, schedule.ID, setupTenant(t, db))
+	assert.Error(t, err)
+}
+
+func TestRepository_EncryptsSecrets(t *testing.T) {
+	db, _ := setupRepo(t)
+	ctx := context.Background()
+	tenantID := setupTenant(t, db)
+
+	oldKey, err := secrets.GenerateKey()
+	require.NoError(t, err)
+	keyring, err := secrets.NewKeyring(oldKey)
+	require.NoError(t, err)
+	repo := NewRepositoryWithKeyring(db, keyring)
+
+	schedule := newTestSchedule(tenantID, time.Now().Add(time.Hour))
+	schedule.Delivery.Secret = "hook-secret"
+	schedule.Delivery.Headers = map[string]string{"Authorization": "Bearer abc"}
+	require.NoError(t, repo.Create(ctx, schedule))
+	assert.Equal(t, "hook-secret", schedule.Delivery.Secret, "the caller's copy stays in plaintext")
+
+	var raw Schedule
+	require.NoError(t, db.Where("id = ?", schedule.ID).First(&raw).Error)
+	assert.True(t, secrets.IsSealed(raw.Delivery.Secret))
+	assert.True(t, secrets.IsSealed(raw.Delivery.Headers["Authorization"]))
+	assert.NotContains(t, raw.DeliveryJSON, "Bearer abc")
+
+	fetched, err := repo.Get(ctx, schedule.ID, tenantID)
+	require.NoError(t, err)
+	assert.Equal(t, schedule.Delivery, fetched.Delivery)
+
+	newKey, err := secrets.GenerateKey()
+	require.NoError(t, err)
+	rotated, err := secrets.NewKeyring(newKey, oldKey)
+	require.NoError(t, err)
+	_, err = RewrapSecrets(ctx, db.Where("tenant_id = ?", tenantID), rotated)
+	require.NoError(t, err)
+
+	current, err := secrets.NewKeyring(newKey)
+	require.NoError(t, err)
+	fetched, err = NewRepositoryWithKeyring(db, current).Get(ctx, schedule.ID, tenantID)
+	require.NoError(t, err)
+	assert.Equal(t, schedule.Delivery, fetched.Delivery)
+}
+
+func TestRepository_DueAndClaim(t *testing.T) {
+	db, repo := setupRepo(t)
+	ctx := context.Background()
+	tenantID := setupTenant(t, db)
+
+	now := time.Now().Truncate(time.Second)
+	due := newTestSchedule(tenantID, now.Add(-time.Minute))
+	later := newTestSchedule(tenantID, now.Add(time.Hour))
+	require.NoError(t, repo.Create(ctx, due))
+	require.NoError(t, repo.Create(ctx, later))
+
+	schedules, err := repo.Due(ctx, now, 100)
+	require.NoError(t, err)
+	var ids []string
+	for _, s := range schedules {
+		ids = append(ids, s.ID)
+	}
+	assert.Contains(t, ids, due.ID)
+	assert.NotContains(t, ids, later.ID)
+
+	claimed, err := repo.Claim(ctx, due.ID, *due.NextRunAt, now.Add(time.Hour))
+	require.NoError(t, err)
+	assert.True(t, claimed)
+
+	claimed, err = repo.Claim(ctx, due.ID, *due.NextRunAt, now.Add(time.Hour))
+	require.NoError(t, err)
+	assert.False(t, claimed, "a run is claimed once")
+
+	require.NoError(t, repo.Disable(ctx, later.ID))
+	disabled, err := repo.Get(ctx, later.ID, tenantID)
+	require.NoError(t, err)
+	assert.False(t, disabled.Enabled)
+	assert.Nil(t, disabled.NextRunAt)
+}
+
+func TestRepository_Runs(t *testing.T) {
+	db, repo := setupRepo(t)
+	ctx := context.Background()
+	tenantID := setupTenant(t, db)
+
+	schedule := newTestSchedule(tenantID, time.Now())
+	require.NoError(t, repo.Create(ctx, schedule))
+
+	run := &Run{ID: uniqueID("run"), ScheduleID: schedule.ID, TenantID: tenantID, Trigger: TriggerManual, Status: RunRunning, StartedAt: time.Now()}
+	require.NoError(t, repo.CreateRun(ctx, run))
+
+	finished := time.Now()
+	run.Status = RunFailed
+	run.Attempts = 3
+	run.Error = "deliver: webhook returned 500"
+	run.FinishedAt = &finished
+	require.NoError(t, repo.UpdateRun(ctx, run))
+
+	runs, err := repo.ListRuns(ctx, schedule.ID, tenantID, 10)
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	assert.Equal(t, RunFailed, runs[0].Status)
+	assert.Equal(t, 3, runs[0].Attempts)
+	assert.NotNil(t, runs[0].FinishedAt)
+}
@@ -0,0 +1,51 @@
+package schedule
+
+import "github.com/gujiaweiguo/goreport/internal/datasource"
+
+// NewResponse is the API view of a schedule. A delivery secret or header
+// value that is set reads as datasource.SecretUnchanged, which UpdateRequest
+// accepts back to keep the stored value.
+func NewResponse(schedule *Schedule) *Schedule {
+	response := *schedule
+	response.Delivery, _ = mapDeliverySecrets(schedule.Delivery, redactSecret)
+	return &response
+}
+
+func NewResponses(schedules []*Schedule) []*Schedule {
+	responses := make([]*Schedule, 0, len(schedules))
+	for _, schedule := range schedules {
+		responses = append(responses, NewResponse(schedule))
+	}
+	return responses
+}
+
+func redactSecret(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	return datasource.SecretUnchanged, nil
+}
+
+// mergeDelivery returns requested with the secrets it leaves as
+// datasource.SecretUnchanged taken from current. An unchanged header that
+// current does not have is dropped.
+func mergeDelivery(current, requested Delivery) Delivery {
+	if requested.Secret == datasource.SecretUnchanged {
+		requested.Secret = current.Secret
+	}
+	if requested.Headers != nil {
+		headers := make(map[string]string, len(requested.Headers))
+		for name, value := range requested.Headers {
+			if value == datasource.SecretUnchanged {
+				stored, ok := current.Headers[name]
+				if !ok {
+					continue
+				}
+				value = stored
+			}
+			headers[name] = value
+		}
+		requested.Headers = headers
+	}
+	return requested
+}
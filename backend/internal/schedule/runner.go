@@ -0,0 +1,262 @@
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gujiaweiguo/goreport/internal/config"
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	dueBatchSize    = 100
+	maxRetryBackoff = 30 * time.Minute
+)
+
+// Runner polls for due schedules and executes runs: it renders the target
+// once, then delivers it, retrying a failed delivery up to the schedule's
+// MaxRetries times with exponential backoff. Several instances may poll the
+// same database; each due run is claimed by exactly one of them.
+type Runner struct {
+	repo         Repository
+	renderer     Renderer
+	deliverers   map[string]Deliverer
+	pollInterval time.Duration
+	retryBackoff time.Duration
+	slots        chan struct{}
+	now          func() time.Time
+
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+	stopOnce sync.Once
+}
+
+func NewRunner(repo Repository, renderer Renderer, deliverers map[string]Deliverer, cfg config.SchedulerConfig) *Runner {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Runner{
+		repo:         repo,
+		renderer:     renderer,
+		deliverers:   deliverers,
+		pollInterval: time.Duration(max(cfg.PollInterval, 1)) * time.Second,
+		retryBackoff: time.Duration(max(cfg.RetryBackoff, 0)) * time.Second,
+		slots:        make(chan struct{}, max(cfg.MaxConcurrent, 1)),
+		now:          time.Now,
+		ctx:          ctx,
+		cancel:       cancel,
+	}
+}
+
+// Start polls for due schedules until Stop is called.
+func (r *Runner) Start() {
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		ticker := time.NewTicker(r.pollInterval)
+		defer ticker.Stop()
+		for {
+			r.poll()
+			select {
+			case <-ticker.C:
+			case <-r.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends polling, cancels pending retries and waits for runs in flight
+// to record their outcome, or for ctx to expire.
+func (r *Runner) Stop(ctx context.Context) error {
+	r.stopOnce.Do(r.cancel)
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *Runner) poll() {
+	now := r.now()
+	due, err := r.repo.Due(r.ctx, now, dueBatchSize)
+	if err != nil {
+		log.Printf("schedule: failed to load due schedules: %v", err)
+		return
+	}
+	for _, s := range due {
+		next, err := nextRunAt(s.Cron, s.Timezone, now)
+		if err != nil {
+			r.disableInvalid(s, err)
+			continue
+		}
+		claimed, err := r.repo.Claim(r.ctx, s.ID, *s.NextRunAt, next)
+		if err != nil {
+			log.Printf("schedule: failed to claim %s: %v", s.ID, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+		if _, err := r.Dispatch(r.ctx, s, TriggerCron); err != nil {
+			log.Printf("schedule: failed to start run of %s: %v", s.ID, err)
+		}
+	}
+}
+
+// disableInvalid disables a schedule whose cron expression or timezone no
+// longer parses, recording a failed run that says why, so it is reported
+// once rather than on every poll.
+func (r *Runner) disableInvalid(s *Schedule, cause error) {
+	log.Printf("schedule: disabling %s, its cron expression is invalid: %v", s.ID, cause)
+	now := r.now()
+	run := &Run{
+		ID:         fmt.Sprintf("run-%d", time.Now().UnixNano()),
+		ScheduleID: s.ID,
+		TenantID:   s.TenantID,
+		Trigger:    TriggerCron,
+		Status:     RunFailed,
+		Error:      fmt.Sprintf("schedule disabled: invalid cron expression: %v", cause),
+		StartedAt:  now,
+		FinishedAt: &now,
+	}
+	if err := r.repo.CreateRun(r.ctx, run); err != nil {
+		log.Printf("schedule: failed to record invalid cron of %s: %v", s.ID, err)
+	}
+	if err := r.repo.Disable(r.ctx, s.ID); err != nil {
+		log.Printf("schedule: failed to disable %s: %v", s.ID, err)
+	}
+}
+
+// Dispatch records a new run of the schedule and executes it in the
+// background. The returned run is its state when it started.
+func (r *Runner) Dispatch(ctx context.Context, s *Schedule, trigger string) (*Run, error) {
+	run := &Run{
+		ID:         fmt.Sprintf("run-%d", time.Now().UnixNano()),
+		ScheduleID: s.ID,
+		TenantID:   s.TenantID,
+		Trigger:    trigger,
+		Status:     RunRunning,
+		StartedAt:  r.now(),
+	}
+	if err := r.repo.CreateRun(ctx, run); err != nil {
+		return nil, err
+	}
+	started := *run
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		select {
+		case r.slots <- struct{}{}:
+			defer func() { <-r.slots }()
+			r.execute(r.ctx, s, run)
+		case <-r.ctx.Done():
+			r.finish(run, r.ctx.Err())
+		}
+	}()
+	return &started, nil
+}
+
+func (r *Runner) execute(ctx context.Context, s *Schedule, run *Run) {
+	now := r.now().In(scheduleLocation(s.Timezone))
+	params, err := ResolveParams(s.Params, now)
+	if err != nil {
+		r.finish(run, err)
+		return
+	}
+
+	deliverer := r.deliverers[s.Delivery.Channel]
+	if deliverer == nil {
+		r.finish(run, fmt.Errorf("%w: %s", ErrDeliveryNotConfigured, s.Delivery.Channel))
+		return
+	}
+
+	artifact, err := r.renderer.Render(ctx, s, params, now)
+	if err != nil {
+		r.finish(run, fmt.Errorf("render: %w", err))
+		return
+	}
+	run.FileName = artifact.FileName
+	run.FileSize = int64(len(artifact.Data))
+
+	for attempt := 1; ; attempt++ {
+		run.Attempts = attempt
+		err = deliverer.Deliver(ctx, s, run, artifact)
+		if err == nil || attempt > s.MaxRetries {
+			break
+		}
+		log.Printf("schedule: delivery of run %s failed (attempt %d): %v", run.ID, attempt, err)
+		select {
+		case <-time.After(r.backoff(attempt)):
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if err != nil {
+		err = fmt.Errorf("deliver: %w", err)
+	}
+	r.finish(run, err)
+}
+
+// backoff is the wait after the given failed attempt: the configured base,
+// doubled for every attempt since the first.
+func (r *Runner) backoff(attempt int) time.Duration {
+	delay := r.retryBackoff
+	for i := 1; i < attempt && delay < maxRetryBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRetryBackoff)
+}
+
+func (r *Runner) finish(run *Run, err error) {
+	finished := r.now()
+	run.FinishedAt = &finished
+	run.Status = RunSucceeded
+	if err != nil {
+		run.Status = RunFailed
+		run.Error = err.Error()
+	}
+	// The run's own context may be cancelled by now; the outcome is still
+	// worth recording.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := r.repo.UpdateRun(ctx, run); err != nil {
+		log.Printf("schedule: failed to record run %s: %v", run.ID, err)
+	}
+}
+
+// nextRunAt returns the first time after the given one that a standard
+// five-field cron expression, or a descriptor such as "@daily", matches in
+// the timezone.
+func nextRunAt(expr, timezone string, after time.Time) (time.Time, error) {
+	schedule, err := cron.ParseStandard(expr)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return schedule.Next(after.In(scheduleLocation(timezone))), nil
+}
+
+// scheduleLocation loads a validated timezone name; an empty name is the
+// server's local time.
+func scheduleLocation(timezone string) *time.Location {
+	if timezone == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
@@ -0,0 +1,386 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gujiaweiguo/goreport/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// memoryRepo keeps schedules and runs in memory; runs are updated from the
+// runner's goroutines, so it is safe for concurrent use.
+type memoryRepo struct {
+	mu        sync.Mutex
+	schedules map[string]*Schedule
+	runs      []*Run
+	claims    int
+	done      chan *Run
+}
+
+func newMemoryRepo() *memoryRepo {
+	return &memoryRepo{schedules: map[string]*Schedule{}, done: make(chan *Run, 10)}
+}
+
+func (m *memoryRepo) Create(ctx context.Context, schedule *Schedule) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.schedules[schedule.ID] = schedule
+	return nil
+}
+
+func (m *memoryRepo) Update(ctx context.Context, schedule *Schedule) error {
+	return m.Create(ctx, schedule)
+}
+
+func (m *memoryRepo) Delete(ctx context.Context, id, tenantID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.schedules, id)
+	return nil
+}
+
+func (m *memoryRepo) Get(ctx context.Context, id, tenantID string) (*Schedule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	schedule, ok := m.schedules[id]
+	if !ok || schedule.TenantID != tenantID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *schedule
+	return &copied, nil
+}
+
+func (m *memoryRepo) List(ctx context.Context, tenantID string) ([]*Schedule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var schedules []*Schedule
+	for _, schedule := range m.schedules {
+		if schedule.TenantID == tenantID {
+			schedules = append(schedules, schedule)
+		}
+	}
+	return schedules, nil
+}
+
+func (m *memoryRepo) Due(ctx context.Context, now time.Time, limit int) ([]*Schedule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var due []*Schedule
+	for _, schedule := range m.schedules {
+		if schedule.Enabled && schedule.NextRunAt != nil && !schedule.NextRunAt.After(now) {
+			copied := *schedule
+			due = append(due, &copied)
+		}
+	}
+	return due, nil
+}
+
+func (m *memoryRepo) Claim(ctx context.Context, id string, prev, next time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	schedule := m.schedules[id]
+	if schedule == nil || schedule.NextRunAt == nil || !schedule.NextRunAt.Equal(prev) {
+		return false, nil
+	}
+	m.claims++
+	schedule.NextRunAt = &next
+	schedule.LastRunAt = &prev
+	return true, nil
+}
+
+func (m *memoryRepo) Disable(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if schedule := m.schedules[id]; schedule != nil {
+		schedule.Enabled = false
+		schedule.NextRunAt = nil
+	}
+	return nil
+}
+
+func (m *memoryRepo) CreateRun(ctx context.Context, run *Run) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	copied := *run
+	m.runs = append(m.runs, &copied)
+	return nil
+}
+
+func (m *memoryRepo) UpdateRun(ctx context.Context, run *Run) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	copied := *run
+	for i, existing := range m.runs {
+		if existing.ID == run.ID {
+			m.runs[i] = &copied
+		}
+	}
+	m.done <- &copied
+	return nil
+}
+
+func (m *memoryRepo) ListRuns(ctx context.Context, scheduleID, tenantID string, limit int) ([]*Run, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var runs []*Run
+	for _, run := range m.runs {
+		if run.ScheduleID == scheduleID && run.TenantID == tenantID {
+			runs = append(runs, run)
+		}
+	}
+	return runs, nil
+}
+
+func (m *memoryRepo) waitRun(t *testing.T) *Run {
+	t.Helper()
+	select {
+	case run := <-m.done:
+		return run
+	case <-time.After(5 * time.Second):
+		t.Fatal("run did not finish")
+		return nil
+	}
+}
+
+type fakeRenderer struct {
+	mu     sync.Mutex
+	err    error
+	params []map[string]interface{}
+}
+
+func (f *fakeRenderer) Render(ctx context.Context, schedule *Schedule, params map[string]interface{}, now time.Time) (*Artifact, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.params = append(f.params, params)
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &Artifact{FileName: schedule.TargetID + ".pdf", ContentType: "application/pdf", Data: []byte("pdf")}, nil
+}
+
+// flakyDeliverer fails its first failures deliveries.
+type flakyDeliverer struct {
+	mu       sync.Mutex
+	failures int
+	calls    []time.Time
+}
+
+func (f *flakyDeliverer) Deliver(ctx context.Context, schedule *Schedule, run *Run, artifact *Artifact) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, time.Now())
+	if len(f.calls) <= f.failures {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func newTestRunner(repo Repository, renderer Renderer, deliverer Deliverer) *Runner {
+	runner := NewRunner(repo, renderer, map[string]Deliverer{ChannelWebhook: deliverer}, config.SchedulerConfig{PollInterval: 1, MaxConcurrent: 2})
+	runner.retryBackoff = 10 * time.Millisecond
+	return runner
+}
+
+func runnerSchedule(maxRetries int) *Schedule {
+	return &Schedule{
+		ID:         "schedule-1",
+		TenantID:   "tenant-1",
+		Cron:       "0 8 * * *",
+		Timezone:   "UTC",
+		TargetType: TargetReport,
+		TargetID:   "report-1",
+		Format:     "pdf",
+		Params:     map[string]interface{}{"day": "@today-1d", "region": "North"},
+		Delivery:   Delivery{Channel: ChannelWebhook, URL: "https://example.com/hook"},
+		MaxRetries: maxRetries,
+		Enabled:    true,
+	}
+}
+
+func TestRunner_RetriesWithBackoff(t *testing.T) {
+	repo := newMemoryRepo()
+	renderer := &fakeRenderer{}
+	deliverer := &flakyDeliverer{failures: 2}
+	runner := newTestRunner(repo, renderer, deliverer)
+	runner.now = func() time.Time { return time.Date(2026, time.March, 1, 8, 0, 0, 0, time.UTC) }
+
+	started, err := runner.Dispatch(context.Background(), runnerSchedule(3), TriggerManual)
+	require.NoError(t, err)
+	assert.Equal(t, RunRunning, started.Status)
+	assert.Equal(t, TriggerManual, started.Trigger)
+
+	run := repo.waitRun(t)
+	assert.Equal(t, RunSucceeded, run.Status)
+	assert.Equal(t, 3, run.Attempts)
+	assert.Empty(t, run.Error)
+	assert.Equal(t, "report-1.pdf", run.FileName)
+	assert.Equal(t, int64(3), run.FileSize)
+	assert.Equal(t, []map[string]interface{}{{"day": "2026-02-28", "region": "North"}}, renderer.params)
+
+	require.Len(t, deliverer.calls, 3)
+	assert.GreaterOrEqual(t, deliverer.calls[1].Sub(deliverer.calls[0]), 10*time.Millisecond)
+	assert.GreaterOrEqual(t, deliverer.calls[2].Sub(deliverer.calls[1]), 20*time.Millisecond)
+}
+
+func TestRunner_FailsAfterRetries(t *testing.T) {
+	repo := newMemoryRepo()
+	deliverer := &flakyDeliverer{failures: 5}
+	runner := newTestRunner(repo, &fakeRenderer{}, deliverer)
+
+	_, err := runner.Dispatch(context.Background(), runnerSchedule(1), TriggerManual)
+	require.NoError(t, err)
+
+	run := repo.waitRun(t)
+	assert.Equal(t, RunFailed, run.Status)
+	assert.Equal(t, 2, run.Attempts)
+	assert.Equal(t, "deliver: connection refused", run.Error)
+	assert.NotNil(t, run.FinishedAt)
+}
+
+func TestRunner_RenderFailureIsNotRetried(t *testing.T) {
+	repo := newMemoryRepo()
+	deliverer := &flakyDeliverer{}
+	runner := newTestRunner(repo, &fakeRenderer{err: errors.New("report not found")}, deliverer)
+
+	_, err := runner.Dispatch(context.Background(), runnerSchedule(3), TriggerManual)
+	require.NoError(t, err)
+
+	run := repo.waitRun(t)
+	assert.Equal(t, RunFailed, run.Status)
+	assert.Equal(t, "render: report not found", run.Error)
+	assert.Zero(t, run.Attempts)
+	assert.Empty(t, deliverer.calls)
+}
+
+func TestRunner_UnconfiguredChannel(t *testing.T) {
+	repo := newMemoryRepo()
+	runner := newTestRunner(repo, &fakeRenderer{}, &flakyDeliverer{})
+	schedule := runnerSchedule(0)
+	schedule.Delivery = Delivery{Channel: ChannelEmail, Recipients: []string{"ops@example.com"}}
+
+	_, err := runner.Dispatch(context.Background(), schedule, TriggerManual)
+	require.NoError(t, err)
+
+	run := repo.waitRun(t)
+	assert.Equal(t, RunFailed, run.Status)
+	assert.Contains(t, run.Error, ErrDeliveryNotConfigured.Error())
+}
+
+func TestRunner_PollClaimsDueSchedules(t *testing.T) {
+	repo := newMemoryRepo()
+	deliverer := &flakyDeliverer{}
+	runner := newTestRunner(repo, &fakeRenderer{}, deliverer)
+	now := time.Date(2026, time.March, 1, 8, 0, 30, 0, time.UTC)
+	runner.now = func() time.Time { return now }
+
+	due := runnerSchedule(0)
+	dueAt := time.Date(2026, time.March, 1, 8, 0, 0, 0, time.UTC)
+	due.NextRunAt = &dueAt
+	later := runnerSchedule(0)
+	later.ID = "schedule-2"
+	laterAt := now.Add(time.Hour)
+	later.NextRunAt = &laterAt
+	require.NoError(t, repo.Create(context.Background(), due))
+	require.NoError(t, repo.Create(context.Background(), later))
+
+	runner.poll()
+	run := repo.waitRun(t)
+	assert.Equal(t, "schedule-1", run.ScheduleID)
+	assert.Equal(t, TriggerCron, run.Trigger)
+	assert.Equal(t, RunSucceeded, run.Status)
+
+	schedule, err := repo.Get(context.Background(), "schedule-1", "tenant-1")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, time.March, 2, 8, 0, 0, 0, time.UTC), schedule.NextRunAt.UTC())
+	assert.Equal(t, dueAt, *schedule.LastRunAt)
+
+	// Nothing is due any more, so a second poll starts nothing.
+	runner.poll()
+	require.NoError(t, runner.Stop(context.Background()))
+	assert.Equal(t, 1, repo.claims)
+	assert.Len(t, deliverer.calls, 1)
+}
+
+func TestRunner_PollDisablesInvalidCron(t *testing.T) {
+	repo := newMemoryRepo()
+	deliverer := &flakyDeliverer{}
+	runner := newTestRunner(repo, &fakeRenderer{}, deliverer)
+	now := time.Date(2026, time.March, 1, 8, 0, 30, 0, time.UTC)
+	runner.now = func() time.Time { return now }
+
+	broken := runnerSchedule(0)
+	broken.Cron = "61 * * * *"
+	dueAt := now.Add(-time.Minute)
+	broken.NextRunAt = &dueAt
+	require.NoError(t, repo.Create(context.Background(), broken))
+
+	runner.poll()
+	runner.poll()
+	require.NoError(t, runner.Stop(context.Background()))
+
+	schedule, err := repo.Get(context.Background(), "schedule-1", "tenant-1")
+	require.NoError(t, err)
+	assert.False(t, schedule.Enabled)
+	assert.Nil(t, schedule.NextRunAt)
+
+	runs, err := repo.ListRuns(context.Background(), "schedule-1", "tenant-1", 10)
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	assert.Equal(t, RunFailed, runs[0].Status)
+	assert.Contains(t, runs[0].Error, "invalid cron expression")
+	assert.Zero(t, repo.claims)
+	assert.Empty(t, deliverer.calls)
+}
+
+func TestRunner_StopCancelsRetries(t *testing.T) {
+	repo := newMemoryRepo()
+	deliverer := &flakyDeliverer{failures: 10}
+	runner := newTestRunner(repo, &fakeRenderer{}, deliverer)
+	runner.retryBackoff = time.Hour
+
+	_, err := runner.Dispatch(context.Background(), runnerSchedule(5), TriggerManual)
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		deliverer.mu.Lock()
+		defer deliverer.mu.Unlock()
+		return len(deliverer.calls) == 1
+	}, 5*time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, runner.Stop(ctx))
+	run := repo.waitRun(t)
+	assert.Equal(t, RunFailed, run.Status)
+	assert.Equal(t, 1, run.Attempts)
+	assert.Contains(t, run.Error, context.Canceled.Error())
+}
+
+func TestRunner_Backoff(t *testing.T) {
+	runner := NewRunner(nil, nil, nil, config.SchedulerConfig{RetryBackoff: 30})
+	assert.Equal(t, 30*time.Second, runner.backoff(1))
+	assert.Equal(t, 60*time.Second, runner.backoff(2))
+	assert.Equal(t, 120*time.Second, runner.backoff(3))
+	assert.Equal(t, maxRetryBackoff, runner.backoff(20))
+}
+
+func TestNextRunAt(t *testing.T) {
+	after := time.Date(2026, time.March, 1, 0, 30, 0, 0, time.UTC)
+
+	next, err := nextRunAt("0 8 * * 1", "Asia/Shanghai", after)
+	require.NoError(t, err)
+	// Monday March 2 at 08:00 in Shanghai.
+	assert.Equal(t, time.Date(2026, time.March, 2, 0, 0, 0, 0, time.UTC), next.UTC())
+
+	next, err = nextRunAt("@daily", "UTC", after)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, time.March, 2, 0, 0, 0, 0, time.UTC), next)
+
+	_, err = nextRunAt("every day", "UTC", after)
+	assert.Error(t, err)
+}
@@ -0,0 +1,261 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gujiaweiguo/goreport/internal/report"
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultMaxRetries = 3
+	maxRetriesLimit   = 10
+	runHistoryLimit   = 50
+)
+
+var (
+	ErrNotFound        = errors.New("schedule not found")
+	ErrInvalidSchedule = errors.New("invalid schedule")
+)
+
+var reportFormats = map[string]bool{
+	report.ExportFormatPDF:  true,
+	report.ExportFormatXLSX: true,
+	report.ExportFormatDOCX: true,
+	report.ExportFormatPNG:  true,
+}
+
+type Service interface {
+	Create(ctx context.Context, req *CreateRequest) (*Schedule, error)
+	Update(ctx context.Context, req *UpdateRequest) (*Schedule, error)
+	Delete(ctx context.Context, id, tenantID string) error
+	Get(ctx context.Context, id, tenantID string) (*Schedule, error)
+	List(ctx context.Context, tenantID string) ([]*Schedule, error)
+	RunNow(ctx context.Context, id, tenantID string) (*Run, error)
+	Runs(ctx context.Context, id, tenantID string) ([]*Run, error)
+}
+
+type service struct {
+	repo   Repository
+	runner *Runner
+	now    func() time.Time
+}
+
+func NewService(repo Repository, runner *Runner) Service {
+	return &service{repo: repo, runner: runner, now: time.Now}
+}
+
+// CreateRequest defines a schedule. Format defaults to pdf for reports and
+// is always json for dashboards; MaxRetries defaults to DefaultMaxRetries
+// and Enabled to true.
+type CreateRequest struct {
+	TenantID   string                 `json:"-"`
+	CreatedBy  string                 `json:"-"`
+	Name       string                 `json:"name" binding:"required"`
+	Cron       string                 `json:"cron" binding:"required"`
+	Timezone   string                 `json:"timezone"`
+	TargetType string                 `json:"targetType" binding:"required"`
+	TargetID   string                 `json:"targetId" binding:"required"`
+	Format     string                 `json:"format"`
+	Params     map[string]interface{} `json:"params"`
+	Delivery   Delivery               `json:"delivery"`
+	MaxRetries *int                   `json:"maxRetries"`
+	Enabled    *bool                  `json:"enabled"`
+}
+
+// UpdateRequest changes the fields that are set; a delivery replaces the
+// old one when its channel is given. Its secret and header values may be
+// datasource.SecretUnchanged to keep the stored ones.
+type UpdateRequest struct {
+	TenantID   string                 `json:"-"`
+	ID         string                 `json:"-"`
+	Name       string                 `json:"name"`
+	Cron       string                 `json:"cron"`
+	Timezone   *string                `json:"timezone"`
+	TargetType string                 `json:"targetType"`
+	TargetID   string                 `json:"targetId"`
+	Format     string                 `json:"format"`
+	Params     map[string]interface{} `json:"params"`
+	Delivery   Delivery               `json:"delivery"`
+	MaxRetries *int                   `json:"maxRetries"`
+	Enabled    *bool                  `json:"enabled"`
+}
+
+func (s *service) Create(ctx context.Context, req *CreateRequest) (*Schedule, error) {
+	now := s.now()
+	schedule := &Schedule{
+		ID:         fmt.Sprintf("schedule-%d", now.UnixNano()),
+		TenantID:   req.TenantID,
+		Name:       req.Name,
+		Cron:       req.Cron,
+		Timezone:   req.Timezone,
+		TargetType: req.TargetType,
+		TargetID:   req.TargetID,
+		Format:     req.Format,
+		Params:     req.Params,
+		Delivery:   req.Delivery,
+		MaxRetries: DefaultMaxRetries,
+		Enabled:    true,
+		CreatedBy:  req.CreatedBy,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if req.MaxRetries != nil {
+		schedule.MaxRetries = *req.MaxRetries
+	}
+	if req.Enabled != nil {
+		schedule.Enabled = *req.Enabled
+	}
+	if err := s.prepare(schedule, now); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Create(ctx, schedule); err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+func (s *service) Update(ctx context.Context, req *UpdateRequest) (*Schedule, error) {
+	schedule, err := s.Get(ctx, req.ID, req.TenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != "" {
+		schedule.Name = req.Name
+	}
+	if req.Cron != "" {
+		schedule.Cron = req.Cron
+	}
+	if req.Timezone != nil {
+		schedule.Timezone = *req.Timezone
+	}
+	if req.TargetType != "" && req.TargetType != schedule.TargetType {
+		// The old format belongs to the old target type.
+		schedule.TargetType = req.TargetType
+		schedule.Format = ""
+	}
+	if req.TargetID != "" {
+		schedule.TargetID = req.TargetID
+	}
+	if req.Format != "" {
+		schedule.Format = req.Format
+	}
+	if req.Params != nil {
+		schedule.Params = req.Params
+	}
+	if req.Delivery.Channel != "" {
+		schedule.Delivery = mergeDelivery(schedule.Delivery, req.Delivery)
+	}
+	if req.MaxRetries != nil {
+		schedule.MaxRetries = *req.MaxRetries
+	}
+	if req.Enabled != nil {
+		schedule.Enabled = *req.Enabled
+	}
+	now := s.now()
+	schedule.UpdatedAt = now
+	if err := s.prepare(schedule, now); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Update(ctx, schedule); err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+func (s *service) Delete(ctx context.Context, id, tenantID string) error {
+	return s.repo.Delete(ctx, id, tenantID)
+}
+
+func (s *service) Get(ctx context.Context, id, tenantID string) (*Schedule, error) {
+	schedule, err := s.repo.Get(ctx, id, tenantID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return schedule, nil
+}
+
+func (s *service) List(ctx context.Context, tenantID string) ([]*Schedule, error) {
+	return s.repo.List(ctx, tenantID)
+}
+
+// RunNow starts a run outside the cron timetable, even when the schedule is
+// disabled. It returns once the run is recorded, not when it finishes.
+func (s *service) RunNow(ctx context.Context, id, tenantID string) (*Run, error) {
+	schedule, err := s.Get(ctx, id, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	return s.runner.Dispatch(ctx, schedule, TriggerManual)
+}
+
+// Runs returns the latest runs of a schedule, newest first.
+func (s *service) Runs(ctx context.Context, id, tenantID string) ([]*Run, error) {
+	if _, err := s.Get(ctx, id, tenantID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListRuns(ctx, id, tenantID, runHistoryLimit)
+}
+
+// prepare fills defaults, validates the schedule and computes its next run.
+func (s *service) prepare(schedule *Schedule, now time.Time) error {
+	if schedule.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidSchedule)
+	}
+	if schedule.Timezone != "" {
+		if _, err := time.LoadLocation(schedule.Timezone); err != nil {
+			return fmt.Errorf("%w: unknown timezone %q", ErrInvalidSchedule, schedule.Timezone)
+		}
+	}
+	next, err := nextRunAt(schedule.Cron, schedule.Timezone, now)
+	if err != nil {
+		return fmt.Errorf("%w: cron: %v", ErrInvalidSchedule, err)
+	}
+
+	switch schedule.TargetType {
+	case TargetReport:
+		if schedule.Format == "" {
+			schedule.Format = report.ExportFormatPDF
+		}
+		if !reportFormats[schedule.Format] {
+			return fmt.Errorf("%w: unsupported report format %q", ErrInvalidSchedule, schedule.Format)
+		}
+	case TargetDashboard:
+		if schedule.Format == "" {
+			schedule.Format = FormatJSON
+		}
+		if schedule.Format != FormatJSON {
+			return fmt.Errorf("%w: dashboards are delivered as json", ErrInvalidSchedule)
+		}
+	default:
+		return fmt.Errorf("%w: target type must be report or dashboard", ErrInvalidSchedule)
+	}
+	if schedule.TargetID == "" {
+		return fmt.Errorf("%w: target id is required", ErrInvalidSchedule)
+	}
+
+	if schedule.MaxRetries < 0 || schedule.MaxRetries > maxRetriesLimit {
+		return fmt.Errorf("%w: maxRetries must be between 0 and %d", ErrInvalidSchedule, maxRetriesLimit)
+	}
+	if _, err := ResolveParams(schedule.Params, now); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSchedule, err)
+	}
+	if err := validateDelivery(&schedule.Delivery); err != nil {
+		return err
+	}
+
+	schedule.NextRunAt = nil
+	if schedule.Enabled {
+		schedule.NextRunAt = &next
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package schedule
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gujiaweiguo/goreport/internal/datasource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestService(repo *memoryRepo, deliverer Deliverer) *service {
+	svc := NewService(repo, newTestRunner(repo, &fakeRenderer{}, deliverer)).(*service)
+	svc.now = func() time.Time { return time.Date(2026, time.March, 1, 9, 0, 0, 0, time.UTC) }
+	return svc
+}
+
+func validCreateRequest() *CreateRequest {
+	return &CreateRequest{
+		TenantID:   "tenant-1",
+		Name:       "Weekly sales",
+		Cron:       "0 8 * * 1",
+		Timezone:   "Asia/Shanghai",
+		TargetType: TargetReport,
+		TargetID:   "report-1",
+		Params:     map[string]interface{}{"period": []interface{}{"@startOfWeek-1w", "@endOfWeek-1w"}},
+		Delivery:   Delivery{Channel: ChannelWebhook, URL: "https://example.com/hook"},
+	}
+}
+
+func TestService_Create(t *testing.T) {
+	svc := newTestService(newMemoryRepo(), &flakyDeliverer{})
+
+	schedule, err := svc.Create(context.Background(), validCreateRequest())
+	require.NoError(t, err)
+	assert.NotEmpty(t, schedule.ID)
+	assert.Equal(t, "pdf", schedule.Format)
+	assert.Equal(t, DefaultMaxRetries, schedule.MaxRetries)
+	assert.True(t, schedule.Enabled)
+	require.NotNil(t, schedule.NextRunAt)
+	// Monday March 2, 08:00 in Shanghai.
+	assert.Equal(t, time.Date(2026, time.March, 2, 0, 0, 0, 0, time.UTC), schedule.NextRunAt.UTC())
+
+	req := validCreateRequest()
+	req.TargetType = TargetDashboard
+	req.TargetID = "dashboard-1"
+	disabled := false
+	req.Enabled = &disabled
+	schedule, err = svc.Create(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, FormatJSON, schedule.Format)
+	assert.Nil(t, schedule.NextRunAt)
+}
+
+func TestService_Create_Invalid(t *testing.T) {
+	svc := newTestService(newMemoryRepo(), &flakyDeliverer{})
+	retries := 11
+	invalid := map[string]func(*CreateRequest){
+		"cron":       func(r *CreateRequest) { r.Cron = "every monday" },
+		"timezone":   func(r *CreateRequest) { r.Timezone = "Mars/Olympus" },
+		"targetType": func(r *CreateRequest) { r.TargetType = "chart" },
+		"format":     func(r *CreateRequest) { r.Format = "csv" },
+		"dashboard":  func(r *CreateRequest) { r.TargetType = TargetDashboard; r.Format = "pdf" },
+		"retries":    func(r *CreateRequest) { r.MaxRetries = &retries },
+		"params":     func(r *CreateRequest) { r.Params = map[string]interface{}{"day": "@someday"} },
+		"delivery":   func(r *CreateRequest) { r.Delivery = Delivery{Channel: ChannelEmail} },
+	}
+	for name, mutate := range invalid {
+		req := validCreateRequest()
+		mutate(req)
+		_, err := svc.Create(context.Background(), req)
+		assert.ErrorIs(t, err, ErrInvalidSchedule, name)
+	}
+}
+
+func TestService_Update(t *testing.T) {
+	repo := newMemoryRepo()
+	svc := newTestService(repo, &flakyDeliverer{})
+	created, err := svc.Create(context.Background(), validCreateRequest())
+	require.NoError(t, err)
+
+	disabled := false
+	updated, err := svc.Update(context.Background(), &UpdateRequest{
+		ID: created.ID, TenantID: "tenant-1", TargetType: TargetDashboard, TargetID: "dashboard-1", Enabled: &disabled,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, FormatJSON, updated.Format, "the report format does not carry over to a dashboard")
+	assert.Equal(t, "Weekly sales", updated.Name)
+	assert.Nil(t, updated.NextRunAt)
+
+	enabled := true
+	updated, err = svc.Update(context.Background(), &UpdateRequest{ID: created.ID, TenantID: "tenant-1", Cron: "@hourly", Enabled: &enabled})
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, time.March, 1, 10, 0, 0, 0, time.UTC), updated.NextRunAt.UTC())
+
+	_, err = svc.Update(context.Background(), &UpdateRequest{ID: created.ID, TenantID: "tenant-1", Cron: "bad"})
+	assert.ErrorIs(t, err, ErrInvalidSchedule)
+
+	_, err = svc.Update(context.Background(), &UpdateRequest{ID: created.ID, TenantID: "tenant-2", Name: "x"})
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestService_Update_KeepsUnchangedSecrets(t *testing.T) {
+	repo := newMemoryRepo()
+	svc := newTestService(repo, &flakyDeliverer{})
+	req := validCreateRequest()
+	req.Delivery.Secret = "hook-secret"
+	req.Delivery.Headers = map[string]string{"Authorization": "Bearer abc", "X-Team": "sales"}
+	created, err := svc.Create(context.Background(), req)
+	require.NoError(t, err)
+
+	updated, err := svc.Update(context.Background(), &UpdateRequest{
+		ID: created.ID, TenantID: "tenant-1",
+		Delivery: Delivery{
+			Channel: ChannelWebhook,
+			URL:     "https://example.com/other",
+			Secret:  datasource.SecretUnchanged,
+			Headers: map[string]string{
+				"Authorization": datasource.SecretUnchanged,
+				"X-Team":        "finance",
+				"X-Missing":     datasource.SecretUnchanged,
+			},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/other", updated.Delivery.URL)
+	assert.Equal(t, "hook-secret", updated.Delivery.Secret)
+	assert.Equal(t, map[string]string{"Authorization": "Bearer abc", "X-Team": "finance"}, updated.Delivery.Headers)
+
+	updated, err = svc.Update(context.Background(), &UpdateRequest{
+		ID: created.ID, TenantID: "tenant-1",
+		Delivery: Delivery{Channel: ChannelWebhook, URL: "https://example.com/other"},
+	})
+	require.NoError(t, err)
+	assert.Empty(t, updated.Delivery.Secret, "a delivery without the secret removes it")
+	assert.Empty(t, updated.Delivery.Headers)
+}
+
+func TestService_RunNowAndRuns(t *testing.T) {
+	repo := newMemoryRepo()
+	deliverer := &flakyDeliverer{}
+	svc := newTestService(repo, deliverer)
+	req := validCreateRequest()
+	disabled := false
+	req.Enabled = &disabled
+	created, err := svc.Create(context.Background(), req)
+	require.NoError(t, err)
+
+	run, err := svc.RunNow(context.Background(), created.ID, "tenant-1")
+	require.NoError(t, err)
+	assert.Equal(t, TriggerManual, run.Trigger)
+	assert.Equal(t, RunSucceeded, repo.waitRun(t).Status, "a disabled schedule can still be run by hand")
+
+	runs, err := svc.Runs(context.Background(), created.ID, "tenant-1")
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	assert.Equal(t, run.ID, runs[0].ID)
+
+	_, err = svc.RunNow(context.Background(), created.ID, "tenant-2")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = svc.Runs(context.Background(), "missing", "tenant-1")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
@@ -0,0 +1,68 @@
+package schedule
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gujiaweiguo/goreport/internal/dashboard"
+	"github.com/gujiaweiguo/goreport/internal/models"
+	"github.com/gujiaweiguo/goreport/internal/report"
+)
+
+const FormatJSON = "json"
+
+// Renderer produces the file a schedule delivers.
+type Renderer interface {
+	Render(ctx context.Context, schedule *Schedule, params map[string]interface{}, now time.Time) (*Artifact, error)
+}
+
+type targetRenderer struct {
+	reports    report.Service
+	dashboards dashboard.Service
+}
+
+// NewRenderer renders reports through the report export and dashboards as a
+// JSON snapshot of their definition.
+func NewRenderer(reports report.Service, dashboards dashboard.Service) Renderer {
+	return &targetRenderer{reports: reports, dashboards: dashboards}
+}
+
+// DashboardSnapshot is the file delivered for a dashboard.
+type DashboardSnapshot struct {
+	Dashboard   *models.Dashboard `json:"dashboard"`
+	GeneratedAt time.Time         `json:"generatedAt"`
+}
+
+func (r *targetRenderer) Render(ctx context.Context, schedule *Schedule, params map[string]interface{}, now time.Time) (*Artifact, error) {
+	fileName := fmt.Sprintf("%s-%s.%s", schedule.TargetID, now.Format("20060102-150405"), schedule.Format)
+
+	switch schedule.TargetType {
+	case TargetReport:
+		var buf bytes.Buffer
+		req := &report.ExportRequest{
+			TenantID: schedule.TenantID,
+			ID:       schedule.TargetID,
+			Format:   schedule.Format,
+			Params:   params,
+		}
+		if err := r.reports.Export(ctx, req, &buf); err != nil {
+			return nil, err
+		}
+		return &Artifact{FileName: fileName, ContentType: report.ExportContentType(schedule.Format), Data: buf.Bytes()}, nil
+	case TargetDashboard:
+		d, err := r.dashboards.Get(ctx, schedule.TargetID, schedule.TenantID)
+		if err != nil {
+			return nil, err
+		}
+		data, err := json.Marshal(&DashboardSnapshot{Dashboard: d, GeneratedAt: now})
+		if err != nil {
+			return nil, err
+		}
+		return &Artifact{FileName: fileName, ContentType: "application/json", Data: data}, nil
+	default:
+		return nil, fmt.Errorf("%w: unknown target type %q", ErrInvalidSchedule, schedule.TargetType)
+	}
+}
@@ -0,0 +1,276 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/gujiaweiguo/goreport/internal/config"
+)
+
+// envelopePrefix marks an encrypted value. A sealed value reads
+// "enc:v1:<key id>:<wrapped data key>:<ciphertext>", where the data key is
+// random per value and wrapped by the master key.
+const envelopePrefix = "enc:v1:"
+
+const keySize = 32
+
+var (
+	ErrNoMasterKey = errors.New("secrets: no master key configured")
+	ErrUnknownKey  = errors.New("secrets: value was sealed with an unknown master key")
+	ErrMalformed   = errors.New("secrets: malformed sealed value")
+	ErrReserved    = errors.New("secrets: plaintext must not start with the " + envelopePrefix + " prefix")
+)
+
+var (
+	keyringMu sync.RWMutex
+	keyring   = &Keyring{keys: map[string]cipher.AEAD{}}
+)
+
+// Init replaces the shared keyring with one built from cfg.
+func Init(cfg *config.DatasourceConfig) error {
+	k, err := NewKeyring(cfg.SecretKey, cfg.PreviousSecretKeys...)
+	if err != nil {
+		return err
+	}
+	keyringMu.Lock()
+	keyring = k
+	keyringMu.Unlock()
+	return nil
+}
+
+// Default returns the shared keyring. Without Init it has no master key and
+// leaves values in plaintext.
+func Default() *Keyring {
+	keyringMu.RLock()
+	defer keyringMu.RUnlock()
+	return keyring
+}
+
+// Keyring seals values under its current master key and opens values sealed
+// under the current or any previous key.
+type Keyring struct {
+	currentID string
+	keys      map[string]cipher.AEAD
+}
+
+// NewKeyring builds a keyring from base64-encoded 32-byte keys. An empty
+// current key yields a keyring that stores plaintext but can still open
+// values sealed under previous keys.
+func NewKeyring(current string, previous ...string) (*Keyring, error) {
+	k := &Keyring{keys: map[string]cipher.AEAD{}}
+	if current = strings.TrimSpace(current); current != "" {
+		id, aead, err := parseKey(current)
+		if err != nil {
+			return nil, err
+		}
+		k.keys[id] = aead
+		k.currentID = id
+	}
+	for _, encoded := range previous {
+		if encoded = strings.TrimSpace(encoded); encoded == "" {
+			continue
+		}
+		id, aead, err := parseKey(encoded)
+		if err != nil {
+			return nil, err
+		}
+		k.keys[id] = aead
+	}
+	return k, nil
+}
+
+// Enabled reports whether the keyring has a master key to seal with.
+func (k *Keyring) Enabled() bool {
+	return k.currentID != ""
+}
+
+// Seal encrypts value under the current master key. Empty values are
+// returned unchanged, as is everything when no master key is configured.
+// Plaintext carrying the envelope prefix is rejected, since Open would
+// mistake it for a sealed value.
+func (k *Keyring) Seal(value string) (string, error) {
+	if IsSealed(value) {
+		return "", ErrReserved
+	}
+	if value == "" || !k.Enabled() {
+		return value, nil
+	}
+
+	dataKey := make([]byte, keySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return "", err
+	}
+	data, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := seal(data, []byte(value), nil)
+	if err != nil {
+		return "", err
+	}
+	wrapped, err := seal(k.keys[k.currentID], dataKey, []byte(k.currentID))
+	if err != nil {
+		return "", err
+	}
+	return envelope(k.currentID, wrapped, ciphertext), nil
+}
+
+// Open decrypts a sealed value. Values without the envelope prefix are
+// legacy plaintext and are returned as is.
+func (k *Keyring) Open(value string) (string, error) {
+	if !IsSealed(value) {
+		return value, nil
+	}
+	id, wrapped, ciphertext, err := parseEnvelope(value)
+	if err != nil {
+		return "", err
+	}
+	dataKey, err := k.unwrap(id, wrapped)
+	if err != nil {
+		return "", err
+	}
+	data, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(data, ciphertext, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// Rewrap brings value under the current master key: plaintext is sealed and
+// a value sealed under a previous key has its data key re-wrapped, leaving
+// the ciphertext itself untouched. It reports whether value changed.
+func (k *Keyring) Rewrap(value string) (string, bool, error) {
+	if value == "" {
+		return value, false, nil
+	}
+	if !k.Enabled() {
+		return "", false, ErrNoMasterKey
+	}
+	if !IsSealed(value) {
+		sealed, err := k.Seal(value)
+		return sealed, err == nil, err
+	}
+
+	id, wrapped, ciphertext, err := parseEnvelope(value)
+	if err != nil {
+		return "", false, err
+	}
+	if id == k.currentID {
+		return value, false, nil
+	}
+	dataKey, err := k.unwrap(id, wrapped)
+	if err != nil {
+		return "", false, err
+	}
+	rewrapped, err := seal(k.keys[k.currentID], dataKey, []byte(k.currentID))
+	if err != nil {
+		return "", false, err
+	}
+	return envelope(k.currentID, rewrapped, ciphertext), true, nil
+}
+
+// IsSealed reports whether value is an encrypted envelope.
+func IsSealed(value string) bool {
+	return strings.HasPrefix(value, envelopePrefix)
+}
+
+// GenerateKey returns a new random master key in the encoding NewKeyring
+// expects.
+func GenerateKey() (string, error) {
+	key := make([]byte, keySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+func (k *Keyring) unwrap(id string, wrapped []byte) ([]byte, error) {
+	master, ok := k.keys[id]
+	if !ok {
+		if len(k.keys) == 0 {
+			return nil, ErrNoMasterKey
+		}
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, id)
+	}
+	return open(master, wrapped, []byte(id))
+}
+
+func parseKey(encoded string) (string, cipher.AEAD, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", nil, fmt.Errorf("secrets: master key is not valid base64: %w", err)
+	}
+	if len(key) != keySize {
+		return "", nil, fmt.Errorf("secrets: master key must be %d bytes, got %d", keySize, len(key))
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", nil, err
+	}
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:4]), aead, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal returns nonce || ciphertext.
+func seal(aead cipher.AEAD, plaintext, additional []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additional), nil
+}
+
+func open(aead cipher.AEAD, sealed, additional []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrMalformed
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, additional)
+	if err != nil {
+		return nil, fmt.Errorf("secrets: decryption failed: %w", err)
+	}
+	return plaintext, nil
+}
+
+func envelope(id string, wrapped, ciphertext []byte) string {
+	return envelopePrefix + id + ":" +
+		base64.RawStdEncoding.EncodeToString(wrapped) + ":" +
+		base64.RawStdEncoding.EncodeToString(ciphertext)
+}
+
+func parseEnvelope(value string) (string, []byte, []byte, error) {
+	parts := strings.Split(strings.TrimPrefix(value, envelopePrefix), ":")
+	if len(parts) != 3 || parts[0] == "" {
+		return "", nil, nil, ErrMalformed
+	}
+	wrapped, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", nil, nil, ErrMalformed
+	}
+	ciphertext, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", nil, nil, ErrMalformed
+	}
+	return parts[0], wrapped, ciphertext, nil
+}
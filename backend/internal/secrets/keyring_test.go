@@ -0,0 +1,120 @@
+package secrets
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestKey(t *testing.T) string {
+	t.Helper()
+	key, err := GenerateKey()
+	require.NoError(t, err)
+	return key
+}
+
+func TestKeyring_SealOpen(t *testing.T) {
+	k, err := NewKeyring(newTestKey(t))
+	require.NoError(t, err)
+	assert.True(t, k.Enabled())
+
+	sealed, err := k.Seal("s3cret")
+	require.NoError(t, err)
+	assert.True(t, IsSealed(sealed))
+	assert.NotContains(t, sealed, "s3cret")
+
+	again, err := k.Seal("s3cret")
+	require.NoError(t, err)
+	assert.NotEqual(t, sealed, again, "each value gets its own data key and nonce")
+
+	_, err = k.Seal(sealed)
+	assert.ErrorIs(t, err, ErrReserved)
+
+	opened, err := k.Open(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", opened)
+
+	empty, err := k.Seal("")
+	require.NoError(t, err)
+	assert.Empty(t, empty)
+
+	legacy, err := k.Open("plain")
+	require.NoError(t, err)
+	assert.Equal(t, "plain", legacy)
+
+	tampered := sealed[:len(sealed)-2] + "AA"
+	_, err = k.Open(tampered)
+	assert.ErrorContains(t, err, "decryption failed")
+
+	_, err = k.Open(envelopePrefix + "abc")
+	assert.ErrorIs(t, err, ErrMalformed)
+}
+
+func TestKeyring_WithoutMasterKey(t *testing.T) {
+	k, err := NewKeyring("")
+	require.NoError(t, err)
+	assert.False(t, k.Enabled())
+
+	value, err := k.Seal("plain")
+	require.NoError(t, err)
+	assert.Equal(t, "plain", value)
+
+	_, err = k.Seal("enc:v1:plain")
+	assert.ErrorIs(t, err, ErrReserved)
+
+	other, err := NewKeyring(newTestKey(t))
+	require.NoError(t, err)
+	sealed, err := other.Seal("s3cret")
+	require.NoError(t, err)
+	_, err = k.Open(sealed)
+	assert.ErrorIs(t, err, ErrNoMasterKey)
+
+	_, _, err = k.Rewrap("plain")
+	assert.ErrorIs(t, err, ErrNoMasterKey)
+}
+
+func TestKeyring_Rotation(t *testing.T) {
+	oldKey, newKey := newTestKey(t), newTestKey(t)
+	old, err := NewKeyring(oldKey)
+	require.NoError(t, err)
+	sealed, err := old.Seal("s3cret")
+	require.NoError(t, err)
+
+	rotated, err := NewKeyring(newKey, oldKey)
+	require.NoError(t, err)
+	opened, err := rotated.Open(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", opened, "previous keys still open old values")
+
+	rewrapped, changed, err := rotated.Rewrap(sealed)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, sealed[strings.LastIndex(sealed, ":"):], rewrapped[strings.LastIndex(rewrapped, ":"):], "ciphertext is kept")
+
+	_, changed, err = rotated.Rewrap(rewrapped)
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	fresh, err := NewKeyring(newKey)
+	require.NoError(t, err)
+	opened, err = fresh.Open(rewrapped)
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", opened)
+	_, err = fresh.Open(sealed)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+
+	encrypted, changed, err := fresh.Rewrap("legacy")
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.True(t, IsSealed(encrypted))
+}
+
+func TestNewKeyring_InvalidKey(t *testing.T) {
+	_, err := NewKeyring("not base64!")
+	assert.ErrorContains(t, err, "base64")
+
+	_, err = NewKeyring("c2hvcnQ=")
+	assert.ErrorContains(t, err, "must be 32 bytes")
+}
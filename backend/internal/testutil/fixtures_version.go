@@ -0,0 +1,80 @@
+package testutil
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/gujiaweiguo/goreport/internal/version"
+	"gorm.io/gorm"
+)
+
+// VersionRepository keeps resource versions in memory for service tests.
+type VersionRepository struct {
+	mu       sync.Mutex
+	Versions []*version.Version
+	// CreateErr, when set, fails every Create.
+	CreateErr error
+}
+
+func NewVersionRepository() *VersionRepository {
+	return &VersionRepository{}
+}
+
+// WithTx returns r; the in-memory repository has no transactions.
+func (r *VersionRepository) WithTx(tx *gorm.DB) version.Repository {
+	return r
+}
+
+func (r *VersionRepository) Create(ctx context.Context, v *version.Version) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.CreateErr != nil {
+		return r.CreateErr
+	}
+	v.Number = 1
+	for _, existing := range r.Versions {
+		if existing.ResourceType == v.ResourceType && existing.ResourceID == v.ResourceID && existing.Number >= v.Number {
+			v.Number = existing.Number + 1
+		}
+	}
+	r.Versions = append(r.Versions, v)
+	return nil
+}
+
+func (r *VersionRepository) List(ctx context.Context, tenantID, resourceType, resourceID string) ([]*version.Version, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	versions := []*version.Version{}
+	for _, v := range r.Versions {
+		if v.TenantID == tenantID && v.ResourceType == resourceType && v.ResourceID == resourceID {
+			listed := *v
+			listed.Snapshot = nil
+			versions = append(versions, &listed)
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].Number > versions[j].Number })
+	return versions, nil
+}
+
+func (r *VersionRepository) Get(ctx context.Context, tenantID, resourceType, resourceID string, number int) (*version.Version, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, v := range r.Versions {
+		if v.TenantID == tenantID && v.ResourceType == resourceType && v.ResourceID == resourceID && v.Number == number {
+			return v, nil
+		}
+	}
+	return nil, version.ErrNotFound
+}
+
+func (r *VersionRepository) Latest(ctx context.Context, tenantID, resourceType, resourceID string) (*version.Version, error) {
+	versions, err := r.List(ctx, tenantID, resourceType, resourceID)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, version.ErrNotFound
+	}
+	return r.Get(ctx, tenantID, resourceType, resourceID, versions[0].Number)
+}
@@ -0,0 +1,115 @@
+package version
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+const (
+	ChangeAdded    = "added"
+	ChangeRemoved  = "removed"
+	ChangeModified = "modified"
+)
+
+// Change is one difference between two JSON documents. Path addresses the
+// value with dots for object keys and [i] for array elements.
+type Change struct {
+	Path string      `json:"path"`
+	Kind string      `json:"kind"`
+	From interface{} `json:"from,omitempty"`
+	To   interface{} `json:"to,omitempty"`
+}
+
+// Diff lists what changed between two versions of a resource. Version 0 is
+// the current draft.
+type Diff struct {
+	From    int      `json:"from"`
+	To      int      `json:"to"`
+	Changes []Change `json:"changes"`
+}
+
+// DiffJSON compares two JSON documents.
+func DiffJSON(from, to []byte) ([]Change, error) {
+	var a, b interface{}
+	if len(from) > 0 {
+		if err := json.Unmarshal(from, &a); err != nil {
+			return nil, fmt.Errorf("diff: %w", err)
+		}
+	}
+	if len(to) > 0 {
+		if err := json.Unmarshal(to, &b); err != nil {
+			return nil, fmt.Errorf("diff: %w", err)
+		}
+	}
+	return DiffValues("", a, b), nil
+}
+
+// DiffValues compares two decoded JSON values, descending into objects and
+// arrays so a change is reported at the deepest path it occurs.
+func DiffValues(path string, from, to interface{}) []Change {
+	changes := []Change{}
+	switch a := from.(type) {
+	case map[string]interface{}:
+		b, ok := to.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(a)+len(b))
+		for key := range a {
+			keys = append(keys, key)
+		}
+		for key := range b {
+			if _, ok := a[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			child := key
+			if path != "" {
+				child = path + "." + key
+			}
+			va, inA := a[key]
+			vb, inB := b[key]
+			switch {
+			case !inA:
+				changes = append(changes, Change{Path: child, Kind: ChangeAdded, To: vb})
+			case !inB:
+				changes = append(changes, Change{Path: child, Kind: ChangeRemoved, From: va})
+			default:
+				changes = append(changes, DiffValues(child, va, vb)...)
+			}
+		}
+		return changes
+	case []interface{}:
+		b, ok := to.([]interface{})
+		if !ok {
+			break
+		}
+		for i := 0; i < len(a) || i < len(b); i++ {
+			child := path + "[" + strconv.Itoa(i) + "]"
+			switch {
+			case i >= len(a):
+				changes = append(changes, Change{Path: child, Kind: ChangeAdded, To: b[i]})
+			case i >= len(b):
+				changes = append(changes, Change{Path: child, Kind: ChangeRemoved, From: a[i]})
+			default:
+				changes = append(changes, DiffValues(child, a[i], b[i])...)
+			}
+		}
+		return changes
+	}
+	if !reflect.DeepEqual(from, to) {
+		kind := ChangeModified
+		if from == nil {
+			kind = ChangeAdded
+		} else if to == nil {
+			kind = ChangeRemoved
+		}
+		changes = append(changes, Change{Path: path, Kind: kind, From: from, To: to})
+	}
+	return changes
+}
@@ -0,0 +1,40 @@
+package version
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffJSON(t *testing.T) {
+	changes, err := DiffJSON(
+		[]byte(`{"name":"Sales","config":{"cells":[{"text":"A"},{"text":"B"}],"page":{"size":"A4"}}}`),
+		[]byte(`{"name":"Sales","config":{"cells":[{"text":"A","bold":true}],"page":{"size":"A3"},"title":"Q1"}}`),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, []Change{
+		{Path: "config.cells[0].bold", Kind: ChangeAdded, To: true},
+		{Path: "config.cells[1]", Kind: ChangeRemoved, From: map[string]interface{}{"text": "B"}},
+		{Path: "config.page.size", Kind: ChangeModified, From: "A4", To: "A3"},
+		{Path: "config.title", Kind: ChangeAdded, To: "Q1"},
+	}, changes)
+
+	changes, err = DiffJSON([]byte(`{"a":1}`), []byte(`{"a":1}`))
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+
+	changes, err = DiffJSON([]byte(`{"a":[1]}`), []byte(`{"a":"x"}`))
+	require.NoError(t, err)
+	assert.Equal(t, []Change{{Path: "a", Kind: ChangeModified, From: []interface{}{1.0}, To: "x"}}, changes, "a changed type is replaced whole")
+
+	_, err = DiffJSON([]byte(`{`), []byte(`{}`))
+	assert.Error(t, err)
+}
+
+func TestCanEdit(t *testing.T) {
+	assert.True(t, CanEdit([]string{"viewer", "admin"}))
+	assert.True(t, CanEdit([]string{"user"}))
+	assert.False(t, CanEdit([]string{"viewer"}))
+	assert.False(t, CanEdit(nil))
+}
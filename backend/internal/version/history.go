@@ -0,0 +1,84 @@
+package version
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// History records and reads the versions of one resource type on behalf of
+// its service. Without a repository it keeps no history.
+type History struct {
+	repo         Repository
+	resourceType string
+}
+
+func NewHistory(repo Repository, resourceType string) *History {
+	return &History{repo: repo, resourceType: resourceType}
+}
+
+// WithTx returns the history recording in tx; a nil tx leaves it unchanged.
+func (h *History) WithTx(tx *gorm.DB) *History {
+	if h.repo == nil || tx == nil {
+		return h
+	}
+	return NewHistory(h.repo.WithTx(tx), h.resourceType)
+}
+
+// Record stores snapshot as the next version of the resource.
+func (h *History) Record(ctx context.Context, tenantID, resourceID, createdBy, comment string, snapshot interface{}) (*Version, error) {
+	if h.repo == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil, err
+	}
+	version := &Version{
+		ID:           fmt.Sprintf("version-%d", time.Now().UnixNano()),
+		TenantID:     tenantID,
+		ResourceType: h.resourceType,
+		ResourceID:   resourceID,
+		Snapshot:     data,
+		Comment:      comment,
+		CreatedBy:    createdBy,
+		CreatedAt:    time.Now(),
+	}
+	if err := h.repo.Create(ctx, version); err != nil {
+		return nil, fmt.Errorf("record version: %w", err)
+	}
+	return version, nil
+}
+
+func (h *History) List(ctx context.Context, tenantID, resourceID string) ([]*Version, error) {
+	if h.repo == nil {
+		return []*Version{}, nil
+	}
+	return h.repo.List(ctx, tenantID, h.resourceType, resourceID)
+}
+
+// Get returns a version with its snapshot; number 0 is the latest.
+func (h *History) Get(ctx context.Context, tenantID, resourceID string, number int) (*Version, error) {
+	if h.repo == nil || number < 0 {
+		return nil, ErrNotFound
+	}
+	if number == 0 {
+		return h.repo.Latest(ctx, tenantID, h.resourceType, resourceID)
+	}
+	return h.repo.Get(ctx, tenantID, h.resourceType, resourceID, number)
+}
+
+// Load reads a version's snapshot into snapshot.
+func (h *History) Load(ctx context.Context, tenantID, resourceID string, number int, snapshot interface{}) (*Version, error) {
+	version, err := h.Get(ctx, tenantID, resourceID, number)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(version.Snapshot, snapshot); err != nil {
+		return nil, fmt.Errorf("version %d: %w", version.Number, err)
+	}
+	return version, nil
+}
@@ -0,0 +1,40 @@
+package version
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	ResourceDashboard = "dashboard"
+	ResourceReport    = "report"
+	ResourceChart     = "chart"
+)
+
+// Status values shared by dashboards, reports and charts. A draft has never
+// been published; a published resource keeps serving the version its
+// PublishedVersion pins to viewers while editors save further drafts. One
+// published before versioning pins none and serves its current row.
+const (
+	StatusDraft     = 0
+	StatusPublished = 1
+	StatusDisabled  = 2
+)
+
+// Version is a snapshot of a resource taken on save, numbered from 1 per
+// resource. Snapshot holds the resource's own snapshot type as JSON.
+type Version struct {
+	ID           string          `gorm:"primaryKey;type:varchar(36)" json:"id"`
+	TenantID     string          `gorm:"index;type:varchar(36)" json:"tenantId"`
+	ResourceType string          `gorm:"type:varchar(20);uniqueIndex:idx_resource_version" json:"resourceType"`
+	ResourceID   string          `gorm:"type:varchar(36);uniqueIndex:idx_resource_version" json:"resourceId"`
+	Number       int             `gorm:"uniqueIndex:idx_resource_version" json:"number"`
+	Snapshot     json.RawMessage `gorm:"type:json" json:"snapshot,omitempty"`
+	Comment      string          `gorm:"type:varchar(500)" json:"comment"`
+	CreatedBy    string          `gorm:"type:varchar(36)" json:"createdBy"`
+	CreatedAt    time.Time       `json:"createdAt"`
+}
+
+func (Version) TableName() string {
+	return "resource_versions"
+}
@@ -0,0 +1,92 @@
+package version
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+var ErrNotFound = errors.New("version not found")
+
+type Repository interface {
+	// Create numbers the version after the latest of its resource.
+	Create(ctx context.Context, version *Version) error
+	// List returns the versions of a resource newest first, without their
+	// snapshots.
+	List(ctx context.Context, tenantID, resourceType, resourceID string) ([]*Version, error)
+	Get(ctx context.Context, tenantID, resourceType, resourceID string, number int) (*Version, error)
+	Latest(ctx context.Context, tenantID, resourceType, resourceID string) (*Version, error)
+	// WithTx returns the repository bound to tx, so versions are recorded in
+	// the transaction that writes their resource.
+	WithTx(tx *gorm.DB) Repository
+}
+
+type repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db: db}
+}
+
+func (r *repository) WithTx(tx *gorm.DB) Repository {
+	return &repository{db: tx}
+}
+
+func (r *repository) Create(ctx context.Context, version *Version) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var latest int
+		if err := tx.Model(&Version{}).
+			Where("resource_type = ? AND resource_id = ?", version.ResourceType, version.ResourceID).
+			Select("COALESCE(MAX(number), 0)").
+			Scan(&latest).Error; err != nil {
+			return err
+		}
+		version.Number = latest + 1
+		// The unique index on the resource and number fails a concurrent save
+		// that read the same latest number.
+		return tx.Create(version).Error
+	})
+}
+
+func (r *repository) List(ctx context.Context, tenantID, resourceType, resourceID string) ([]*Version, error) {
+	var versions []*Version
+	if err := r.db.WithContext(ctx).
+		Omit("snapshot").
+		Where("tenant_id = ? AND resource_type = ? AND resource_id = ?", tenantID, resourceType, resourceID).
+		Order("number desc").
+		Find(&versions).Error; err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+func (r *repository) Get(ctx context.Context, tenantID, resourceType, resourceID string, number int) (*Version, error) {
+	var version Version
+	err := r.db.WithContext(ctx).
+		Where("tenant_id = ? AND resource_type = ? AND resource_id = ? AND number = ?", tenantID, resourceType, resourceID, number).
+		First(&version).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &version, nil
+}
+
+func (r *repository) Latest(ctx context.Context, tenantID, resourceType, resourceID string) (*Version, error) {
+	var version Version
+	err := r.db.WithContext(ctx).
+		Where("tenant_id = ? AND resource_type = ? AND resource_id = ?", tenantID, resourceType, resourceID).
+		Order("number desc").
+		First(&version).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &version, nil
+}
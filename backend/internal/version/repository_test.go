@@ -0,0 +1,57 @@
+package version_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gujiaweiguo/goreport/internal/testutil"
+	. "github.com/gujiaweiguo/goreport/internal/version"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRepository(t *testing.T) {
+	repo := NewRepository(nil)
+	assert.NotNil(t, repo)
+}
+
+func TestRepository_History(t *testing.T) {
+	db := testutil.SetupMySQLTestDB(t)
+	require.NoError(t, db.AutoMigrate(&Version{}))
+	t.Cleanup(func() {
+		testutil.CloseDB(db)
+	})
+	testutil.EnsureTenants(db, t)
+
+	ctx := context.Background()
+	history := NewHistory(NewRepository(db), ResourceDashboard)
+	resourceID := fmt.Sprintf("dashboard-%d", time.Now().UnixNano())
+
+	for _, name := range []string{"first", "second"} {
+		_, err := history.Record(ctx, "tenant-1", resourceID, "user-1", "", map[string]string{"name": name})
+		require.NoError(t, err)
+	}
+
+	versions, err := history.List(ctx, "tenant-1", resourceID)
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	assert.Equal(t, 2, versions[0].Number)
+	assert.Empty(t, versions[0].Snapshot)
+
+	var snapshot map[string]string
+	latest, err := history.Load(ctx, "tenant-1", resourceID, 0, &snapshot)
+	require.NoError(t, err)
+	assert.Equal(t, 2, latest.Number)
+	assert.Equal(t, "second", snapshot["name"])
+
+	first, err := history.Get(ctx, "tenant-1", resourceID, 1)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"name":"first"}`, string(first.Snapshot))
+
+	_, err = history.Get(ctx, "tenant-2", resourceID, 1)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = history.Get(ctx, "tenant-1", resourceID, 3)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
@@ -0,0 +1,41 @@
+package version
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gujiaweiguo/goreport/internal/auth"
+)
+
+type publishedKey struct{}
+
+// WithPublished marks ctx as a viewer's: services load the published version
+// of a dashboard, report or chart rather than its draft, and hide those
+// never published.
+func WithPublished(ctx context.Context) context.Context {
+	return context.WithValue(ctx, publishedKey{}, true)
+}
+
+func Published(ctx context.Context) bool {
+	published, _ := ctx.Value(publishedKey{}).(bool)
+	return published
+}
+
+// CanEdit reports whether the roles may see and edit drafts.
+func CanEdit(roles []string) bool {
+	for _, role := range roles {
+		if role == "admin" || role == "user" {
+			return true
+		}
+	}
+	return false
+}
+
+// ViewContext is the request context, marked published unless the caller
+// may edit.
+func ViewContext(c *gin.Context) context.Context {
+	if CanEdit(auth.GetRoles(c)) {
+		return c.Request.Context()
+	}
+	return WithPublished(c.Request.Context())
+}